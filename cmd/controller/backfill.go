@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/chains/pkg/reconciler/chainsconfig"
+	"github.com/tektoncd/chains/pkg/reconciler/taskrun"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/system"
+)
+
+// backfill signs the unsigned TaskRuns that completed within window, then exits. With
+// useFinalizer, the TaskRuns get the finalizer while they're signed, as with the controller.
+func backfill(ctx context.Context, namespace string, window time.Duration, useFinalizer bool) {
+	cfg := injection.ParseAndGetRESTConfigOrDie()
+	kubeClient := kubernetes.NewForConfigOrDie(cfg)
+	pipelineClient := versioned.NewForConfigOrDie(cfg)
+
+	// The configuration is read like the controller reads it.
+	chainsConfig, err := chainsconfig.Load(ctx, dynamic.NewForConfigOrDie(cfg), kubeClient, system.Namespace(), config.OverridesFromContext(ctx))
+	if err != nil {
+		log.Fatalf("Error reading the configuration: %v", err)
+	}
+	ctx = config.ToContext(ctx, chainsConfig)
+
+	// Backfilling exits once it's done, so it doesn't queue transparency log uploads.
+	r := taskrun.NewReconciler(kubeClient, pipelineClient, nil)
+	r.UseFinalizer = useFinalizer
+	if r.NamespaceConfigs, err = taskrun.NewNamespaceConfigLister(ctx, kubeClient); err != nil {
+		log.Fatalf("Error listing the %s of the namespaces: %v", config.ChainsConfig, err)
+	}
+	if r.Namespaces, err = taskrun.NewNamespaceLister(ctx, kubeClient); err != nil {
+		log.Fatalf("Error listing the namespaces: %v", err)
+	}
+	count, err := r.Backfill(ctx, namespace, time.Now().Add(-window))
+	log.Printf("Backfilled %d TaskRuns", count)
+	if err != nil {
+		log.Fatalf("Error backfilling TaskRuns: %v", err)
+	}
+}
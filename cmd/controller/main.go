@@ -15,18 +15,122 @@ package main
 
 import (
 	"flag"
+	"log"
+	"os"
+	"strings"
 
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/chains/pkg/reconciler/taskrun"
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection"
 	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/leaderelection"
 	"knative.dev/pkg/signals"
 )
 
-var namespace = flag.String("namespace", "", "Namespace to restrict informer to. Optional, defaults to all namespaces.")
+var (
+	namespace            = flag.String("namespace", "", "Namespace to restrict informer to. Optional, defaults to all namespaces.")
+	threadsPerController = flag.Int("threads-per-controller", controller.DefaultThreadsPerController, "Number of TaskRuns to sign concurrently.")
+	useFinalizer         = flag.Bool("use-finalizer", true, "Add a finalizer to completed TaskRuns so they can't be deleted before they are signed. When false, the finalizer is removed from the TaskRuns that have it.")
+	backfillWindow       = flag.Duration("backfill", 0, "Sign the unsigned TaskRuns that completed within this duration and exit, instead of running the controller.")
+	shutdownGracePeriod  = flag.Duration("shutdown-grace-period", taskrun.ShutdownGracePeriod, "How long TaskRuns that are being signed are given to finish when the controller is stopped.")
+	stripCache           = flag.Bool("strip-cached-taskruns", false, "Strip the fields that aren't needed to decide whether to sign a TaskRun from cached TaskRuns, to reduce memory use.")
+	taskRunSelector      = flag.String("taskrun-selector", "", "Label selector that limits the TaskRuns watched and signed. Optional, defaults to all TaskRuns.")
+	remoteClusters       = flag.String("remote-clusters", "", "Directory of kubeconfig files for remote clusters to also sign TaskRuns in. Optional.")
+	profiling            = flag.Bool("profiling", false, "Serve the pprof profiles of the controller under /debug/pprof/ on the -debug-port.")
+	pipelinesNamespace   = flag.String("pipelines-namespace", chains.PipelinesNamespace, "Namespace Tekton Pipelines is installed in, whose feature flags are recorded in provenance.")
+	readinessPort        = flag.Int("readiness-port", 0, "Port to serve the readiness endpoint on, which reports ready once the configured signers passed their probe. Disabled when 0.")
+	debugPort            = flag.Int("debug-port", 0, "Port to serve the endpoint listing the TaskRuns waiting to be signed, being signed and that failed on, on localhost only. Disabled when 0.")
+	backlogAfter         = flag.Duration("backlog-after", taskrun.BacklogAfter, "How long after they completed unsigned TaskRuns are signed after the recent ones, e.g. when catching up after an outage. Disabled when 0.")
+	backlogMaxWait       = flag.Duration("backlog-max-wait", taskrun.BacklogMaxWait, "How long TaskRuns are held back in the backlog at most, when other TaskRuns keep waiting to be signed. Unlimited when 0.")
+	maxBuckets           = flag.Uint("max-buckets", uint(leaderelection.MaxBuckets), "Largest number of buckets config-leader-election can partition TaskRuns into, so they can be sharded across that many replicas.")
+	configFlags          = configOverrides{}
+)
+
+func init() {
+	flag.Var(configFlags, "config", "A chains-config key=value pair that overrides the ConfigMap, e.g. artifacts.taskrun.format=in-toto. Can be repeated, and takes precedence over CHAINS_* environment variables.")
+}
+
+// configOverrides are the chains-config keys set by -config flags.
+type configOverrides map[string]string
+
+func (o configOverrides) String() string {
+	var pairs []string
+	for _, k := range config.OverriddenKeys(o) {
+		pairs = append(pairs, k+"="+o[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (o configOverrides) Set(s string) error {
+	key, value, err := config.ParseOverride(s)
+	if err != nil {
+		return err
+	}
+	o[key] = value
+	return nil
+}
 
 func main() {
 	flag.Parse()
+	overrides, err := config.FromEnv(os.Environ())
+	if err != nil {
+		log.Fatal(err)
+	}
+	overrides = config.WithOverrides(overrides, configFlags)
+	// Each worker formats, signs and stores a single TaskRun at a time.
+	controller.DefaultThreadsPerController = *threadsPerController
+	taskrun.ShutdownGracePeriod = *shutdownGracePeriod
+	taskrun.DebugPort = *debugPort
+	taskrun.ReadinessPort = *readinessPort
+	taskrun.BacklogAfter = *backlogAfter
+	taskrun.BacklogMaxWait = *backlogMaxWait
+	if *maxBuckets == 0 {
+		log.Fatal("-max-buckets must be at least 1")
+	}
+	leaderelection.MaxBuckets = uint32(*maxBuckets)
+	if *profiling && *debugPort == 0 {
+		log.Fatal("-profiling needs a -debug-port to serve the profiles on")
+	}
+	taskrun.Profiling = *profiling
+	chains.PipelinesNamespace = *pipelinesNamespace
+	if v := os.Getenv("CONTROLLER_VERSION"); v != "" {
+		chains.Version = v
+	}
 	ctx := injection.WithNamespaceScope(signals.NewContext(), *namespace)
+	if len(overrides) > 0 {
+		log.Printf("%s keys set by the environment and flags: %s", config.ChainsConfig, strings.Join(config.OverriddenKeys(overrides), ", "))
+		ctx = config.ToContextWithOverrides(ctx, overrides)
+	}
+	if *stripCache || *taskRunSelector != "" {
+		ctx = taskrun.WithCacheOptions(ctx, taskrun.CacheOptions{
+			Strip:         *stripCache,
+			LabelSelector: *taskRunSelector,
+		})
+	}
+	if *backfillWindow > 0 {
+		backfill(ctx, *namespace, *backfillWindow, *useFinalizer)
+		return
+	}
 
-	sharedmain.MainWithContext(ctx, "watcher", taskrun.NewController)
+	ctor := taskrun.NewController
+	if !*useFinalizer {
+		ctor = taskrun.NewControllerWithoutFinalizer
+	}
+	ctors := []injection.ControllerConstructor{ctor}
+	if *remoteClusters != "" {
+		clusters, err := taskrun.LoadRemoteClusters(*remoteClusters)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for name, cfg := range clusters {
+			ctors = append(ctors, taskrun.NewRemoteController(name, cfg, *useFinalizer))
+		}
+	}
+	sharedmain.MainWithContext(ctx, "watcher", ctors...)
+	// MainWithContext returns once the controller has been asked to stop.
+	if !taskrun.Drain() {
+		log.Print("Timed out waiting for TaskRuns being signed to finish")
+	}
 }
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/chains/verify"
+	"github.com/tektoncd/chains/pkg/webhook"
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/signals"
+)
+
+var (
+	port         = flag.Int("port", 8443, "Port to serve admission reviews on.")
+	tlsCertFile  = flag.String("tls-cert-file", "/etc/webhook/certs/tls.crt", "TLS certificate to serve with. It is read again for each connection, so it can be rotated.")
+	tlsKeyFile   = flag.String("tls-key-file", "/etc/webhook/certs/tls.key", "Private key of the TLS certificate.")
+	key          = flag.String("key", "/etc/signing-secrets/cosign.pub", "Public key images must be signed with: a PEM file, or a KMS reference.")
+	fulcio       = flag.Bool("fulcio", false, "Check signatures with the Fulcio certificates attached to them, instead of -key.")
+	attestations = flag.Bool("attestations", false, "Require in-toto attestations, instead of simple signatures.")
+	builderID    = flag.String("builder-id", "", "builder.id the provenance in attestations must have. Optional, implies -attestations.")
+	namespace    = flag.String("chains-namespace", "tekton-chains", "Namespace Chains is installed in, whose chains-config holds the cluster configuration.")
+)
+
+func main() {
+	flag.Parse()
+	zl, err := zap.NewProduction()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger := zl.Sugar()
+	ctx := logging.WithLogger(signals.NewContext(), logger)
+
+	policy := webhook.Policy{
+		Attestations: *attestations || *builderID != "",
+		BuilderID:    *builderID,
+	}
+	if !*fulcio {
+		if policy.Verifier, err = verify.LoadPublicKey(ctx, *key); err != nil {
+			logger.Fatalf("Error loading %s: %v", *key, err)
+		}
+	}
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	kc, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/verify-images", &webhook.Admitter{KubeClient: kc, Policy: policy, Logger: logger})
+	mux.Handle("/validate-config", &webhook.ConfigValidator{Namespace: *namespace, Logger: logger})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", *port),
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				cert, err := tls.LoadX509KeyPair(*tlsCertFile, *tlsKeyFile)
+				return &cert, err
+			},
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Errorf("Error shutting down: %v", err)
+		}
+	}()
+	logger.Infof("Serving admission reviews on %s", srv.Addr)
+	if err := srv.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+		logger.Fatal(err)
+	}
+}
@@ -5,9 +5,14 @@ go 1.16
 require (
 	cloud.google.com/go v0.97.0
 	cloud.google.com/go/storage v1.18.2
+	github.com/aws/aws-sdk-go v1.42.4
 	github.com/cenkalti/backoff/v3 v3.2.2 // indirect
+	github.com/docker/cli v20.10.11+incompatible
+	github.com/docker/docker-credential-helpers v0.6.4
 	github.com/gabriel-vasile/mimetype v1.3.1 // indirect
 	github.com/ghodss/yaml v1.0.0
+	github.com/go-openapi/strfmt v0.21.1
+	github.com/go-openapi/swag v0.19.15
 	github.com/golang/snappy v0.0.4
 	github.com/golangci/golangci-lint v1.42.0
 	github.com/google/addlicense v1.0.0
@@ -15,6 +20,7 @@ require (
 	github.com/google/go-containerregistry v0.7.1-0.20211118220127-abdc633f8305
 	github.com/google/go-containerregistry/pkg/authn/k8schain v0.0.0-20211118220127-abdc633f8305
 	github.com/google/go-licenses v0.0.0-20210816172045-3099c18c36e1
+	github.com/google/trillian v1.4.0
 	github.com/hashicorp/errwrap v1.1.0
 	github.com/hashicorp/go-multierror v1.1.1
 	github.com/hashicorp/go-sockaddr v1.0.2
@@ -35,19 +41,24 @@ require (
 	github.com/sigstore/fulcio v0.1.2-0.20210831152525-42f7422734bb
 	github.com/sigstore/rekor v0.3.1-0.20211117161348-09070aa96aef
 	github.com/sigstore/sigstore v1.0.2-0.20211115214857-534e133ebf9d
+	github.com/spf13/cobra v1.2.1
 	github.com/tektoncd/pipeline v0.27.1-0.20210830150214-8afd1563782d
 	github.com/tektoncd/plumbing v0.0.0-20210902122415-a65b22d5f63b
+	github.com/theupdateframework/go-tuf v0.0.0-20211115152232-a4f2dd6ea314
+	go.opencensus.io v0.23.0
 	go.uber.org/zap v1.19.1
 	gocloud.dev v0.24.0
 	golang.org/x/crypto v0.0.0-20210920023735-84f357641f63
+	golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac
 	google.golang.org/api v0.60.0
 	k8s.io/api v0.22.1
-	k8s.io/apiextensions-apiserver v0.22.1 // indirect
+	k8s.io/apiextensions-apiserver v0.22.1
 	k8s.io/apimachinery v0.22.1
 	k8s.io/client-go v0.22.1
 	k8s.io/code-generator v0.22.1
 	k8s.io/utils v0.0.0-20211116205334-6203023598ed
 	knative.dev/pkg v0.0.0-20211123135150-787aec59e70a
+	sigs.k8s.io/yaml v1.3.0
 )
 
 // Knative deps (release-0.20)
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"go.uber.org/zap"
+)
+
+// Blob is a file that never touches a registry, such as a release tarball or a binary,
+// named by a pair of results.
+type Blob struct {
+	// URI is where the blob was published, or the path of the file in a workspace.
+	URI string
+	// Digest is the hex encoded sha256 digest of the blob.
+	Digest string
+	// TaskRun is the TaskRun that produced the blob.
+	TaskRun *v1beta1.TaskRun
+}
+
+type BlobArtifact struct {
+	Logger *zap.SugaredLogger
+}
+
+func (ba *BlobArtifact) ExtractObjects(tr *v1beta1.TaskRun) []interface{} {
+	objs := []interface{}{}
+	for _, b := range ExtractBlobsFromResults(tr, ba.Logger) {
+		b := b
+		objs = append(objs, &b)
+	}
+	return objs
+}
+
+// ExtractBlobsFromResults finds the blobs named by pairs of *BLOB_URL and *BLOB_DIGEST results.
+// Like images, the result names may have a prefix, e.g. CLI_BLOB_URL, to name several blobs.
+// The controller can't read the TaskRun's workspaces, so the task computes the digests: they
+// are sha256 digests, with or without the sha256: prefix. The results steps and sidecars
+// reported are included.
+func ExtractBlobsFromResults(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []Blob {
+	var objs []Blob
+	seen := map[string]bool{}
+	for _, set := range resultSets(tr) {
+		for _, b := range extractBlobsFromResults(set, logger) {
+			if key := b.URI + "@" + b.Digest; !seen[key] {
+				seen[key] = true
+				b.TaskRun = tr
+				objs = append(objs, b)
+			}
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].URI < objs[j].URI
+	})
+	return objs
+}
+
+func extractBlobsFromResults(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []Blob {
+	const urlSuffix, digestSuffix = "BLOB_URL", "BLOB_DIGEST"
+	blobs := map[string]*Blob{}
+	for _, res := range tr.Status.TaskRunResults {
+		value := strings.TrimSpace(res.Value)
+		switch {
+		case strings.HasSuffix(res.Name, urlSuffix):
+			p := strings.TrimSuffix(res.Name, urlSuffix)
+			if _, ok := blobs[p]; !ok {
+				blobs[p] = &Blob{TaskRun: tr}
+			}
+			blobs[p].URI = value
+		case strings.HasSuffix(res.Name, digestSuffix):
+			p := strings.TrimSuffix(res.Name, digestSuffix)
+			if _, ok := blobs[p]; !ok {
+				blobs[p] = &Blob{TaskRun: tr}
+			}
+			blobs[p].Digest = value
+		}
+	}
+
+	var objs []Blob
+	for p, b := range blobs {
+		// Only add it if we got both the URL and digest.
+		if b.URI == "" || b.Digest == "" {
+			continue
+		}
+		digest := strings.TrimPrefix(b.Digest, "sha256:")
+		if d, err := hex.DecodeString(digest); err != nil || len(d) != 32 {
+			logger.Errorf("error getting digest of blob %s in result %s%s: %s is not a sha256 digest", b.URI, p, digestSuffix, b.Digest)
+			continue
+		}
+		b.Digest = strings.ToLower(digest)
+		objs = append(objs, *b)
+	}
+	return objs
+}
+
+func (ba *BlobArtifact) Type() string {
+	return "blob"
+}
+
+func (ba *BlobArtifact) StorageBackend(cfg config.Config) string {
+	return cfg.Artifacts.Blobs.StorageBackend
+}
+
+func (ba *BlobArtifact) PayloadFormat(cfg config.Config) formats.PayloadType {
+	return formats.PayloadType(cfg.Artifacts.Blobs.Format)
+}
+
+func (ba *BlobArtifact) Signer(cfg config.Config) string {
+	return cfg.Artifacts.Blobs.Signer
+}
+
+func (ba *BlobArtifact) Key(obj interface{}) string {
+	b := obj.(*Blob)
+	return "blob-" + b.Digest[:12]
+}
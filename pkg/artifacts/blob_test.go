@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestExtractBlobsFromResults(t *testing.T) {
+	const sha = "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+	tests := []struct {
+		name    string
+		results []v1beta1.TaskRunResult
+		want    []Blob
+	}{
+		{
+			name: "url",
+			results: []v1beta1.TaskRunResult{
+				{Name: "BLOB_URL", Value: "https://example.com/releases/cli-1.0.tar.gz\n"},
+				{Name: "BLOB_DIGEST", Value: "sha256:" + sha},
+			},
+			want: []Blob{{URI: "https://example.com/releases/cli-1.0.tar.gz", Digest: sha}},
+		},
+		{
+			name: "workspace files with prefixes",
+			results: []v1beta1.TaskRunResult{
+				{Name: "LINUX_BLOB_URL", Value: "/workspace/output/cli-linux-amd64"},
+				{Name: "LINUX_BLOB_DIGEST", Value: sha},
+				{Name: "DARWIN_BLOB_URL", Value: "/workspace/output/cli-darwin-amd64"},
+				{Name: "DARWIN_BLOB_DIGEST", Value: "sha256:" + sha},
+			},
+			want: []Blob{
+				{URI: "/workspace/output/cli-darwin-amd64", Digest: sha},
+				{URI: "/workspace/output/cli-linux-amd64", Digest: sha},
+			},
+		},
+		{
+			name: "missing digest",
+			results: []v1beta1.TaskRunResult{
+				{Name: "BLOB_URL", Value: "cli.tar.gz"},
+			},
+		},
+		{
+			name: "not a sha256 digest",
+			results: []v1beta1.TaskRunResult{
+				{Name: "BLOB_URL", Value: "cli.tar.gz"},
+				{Name: "BLOB_DIGEST", Value: "sha1:da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &v1beta1.TaskRun{
+				Status: v1beta1.TaskRunStatus{
+					TaskRunStatusFields: v1beta1.TaskRunStatusFields{TaskRunResults: tt.results},
+				},
+			}
+			got := ExtractBlobsFromResults(tr, logtesting.TestLogger(t))
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreFields(Blob{}, "TaskRun")); diff != "" {
+				t.Errorf("ExtractBlobsFromResults() -want +got: %s", diff)
+			}
+			for _, b := range got {
+				if b.TaskRun != tr {
+					t.Errorf("blob %s doesn't refer to its TaskRun", b.URI)
+				}
+			}
+		})
+	}
+}
+
+func TestBlobArtifactKey(t *testing.T) {
+	b := &Blob{URI: "cli.tar.gz", Digest: "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"}
+	if got := (&BlobArtifact{}).Key(b); got != "blob-05f95b26ed10" {
+		t.Errorf("Key() = %s, want blob-05f95b26ed10", got)
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/hashicorp/go-multierror"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+const (
+	// ImagesResult is the result that lists the images a TaskRun built.
+	ImagesResult = "IMAGES"
+
+	// ResolvedImagesAnnotation records the digests Chains resolved the images reported without
+	// one to, separated by commas. Each is recorded with the tag it was resolved from, e.g.
+	// gcr.io/foo/bar:v1@sha256:...
+	ResolvedImagesAnnotation = "chains.tekton.dev/resolved-images"
+)
+
+// ParseImages parses the value of the IMAGES result: images separated by commas or newlines.
+// Whitespace, empty entries and duplicates are ignored. Images qualified by digest are returned
+// as digests, and images without one as tags, so their digests can be looked up. The error
+// lists every entry that isn't an image.
+func ParseImages(value string) ([]name.Digest, []name.Tag, error) {
+	var digests []name.Digest
+	var tags []name.Tag
+	var merr *multierror.Error
+	seen := map[string]bool{}
+	entries := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n'
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		if strings.Contains(entry, "@") {
+			d, err := name.NewDigest(entry)
+			if err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("%s entry %q: %w", ImagesResult, entry, err))
+				continue
+			}
+			digests = append(digests, d)
+			continue
+		}
+		t, err := name.NewTag(entry)
+		if err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("%s entry %q: %w", ImagesResult, entry, err))
+			continue
+		}
+		tags = append(tags, t)
+	}
+	return digests, tags, merr.ErrorOrNil()
+}
+
+// ParseImagesResult parses the IMAGES result of the TaskRun, if it has one.
+func ParseImagesResult(tr *v1beta1.TaskRun) ([]name.Digest, []name.Tag, error) {
+	var values []string
+	for _, res := range tr.Status.TaskRunResults {
+		if res.Name == ImagesResult {
+			values = append(values, res.Value)
+		}
+	}
+	return ParseImages(strings.Join(values, "\n"))
+}
+
+// ParseImageURLsWithoutDigest returns the images named by *IMAGE_URL results that have no
+// matching *IMAGE_DIGEST result, so their digests can be looked up. The error lists every
+// such result that isn't an image.
+func ParseImageURLsWithoutDigest(tr *v1beta1.TaskRun) ([]name.Tag, error) {
+	const urlSuffix, digestSuffix = "IMAGE_URL", "IMAGE_DIGEST"
+	urls := map[string]string{}
+	hasDigest := map[string]bool{}
+	for _, res := range tr.Status.TaskRunResults {
+		value := strings.TrimSpace(res.Value)
+		switch {
+		case strings.HasSuffix(res.Name, urlSuffix):
+			urls[strings.TrimSuffix(res.Name, urlSuffix)] = value
+		case strings.HasSuffix(res.Name, digestSuffix) && value != "":
+			hasDigest[strings.TrimSuffix(res.Name, digestSuffix)] = true
+		}
+	}
+	var prefixes []string
+	for p := range urls {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+
+	var tags []name.Tag
+	var merr *multierror.Error
+	for _, p := range prefixes {
+		if hasDigest[p] || urls[p] == "" {
+			continue
+		}
+		t, err := name.NewTag(urls[p])
+		if err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("%s%s %q: %w", p, urlSuffix, urls[p], err))
+			continue
+		}
+		tags = append(tags, t)
+	}
+	return tags, merr.ErrorOrNil()
+}
+
+// resolvedImages returns the digests recorded in ResolvedImagesAnnotation.
+func resolvedImages(tr *v1beta1.TaskRun) []name.Digest {
+	digests, _, _ := ParseImages(tr.Annotations[ResolvedImagesAnnotation])
+	return digests
+}
+
+// dedupe removes the images that are in objs more than once, keeping the first. Images are
+// the same when they have the same repository and digest, whatever tag they were reported with.
+func dedupe(objs []interface{}) []interface{} {
+	seen := map[string]bool{}
+	var deduped []interface{}
+	for _, obj := range objs {
+		if d, ok := obj.(name.Digest); ok {
+			key := d.Repository.Name() + "@" + d.DigestStr()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		deduped = append(deduped, obj)
+	}
+	return deduped
+}
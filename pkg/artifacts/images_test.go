@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestParseImages(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantDigests []string
+		wantTags    []string
+		wantErr     bool
+	}{
+		{
+			name:        "commas",
+			value:       fmt.Sprintf("img1@%s, img2@%s", digest1, digest2),
+			wantDigests: []string{"img1@" + digest1, "img2@" + digest2},
+		},
+		{
+			name:        "newlines, whitespace and trailing commas",
+			value:       fmt.Sprintf("\n  img1@%s,\n\timg2@%s ,\n,", digest1, digest2),
+			wantDigests: []string{"img1@" + digest1, "img2@" + digest2},
+		},
+		{
+			name:        "duplicates",
+			value:       fmt.Sprintf("img1@%s,img1@%s\nimg1@%s", digest1, digest1, digest1),
+			wantDigests: []string{"img1@" + digest1},
+		},
+		{
+			name:        "missing digest",
+			value:       fmt.Sprintf("img1@%s,gcr.io/foo/bar:v1", digest1),
+			wantDigests: []string{"img1@" + digest1},
+			wantTags:    []string{"gcr.io/foo/bar:v1"},
+		},
+		{
+			name:        "invalid entries",
+			value:       fmt.Sprintf("img1@%s,img2@sha256:abc,UPPER CASE", digest1),
+			wantDigests: []string{"img1@" + digest1},
+			wantErr:     true,
+		},
+		{
+			name: "empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			digests, tags, err := ParseImages(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseImages() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var gotDigests, gotTags []string
+			for _, d := range digests {
+				gotDigests = append(gotDigests, d.String())
+			}
+			for _, tag := range tags {
+				gotTags = append(gotTags, tag.String())
+			}
+			if diff := cmp.Diff(tt.wantDigests, gotDigests); diff != "" {
+				t.Errorf("digests -want +got: %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantTags, gotTags); diff != "" {
+				t.Errorf("tags -want +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestExtractOCIImagesFromResultsImages(t *testing.T) {
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{ResolvedImagesAnnotation: "gcr.io/foo/bar:v1@" + digest2 + ",img2:v1@" + digest2},
+		},
+		Status: v1beta1.TaskRunStatus{
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				TaskRunResults: []v1beta1.TaskRunResult{
+					// An invalid pair doesn't stop the other images being found.
+					{Name: "BAD_IMAGE_URL", Value: "img1"},
+					{Name: "BAD_IMAGE_DIGEST", Value: "sha256:abc"},
+					{Name: "IMAGE_URL", Value: "img1"},
+					{Name: "IMAGE_DIGEST", Value: digest1},
+					{Name: "IMAGES", Value: fmt.Sprintf("img1@%s,\nimg2@%s,\ngcr.io/foo/bar:v1\n", digest1, digest2)},
+				},
+			},
+		},
+	}
+	// img2:v1 resolved to an image that is already listed, so it's only included once.
+	want := []string{"img1@" + digest1, "img2@" + digest2, "gcr.io/foo/bar:v1@" + digest2}
+	var got []string
+	for _, obj := range ExtractOCIImagesFromResults(tr, logtesting.TestLogger(t)) {
+		got = append(got, obj.(name.Digest).String())
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ExtractOCIImagesFromResults() -want +got: %s", diff)
+	}
+}
+
+func TestParseImageURLsWithoutDigest(t *testing.T) {
+	tr := &v1beta1.TaskRun{
+		Status: v1beta1.TaskRunStatus{
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				TaskRunResults: []v1beta1.TaskRunResult{
+					{Name: "img1_IMAGE_URL", Value: "img1"},
+					{Name: "img1_IMAGE_DIGEST", Value: digest1},
+					{Name: "img2_IMAGE_URL", Value: "gcr.io/foo/img2:v1\n"},
+					{Name: "img3_IMAGE_URL", Value: "gcr.io/foo/img3"},
+					{Name: "img3_IMAGE_DIGEST", Value: "\n"},
+					{Name: "bad_IMAGE_URL", Value: "NOT AN IMAGE"},
+				},
+			},
+		},
+	}
+	tags, err := ParseImageURLsWithoutDigest(tr)
+	if err == nil {
+		t.Error("expected an error for bad_IMAGE_URL")
+	}
+	var got []string
+	for _, tag := range tags {
+		got = append(got, tag.String())
+	}
+	want := []string{"gcr.io/foo/img2:v1", "gcr.io/foo/img3"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseImageURLsWithoutDigest() -want +got: %s", diff)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"go.uber.org/zap"
+)
+
+// Package is a non-OCI artifact, such as a jar or a wheel, named by a pair of results.
+type Package struct {
+	// Type is the kind of package: maven, npm, pypi or deb.
+	Type string
+	// URL is where the package was published, or its file name.
+	URL string
+	// Digest is the hex encoded sha256 digest of the package.
+	Digest string
+}
+
+// packageHints maps the result names that hint at packages, without their _URL and _DIGEST
+// suffixes, to the type of package.
+var packageHints = map[string]string{
+	"MAVEN_ARTIFACT": "maven",
+	"NPM_PACKAGE":    "npm",
+	"PYTHON_WHEEL":   "pypi",
+	"DEB_PACKAGE":    "deb",
+}
+
+// ExtractPackagesFromResults finds the packages named by pairs of results such as
+// PYTHON_WHEEL_URL and PYTHON_WHEEL_DIGEST. Like images, the result names may have a prefix,
+// e.g. CLIENT_PYTHON_WHEEL_URL, to name several packages. Digests are sha256 digests,
+// with or without the sha256: prefix. The results steps and sidecars reported are included.
+func ExtractPackagesFromResults(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []Package {
+	var objs []Package
+	seen := map[Package]bool{}
+	for _, set := range resultSets(tr) {
+		for _, p := range extractPackagesFromResults(set, logger) {
+			if !seen[p] {
+				seen[p] = true
+				objs = append(objs, p)
+			}
+		}
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].URL < objs[j].URL
+	})
+	return objs
+}
+
+func extractPackagesFromResults(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []Package {
+	pkgs := map[string]*Package{}
+	for _, res := range tr.Status.TaskRunResults {
+		for hint, typ := range packageHints {
+			var prefix string
+			var isURL bool
+			switch {
+			case strings.HasSuffix(res.Name, hint+"_URL"):
+				prefix, isURL = strings.TrimSuffix(res.Name, hint+"_URL"), true
+			case strings.HasSuffix(res.Name, hint+"_DIGEST"):
+				prefix = strings.TrimSuffix(res.Name, hint+"_DIGEST")
+			default:
+				continue
+			}
+			key := prefix + hint
+			p, ok := pkgs[key]
+			if !ok {
+				p = &Package{Type: typ}
+				pkgs[key] = p
+			}
+			value := strings.TrimSpace(res.Value)
+			if isURL {
+				p.URL = value
+			} else {
+				p.Digest = value
+			}
+		}
+	}
+
+	var objs []Package
+	for key, p := range pkgs {
+		// Only add it if we got both the URL and digest.
+		if p.URL == "" || p.Digest == "" {
+			continue
+		}
+		digest := strings.TrimPrefix(p.Digest, "sha256:")
+		if b, err := hex.DecodeString(digest); err != nil || len(b) != 32 {
+			logger.Errorf("error getting digest of %s package %s: %s is not a sha256 digest", key, p.URL, p.Digest)
+			continue
+		}
+		p.Digest = strings.ToLower(digest)
+		objs = append(objs, *p)
+	}
+	return objs
+}
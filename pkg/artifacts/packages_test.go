@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestExtractPackagesFromResults(t *testing.T) {
+	const sha = "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+	tests := []struct {
+		name    string
+		results []v1beta1.TaskRunResult
+		want    []Package
+	}{
+		{
+			name: "each type",
+			results: []v1beta1.TaskRunResult{
+				{Name: "MAVEN_ARTIFACT_URL", Value: "https://repo.example.com/maven2/com/example/lib/1.0/lib-1.0.jar"},
+				{Name: "MAVEN_ARTIFACT_DIGEST", Value: "sha256:" + sha},
+				{Name: "NPM_PACKAGE_URL", Value: "https://registry.npmjs.org/lib/-/lib-1.0.0.tgz"},
+				{Name: "NPM_PACKAGE_DIGEST", Value: sha},
+				{Name: "PYTHON_WHEEL_URL", Value: "lib-1.0-py3-none-any.whl\n"},
+				{Name: "PYTHON_WHEEL_DIGEST", Value: sha + "\n"},
+				{Name: "DEB_PACKAGE_URL", Value: "https://deb.example.com/pool/main/l/lib/lib_1.0_amd64.deb"},
+				{Name: "DEB_PACKAGE_DIGEST", Value: "sha256:" + sha},
+			},
+			want: []Package{
+				{Type: "deb", URL: "https://deb.example.com/pool/main/l/lib/lib_1.0_amd64.deb", Digest: sha},
+				{Type: "npm", URL: "https://registry.npmjs.org/lib/-/lib-1.0.0.tgz", Digest: sha},
+				{Type: "maven", URL: "https://repo.example.com/maven2/com/example/lib/1.0/lib-1.0.jar", Digest: sha},
+				{Type: "pypi", URL: "lib-1.0-py3-none-any.whl", Digest: sha},
+			},
+		},
+		{
+			name: "prefixes",
+			results: []v1beta1.TaskRunResult{
+				{Name: "CLIENT_PYTHON_WHEEL_URL", Value: "client-1.0-py3-none-any.whl"},
+				{Name: "CLIENT_PYTHON_WHEEL_DIGEST", Value: sha},
+				{Name: "SERVER_PYTHON_WHEEL_URL", Value: "server-1.0-py3-none-any.whl"},
+				{Name: "SERVER_PYTHON_WHEEL_DIGEST", Value: sha},
+			},
+			want: []Package{
+				{Type: "pypi", URL: "client-1.0-py3-none-any.whl", Digest: sha},
+				{Type: "pypi", URL: "server-1.0-py3-none-any.whl", Digest: sha},
+			},
+		},
+		{
+			name: "missing digest",
+			results: []v1beta1.TaskRunResult{
+				{Name: "NPM_PACKAGE_URL", Value: "lib-1.0.0.tgz"},
+			},
+		},
+		{
+			name: "not a sha256 digest",
+			results: []v1beta1.TaskRunResult{
+				{Name: "NPM_PACKAGE_URL", Value: "lib-1.0.0.tgz"},
+				{Name: "NPM_PACKAGE_DIGEST", Value: "sha1:da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &v1beta1.TaskRun{
+				Status: v1beta1.TaskRunStatus{
+					TaskRunStatusFields: v1beta1.TaskRunStatusFields{TaskRunResults: tt.results},
+				},
+			}
+			got := ExtractPackagesFromResults(tr, logtesting.TestLogger(t))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ExtractPackagesFromResults() -want +got: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"encoding/json"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// resultSets returns the TaskRun, followed by a copy of it for each step and sidecar that
+// reported results, with those results in place of the results of the TaskRun. Composite
+// tasks report their outputs per step, and a result a step reports can be overwritten by a
+// later step before it becomes a result of the TaskRun. Looking for hints in each set keeps
+// pairs of results, such as IMAGE_URL and IMAGE_DIGEST, from the same step together.
+func resultSets(tr *v1beta1.TaskRun) []*v1beta1.TaskRun {
+	sets := []*v1beta1.TaskRun{tr}
+	var states []corev1.ContainerState
+	for _, s := range tr.Status.Steps {
+		states = append(states, s.ContainerState)
+	}
+	for _, s := range tr.Status.Sidecars {
+		states = append(states, s.ContainerState)
+	}
+	for _, state := range states {
+		results := containerResults(state)
+		if len(results) == 0 {
+			continue
+		}
+		set := *tr
+		set.Status.TaskRunResults = results
+		sets = append(sets, &set)
+	}
+	return sets
+}
+
+// containerResults parses the results a step or sidecar reported in its termination message.
+// Messages that aren't results, which sidecars may write, are ignored.
+func containerResults(state corev1.ContainerState) []v1beta1.TaskRunResult {
+	if state.Terminated == nil || state.Terminated.Message == "" {
+		return nil
+	}
+	var reported []v1beta1.PipelineResourceResult
+	if err := json.Unmarshal([]byte(state.Terminated.Message), &reported); err != nil {
+		return nil
+	}
+	var results []v1beta1.TaskRunResult
+	for _, r := range reported {
+		// Steps also report the results of PipelineResources, which aren't hints.
+		if r.ResourceName != "" || (r.ResultType != "" && r.ResultType != v1beta1.TaskRunResultType) {
+			continue
+		}
+		results = append(results, v1beta1.TaskRunResult{Name: r.Key, Value: r.Value})
+	}
+	return results
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func terminated(message string) corev1.ContainerState {
+	return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}}
+}
+
+func TestContainerResults(t *testing.T) {
+	tests := []struct {
+		name  string
+		state corev1.ContainerState
+		want  []v1beta1.TaskRunResult
+	}{
+		{
+			name:  "results",
+			state: terminated(`[{"key":"IMAGE_URL","value":"img1","type":"TaskRunResult"},{"key":"IMAGE_DIGEST","value":"` + digest1 + `"}]`),
+			want: []v1beta1.TaskRunResult{
+				{Name: "IMAGE_URL", Value: "img1"},
+				{Name: "IMAGE_DIGEST", Value: digest1},
+			},
+		},
+		{
+			name:  "pipeline resource results",
+			state: terminated(`[{"key":"digest","value":"` + digest1 + `","resourceName":"my-image","type":"PipelineResourceResult"},{"key":"StartedAt","value":"2021-01-01T00:00:00Z","type":"InternalTektonResult"}]`),
+		},
+		{
+			name:  "not results",
+			state: terminated("sidecar exited"),
+		},
+		{
+			name:  "running",
+			state: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, containerResults(tt.state)); diff != "" {
+				t.Errorf("containerResults() -want +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestExtractFromStepResults(t *testing.T) {
+	const sha = "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+	tr := &v1beta1.TaskRun{
+		Status: v1beta1.TaskRunStatus{
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				// The second step overwrote the image the first step reported.
+				TaskRunResults: []v1beta1.TaskRunResult{
+					{Name: "IMAGE_URL", Value: "img2"},
+					{Name: "IMAGE_DIGEST", Value: digest2},
+				},
+				Steps: []v1beta1.StepState{
+					{Name: "build-1", ContainerState: terminated(`[{"key":"IMAGE_URL","value":"img1","type":"TaskRunResult"},{"key":"IMAGE_DIGEST","value":"` + digest1 + `","type":"TaskRunResult"}]`)},
+					{Name: "build-2", ContainerState: terminated(`[{"key":"IMAGE_URL","value":"img2","type":"TaskRunResult"},{"key":"IMAGE_DIGEST","value":"` + digest2 + `","type":"TaskRunResult"}]`)},
+				},
+				Sidecars: []v1beta1.SidecarState{
+					{Name: "publisher", ContainerState: terminated(`[{"key":"BLOB_URL","value":"cli.tar.gz"},{"key":"BLOB_DIGEST","value":"` + sha + `"},{"key":"NPM_PACKAGE_URL","value":"lib-1.0.0.tgz"},{"key":"NPM_PACKAGE_DIGEST","value":"` + sha + `"}]`)},
+				},
+			},
+		},
+	}
+	logger := logtesting.TestLogger(t)
+
+	var images []string
+	for _, obj := range ExtractOCIImagesFromResults(tr, logger) {
+		images = append(images, obj.(name.Digest).String())
+	}
+	if diff := cmp.Diff([]string{"img2@" + digest2, "img1@" + digest1}, images); diff != "" {
+		t.Errorf("ExtractOCIImagesFromResults() -want +got: %s", diff)
+	}
+
+	blobs := ExtractBlobsFromResults(tr, logger)
+	if len(blobs) != 1 || blobs[0].URI != "cli.tar.gz" || blobs[0].TaskRun != tr {
+		t.Errorf("ExtractBlobsFromResults() = %v, want cli.tar.gz from the TaskRun", blobs)
+	}
+
+	wantPackages := []Package{{Type: "npm", URL: "lib-1.0.0.tgz", Digest: sha}}
+	if diff := cmp.Diff(wantPackages, ExtractPackagesFromResults(tr, logger)); diff != "" {
+		t.Errorf("ExtractPackagesFromResults() -want +got: %s", diff)
+	}
+}
+
+func TestExtractFromLargeResults(t *testing.T) {
+	// Results read from the logs of a sidecar are recorded in full on the TaskRun, and the
+	// steps don't report them in their termination messages.
+	var images []string
+	for i := 0; i < 64; i++ {
+		images = append(images, fmt.Sprintf("gcr.io/foo/image-%d@%s", i, digest1))
+	}
+	value := strings.Join(images, ",")
+	if len(value) <= 4096 {
+		t.Fatalf("expected the result to exceed the termination message limit, got %d bytes", len(value))
+	}
+	tr := &v1beta1.TaskRun{
+		Status: v1beta1.TaskRunStatus{
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				TaskRunResults: []v1beta1.TaskRunResult{{Name: "IMAGES", Value: value}},
+				Steps:          []v1beta1.StepState{{Name: "build", ContainerState: terminated("")}},
+				Sidecars:       []v1beta1.SidecarState{{Name: "tekton-log-results", ContainerState: terminated("")}},
+			},
+		},
+	}
+
+	var got []string
+	for _, obj := range ExtractOCIImagesFromResults(tr, logtesting.TestLogger(t)) {
+		got = append(got, obj.(name.Digest).String())
+	}
+	if diff := cmp.Diff(images, got); diff != "" {
+		t.Errorf("ExtractOCIImagesFromResults() -want +got: %s", diff)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"regexp"
+	"sort"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// SubjectRewriter renames the subjects of attestations, e.g. to replace an internal proxy
+// with the registry consumers pull from, so attestations match the names consumers use.
+type SubjectRewriter struct {
+	patterns     []*regexp.Regexp
+	replacements []string
+}
+
+// NewSubjectRewriter compiles the rewrites in the configuration.
+func NewSubjectRewriter(rewrites []config.SubjectRewrite) (*SubjectRewriter, error) {
+	r := &SubjectRewriter{}
+	for _, rw := range rewrites {
+		p, err := regexp.Compile(rw.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compiling subject rewrite %q", rw.Pattern)
+		}
+		r.patterns = append(r.patterns, p)
+		r.replacements = append(r.replacements, rw.Replacement)
+	}
+	return r, nil
+}
+
+// Rewrite applies the first rewrite whose pattern matches name. Names that no pattern
+// matches are returned as they are.
+func (r *SubjectRewriter) Rewrite(name string) string {
+	if r == nil {
+		return name
+	}
+	for i, p := range r.patterns {
+		if p.MatchString(name) {
+			return p.ReplaceAllString(name, r.replacements[i])
+		}
+	}
+	return name
+}
+
+// RewriteSubjects rewrites the names of subjects, and sorts them by their new names.
+func (r *SubjectRewriter) RewriteSubjects(subjects []intoto.Subject) []intoto.Subject {
+	for i := range subjects {
+		subjects[i].Name = r.Rewrite(subjects[i].Name)
+	}
+	sort.SliceStable(subjects, func(i, j int) bool {
+		return subjects[i].Name < subjects[j].Name
+	})
+	return subjects
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+func TestSubjectRewriter(t *testing.T) {
+	rewrites := []config.SubjectRewrite{
+		{Pattern: `^mirror\.internal:5000/(.*)$`, Replacement: "gcr.io/$1"},
+		{Pattern: `^gcr\.io/staging/`, Replacement: "gcr.io/release/"},
+		{Pattern: `gcr\.io`, Replacement: "unused.io"},
+	}
+	r, err := NewSubjectRewriter(rewrites)
+	if err != nil {
+		t.Fatalf("NewSubjectRewriter() = %v", err)
+	}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{{
+		name: "groups",
+		in:   "mirror.internal:5000/foo/bar",
+		want: "gcr.io/foo/bar",
+	}, {
+		name: "first match wins",
+		in:   "gcr.io/staging/foo",
+		want: "gcr.io/release/foo",
+	}, {
+		name: "no match",
+		in:   "docker.io/library/busybox",
+		want: "docker.io/library/busybox",
+	}, {
+		name: "not an image",
+		in:   "pkg:npm/foo@1.0.0",
+		want: "pkg:npm/foo@1.0.0",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Rewrite(tt.in); got != tt.want {
+				t.Errorf("Rewrite(%q) = %s, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	var none *SubjectRewriter
+	if got := none.Rewrite("gcr.io/foo"); got != "gcr.io/foo" {
+		t.Errorf("Rewrite() with no rewriter = %s, want gcr.io/foo", got)
+	}
+	if _, err := NewSubjectRewriter([]config.SubjectRewrite{{Pattern: "("}}); err == nil {
+		t.Error("expected error compiling an invalid pattern")
+	}
+}
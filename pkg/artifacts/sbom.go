@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"go.uber.org/zap"
+)
+
+// SBOM is an SBOM a task produced for an image it built.
+type SBOM struct {
+	// URL is where the SBOM can be downloaded from.
+	URL string
+	// Digest is the digest of the SBOM, e.g. sha256:...
+	Digest string
+	// Image is the image the SBOM describes.
+	Image name.Digest
+}
+
+// ExtractSBOMsFromResults finds the SBOMs named by pairs of *SBOM_URL and *SBOM_DIGEST results.
+// An SBOM describes the image named by the *IMAGE_URL and *IMAGE_DIGEST results with the
+// same prefix, e.g. FOO_SBOM_URL describes FOO_IMAGE_URL. An SBOM without a matching image
+// describes the only image of the TaskRun, if it has exactly one.
+func ExtractSBOMsFromResults(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []SBOM {
+	const urlSuffix, digestSuffix = "SBOM_URL", "SBOM_DIGEST"
+	sboms := map[string]*SBOM{}
+	for _, res := range tr.Status.TaskRunResults {
+		value := strings.TrimSpace(res.Value)
+		switch {
+		case strings.HasSuffix(res.Name, urlSuffix):
+			p := strings.TrimSuffix(res.Name, urlSuffix)
+			if _, ok := sboms[p]; !ok {
+				sboms[p] = &SBOM{}
+			}
+			sboms[p].URL = value
+		case strings.HasSuffix(res.Name, digestSuffix):
+			p := strings.TrimSuffix(res.Name, digestSuffix)
+			if _, ok := sboms[p]; !ok {
+				sboms[p] = &SBOM{}
+			}
+			sboms[p].Digest = value
+		}
+	}
+	if len(sboms) == 0 {
+		return nil
+	}
+
+	images := imagesByPrefix(tr)
+	var objs []SBOM
+	for p, s := range sboms {
+		// Only add it if we got both the URL and digest.
+		if s.URL == "" || s.Digest == "" {
+			continue
+		}
+		img, ok := images[p]
+		if !ok && len(images) == 1 {
+			for _, only := range images {
+				img, ok = only, true
+			}
+		}
+		if !ok {
+			logger.Errorf("no image found for SBOM %s in results %sSBOM_URL and %sSBOM_DIGEST", s.URL, p, p)
+			continue
+		}
+		s.Image = img
+		objs = append(objs, *s)
+	}
+	sort.Slice(objs, func(i, j int) bool {
+		return objs[i].URL < objs[j].URL
+	})
+	return objs
+}
+
+// imagesByPrefix returns the images named by *IMAGE_URL and *IMAGE_DIGEST results, by prefix.
+func imagesByPrefix(tr *v1beta1.TaskRun) map[string]name.Digest {
+	const urlSuffix, digestSuffix = "IMAGE_URL", "IMAGE_DIGEST"
+	imgs := map[string]*image{}
+	for _, res := range tr.Status.TaskRunResults {
+		switch {
+		case strings.HasSuffix(res.Name, urlSuffix):
+			p := strings.TrimSuffix(res.Name, urlSuffix)
+			if _, ok := imgs[p]; !ok {
+				imgs[p] = &image{}
+			}
+			imgs[p].url = strings.Trim(res.Value, "\n")
+		case strings.HasSuffix(res.Name, digestSuffix):
+			p := strings.TrimSuffix(res.Name, digestSuffix)
+			if _, ok := imgs[p]; !ok {
+				imgs[p] = &image{}
+			}
+			imgs[p].digest = strings.Trim(res.Value, "\n")
+		}
+	}
+	digests := map[string]name.Digest{}
+	for p, img := range imgs {
+		dgst, err := name.NewDigest(fmt.Sprintf("%s@%s", img.url, img.digest))
+		if err != nil {
+			continue
+		}
+		digests[p] = dgst
+	}
+	return digests
+}
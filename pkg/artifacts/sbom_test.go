@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestExtractSBOMsFromResults(t *testing.T) {
+	const digest = "sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+	foo := name.MustParseReference("gcr.io/foo/bar@" + digest).(name.Digest)
+	baz := name.MustParseReference("gcr.io/foo/baz@" + digest).(name.Digest)
+	tests := []struct {
+		name    string
+		results []v1beta1.TaskRunResult
+		want    []SBOM
+	}{
+		{
+			name: "only image",
+			results: []v1beta1.TaskRunResult{
+				{Name: "IMAGE_URL", Value: "gcr.io/foo/bar"},
+				{Name: "IMAGE_DIGEST", Value: digest},
+				{Name: "SBOM_URL", Value: "https://example.com/sbom.spdx.json\n"},
+				{Name: "SBOM_DIGEST", Value: digest},
+			},
+			want: []SBOM{{URL: "https://example.com/sbom.spdx.json", Digest: digest, Image: foo}},
+		},
+		{
+			name: "matched by prefix",
+			results: []v1beta1.TaskRunResult{
+				{Name: "BAR_IMAGE_URL", Value: "gcr.io/foo/bar"},
+				{Name: "BAR_IMAGE_DIGEST", Value: digest},
+				{Name: "BAZ_IMAGE_URL", Value: "gcr.io/foo/baz"},
+				{Name: "BAZ_IMAGE_DIGEST", Value: digest},
+				{Name: "BAR_SBOM_URL", Value: "https://example.com/bar.json"},
+				{Name: "BAR_SBOM_DIGEST", Value: digest},
+				{Name: "BAZ_SBOM_URL", Value: "https://example.com/baz.json"},
+				{Name: "BAZ_SBOM_DIGEST", Value: digest},
+			},
+			want: []SBOM{
+				{URL: "https://example.com/bar.json", Digest: digest, Image: foo},
+				{URL: "https://example.com/baz.json", Digest: digest, Image: baz},
+			},
+		},
+		{
+			name: "no matching image",
+			results: []v1beta1.TaskRunResult{
+				{Name: "BAR_IMAGE_URL", Value: "gcr.io/foo/bar"},
+				{Name: "BAR_IMAGE_DIGEST", Value: digest},
+				{Name: "BAZ_IMAGE_URL", Value: "gcr.io/foo/baz"},
+				{Name: "BAZ_IMAGE_DIGEST", Value: digest},
+				{Name: "SBOM_URL", Value: "https://example.com/sbom.json"},
+				{Name: "SBOM_DIGEST", Value: digest},
+			},
+		},
+		{
+			name: "missing digest",
+			results: []v1beta1.TaskRunResult{
+				{Name: "IMAGE_URL", Value: "gcr.io/foo/bar"},
+				{Name: "IMAGE_DIGEST", Value: digest},
+				{Name: "SBOM_URL", Value: "https://example.com/sbom.json"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &v1beta1.TaskRun{
+				Status: v1beta1.TaskRunStatus{
+					TaskRunStatusFields: v1beta1.TaskRunStatusFields{TaskRunResults: tt.results},
+				},
+			}
+			got := ExtractSBOMsFromResults(tr, logtesting.TestLogger(t))
+			if diff := cmp.Diff(tt.want, got, cmp.Comparer(func(a, b name.Digest) bool { return a.String() == b.String() })); diff != "" {
+				t.Errorf("ExtractSBOMsFromResults() -want +got: %s", diff)
+			}
+		})
+	}
+}
@@ -107,10 +107,20 @@ func (oa *OCIArtifact) ExtractObjects(tr *v1beta1.TaskRun) []interface{} {
 	resultImages := ExtractOCIImagesFromResults(tr, oa.Logger)
 	objs = append(objs, resultImages...)
 
-	return objs
+	return dedupe(objs)
 }
 
+// ExtractOCIImagesFromResults finds the images named by the results of the TaskRun, and by the
+// results its steps and sidecars reported.
 func ExtractOCIImagesFromResults(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []interface{} {
+	var objs []interface{}
+	for _, set := range resultSets(tr) {
+		objs = append(objs, extractOCIImagesFromResults(set, logger)...)
+	}
+	return dedupe(objs)
+}
+
+func extractOCIImagesFromResults(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []interface{} {
 	taskResultImages := map[string]*image{}
 	var objs []interface{}
 	urlSuffix := "IMAGE_URL"
@@ -139,30 +149,26 @@ func ExtractOCIImagesFromResults(tr *v1beta1.TaskRun, logger *zap.SugaredLogger)
 			dgst, err := name.NewDigest(fmt.Sprintf("%s@%s", img.url, img.digest))
 			if err != nil {
 				logger.Errorf("error getting digest: %v", err)
-				return nil
+				continue
 			}
 			objs = append(objs, dgst)
 		}
 	}
 
-	// look for a comma separated list of images
-	for _, key := range tr.Status.TaskRunResults {
-		if key.Name != "IMAGES" {
-			continue
-		}
-		imgs := strings.Split(key.Value, ",")
-		for _, img := range imgs {
-			trimmed := strings.TrimSpace(img)
-			if trimmed == "" {
-				continue
-			}
-			dgst, err := name.NewDigest(trimmed)
-			if err != nil {
-				logger.Errorf("error getting digest for img %s: %v", trimmed, err)
-				continue
-			}
-			objs = append(objs, dgst)
-		}
+	// look for a list of images, separated by commas or newlines
+	digests, tags, err := ParseImagesResult(tr)
+	if err != nil {
+		logger.Errorf("error parsing %s result: %v", ImagesResult, err)
+	}
+	for _, d := range digests {
+		objs = append(objs, d)
+	}
+	// Images reported without a digest are only included once Chains has resolved them.
+	for _, d := range resolvedImages(tr) {
+		objs = append(objs, d)
+	}
+	if len(tags) > 0 {
+		logger.Infof("%d images in the %s result have no digest", len(tags), ImagesResult)
 	}
 
 	return objs
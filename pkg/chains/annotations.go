@@ -17,6 +17,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/tektoncd/chains/pkg/patch"
@@ -31,7 +32,60 @@ const (
 	ChainsAnnotation             = "chains.tekton.dev/signed"
 	RetryAnnotation              = "chains.tekton.dev/retries"
 	ChainsTransparencyAnnotation = "chains.tekton.dev/transparency"
-	MaxRetries                   = 3
+	// SkipAnnotation can be set to "true" on a TaskRun to prevent it from being signed.
+	SkipAnnotation = "chains.tekton.dev/skip"
+	// ResignAnnotation can be set to "true" on a reconciled TaskRun to have it signed again,
+	// for example after a key rotation.
+	ResignAnnotation = "chains.tekton.dev/resign"
+	// SigningVersionAnnotation records how many times a TaskRun has been re-signed.
+	SigningVersionAnnotation = "chains.tekton.dev/signing-version"
+	MaxRetries               = 3
+
+	// StateAnnotation records where a TaskRun is in the signing process.
+	StateAnnotation = "chains.tekton.dev/state"
+	// StateTimeAnnotation records when StateAnnotation last changed, in RFC3339 format.
+	StateTimeAnnotation = "chains.tekton.dev/state-updated"
+	// LastErrorAnnotation records the most recent error encountered while signing a TaskRun.
+	LastErrorAnnotation = "chains.tekton.dev/last-error"
+	// FailureReasonAnnotation records why signing a TaskRun failed, e.g. StorageFailed.
+	FailureReasonAnnotation = "chains.tekton.dev/failure-reason"
+	// RedactedAnnotation records where the secrets redacted from the payloads of a TaskRun were found.
+	RedactedAnnotation = "chains.tekton.dev/redacted"
+	// OCITargetsAnnotation records the repositories the signatures and attestations of the images
+	// of a TaskRun were pushed to, and whether each push succeeded.
+	OCITargetsAnnotation = "chains.tekton.dev/oci-targets"
+	// maxErrorLength bounds the size of LastErrorAnnotation.
+	maxErrorLength = 1024
+
+	// DryRunKeySuffix is appended to the storage keys of unsigned payloads stored in dry-run mode.
+	DryRunKeySuffix = "-dryrun"
+	// VSAKeySuffix is appended to the storage key of provenance to store the VSA signed for it.
+	VSAKeySuffix = "-vsa"
+	// SBOMKeySuffix is appended to the storage key of an image to store the SBOM attestation signed for it.
+	SBOMKeySuffix = "-sbom"
+	// SourceKeySuffix is appended to the storage key of a TaskRun to store the source attestation signed for it.
+	SourceKeySuffix = "-source"
+	// BundleKeySuffix is appended to the storage key of a payload to store its Sigstore bundle.
+	BundleKeySuffix = "-bundle"
+	// CountersignatureKeySuffix is appended to the storage key of a payload to store the
+	// countersignature of its envelope.
+	CountersignatureKeySuffix = "-countersignature"
+)
+
+// The states a TaskRun moves through. A TaskRun without a state has not been seen yet.
+const (
+	// StatePending means the TaskRun will be signed once it completes, or retried after an error.
+	StatePending = "pending"
+	// StateSigning means chains is generating, signing and storing payloads for the TaskRun.
+	StateSigning = "signing"
+	// StateSigned means the TaskRun has been signed.
+	StateSigned = "signed"
+	// StateFailed means signing failed and will not be retried.
+	StateFailed = "failed"
+	// StateSkipped means the TaskRun asked not to be signed.
+	StateSkipped = "skipped"
+	// StateDryRun means unsigned payloads were stored for the TaskRun in dry-run mode.
+	StateDryRun = "dryrun"
 )
 
 // Reconciled determines whether a TaskRun has already passed through the reconcile loops, up to 3x
@@ -40,7 +94,83 @@ func Reconciled(tr *v1beta1.TaskRun) bool {
 	if !ok {
 		return false
 	}
-	return val == "true" || val == "failed"
+	return val == "true" || val == "failed" || val == "skipped" || val == "dryrun"
+}
+
+// SkipRequested determines whether a TaskRun has asked not to be signed.
+func SkipRequested(tr *v1beta1.TaskRun) bool {
+	return tr.ObjectMeta.Annotations[SkipAnnotation] == "true"
+}
+
+// ResignRequested determines whether a TaskRun has asked to be signed again.
+func ResignRequested(tr *v1beta1.TaskRun) bool {
+	return tr.ObjectMeta.Annotations[ResignAnnotation] == "true"
+}
+
+// SigningVersion returns the number of times a TaskRun has been re-signed.
+func SigningVersion(tr *v1beta1.TaskRun) int {
+	val, err := strconv.Atoi(tr.Annotations[SigningVersionAnnotation])
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
+
+// VersionedKey returns the storage key to use for a TaskRun. Re-signed TaskRuns get
+// a versioned key so the artifacts stored by earlier signings are preserved.
+func VersionedKey(tr *v1beta1.TaskRun, key string) string {
+	if v := SigningVersion(tr); v > 0 {
+		return fmt.Sprintf("%s-v%d", key, v)
+	}
+	return key
+}
+
+// RequestResign asks for a reconciled TaskRun to be signed again.
+func RequestResign(tr *v1beta1.TaskRun, ps versioned.Interface) error {
+	patchBytes, err := patch.GetAnnotationsPatch(map[string]string{ResignAnnotation: "true"})
+	if err != nil {
+		return err
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Patch(
+		context.TODO(), tr.Name, types.MergePatchType, patchBytes, v1.PatchOptions{}); err != nil {
+		return errors.Wrap(err, "requesting resign")
+	}
+	return nil
+}
+
+// PrepareResign clears the signing state of a TaskRun and bumps its signing version,
+// so the next reconcile signs it again under a new versioned key.
+func PrepareResign(tr *v1beta1.TaskRun, ps versioned.Interface) error {
+	patchBytes, err := patch.GetAnnotationsRemovalPatch(
+		map[string]string{SigningVersionAnnotation: strconv.Itoa(SigningVersion(tr) + 1)},
+		[]string{ChainsAnnotation, RetryAnnotation, ChainsTransparencyAnnotation, TransparencyEntriesAnnotation, TransparencyPendingAnnotation, ResignAnnotation, StateAnnotation, LastErrorAnnotation, FailureReasonAnnotation, RedactedAnnotation, OCITargetsAnnotation},
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Patch(
+		context.TODO(), tr.Name, types.MergePatchType, patchBytes, v1.PatchOptions{}); err != nil {
+		return errors.Wrap(err, "preparing resign")
+	}
+	return nil
+}
+
+// State returns the signing state of a TaskRun, or "" if it has not been seen yet.
+func State(tr *v1beta1.TaskRun) string {
+	return tr.Annotations[StateAnnotation]
+}
+
+// MarkPending marks a TaskRun as waiting to be signed.
+func MarkPending(tr *v1beta1.TaskRun, ps versioned.Interface) error {
+	if State(tr) == StatePending {
+		return nil
+	}
+	return setState(tr, ps, StatePending, nil)
+}
+
+// MarkSigning marks a TaskRun as being signed.
+func MarkSigning(tr *v1beta1.TaskRun, ps versioned.Interface) error {
+	return setState(tr, ps, StateSigning, nil)
 }
 
 // MarkSigned marks a TaskRun as signed.
@@ -48,11 +178,70 @@ func MarkSigned(tr *v1beta1.TaskRun, ps versioned.Interface, annotations map[str
 	if _, ok := tr.Annotations[ChainsAnnotation]; ok {
 		return nil
 	}
-	return AddAnnotation(tr, ps, ChainsAnnotation, "true", annotations)
+	annotations = copyAnnotations(annotations)
+	annotations[ChainsAnnotation] = "true"
+	return setState(tr, ps, StateSigned, annotations, LastErrorAnnotation, FailureReasonAnnotation)
 }
 
 func MarkFailed(tr *v1beta1.TaskRun, ps versioned.Interface, annotations map[string]string) error {
-	return AddAnnotation(tr, ps, ChainsAnnotation, "failed", annotations)
+	annotations = copyAnnotations(annotations)
+	annotations[ChainsAnnotation] = "failed"
+	return setState(tr, ps, StateFailed, annotations)
+}
+
+// MarkSkipped marks a TaskRun as deliberately not signed.
+func MarkSkipped(tr *v1beta1.TaskRun, ps versioned.Interface, annotations map[string]string) error {
+	annotations = copyAnnotations(annotations)
+	annotations[ChainsAnnotation] = "skipped"
+	return setState(tr, ps, StateSkipped, annotations)
+}
+
+// MarkDryRun marks a TaskRun as processed in dry-run mode, without being signed.
+func MarkDryRun(tr *v1beta1.TaskRun, ps versioned.Interface, annotations map[string]string) error {
+	annotations = copyAnnotations(annotations)
+	annotations[ChainsAnnotation] = "dryrun"
+	return setState(tr, ps, StateDryRun, annotations, LastErrorAnnotation, FailureReasonAnnotation)
+}
+
+// WithLastError returns a copy of annotations that records err as the last signing error.
+func WithLastError(annotations map[string]string, err error) map[string]string {
+	annotations = copyAnnotations(annotations)
+	msg := err.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	annotations[LastErrorAnnotation] = msg
+	return annotations
+}
+
+// setState patches the state of a TaskRun along with any extra annotations, removing
+// the annotations listed in removed.
+func setState(tr *v1beta1.TaskRun, ps versioned.Interface, state string, annotations map[string]string, removed ...string) error {
+	annotations = withState(annotations, state)
+	patchBytes, err := patch.GetAnnotationsRemovalPatch(annotations, removed)
+	if err != nil {
+		return err
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Patch(
+		context.TODO(), tr.Name, types.MergePatchType, patchBytes, v1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "marking taskrun %s", state)
+	}
+	return nil
+}
+
+func withState(annotations map[string]string, state string) map[string]string {
+	annotations = copyAnnotations(annotations)
+	annotations[StateAnnotation] = state
+	annotations[StateTimeAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	return annotations
+}
+
+func copyAnnotations(annotations map[string]string) map[string]string {
+	c := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		c[k] = v
+	}
+	return c
 }
 
 func RetryAvailable(tr *v1beta1.TaskRun) bool {
@@ -68,6 +257,7 @@ func RetryAvailable(tr *v1beta1.TaskRun) bool {
 }
 
 func AddRetry(tr *v1beta1.TaskRun, ps versioned.Interface, annotations map[string]string) error {
+	annotations = withState(annotations, StatePending)
 	retries := tr.Annotations[RetryAnnotation]
 	if retries == "" {
 		return AddAnnotation(tr, ps, RetryAnnotation, "0", annotations)
@@ -38,6 +38,16 @@ func TestReconciled(t *testing.T) {
 			want:       true,
 			annotation: "failed",
 		},
+		{
+			name:       "skipped",
+			want:       true,
+			annotation: "skipped",
+		},
+		{
+			name:       "dry run",
+			want:       true,
+			annotation: "dryrun",
+		},
 		{
 			name:       "signed with other string",
 			want:       false,
@@ -146,3 +156,105 @@ func TestAddRetry(t *testing.T) {
 		t.Fatalf("annotation isn't correct: %v %v", ok, val)
 	}
 }
+
+func TestVersionedKey(t *testing.T) {
+	tests := []struct {
+		description string
+		annotations map[string]string
+		want        string
+	}{
+		{
+			description: "never re-signed",
+			want:        "taskrun-1234",
+		}, {
+			description: "re-signed twice",
+			annotations: map[string]string{SigningVersionAnnotation: "2"},
+			want:        "taskrun-1234-v2",
+		}, {
+			description: "invalid version",
+			annotations: map[string]string{SigningVersionAnnotation: "abc"},
+			want:        "taskrun-1234",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: test.annotations,
+				},
+			}
+			if got := VersionedKey(tr, "taskrun-1234"); got != test.want {
+				t.Errorf("VersionedKey() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRequestResign(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	c := fakepipelineclient.Get(ctx)
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "mytaskrun",
+			Annotations: map[string]string{ChainsAnnotation: "true"},
+		},
+	}
+	if _, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RequestResign(tr, c); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ResignRequested(got) {
+		t.Error("expected resign to be requested")
+	}
+	if got.Annotations[ChainsAnnotation] != "true" {
+		t.Error("expected the signing state to be left for the controller to reset")
+	}
+}
+
+func TestPrepareResign(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	c := fakepipelineclient.Get(ctx)
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "mytaskrun",
+			Annotations: map[string]string{
+				ChainsAnnotation:                         "true",
+				RetryAnnotation:                          "1",
+				ResignAnnotation:                         "true",
+				SigningVersionAnnotation:                 "1",
+				"chains.tekton.dev/payload-taskrun-1234": "old",
+			},
+		},
+	}
+	if _, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PrepareResign(tr, c); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []string{ChainsAnnotation, RetryAnnotation, ResignAnnotation} {
+		if _, ok := got.Annotations[a]; ok {
+			t.Errorf("expected annotation %s to be removed", a)
+		}
+	}
+	if v := got.Annotations[SigningVersionAnnotation]; v != "2" {
+		t.Errorf("signing version = %q, want 2", v)
+	}
+	if got.Annotations["chains.tekton.dev/payload-taskrun-1234"] != "old" {
+		t.Error("expected previously stored payload to be preserved")
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bundle creates Sigstore bundles: a single JSON document with a signed payload, the
+// certificate chain it was signed with and its transparency log entry, which Sigstore clients
+// verify without looking anything else up.
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// The media types of bundles. Bundles with an inclusion proof and a checkpoint are v0.2, the
+// others only have the log's promise to include the entry.
+const (
+	MediaTypeV01 = "application/vnd.dev.sigstore.bundle+json;version=0.1"
+	MediaTypeV02 = "application/vnd.dev.sigstore.bundle+json;version=0.2"
+)
+
+// PayloadFormat is the format bundles are stored with.
+const PayloadFormat = "sigstore-bundle"
+
+// Bundle is a Sigstore bundle, in the JSON encoding of its protobuf message. It has either a
+// MessageSignature or a DSSEEnvelope.
+type Bundle struct {
+	MediaType            string               `json:"mediaType"`
+	VerificationMaterial VerificationMaterial `json:"verificationMaterial"`
+	MessageSignature     *MessageSignature    `json:"messageSignature,omitempty"`
+	DSSEEnvelope         *Envelope            `json:"dsseEnvelope,omitempty"`
+}
+
+// VerificationMaterial is what the signature is verified with. It has either a certificate
+// chain, or a public key that's found out of band.
+type VerificationMaterial struct {
+	PublicKey            *PublicKey             `json:"publicKey,omitempty"`
+	X509CertificateChain *CertificateChain      `json:"x509CertificateChain,omitempty"`
+	TlogEntries          []TransparencyLogEntry `json:"tlogEntries"`
+}
+
+// PublicKey identifies the public key that verifies the signature.
+type PublicKey struct {
+	Hint string `json:"hint,omitempty"`
+}
+
+// CertificateChain is the signing certificate, followed by its issuers.
+type CertificateChain struct {
+	Certificates []Certificate `json:"certificates"`
+}
+
+// Certificate is a DER encoded X.509 certificate.
+type Certificate struct {
+	RawBytes []byte `json:"rawBytes"`
+}
+
+// TransparencyLogEntry is the entry of the signature in a transparency log. Integers are
+// strings, as in the JSON encoding of protobuf int64s.
+type TransparencyLogEntry struct {
+	LogIndex          string            `json:"logIndex"`
+	LogID             LogID             `json:"logId"`
+	KindVersion       KindVersion       `json:"kindVersion"`
+	IntegratedTime    string            `json:"integratedTime"`
+	InclusionPromise  *InclusionPromise `json:"inclusionPromise,omitempty"`
+	InclusionProof    *InclusionProof   `json:"inclusionProof,omitempty"`
+	CanonicalizedBody []byte            `json:"canonicalizedBody"`
+}
+
+// LogID identifies a log by the hash of its public key.
+type LogID struct {
+	KeyID []byte `json:"keyId"`
+}
+
+// KindVersion is the type of a log entry.
+type KindVersion struct {
+	Kind    string `json:"kind"`
+	Version string `json:"version"`
+}
+
+// InclusionPromise is the log's signature over the entry, promising to include it.
+type InclusionPromise struct {
+	SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+}
+
+// InclusionProof is a Merkle inclusion proof of an entry, with the checkpoint it was made for.
+type InclusionProof struct {
+	LogIndex   string     `json:"logIndex"`
+	RootHash   []byte     `json:"rootHash"`
+	TreeSize   string     `json:"treeSize"`
+	Hashes     [][]byte   `json:"hashes"`
+	Checkpoint Checkpoint `json:"checkpoint"`
+}
+
+// Checkpoint is a signed tree head, in the signed note format.
+type Checkpoint struct {
+	Envelope string `json:"envelope"`
+}
+
+// MessageSignature is a signature over a payload that isn't in the bundle.
+type MessageSignature struct {
+	MessageDigest MessageDigest `json:"messageDigest"`
+	Signature     []byte        `json:"signature"`
+}
+
+// MessageDigest is the digest of the signed payload.
+type MessageDigest struct {
+	Algorithm string `json:"algorithm"`
+	Digest    []byte `json:"digest"`
+}
+
+// Envelope is a DSSE envelope.
+type Envelope struct {
+	Payload     []byte      `json:"payload"`
+	PayloadType string      `json:"payloadType"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a signature of a DSSE envelope.
+type Signature struct {
+	Sig   []byte `json:"sig"`
+	KeyID string `json:"keyid"`
+}
+
+// New returns the bundle of a signed payload. When envelope is set, signature is the DSSE
+// envelope of the payload, as the wrapped signers return it, otherwise it's a signature over
+// rawPayload. cert and chain are the PEM certificates of the signer, if it has any, and tlog is
+// the entry of the signature in the transparency log, if it was uploaded to one.
+func New(rawPayload, signature []byte, envelope bool, cert, chain string, tlog *config.TlogBundle) (*Bundle, error) {
+	b := &Bundle{MediaType: MediaTypeV01, VerificationMaterial: VerificationMaterial{TlogEntries: []TransparencyLogEntry{}}}
+	if envelope {
+		b.DSSEEnvelope = &Envelope{}
+		if err := json.Unmarshal(signature, b.DSSEEnvelope); err != nil {
+			return nil, errors.Wrap(err, "decoding the DSSE envelope")
+		}
+	} else {
+		digest := sha256.Sum256(rawPayload)
+		b.MessageSignature = &MessageSignature{
+			MessageDigest: MessageDigest{Algorithm: "SHA2_256", Digest: digest[:]},
+			Signature:     signature,
+		}
+	}
+
+	certs := certificates(cert + "\n" + chain)
+	if len(certs) > 0 {
+		b.VerificationMaterial.X509CertificateChain = &CertificateChain{Certificates: certs}
+	} else {
+		b.VerificationMaterial.PublicKey = &PublicKey{}
+	}
+
+	if tlog != nil {
+		entry, err := logEntry(tlog)
+		if err != nil {
+			return nil, err
+		}
+		if entry.InclusionProof != nil {
+			b.MediaType = MediaTypeV02
+		}
+		b.VerificationMaterial.TlogEntries = append(b.VerificationMaterial.TlogEntries, *entry)
+	}
+	return b, nil
+}
+
+// certificates returns the DER certificates in the PEM data, in order.
+func certificates(data string) []Certificate {
+	var certs []Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return certs
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, Certificate{RawBytes: block.Bytes})
+		}
+	}
+}
+
+// logEntry converts the entry a transparency log returned.
+func logEntry(tlog *config.TlogBundle) (*TransparencyLogEntry, error) {
+	body, err := base64.StdEncoding.DecodeString(tlog.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding the transparency log entry")
+	}
+	var kind struct {
+		Kind       string `json:"kind"`
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal(body, &kind); err != nil {
+		return nil, errors.Wrap(err, "decoding the kind of the transparency log entry")
+	}
+	logID, err := hex.DecodeString(tlog.LogID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding log ID %s", tlog.LogID)
+	}
+	e := &TransparencyLogEntry{
+		LogIndex:          strconv.FormatInt(tlog.LogIndex, 10),
+		LogID:             LogID{KeyID: logID},
+		KindVersion:       KindVersion{Kind: kind.Kind, Version: kind.APIVersion},
+		IntegratedTime:    strconv.FormatInt(tlog.IntegratedTime, 10),
+		CanonicalizedBody: body,
+	}
+	if len(tlog.SignedEntryTimestamp) > 0 {
+		e.InclusionPromise = &InclusionPromise{SignedEntryTimestamp: tlog.SignedEntryTimestamp}
+	}
+	// The checkpoint of a bundle is the one the inclusion proof was made for, not a later one.
+	if p := tlog.InclusionProof; p != nil && tlog.Checkpoint != nil && len(tlog.Checkpoint.ConsistencyProof) == 0 {
+		proof := &InclusionProof{
+			LogIndex:   strconv.FormatInt(p.LogIndex, 10),
+			TreeSize:   strconv.FormatInt(p.TreeSize, 10),
+			Hashes:     [][]byte{},
+			Checkpoint: Checkpoint{Envelope: tlog.Checkpoint.SignedNote},
+		}
+		if proof.RootHash, err = hex.DecodeString(p.RootHash); err != nil {
+			return nil, errors.Wrap(err, "decoding the root hash of the inclusion proof")
+		}
+		for _, h := range p.Hashes {
+			hash, err := hex.DecodeString(h)
+			if err != nil {
+				return nil, errors.Wrap(err, "decoding the hashes of the inclusion proof")
+			}
+			proof.Hashes = append(proof.Hashes, hash)
+		}
+		e.InclusionProof = proof
+	}
+	return e, nil
+}
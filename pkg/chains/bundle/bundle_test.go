@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bundle
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+func TestNew_MessageSignature(t *testing.T) {
+	cert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("leaf")}))
+	chain := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("intermediate")})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("root")}))
+	body := `{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{}}`
+	tlog := &config.TlogBundle{
+		Body:                 base64.StdEncoding.EncodeToString([]byte(body)),
+		IntegratedTime:       1637000000,
+		LogIndex:             7,
+		LogID:                "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+		SignedEntryTimestamp: []byte("set"),
+		InclusionProof:       &config.InclusionProof{LogIndex: 7, TreeSize: 8, RootHash: "abcd", Hashes: []string{"ef"}},
+		Checkpoint:           &config.Checkpoint{SignedNote: "rekor.sigstore.dev\n8\nq80=\n\n— rekor abcd\n"},
+	}
+
+	b, err := New([]byte("payload"), []byte("signature"), false, cert, chain, tlog)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	if b.MediaType != MediaTypeV02 {
+		t.Errorf("mediaType = %s, want %s", b.MediaType, MediaTypeV02)
+	}
+	digest := sha256.Sum256([]byte("payload"))
+	want := &MessageSignature{
+		MessageDigest: MessageDigest{Algorithm: "SHA2_256", Digest: digest[:]},
+		Signature:     []byte("signature"),
+	}
+	if d := cmp.Diff(want, b.MessageSignature); d != "" {
+		t.Errorf("messageSignature diff (-want +got):\n%s", d)
+	}
+	wantChain := &CertificateChain{Certificates: []Certificate{{RawBytes: []byte("leaf")}, {RawBytes: []byte("intermediate")}, {RawBytes: []byte("root")}}}
+	if d := cmp.Diff(wantChain, b.VerificationMaterial.X509CertificateChain); d != "" {
+		t.Errorf("x509CertificateChain diff (-want +got):\n%s", d)
+	}
+	wantEntries := []TransparencyLogEntry{{
+		LogIndex:         "7",
+		LogID:            LogID{KeyID: []byte{0xc0, 0xd2, 0x3d, 0x6a, 0xd4, 0x06, 0x97, 0x3f, 0x95, 0x59, 0xf3, 0xba, 0x2d, 0x1c, 0xa0, 0x1f, 0x84, 0x14, 0x7d, 0x8f, 0xfc, 0x5b, 0x84, 0x45, 0xc2, 0x24, 0xf9, 0x8b, 0x95, 0x91, 0x80, 0x1d}},
+		KindVersion:      KindVersion{Kind: "hashedrekord", Version: "0.0.1"},
+		IntegratedTime:   "1637000000",
+		InclusionPromise: &InclusionPromise{SignedEntryTimestamp: []byte("set")},
+		InclusionProof: &InclusionProof{
+			LogIndex:   "7",
+			RootHash:   []byte{0xab, 0xcd},
+			TreeSize:   "8",
+			Hashes:     [][]byte{{0xef}},
+			Checkpoint: Checkpoint{Envelope: tlog.Checkpoint.SignedNote},
+		},
+		CanonicalizedBody: []byte(body),
+	}}
+	if d := cmp.Diff(wantEntries, b.VerificationMaterial.TlogEntries); d != "" {
+		t.Errorf("tlogEntries diff (-want +got):\n%s", d)
+	}
+
+	// A checkpoint of a later tree doesn't go with the inclusion proof.
+	tlog.Checkpoint.ConsistencyProof = []string{"01"}
+	if b, err = New([]byte("payload"), []byte("signature"), false, cert, chain, tlog); err != nil {
+		t.Fatal(err)
+	}
+	if b.MediaType != MediaTypeV01 || b.VerificationMaterial.TlogEntries[0].InclusionProof != nil {
+		t.Errorf("expected a v0.1 bundle without an inclusion proof, got %+v", b)
+	}
+}
+
+func TestNew_Envelope(t *testing.T) {
+	envelope := `{"payloadType":"application/vnd.in-toto+json","payload":"cGF5bG9hZA==","signatures":[{"keyid":"","sig":"c2lnbmF0dXJl"}]}`
+	b, err := New([]byte("payload"), []byte(envelope), true, "", "", nil)
+	if err != nil {
+		t.Fatalf("New() = %v", err)
+	}
+	want := &Envelope{
+		Payload:     []byte("payload"),
+		PayloadType: "application/vnd.in-toto+json",
+		Signatures:  []Signature{{Sig: []byte("signature")}},
+	}
+	if d := cmp.Diff(want, b.DSSEEnvelope); d != "" {
+		t.Errorf("dsseEnvelope diff (-want +got):\n%s", d)
+	}
+	if b.MessageSignature != nil || b.VerificationMaterial.PublicKey == nil || len(b.VerificationMaterial.TlogEntries) != 0 {
+		t.Errorf("expected a bundle verified with a public key, without log entries, got %+v", b)
+	}
+
+	if _, err := New([]byte("payload"), []byte("signature"), true, "", "", nil); err == nil {
+		t.Error("expected an error for a signature that isn't an envelope")
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cache keeps the keys that signatures are made and verified with in memory for a
+// while, so they aren't fetched from KMSs and transparency logs for every TaskRun.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long keys are cached for by default. A rotated key is picked up once the
+// old one expires.
+const DefaultTTL = 5 * time.Minute
+
+// Cache holds values for a time to live.
+type Cache struct {
+	ttl time.Duration
+	// now is set for testing.
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// New returns a Cache that holds values for ttl.
+func New(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, now: time.Now, entries: map[string]entry{}}
+}
+
+// Get returns the value of key, calling fetch to get it when it isn't cached or has expired.
+// Errors aren't cached, so a failed fetch is tried again on the next Get. The lock isn't held
+// while fetching, so a slow fetch doesn't hold up the other keys, and keys fetched at the same
+// time may be fetched more than once.
+func (c *Cache) Get(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.value, nil
+	}
+	v, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry{value: v, expires: c.now().Add(c.ttl)}
+	return v, nil
+}
+
+// Purge empties the cache.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]entry{}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGet(t *testing.T) {
+	now := time.Now()
+	c := New(time.Minute)
+	c.now = func() time.Time { return now }
+
+	fetches := 0
+	fetch := func() (interface{}, error) {
+		fetches++
+		return fetches, nil
+	}
+	get := func(key string) interface{} {
+		t.Helper()
+		v, err := c.Get(key, fetch)
+		if err != nil {
+			t.Fatalf("Get(%s) = %v", key, err)
+		}
+		return v
+	}
+
+	if v := get("a"); v != 1 {
+		t.Errorf("Get(a) = %v, want 1", v)
+	}
+	if v := get("a"); v != 1 {
+		t.Errorf("Get(a) = %v, want the cached 1", v)
+	}
+	if v := get("b"); v != 2 {
+		t.Errorf("Get(b) = %v, want 2", v)
+	}
+
+	now = now.Add(time.Minute)
+	if v := get("a"); v != 3 {
+		t.Errorf("Get(a) = %v, want 3 once expired", v)
+	}
+
+	if _, err := c.Get("c", func() (interface{}, error) { return nil, errors.New("unavailable") }); err == nil {
+		t.Error("expected the error of the fetch")
+	}
+	if v := get("c"); v != 4 {
+		t.Errorf("Get(c) = %v, want 4 since errors aren't cached", v)
+	}
+
+	c.Purge()
+	if v := get("b"); v != 5 {
+		t.Errorf("Get(b) = %v, want 5 once purged", v)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloudevents sends CloudEvents about signed TaskRuns to a sink, using
+// the HTTP protocol binding in binary content mode.
+package cloudevents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+const (
+	// SignedEventType is the type of the event sent once a TaskRun has been signed.
+	SignedEventType = "dev.tekton.chains.taskrun.signed.v1"
+
+	specVersion = "1.0"
+)
+
+// Attestation describes a single signed payload.
+type Attestation struct {
+	// Subject is the image reference or TaskRun that was attested to.
+	Subject string `json:"subject"`
+	// Digest is the digest of the subject, for images.
+	Digest string `json:"digest,omitempty"`
+	// PayloadType is the format of the signed payload.
+	PayloadType string `json:"payloadType"`
+	// StorageBackend is the backend the payload and signature were stored in.
+	StorageBackend string `json:"storageBackend"`
+	// StorageKey is the key the payload and signature were stored under.
+	StorageKey string `json:"storageKey"`
+	// RekorLogIndex is the index of the transparency log entry, if one was uploaded.
+	RekorLogIndex *int64 `json:"rekorLogIndex,omitempty"`
+	// RekorUUID identifies the transparency log entry, if one was uploaded.
+	RekorUUID string `json:"rekorUUID,omitempty"`
+}
+
+// SignedEventData is the data of a SignedEventType event.
+type SignedEventData struct {
+	Namespace    string        `json:"namespace"`
+	Name         string        `json:"name"`
+	UID          string        `json:"uid"`
+	Attestations []Attestation `json:"attestations"`
+}
+
+// Client sends events to a sink.
+type Client struct {
+	HTTPClient *http.Client
+	Sink       string
+}
+
+// NewClient returns a Client that sends events to sink.
+func NewClient(sink string) *Client {
+	return &Client{
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Sink:       sink,
+	}
+}
+
+// SendSigned sends a SignedEventType event for the TaskRun.
+func (c *Client) SendSigned(ctx context.Context, tr *v1beta1.TaskRun, id string, attestations []Attestation) error {
+	body, err := json.Marshal(SignedEventData{
+		Namespace:    tr.Namespace,
+		Name:         tr.Name,
+		UID:          string(tr.UID),
+		Attestations: attestations,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Sink, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "creating cloudevent request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Specversion", specVersion)
+	req.Header.Set("Ce-Type", SignedEventType)
+	req.Header.Set("Ce-Source", fmt.Sprintf("/apis/tekton.dev/v1beta1/namespaces/%s/taskruns/%s", tr.Namespace, tr.Name))
+	req.Header.Set("Ce-Subject", tr.Name)
+	req.Header.Set("Ce-Id", id)
+	req.Header.Set("Ce-Time", time.Now().UTC().Format(time.RFC3339))
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "sending cloudevent to %s", c.Sink)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sending cloudevent to %s: unexpected status %s", c.Sink, resp.Status)
+	}
+	return nil
+}
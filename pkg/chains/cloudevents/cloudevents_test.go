@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClient_SendSigned(t *testing.T) {
+	var gotHeaders http.Header
+	var gotData SignedEventData
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&gotData); err != nil {
+			t.Errorf("error decoding event: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "bar",
+			UID:       "1234",
+		},
+	}
+	index := int64(7)
+	attestations := []Attestation{{
+		Subject:        "gcr.io/foo/bar@sha256:abcd",
+		Digest:         "sha256:abcd",
+		PayloadType:    "simplesigning",
+		StorageBackend: "oci",
+		StorageKey:     "abcd",
+		RekorLogIndex:  &index,
+	}}
+
+	c := NewClient(server.URL)
+	if err := c.SendSigned(context.Background(), tr, "1234-0", attestations); err != nil {
+		t.Fatalf("SendSigned() error = %v", err)
+	}
+
+	wantHeaders := map[string]string{
+		"Ce-Specversion": "1.0",
+		"Ce-Type":        SignedEventType,
+		"Ce-Source":      "/apis/tekton.dev/v1beta1/namespaces/bar/taskruns/foo",
+		"Ce-Id":          "1234-0",
+		"Content-Type":   "application/json",
+	}
+	for k, v := range wantHeaders {
+		if got := gotHeaders.Get(k); got != v {
+			t.Errorf("header %s = %q, want %q", k, got, v)
+		}
+	}
+	want := SignedEventData{Namespace: "bar", Name: "foo", UID: "1234", Attestations: attestations}
+	if diff := cmp.Diff(want, gotData); diff != "" {
+		t.Errorf("event data mismatch (-want +got): %s", diff)
+	}
+}
+
+func TestClient_SendSignedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	if err := c.SendSigned(context.Background(), &v1beta1.TaskRun{}, "id", nil); err == nil {
+		t.Error("expected error for non-2xx response")
+	}
+}
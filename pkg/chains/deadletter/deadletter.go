@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deadletter records TaskRuns that chains gave up signing in a docstore collection.
+package deadletter
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"gocloud.dev/docstore"
+	_ "gocloud.dev/docstore/awsdynamodb"
+	_ "gocloud.dev/docstore/gcpfirestore"
+)
+
+// Record describes a TaskRun whose signing permanently failed.
+type Record struct {
+	// Name is the key of the record, the UID of the TaskRun.
+	Name      string
+	Namespace string
+	TaskRun   string
+	Reason    string
+	Error     string
+	// FailedAt is when signing was given up on, in RFC3339 format.
+	FailedAt string
+}
+
+// NewRecord returns the Record for a TaskRun that failed to be signed.
+func NewRecord(tr *v1beta1.TaskRun, reason string, err error) *Record {
+	return &Record{
+		Name:      string(tr.UID),
+		Namespace: tr.Namespace,
+		TaskRun:   tr.Name,
+		Reason:    reason,
+		Error:     err.Error(),
+		FailedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// Write stores the record in the docstore collection at url, for example
+// firestore://projects/[PROJECT]/databases/(default)/documents/[COLLECTION]?name_field=name
+func Write(ctx context.Context, url string, r *Record) error {
+	coll, err := docstore.OpenCollection(ctx, url)
+	if err != nil {
+		return errors.Wrap(err, "opening dead letter collection")
+	}
+	if err := coll.Put(ctx, r); err != nil {
+		coll.Close()
+		return errors.Wrapf(err, "writing dead letter record for %s/%s", r.Namespace, r.TaskRun)
+	}
+	return coll.Close()
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"gocloud.dev/docstore"
+	_ "gocloud.dev/docstore/memdocstore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	// Use a file backed collection, so the record can be read back after Write closes it.
+	file := filepath.Join(t.TempDir(), "deadletter")
+	url := "mem://deadletter/Name?filename=" + file
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "bar",
+			UID:       "1234",
+		},
+	}
+	if err := Write(ctx, url, NewRecord(tr, "StorageFailed", errors.New("bucket not found"))); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	coll, err := docstore.OpenCollection(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+	got := &Record{Name: "1234"}
+	if err := coll.Get(ctx, got); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Namespace != "bar" || got.TaskRun != "foo" || got.Reason != "StorageFailed" || got.Error != "bucket not found" {
+		t.Errorf("unexpected record: %+v", got)
+	}
+}
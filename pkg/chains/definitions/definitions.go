@@ -0,0 +1,305 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package definitions resolves the Tekton bundles a TaskRun got its Task and Pipeline
+// definitions from, and the ClusterTask it ran, so provenance can record which definitions
+// actually ran, and verifies the signatures of the bundles, so provenance can assert the
+// build ran trusted definitions.
+package definitions
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/pkg/cosign"
+	ociremote "github.com/sigstore/cosign/pkg/oci/remote"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/tektoncd/chains/pkg/chains/signing/kms"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/logging"
+)
+
+// Annotation records the resolved definitions on the TaskRun, as JSON.
+const Annotation = "chains.tekton.dev/definitions"
+
+const (
+	KindTask     = "Task"
+	KindPipeline = "Pipeline"
+
+	// ResolverBundles is the resolver of definitions read from Tekton bundles.
+	ResolverBundles = "bundles"
+	// ResolverCluster is the resolver of ClusterTasks, read from the cluster.
+	ResolverCluster = "cluster"
+)
+
+// Result is a definition read from a bundle or a ClusterTask, and the outcome of verifying
+// the bundle.
+type Result struct {
+	// Kind is Task or Pipeline.
+	Kind string `json:"kind"`
+	// Name is the name of the definition in the bundle, or of the ClusterTask.
+	Name string `json:"name"`
+	// Resolver is how the definition was resolved: from a bundle, or from the cluster.
+	Resolver string `json:"resolver"`
+	// Bundle is the reference to the bundle, as written in the TaskRun or PipelineRun.
+	Bundle string `json:"bundle,omitempty"`
+	// Digest is the digest the bundle reference resolved to, e.g. sha256:..., or the digest
+	// of the YAML of the spec of the ClusterTask the TaskRun ran.
+	Digest string `json:"digest,omitempty"`
+	// Verified is true when the bundle is signed by the trusted key.
+	Verified bool `json:"verified"`
+	// Error explains why the bundle could not be resolved or verified.
+	Error string `json:"error,omitempty"`
+}
+
+// URI is the URI of the bundle the definition was read from, without its digest.
+func (r Result) URI() string {
+	if r.Resolver == ResolverCluster {
+		return "clustertask://" + r.Name
+	}
+	ref, err := name.ParseReference(r.Bundle)
+	if err != nil {
+		return "oci://" + r.Bundle
+	}
+	if tag, ok := ref.(name.Tag); ok {
+		return "oci://" + tag.Name()
+	}
+	return "oci://" + ref.Context().Name()
+}
+
+// DigestSet is the digest of the bundle, keyed by algorithm.
+func (r Result) DigestSet() map[string]string {
+	parts := strings.SplitN(r.Digest, ":", 2)
+	if len(parts) != 2 {
+		return nil
+	}
+	return map[string]string{parts[0]: parts[1]}
+}
+
+// Set these as vars for mocking.
+var (
+	resolveBundle = func(ctx context.Context, ref name.Reference, keychain authn.Keychain) (string, error) {
+		desc, err := remote.Head(ref, remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		return desc.Digest.String(), nil
+	}
+	verifyBundle = func(ctx context.Context, ref name.Reference, co *cosign.CheckOpts) error {
+		_, _, err := cosign.VerifyImageSignatures(ctx, ref, co)
+		return err
+	}
+)
+
+// Resolve lists the definitions of the TaskRun that came from bundles: its Task and, when it
+// ran as part of a PipelineRun, the Pipeline. It resolves the digests of the bundles.
+// A ClusterTask is included with the digest of the spec the TaskRun ran. Other definitions
+// that didn't come from a bundle, such as Tasks in the namespace, are not included.
+func Resolve(ctx context.Context, kc kubernetes.Interface, ps versioned.Interface, tr *v1beta1.TaskRun) ([]Result, error) {
+	results, err := bundles(ctx, ps, tr)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > 0 {
+		if err := resolveBundles(ctx, kc, tr, results); err != nil {
+			return nil, err
+		}
+	}
+	if ref := tr.Spec.TaskRef; ref != nil && ref.Bundle == "" && ref.Kind == v1beta1.ClusterTaskKind {
+		results = append([]Result{clusterTask(ref.Name, tr)}, results...)
+	}
+	return results, nil
+}
+
+// resolveBundles resolves the digests of the bundles of results.
+func resolveBundles(ctx context.Context, kc kubernetes.Interface, tr *v1beta1.TaskRun, results []Result) error {
+	keychain, err := keychain(ctx, kc, tr)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		ref, err := name.ParseReference(r.Bundle)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if d, ok := ref.(name.Digest); ok {
+			results[i].Digest = d.DigestStr()
+			continue
+		}
+		if results[i].Digest, err = resolveBundle(ctx, ref, keychain); err != nil {
+			logging.FromContext(ctx).Infof("Could not resolve %s %s from bundle %s: %v", r.Kind, r.Name, r.Bundle, err)
+			results[i].Error = err.Error()
+		}
+	}
+	return nil
+}
+
+// clusterTask describes the ClusterTask the TaskRun ran. The ClusterTask may have changed
+// since, so its digest is that of the spec Tekton Pipelines copied to the TaskRun.
+func clusterTask(name string, tr *v1beta1.TaskRun) Result {
+	r := Result{Kind: KindTask, Name: name, Resolver: ResolverCluster}
+	if tr.Status.TaskSpec == nil {
+		r.Error = "the TaskRun has no spec of the ClusterTask"
+		return r
+	}
+	digest, err := SpecDigest(tr.Status.TaskSpec)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Digest = digest
+	return r
+}
+
+// SpecDigest returns the sha256 digest of the YAML of spec.
+func SpecDigest(spec *v1beta1.TaskSpec) (string, error) {
+	b, err := yaml.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b)), nil
+}
+
+// Verify resolves the definitions of the TaskRun like Resolve, and verifies that the bundles
+// they came from are signed by the key in the configuration.
+func Verify(ctx context.Context, cfg config.Config, kc kubernetes.Interface, ps versioned.Interface, tr *v1beta1.TaskRun) ([]Result, error) {
+	results, err := Resolve(ctx, kc, ps, tr)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	verifier, err := LoadVerifier(ctx, cfg.Definitions.Key)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading the key to verify definitions with")
+	}
+	keychain, err := keychain(ctx, kc, tr)
+	if err != nil {
+		return nil, err
+	}
+	co := &cosign.CheckOpts{
+		RegistryClientOpts: []ociremote.Option{
+			ociremote.WithRemoteOptions(remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx)),
+		},
+		SigVerifier:   verifier,
+		ClaimVerifier: cosign.SimpleClaimVerifier,
+	}
+	for i, r := range results {
+		if r.Error != "" || r.Resolver == ResolverCluster {
+			continue
+		}
+		// Verify the digest that was resolved, so the result describes the recorded digest.
+		ref, err := name.ParseReference(r.Bundle)
+		if err == nil {
+			err = verifyBundle(ctx, ref.Context().Digest(r.Digest), co)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Infof("%s %s from bundle %s is not verified: %v", r.Kind, r.Name, r.Bundle, err)
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Verified = true
+	}
+	return results, nil
+}
+
+func keychain(ctx context.Context, kc kubernetes.Interface, tr *v1beta1.TaskRun) (authn.Keychain, error) {
+	if kc == nil {
+		return authn.DefaultKeychain, nil
+	}
+	keychain, err := k8schain.New(ctx, kc, k8schain.Options{Namespace: tr.Namespace, ServiceAccountName: tr.Spec.ServiceAccountName})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating keychain")
+	}
+	return keychain, nil
+}
+
+// bundles lists the definitions of the TaskRun that came from bundles.
+func bundles(ctx context.Context, ps versioned.Interface, tr *v1beta1.TaskRun) ([]Result, error) {
+	var results []Result
+	if ref := tr.Spec.TaskRef; ref != nil && ref.Bundle != "" {
+		results = append(results, Result{Kind: KindTask, Name: ref.Name, Resolver: ResolverBundles, Bundle: ref.Bundle})
+	}
+	prName := tr.Labels[pipeline.PipelineRunLabelKey]
+	if prName == "" || ps == nil {
+		return results, nil
+	}
+	pr, err := ps.TektonV1beta1().PipelineRuns(tr.Namespace).Get(ctx, prName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return results, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting PipelineRun %s/%s", tr.Namespace, prName)
+	}
+	if ref := pr.Spec.PipelineRef; ref != nil && ref.Bundle != "" {
+		results = append(results, Result{Kind: KindPipeline, Name: ref.Name, Resolver: ResolverBundles, Bundle: ref.Bundle})
+	}
+	return results, nil
+}
+
+// LoadVerifier loads a PEM encoded public key file, or a key in a KMS when ref is a KMS
+// reference such as gcpkms://projects/...
+func LoadVerifier(ctx context.Context, ref string) (signature.Verifier, error) {
+	if strings.Contains(ref, "://") {
+		return kms.NewSigner(config.KMSSigner{KMSRef: ref}, logging.FromContext(ctx))
+	}
+	return signature.LoadVerifierFromPEMFile(ref, crypto.SHA256)
+}
+
+// Verified reports whether all the bundles of results are verified. ClusterTasks aren't
+// signed, so they aren't verified.
+func Verified(results []Result) bool {
+	for _, r := range results {
+		if r.Resolver != ResolverCluster && !r.Verified {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode encodes results as the value of Annotation.
+func Encode(results []Result) (string, error) {
+	if results == nil {
+		results = []Result{}
+	}
+	b, err := json.Marshal(results)
+	return string(b), err
+}
+
+// FromTaskRun decodes the results recorded on the TaskRun.
+func FromTaskRun(tr *v1beta1.TaskRun) ([]Result, error) {
+	value, ok := tr.Annotations[Annotation]
+	if !ok {
+		return nil, nil
+	}
+	var results []Result
+	if err := json.Unmarshal([]byte(value), &results); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s annotation", Annotation)
+	}
+	return results, nil
+}
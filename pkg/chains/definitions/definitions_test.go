@@ -0,0 +1,301 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package definitions
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func writeKey(t *testing.T) string {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := ioutil.WriteFile(path, pub, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const digest = "sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+
+func mockResolve(t *testing.T) {
+	old := resolveBundle
+	t.Cleanup(func() { resolveBundle = old })
+	resolveBundle = func(_ context.Context, ref name.Reference, _ authn.Keychain) (string, error) {
+		if strings.Contains(ref.String(), "missing") {
+			return "", errors.New("MANIFEST_UNKNOWN")
+		}
+		return digest, nil
+	}
+}
+
+func TestResolve(t *testing.T) {
+	mockResolve(t)
+	pr := &v1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "pr", Namespace: "default"},
+		Spec: v1beta1.PipelineRunSpec{
+			PipelineRef: &v1beta1.PipelineRef{Name: "release", Bundle: "gcr.io/foo/pipelines@" + digest},
+		},
+	}
+	ps := fakepipelineclient.NewSimpleClientset(pr)
+	spec := &v1beta1.TaskSpec{Steps: []v1beta1.Step{{Container: corev1.Container{Name: "build", Image: "golang"}}}}
+	specDigest, err := SpecDigest(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		tr   *v1beta1.TaskRun
+		want []Result
+	}{
+		{
+			name: "task in the namespace",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build"}},
+			},
+		},
+		{
+			name: "cluster task",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build", Kind: v1beta1.ClusterTaskKind}},
+				Status: v1beta1.TaskRunStatus{
+					TaskRunStatusFields: v1beta1.TaskRunStatusFields{TaskSpec: spec},
+				},
+			},
+			want: []Result{{Kind: KindTask, Name: "build", Resolver: ResolverCluster, Digest: specDigest}},
+		},
+		{
+			name: "cluster task without a spec",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build", Kind: v1beta1.ClusterTaskKind}},
+			},
+			want: []Result{{Kind: KindTask, Name: "build", Resolver: ResolverCluster, Error: "the TaskRun has no spec of the ClusterTask"}},
+		},
+		{
+			name: "tagged task bundle",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build", Bundle: "gcr.io/foo/tasks:v1"}},
+			},
+			want: []Result{{Kind: KindTask, Name: "build", Resolver: ResolverBundles, Bundle: "gcr.io/foo/tasks:v1", Digest: digest}},
+		},
+		{
+			name: "missing task bundle",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build", Bundle: "gcr.io/foo/missing:v1"}},
+			},
+			want: []Result{{Kind: KindTask, Name: "build", Resolver: ResolverBundles, Bundle: "gcr.io/foo/missing:v1", Error: "MANIFEST_UNKNOWN"}},
+		},
+		{
+			name: "pipeline bundle by digest",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+					Labels:    map[string]string{"tekton.dev/pipelineRun": "pr"},
+				},
+			},
+			want: []Result{{Kind: KindPipeline, Name: "release", Resolver: ResolverBundles, Bundle: "gcr.io/foo/pipelines@" + digest, Digest: digest}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(context.Background(), nil, ps, tt.tr)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Resolve() -want +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestResultMaterial(t *testing.T) {
+	tests := []struct {
+		bundle     string
+		digest     string
+		wantURI    string
+		wantDigest map[string]string
+	}{
+		{bundle: "gcr.io/foo/tasks:v1", digest: digest, wantURI: "oci://gcr.io/foo/tasks:v1", wantDigest: map[string]string{"sha256": digest[len("sha256:"):]}},
+		{bundle: "gcr.io/foo/tasks@" + digest, digest: digest, wantURI: "oci://gcr.io/foo/tasks", wantDigest: map[string]string{"sha256": digest[len("sha256:"):]}},
+		{bundle: "gcr.io/foo/tasks", wantURI: "oci://gcr.io/foo/tasks:latest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bundle, func(t *testing.T) {
+			r := Result{Bundle: tt.bundle, Digest: tt.digest}
+			if got := r.URI(); got != tt.wantURI {
+				t.Errorf("URI() = %s, want %s", got, tt.wantURI)
+			}
+			if diff := cmp.Diff(tt.wantDigest, r.DigestSet()); diff != "" {
+				t.Errorf("DigestSet() -want +got: %s", diff)
+			}
+		})
+	}
+
+	r := Result{Name: "build", Resolver: ResolverCluster, Digest: digest}
+	if got := r.URI(); got != "clustertask://build" {
+		t.Errorf("URI() = %s, want clustertask://build", got)
+	}
+	// ClusterTasks aren't signed, so they don't need to be verified.
+	if !Verified([]Result{r}) {
+		t.Error("expected a ClusterTask not to need verification")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	mockResolve(t)
+	old := verifyBundle
+	defer func() { verifyBundle = old }()
+	// Bundles are signed unless their name says otherwise.
+	verifyBundle = func(_ context.Context, ref name.Reference, _ *cosign.CheckOpts) error {
+		if _, ok := ref.(name.Digest); !ok {
+			return errors.New("not verified by digest")
+		}
+		if strings.Contains(ref.String(), "unsigned") {
+			return errors.New("no matching signatures")
+		}
+		return nil
+	}
+
+	pr := &v1beta1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "pr", Namespace: "default"},
+		Spec: v1beta1.PipelineRunSpec{
+			PipelineRef: &v1beta1.PipelineRef{Name: "release", Bundle: "gcr.io/foo/pipelines-unsigned:v1"},
+		},
+	}
+	ps := fakepipelineclient.NewSimpleClientset(pr)
+	cfg := config.Config{Definitions: config.DefinitionsConfig{Verify: true, Key: writeKey(t)}}
+
+	tests := []struct {
+		name string
+		tr   *v1beta1.TaskRun
+		want []Result
+	}{
+		{
+			name: "cluster task",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build"}},
+			},
+		},
+		{
+			name: "signed task bundle",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build", Bundle: "gcr.io/foo/tasks:v1"}},
+			},
+			want: []Result{{Kind: KindTask, Name: "build", Resolver: ResolverBundles, Bundle: "gcr.io/foo/tasks:v1", Digest: digest, Verified: true}},
+		},
+		{
+			name: "unsigned pipeline bundle",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+					Labels:    map[string]string{"tekton.dev/pipelineRun": "pr"},
+				},
+				Spec: v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build", Bundle: "gcr.io/foo/tasks:v1"}},
+			},
+			want: []Result{
+				{Kind: KindTask, Name: "build", Resolver: ResolverBundles, Bundle: "gcr.io/foo/tasks:v1", Digest: digest, Verified: true},
+				{Kind: KindPipeline, Name: "release", Resolver: ResolverBundles, Bundle: "gcr.io/foo/pipelines-unsigned:v1", Digest: digest, Error: "no matching signatures"},
+			},
+		},
+		{
+			name: "deleted pipelinerun",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "default",
+					Labels:    map[string]string{"tekton.dev/pipelineRun": "gone"},
+				},
+			},
+		},
+		{
+			name: "invalid reference",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       v1beta1.TaskRunSpec{TaskRef: &v1beta1.TaskRef{Name: "build", Bundle: "gcr.io/foo/UPPER"}},
+			},
+			want: []Result{{Kind: KindTask, Name: "build", Resolver: ResolverBundles, Bundle: "gcr.io/foo/UPPER", Error: "could not parse reference: gcr.io/foo/UPPER"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(context.Background(), cfg, nil, ps, tt.tr)
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Verify() -want +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	results := []Result{{Kind: KindTask, Name: "build", Resolver: ResolverBundles, Bundle: "gcr.io/foo/tasks:v1", Digest: digest, Verified: true}}
+	value, err := Encode(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{Annotation: value}}}
+	got, err := FromTaskRun(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(results, got); diff != "" {
+		t.Errorf("FromTaskRun() -want +got: %s", diff)
+	}
+
+	if value, _ := Encode(nil); value != "[]" {
+		t.Errorf("Encode(nil) = %s, want []", value)
+	}
+	if got, err := FromTaskRun(&v1beta1.TaskRun{}); got != nil || err != nil {
+		t.Errorf("FromTaskRun() = %v, %v without the annotation", got, err)
+	}
+}
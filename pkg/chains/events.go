@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/tektoncd/chains/pkg/artifacts"
+	"github.com/tektoncd/chains/pkg/chains/cloudevents"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/controller"
+)
+
+// Reasons for the Events recorded on TaskRuns.
+const (
+	// EventReasonSigned is recorded once all payloads of a TaskRun have been signed and stored.
+	EventReasonSigned = "Signed"
+	// EventReasonSigningFailed is recorded when a payload could not be created or signed.
+	EventReasonSigningFailed = "SigningFailed"
+	// EventReasonStorageFailed is recorded when a signed payload could not be stored in a backend.
+	EventReasonStorageFailed = "StorageFailed"
+	// EventReasonTransparencyFailed is the failure reason when an entry could not be uploaded to the transparency log.
+	EventReasonTransparencyFailed = "TransparencyFailed"
+	// EventReasonDryRun is recorded once unsigned payloads have been stored in dry-run mode.
+	EventReasonDryRun = "DryRun"
+	// EventReasonSigningTimeout is recorded when signing and storing a TaskRun takes longer than the signing timeout.
+	EventReasonSigningTimeout = "SigningTimeout"
+	// EventReasonPolicyFailed is recorded when provenance doesn't pass the policy, so no VSA is signed for it.
+	EventReasonPolicyFailed = "PolicyFailed"
+	// EventReasonDefinitionsUnverified is the failure reason when definitions.verify.required is set and
+	// the Task or Pipeline of a TaskRun didn't come from a bundle signed by the trusted key.
+	EventReasonDefinitionsUnverified = "DefinitionsUnverified"
+	// EventReasonSBOMFailed is recorded when an SBOM a TaskRun produced could not be signed and attached to its image.
+	EventReasonSBOMFailed = "SBOMFailed"
+	// EventReasonInvalidImages is recorded when the IMAGES result of a TaskRun lists entries that aren't signed.
+	EventReasonInvalidImages = "InvalidImages"
+	// EventReasonSecretsRedacted is recorded when secrets are redacted from a payload before it is signed.
+	EventReasonSecretsRedacted = "SecretsRedacted"
+	// EventReasonSecretsFound is the failure reason when secrets are found in a payload and redact.action is fail.
+	EventReasonSecretsFound = "SecretsFound"
+)
+
+// recordEvent records an Event on the TaskRun with the recorder in ctx, if there is one.
+func recordEvent(ctx context.Context, tr *v1beta1.TaskRun, eventType, reason, messageFmt string, args ...interface{}) {
+	if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+		recorder.Eventf(tr, eventType, reason, messageFmt, args...)
+	}
+}
+
+func recordWarning(ctx context.Context, tr *v1beta1.TaskRun, reason, messageFmt string, args ...interface{}) {
+	recordEvent(ctx, tr, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// newAttestation describes a payload stored for obj, for use in CloudEvents.
+func newAttestation(obj interface{}, payloadType, backend, key string) cloudevents.Attestation {
+	a := cloudevents.Attestation{
+		PayloadType:    payloadType,
+		StorageBackend: backend,
+		StorageKey:     key,
+	}
+	switch o := obj.(type) {
+	case name.Digest:
+		a.Subject = o.String()
+		a.Digest = o.DigestStr()
+	case *v1beta1.TaskRun:
+		a.Subject = fmt.Sprintf("%s/%s", o.Namespace, o.Name)
+	case *artifacts.Blob:
+		a.Subject = o.URI
+		a.Digest = "sha256:" + o.Digest
+	}
+	return a
+}
+
+// signedEventID is unique for each signing of a TaskRun, so sinks can drop duplicates.
+func signedEventID(tr *v1beta1.TaskRun) string {
+	return fmt.Sprintf("%s-%d", tr.UID, SigningVersion(tr))
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formats
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+// BuilderAnnotation records the versions of Tekton Pipelines and Chains that built and
+// signed a TaskRun, and the feature flags of Tekton Pipelines, as JSON.
+const BuilderAnnotation = "chains.tekton.dev/builder"
+
+// Builder describes the Tekton installation that ran a TaskRun, so that policies can require
+// specific versions and features.
+type Builder struct {
+	// ChainsVersion is the version of the Chains controller that signed the TaskRun.
+	ChainsVersion string `json:"chainsVersion,omitempty"`
+	// PipelinesVersion is the version of Tekton Pipelines that created the pod of the TaskRun.
+	PipelinesVersion string `json:"pipelinesVersion,omitempty"`
+	// FeatureFlags are the values of the feature flags of Tekton Pipelines, defaults included.
+	FeatureFlags map[string]string `json:"featureFlags,omitempty"`
+}
+
+// BuilderFromTaskRun decodes the builder recorded on the TaskRun, or returns nil if there's
+// none.
+func BuilderFromTaskRun(tr *v1beta1.TaskRun) (*Builder, error) {
+	value, ok := tr.Annotations[BuilderAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	b := &Builder{}
+	if err := json.Unmarshal([]byte(value), b); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s annotation", BuilderAnnotation)
+	}
+	return b, nil
+}
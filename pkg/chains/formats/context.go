@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formats
+
+import (
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+// Context is the organizational context of a TaskRun recorded in provenance, such as the team
+// it belongs to or the change ticket it was run for.
+type Context struct {
+	// Labels are the labels of the TaskRun listed by provenance.labels.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are the annotations of the TaskRun listed by provenance.annotations.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ContextFromTaskRun returns the labels and annotations of the TaskRun cfg lists, or nil if
+// it has none of them. Keys the TaskRun doesn't have are left out.
+func ContextFromTaskRun(tr *v1beta1.TaskRun, cfg config.ProvenanceConfig) *Context {
+	c := &Context{
+		Labels:      selectKeys(tr.Labels, cfg.Labels),
+		Annotations: selectKeys(tr.Annotations, cfg.Annotations),
+	}
+	if c.Labels == nil && c.Annotations == nil {
+		return nil
+	}
+	return c
+}
+
+func selectKeys(m map[string]string, keys []string) map[string]string {
+	var selected map[string]string
+	for _, k := range keys {
+		v, ok := m[k]
+		if !ok {
+			continue
+		}
+		if selected == nil {
+			selected = map[string]string{}
+		}
+		selected[k] = v
+	}
+	return selected
+}
@@ -26,6 +26,7 @@ import (
 
 	intoto "github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/tektoncd/chains/pkg/artifacts"
+	"github.com/tektoncd/chains/pkg/chains/definitions"
 	"github.com/tektoncd/chains/pkg/chains/formats"
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
@@ -41,18 +42,30 @@ const (
 	urlParam                     = "CHAINS-GIT_URL"
 	ociDigestResult              = "IMAGE_DIGEST"
 	chainsDigestSuffix           = "_DIGEST"
-	ChainsReproducibleAnnotation = "chains.tekton.dev/reproducible"
+	ChainsReproducibleAnnotation = formats.ReproducibleAnnotation
 )
 
 type InTotoIte6 struct {
 	builderID string
-	logger    *zap.SugaredLogger
+	// definitions records the verification of the definitions of TaskRuns in the invocation.
+	definitions bool
+	rewriter    *artifacts.SubjectRewriter
+	// context are the labels and annotations of TaskRuns recorded in the invocation.
+	context config.ProvenanceConfig
+	logger  *zap.SugaredLogger
 }
 
 func NewFormatter(cfg config.Config, logger *zap.SugaredLogger) (formats.Payloader, error) {
+	rewriter, err := artifacts.NewSubjectRewriter(cfg.Subjects.Rewrites)
+	if err != nil {
+		return nil, err
+	}
 	return &InTotoIte6{
-		builderID: cfg.Builder.ID,
-		logger:    logger,
+		builderID:   cfg.Builder.ID,
+		definitions: cfg.Definitions.Verify,
+		rewriter:    rewriter,
+		context:     cfg.Provenance,
+		logger:      logger,
 	}, nil
 }
 
@@ -61,20 +74,68 @@ func (i *InTotoIte6) Wrap() bool {
 }
 
 func (i *InTotoIte6) CreatePayload(obj interface{}) (interface{}, error) {
-	var tr *v1beta1.TaskRun
 	switch v := obj.(type) {
 	case *v1beta1.TaskRun:
-		tr = v
+		return i.generateAttestationFromTaskRun(v, GetSubjectDigests(v, i.logger))
+	case *artifacts.Blob:
+		// The attestation of a blob is about that blob alone, so it can be verified with it.
+		return i.generateAttestationFromTaskRun(v.TaskRun, []intoto.Subject{{
+			Name:   v.URI,
+			Digest: slsa.DigestSet{"sha256": v.Digest},
+		}})
 	default:
 		return nil, fmt.Errorf("intoto does not support type: %s", v)
 	}
-	return i.generateAttestationFromTaskRun(tr)
 }
 
 // generateAttestationFromTaskRun translates a Tekton TaskRun into an in-toto attestation
 // with the slsa-provenance predicate type
-func (i *InTotoIte6) generateAttestationFromTaskRun(tr *v1beta1.TaskRun) (interface{}, error) {
-	subjects := GetSubjectDigests(tr, i.logger)
+func (i *InTotoIte6) generateAttestationFromTaskRun(tr *v1beta1.TaskRun, subjects []intoto.Subject) (interface{}, error) {
+	subjects = i.rewriter.RewriteSubjects(subjects)
+	defs, err := definitions.FromTaskRun(tr)
+	if err != nil {
+		return nil, err
+	}
+	inv := invocation(tr)
+	for _, d := range defs {
+		if d.Kind == definitions.KindTask && d.Digest != "" {
+			inv.ConfigSource = slsa.ConfigSource{URI: d.URI(), Digest: d.DigestSet(), EntryPoint: d.Name}
+		}
+	}
+	builder, err := formats.BuilderFromTaskRun(tr)
+	if err != nil {
+		return nil, err
+	}
+	isolation, err := formats.IsolationFromTaskRun(tr)
+	if err != nil {
+		return nil, err
+	}
+	env := map[string]interface{}{}
+	if taskRun := formats.InvocationEnvironment(tr); taskRun != nil {
+		env["taskRun"] = taskRun
+	}
+	// The SLSA builder only has an ID, so what's known about it is recorded here.
+	if builder != nil {
+		env["builder"] = builder
+	}
+	// SLSA v0.2 has no field for hermeticity either.
+	if isolation != nil {
+		env["isolation"] = isolation
+	}
+	if rebuild := formats.RebuildInstructions(tr, defs); rebuild != nil {
+		env["rebuild"] = rebuild
+	}
+	// Nor for organizational context.
+	if context := formats.ContextFromTaskRun(tr, i.context); context != nil {
+		env["context"] = context
+	}
+	// Chains only records the results once it has verified the definitions itself.
+	if i.definitions && defs != nil {
+		env["definitions"] = defs
+	}
+	if len(env) > 0 {
+		inv.Environment = env
+	}
 
 	att := intoto.ProvenanceStatement{
 		StatementHeader: intoto.StatementHeader{
@@ -87,54 +148,33 @@ func (i *InTotoIte6) generateAttestationFromTaskRun(tr *v1beta1.TaskRun) (interf
 				ID: i.builderID,
 			},
 			BuildType:   tektonID,
-			Invocation:  invocation(tr),
+			Invocation:  inv,
 			BuildConfig: buildConfig(tr),
 			Metadata:    metadata(tr),
-			Materials:   materials(tr),
+			Materials:   append(materials(tr), definitionMaterials(defs)...),
 		},
 	}
 	return att, nil
 }
 
 func metadata(tr *v1beta1.TaskRun) *slsa.ProvenanceMetadata {
-	m := &slsa.ProvenanceMetadata{}
+	m := &slsa.ProvenanceMetadata{BuildInvocationID: formats.InvocationID(tr)}
 	if tr.Status.StartTime != nil {
 		m.BuildStartedOn = &tr.Status.StartTime.Time
 	}
 	if tr.Status.CompletionTime != nil {
 		m.BuildFinishedOn = &tr.Status.CompletionTime.Time
 	}
-	for label, value := range tr.Labels {
-		if label == ChainsReproducibleAnnotation && value == "true" {
-			m.Reproducible = true
-		}
-	}
+	m.Reproducible = formats.Reproducible(tr)
 	return m
 }
 
 // invocation describes the event that kicked off the build
-// we currently don't set ConfigSource because we don't know
-// which material the Task definition came from
+// ConfigSource is set by the caller when the Task definition came from a bundle
 func invocation(tr *v1beta1.TaskRun) slsa.ProvenanceInvocation {
 	i := slsa.ProvenanceInvocation{}
-	// get parameters
-	var params []string
-	for _, p := range tr.Spec.Params {
-		params = append(params, fmt.Sprintf("%s=%v", p.Name, p.Value))
-	}
-	// add params
-	if ts := tr.Status.TaskSpec; ts != nil {
-		for _, p := range ts.Params {
-			if p.Default != nil {
-				v := p.Default.StringVal
-				if v == "" {
-					v = fmt.Sprintf("%v", p.Default.ArrayVal)
-				}
-				params = append(params, fmt.Sprintf("%s=%s", p.Name, v))
-			}
-		}
-	}
-	i.Parameters = params
+	// The parameters the build actually used, defaults included.
+	i.Parameters = formats.InvocationParameters(tr)
 	return i
 }
 
@@ -155,6 +195,20 @@ func GetSubjectDigests(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []intoto.
 		}
 	}
 
+	for _, p := range artifacts.ExtractPackagesFromResults(tr, logger) {
+		subjects = append(subjects, intoto.Subject{
+			Name:   p.URL,
+			Digest: slsa.DigestSet{"sha256": p.Digest},
+		})
+	}
+
+	for _, b := range artifacts.ExtractBlobsFromResults(tr, logger) {
+		subjects = append(subjects, intoto.Subject{
+			Name:   b.URI,
+			Digest: slsa.DigestSet{"sha256": b.Digest},
+		})
+	}
+
 	if tr.Spec.Resources == nil {
 		return subjects
 	}
@@ -245,6 +299,18 @@ func materials(tr *v1beta1.TaskRun) []slsa.ProvenanceMaterial {
 	return mats
 }
 
+// definitionMaterials records the bundles the Task and Pipeline definitions were read from
+func definitionMaterials(defs []definitions.Result) []slsa.ProvenanceMaterial {
+	var mats []slsa.ProvenanceMaterial
+	for _, d := range defs {
+		if d.Digest == "" {
+			continue
+		}
+		mats = append(mats, slsa.ProvenanceMaterial{URI: d.URI(), Digest: d.DigestSet()})
+	}
+	return mats
+}
+
 func (i *InTotoIte6) Type() formats.PayloadType {
 	return formats.PayloadTypeInTotoIte6
 }
@@ -22,6 +22,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tektoncd/chains/pkg/artifacts"
+	"github.com/tektoncd/chains/pkg/chains/definitions"
 	"github.com/tektoncd/chains/pkg/chains/formats"
 	"github.com/tektoncd/chains/pkg/config"
 
@@ -58,17 +60,36 @@ func TestCreatePayload1(t *testing.T) {
 		},
 		Predicate: slsa.ProvenancePredicate{
 			Metadata: &slsa.ProvenanceMetadata{
-				BuildStartedOn:  &e1BuildStart,
-				BuildFinishedOn: &e1BuildFinished,
+				BuildInvocationID: "2a5d0b10-3d3e-4f3b-8e4a-0c7f6a1e9d21",
+				BuildStartedOn:    &e1BuildStart,
+				BuildFinishedOn:   &e1BuildFinished,
 			},
 			Materials: []slsa.ProvenanceMaterial{
 				{URI: "https://git.test.com", Digest: slsa.DigestSet{"revision": "abcd"}},
 			},
 			Invocation: slsa.ProvenanceInvocation{
 				Parameters: []string{
-					"IMAGE={string test.io/test/image []}", "CHAINS-GIT_COMMIT={string abcd []}",
-					"CHAINS-GIT_URL={string https://git.test.com []}",
-					"filename={string /bin/ls []}",
+					"IMAGE=test.io/test/image",
+					"filename=/bin/ls",
+					"CHAINS-GIT_COMMIT=abcd",
+					"CHAINS-GIT_URL=https://git.test.com",
+				},
+				Environment: map[string]interface{}{
+					"taskRun": &formats.TaskRunEnvironment{
+						UID:      "2a5d0b10-3d3e-4f3b-8e4a-0c7f6a1e9d21",
+						PodName:  "test-pod-name",
+						NodeName: "test-node-name",
+					},
+					"builder": &formats.Builder{
+						ChainsVersion:    "v0.6.0",
+						PipelinesVersion: "v0.27.3",
+						FeatureFlags:     map[string]string{"enable-api-fields": "stable"},
+					},
+					"isolation": &formats.Isolation{
+						Claimed:    true,
+						Verified:   true,
+						Violations: []string{"the pod uses the host network"},
+					},
 				},
 			},
 			Builder: slsa.ProvenanceBuilder{
@@ -134,6 +155,9 @@ func TestCreatePayload2(t *testing.T) {
 			},
 			Invocation: slsa.ProvenanceInvocation{
 				Parameters: []string(nil),
+				Environment: map[string]interface{}{
+					"taskRun": &formats.TaskRunEnvironment{PodName: "test-pod-name"},
+				},
 			},
 			BuildType: "https://tekton.dev/attestations/chains@v2",
 			BuildConfig: BuildConfig{
@@ -181,6 +205,139 @@ func TestCreatePayloadNilTaskRef(t *testing.T) {
 	}
 }
 
+func TestCreatePayloadDefinitions(t *testing.T) {
+	verified := `[{"kind":"Task","name":"build","bundle":"gcr.io/foo/catalog:v1","verified":true}]`
+	tests := []struct {
+		name       string
+		verify     bool
+		annotation *string
+		want       interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "verified",
+			verify:     true,
+			annotation: &verified,
+			want: []definitions.Result{
+				{Kind: "Task", Name: "build", Bundle: "gcr.io/foo/catalog:v1", Verified: true},
+			},
+		},
+		{
+			name:   "not verified by chains",
+			verify: true,
+		},
+		{
+			name:       "verification disabled",
+			annotation: &verified,
+		},
+		{
+			name:       "invalid annotation",
+			verify:     true,
+			annotation: new(string),
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := taskrunFromFile(t, "testdata/taskrun1.json")
+			if tt.annotation != nil {
+				tr.Annotations = map[string]string{definitions.Annotation: *tt.annotation}
+			}
+			cfg := config.Config{
+				Definitions: config.DefinitionsConfig{Verify: tt.verify, Key: "cosign.pub"},
+			}
+			f, _ := NewFormatter(cfg, logtesting.TestLogger(t))
+			p, err := f.CreatePayload(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreatePayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			env := p.(in_toto.ProvenanceStatement).Predicate.Invocation.Environment
+			got := env.(map[string]interface{})["definitions"]
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("invocation environment definitions: -want +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestCreatePayloadDefinitionMaterials(t *testing.T) {
+	tr := taskrunFromFile(t, "testdata/taskrun1.json")
+	tr.Annotations = map[string]string{definitions.Annotation: `[
+		{"kind": "Task", "name": "build", "resolver": "bundles", "bundle": "gcr.io/foo/tasks:v1", "digest": "sha256:abcd"},
+		{"kind": "Pipeline", "name": "release", "resolver": "bundles", "bundle": "gcr.io/foo/pipelines:v1", "error": "MANIFEST_UNKNOWN"}
+	]`}
+	f, _ := NewFormatter(config.Config{}, logtesting.TestLogger(t))
+	p, err := f.CreatePayload(tr)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ps := p.(in_toto.ProvenanceStatement)
+
+	wantMaterials := []slsa.ProvenanceMaterial{
+		{URI: "https://git.test.com", Digest: slsa.DigestSet{"revision": "abcd"}},
+		{URI: "oci://gcr.io/foo/tasks:v1", Digest: slsa.DigestSet{"sha256": "abcd"}},
+	}
+	if diff := cmp.Diff(wantMaterials, ps.Predicate.Materials); diff != "" {
+		t.Errorf("materials: -want +got: %s", diff)
+	}
+	wantSource := slsa.ConfigSource{URI: "oci://gcr.io/foo/tasks:v1", Digest: slsa.DigestSet{"sha256": "abcd"}, EntryPoint: "build"}
+	if diff := cmp.Diff(wantSource, ps.Predicate.Invocation.ConfigSource); diff != "" {
+		t.Errorf("config source: -want +got: %s", diff)
+	}
+	if defs, ok := ps.Predicate.Invocation.Environment.(map[string]interface{})["definitions"]; ok {
+		t.Errorf("expected no verification results without definitions.verify.enabled, got %v", defs)
+	}
+}
+
+func TestCreatePayloadRebuild(t *testing.T) {
+	tr := taskrunFromFile(t, "testdata/taskrun2.json")
+	tr.Annotations = map[string]string{ChainsReproducibleAnnotation: "true"}
+	f, _ := NewFormatter(config.Config{}, logtesting.TestLogger(t))
+	p, err := f.CreatePayload(tr)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	predicate := p.(in_toto.ProvenanceStatement).Predicate
+	if !predicate.Metadata.Reproducible {
+		t.Error("expected the build to be reproducible")
+	}
+	rebuild, ok := predicate.Invocation.Environment.(map[string]interface{})["rebuild"].(*formats.Rebuild)
+	if !ok {
+		t.Fatalf("expected rebuild instructions, got %v", predicate.Invocation.Environment)
+	}
+	if len(rebuild.Images) != 1 || rebuild.Images[0].Step != "step1" {
+		t.Errorf("expected the image of step1, got %v", rebuild.Images)
+	}
+}
+
+func TestCreatePayloadContext(t *testing.T) {
+	tr := taskrunFromFile(t, "testdata/taskrun2.json")
+	tr.Labels = map[string]string{"team": "payments", "tekton.dev/task": "build"}
+	tr.Annotations = map[string]string{"example.com/change-ticket": "CHG-1234"}
+	cfg := config.Config{
+		Provenance: config.ProvenanceConfig{
+			Labels:      []string{"team", "cost-center"},
+			Annotations: []string{"example.com/change-ticket"},
+		},
+	}
+	f, _ := NewFormatter(cfg, logtesting.TestLogger(t))
+	p, err := f.CreatePayload(tr)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	env := p.(in_toto.ProvenanceStatement).Predicate.Invocation.Environment.(map[string]interface{})
+	want := &formats.Context{
+		Labels:      map[string]string{"team": "payments"},
+		Annotations: map[string]string{"example.com/change-ticket": "CHG-1234"},
+	}
+	if diff := cmp.Diff(want, env["context"]); diff != "" {
+		t.Errorf("context: -want +got: %s", diff)
+	}
+}
+
 func TestMultipleSubjects(t *testing.T) {
 	tr := taskrunFromFile(t, "testdata/taskrun-multiple-subjects.json")
 	cfg := config.Config{
@@ -214,6 +371,9 @@ func TestMultipleSubjects(t *testing.T) {
 			},
 			Invocation: slsa.ProvenanceInvocation{
 				Parameters: []string(nil),
+				Environment: map[string]interface{}{
+					"taskRun": &formats.TaskRunEnvironment{PodName: "test-pod-name"},
+				},
 			},
 			BuildConfig: BuildConfig{
 				Steps: []Step{
@@ -239,6 +399,107 @@ func TestMultipleSubjects(t *testing.T) {
 	}
 }
 
+func TestPackageSubjects(t *testing.T) {
+	sha := "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+	tr := &v1beta1.TaskRun{
+		Status: v1beta1.TaskRunStatus{
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				TaskRunResults: []v1beta1.TaskRunResult{
+					{Name: "MAVEN_ARTIFACT_URL", Value: "https://repo.example.com/maven2/com/example/lib/1.0/lib-1.0.jar"},
+					{Name: "MAVEN_ARTIFACT_DIGEST", Value: "sha256:" + sha},
+					{Name: "IMAGE_URL", Value: "gcr.io/foo/bar"},
+					{Name: "IMAGE_DIGEST", Value: "sha256:" + sha},
+					{Name: "BLOB_URL", Value: "https://example.com/releases/cli-1.0.tar.gz"},
+					{Name: "BLOB_DIGEST", Value: "sha256:" + sha},
+				},
+			},
+		},
+	}
+	want := []in_toto.Subject{
+		{Name: "gcr.io/foo/bar", Digest: slsa.DigestSet{"sha256": sha}},
+		{Name: "https://repo.example.com/maven2/com/example/lib/1.0/lib-1.0.jar", Digest: slsa.DigestSet{"sha256": sha}},
+		{Name: "https://example.com/releases/cli-1.0.tar.gz", Digest: slsa.DigestSet{"sha256": sha}},
+	}
+	if diff := cmp.Diff(want, GetSubjectDigests(tr, logtesting.TestLogger(t))); diff != "" {
+		t.Errorf("GetSubjectDigests(): -want +got: %s", diff)
+	}
+}
+
+func TestCreatePayloadBlob(t *testing.T) {
+	sha := "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+	tr := &v1beta1.TaskRun{
+		Status: v1beta1.TaskRunStatus{
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				TaskRunResults: []v1beta1.TaskRunResult{
+					{Name: "IMAGE_URL", Value: "gcr.io/foo/bar"},
+					{Name: "IMAGE_DIGEST", Value: "sha256:" + sha},
+					{Name: "BLOB_URL", Value: "/workspace/output/cli"},
+					{Name: "BLOB_DIGEST", Value: sha},
+				},
+			},
+		},
+	}
+	f, err := NewFormatter(config.Config{Builder: config.BuilderConfig{ID: "testid"}}, logtesting.TestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.CreatePayload(&artifacts.Blob{URI: "/workspace/output/cli", Digest: sha, TaskRun: tr})
+	if err != nil {
+		t.Fatalf("CreatePayload() = %v", err)
+	}
+	statement := got.(in_toto.ProvenanceStatement)
+	// Only the blob is a subject of its own attestation.
+	want := []in_toto.Subject{{Name: "/workspace/output/cli", Digest: slsa.DigestSet{"sha256": sha}}}
+	if diff := cmp.Diff(want, statement.Subject); diff != "" {
+		t.Errorf("Subject: -want +got: %s", diff)
+	}
+	if statement.Predicate.Builder.ID != "testid" {
+		t.Errorf("Builder.ID = %s, want testid", statement.Predicate.Builder.ID)
+	}
+}
+
+func TestCreatePayloadRewritesSubjects(t *testing.T) {
+	sha := "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+	tr := &v1beta1.TaskRun{
+		Status: v1beta1.TaskRunStatus{
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				TaskRunResults: []v1beta1.TaskRunResult{
+					{Name: "IMAGE_URL", Value: "mirror.internal:5000/foo/bar"},
+					{Name: "IMAGE_DIGEST", Value: "sha256:" + sha},
+					{Name: "BAZ_IMAGE_URL", Value: "gcr.io/foo/baz"},
+					{Name: "BAZ_IMAGE_DIGEST", Value: "sha256:" + sha},
+				},
+			},
+		},
+	}
+	cfg := config.Config{
+		Builder: config.BuilderConfig{ID: "testid"},
+		Subjects: config.SubjectsConfig{Rewrites: []config.SubjectRewrite{
+			{Pattern: `^mirror\.internal:5000/(.*)$`, Replacement: "gcr.io/$1"},
+		}},
+	}
+	f, err := NewFormatter(cfg, logtesting.TestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.CreatePayload(tr)
+	if err != nil {
+		t.Fatalf("CreatePayload() = %v", err)
+	}
+	want := []in_toto.Subject{
+		{Name: "gcr.io/foo/bar", Digest: slsa.DigestSet{"sha256": sha}},
+		{Name: "gcr.io/foo/baz", Digest: slsa.DigestSet{"sha256": sha}},
+	}
+	if diff := cmp.Diff(want, got.(in_toto.ProvenanceStatement).Subject); diff != "" {
+		t.Errorf("Subject: -want +got: %s", diff)
+	}
+
+	cfg.Subjects.Rewrites = []config.SubjectRewrite{{Pattern: "(", Replacement: ""}}
+	if _, err := NewFormatter(cfg, logtesting.TestLogger(t)); err == nil {
+		t.Error("expected error creating formatter with an invalid rewrite")
+	}
+}
+
 func TestNewFormatter(t *testing.T) {
 	t.Run("Ok", func(t *testing.T) {
 		cfg := config.Config{
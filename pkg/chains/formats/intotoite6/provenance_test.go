@@ -43,6 +43,7 @@ func TestMetadata(t *testing.T) {
 		ObjectMeta: v1.ObjectMeta{
 			Name:      "my-taskrun",
 			Namespace: "my-namespace",
+			UID:       "my-uid",
 			Annotations: map[string]string{
 				"chains.tekton.dev/reproducible": "true",
 			},
@@ -57,8 +58,11 @@ func TestMetadata(t *testing.T) {
 	start := time.Date(1995, time.December, 24, 6, 12, 12, 12, time.UTC)
 	end := time.Date(1995, time.December, 24, 6, 12, 12, 24, time.UTC)
 	expected := &slsa.ProvenanceMetadata{
-		BuildStartedOn:  &start,
-		BuildFinishedOn: &end,
+		BuildInvocationID: "my-uid",
+		BuildStartedOn:    &start,
+		BuildFinishedOn:   &end,
+		// The annotation marks the build as reproducible.
+		Reproducible: true,
 	}
 	got := metadata(tr)
 	if !reflect.DeepEqual(expected, got) {
@@ -204,8 +208,8 @@ spec:
 
 	expected := slsa.ProvenanceInvocation{
 		Parameters: []string{
-			"my-param={string string-param []}",
-			"my-array-param={array  [my array]}",
+			"my-param=string-param",
+			"my-array-param=[my array]",
 		},
 	}
 
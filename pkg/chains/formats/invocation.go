@@ -0,0 +1,47 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formats
+
+import "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+
+// NodeNameAnnotation records the node the pod of a TaskRun ran on. Tekton Pipelines doesn't
+// record it on the TaskRun, so Chains looks it up before signing.
+const NodeNameAnnotation = "chains.tekton.dev/node-name"
+
+// InvocationID returns the ID of the run of a TaskRun recorded in provenance: its UID. It
+// doesn't change when the TaskRun is signed again, and is the UID Chains logs the TaskRun
+// with and Tekton Results names its record after, so provenance can be correlated with them.
+func InvocationID(tr *v1beta1.TaskRun) string {
+	return string(tr.UID)
+}
+
+// TaskRunEnvironment describes where a TaskRun ran, as recorded in provenance.
+type TaskRunEnvironment struct {
+	UID      string `json:"uid,omitempty"`
+	PodName  string `json:"podName,omitempty"`
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// InvocationEnvironment returns where the TaskRun ran, or nil if nothing is known about it.
+func InvocationEnvironment(tr *v1beta1.TaskRun) *TaskRunEnvironment {
+	env := TaskRunEnvironment{
+		UID:      string(tr.UID),
+		PodName:  tr.Status.PodName,
+		NodeName: tr.Annotations[NodeNameAnnotation],
+	}
+	if env == (TaskRunEnvironment{}) {
+		return nil
+	}
+	return &env
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formats
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+const (
+	// HermeticAnnotation is set to "true" by Tasks, Pipelines and TaskRuns that claim to run
+	// hermetically, without network access or access to the host.
+	HermeticAnnotation = "chains.tekton.dev/hermetic"
+	// HermeticResult is the result a TaskRun sets to "true" to claim it ran hermetically.
+	HermeticResult = "CHAINS-HERMETIC"
+	// IsolationAnnotation records whether Chains found the claim of a TaskRun to run
+	// hermetically to hold, as JSON.
+	IsolationAnnotation = "chains.tekton.dev/isolation"
+)
+
+// Isolation describes how isolated a TaskRun ran.
+type Isolation struct {
+	// Hermetic is set when the TaskRun claimed to run hermetically, and Chains verified the
+	// claim against its pod without finding any violation.
+	Hermetic bool `json:"hermetic"`
+	// Claimed is set when the TaskRun claimed to run hermetically.
+	Claimed bool `json:"claimed"`
+	// Verified is set when Chains could check the pod of the TaskRun.
+	Verified bool `json:"verified"`
+	// Violations are what Chains found that contradicts the claim.
+	Violations []string `json:"violations,omitempty"`
+}
+
+// HermeticClaimed returns whether the TaskRun claims to have run hermetically, with the
+// HermeticAnnotation, which Tekton Pipelines copies from its Task and Pipeline, or the
+// HermeticResult.
+func HermeticClaimed(tr *v1beta1.TaskRun) bool {
+	if strings.TrimSpace(tr.Annotations[HermeticAnnotation]) == "true" {
+		return true
+	}
+	for _, r := range tr.Status.TaskRunResults {
+		if r.Name == HermeticResult && strings.TrimSpace(r.Value) == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsolationFromTaskRun decodes the isolation Chains recorded on the TaskRun, or returns nil if
+// there's none.
+func IsolationFromTaskRun(tr *v1beta1.TaskRun) (*Isolation, error) {
+	value, ok := tr.Annotations[IsolationAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	i := &Isolation{}
+	if err := json.Unmarshal([]byte(value), i); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s annotation", IsolationAnnotation)
+	}
+	return i, nil
+}
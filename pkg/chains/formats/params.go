@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formats
+
+import (
+	"fmt"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"github.com/tektoncd/pipeline/pkg/substitution"
+)
+
+// ResolvedParams returns the parameters a TaskRun ran with: the values its spec sets, and the
+// defaults of the parameters of its Task it doesn't set, with the context variables of the
+// TaskRun, such as $(context.taskRun.name), replaced. They are in the order the Task declares
+// them, followed by those of the spec it doesn't declare.
+func ResolvedParams(tr *v1beta1.TaskRun) []v1beta1.Param {
+	values := map[string]v1beta1.ArrayOrString{}
+	for _, p := range tr.Spec.Params {
+		values[p.Name] = p.Value
+	}
+
+	var params []v1beta1.Param
+	declared := map[string]bool{}
+	if ts := tr.Status.TaskSpec; ts != nil {
+		for _, p := range ts.Params {
+			declared[p.Name] = true
+			if v, ok := values[p.Name]; ok {
+				params = append(params, v1beta1.Param{Name: p.Name, Value: v})
+			} else if p.Default != nil {
+				params = append(params, v1beta1.Param{Name: p.Name, Value: *p.Default})
+			}
+		}
+	}
+	for _, p := range tr.Spec.Params {
+		if !declared[p.Name] {
+			params = append(params, p)
+		}
+	}
+
+	replacements := contextReplacements(tr)
+	for i := range params {
+		// The array is copied before its values are replaced, so the TaskRun isn't changed.
+		v := &params[i].Value
+		v.StringVal = substitution.ApplyReplacements(v.StringVal, replacements)
+		if v.ArrayVal != nil {
+			array := make([]string, len(v.ArrayVal))
+			for j, a := range v.ArrayVal {
+				array[j] = substitution.ApplyReplacements(a, replacements)
+			}
+			v.ArrayVal = array
+		}
+	}
+	return params
+}
+
+// InvocationParameters returns the resolved parameters of a TaskRun as name=value, the way
+// they are recorded in the invocation of provenance. Arrays are written as [a b].
+func InvocationParameters(tr *v1beta1.TaskRun) []string {
+	var params []string
+	for _, p := range ResolvedParams(tr) {
+		v := p.Value.StringVal
+		if p.Value.Type == v1beta1.ParamTypeArray {
+			v = fmt.Sprintf("%v", p.Value.ArrayVal)
+		}
+		params = append(params, fmt.Sprintf("%s=%s", p.Name, v))
+	}
+	return params
+}
+
+// contextReplacements are the values of the context variables Tekton replaces in the
+// parameters of a TaskRun.
+func contextReplacements(tr *v1beta1.TaskRun) map[string]string {
+	taskName := tr.Name
+	if tr.Spec.TaskRef != nil {
+		taskName = tr.Spec.TaskRef.Name
+	}
+	return map[string]string{
+		"context.taskRun.name":      tr.Name,
+		"context.taskRun.namespace": tr.Namespace,
+		"context.taskRun.uid":       string(tr.UID),
+		"context.task.name":         taskName,
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formats
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+func TestInvocationParameters(t *testing.T) {
+	taskrun := `apiVersion: tekton.dev/v1beta1
+kind: TaskRun
+metadata:
+  name: build-1
+  namespace: team-a
+  uid: my-uid
+spec:
+  taskRef:
+    name: build
+  params:
+  - name: IMAGE
+    value: gcr.io/team-a/$(context.taskRun.name)
+  - name: extra
+    value: not-declared
+status:
+  taskSpec:
+    params:
+    - name: CONTEXT
+      default: .
+    - name: IMAGE
+      default: gcr.io/default
+    - name: TAGS
+      type: array
+      default:
+      - latest
+      - $(context.task.name)
+    - name: NO-DEFAULT`
+
+	var tr *v1beta1.TaskRun
+	if err := yaml.Unmarshal([]byte(taskrun), &tr); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CONTEXT=.",
+		"IMAGE=gcr.io/team-a/build-1",
+		"TAGS=[latest build]",
+		"extra=not-declared",
+	}
+	if d := cmp.Diff(want, InvocationParameters(tr)); d != "" {
+		t.Errorf("InvocationParameters() diff (-want +got):\n%s", d)
+	}
+	// The TaskRun is left as it was.
+	if got := tr.Status.TaskSpec.Params[2].Default.ArrayVal[1]; got != "$(context.task.name)" {
+		t.Errorf("expected the TaskRun not to be changed, got %s", got)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
 	"github.com/pkg/errors"
 	"github.com/tektoncd/chains/pkg/artifacts"
+	"github.com/tektoncd/chains/pkg/chains/definitions"
 	"github.com/tektoncd/chains/pkg/chains/formats"
 	"github.com/tektoncd/chains/pkg/chains/provenance"
 	"github.com/tektoncd/chains/pkg/config"
@@ -38,14 +39,17 @@ import (
 const (
 	commitParam                  = "CHAINS-GIT_COMMIT"
 	urlParam                     = "CHAINS-GIT_URL"
-	ChainsReproducibleAnnotation = "chains.tekton.dev/reproducible"
+	ChainsReproducibleAnnotation = formats.ReproducibleAnnotation
 	PredicateType                = "https://tekton.dev/chains/provenance"
 	statementType                = "https://in-toto.io/Statement/v0.1"
 )
 
 type Provenance struct {
 	builderID string
-	logger    *zap.SugaredLogger
+	rewriter  *artifacts.SubjectRewriter
+	// context are the labels and annotations of TaskRuns recorded in the metadata.
+	context config.ProvenanceConfig
+	logger  *zap.SugaredLogger
 }
 
 func NewFormatter(cfg config.Config, logger *zap.SugaredLogger) (formats.Payloader, error) {
@@ -55,8 +59,14 @@ func NewFormatter(cfg config.Config, logger *zap.SugaredLogger) (formats.Payload
 	
 	kubectl patch configmap chains-config -n tekton-chains -p='{"data":{"artifacts.taskrun.format": "in-toto"}}'	
 	`
+	rewriter, err := artifacts.NewSubjectRewriter(cfg.Subjects.Rewrites)
+	if err != nil {
+		return nil, err
+	}
 	return &Provenance{
 		builderID: cfg.Builder.ID,
+		rewriter:  rewriter,
+		context:   cfg.Provenance,
 		logger:    logger,
 	}, errors.New(errorMsg)
 }
@@ -74,6 +84,7 @@ func (i *Provenance) CreatePayload(obj interface{}) (interface{}, error) {
 		return nil, fmt.Errorf("intoto does not support type: %s", v)
 	}
 	subjects := GetSubjectDigests(tr, i.logger)
+	subjects = i.rewriter.RewriteSubjects(subjects)
 	att, err := i.generateProvenanceFromSubject(tr, subjects)
 	if err != nil {
 		return nil, errors.Wrapf(err, "generating provenance for subject %s", subjects)
@@ -82,6 +93,18 @@ func (i *Provenance) CreatePayload(obj interface{}) (interface{}, error) {
 }
 
 func (i *Provenance) generateProvenanceFromSubject(tr *v1beta1.TaskRun, subjects []in_toto.Subject) (interface{}, error) {
+	defs, err := definitions.FromTaskRun(tr)
+	if err != nil {
+		return nil, err
+	}
+	builder, err := formats.BuilderFromTaskRun(tr)
+	if err != nil {
+		return nil, err
+	}
+	isolation, err := formats.IsolationFromTaskRun(tr)
+	if err != nil {
+		return nil, err
+	}
 	att := in_toto.Statement{
 		StatementHeader: in_toto.StatementHeader{
 			Type:          statementType,
@@ -90,10 +113,25 @@ func (i *Provenance) generateProvenanceFromSubject(tr *v1beta1.TaskRun, subjects
 		},
 	}
 
+	inv := invocation(i.builderID, tr)
+	if builder != nil {
+		inv.Builder = builder
+	}
+	if rebuild := formats.RebuildInstructions(tr, defs); rebuild != nil {
+		inv.Rebuild = rebuild
+	}
+	m := metadata(tr)
+	if isolation != nil {
+		m.Hermetic = isolation.Hermetic
+		m.Isolation = isolation
+	}
+	if context := formats.ContextFromTaskRun(tr, i.context); context != nil {
+		m.Context = context
+	}
 	pred := provenance.ProvenancePredicate{
-		Metadata:   metadata(tr),
-		Invocation: invocation(i.builderID, tr),
-		Materials:  materials(tr),
+		Metadata:   m,
+		Invocation: inv,
+		Materials:  append(materials(tr), definitionMaterials(defs)...),
 		Recipe:     provenance.ProvenanceRecipe{Steps: Steps(tr)},
 	}
 
@@ -102,7 +140,7 @@ func (i *Provenance) generateProvenanceFromSubject(tr *v1beta1.TaskRun, subjects
 }
 
 func metadata(tr *v1beta1.TaskRun) provenance.ProvenanceMetadata {
-	m := provenance.ProvenanceMetadata{}
+	m := provenance.ProvenanceMetadata{BuildInvocationID: formats.InvocationID(tr)}
 
 	if tr.Status.StartTime != nil {
 		m.BuildStartedOn = &tr.Status.StartTime.Time
@@ -110,11 +148,7 @@ func metadata(tr *v1beta1.TaskRun) provenance.ProvenanceMetadata {
 	if tr.Status.CompletionTime != nil {
 		m.BuildFinishedOn = &tr.Status.CompletionTime.Time
 	}
-	for label, value := range tr.Labels {
-		if label == ChainsReproducibleAnnotation && value == "true" {
-			m.Reproducible = true
-		}
-	}
+	m.Reproducible = formats.Reproducible(tr)
 	return m
 }
 
@@ -171,35 +205,33 @@ func materials(tr *v1beta1.TaskRun) []provenance.ProvenanceMaterial {
 	return mats
 }
 
+// definitionMaterials records the bundles the Task and Pipeline definitions were read from
+func definitionMaterials(defs []definitions.Result) []provenance.ProvenanceMaterial {
+	var mats []provenance.ProvenanceMaterial
+	for _, d := range defs {
+		if d.Digest == "" {
+			continue
+		}
+		mats = append(mats, provenance.ProvenanceMaterial{URI: d.URI(), Digest: d.DigestSet()})
+	}
+	return mats
+}
+
 func invocation(builderID string, tr *v1beta1.TaskRun) provenance.Invocation {
 	// take care of the eventID
 	invocation := provenance.Invocation{
 		EventID: string(tr.UID),
 	}
 
-	// get parameters
-	var params []string
-	for _, p := range tr.Spec.Params {
-		params = append(params, fmt.Sprintf("%s=%v", p.Name, p.Value))
-	}
-	// add params
-	if ts := tr.Status.TaskSpec; ts != nil {
-		for _, p := range ts.Params {
-			if p.Default != nil {
-				v := p.Default.StringVal
-				if v == "" {
-					v = fmt.Sprintf("%v", p.Default.ArrayVal)
-				}
-				params = append(params, fmt.Sprintf("%s=%s", p.Name, v))
-			}
-		}
-	}
-
-	invocation.Parameters = params
+	// The parameters the build actually used, defaults included.
+	invocation.Parameters = formats.InvocationParameters(tr)
 
 	// get URI
 	invocation.RecipeURI = recipeURI(tr)
 	invocation.ID = builderID
+	// Where the TaskRun ran, to correlate with the logs of its pod.
+	invocation.PodName = tr.Status.PodName
+	invocation.NodeName = tr.Annotations[formats.NodeNameAnnotation]
 	return invocation
 }
 
@@ -340,6 +372,20 @@ func GetSubjectDigests(tr *v1beta1.TaskRun, logger *zap.SugaredLogger) []in_toto
 		}
 	}
 
+	for _, p := range artifacts.ExtractPackagesFromResults(tr, logger) {
+		subjects = append(subjects, in_toto.Subject{
+			Name:   p.URL,
+			Digest: slsa.DigestSet{"sha256": p.Digest},
+		})
+	}
+
+	for _, b := range artifacts.ExtractBlobsFromResults(tr, logger) {
+		subjects = append(subjects, in_toto.Subject{
+			Name:   b.URI,
+			Digest: slsa.DigestSet{"sha256": b.Digest},
+		})
+	}
+
 	if tr.Spec.Resources == nil {
 		return subjects
 	}
@@ -27,7 +27,9 @@ import (
 	"github.com/ghodss/yaml"
 	"github.com/google/go-cmp/cmp"
 	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/tektoncd/chains/pkg/chains/formats"
 	"github.com/tektoncd/chains/pkg/chains/provenance"
+	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"github.com/tektoncd/pipeline/pkg/apis/resource/v1alpha1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,6 +46,7 @@ func TestMetadata(t *testing.T) {
 		ObjectMeta: v1.ObjectMeta{
 			Name:      "my-taskrun",
 			Namespace: "my-namespace",
+			UID:       "my-uid",
 			Annotations: map[string]string{
 				"chains.tekton.dev/reproducible": "true",
 			},
@@ -58,8 +61,11 @@ func TestMetadata(t *testing.T) {
 	start := time.Date(1995, time.December, 24, 6, 12, 12, 12, time.UTC)
 	end := time.Date(1995, time.December, 24, 6, 12, 12, 24, time.UTC)
 	expected := provenance.ProvenanceMetadata{
-		BuildStartedOn:  &start,
-		BuildFinishedOn: &end,
+		BuildInvocationID: "my-uid",
+		BuildStartedOn:    &start,
+		BuildFinishedOn:   &end,
+		// The annotation marks the build as reproducible.
+		Reproducible: true,
 	}
 	got := metadata(tr)
 	if !reflect.DeepEqual(expected, got) {
@@ -104,6 +110,131 @@ status:
 	}
 }
 
+func TestDefinitionMaterials(t *testing.T) {
+	taskrun := `apiVersion: tekton.dev/v1beta1
+kind: TaskRun
+metadata:
+  annotations:
+    chains.tekton.dev/definitions: '[{"kind":"Task","name":"build","resolver":"bundles","bundle":"gcr.io/foo/tasks@sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5","digest":"sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"}]'
+spec:
+  taskRef:
+    name: build
+    bundle: gcr.io/foo/tasks@sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5`
+
+	var taskRun *v1beta1.TaskRun
+	if err := yaml.Unmarshal([]byte(taskrun), &taskRun); err != nil {
+		t.Fatal(err)
+	}
+	got, err := (&Provenance{}).generateProvenanceFromSubject(taskRun, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []provenance.ProvenanceMaterial{
+		{
+			URI:    "oci://gcr.io/foo/tasks",
+			Digest: provenance.DigestSet{"sha256": "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"},
+		},
+	}
+	if diff := cmp.Diff(expected, got.(in_toto.Statement).Predicate.(provenance.ProvenancePredicate).Materials); diff != "" {
+		t.Errorf("materials: -want +got: %s", diff)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	taskrun := `apiVersion: tekton.dev/v1beta1
+kind: TaskRun
+metadata:
+  annotations:
+    chains.tekton.dev/builder: '{"chainsVersion":"v0.6.0","pipelinesVersion":"v0.27.3","featureFlags":{"enable-api-fields":"alpha"}}'`
+
+	var taskRun *v1beta1.TaskRun
+	if err := yaml.Unmarshal([]byte(taskrun), &taskRun); err != nil {
+		t.Fatal(err)
+	}
+	got, err := (&Provenance{}).generateProvenanceFromSubject(taskRun, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &formats.Builder{
+		ChainsVersion:    "v0.6.0",
+		PipelinesVersion: "v0.27.3",
+		FeatureFlags:     map[string]string{"enable-api-fields": "alpha"},
+	}
+	if diff := cmp.Diff(expected, got.(in_toto.Statement).Predicate.(provenance.ProvenancePredicate).Invocation.Builder); diff != "" {
+		t.Errorf("builder: -want +got: %s", diff)
+	}
+
+	taskRun.Annotations[formats.BuilderAnnotation] = "nope"
+	if _, err := (&Provenance{}).generateProvenanceFromSubject(taskRun, nil); err == nil {
+		t.Error("expected an error for an invalid builder annotation")
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	taskrun := `apiVersion: tekton.dev/v1beta1
+kind: TaskRun
+metadata:
+  annotations:
+    chains.tekton.dev/isolation: '{"hermetic":true,"claimed":true,"verified":true}'`
+
+	var taskRun *v1beta1.TaskRun
+	if err := yaml.Unmarshal([]byte(taskrun), &taskRun); err != nil {
+		t.Fatal(err)
+	}
+	got, err := (&Provenance{}).generateProvenanceFromSubject(taskRun, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := got.(in_toto.Statement).Predicate.(provenance.ProvenancePredicate).Metadata
+	if !m.Hermetic {
+		t.Error("expected the build to be hermetic")
+	}
+	if diff := cmp.Diff(&formats.Isolation{Hermetic: true, Claimed: true, Verified: true}, m.Isolation); diff != "" {
+		t.Errorf("isolation: -want +got: %s", diff)
+	}
+}
+
+func TestContext(t *testing.T) {
+	taskrun := `apiVersion: tekton.dev/v1beta1
+kind: TaskRun
+metadata:
+  labels:
+    team: payments
+    tekton.dev/task: build
+  annotations:
+    example.com/change-ticket: CHG-1234`
+
+	var taskRun *v1beta1.TaskRun
+	if err := yaml.Unmarshal([]byte(taskrun), &taskRun); err != nil {
+		t.Fatal(err)
+	}
+	p := &Provenance{context: config.ProvenanceConfig{
+		Labels:      []string{"team", "cost-center"},
+		Annotations: []string{"example.com/change-ticket"},
+	}}
+	got, err := p.generateProvenanceFromSubject(taskRun, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &formats.Context{
+		Labels:      map[string]string{"team": "payments"},
+		Annotations: map[string]string{"example.com/change-ticket": "CHG-1234"},
+	}
+	if diff := cmp.Diff(expected, got.(in_toto.Statement).Predicate.(provenance.ProvenancePredicate).Metadata.Context); diff != "" {
+		t.Errorf("context: -want +got: %s", diff)
+	}
+
+	// Nothing is recorded unless it's configured.
+	got, err = (&Provenance{}).generateProvenanceFromSubject(taskRun, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := got.(in_toto.Statement).Predicate.(provenance.ProvenancePredicate).Metadata.Context; c != nil {
+		t.Errorf("expected no context, got %v", c)
+	}
+}
+
 func TestMaterials(t *testing.T) {
 	// make sure this works with Git resources
 	taskrun := `apiVersion: tekton.dev/v1beta1
@@ -254,6 +385,8 @@ func TestInvocation(t *testing.T) {
 kind: TaskRun
 metadata:
   uid: my-uid
+  annotations:
+    chains.tekton.dev/node-name: my-node
 spec:
   params:
   - name: my-param
@@ -261,7 +394,9 @@ spec:
   - name: my-array-param
     value:
     - "my"
-    - "array"`
+    - "array"
+status:
+  podName: my-pod`
 
 	var taskRun *v1beta1.TaskRun
 	if err := yaml.Unmarshal([]byte(taskrun), &taskRun); err != nil {
@@ -271,10 +406,12 @@ spec:
 	expected := provenance.Invocation{
 		EventID: "my-uid",
 		Parameters: []string{
-			"my-param={string string-param []}",
-			"my-array-param={array  [my array]}",
+			"my-param=string-param",
+			"my-array-param=[my array]",
 		},
-		ID: "tekton-chains",
+		ID:       "tekton-chains",
+		PodName:  "my-pod",
+		NodeName: "my-node",
 	}
 
 	got := invocation("tekton-chains", taskRun)
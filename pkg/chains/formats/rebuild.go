@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formats
+
+import (
+	"strings"
+
+	"github.com/tektoncd/chains/pkg/chains/definitions"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+// ReproducibleAnnotation is set to "true" by Tasks, Pipelines and TaskRuns whose builds are
+// reproducible. Earlier versions of Chains read it as a label, which is still supported.
+const ReproducibleAnnotation = "chains.tekton.dev/reproducible"
+
+// Reproducible returns whether the TaskRun is marked as reproducible.
+func Reproducible(tr *v1beta1.TaskRun) bool {
+	return tr.Annotations[ReproducibleAnnotation] == "true" || tr.Labels[ReproducibleAnnotation] == "true"
+}
+
+// Rebuild are the instructions an independent rebuilder follows to run a reproducible
+// TaskRun again, and compare what it builds with its subjects.
+type Rebuild struct {
+	Task   RebuildTask     `json:"task"`
+	Params []v1beta1.Param `json:"params,omitempty"`
+	Images []RebuildImage  `json:"images,omitempty"`
+}
+
+// RebuildTask is the Task the TaskRun ran.
+type RebuildTask struct {
+	// Name is the name of the Task the TaskRun refers to, if any.
+	Name string `json:"name,omitempty"`
+	// Bundle is the bundle the Task was read from, and BundleDigest the digest it resolved to.
+	Bundle       string `json:"bundle,omitempty"`
+	BundleDigest string `json:"bundleDigest,omitempty"`
+	// SpecDigest is the digest of the YAML of the spec of the Task the TaskRun ran, which
+	// also identifies Tasks that weren't read from a bundle.
+	SpecDigest string `json:"specDigest,omitempty"`
+}
+
+// RebuildImage is the image a step ran.
+type RebuildImage struct {
+	Step string `json:"step"`
+	// Image is the image as the Task sets it, and Digest the digest it ran.
+	Image  string `json:"image,omitempty"`
+	Digest string `json:"digest"`
+}
+
+// RebuildInstructions returns the instructions to rebuild the TaskRun, or nil if it isn't
+// marked as reproducible. defs are the definitions resolved for it.
+func RebuildInstructions(tr *v1beta1.TaskRun, defs []definitions.Result) *Rebuild {
+	if !Reproducible(tr) {
+		return nil
+	}
+	r := &Rebuild{Params: ResolvedParams(tr)}
+	if tr.Spec.TaskRef != nil {
+		r.Task.Name = tr.Spec.TaskRef.Name
+	}
+	for _, d := range defs {
+		if d.Kind == definitions.KindTask && d.Resolver != definitions.ResolverCluster && d.Digest != "" {
+			r.Task.Bundle = d.Bundle
+			r.Task.BundleDigest = d.Digest
+		}
+	}
+	if ts := tr.Status.TaskSpec; ts != nil {
+		r.Task.SpecDigest, _ = definitions.SpecDigest(ts)
+	}
+
+	images := map[string]string{}
+	if ts := tr.Status.TaskSpec; ts != nil {
+		for _, s := range ts.Steps {
+			images[s.Name] = s.Image
+		}
+	}
+	for _, s := range tr.Status.Steps {
+		if s.ImageID == "" {
+			continue
+		}
+		r.Images = append(r.Images, RebuildImage{
+			Step:   s.Name,
+			Image:  images[s.Name],
+			Digest: strings.TrimPrefix(s.ImageID, "docker-pullable://"),
+		})
+	}
+	return r
+}
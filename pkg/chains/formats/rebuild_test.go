@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formats
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tektoncd/chains/pkg/chains/definitions"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+func TestRebuildInstructions(t *testing.T) {
+	taskrun := `apiVersion: tekton.dev/v1beta1
+kind: TaskRun
+metadata:
+  name: build-1
+  annotations:
+    chains.tekton.dev/reproducible: "true"
+spec:
+  taskRef:
+    name: build
+    bundle: gcr.io/foo/tasks:v1
+  params:
+  - name: IMAGE
+    value: gcr.io/foo/app
+status:
+  taskSpec:
+    params:
+    - name: IMAGE
+    steps:
+    - name: compile
+      image: golang:1.17
+    - name: push
+      image: gcr.io/go-containerregistry/crane
+  steps:
+  - name: compile
+    imageID: docker-pullable://golang@sha256:d4b63d3e24d6eef04a6dc0795cf8a73470688803d97c52cffa3c8d4efd3397b6
+  - name: push
+    imageID: docker-pullable://gcr.io/go-containerregistry/crane@sha256:4d6dd704ef58cb214dd826519929e92a978a57cdee43693006139c0080fd6fac
+  - name: skipped`
+
+	var tr *v1beta1.TaskRun
+	if err := yaml.Unmarshal([]byte(taskrun), &tr); err != nil {
+		t.Fatal(err)
+	}
+	defs := []definitions.Result{
+		{Kind: definitions.KindTask, Name: "build", Bundle: "gcr.io/foo/tasks:v1", Digest: "sha256:abcd"},
+	}
+	want := &Rebuild{
+		Task: RebuildTask{Name: "build", Bundle: "gcr.io/foo/tasks:v1", BundleDigest: "sha256:abcd"},
+		Params: []v1beta1.Param{
+			{Name: "IMAGE", Value: *v1beta1.NewArrayOrString("gcr.io/foo/app")},
+		},
+		Images: []RebuildImage{
+			{Step: "compile", Image: "golang:1.17", Digest: "golang@sha256:d4b63d3e24d6eef04a6dc0795cf8a73470688803d97c52cffa3c8d4efd3397b6"},
+			{Step: "push", Image: "gcr.io/go-containerregistry/crane", Digest: "gcr.io/go-containerregistry/crane@sha256:4d6dd704ef58cb214dd826519929e92a978a57cdee43693006139c0080fd6fac"},
+		},
+	}
+	got := RebuildInstructions(tr, defs)
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(RebuildTask{}, "SpecDigest")); diff != "" {
+		t.Errorf("RebuildInstructions(): -want +got: %s", diff)
+	}
+	if !strings.HasPrefix(got.Task.SpecDigest, "sha256:") {
+		t.Errorf("expected the digest of the spec of the Task, got %q", got.Task.SpecDigest)
+	}
+
+	// The digest of the spec changes with the Task.
+	tr.Status.TaskSpec.Steps[0].Image = "golang:1.16"
+	if again := RebuildInstructions(tr, defs); again.Task.SpecDigest == got.Task.SpecDigest {
+		t.Error("expected the digest of the spec to change with the Task")
+	}
+
+	// TaskRuns that aren't reproducible have no instructions.
+	delete(tr.Annotations, ReproducibleAnnotation)
+	if got := RebuildInstructions(tr, defs); got != nil {
+		t.Errorf("expected no instructions, got %v", got)
+	}
+	// Earlier versions of Chains read a label.
+	tr.Labels = map[string]string{ReproducibleAnnotation: "true"}
+	if !Reproducible(tr) {
+		t.Error("expected the label to mark the TaskRun as reproducible")
+	}
+}
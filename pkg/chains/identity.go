@@ -0,0 +1,54 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// identityTokenExpiration is how long the tokens Fulcio certificates are requested with are
+// valid for, the shortest the TokenRequest API allows. They are only used once.
+var identityTokenExpiration int64 = 600
+
+// serviceAccountIdentity returns the tokens of the service account tr ran as, so the Fulcio
+// certificates its payloads are signed with are for the identity of the pipeline instead of
+// the controller's.
+func (ts *TaskRunSigner) serviceAccountIdentity(tr *v1beta1.TaskRun) x509.IdentityToken {
+	if ts.KubeClient == nil {
+		return nil
+	}
+	return func(ctx context.Context, audience string) (string, error) {
+		sa := tr.Spec.ServiceAccountName
+		if sa == "" {
+			sa = "default"
+		}
+		req := &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				Audiences:         []string{audience},
+				ExpirationSeconds: &identityTokenExpiration,
+			},
+		}
+		resp, err := ts.KubeClient.CoreV1().ServiceAccounts(tr.Namespace).CreateToken(ctx, sa, req, metav1.CreateOptions{})
+		if err != nil {
+			return "", errors.Wrapf(err, "requesting a token for service account %s/%s", tr.Namespace, sa)
+		}
+		return resp.Status.Token, nil
+	}
+}
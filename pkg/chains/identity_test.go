@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+func TestServiceAccountIdentity(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceAccount string
+		want           string
+	}{
+		{name: "service account", serviceAccount: "builder", want: "team-a/builder"},
+		{name: "default service account", want: "team-a/default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			kc := fakekubeclient.Get(ctx)
+			var got string
+			var audiences []string
+			kc.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				create := action.(k8stesting.CreateActionImpl)
+				req := create.GetObject().(*authenticationv1.TokenRequest)
+				got = create.GetNamespace() + "/" + create.Name
+				audiences = req.Spec.Audiences
+				return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: "token"}}, nil
+			})
+
+			ts := &TaskRunSigner{KubeClient: kc}
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "team-a"},
+				Spec:       v1beta1.TaskRunSpec{ServiceAccountName: tt.serviceAccount},
+			}
+			token, err := ts.serviceAccountIdentity(tr)(ctx, "fulcio.example.com")
+			if err != nil {
+				t.Fatalf("serviceAccountIdentity() error = %v", err)
+			}
+			if token != "token" || got != tt.want {
+				t.Errorf("got token %q for %s, want token for %s", token, got, tt.want)
+			}
+			if diff := cmp.Diff([]string{"fulcio.example.com"}, audiences); diff != "" {
+				t.Errorf("audiences mismatch (-want +got): %s", diff)
+			}
+		})
+	}
+
+	if (&TaskRunSigner{}).serviceAccountIdentity(&v1beta1.TaskRun{}) != nil {
+		t.Error("expected no identity without a Kubernetes client")
+	}
+}
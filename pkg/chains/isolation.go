@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/logging"
+)
+
+// recordIsolation checks the claim of the TaskRun to have run hermetically against pod, and
+// records the result in an annotation on tr for the formatters. The claim can't be verified
+// when pod is nil. Nothing is recorded for TaskRuns that make no claim.
+func (ts *TaskRunSigner) recordIsolation(ctx context.Context, tr *v1beta1.TaskRun, pod *corev1.Pod) {
+	if !formats.HermeticClaimed(tr) {
+		return
+	}
+	isolation := formats.Isolation{Claimed: true}
+	if pod != nil {
+		violations, err := ts.isolationViolations(ctx, pod)
+		if err != nil {
+			logging.FromContext(ctx).Warnf("Not verifying the hermeticity of pod %s: %v", pod.Name, err)
+		} else {
+			isolation.Verified = true
+			isolation.Violations = violations
+			isolation.Hermetic = len(violations) == 0
+		}
+	}
+	value, err := json.Marshal(isolation)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Not recording the isolation of TaskRun %s/%s: %v", tr.Namespace, tr.Name, err)
+		return
+	}
+	if tr.Annotations == nil {
+		tr.Annotations = map[string]string{}
+	}
+	tr.Annotations[formats.IsolationAnnotation] = string(value)
+}
+
+// isolationViolations returns what gave pod access to the network or the host.
+func (ts *TaskRunSigner) isolationViolations(ctx context.Context, pod *corev1.Pod) ([]string, error) {
+	var violations []string
+	if pod.Spec.HostNetwork {
+		violations = append(violations, "the pod uses the host network")
+	}
+	if pod.Spec.HostPID || pod.Spec.HostIPC {
+		violations = append(violations, "the pod shares the process or IPC namespace of the host")
+	}
+	for _, v := range pod.Spec.Volumes {
+		if v.HostPath != nil {
+			violations = append(violations, fmt.Sprintf("volume %s mounts %s from the host", v.Name, v.HostPath.Path))
+		}
+	}
+
+	// NetworkPolicies add up: the egress of the pod is denied when a policy that selects it
+	// restricts its egress, and none allows any.
+	policies, err := ts.KubeClient.NetworkingV1().NetworkPolicies(pod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	restricted := false
+	for _, p := range policies.Items {
+		selector, err := metav1.LabelSelectorAsSelector(&p.Spec.PodSelector)
+		if err != nil || !selector.Matches(labels.Set(pod.Labels)) || !restrictsEgress(p) {
+			continue
+		}
+		restricted = true
+		if len(p.Spec.Egress) > 0 {
+			violations = append(violations, fmt.Sprintf("NetworkPolicy %s allows egress", p.Name))
+		}
+	}
+	if !restricted {
+		violations = append(violations, "no NetworkPolicy denies the egress of the pod")
+	}
+	return violations, nil
+}
+
+func restrictsEgress(p networkingv1.NetworkPolicy) bool {
+	if len(p.Spec.Egress) > 0 {
+		return true
+	}
+	for _, t := range p.Spec.PolicyTypes {
+		if t == networkingv1.PolicyTypeEgress {
+			return true
+		}
+	}
+	return false
+}
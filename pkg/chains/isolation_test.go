@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+func TestRecordIsolation(t *testing.T) {
+	denyEgress := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-egress", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"hermetic": "true"}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		},
+	}
+	allowDNS := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-dns", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Egress: []networkingv1.NetworkPolicyEgressRule{{}},
+		},
+	}
+	otherPods := denyEgress
+	otherPods.Name = "other-pods"
+	otherPods.Spec.PodSelector = metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	hermeticPod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default", Labels: map[string]string{"hermetic": "true"}},
+	}
+	hostPod := hermeticPod
+	hostPod.Spec = corev1.PodSpec{
+		HostNetwork: true,
+		Volumes: []corev1.Volume{{
+			Name:         "docker",
+			VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run/docker.sock"}},
+		}},
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		results     []v1beta1.TaskRunResult
+		pod         *corev1.Pod
+		policies    []networkingv1.NetworkPolicy
+		want        *formats.Isolation
+	}{
+		{
+			name:     "no claim",
+			pod:      &hermeticPod,
+			policies: []networkingv1.NetworkPolicy{denyEgress},
+		},
+		{
+			name:        "hermetic",
+			annotations: map[string]string{formats.HermeticAnnotation: "true"},
+			pod:         &hermeticPod,
+			policies:    []networkingv1.NetworkPolicy{denyEgress, otherPods},
+			want:        &formats.Isolation{Hermetic: true, Claimed: true, Verified: true},
+		},
+		{
+			name:     "claimed by a result",
+			results:  []v1beta1.TaskRunResult{{Name: formats.HermeticResult, Value: "true\n"}},
+			pod:      &hermeticPod,
+			policies: []networkingv1.NetworkPolicy{denyEgress},
+			want:     &formats.Isolation{Hermetic: true, Claimed: true, Verified: true},
+		},
+		{
+			name:        "egress allowed",
+			annotations: map[string]string{formats.HermeticAnnotation: "true"},
+			pod:         &hermeticPod,
+			policies:    []networkingv1.NetworkPolicy{denyEgress, allowDNS},
+			want: &formats.Isolation{Claimed: true, Verified: true, Violations: []string{
+				"NetworkPolicy allow-dns allows egress",
+			}},
+		},
+		{
+			name:        "host access",
+			annotations: map[string]string{formats.HermeticAnnotation: "true"},
+			pod:         &hostPod,
+			policies:    []networkingv1.NetworkPolicy{otherPods},
+			want: &formats.Isolation{Claimed: true, Verified: true, Violations: []string{
+				"the pod uses the host network",
+				"volume docker mounts /var/run/docker.sock from the host",
+				"no NetworkPolicy denies the egress of the pod",
+			}},
+		},
+		{
+			name: "pod gone",
+			annotations: map[string]string{
+				formats.HermeticAnnotation: "true",
+				// A forged annotation is never trusted.
+				formats.IsolationAnnotation: `{"hermetic":true,"claimed":true,"verified":true}`,
+			},
+			want: &formats.Isolation{Claimed: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			kc := fakekubeclient.Get(ctx)
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: tt.annotations},
+				Status: v1beta1.TaskRunStatus{
+					TaskRunStatusFields: v1beta1.TaskRunStatusFields{PodName: "pod", TaskRunResults: tt.results},
+				},
+			}
+			if tt.pod != nil {
+				if _, err := kc.CoreV1().Pods("default").Create(ctx, tt.pod, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := range tt.policies {
+				if _, err := kc.NetworkingV1().NetworkPolicies("default").Create(ctx, &tt.policies[i], metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ts := &TaskRunSigner{KubeClient: kc}
+			ts.recordPod(ctx, tr)
+			got, err := formats.IsolationFromTaskRun(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("isolation: -want +got: %s", diff)
+			}
+		})
+	}
+}
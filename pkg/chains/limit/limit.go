@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package limit bounds the number of calls made at the same time to the KMSs and registries
+// across all the workers, so that a burst of completed TaskRuns doesn't get throttled by the
+// cloud providers and fail to be signed.
+package limit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+var (
+	// KMS limits the signing calls made to KMSs.
+	KMS = &Limiter{}
+	// Registry limits the pushes of signatures and attestations to registries.
+	Registry = &Limiter{}
+)
+
+// Update sets the limits from the configuration. It's called with each new configuration
+// stored by the config.ConfigStore, so values that aren't a *config.Config are ignored.
+func Update(_ string, value interface{}) {
+	cfg, ok := value.(*config.Config)
+	if !ok {
+		return
+	}
+	KMS.SetLimit(cfg.Signers.KMS.MaxConcurrent)
+	Registry.SetLimit(cfg.Storage.OCI.MaxConcurrent)
+}
+
+// Limiter is a semaphore whose size can be changed while it's in use. The zero value has no
+// limit.
+type Limiter struct {
+	mu sync.Mutex
+	// limit is the number of calls allowed at the same time, unlimited when it's 0 or less.
+	limit int
+	inUse int
+	// waiters are the calls waiting in turn, which are let through by closing their channel.
+	waiters []chan struct{}
+}
+
+// SetLimit sets the number of calls allowed at the same time, 0 for no limit. Lowering it
+// doesn't interrupt the calls already let through, the next ones wait for them.
+func (l *Limiter) SetLimit(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = n
+	l.wake()
+}
+
+// Acquire waits until a call is allowed, or ctx is done. Release must be called once the
+// call is done, if it returns no error.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if len(l.waiters) == 0 && l.free() {
+		l.inUse++
+		l.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for i, w := range l.waiters {
+			if w == ready {
+				l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// The call was let through as ctx was done, so its turn is passed on.
+		l.inUse--
+		l.wake()
+		return ctx.Err()
+	}
+}
+
+// Release ends a call let through by Acquire.
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inUse--
+	l.wake()
+}
+
+// Do calls f once it's allowed, or returns the error of ctx.
+func (l *Limiter) Do(ctx context.Context, f func() error) error {
+	if err := l.Acquire(ctx); err != nil {
+		return err
+	}
+	defer l.Release()
+	return f()
+}
+
+// wake lets the waiting calls through, in order, while there's room. l.mu must be held.
+func (l *Limiter) wake() {
+	for len(l.waiters) > 0 && l.free() {
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+		l.inUse++
+	}
+}
+
+// free returns whether another call is allowed. l.mu must be held.
+func (l *Limiter) free() bool {
+	return l.limit <= 0 || l.inUse < l.limit
+}
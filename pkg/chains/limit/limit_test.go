@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package limit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+func TestLimiter(t *testing.T) {
+	l := &Limiter{}
+	l.SetLimit(2)
+
+	var mu sync.Mutex
+	running, max := 0, 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := l.Do(context.Background(), func() error {
+				mu.Lock()
+				running++
+				if running > max {
+					max = running
+				}
+				mu.Unlock()
+				time.Sleep(5 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if max > 2 {
+		t.Errorf("%d calls ran at the same time, want at most 2", max)
+	}
+}
+
+func TestLimiterSetLimit(t *testing.T) {
+	l := &Limiter{}
+	l.SetLimit(1)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Error(err)
+		}
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected the second call to wait")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// Raising the limit lets the waiting call through.
+	l.SetLimit(0)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected the second call to be let through once the limit is removed")
+	}
+}
+
+func TestLimiterCanceled(t *testing.T) {
+	l := &Limiter{}
+	l.SetLimit(1)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Acquire(ctx); err != context.Canceled {
+		t.Errorf("Acquire() = %v, want %v", err, context.Canceled)
+	}
+
+	// The canceled call doesn't take a turn.
+	l.Release()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.Acquire(ctx); err != nil {
+		t.Errorf("Acquire() = %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	defer Update("", &config.Config{})
+	cfg := &config.Config{}
+	cfg.Signers.KMS.MaxConcurrent = 3
+	cfg.Storage.OCI.MaxConcurrent = 5
+	Update(config.ChainsConfig, cfg)
+	if KMS.limit != 3 {
+		t.Errorf("KMS limit = %d, want 3", KMS.limit)
+	}
+	if Registry.limit != 5 {
+		t.Errorf("Registry limit = %d, want 5", Registry.limit)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logs sets the log levels of the parts of Chains that can be tuned on their own,
+// from the loglevel.<component> keys of the config-logging ConfigMap.
+package logs
+
+import (
+	"sync"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/logging"
+)
+
+// The components whose level can be set with a loglevel.<component> key.
+const (
+	// Signing is the formatting and signing of TaskRuns and their artifacts.
+	Signing = "signing"
+	// Storage is the storage of signatures and payloads.
+	Storage = "storage"
+	// Transparency is the upload of signatures to transparency logs.
+	Transparency = "transparency"
+)
+
+// Components are all the components.
+var Components = []string{Signing, Storage, Transparency}
+
+var (
+	mu sync.RWMutex
+	// levels are the levels set for components. The others log at the level of the logger
+	// they were made from.
+	levels = map[string]zapcore.Level{}
+)
+
+// For returns a logger for a component, which logs at the level set for it while there is
+// one, and at the level of logger otherwise.
+func For(logger *zap.SugaredLogger, component string) *zap.SugaredLogger {
+	return logger.Desugar().WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		// A logger made from that of another component only takes the level of this one.
+		if lc, ok := core.(*levelCore); ok {
+			core = lc.Core
+		}
+		return &levelCore{Core: core, component: component}
+	})).Sugar()
+}
+
+// SetLevel sets the level of a component. A nil level unsets it.
+func SetLevel(component string, level *zapcore.Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	if level == nil {
+		delete(levels, component)
+		return
+	}
+	levels[component] = *level
+}
+
+func levelOf(component string) (zapcore.Level, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	l, ok := levels[component]
+	return l, ok
+}
+
+// UpdateLevels returns an observer of the config-logging ConfigMap that sets the levels of
+// the components. A component without a key logs at the level of the controller again.
+func UpdateLevels(logger *zap.SugaredLogger) configmap.Observer {
+	return func(cm *corev1.ConfigMap) {
+		cfg, err := logging.NewConfigFromConfigMap(cm)
+		if err != nil {
+			logger.Errorw("Failed to parse the logging configmap, keeping the levels of the components", zap.Error(err))
+			return
+		}
+		for _, c := range Components {
+			l, set := cfg.LoggingLevel[c]
+			old, wasSet := levelOf(c)
+			switch {
+			case set && (!wasSet || old != l):
+				logger.Infof("Updating the logging level of %s to %v", c, l)
+				SetLevel(c, &l)
+			case !set && wasSet:
+				logger.Infof("Resetting the logging level of %s to that of the controller", c)
+				SetLevel(c, nil)
+			}
+		}
+	}
+}
+
+// PayloadFormatKey is the field of the payload format on the lines logged about a payload.
+const PayloadFormatKey = "payload.format"
+
+// TaskRunFields are the fields that identify a TaskRun on every line logged about it.
+func TaskRunFields(tr *v1beta1.TaskRun) []interface{} {
+	return []interface{}{
+		"taskrun.namespace", tr.Namespace,
+		"taskrun.name", tr.Name,
+		"taskrun.uid", string(tr.UID),
+	}
+}
+
+// levelCore is a zapcore.Core that logs at the level set for its component, when there is
+// one, whatever the level of the core it wraps.
+type levelCore struct {
+	zapcore.Core
+	component string
+}
+
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	if min, ok := levelOf(c.component); ok {
+		return min.Enabled(l)
+	}
+	return c.Core.Enabled(l)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), component: c.component}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	min, ok := levelOf(c.component)
+	if !ok {
+		return c.Core.Check(ent, ce)
+	}
+	if !min.Enabled(ent.Level) {
+		return ce
+	}
+	// The wrapped core would check its own level, so it's written to directly.
+	return ce.AddCore(ent, c.Core)
+}
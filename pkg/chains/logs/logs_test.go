@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+// jsonLogger returns a logger at the info level that writes JSON lines to buf.
+func jsonLogger(buf *bytes.Buffer) *zap.SugaredLogger {
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg", LevelKey: "level", EncodeLevel: zapcore.LowercaseLevelEncoder})
+	return zap.New(zapcore.NewCore(enc, zapcore.AddSync(buf), zapcore.InfoLevel)).Sugar()
+}
+
+func messages(t *testing.T, buf *bytes.Buffer) []string {
+	t.Helper()
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q isn't JSON: %v", line, err)
+		}
+		msgs = append(msgs, entry["msg"].(string))
+	}
+	buf.Reset()
+	return msgs
+}
+
+func TestFor(t *testing.T) {
+	t.Cleanup(func() {
+		for _, c := range Components {
+			SetLevel(c, nil)
+		}
+	})
+	buf := &bytes.Buffer{}
+	logger := jsonLogger(buf)
+	signingLogger := For(logger, Signing).With("key", "value")
+	storageLogger := For(signingLogger, Storage)
+	log := func() {
+		signingLogger.Debug("signing debug")
+		signingLogger.Info("signing info")
+		storageLogger.Debug("storage debug")
+		storageLogger.Info("storage info")
+	}
+
+	log()
+	if d := cmp.Diff([]string{"signing info", "storage info"}, messages(t, buf)); d != "" {
+		t.Errorf("without levels, lines logged diff (-want +got):\n%s", d)
+	}
+
+	debug, warn := zapcore.DebugLevel, zapcore.WarnLevel
+	SetLevel(Signing, &debug)
+	log()
+	if d := cmp.Diff([]string{"signing debug", "signing info", "storage info"}, messages(t, buf)); d != "" {
+		t.Errorf("with signing at debug, lines logged diff (-want +got):\n%s", d)
+	}
+
+	SetLevel(Storage, &warn)
+	log()
+	if d := cmp.Diff([]string{"signing debug", "signing info"}, messages(t, buf)); d != "" {
+		t.Errorf("with storage at warn, lines logged diff (-want +got):\n%s", d)
+	}
+
+	SetLevel(Signing, nil)
+	SetLevel(Storage, nil)
+	signingLogger.Info("fields")
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["key"] != "value" {
+		t.Errorf("expected the fields of the logger to be kept, got %v", entry)
+	}
+}
+
+func TestUpdateLevels(t *testing.T) {
+	t.Cleanup(func() {
+		for _, c := range Components {
+			SetLevel(c, nil)
+		}
+	})
+	observe := UpdateLevels(logtesting.TestLogger(t))
+	levelsOf := func() map[string]string {
+		got := map[string]string{}
+		for _, c := range Components {
+			if l, ok := levelOf(c); ok {
+				got[c] = l.String()
+			}
+		}
+		return got
+	}
+
+	observe(&corev1.ConfigMap{Data: map[string]string{
+		"loglevel.signing":      "debug",
+		"loglevel.transparency": "error",
+		"loglevel.watcher":      "warn",
+	}})
+	if d := cmp.Diff(map[string]string{"signing": "debug", "transparency": "error"}, levelsOf()); d != "" {
+		t.Errorf("levels diff (-want +got):\n%s", d)
+	}
+
+	// An invalid ConfigMap keeps the levels.
+	observe(&corev1.ConfigMap{Data: map[string]string{"loglevel.signing": "loud"}})
+	if d := cmp.Diff(map[string]string{"signing": "debug", "transparency": "error"}, levelsOf()); d != "" {
+		t.Errorf("after an invalid ConfigMap, levels diff (-want +got):\n%s", d)
+	}
+
+	observe(&corev1.ConfigMap{Data: map[string]string{"loglevel.storage": "info"}})
+	if d := cmp.Diff(map[string]string{"storage": "info"}, levelsOf()); d != "" {
+		t.Errorf("after removing keys, levels diff (-want +got):\n%s", d)
+	}
+}
+
+func TestTaskRunFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "tr", UID: "uid"}}
+	jsonLogger(buf).With(TaskRunFields(tr)...).With(PayloadFormatKey, "in-toto").Info("signed")
+
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"level":             "info",
+		"msg":               "signed",
+		"taskrun.namespace": "ns",
+		"taskrun.name":      "tr",
+		"taskrun.uid":       "uid",
+		"payload.format":    "in-toto",
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("log line diff (-want +got):\n%s", d)
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/logging"
+)
+
+// signingKeys returns the x509 keys the payloads of tr are signed with. With
+// signers.x509.namespace-secret set, they're those of the secret of that name in the namespace
+// of tr, so tenants manage their own keys. Without one, and in namespaces without the secret,
+// they're those of the signing secret mounted at secretPath.
+func signingKeys(ctx context.Context, kc kubernetes.Interface, secretPath string, cfg config.Config, tr *v1beta1.TaskRun) (x509.Keys, error) {
+	name := cfg.Signers.X509.NamespaceSecret
+	if name == "" || kc == nil {
+		return x509.KeysIn(secretPath), nil
+	}
+	secret, err := kc.CoreV1().Secrets(tr.Namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		logging.FromContext(ctx).Debugf("No secret %s in namespace %s, using the keys of the signing secret", name, tr.Namespace)
+		return x509.KeysIn(secretPath), nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "getting the signing keys in secret %s/%s", tr.Namespace, name)
+	}
+	return x509.KeysOf(secret), nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+func TestSigningKeys(t *testing.T) {
+	const secretPath = "./signing/x509/testdata/"
+	clusterKey, err := ioutil.ReadFile(secretPath + "x509.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, _ := rtesting.SetupFakeContext(t)
+	kc := fakekubeclient.Get(ctx)
+	if _, err := kc.CoreV1().Secrets("team-a").Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "signing-secrets", Namespace: "team-a"},
+		Data:       map[string][]byte{"x509.pem": []byte("team-a's key")},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	kc.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "broken" {
+			return true, nil, errors.New("etcdserver: request timed out")
+		}
+		return false, nil, nil
+	})
+
+	tests := []struct {
+		name      string
+		secret    string
+		namespace string
+		want      string
+		wantErr   bool
+	}{{
+		name:      "disabled",
+		namespace: "team-a",
+		want:      string(clusterKey),
+	}, {
+		name:      "namespace secret",
+		secret:    "signing-secrets",
+		namespace: "team-a",
+		want:      "team-a's key",
+	}, {
+		name:      "falls back to the signing secret",
+		secret:    "signing-secrets",
+		namespace: "team-b",
+		want:      string(clusterKey),
+	}, {
+		name:      "fails on other errors",
+		secret:    "signing-secrets",
+		namespace: "broken",
+		wantErr:   true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Config{Signers: config.SignerConfigs{X509: config.X509Signer{NamespaceSecret: tt.secret}}}
+			tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: tt.namespace}}
+			keys, err := signingKeys(ctx, kc, secretPath, cfg, tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("signingKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := keys("x509.pem")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("x509.pem = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/chains/redact"
+	"github.com/tektoncd/chains/pkg/chains/signing"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// payloadCacheTTL is how long the payloads of a TaskRun are kept after they were last
+	// signed, in case it's deleted before it's retried.
+	payloadCacheTTL = time.Hour
+	// payloadCacheMaxTaskRuns bounds the number of TaskRuns whose payloads are kept. The
+	// payloads of the others are signed again when they are retried.
+	payloadCacheMaxTaskRuns = 1000
+)
+
+// signedPayload is a payload that was signed for a TaskRun, with what's needed to upload it
+// to the transparency logs and store it again.
+type signedPayload struct {
+	payload    interface{}
+	rawPayload []byte
+	signature  []byte
+	// signer signed the payload. It's kept since the keys of some signers, e.g. Fulcio's, are
+	// only used once.
+	signer signing.Signer
+	// countersignature countersigns the envelope in signature, with countersigner.
+	countersignature []byte
+	countersigner    signing.Signer
+	// uploaded are the entries of the transparency logs that took the payload.
+	uploaded []tlogUpload
+	// redacted are where the secrets redacted from rawPayload were found.
+	redacted []redact.Finding
+}
+
+// uploadedTo returns the entry of the transparency log at url, or nil if the payload wasn't
+// uploaded to it or there's no payload.
+func (p *signedPayload) uploadedTo(url string) *tlogUpload {
+	if p == nil {
+		return nil
+	}
+	for i := range p.uploaded {
+		if p.uploaded[i].url == url {
+			return &p.uploaded[i]
+		}
+	}
+	return nil
+}
+
+// PayloadCache keeps the payloads signed for TaskRuns until they are marked as signed, so
+// that a TaskRun retried after its payloads failed to be stored reuses them. They aren't
+// signed and uploaded to the transparency logs again, which would add entries to the logs
+// and calls to the KMS. The payloads are only kept in memory, so they are signed again after
+// the controller restarts.
+type PayloadCache struct {
+	// now is set for testing.
+	now func() time.Time
+
+	mu       sync.Mutex
+	taskRuns map[types.UID]*cachedTaskRun
+}
+
+type cachedTaskRun struct {
+	payloads map[string]*signedPayload
+	expires  time.Time
+}
+
+// NewPayloadCache returns an empty PayloadCache.
+func NewPayloadCache() *PayloadCache {
+	return &PayloadCache{
+		now:      time.Now,
+		taskRuns: map[types.UID]*cachedTaskRun{},
+	}
+}
+
+// get returns the payload signed for the TaskRun under key, or nil. A nil cache has none.
+func (c *PayloadCache) get(uid types.UID, key string) *signedPayload {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.taskRuns[uid]
+	if !ok || c.now().After(t.expires) {
+		return nil
+	}
+	return t.payloads[key]
+}
+
+// put keeps the payload signed for the TaskRun under key. A nil cache keeps nothing.
+func (c *PayloadCache) put(uid types.UID, key string, p *signedPayload) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	t, ok := c.taskRuns[uid]
+	if !ok {
+		for u, t := range c.taskRuns {
+			if now.After(t.expires) {
+				delete(c.taskRuns, u)
+			}
+		}
+		if len(c.taskRuns) >= payloadCacheMaxTaskRuns {
+			return
+		}
+		t = &cachedTaskRun{payloads: map[string]*signedPayload{}}
+		c.taskRuns[uid] = t
+	}
+	t.payloads[key] = p
+	t.expires = now.Add(payloadCacheTTL)
+}
+
+// forget drops the payloads of the TaskRun, once it's signed or won't be retried.
+func (c *PayloadCache) forget(uid types.UID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.taskRuns, uid)
+}
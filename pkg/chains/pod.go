@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	pipelineconfig "github.com/tektoncd/pipeline/pkg/apis/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+var (
+	// Version is the version of the Chains controller recorded in provenance. The controller
+	// sets it from its deployment.
+	Version = "devel"
+	// PipelinesNamespace is the namespace Tekton Pipelines is installed in. The feature flags
+	// recorded in provenance are read from it.
+	PipelinesNamespace = "tekton-pipelines"
+)
+
+// pipelinesReleaseAnnotation is set by Tekton Pipelines on the pods it creates, to its version.
+const pipelinesReleaseAnnotation = "pipeline.tekton.dev/release"
+
+// recordPod looks up the pod of the TaskRun, and records the node it ran on, the builder that
+// ran it and how isolated it was in annotations on tr for the formatters. They're looked up
+// again each time and never trusted from the TaskRun, so the node and builder are left out
+// when the pod is gone.
+func (ts *TaskRunSigner) recordPod(ctx context.Context, tr *v1beta1.TaskRun) {
+	delete(tr.Annotations, formats.NodeNameAnnotation)
+	delete(tr.Annotations, formats.BuilderAnnotation)
+	delete(tr.Annotations, formats.IsolationAnnotation)
+	pod := ts.taskRunPod(ctx, tr)
+	ts.recordIsolation(ctx, tr, pod)
+	if pod == nil {
+		return
+	}
+	if tr.Annotations == nil {
+		tr.Annotations = map[string]string{}
+	}
+	if pod.Spec.NodeName != "" {
+		tr.Annotations[formats.NodeNameAnnotation] = pod.Spec.NodeName
+	}
+	value, err := json.Marshal(ts.builder(ctx, pod))
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Not recording the builder of pod %s: %v", pod.Name, err)
+		return
+	}
+	tr.Annotations[formats.BuilderAnnotation] = string(value)
+}
+
+// taskRunPod returns the pod of the TaskRun, or nil if it can't be read.
+func (ts *TaskRunSigner) taskRunPod(ctx context.Context, tr *v1beta1.TaskRun) *corev1.Pod {
+	if ts.KubeClient == nil || tr.Status.PodName == "" {
+		return nil
+	}
+	pod, err := ts.KubeClient.CoreV1().Pods(tr.Namespace).Get(ctx, tr.Status.PodName, metav1.GetOptions{})
+	if err != nil {
+		logging.FromContext(ctx).Debugf("Not recording the node, builder and isolation of pod %s: %v", tr.Status.PodName, err)
+		return nil
+	}
+	return pod
+}
+
+// builder describes the Tekton installation that ran pod.
+func (ts *TaskRunSigner) builder(ctx context.Context, pod *corev1.Pod) formats.Builder {
+	b := formats.Builder{
+		ChainsVersion:    Version,
+		PipelinesVersion: pod.Annotations[pipelinesReleaseAnnotation],
+	}
+	// The feature flags are those set when the TaskRun is signed, which are the ones it ran
+	// with unless they were changed since.
+	name := pipelineconfig.GetFeatureFlagsConfigName()
+	cm, err := ts.KubeClient.CoreV1().ConfigMaps(PipelinesNamespace).Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{}
+	case err != nil:
+		logging.FromContext(ctx).Warnf("Not recording the feature flags of Tekton Pipelines: %v", err)
+		return b
+	}
+	flags, err := pipelineconfig.NewFeatureFlagsFromMap(cm.Data)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Not recording the feature flags of Tekton Pipelines: %v", err)
+		return b
+	}
+	b.FeatureFlags = map[string]string{
+		"enable-api-fields":                             flags.EnableAPIFields,
+		"disable-affinity-assistant":                    strconv.FormatBool(flags.DisableAffinityAssistant),
+		"disable-creds-init":                            strconv.FormatBool(flags.DisableCredsInit),
+		"disable-home-env-overwrite":                    strconv.FormatBool(flags.DisableHomeEnvOverwrite),
+		"disable-working-directory-overwrite":           strconv.FormatBool(flags.DisableWorkingDirOverwrite),
+		"enable-custom-tasks":                           strconv.FormatBool(flags.EnableCustomTasks),
+		"enable-tekton-oci-bundles":                     strconv.FormatBool(flags.EnableTektonOCIBundles),
+		"require-git-ssh-secret-known-hosts":            strconv.FormatBool(flags.RequireGitSSHSecretKnownHosts),
+		"running-in-environment-with-injected-sidecars": strconv.FormatBool(flags.RunningInEnvWithInjectedSidecars),
+		"scope-when-expressions-to-task":                strconv.FormatBool(flags.ScopeWhenExpressionsToTask),
+	}
+	return b
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+func TestRecordPod(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pod",
+			Namespace:   "default",
+			Annotations: map[string]string{pipelinesReleaseAnnotation: "v0.27.3"},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-1"},
+	}
+	unscheduled := pod.DeepCopy()
+	unscheduled.Spec.NodeName = ""
+	forged := map[string]string{
+		formats.NodeNameAnnotation: "forged-node",
+		formats.BuilderAnnotation:  `{"chainsVersion":"v9.9.9","pipelinesVersion":"v9.9.9"}`,
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		pod         *corev1.Pod
+		wantNode    string
+		want        *formats.Builder
+	}{
+		{
+			name:     "pod",
+			pod:      pod,
+			wantNode: "node-1",
+			want:     &formats.Builder{ChainsVersion: Version, PipelinesVersion: "v0.27.3"},
+		},
+		{
+			name:        "node and builder set on the TaskRun",
+			annotations: forged,
+			pod:         pod,
+			wantNode:    "node-1",
+			want:        &formats.Builder{ChainsVersion: Version, PipelinesVersion: "v0.27.3"},
+		},
+		{
+			name:        "pod without a node",
+			annotations: forged,
+			pod:         unscheduled,
+			want:        &formats.Builder{ChainsVersion: Version, PipelinesVersion: "v0.27.3"},
+		},
+		{
+			name:        "pod gone",
+			annotations: forged,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			kc := fakekubeclient.Get(ctx)
+			if tt.pod != nil {
+				if _, err := kc.CoreV1().Pods(tt.pod.Namespace).Create(ctx, tt.pod, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			annotations := map[string]string{}
+			for k, v := range tt.annotations {
+				annotations[k] = v
+			}
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", Annotations: annotations},
+				Status: v1beta1.TaskRunStatus{
+					TaskRunStatusFields: v1beta1.TaskRunStatusFields{PodName: "pod"},
+				},
+			}
+
+			ts := &TaskRunSigner{KubeClient: kc}
+			ts.recordPod(ctx, tr)
+			if node, ok := tr.Annotations[formats.NodeNameAnnotation]; node != tt.wantNode || ok != (tt.wantNode != "") {
+				t.Errorf("node = %q, want %q", node, tt.wantNode)
+			}
+			got, err := formats.BuilderFromTaskRun(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The feature flags are covered by TestTaskRunSigner_Pod.
+			if got != nil {
+				got.FeatureFlags = nil
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("builder: -want +got: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+	"github.com/tektoncd/chains/pkg/chains/signing"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/config"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The reasons a signer probe fails for.
+const (
+	ProbeReasonAuth       = "auth"
+	ProbeReasonPermission = "permission"
+	ProbeReasonNetwork    = "network"
+	ProbeReasonNotFound   = "not-found"
+	ProbeReasonMismatch   = "mismatch"
+	ProbeReasonUnknown    = "unknown"
+)
+
+// probeCanary is the payload signers are asked to sign when signers.probe is sign.
+var probeCanary = []byte("tekton-chains signer probe")
+
+// SignerProbe is the outcome of probing a signer.
+type SignerProbe struct {
+	// Signer is x509, kms or the name of a signer profile.
+	Signer string `json:"signer"`
+	Ready  bool   `json:"ready"`
+	// Skipped is why the signer wasn't probed, if it wasn't.
+	Skipped string `json:"skipped,omitempty"`
+	// Reason is what kind of problem the signer has, one of the ProbeReason constants.
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Hint is what to look at to fix the problem.
+	Hint string `json:"hint,omitempty"`
+}
+
+// ProbeSigners checks each signer the artifacts are signed or countersigned with, the way
+// cfg.Signers.Probe says: by loading it and fetching its public key, and by signing a canary
+// payload and verifying the signature. The signers are probed in the order of their names.
+func ProbeSigners(ctx context.Context, secretPath string, cfg config.Config, logger *zap.SugaredLogger) []SignerProbe {
+	if cfg.Signers.Probe == config.SignerProbeNone {
+		return nil
+	}
+	used := sets.NewString()
+	for _, s := range []string{cfg.Artifacts.TaskRuns.Signer, cfg.Artifacts.OCI.Signer, cfg.Artifacts.Blobs.Signer, cfg.Signers.Countersigner} {
+		if s != "" {
+			used.Insert(s)
+		}
+	}
+	probes := []SignerProbe{}
+	for _, name := range used.List() {
+		probes = append(probes, probeSigner(ctx, secretPath, cfg, name, logger))
+	}
+	return probes
+}
+
+func probeSigner(ctx context.Context, secretPath string, cfg config.Config, name string, logger *zap.SugaredLogger) SignerProbe {
+	probe := SignerProbe{Signer: name}
+	if p, pcfg := cfg.ResolveSigner(name); p.Type == signing.TypeX509 && pcfg.Signers.X509.FulcioEnabled {
+		// Fulcio certificates are requested for the identity of each TaskRun when it's signed.
+		probe.Ready, probe.Skipped = true, "fulcio certificates are only requested when signing"
+		return probe
+	}
+	signer, err := newProfileSigner(x509.KeysIn(secretPath), cfg, name, nil, logger)
+	if err != nil {
+		return probe.failed(errors.Wrap(err, "loading the signer"))
+	}
+	if _, err := signer.PublicKey(options.WithContext(ctx)); err != nil {
+		return probe.failed(errors.Wrap(err, "fetching the public key"))
+	}
+	if cfg.Signers.Probe == config.SignerProbeSign {
+		sig, err := signer.SignMessage(bytes.NewReader(probeCanary), options.WithContext(ctx))
+		if err != nil {
+			return probe.failed(errors.Wrap(err, "signing the canary payload"))
+		}
+		if err := signer.VerifySignature(bytes.NewReader(sig), bytes.NewReader(probeCanary), options.WithContext(ctx)); err != nil {
+			probe = probe.failed(errors.Wrap(err, "verifying the canary signature with the public key"))
+			probe.Reason, probe.Hint = ProbeReasonMismatch, "the signature doesn't verify with the signer's public key, check that the key files or the KMS key version belong together"
+			return probe
+		}
+	}
+	probe.Ready = true
+	return probe
+}
+
+func (p SignerProbe) failed(err error) SignerProbe {
+	p.Ready = false
+	p.Error = err.Error()
+	p.Reason, p.Hint = diagnoseProbe(err)
+	return p
+}
+
+// probeDiagnoses are the symptoms of each reason in the errors of the signers and their KMSs,
+// checked in order.
+var probeDiagnoses = []struct {
+	reason, hint string
+	symptoms     []string
+}{{
+	reason:   ProbeReasonNetwork,
+	hint:     "the controller can't reach the KMS or key server, check DNS, proxies, egress network policies and its address",
+	symptoms: []string{"connection refused", "no such host", "i/o timeout", "deadline exceeded", "tls handshake", "code = unavailable", "network is unreachable", "connection reset"},
+}, {
+	reason: ProbeReasonAuth,
+	hint:   "the controller has no valid credentials for the KMS, check its workload identity, environment and the signers.kms settings",
+	symptoms: []string{"code = unauthenticated", "statuscode=401", "code: 401", "401 unauthorized", "could not find default credentials", "nocredentialproviders",
+		"invalid_grant", "expiredtoken", "unrecognizedclientexception", "invalidclienttokenid", "vault_token"},
+}, {
+	reason:   ProbeReasonPermission,
+	hint:     "the controller's identity isn't allowed to use the key, grant it permission to get the public key and sign, e.g. roles/cloudkms.signerVerifier, kms:Sign and kms:GetPublicKey, or the key's Vault policy",
+	symptoms: []string{"code = permissiondenied", "statuscode=403", "code: 403", "403 forbidden", "accessdenied", "permission denied", "not authorized", "forbidden"},
+}, {
+	reason:   ProbeReasonNotFound,
+	hint:     "the key doesn't exist, check the key reference, its version and the files of the signing secret",
+	symptoms: []string{"code = notfound", "statuscode=404", "code: 404", "notfoundexception", "no such file", "no valid private key found", "not found", "does not exist"},
+}}
+
+// diagnoseProbe returns the reason err happened for, and a hint at fixing it.
+func diagnoseProbe(err error) (string, string) {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return probeDiagnoses[0].reason, probeDiagnoses[0].hint
+	}
+	msg := strings.ToLower(err.Error())
+	for _, d := range probeDiagnoses {
+		for _, s := range d.symptoms {
+			if strings.Contains(msg, s) {
+				return d.reason, d.hint
+			}
+		}
+	}
+	return ProbeReasonUnknown, ""
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestProbeSigners(t *testing.T) {
+	ctx := context.Background()
+	logger := logtesting.TestLogger(t)
+	cfg, err := config.NewConfigFromMap(map[string]string{
+		"signers.probe":            "sign",
+		"signers.profiles":         "missing: x509\nkeyless: x509 fulcio",
+		"artifacts.taskrun.signer": "x509",
+		"artifacts.oci.signer":     "missing",
+		"signers.countersigner":    "keyless",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probes := ProbeSigners(ctx, "./signing/x509/testdata/", *cfg, logger)
+	if len(probes) != 3 {
+		t.Fatalf("ProbeSigners() = %v, want the probes of 3 signers", probes)
+	}
+	keyless, missing, x509 := probes[0], probes[1], probes[2]
+	if !keyless.Ready || keyless.Skipped == "" {
+		t.Errorf("expected the fulcio signer to be skipped, got %+v", keyless)
+	}
+	if missing.Ready || missing.Reason != ProbeReasonNotFound || missing.Hint == "" {
+		t.Errorf("expected the signer without keys not to be found, got %+v", missing)
+	}
+	if x509.Signer != "x509" || !x509.Ready || x509.Error != "" {
+		t.Errorf("expected the x509 signer to sign the canary, got %+v", x509)
+	}
+
+	cfg.Signers.Probe = config.SignerProbeNone
+	if probes := ProbeSigners(ctx, "./signing/x509/testdata/", *cfg, logger); len(probes) != 0 {
+		t.Errorf("ProbeSigners() = %v with signers.probe none, want no probes", probes)
+	}
+}
+
+func TestDiagnoseProbe(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ProbeReasonNetwork},
+		{errors.New("rpc error: code = Unavailable desc = name resolver error"), ProbeReasonNetwork},
+		{errors.New("google: could not find default credentials"), ProbeReasonAuth},
+		{errors.New("keyvault.BaseClient#GetKey: Failure responding to request: StatusCode=401"), ProbeReasonAuth},
+		{errors.New("rpc error: code = PermissionDenied desc = Permission 'cloudkms.cryptoKeyVersions.viewPublicKey' denied"), ProbeReasonPermission},
+		{errors.New("AccessDeniedException: User is not authorized to perform: kms:GetPublicKey"), ProbeReasonPermission},
+		{errors.New("Error making API request.\n\nCode: 403. Errors:\n\n* 1 error occurred:\n\t* permission denied"), ProbeReasonPermission},
+		{errors.New("rpc error: code = NotFound desc = CryptoKeyVersion projects/p/... not found"), ProbeReasonNotFound},
+		{errors.New("something else"), ProbeReasonUnknown},
+	}
+	for _, tt := range tests {
+		if got, _ := diagnoseProbe(errors.Wrap(tt.err, "fetching the public key")); got != tt.want {
+			t.Errorf("diagnoseProbe(%q) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
@@ -39,6 +39,13 @@ type Invocation struct {
 	RecipeURI string `json:"recipe_uri"`
 	EventID   string `json:"event_id"`
 	ID        string `json:"builder.id"`
+	// PodName and NodeName are where the TaskRun ran.
+	PodName  string `json:"pod_name,omitempty"`
+	NodeName string `json:"node_name,omitempty"`
+	// Builder describes the Tekton installation that ran the TaskRun.
+	Builder interface{} `json:"builder,omitempty"`
+	// Rebuild are the instructions to rebuild a reproducible TaskRun.
+	Rebuild interface{} `json:"rebuild,omitempty"`
 }
 
 // ProvenanceRecipe describes the actions performed by the builder.
@@ -55,10 +62,18 @@ type RecipeStep struct {
 
 // ProvenanceMetadata contains metadata for the built artifact.
 type ProvenanceMetadata struct {
+	// BuildInvocationID identifies the run of the TaskRun, see formats.InvocationID.
+	BuildInvocationID string `json:"buildInvocationID,omitempty"`
 	// Use pointer to make sure that the abscense of a time is not
 	// encoded as the Epoch time.
 	BuildStartedOn  *time.Time `json:"buildStartedOn,omitempty"`
 	BuildFinishedOn *time.Time `json:"buildFinishedOn,omitempty"`
+	// Hermetic is set when the TaskRun claimed to run hermetically and Chains verified it.
+	Hermetic bool `json:"hermetic,omitempty"`
+	// Isolation describes the verification of the claim, when there's one.
+	Isolation interface{} `json:"isolation,omitempty"`
+	// Context are the labels and annotations of the TaskRun listed in the configuration.
+	Context interface{} `json:"context,omitempty"`
 	// removed: Completeness
 	Reproducible bool `json:"reproducible,omitempty"`
 }
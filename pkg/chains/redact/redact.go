@@ -0,0 +1,208 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package redact finds secrets, such as credentials passed to a Task as parameters, in the
+// payloads Chains signs, so they can be redacted before they are published in a public
+// transparency log.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// Redacted replaces the secrets found in payloads.
+const Redacted = "[REDACTED]"
+
+// RuleEntropy is the rule of the findings of tokens with a high entropy.
+const RuleEntropy = "entropy"
+
+// DefaultPatterns match well known kinds of credentials. They are always used. When a pattern
+// has a group, only what the first group matches is redacted.
+var DefaultPatterns = []string{
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// PEM private keys
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[^-]*-----END [A-Z ]*PRIVATE KEY-----`,
+	// GitHub tokens
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	// Slack tokens
+	`\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+	// Google API keys
+	`\bAIza[0-9A-Za-z_-]{35}`,
+	// JSON Web Tokens
+	`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+	// Passwords in URLs
+	`://[^/\s:@]+:([^/\s@]+)@`,
+}
+
+// minEntropyLength is the length of the shortest token whose entropy is measured. Shorter
+// tokens can't have a high enough entropy to tell them from words.
+const minEntropyLength = 20
+
+// tokenPattern matches the tokens of a string whose entropy is measured.
+var tokenPattern = regexp.MustCompile(`[A-Za-z0-9+/_-]{20,}=*`)
+
+// Finding is a secret found in a payload.
+type Finding struct {
+	// Path is where the secret is in the payload, e.g. predicate.invocation.parameters.token.
+	Path string
+	// Rule is the pattern that matched the secret, or RuleEntropy.
+	Rule string
+}
+
+// Scanner finds secrets in payloads.
+type Scanner struct {
+	patterns []*regexp.Regexp
+	entropy  float64
+}
+
+// NewScanner returns a Scanner for the DefaultPatterns, the patterns in cfg and, when its
+// threshold is set, tokens with a high entropy.
+func NewScanner(cfg config.RedactConfig) (*Scanner, error) {
+	s := &Scanner{entropy: cfg.EntropyThreshold}
+	for _, p := range append(append([]string{}, DefaultPatterns...), cfg.Patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compiling pattern %q", p)
+		}
+		s.patterns = append(s.patterns, re)
+	}
+	return s, nil
+}
+
+// Redact replaces the secrets in the string values of the JSON document raw with Redacted.
+// It returns the redacted document, and where the secrets were found. raw is returned as it
+// is when there are none.
+func (s *Scanner) Redact(raw []byte) ([]byte, []Finding, error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return nil, nil, errors.Wrap(err, "decoding payload")
+	}
+	var findings []Finding
+	doc = s.walk("", doc, &findings)
+	if len(findings) == 0 {
+		return raw, nil, nil
+	}
+	// Objects are walked in no particular order.
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Path < findings[j].Path })
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "encoding payload")
+	}
+	return redacted, findings, nil
+}
+
+func (s *Scanner) walk(path string, v interface{}, findings *[]Finding) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			v[k] = s.walk(p, e, findings)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = s.walk(fmt.Sprintf("%s[%d]", path, i), e, findings)
+		}
+	case string:
+		return s.redactString(path, v, findings)
+	}
+	return v
+}
+
+func (s *Scanner) redactString(path, v string, findings *[]Finding) string {
+	for _, re := range s.patterns {
+		if re.MatchString(v) {
+			*findings = append(*findings, Finding{Path: path, Rule: re.String()})
+			v = replace(re, v)
+		}
+	}
+	if s.entropy <= 0 {
+		return v
+	}
+	found := false
+	v = tokenPattern.ReplaceAllStringFunc(v, func(token string) string {
+		if Entropy(token) < s.entropy {
+			return token
+		}
+		found = true
+		return Redacted
+	})
+	if found {
+		*findings = append(*findings, Finding{Path: path, Rule: RuleEntropy})
+	}
+	return v
+}
+
+// replace replaces the matches of re in v, or the matches of its first group if it has one,
+// with Redacted.
+func replace(re *regexp.Regexp, v string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(v, Redacted)
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(v, -1) {
+		if m[2] < 0 {
+			continue
+		}
+		b.WriteString(v[last:m[2]])
+		b.WriteString(Redacted)
+		last = m[3]
+	}
+	b.WriteString(v[last:])
+	return b.String()
+}
+
+// Entropy returns the Shannon entropy of s, in bits per character.
+func Entropy(s string) float64 {
+	if len(s) < minEntropyLength {
+		return 0
+	}
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+	var e float64
+	for _, c := range counts {
+		p := float64(c) / float64(len(s))
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// Paths returns the paths of the findings, without duplicates, e.g. for an error message.
+func Paths(findings []Finding) string {
+	seen := map[string]bool{}
+	paths := []string{}
+	for _, f := range findings {
+		if !seen[f.Path] {
+			seen[f.Path] = true
+			paths = append(paths, f.Path)
+		}
+	}
+	return strings.Join(paths, ", ")
+}
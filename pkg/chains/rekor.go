@@ -15,14 +15,27 @@ package chains
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/trillian/merkle/logverifier"
+	"github.com/google/trillian/merkle/rfc6962"
 	"github.com/pkg/errors"
 	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/oci"
 	rc "github.com/sigstore/rekor/pkg/client"
 	"github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/rekor/pkg/generated/client/entries"
+	"github.com/sigstore/rekor/pkg/generated/client/tlog"
 	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/rekor/pkg/util"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
 	"github.com/tektoncd/chains/pkg/chains/signing"
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
@@ -39,23 +52,259 @@ var (
 )
 
 type rekor struct {
-	c      *client.Rekor
-	logger *zap.SugaredLogger
+	c          *client.Rekor
+	url        string
+	cfg        config.TransparencyConfig
+	secretPath string
+	logger     *zap.SugaredLogger
 }
 
 type rekorClient interface {
-	UploadTlog(ctx context.Context, signer signing.Signer, signature, rawPayload []byte, cert, payloadFormat string) (*models.LogEntryAnon, error)
+	UploadTlog(ctx context.Context, signer signing.Signer, signature, rawPayload []byte, cert, entryType string) (*config.TlogBundle, error)
 }
 
-func (r *rekor) UploadTlog(ctx context.Context, signer signing.Signer, signature, rawPayload []byte, cert, payloadFormat string) (*models.LogEntryAnon, error) {
+// UploadTlog uploads the signature to the transparency log as an entry of the given kind. The
+// entry the log returns is only trusted once its inclusion proof and signed entry timestamp
+// are verified. It's returned with everything needed to verify it again offline.
+func (r *rekor) UploadTlog(ctx context.Context, signer signing.Signer, signature, rawPayload []byte, cert, entryType string) (*config.TlogBundle, error) {
 	pkoc, err := publicKeyOrCert(signer, cert)
 	if err != nil {
 		return nil, errors.Wrap(err, "public key or cert")
 	}
-	if payloadFormat == "in-toto" || payloadFormat == "tekton-provenance" {
-		return cosign.TLogUploadInTotoAttestation(ctx, r.c, signature, pkoc)
+	proposed, err := NewTlogEntry(entryType, signature, rawPayload, pkoc)
+	if err != nil {
+		return nil, err
+	}
+	uploaded, err := r.upload(ctx, proposed)
+	if err != nil {
+		return nil, err
+	}
+	logKey, err := LogPublicKey(ctx, r.c, r.cfg, r.url, r.secretPath)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := r.verifiedEntry(ctx, uploaded, logKey)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := NewTlogBundle(entry)
+	if err != nil {
+		return nil, err
+	}
+	// The signed entry timestamp already promises the entry is in the log, so a log that
+	// doesn't serve checkpoints doesn't fail the upload.
+	if err := r.addCheckpoint(ctx, bundle, logKey); err != nil {
+		r.logger.Warnf("Not storing a checkpoint for transparency log entry %d: %v", bundle.LogIndex, err)
+	}
+	return bundle, nil
+}
+
+// upload creates an entry in the log. An entry that is already in the log, e.g. because the
+// TaskRun is being signed again, is fetched instead.
+func (r *rekor) upload(ctx context.Context, proposed models.ProposedEntry) (*models.LogEntryAnon, error) {
+	params := entries.NewCreateLogEntryParamsWithContext(ctx)
+	params.SetProposedEntry(proposed)
+	resp, err := r.c.Entries.CreateLogEntry(params)
+	if err != nil {
+		var exists *entries.CreateLogEntryConflict
+		if errors.As(err, &exists) {
+			parts := strings.Split(exists.Location.String(), "/")
+			return cosign.GetTlogEntry(ctx, r.c, parts[len(parts)-1])
+		}
+		return nil, errors.Wrapf(err, "uploading %s entry", proposed.Kind())
+	}
+	for _, entry := range resp.Payload {
+		entry := entry
+		return &entry, nil
+	}
+	return nil, errors.New("transparency log returned no entry")
+}
+
+// verifiedEntry gets the inclusion proof of an entry that was just uploaded, which the upload
+// response doesn't include, and verifies the entry against the log's public key.
+func (r *rekor) verifiedEntry(ctx context.Context, uploaded *models.LogEntryAnon, logKey *ecdsa.PublicKey) (*models.LogEntryAnon, error) {
+	body, ok := uploaded.Body.(string)
+	if !ok {
+		return nil, errors.New("transparency log entry has no body")
+	}
+	leaf, err := leafHash(body)
+	if err != nil {
+		return nil, err
 	}
-	return cosign.TLogUpload(ctx, r.c, signature, rawPayload, pkoc)
+	return GetVerifiedTlogEntry(ctx, r.c, hex.EncodeToString(leaf), logKey)
+}
+
+// GetVerifiedTlogEntry gets a log entry with its inclusion proof, and verifies the entry
+// against the log's public key.
+func GetVerifiedTlogEntry(ctx context.Context, c *client.Rekor, uuid string, logKey *ecdsa.PublicKey) (*models.LogEntryAnon, error) {
+	entry, err := cosign.GetTlogEntry(ctx, c, uuid)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting transparency log entry")
+	}
+	bundle, err := NewTlogBundle(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyTlogBundle(bundle, logKey); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// addCheckpoint adds the log's latest signed checkpoint to a bundle, with a proof that the
+// tree of the bundle's inclusion proof is a prefix of the checkpoint's. The log only proves
+// inclusion in its current tree, which may have grown by the time the checkpoint is fetched.
+func (r *rekor) addCheckpoint(ctx context.Context, bundle *config.TlogBundle, logKey *ecdsa.PublicKey) error {
+	p := bundle.InclusionProof
+	if p == nil {
+		return errors.New("transparency log entry has no inclusion proof")
+	}
+	info, err := r.c.Tlog.GetLogInfo(tlog.NewGetLogInfoParamsWithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "getting transparency log checkpoint")
+	}
+	if info.Payload.SignedTreeHead == nil {
+		return errors.New("transparency log returned no checkpoint")
+	}
+	checkpoint := &config.Checkpoint{SignedNote: *info.Payload.SignedTreeHead}
+	sc := util.SignedCheckpoint{}
+	if err := sc.UnmarshalText([]byte(checkpoint.SignedNote)); err != nil {
+		return errors.Wrap(err, "parsing transparency log checkpoint")
+	}
+	if size := int64(sc.Size); size > p.TreeSize {
+		params := tlog.NewGetLogProofParamsWithContext(ctx)
+		params.SetFirstSize(&p.TreeSize)
+		params.SetLastSize(size)
+		resp, err := r.c.Tlog.GetLogProof(params)
+		if err != nil {
+			return errors.Wrap(err, "getting transparency log consistency proof")
+		}
+		checkpoint.ConsistencyProof = resp.Payload.Hashes
+	}
+	root, err := hex.DecodeString(p.RootHash)
+	if err != nil {
+		return errors.Wrap(err, "decoding root hash")
+	}
+	if err := verifyCheckpoint(checkpoint, p.TreeSize, root, logKey); err != nil {
+		return errors.Wrap(err, "verifying checkpoint")
+	}
+	bundle.Checkpoint = checkpoint
+	return nil
+}
+
+// NewTlogBundle collects what is needed to verify a transparency log entry offline.
+func NewTlogBundle(entry *models.LogEntryAnon) (*config.TlogBundle, error) {
+	body, ok := entry.Body.(string)
+	if !ok || entry.IntegratedTime == nil || entry.LogIndex == nil || entry.LogID == nil {
+		return nil, errors.New("transparency log entry is incomplete")
+	}
+	if entry.Verification == nil || len(entry.Verification.SignedEntryTimestamp) == 0 {
+		return nil, errors.New("transparency log entry has no signed entry timestamp")
+	}
+	bundle := &config.TlogBundle{
+		Body:                 body,
+		IntegratedTime:       *entry.IntegratedTime,
+		LogIndex:             *entry.LogIndex,
+		LogID:                *entry.LogID,
+		SignedEntryTimestamp: entry.Verification.SignedEntryTimestamp,
+	}
+	if p := entry.Verification.InclusionProof; p != nil {
+		if p.LogIndex == nil || p.TreeSize == nil || p.RootHash == nil {
+			return nil, errors.New("transparency log inclusion proof is incomplete")
+		}
+		bundle.InclusionProof = &config.InclusionProof{
+			LogIndex: *p.LogIndex,
+			TreeSize: *p.TreeSize,
+			RootHash: *p.RootHash,
+			Hashes:   p.Hashes,
+		}
+	}
+	return bundle, nil
+}
+
+// VerifyTlogBundle checks the signed entry timestamp of a transparency log entry against the
+// log's public key, and its inclusion proof and checkpoint if it has them. It doesn't talk to
+// the log.
+func VerifyTlogBundle(bundle *config.TlogBundle, logKey *ecdsa.PublicKey) error {
+	payload := oci.BundlePayload{
+		Body:           bundle.Body,
+		IntegratedTime: bundle.IntegratedTime,
+		LogIndex:       bundle.LogIndex,
+		LogID:          bundle.LogID,
+	}
+	if err := cosign.VerifySET(payload, bundle.SignedEntryTimestamp, logKey); err != nil {
+		return errors.Wrap(err, "verifying signed entry timestamp")
+	}
+	p := bundle.InclusionProof
+	if p == nil {
+		if bundle.Checkpoint != nil {
+			return errors.New("transparency log checkpoint has no inclusion proof")
+		}
+		return nil
+	}
+	leaf, err := leafHash(bundle.Body)
+	if err != nil {
+		return err
+	}
+	root, err := hex.DecodeString(p.RootHash)
+	if err != nil {
+		return errors.Wrap(err, "decoding root hash")
+	}
+	hashes, err := decodeHashes(p.Hashes)
+	if err != nil {
+		return errors.Wrap(err, "decoding inclusion proof")
+	}
+	v := logverifier.New(rfc6962.DefaultHasher)
+	if err := v.VerifyInclusionProof(p.LogIndex, p.TreeSize, hashes, root, leaf); err != nil {
+		return errors.Wrap(err, "verifying inclusion proof")
+	}
+	if bundle.Checkpoint == nil {
+		return nil
+	}
+	return errors.Wrap(verifyCheckpoint(bundle.Checkpoint, p.TreeSize, root, logKey), "verifying checkpoint")
+}
+
+// verifyCheckpoint checks a checkpoint is signed by the log, and that the tree of the given
+// size and root is a prefix of the tree it commits to.
+func verifyCheckpoint(c *config.Checkpoint, treeSize int64, root []byte, logKey *ecdsa.PublicKey) error {
+	sc := util.SignedCheckpoint{}
+	if err := sc.UnmarshalText([]byte(c.SignedNote)); err != nil {
+		return err
+	}
+	verifier, err := signature.LoadECDSAVerifier(logKey, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	if !sc.Verify(verifier) {
+		return errors.New("checkpoint is not signed by the transparency log")
+	}
+	proof, err := decodeHashes(c.ConsistencyProof)
+	if err != nil {
+		return errors.Wrap(err, "decoding consistency proof")
+	}
+	v := logverifier.New(rfc6962.DefaultHasher)
+	return v.VerifyConsistencyProof(treeSize, int64(sc.Size), root, sc.Hash, proof)
+}
+
+func decodeHashes(hashes []string) ([][]byte, error) {
+	decoded := make([][]byte, 0, len(hashes))
+	for _, h := range hashes {
+		b, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, b)
+	}
+	return decoded, nil
+}
+
+// leafHash is the Merkle tree leaf of a log entry, which is also its UUID.
+func leafHash(body string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("decoding transparency log entry: %v", err)
+	}
+	return rfc6962.DefaultHasher.HashLeaf(decoded), nil
 }
 
 // return the cert if we have it, otherwise return public key
@@ -75,17 +324,32 @@ func publicKeyOrCert(signer signing.Signer, cert string) ([]byte, error) {
 }
 
 // for testing
-var getRekor = func(url string, l *zap.SugaredLogger) (rekorClient, error) {
+var getRekor = func(cfg config.TransparencyConfig, url, secretPath string, l *zap.SugaredLogger) (rekorClient, error) {
 	rekorClient, err := rc.GetRekorClient(url)
 	if err != nil {
 		return nil, err
 	}
 	return &rekor{
-		c:      rekorClient,
-		logger: l,
+		c:          rekorClient,
+		url:        url,
+		cfg:        cfg,
+		secretPath: secretPath,
+		logger:     l,
 	}, nil
 }
 
+// shouldUploadFormat returns whether payloads of the format are uploaded to the transparency
+// log: as the route of the format says, or else as shouldUploadTlog does.
+func shouldUploadFormat(cfg config.Config, tr *v1beta1.TaskRun, payloadFormat string) bool {
+	if r, ok := cfg.Storage.Route(payloadFormat); ok && r.Transparency != nil {
+		if !*r.Transparency {
+			return false
+		}
+		cfg.Transparency.Enabled = true
+	}
+	return shouldUploadTlog(cfg, tr)
+}
+
 func shouldUploadTlog(cfg config.Config, tr *v1beta1.TaskRun) bool {
 	// if transparency isn't enabled, return false
 	if !cfg.Transparency.Enabled {
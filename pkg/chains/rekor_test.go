@@ -14,8 +14,25 @@ limitations under the License.
 package chains
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian/merkle/rfc6962"
+	rc "github.com/sigstore/rekor/pkg/client"
+	"github.com/sigstore/rekor/pkg/util"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/options"
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -89,3 +106,256 @@ func TestShouldUploadTlog(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyTlogBundle(t *testing.T) {
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A log of two entries, the first of which is ours, that has since grown to three.
+	entry := []byte(`{"kind":"rekord"}`)
+	leaf := rfc6962.DefaultHasher.HashLeaf(entry)
+	sibling := rfc6962.DefaultHasher.HashLeaf([]byte("other"))
+	root := rfc6962.DefaultHasher.HashChildren(leaf, sibling)
+	third := rfc6962.DefaultHasher.HashLeaf([]byte("third"))
+	grownRoot := rfc6962.DefaultHasher.HashChildren(root, third)
+
+	bundle := func() *config.TlogBundle {
+		b := &config.TlogBundle{
+			Body:           base64.StdEncoding.EncodeToString(entry),
+			IntegratedTime: 1637000000,
+			LogIndex:       0,
+			LogID:          "log",
+			InclusionProof: &config.InclusionProof{
+				LogIndex: 0,
+				TreeSize: 2,
+				RootHash: hex.EncodeToString(root),
+				Hashes:   []string{hex.EncodeToString(sibling)},
+			},
+		}
+		// The signed entry timestamp is over the canonical JSON of these fields, which is sorted by key.
+		set, err := json.Marshal(map[string]interface{}{
+			"body":           b.Body,
+			"integratedTime": b.IntegratedTime,
+			"logID":          b.LogID,
+			"logIndex":       b.LogIndex,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest := sha256.Sum256(set)
+		if b.SignedEntryTimestamp, err = ecdsa.SignASN1(rand.Reader, logKey, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(b *config.TlogBundle)
+		key     *ecdsa.PublicKey
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			modify: func(b *config.TlogBundle) {},
+			key:    &logKey.PublicKey,
+		},
+		{
+			name:   "no inclusion proof",
+			modify: func(b *config.TlogBundle) { b.InclusionProof = nil },
+			key:    &logKey.PublicKey,
+		},
+		{
+			name:    "signed by another log",
+			modify:  func(b *config.TlogBundle) {},
+			key:     &otherKey.PublicKey,
+			wantErr: true,
+		},
+		{
+			name:    "tampered log index",
+			modify:  func(b *config.TlogBundle) { b.LogIndex = 1 },
+			key:     &logKey.PublicKey,
+			wantErr: true,
+		},
+		{
+			name:    "wrong root",
+			modify:  func(b *config.TlogBundle) { b.InclusionProof.RootHash = hex.EncodeToString(sibling) },
+			key:     &logKey.PublicKey,
+			wantErr: true,
+		},
+		{
+			name:    "missing proof hashes",
+			modify:  func(b *config.TlogBundle) { b.InclusionProof.Hashes = nil },
+			key:     &logKey.PublicKey,
+			wantErr: true,
+		},
+		{
+			name: "checkpoint",
+			modify: func(b *config.TlogBundle) {
+				b.Checkpoint = &config.Checkpoint{SignedNote: signedCheckpoint(t, logKey, 2, root)}
+			},
+			key: &logKey.PublicKey,
+		},
+		{
+			name: "checkpoint of a grown log",
+			modify: func(b *config.TlogBundle) {
+				b.Checkpoint = &config.Checkpoint{
+					SignedNote:       signedCheckpoint(t, logKey, 3, grownRoot),
+					ConsistencyProof: []string{hex.EncodeToString(third)},
+				}
+			},
+			key: &logKey.PublicKey,
+		},
+		{
+			name: "checkpoint of a grown log without consistency proof",
+			modify: func(b *config.TlogBundle) {
+				b.Checkpoint = &config.Checkpoint{SignedNote: signedCheckpoint(t, logKey, 3, grownRoot)}
+			},
+			key:     &logKey.PublicKey,
+			wantErr: true,
+		},
+		{
+			name: "checkpoint of another tree",
+			modify: func(b *config.TlogBundle) {
+				b.Checkpoint = &config.Checkpoint{SignedNote: signedCheckpoint(t, logKey, 2, grownRoot)}
+			},
+			key:     &logKey.PublicKey,
+			wantErr: true,
+		},
+		{
+			name: "checkpoint signed by another log",
+			modify: func(b *config.TlogBundle) {
+				b.Checkpoint = &config.Checkpoint{SignedNote: signedCheckpoint(t, otherKey, 2, root)}
+			},
+			key:     &logKey.PublicKey,
+			wantErr: true,
+		},
+		{
+			name: "checkpoint without inclusion proof",
+			modify: func(b *config.TlogBundle) {
+				b.InclusionProof = nil
+				b.Checkpoint = &config.Checkpoint{SignedNote: signedCheckpoint(t, logKey, 2, root)}
+			},
+			key:     &logKey.PublicKey,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bundle()
+			tt.modify(b)
+			if err := VerifyTlogBundle(b, tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyTlogBundle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// signedCheckpoint returns a checkpoint of a log of the given size and root, signed with key.
+func signedCheckpoint(t *testing.T, key *ecdsa.PrivateKey, size uint64, root []byte) string {
+	t.Helper()
+	sc, err := util.CreateSignedCheckpoint(util.Checkpoint{Ecosystem: "Rekor", Size: size, Hash: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signature.LoadECDSASigner(key, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.Sign("rekor", signer, options.WithCryptoSignerOpts(crypto.SHA256)); err != nil {
+		t.Fatal(err)
+	}
+	note, err := sc.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(note)
+}
+
+func TestAddCheckpoint(t *testing.T) {
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Our entry was proven to be in a tree of two entries, and the log has grown to three since.
+	leaf := rfc6962.DefaultHasher.HashLeaf([]byte(`{"kind":"rekord"}`))
+	sibling := rfc6962.DefaultHasher.HashLeaf([]byte("other"))
+	root := rfc6962.DefaultHasher.HashChildren(leaf, sibling)
+	third := rfc6962.DefaultHasher.HashLeaf([]byte("third"))
+	grownRoot := rfc6962.DefaultHasher.HashChildren(root, third)
+
+	tests := []struct {
+		name    string
+		signer  *ecdsa.PrivateKey
+		wantErr bool
+	}{
+		{name: "signed by the log", signer: logKey},
+		{name: "signed by another log", signer: otherKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			note := signedCheckpoint(t, tt.signer, 3, grownRoot)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/v1/log", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"rootHash":       hex.EncodeToString(grownRoot),
+					"signedTreeHead": note,
+					"treeSize":       3,
+				})
+			})
+			mux.HandleFunc("/api/v1/log/proof", func(w http.ResponseWriter, r *http.Request) {
+				if got := r.URL.Query().Get("firstSize"); got != "2" {
+					t.Errorf("consistency proof from tree size %s, want 2", got)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"rootHash": hex.EncodeToString(grownRoot),
+					"hashes":   []string{hex.EncodeToString(third)},
+				})
+			})
+			s := httptest.NewServer(mux)
+			defer s.Close()
+			c, err := rc.GetRekorClient(s.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := &rekor{c: c}
+			bundle := &config.TlogBundle{
+				Body: base64.StdEncoding.EncodeToString([]byte(`{"kind":"rekord"}`)),
+				InclusionProof: &config.InclusionProof{
+					LogIndex: 0,
+					TreeSize: 2,
+					RootHash: hex.EncodeToString(root),
+					Hashes:   []string{hex.EncodeToString(sibling)},
+				},
+			}
+			err = r.addCheckpoint(context.Background(), bundle, &logKey.PublicKey)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("addCheckpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if bundle.Checkpoint != nil {
+					t.Errorf("expected no checkpoint, got %+v", bundle.Checkpoint)
+				}
+				return
+			}
+			want := &config.Checkpoint{SignedNote: note, ConsistencyProof: []string{hex.EncodeToString(third)}}
+			if d := cmp.Diff(want, bundle.Checkpoint); d != "" {
+				t.Errorf("addCheckpoint() diff (-want +got):\n%s", d)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/pkg/cosign"
+	sigstoretuf "github.com/sigstore/cosign/pkg/cosign/tuf"
+	"github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/rekor/pkg/generated/client/pubkey"
+	"github.com/tektoncd/chains/pkg/chains/cache"
+	"github.com/tektoncd/chains/pkg/config"
+	tuf "github.com/theupdateframework/go-tuf"
+	tufclient "github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+	"knative.dev/pkg/logging"
+)
+
+// The files in the signing secret that establish trust in the transparency log.
+const (
+	// RekorPublicKeyFile is the PEM encoded public key of the log.
+	RekorPublicKeyFile = "rekor.pub"
+	// RekorTUFRootFile is the root.json of the TUF repository the log's public key is
+	// distributed by.
+	RekorTUFRootFile = "rekor-tuf-root.json"
+)
+
+// SigstoreRekorURL is the public Rekor instance run by Sigstore.
+const SigstoreRekorURL = "https://rekor.sigstore.dev"
+
+// The Sigstore TUF repository the key of the public Rekor instance is distributed by, and the
+// root it's trusted through, which is embedded in cosign.
+// Set these as vars for mocking.
+var (
+	sigstoreTUFMirror = "https://sigstore-tuf-root.storage.googleapis.com"
+	sigstoreTUFRoot   = sigstoretuf.GetEmbeddedRoot
+)
+
+// LogPublicKey returns the public key the entries of the transparency log at logURL are verified
+// with. A public key in the signing secret is used as it is. Otherwise, when the secret has a
+// TUF root, the key is downloaded from the TUF repository at transparency.tuf.mirror, and is
+// only trusted if the repository's metadata chains to that root. Without either, the key of
+// the public Rekor instance is downloaded from the Sigstore TUF repository, trusted through the
+// root embedded in cosign. The key of any other log is asked of the log itself, with a warning,
+// as that only protects against a log that changes its key.
+//
+// The key of transparency.url is read from RekorPublicKeyFile. The keys of the logs in
+// transparency.additional-urls are read from files named after their hosts, e.g.
+// rekor-rekor.example.com.pub, and are never downloaded from the TUF repository at
+// transparency.tuf.mirror.
+//
+// The keys are cached, so they aren't read or downloaded again for every entry.
+func LogPublicKey(ctx context.Context, c *client.Rekor, cfg config.TransparencyConfig, logURL, secretPath string) (*ecdsa.PublicKey, error) {
+	key := strings.Join([]string{logURL, cfg.URL, secretPath, cfg.TUF.Mirror, cfg.TUF.Target}, "|")
+	pub, err := logPublicKeys.Get(key, func() (interface{}, error) {
+		return fetchLogPublicKey(ctx, c, cfg, logURL, secretPath)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pub.(*ecdsa.PublicKey), nil
+}
+
+// logPublicKeys caches the public keys of the transparency logs.
+var logPublicKeys = cache.New(cache.DefaultTTL)
+
+func fetchLogPublicKey(ctx context.Context, c *client.Rekor, cfg config.TransparencyConfig, logURL, secretPath string) (*ecdsa.PublicKey, error) {
+	keyFile, primary := RekorPublicKeyFile, logURL == cfg.URL
+	if !primary {
+		var err error
+		if keyFile, err = AdditionalLogPublicKeyFile(logURL); err != nil {
+			return nil, err
+		}
+	}
+	pub, err := readSecretFile(secretPath, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if pub != nil {
+		key, err := cosign.PemToECDSAKey(pub)
+		return key, errors.Wrapf(err, "parsing %s", keyFile)
+	}
+	if !primary {
+		return defaultLogPublicKey(ctx, c, logURL, keyFile)
+	}
+
+	root, err := readSecretFile(secretPath, RekorTUFRootFile)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case root != nil && cfg.TUF.Mirror == "":
+		return nil, errors.Errorf("the signing secret has a %s, but transparency.tuf.mirror is not set", RekorTUFRootFile)
+	case root == nil && cfg.TUF.Mirror != "":
+		return nil, errors.Errorf("transparency.tuf.mirror is set, but the signing secret has no %s", RekorTUFRootFile)
+	case root != nil:
+		target := cfg.TUF.Target
+		if target == "" {
+			target = RekorPublicKeyFile
+		}
+		pub, err := tufTarget(cfg.TUF.Mirror, target, root)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting %s from %s", target, cfg.TUF.Mirror)
+		}
+		key, err := cosign.PemToECDSAKey(pub)
+		return key, errors.Wrapf(err, "parsing %s", target)
+	}
+
+	return defaultLogPublicKey(ctx, c, logURL, keyFile)
+}
+
+// defaultLogPublicKey returns the public key of a log that the signing secret doesn't establish
+// trust in. The public Rekor instance's key is downloaded from the Sigstore TUF repository. Any
+// other log can only be asked for its key, which is logged as a warning.
+func defaultLogPublicKey(ctx context.Context, c *client.Rekor, logURL, keyFile string) (*ecdsa.PublicKey, error) {
+	if strings.TrimSuffix(logURL, "/") != SigstoreRekorURL {
+		logging.FromContext(ctx).Warnf("No trusted public key is configured for transparency log %s, so its entries are verified with the key the log reports. Add %s to the signing secret to verify them with a trusted key.", logURL, keyFile)
+		return askLogPublicKey(ctx, c)
+	}
+	root, err := sigstoreTUFRoot()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the Sigstore TUF root")
+	}
+	pub, err := tufTarget(sigstoreTUFMirror, RekorPublicKeyFile, root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting %s from %s", RekorPublicKeyFile, sigstoreTUFMirror)
+	}
+	key, err := cosign.PemToECDSAKey(pub)
+	return key, errors.Wrapf(err, "parsing %s", RekorPublicKeyFile)
+}
+
+// AdditionalLogPublicKeyFile is the file of the signing secret the public key of an additional
+// transparency log is read from.
+func AdditionalLogPublicKeyFile(logURL string) (string, error) {
+	u, err := url.Parse(logURL)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Errorf("transparency log URL %q has no host", logURL)
+	}
+	return fmt.Sprintf("rekor-%s.pub", u.Hostname()), nil
+}
+
+// askLogPublicKey gets the public key the log claims to have.
+func askLogPublicKey(ctx context.Context, c *client.Rekor) (*ecdsa.PublicKey, error) {
+	resp, err := c.Pubkey.GetPublicKey(pubkey.NewGetPublicKeyParamsWithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "getting transparency log public key")
+	}
+	key, err := cosign.PemToECDSAKey([]byte(resp.Payload))
+	return key, errors.Wrap(err, "transparency log public key")
+}
+
+// readSecretFile reads a file of the signing secret. It returns nil if there's no such file.
+func readSecretFile(secretPath, name string) ([]byte, error) {
+	if secretPath == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(secretPath, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, errors.Wrapf(err, "reading %s", name)
+}
+
+// tufTarget downloads a target from a TUF repository. Only the root is trusted: the rest of the
+// repository's metadata is downloaded and verified against it, and the target against that.
+func tufTarget(mirror, target string, root []byte) ([]byte, error) {
+	keys, threshold, err := tufRootKeys(root)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := tufclient.HTTPRemoteStore(mirror, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := tufclient.NewClient(tufclient.MemoryLocalStore(), remote)
+	if err := c.Init(keys, threshold); err != nil {
+		return nil, errors.Wrap(err, "initializing TUF client")
+	}
+	if _, err := c.Update(); err != nil {
+		return nil, errors.Wrap(err, "updating TUF metadata")
+	}
+	dest := &bytesDestination{}
+	if err := c.Download(target, dest); err != nil {
+		return nil, err
+	}
+	return dest.Bytes(), nil
+}
+
+// tufRootKeys returns the keys of a TUF root, and how many of them must sign the root.
+func tufRootKeys(root []byte) ([]*data.PublicKey, int, error) {
+	repo, err := tuf.NewRepo(tuf.MemoryStore(map[string]json.RawMessage{"root.json": root}, nil))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "loading TUF root")
+	}
+	keys, err := repo.RootKeys()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "loading TUF root keys")
+	}
+	threshold, err := repo.GetThreshold("root")
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "loading TUF root threshold")
+	}
+	return keys, threshold, nil
+}
+
+// bytesDestination is where TUF downloads a target to.
+type bytesDestination struct {
+	bytes.Buffer
+}
+
+func (d *bytesDestination) Delete() error {
+	d.Reset()
+	return nil
+}
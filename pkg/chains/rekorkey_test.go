@@ -0,0 +1,317 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rc "github.com/sigstore/rekor/pkg/client"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/tektoncd/chains/pkg/config"
+	tuf "github.com/theupdateframework/go-tuf"
+)
+
+func logKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pub
+}
+
+// tufRepo serves a TUF repository with the given targets, and returns its root.json.
+func tufRepo(t *testing.T, targets map[string][]byte) (string, []byte) {
+	t.Helper()
+	store := tuf.MemoryStore(nil, targets)
+	repo, err := tuf.NewRepo(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+		if _, err := repo.GenKey(role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name := range targets {
+		if err := repo.AddTarget(name, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Timestamp(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := store.GetMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if target, ok := targets[strings.TrimPrefix(name, "targets/")]; ok && strings.HasPrefix(name, "targets/") {
+			w.Write(target)
+			return
+		}
+		if m, ok := meta[name]; ok {
+			w.Write(m)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s.URL, meta["root.json"]
+}
+
+// fakeLog serves the public key of a transparency log.
+func fakeLog(t *testing.T, pub []byte) string {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/log/publicKey" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write(pub)
+	}))
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+func TestLogPublicKey(t *testing.T) {
+	key, pub := logKey(t)
+	// The log claims a key of its own, which is only used when nothing else is configured.
+	logsKey, logsPub := logKey(t)
+	mirror, root := tufRepo(t, map[string][]byte{"rekor.pub": pub, "private-rekor.pub": pub})
+	_, otherRoot := tufRepo(t, map[string][]byte{"rekor.pub": pub})
+
+	const primary, additional = "https://rekor.internal.example.com", "https://rekor.example.com:8443"
+	tests := []struct {
+		name    string
+		url     string
+		files   map[string][]byte
+		tuf     config.TUFConfig
+		want    *ecdsa.PrivateKey
+		wantErr bool
+	}{{
+		name: "from the log",
+		want: logsKey,
+	}, {
+		name:  "public key in the secret",
+		files: map[string][]byte{RekorPublicKeyFile: pub},
+		want:  key,
+	}, {
+		name:  "TUF",
+		files: map[string][]byte{RekorTUFRootFile: root},
+		tuf:   config.TUFConfig{Mirror: mirror},
+		want:  key,
+	}, {
+		name:  "TUF target",
+		files: map[string][]byte{RekorTUFRootFile: root},
+		tuf:   config.TUFConfig{Mirror: mirror, Target: "private-rekor.pub"},
+		want:  key,
+	}, {
+		name:    "missing TUF target",
+		files:   map[string][]byte{RekorTUFRootFile: root},
+		tuf:     config.TUFConfig{Mirror: mirror, Target: "missing.pub"},
+		wantErr: true,
+	}, {
+		name:    "TUF repository with another root",
+		files:   map[string][]byte{RekorTUFRootFile: otherRoot},
+		tuf:     config.TUFConfig{Mirror: mirror},
+		wantErr: true,
+	}, {
+		name:    "TUF root without mirror",
+		files:   map[string][]byte{RekorTUFRootFile: root},
+		wantErr: true,
+	}, {
+		name:    "mirror without TUF root",
+		tuf:     config.TUFConfig{Mirror: mirror},
+		wantErr: true,
+	}, {
+		name:    "invalid public key",
+		files:   map[string][]byte{RekorPublicKeyFile: []byte("not a key")},
+		wantErr: true,
+	}, {
+		name:  "additional log's public key in the secret",
+		url:   additional,
+		files: map[string][]byte{RekorPublicKeyFile: []byte("not a key"), "rekor-rekor.example.com.pub": pub},
+		want:  key,
+	}, {
+		name:  "additional log without public key",
+		url:   additional,
+		files: map[string][]byte{RekorPublicKeyFile: pub, RekorTUFRootFile: root},
+		tuf:   config.TUFConfig{Mirror: mirror},
+		want:  logsKey,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, b := range tt.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c, err := rc.GetRekorClient(fakeLog(t, logsPub))
+			if err != nil {
+				t.Fatal(err)
+			}
+			url := tt.url
+			if url == "" {
+				url = primary
+			}
+			got, err := LogPublicKey(context.Background(), c, config.TransparencyConfig{URL: primary, TUF: tt.tuf}, url, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LogPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !got.Equal(tt.want.Public()) {
+				t.Error("LogPublicKey() returned the wrong key")
+			}
+		})
+	}
+}
+
+func TestLogPublicKeySigstore(t *testing.T) {
+	key, pub := logKey(t)
+	// The public instance is never asked for its key.
+	_, logsPub := logKey(t)
+	mirror, root := tufRepo(t, map[string][]byte{"rekor.pub": pub})
+	oldMirror, oldRoot := sigstoreTUFMirror, sigstoreTUFRoot
+	sigstoreTUFMirror = mirror
+	t.Cleanup(func() { sigstoreTUFMirror, sigstoreTUFRoot = oldMirror, oldRoot })
+	_, otherRoot := tufRepo(t, map[string][]byte{"rekor.pub": pub})
+
+	tests := []struct {
+		name    string
+		cfg     config.TransparencyConfig
+		url     string
+		root    []byte
+		wantErr bool
+	}{{
+		name: "public instance",
+		cfg:  config.TransparencyConfig{URL: SigstoreRekorURL},
+		url:  SigstoreRekorURL,
+		root: root,
+	}, {
+		name: "public instance with trailing slash",
+		cfg:  config.TransparencyConfig{URL: SigstoreRekorURL + "/"},
+		url:  SigstoreRekorURL + "/",
+		root: root,
+	}, {
+		name: "public instance as additional log",
+		cfg:  config.TransparencyConfig{URL: "https://rekor.internal.example.com"},
+		url:  SigstoreRekorURL,
+		root: root,
+	}, {
+		name:    "Sigstore TUF repository with another root",
+		cfg:     config.TransparencyConfig{URL: SigstoreRekorURL},
+		url:     SigstoreRekorURL,
+		root:    otherRoot,
+		wantErr: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sigstoreTUFRoot = func() ([]byte, error) { return tt.root, nil }
+			c, err := rc.GetRekorClient(fakeLog(t, logsPub))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := LogPublicKey(context.Background(), c, tt.cfg, tt.url, t.TempDir())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LogPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(key.Public()) {
+				t.Error("LogPublicKey() returned the wrong key")
+			}
+		})
+	}
+}
+
+func TestLogPublicKeyCached(t *testing.T) {
+	key, pub := logKey(t)
+	asked := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		asked++
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write(pub)
+	}))
+	defer s.Close()
+	c, err := rc.GetRekorClient(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.TransparencyConfig{URL: s.URL}
+	for i := 0; i < 3; i++ {
+		got, err := LogPublicKey(context.Background(), c, cfg, s.URL, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(key.Public()) {
+			t.Error("LogPublicKey() returned the wrong key")
+		}
+	}
+	// Each secret path is cached on its own.
+	if asked != 3 {
+		t.Errorf("public key asked of the log %d times, want 3", asked)
+	}
+
+	dir := t.TempDir()
+	for i := 0; i < 3; i++ {
+		if _, err := LogPublicKey(context.Background(), c, cfg, s.URL, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if asked != 4 {
+		t.Errorf("public key asked of the log %d times, want it cached after the 4th", asked)
+	}
+}
+
+func TestTufRootKeys(t *testing.T) {
+	_, root := tufRepo(t, map[string][]byte{"rekor.pub": []byte("key")})
+	keys, threshold, err := tufRootKeys(root)
+	if err != nil {
+		t.Fatalf("tufRootKeys() = %v", err)
+	}
+	if len(keys) != 1 || threshold != 1 {
+		t.Errorf("tufRootKeys() = %d keys, threshold %d, want 1 key, threshold 1", len(keys), threshold)
+	}
+	root, err = json.Marshal(map[string]string{"not": "a root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tufRootKeys(root); err == nil {
+		t.Error("expected error loading an invalid root")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sbom downloads the SBOMs tasks produce and wraps them in in-toto statements about
+// the images they describe, in the format `cosign attest --type` uses.
+package sbom
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/artifacts"
+)
+
+const (
+	// PredicateSPDX is the predicate type of SPDX SBOMs.
+	PredicateSPDX = "https://spdx.dev/Document"
+	// PredicateCycloneDX is the predicate type of CycloneDX SBOMs.
+	PredicateCycloneDX = "https://cyclonedx.org/bom"
+
+	// maxSize bounds the size of the SBOMs that are downloaded.
+	maxSize = 16 << 20
+)
+
+// Fetch downloads the SBOM and checks its digest.
+func Fetch(ctx context.Context, s artifacts.SBOM) ([]byte, error) {
+	parts := strings.SplitN(s.Digest, ":", 2)
+	if len(parts) != 2 || parts[0] != "sha256" {
+		return nil, fmt.Errorf("SBOM digest %s is not a sha256 digest", s.Digest)
+	}
+	if !strings.HasPrefix(s.URL, "https://") && !strings.HasPrefix(s.URL, "http://") {
+		return nil, fmt.Errorf("SBOM URL %s is not an HTTP URL", s.URL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "downloading SBOM %s", s.URL)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading SBOM %s: %s", s.URL, resp.Status)
+	}
+	doc, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "downloading SBOM %s", s.URL)
+	}
+	if len(doc) > maxSize {
+		return nil, fmt.Errorf("SBOM %s is larger than %d bytes", s.URL, maxSize)
+	}
+	sum := sha256.Sum256(doc)
+	if got := hex.EncodeToString(sum[:]); got != strings.ToLower(parts[1]) {
+		return nil, fmt.Errorf("SBOM %s has digest sha256:%s, not %s", s.URL, got, s.Digest)
+	}
+	return doc, nil
+}
+
+// NewStatement wraps an SPDX or CycloneDX JSON document in an in-toto statement about the
+// image the SBOM describes.
+func NewStatement(s artifacts.SBOM, doc []byte) (intoto.Statement, error) {
+	var fields struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return intoto.Statement{}, errors.Wrapf(err, "SBOM %s is not JSON", s.URL)
+	}
+	var predicateType string
+	switch {
+	case fields.SPDXVersion != "":
+		predicateType = PredicateSPDX
+	case fields.BOMFormat == "CycloneDX":
+		predicateType = PredicateCycloneDX
+	default:
+		return intoto.Statement{}, fmt.Errorf("SBOM %s is neither an SPDX nor a CycloneDX document", s.URL)
+	}
+	return intoto.Statement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: predicateType,
+			Subject: []intoto.Subject{{
+				Name:   s.Image.Repository.Name(),
+				Digest: slsa.DigestSet{"sha256": strings.TrimPrefix(s.Image.DigestStr(), "sha256:")},
+			}},
+		},
+		Predicate: json.RawMessage(doc),
+	}, nil
+}
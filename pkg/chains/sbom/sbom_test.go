@@ -0,0 +1,135 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sbom
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/tektoncd/chains/pkg/artifacts"
+)
+
+const imageDigest = "sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+
+func digestOf(doc string) string {
+	sum := sha256.Sum256([]byte(doc))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestFetch(t *testing.T) {
+	const doc = `{"spdxVersion": "SPDX-2.2"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sbom.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		sbom    artifacts.SBOM
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			sbom: artifacts.SBOM{URL: srv.URL + "/sbom.json", Digest: digestOf(doc)},
+		},
+		{
+			name:    "digest mismatch",
+			sbom:    artifacts.SBOM{URL: srv.URL + "/sbom.json", Digest: digestOf("other")},
+			wantErr: true,
+		},
+		{
+			name:    "not sha256",
+			sbom:    artifacts.SBOM{URL: srv.URL + "/sbom.json", Digest: "sha1:da39a3ee5e6b4b0d3255bfef95601890afd80709"},
+			wantErr: true,
+		},
+		{
+			name:    "not found",
+			sbom:    artifacts.SBOM{URL: srv.URL + "/missing.json", Digest: digestOf(doc)},
+			wantErr: true,
+		},
+		{
+			name:    "file path",
+			sbom:    artifacts.SBOM{URL: "/workspace/sbom.json", Digest: digestOf(doc)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Fetch(context.Background(), tt.sbom)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != doc {
+				t.Errorf("Fetch() = %s, want %s", got, doc)
+			}
+		})
+	}
+}
+
+func TestNewStatement(t *testing.T) {
+	img := name.MustParseReference("gcr.io/foo/bar@" + imageDigest).(name.Digest)
+	tests := []struct {
+		name          string
+		doc           string
+		wantPredicate string
+		wantErr       bool
+	}{
+		{
+			name:          "spdx",
+			doc:           `{"spdxVersion": "SPDX-2.2", "name": "bar"}`,
+			wantPredicate: PredicateSPDX,
+		},
+		{
+			name:          "cyclonedx",
+			doc:           `{"bomFormat": "CycloneDX", "specVersion": "1.4"}`,
+			wantPredicate: PredicateCycloneDX,
+		},
+		{
+			name:    "unknown format",
+			doc:     `{"foo": "bar"}`,
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			doc:     `SPDXVersion: SPDX-2.2`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewStatement(artifacts.SBOM{URL: "https://example.com/sbom", Image: img}, []byte(tt.doc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewStatement() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.PredicateType != tt.wantPredicate {
+				t.Errorf("PredicateType = %s, want %s", got.PredicateType, tt.wantPredicate)
+			}
+			if len(got.Subject) != 1 || got.Subject[0].Name != "gcr.io/foo/bar" || got.Subject[0].Digest["sha256"] != imageDigest[len("sha256:"):] {
+				t.Errorf("Subject = %v, want gcr.io/foo/bar@%s", got.Subject, imageDigest)
+			}
+		})
+	}
+}
@@ -16,25 +16,52 @@ package chains
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/hashicorp/go-multierror"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature/options"
 	"github.com/tektoncd/chains/pkg/artifacts"
+	"github.com/tektoncd/chains/pkg/chains/bundle"
+	"github.com/tektoncd/chains/pkg/chains/cloudevents"
+	"github.com/tektoncd/chains/pkg/chains/deadletter"
+	"github.com/tektoncd/chains/pkg/chains/definitions"
 	"github.com/tektoncd/chains/pkg/chains/formats"
 	"github.com/tektoncd/chains/pkg/chains/formats/intotoite6"
 	"github.com/tektoncd/chains/pkg/chains/formats/provenance"
 	"github.com/tektoncd/chains/pkg/chains/formats/simple"
 	"github.com/tektoncd/chains/pkg/chains/formats/tekton"
+	"github.com/tektoncd/chains/pkg/chains/logs"
+	"github.com/tektoncd/chains/pkg/chains/redact"
+	"github.com/tektoncd/chains/pkg/chains/sbom"
 	"github.com/tektoncd/chains/pkg/chains/signing"
 	"github.com/tektoncd/chains/pkg/chains/signing/kms"
 	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/chains/source"
 	"github.com/tektoncd/chains/pkg/chains/storage"
+	"github.com/tektoncd/chains/pkg/chains/storage/oci"
+	"github.com/tektoncd/chains/pkg/chains/vsa"
 	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/chains/pkg/metrics"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 )
 
@@ -46,17 +73,28 @@ type TaskRunSigner struct {
 	KubeClient        kubernetes.Interface
 	Pipelineclientset versioned.Interface
 	SecretPath        string
+	// TlogQueue uploads entries in the background when transparency.async is set. Without
+	// one, entries are always uploaded before the payloads are stored.
+	TlogQueue *TlogQueue
+	// PayloadCache keeps the payloads signed for TaskRuns that are retried. Without one, they
+	// are signed again on every attempt.
+	PayloadCache *PayloadCache
 }
 
 // Set this as a var for mocking.
 var getBackends = storage.InitializeBackends
 
-func allSigners(sp string, cfg config.Config, l *zap.SugaredLogger) map[string]signing.Signer {
+// Set this as a var for mocking.
+var getSigners = allSigners
+
+// allSigners creates the signers, whose x509 keys are read from keys. identity, if set, returns
+// the tokens of the identity Fulcio certificates are requested for, with the serviceaccount auth.
+func allSigners(keys x509.Keys, cfg config.Config, identity x509.IdentityToken, l *zap.SugaredLogger) map[string]signing.Signer {
 	all := map[string]signing.Signer{}
 	for _, s := range signing.AllSigners {
 		switch s {
 		case signing.TypeX509:
-			signer, err := x509.NewSigner(sp, cfg, l)
+			signer, err := x509.NewSignerWithKeys(keys, "", cfg, identity, l)
 			if err != nil {
 				l.Warnf("error configuring x509 signer: %s", err)
 				continue
@@ -74,9 +112,49 @@ func allSigners(sp string, cfg config.Config, l *zap.SugaredLogger) map[string]s
 			l.Panicf("unsupported signer: %s", s)
 		}
 	}
+	// Only the profiles in use are loaded, since each may call out to a KMS.
+	for _, name := range profilesInUse(cfg) {
+		signer, err := newProfileSigner(keys, cfg, name, identity, l)
+		if err != nil {
+			l.Warnf("error configuring signer profile %s: %s", name, err)
+			continue
+		}
+		all[name] = signer
+	}
 	return all
 }
 
+// profilesInUse returns the names of the signer profiles the artifacts are signed with. The
+// countersigner is created by newCountersigner.
+func profilesInUse(cfg config.Config) []string {
+	used := sets.NewString()
+	for _, s := range []string{cfg.Artifacts.TaskRuns.Signer, cfg.Artifacts.OCI.Signer, cfg.Artifacts.Blobs.Signer} {
+		if p, _ := cfg.ResolveSigner(s); p.Name != "" {
+			used.Insert(p.Name)
+		}
+	}
+	return used.List()
+}
+
+// newProfileSigner creates the signer of a signer profile.
+func newProfileSigner(keys x509.Keys, cfg config.Config, name string, identity x509.IdentityToken, l *zap.SugaredLogger) (signing.Signer, error) {
+	p, cfg := cfg.ResolveSigner(name)
+	switch p.Type {
+	case signing.TypeX509:
+		return x509.NewSignerWithKeys(keys, p.KeyPrefix(), cfg, identity, l)
+	case signing.TypeKMS:
+		return kms.NewSigner(cfg.Signers.KMS, l)
+	}
+	return nil, fmt.Errorf("unsupported signer: %s", p.Type)
+}
+
+// newCountersigner creates the countersigner, whose x509 keys are always those of the signing
+// secret mounted at secretPath. The keys in the namespace of a TaskRun are its tenant's, who
+// would otherwise hold the keys of both signatures.
+func newCountersigner(secretPath string, cfg config.Config, identity x509.IdentityToken, l *zap.SugaredLogger) (signing.Signer, error) {
+	return newProfileSigner(x509.KeysIn(secretPath), cfg, cfg.Signers.Countersigner, identity, l)
+}
+
 func allFormatters(cfg config.Config, l *zap.SugaredLogger) map[formats.PayloadType]formats.Payloader {
 	all := map[formats.PayloadType]formats.Payloader{}
 
@@ -112,37 +190,164 @@ func allFormatters(cfg config.Config, l *zap.SugaredLogger) map[formats.PayloadT
 	return all
 }
 
+// CreatePayload formats obj in the given format, the way it is formatted before it is signed.
+func CreatePayload(cfg config.Config, format formats.PayloadType, obj interface{}, l *zap.SugaredLogger) (interface{}, error) {
+	payloader, ok := allFormatters(cfg, l)[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+	return payloader.CreatePayload(obj)
+}
+
 // SignTaskRun signs a TaskRun, and marks it as signed.
+// If it takes longer than the configured signing timeout, the signers and storage backends
+// are cancelled, and once they returned the TaskRun is marked for retry.
 func (ts *TaskRunSigner) SignTaskRun(ctx context.Context, tr *v1beta1.TaskRun) error {
+	ctx = metrics.WithNamespace(ctx, tr.Namespace)
+	cfg := *config.FromContext(ctx)
+	timeout := cfg.Signing.Timeout
+	if timeout <= 0 {
+		return ts.signTaskRun(ctx, tr)
+	}
+	signCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// Signing is waited for, so that it's done with the TaskRun before it's marked for retry
+	// and can be signed again.
+	err := ts.signTaskRun(signCtx, tr)
+	if err == nil || signCtx.Err() != context.DeadlineExceeded || ctx.Err() != nil {
+		return err
+	}
+
+	err = fmt.Errorf("signing taskrun %s/%s timed out after %s", tr.Namespace, tr.Name, timeout)
+	recordWarning(ctx, tr, EventReasonSigningTimeout, "Signing timed out after %s", timeout)
+	return ts.failSigning(ctx, cfg, tr, nil, EventReasonSigningTimeout, err)
+}
+
+// failSigning records why signing the TaskRun failed, along with any extra annotations, and
+// marks it for a retry, or as failed once it has run out of retries.
+func (ts *TaskRunSigner) failSigning(ctx context.Context, cfg config.Config, tr *v1beta1.TaskRun, extraAnnotations map[string]string, reason string, err error) error {
+	// Nothing is recorded once ctx is done: SignTaskRun marks signing that timed out for
+	// retry, and signing cancelled by a shutdown is reconciled again.
+	if ctx.Err() != nil {
+		return err
+	}
+	annotations := WithLastError(extraAnnotations, err)
+	annotations[FailureReasonAnnotation] = reason
+	if !RetryAvailable(tr) {
+		reportDeadLetter(ctx, cfg, tr, reason, err)
+		ts.PayloadCache.forget(tr.UID)
+	}
+	if rerr := HandleRetry(tr, ts.Pipelineclientset, annotations); rerr != nil {
+		return multierror.Append(err, rerr)
+	}
+	return err
+}
+
+func (ts *TaskRunSigner) signTaskRun(ctx context.Context, tr *v1beta1.TaskRun) error {
 	// Get all the things we might need (storage backends, signers and formatters)
 	cfg := *config.FromContext(ctx)
-	logger := logging.FromContext(ctx)
+	logger := logs.For(logging.FromContext(ctx), logs.Signing).With(logs.TaskRunFields(tr)...)
+	ctx = logging.WithLogger(ctx, logger)
 
 	// TODO: Hook this up to config.
 	enabledSignableTypes := []artifacts.Signable{
 		&artifacts.TaskRunArtifact{Logger: logger},
 		&artifacts.OCIArtifact{Logger: logger},
 	}
+	if cfg.Artifacts.Blobs.StorageBackend != "" {
+		enabledSignableTypes = append(enabledSignableTypes, &artifacts.BlobArtifact{Logger: logger})
+	}
+
+	if err := MarkSigning(tr, ts.Pipelineclientset); err != nil {
+		return err
+	}
 
 	// Storage
-	allBackends, err := getBackends(ts.Pipelineclientset, ts.KubeClient, logger, tr, cfg)
+	allBackends, err := getBackends(ts.Pipelineclientset, ts.KubeClient, logs.For(logger, logs.Storage), tr, cfg)
 	if err != nil {
 		return err
 	}
 
-	signers := allSigners(ts.SecretPath, cfg, logger)
+	// Dry runs never touch the signers, which may call out to a KMS.
+	signers := map[string]signing.Signer{}
+	var clusterCountersigner signing.Signer
+	if !cfg.DryRun.Enabled {
+		keys, err := signingKeys(ctx, ts.KubeClient, ts.SecretPath, cfg, tr)
+		if err != nil {
+			return err
+		}
+		signers = getSigners(keys, cfg, ts.serviceAccountIdentity(tr), logger)
+		if name := cfg.Signers.Countersigner; name != "" {
+			cs, err := newCountersigner(ts.SecretPath, cfg, ts.serviceAccountIdentity(tr), logger)
+			if err != nil {
+				logger.Warnf("error configuring countersigner %s: %s", name, err)
+			} else {
+				clusterCountersigner = cs
+			}
+		}
+	}
 	allFormats := allFormatters(cfg, logger)
 
-	rekorClient, err := getRekor(cfg.Transparency.URL, logger)
-	if err != nil {
-		return err
+	var tlogs []transparencyLog
+	for _, url := range cfg.Transparency.URLs() {
+		c, err := getRekor(cfg.Transparency, url, ts.SecretPath, logs.For(logger, logs.Transparency))
+		if err != nil {
+			return err
+		}
+		tlogs = append(tlogs, transparencyLog{url: url, client: c})
+	}
+
+	var scanner *redact.Scanner
+	if cfg.Redact.Enabled {
+		if scanner, err = redact.NewScanner(cfg.Redact); err != nil {
+			return err
+		}
 	}
 
 	var merr *multierror.Error
 	extraAnnotations := map[string]string{}
+	// The annotation is set from the definitions resolved here, never trusted from the TaskRun.
+	tr = tr.DeepCopy()
+	if err := ts.recordDefinitions(ctx, cfg, tr); err != nil {
+		if !cfg.Definitions.Required {
+			logger.Warnf("Not recording the definitions of TaskRun %s/%s: %v", tr.Namespace, tr.Name, err)
+		} else {
+			logger.Error(err)
+			recordWarning(ctx, tr, EventReasonDefinitionsUnverified, "%v", err)
+			return ts.failSigning(ctx, cfg, tr, nil, EventReasonDefinitionsUnverified, err)
+		}
+	}
+	if value, ok := tr.Annotations[definitions.Annotation]; ok {
+		extraAnnotations[definitions.Annotation] = value
+	}
+	// Problems with some images don't stop the other images and the TaskRun being signed.
+	if err := ts.recordImages(ctx, cfg, tr); err != nil {
+		logger.Errorf("Not signing some images of TaskRun %s/%s: %v", tr.Namespace, tr.Name, err)
+		recordWarning(ctx, tr, EventReasonInvalidImages, "Not signing some images: %v", err)
+	}
+	if value, ok := tr.Annotations[artifacts.ResolvedImagesAnnotation]; ok {
+		extraAnnotations[artifacts.ResolvedImagesAnnotation] = value
+	}
+	ts.recordPod(ctx, tr)
+	for _, a := range []string{formats.NodeNameAnnotation, formats.BuilderAnnotation, formats.IsolationAnnotation} {
+		if value, ok := tr.Annotations[a]; ok {
+			extraAnnotations[a] = value
+		}
+	}
+	attestations := []cloudevents.Attestation{}
+	tlogEntries := []TransparencyEntry{}
+	// With an asynchronous upload, payloads are stored without waiting for the logs, and the
+	// uploads are queued once the TaskRun is marked as signed.
+	async := cfg.Transparency.Async && ts.TlogQueue != nil
+	var tlogJobs []*tlogJob
+	var pendingUploads []PendingUpload
+	var redacted []string
+	failureReason := ""
 	for _, signableType := range enabledSignableTypes {
 
 		payloadFormat := signableType.PayloadFormat(cfg)
+		logger := logger.With(logs.PayloadFormatKey, string(payloadFormat))
+		tlogLogger := logs.For(logger, logs.Transparency)
 		// Find the right payload format and format the object
 		payloader, ok := allFormats[payloadFormat]
 
@@ -157,79 +362,717 @@ func (ts *TaskRunSigner) SignTaskRun(ctx context.Context, tr *v1beta1.TaskRun) e
 
 		// Go through each object one at a time.
 		for _, obj := range objects {
-
-			payload, err := payloader.CreatePayload(obj)
-			if err != nil {
-				logger.Error(err)
-				continue
-			}
-			logger.Infof("Created payload of type %s for TaskRun %s/%s", string(payloadFormat), tr.Namespace, tr.Name)
-
-			// Sign it!
 			signerType := signableType.Signer(cfg)
-			signer, ok := signers[signerType]
-			if !ok {
-				logger.Warnf("No signer %s configured for %s", signerType, signableType.Type())
-				continue
+			// A payload signed on an earlier attempt is stored again as it was.
+			cacheKey := strings.Join([]string{VersionedKey(tr, signableType.Key(obj)), string(payloadFormat), signerType}, "/")
+			// Images pushed to several registries share a key, but not a payload.
+			if d, ok := obj.(name.Digest); ok {
+				cacheKey += "/" + d.Name()
+			}
+			var cached *signedPayload
+			if !cfg.DryRun.Enabled {
+				cached = ts.PayloadCache.get(tr.UID, cacheKey)
 			}
 
-			if payloader.Wrap() {
-				wrapped, err := signing.Wrap(ctx, signer)
+			var payload interface{}
+			var rawPayload, signature, countersignature []byte
+			var signer, countersigner signing.Signer
+			var findings []redact.Finding
+			if cached != nil {
+				logger.Infof("Reusing the payload of type %s signed on an earlier attempt for TaskRun %s/%s", string(payloadFormat), tr.Namespace, tr.Name)
+				payload, rawPayload, signature, signer, findings = cached.payload, cached.rawPayload, cached.signature, cached.signer, cached.redacted
+				countersignature, countersigner = cached.countersignature, cached.countersigner
+			} else {
+				var err error
+				payload, err = payloader.CreatePayload(obj)
+				if err != nil {
+					logger.Error(err)
+					recordWarning(ctx, tr, EventReasonSigningFailed, "Failed to create %s payload: %v", payloadFormat, err)
+					merr = multierror.Append(merr, errors.Wrapf(err, "creating %s payload", payloadFormat))
+					failureReason = EventReasonSigningFailed
+					continue
+				}
+				logger.Infof("Created payload of type %s for TaskRun %s/%s", string(payloadFormat), tr.Namespace, tr.Name)
+
+				if cfg.DryRun.Enabled {
+					for _, backendType := range cfg.Storage.StorageBackends(string(payloadFormat), signableType.StorageBackend(cfg)) {
+						b, ok := allBackends[backendType]
+						if !ok {
+							err := fmt.Errorf("storage backend %q is not configured", backendType)
+							logger.Error(err)
+							merr = multierror.Append(merr, err)
+							failureReason = EventReasonStorageFailed
+							continue
+						}
+						if err := storeDryRun(ctx, b, tr, payload, string(payloadFormat), VersionedKey(tr, signableType.Key(obj))); err != nil {
+							logger.Error(err)
+							merr = multierror.Append(merr, err)
+							failureReason = EventReasonStorageFailed
+						}
+					}
+					continue
+				}
+
+				// Sign it!
+				var ok bool
+				signer, ok = signers[signerType]
+				if !ok {
+					err := fmt.Errorf("no signer %s configured for %s", signerType, signableType.Type())
+					logger.Error(err)
+					recordWarning(ctx, tr, EventReasonSigningFailed, "Failed to sign %s payload: %v", payloadFormat, err)
+					merr = multierror.Append(merr, err)
+					failureReason = EventReasonSigningFailed
+					continue
+				}
+
+				if payloader.Wrap() {
+					wrapped, err := signing.Wrap(ctx, signer)
+					if err != nil {
+						return err
+					}
+					logger.Infof("Using wrapped envelope signer for %s", payloader.Type())
+					signer = wrapped
+				}
+
+				logger.Infof("Signing object with %s", signerType)
+				rawPayload, err = json.Marshal(payload)
 				if err != nil {
-					return err
+					err = errors.Wrapf(err, "marshalling %s payload", payloadFormat)
+					logger.Error(err)
+					recordWarning(ctx, tr, EventReasonSigningFailed, "Failed to sign %s payload: %v", payloadFormat, err)
+					merr = multierror.Append(merr, err)
+					failureReason = EventReasonSigningFailed
+					continue
+				}
+
+				// Secrets are taken out before the payload is signed and published.
+				if scanner != nil {
+					rawPayload, findings, err = scanner.Redact(rawPayload)
+					if err != nil {
+						return ts.failSigning(ctx, cfg, tr, extraAnnotations, EventReasonSigningFailed, errors.Wrapf(err, "scanning %s payload for secrets", payloadFormat))
+					}
+					if len(findings) > 0 && cfg.Redact.Action == config.RedactActionFail {
+						err := fmt.Errorf("%s payload of TaskRun %s/%s has secrets in %s", payloadFormat, tr.Namespace, tr.Name, redact.Paths(findings))
+						logger.Error(err)
+						recordWarning(ctx, tr, EventReasonSecretsFound, "Not signing %s payload with secrets in %s", payloadFormat, redact.Paths(findings))
+						return ts.failSigning(ctx, cfg, tr, extraAnnotations, EventReasonSecretsFound, err)
+					}
+					if len(findings) > 0 {
+						logger.Warnf("Redacted secrets in %s from the %s payload", redact.Paths(findings), payloadFormat)
+						recordWarning(ctx, tr, EventReasonSecretsRedacted, "Redacted secrets in %s from %s payload", redact.Paths(findings), payloadFormat)
+					}
+				}
+
+				start := time.Now()
+				signature, err = signer.SignMessage(bytes.NewReader(rawPayload), options.WithContext(ctx))
+				if err != nil {
+					logger.Error(err)
+					for _, backendType := range cfg.Storage.StorageBackends(string(payloadFormat), signableType.StorageBackend(cfg)) {
+						metrics.RecordFailed(ctx, string(payloadFormat), backendType)
+					}
+					recordWarning(ctx, tr, EventReasonSigningFailed, "Failed to sign %s payload with %s signer: %v", payloadFormat, signerType, err)
+					merr = multierror.Append(merr, errors.Wrapf(err, "signing %s payload with %s signer", payloadFormat, signerType))
+					failureReason = EventReasonSigningFailed
+					continue
+				}
+				metrics.RecordSigningLatency(ctx, signerType, time.Since(start))
+
+				// Envelopes are countersigned before anything is stored, so none is stored with
+				// a single signature.
+				if name := cfg.Signers.Countersigner; name != "" && payloader.Wrap() {
+					countersigner = clusterCountersigner
+					if countersigner != nil {
+						countersignature, err = signing.Countersign(countersigner, signature, options.WithContext(ctx))
+					} else {
+						err = fmt.Errorf("countersigner %s is not configured", name)
+					}
+					if err != nil {
+						logger.Error(err)
+						for _, backendType := range cfg.Storage.StorageBackends(string(payloadFormat), signableType.StorageBackend(cfg)) {
+							metrics.RecordFailed(ctx, string(payloadFormat), backendType)
+						}
+						recordWarning(ctx, tr, EventReasonSigningFailed, "Failed to countersign %s payload with %s signer: %v", payloadFormat, name, err)
+						merr = multierror.Append(merr, errors.Wrapf(err, "countersigning %s payload", payloadFormat))
+						failureReason = EventReasonSigningFailed
+						continue
+					}
 				}
-				logger.Infof("Using wrapped envelope signer for %s", payloader.Type())
-				signer = wrapped
 			}
 
-			logger.Infof("Signing object with %s", signerType)
-			rawPayload, err := json.Marshal(payload)
-			if err != nil {
-				logger.Warnf("Unable to marshal payload: %v", signerType, obj)
-				continue
+			// Upload to the transparency logs first, so the entry in the first can be stored with
+			// the signature. Every log must take the entry: one that doesn't fails the signing,
+			// and the logs that did take it return the same entry when it's uploaded again.
+			var bundle *config.TlogBundle
+			var uploaded []tlogUpload
+			if shouldUploadFormat(cfg, tr, string(payloadFormat)) && !async {
+				for i, tlog := range tlogs {
+					if u := cached.uploadedTo(tlog.url); u != nil {
+						if i == 0 {
+							bundle = u.bundle
+							extraAnnotations[ChainsTransparencyAnnotation] = fmt.Sprintf("%s/api/v1/log/entries?logIndex=%d", tlog.url, u.bundle.LogIndex)
+						}
+						uploaded = append(uploaded, *u)
+						continue
+					}
+					start := time.Now()
+					b, err := tlog.client.UploadTlog(ctx, signer, signature, rawPayload, signer.Cert(), cfg.Transparency.EntryType(string(payloadFormat)))
+					metrics.RecordTransparencyLatency(ctx, time.Since(start))
+					if err != nil {
+						tlogLogger.Error(err)
+						merr = multierror.Append(merr, errors.Wrapf(err, "uploading to %s", tlog.url))
+						recordWarning(ctx, tr, EventReasonTransparencyFailed, "Failed to upload %s payload to transparency log %s: %v", payloadFormat, tlog.url, err)
+						failureReason = EventReasonTransparencyFailed
+						continue
+					}
+					tlogLogger.Infof("Uploaded entry to %s with index %d", tlog.url, b.LogIndex)
+					if i == 0 {
+						bundle = b
+						extraAnnotations[ChainsTransparencyAnnotation] = fmt.Sprintf("%s/api/v1/log/entries?logIndex=%d", tlog.url, b.LogIndex)
+					}
+					uploaded = append(uploaded, tlogUpload{url: tlog.url, bundle: b})
+				}
 			}
 
-			signature, err := signer.SignMessage(bytes.NewReader(rawPayload))
-			if err != nil {
-				logger.Error(err)
-				continue
+			ts.PayloadCache.put(tr.UID, cacheKey, &signedPayload{
+				payload:          payload,
+				rawPayload:       rawPayload,
+				signature:        signature,
+				signer:           signer,
+				countersignature: countersignature,
+				countersigner:    countersigner,
+				uploaded:         uploaded,
+				redacted:         findings,
+			})
+			for _, f := range findings {
+				redacted = append(redacted, fmt.Sprintf("%s:%s", payloadFormat, f.Path))
+			}
+			if len(redacted) > 0 {
+				extraAnnotations[RedactedAnnotation] = strings.Join(sets.NewString(redacted...).List(), ",")
 			}
 
-			// Now store those!
-			b := allBackends[signableType.StorageBackend(cfg)]
+			// Now store those, in each backend of the format!
 			storageOpts := config.StorageOpts{
-				Key:           signableType.Key(obj),
+				Key:           VersionedKey(tr, signableType.Key(obj)),
 				Cert:          signer.Cert(),
 				Chain:         signer.Chain(),
 				PayloadFormat: string(payloadFormat),
+				Bundle:        bundle,
 			}
-			if err := b.StorePayload(rawPayload, string(signature), storageOpts); err != nil {
-				logger.Error(err)
-				merr = multierror.Append(merr, err)
+			var entries []TransparencyEntry
+			var rekorLogIndex *int64
+			var rekorUUID string
+			for _, u := range uploaded {
+				e, err := newTransparencyEntry(u.url, storageOpts.Key, u.bundle)
+				if err != nil {
+					logger.Warnf("Not recording entry %d of transparency log %s: %v", u.bundle.LogIndex, u.url, err)
+					continue
+				}
+				if u.bundle == bundle {
+					logIndex := bundle.LogIndex
+					rekorLogIndex, rekorUUID = &logIndex, e.UUID
+				}
+				entries = append(entries, e)
 			}
-
-			if shouldUploadTlog(cfg, tr) {
-				entry, err := rekorClient.UploadTlog(ctx, signer, signature, rawPayload, signer.Cert(), string(payloadFormat))
+			storedIn := 0
+			// The backend the payload can be read back from, if it's queued for upload.
+			readBack := ""
+			for _, backendType := range cfg.Storage.StorageBackends(string(payloadFormat), signableType.StorageBackend(cfg)) {
+				b, ok := allBackends[backendType]
+				if !ok {
+					err := fmt.Errorf("storage backend %q is not configured", backendType)
+					logger.Error(err)
+					merr = multierror.Append(merr, err)
+					recordWarning(ctx, tr, EventReasonStorageFailed, "Failed to store %s payload: %v", payloadFormat, err)
+					failureReason = EventReasonStorageFailed
+					continue
+				}
+				err := b.StorePayload(ctx, rawPayload, string(signature), storageOpts)
+				if err := recordOCITargets(extraAnnotations, storage.Targets(b)); err != nil {
+					logger.Warnf("Not recording the OCI targets: %v", err)
+				}
 				if err != nil {
 					logger.Error(err)
 					merr = multierror.Append(merr, err)
-				} else {
-					logger.Infof("Uploaded entry to %s with index %d", cfg.Transparency.URL, *entry.LogIndex)
+					metrics.RecordFailed(ctx, string(payloadFormat), b.Type())
+					recordWarning(ctx, tr, EventReasonStorageFailed, "Failed to store %s payload in %s backend: %v", payloadFormat, b.Type(), err)
+					failureReason = EventReasonStorageFailed
+					continue
+				}
+				storedIn++
+				if readBack == "" && b.Type() != oci.StorageBackendOCI {
+					readBack = b.Type()
+				}
+				metrics.RecordSigned(ctx, string(payloadFormat), b.Type())
+				stored := newAttestation(obj, string(payloadFormat), b.Type(), storageOpts.Key)
+				stored.RekorLogIndex, stored.RekorUUID = rekorLogIndex, rekorUUID
+				attestations = append(attestations, stored)
+
+				if provenance, ok := payload.(intoto.ProvenanceStatement); ok && cfg.VSA.Enabled {
+					if err := signVSA(ctx, cfg, tr, b, signer, provenance, storageOpts, signature); err != nil {
+						logger.Error(err)
+						merr = multierror.Append(merr, err)
+						failureReason = EventReasonStorageFailed
+					}
+				}
 
-					extraAnnotations[ChainsTransparencyAnnotation] = fmt.Sprintf("%s/api/v1/log/entries?logIndex=%d", cfg.Transparency.URL, *entry.LogIndex)
+				// Registries only take the envelope.
+				if countersignature != nil && b.Type() != oci.StorageBackendOCI {
+					if err := storeCountersignature(ctx, tr, b, countersigner, signature, countersignature, storageOpts); err != nil {
+						logger.Error(err)
+						merr = multierror.Append(merr, err)
+						failureReason = EventReasonStorageFailed
+					}
+				}
+
+				// The oci backend attaches the transparency log entry to the signatures it pushes.
+				if cfg.Bundle.Enabled && b.Type() != oci.StorageBackendOCI {
+					if err := storeBundle(ctx, tr, b, rawPayload, signature, payloader.Wrap(), storageOpts); err != nil {
+						logger.Error(err)
+						merr = multierror.Append(merr, err)
+						failureReason = EventReasonStorageFailed
+					}
+				}
+			}
+			if storedIn == 0 {
+				continue
+			}
+			tlogEntries = append(tlogEntries, entries...)
+			if async && shouldUploadFormat(cfg, tr, string(payloadFormat)) {
+				for i, tlog := range tlogs {
+					upload := PendingUpload{
+						Key:       storageOpts.Key,
+						URL:       tlog.url,
+						Primary:   i == 0,
+						EntryType: cfg.Transparency.EntryType(string(payloadFormat)),
+						Signer:    signerType,
+						Storage:   readBack,
+					}
+					tlogJobs = append(tlogJobs, &tlogJob{
+						taskRun:    types.NamespacedName{Namespace: tr.Namespace, Name: tr.Name},
+						upload:     upload,
+						primary:    upload.Primary,
+						client:     tlog.client,
+						signer:     signer,
+						signature:  signature,
+						rawPayload: rawPayload,
+						cert:       signer.Cert(),
+						entryType:  upload.EntryType,
+						recorder:   controller.GetEventRecorder(ctx),
+						logFields:  append(logs.TaskRunFields(tr), logs.PayloadFormatKey, string(payloadFormat)),
+					})
+					pendingUploads = append(pendingUploads, upload)
 				}
 			}
 		}
 		if merr.ErrorOrNil() != nil {
-			if err := HandleRetry(tr, ts.Pipelineclientset, extraAnnotations); err != nil {
-				merr = multierror.Append(merr, err)
+			// The entries in the logs that took them are recorded even when another log didn't.
+			if err := recordTransparencyEntries(extraAnnotations, tlogEntries); err != nil {
+				logger.Warnf("Not recording transparency log entries: %v", err)
 			}
-			return merr
+			return ts.failSigning(ctx, cfg, tr, extraAnnotations, failureReason, merr)
+		}
+	}
+	if err := recordTransparencyEntries(extraAnnotations, tlogEntries); err != nil {
+		return err
+	}
+
+	if cfg.SBOM.Enabled && !cfg.DryRun.Enabled {
+		if err := ts.signSBOMs(ctx, cfg, tr, allBackends[oci.StorageBackendOCI], signers); err != nil {
+			logger.Error(err)
+			return ts.failSigning(ctx, cfg, tr, extraAnnotations, EventReasonSBOMFailed, err)
+		}
+	}
+
+	if cfg.Source.Enabled && !cfg.DryRun.Enabled {
+		if err := ts.signSource(ctx, cfg, tr, allBackends, signers); err != nil {
+			logger.Error(err)
+			return ts.failSigning(ctx, cfg, tr, extraAnnotations, EventReasonStorageFailed, err)
+		}
+	}
+
+	// Nor is the TaskRun marked as signed.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if cfg.DryRun.Enabled {
+		if err := MarkDryRun(tr, ts.Pipelineclientset, extraAnnotations); err != nil {
+			return err
 		}
+		recordEvent(ctx, tr, corev1.EventTypeNormal, EventReasonDryRun, "Payloads stored unsigned in dry-run mode")
+		return nil
+	}
+
+	if len(tlogJobs) > 0 {
+		if err := recordPendingUploads(extraAnnotations, pendingUploads); err != nil {
+			return err
+		}
+		ts.TlogQueue.track(tlogJobs)
 	}
 
 	// Now mark the TaskRun as signed
-	return MarkSigned(tr, ts.Pipelineclientset, extraAnnotations)
+	if err := MarkSigned(tr, ts.Pipelineclientset, extraAnnotations); err != nil {
+		if len(tlogJobs) > 0 {
+			ts.TlogQueue.untrack(tlogJobs...)
+		}
+		return err
+	}
+	ts.PayloadCache.forget(tr.UID)
+	recordEvent(ctx, tr, corev1.EventTypeNormal, EventReasonSigned, "TaskRun signed")
+	if len(tlogJobs) > 0 {
+		ts.TlogQueue.start(tlogJobs)
+		logger.Infof("Queued %d transparency log uploads for TaskRun %s/%s", len(tlogJobs), tr.Namespace, tr.Name)
+	}
+
+	if cfg.Events.Sink != "" {
+		// Downstream consumers are notified on a best effort basis, this doesn't fail the signing.
+		if err := cloudevents.NewClient(cfg.Events.Sink).SendSigned(ctx, tr, signedEventID(tr), attestations); err != nil {
+			logger.Warnf("error sending cloudevent for TaskRun %s/%s: %v", tr.Namespace, tr.Name, err)
+		}
+	}
+	return nil
+}
+
+// Set these as vars for mocking.
+var (
+	resolveDefinitions = definitions.Resolve
+	verifyDefinitions  = definitions.Verify
+)
+
+// recordDefinitions resolves the bundles the Task and Pipeline of the TaskRun came from, and
+// verifies them when that is configured. It records them in an annotation on tr for the
+// in-toto and tekton-provenance formatters.
+// It fails when a definition isn't verified and verification is required.
+func (ts *TaskRunSigner) recordDefinitions(ctx context.Context, cfg config.Config, tr *v1beta1.TaskRun) error {
+	delete(tr.Annotations, definitions.Annotation)
+	var results []definitions.Result
+	var err error
+	if cfg.Definitions.Verify {
+		results, err = verifyDefinitions(ctx, cfg, ts.KubeClient, ts.Pipelineclientset, tr)
+	} else {
+		results, err = resolveDefinitions(ctx, ts.KubeClient, ts.Pipelineclientset, tr)
+	}
+	if err != nil {
+		return errors.Wrap(err, "resolving definitions")
+	}
+	if len(results) > 0 {
+		value, err := definitions.Encode(results)
+		if err != nil {
+			return err
+		}
+		if tr.Annotations == nil {
+			tr.Annotations = map[string]string{}
+		}
+		tr.Annotations[definitions.Annotation] = value
+	}
+	if cfg.Definitions.Required && !definitions.Verified(results) {
+		var unverified []string
+		for _, r := range results {
+			if r.Resolver != definitions.ResolverCluster && !r.Verified {
+				unverified = append(unverified, fmt.Sprintf("%s %s from %s", r.Kind, r.Name, r.Bundle))
+			}
+		}
+		return fmt.Errorf("%s of TaskRun %s/%s not signed by %s", strings.Join(unverified, ", "), tr.Namespace, tr.Name, cfg.Definitions.Key)
+	}
+	return nil
+}
+
+// Set this as a var for mocking.
+var resolveImage = func(ctx context.Context, kc kubernetes.Interface, tr *v1beta1.TaskRun, tag name.Tag) (name.Digest, error) {
+	keychain := authn.DefaultKeychain
+	if kc != nil {
+		var err error
+		keychain, err = k8schain.New(ctx, kc, k8schain.Options{Namespace: tr.Namespace, ServiceAccountName: tr.Spec.ServiceAccountName})
+		if err != nil {
+			return name.Digest{}, errors.Wrap(err, "creating keychain")
+		}
+	}
+	desc, err := remote.Head(tag, remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx))
+	if err != nil {
+		return name.Digest{}, err
+	}
+	return tag.Context().Digest(desc.Digest.String()), nil
+}
+
+// recordImages checks the images the TaskRun reported. When that is configured, it looks up
+// the digests of the images reported without one, in the IMAGES result or by an *IMAGE_URL
+// result without an *IMAGE_DIGEST result, with the TaskRun's service account. It records
+// them, with their tags, in an annotation on tr for the signables and formatters.
+// It returns every image that won't be signed.
+func (ts *TaskRunSigner) recordImages(ctx context.Context, cfg config.Config, tr *v1beta1.TaskRun) error {
+	delete(tr.Annotations, artifacts.ResolvedImagesAnnotation)
+	var merr *multierror.Error
+	_, tags, err := artifacts.ParseImagesResult(tr)
+	if err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	urlTags, err := artifacts.ParseImageURLsWithoutDigest(tr)
+	if err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	var resolved []string
+	seen := map[string]bool{}
+	for _, tag := range append(tags, urlTags...) {
+		if seen[tag.String()] {
+			continue
+		}
+		seen[tag.String()] = true
+		if !cfg.Images.Resolve {
+			merr = multierror.Append(merr, fmt.Errorf("image %s has no digest", tag))
+			continue
+		}
+		d, err := resolveImage(ctx, ts.KubeClient, tr, tag)
+		if err != nil {
+			merr = multierror.Append(merr, errors.Wrapf(err, "resolving the digest of %s", tag))
+			continue
+		}
+		resolved = append(resolved, fmt.Sprintf("%s@%s", tag, d.DigestStr()))
+	}
+	if len(resolved) > 0 {
+		if tr.Annotations == nil {
+			tr.Annotations = map[string]string{}
+		}
+		tr.Annotations[artifacts.ResolvedImagesAnnotation] = strings.Join(resolved, ",")
+	}
+	return merr.ErrorOrNil()
+}
+
+// Set this as a var for mocking.
+var fetchSBOM = sbom.Fetch
+
+// signSBOMs signs the SBOMs the TaskRun produced as in-toto attestations about the images
+// they describe, and attaches them to the images with the OCI storage backend.
+func (ts *TaskRunSigner) signSBOMs(ctx context.Context, cfg config.Config, tr *v1beta1.TaskRun, b storage.Backend, signers map[string]signing.Signer) error {
+	logger := logging.FromContext(ctx)
+	sboms := artifacts.ExtractSBOMsFromResults(tr, logger)
+	if len(sboms) == 0 {
+		return nil
+	}
+	if b == nil {
+		return errors.New("the OCI storage backend is not configured")
+	}
+	signer, ok := signers[cfg.Artifacts.OCI.Signer]
+	if !ok {
+		return fmt.Errorf("no signer %s configured for SBOMs", cfg.Artifacts.OCI.Signer)
+	}
+	signer, err := signing.Wrap(ctx, signer)
+	if err != nil {
+		return err
+	}
+
+	var merr *multierror.Error
+	for _, s := range sboms {
+		if err := signSBOM(ctx, tr, b, signer, s); err != nil {
+			recordWarning(ctx, tr, EventReasonSBOMFailed, "Failed to attach SBOM %s to %s: %v", s.URL, s.Image, err)
+			merr = multierror.Append(merr, err)
+			continue
+		}
+		logger.Infof("Attached SBOM %s to %s", s.URL, s.Image)
+	}
+	return merr.ErrorOrNil()
+}
+
+func signSBOM(ctx context.Context, tr *v1beta1.TaskRun, b storage.Backend, signer signing.Signer, s artifacts.SBOM) error {
+	doc, err := fetchSBOM(ctx, s)
+	if err != nil {
+		return err
+	}
+	statement, err := sbom.NewStatement(s, doc)
+	if err != nil {
+		return err
+	}
+	rawPayload, err := json.Marshal(statement)
+	if err != nil {
+		return errors.Wrap(err, "marshalling SBOM statement")
+	}
+	signature, err := signer.SignMessage(bytes.NewReader(rawPayload), options.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "signing SBOM")
+	}
+	return b.StorePayload(ctx, rawPayload, string(signature), config.StorageOpts{
+		Key:           VersionedKey(tr, (&artifacts.OCIArtifact{}).Key(s.Image)) + SBOMKeySuffix,
+		Cert:          signer.Cert(),
+		Chain:         signer.Chain(),
+		PayloadFormat: string(formats.PayloadTypeInTotoIte6),
+	})
+}
+
+// signSource signs a source attestation about the git commit the TaskRun built, and stores
+// it next to the TaskRun's own attestation. TaskRuns that don't name a commit are skipped.
+func (ts *TaskRunSigner) signSource(ctx context.Context, cfg config.Config, tr *v1beta1.TaskRun, allBackends map[string]storage.Backend, signers map[string]signing.Signer) error {
+	logger := logging.FromContext(ctx)
+	statement, ok, err := source.NewStatement(tr, cfg.Builder.ID)
+	if err != nil {
+		// Retrying won't change the parameters and results of the TaskRun.
+		logger.Warnf("Not signing a source attestation for TaskRun %s/%s: %v", tr.Namespace, tr.Name, err)
+		recordWarning(ctx, tr, EventReasonSigningFailed, "Failed to create source attestation: %v", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	signer, ok := signers[cfg.Artifacts.TaskRuns.Signer]
+	if !ok {
+		logger.Warnf("No signer %s configured for source attestations", cfg.Artifacts.TaskRuns.Signer)
+		return nil
+	}
+	signer, err = signing.Wrap(ctx, signer)
+	if err != nil {
+		return err
+	}
+	rawPayload, err := json.Marshal(statement)
+	if err != nil {
+		return errors.Wrap(err, "marshalling source attestation")
+	}
+	signature, err := signer.SignMessage(bytes.NewReader(rawPayload), options.WithContext(ctx))
+	if err != nil {
+		recordWarning(ctx, tr, EventReasonSigningFailed, "Failed to sign source attestation: %v", err)
+		return nil
+	}
+	opts := config.StorageOpts{
+		Key:           VersionedKey(tr, (&artifacts.TaskRunArtifact{}).Key(tr)) + SourceKeySuffix,
+		Cert:          signer.Cert(),
+		Chain:         signer.Chain(),
+		PayloadFormat: string(formats.PayloadTypeInTotoIte6),
+	}
+	// The attestation is stored where the storage routes send in-toto payloads.
+	var merr *multierror.Error
+	for _, backendType := range cfg.Storage.StorageBackends(opts.PayloadFormat, cfg.Artifacts.TaskRuns.StorageBackend) {
+		b, ok := allBackends[backendType]
+		if !ok {
+			merr = multierror.Append(merr, fmt.Errorf("storing source attestation: storage backend %q is not configured", backendType))
+			continue
+		}
+		if err := b.StorePayload(ctx, rawPayload, string(signature), opts); err != nil {
+			recordWarning(ctx, tr, EventReasonStorageFailed, "Failed to store source attestation in %s backend: %v", b.Type(), err)
+			merr = multierror.Append(merr, errors.Wrapf(err, "storing source attestation in %s backend", b.Type()))
+			continue
+		}
+		logger.Infof("Stored source attestation %s for TaskRun %s/%s in %s backend", opts.Key, tr.Namespace, tr.Name, b.Type())
+	}
+	return merr.ErrorOrNil()
+}
+
+// signVSA evaluates provenance that has been stored against the policy in the configuration.
+// When it passes, it signs a VSA referencing the stored provenance and stores it next to it.
+// Provenance that doesn't pass is recorded with an event, and doesn't fail the signing.
+func signVSA(ctx context.Context, cfg config.Config, tr *v1beta1.TaskRun, b storage.Backend, signer signing.Signer, provenance intoto.ProvenanceStatement, opts config.StorageOpts, envelope []byte) error {
+	logger := logging.FromContext(ctx)
+	if err := vsa.Evaluate(cfg, provenance); err != nil {
+		logger.Infof("Provenance %s for TaskRun %s/%s did not pass the policy: %v", opts.Key, tr.Namespace, tr.Name, err)
+		recordWarning(ctx, tr, EventReasonPolicyFailed, "Provenance did not pass policy %s: %v", cfg.VSA.PolicyURI, err)
+		return nil
+	}
+	digest := sha256.Sum256(envelope)
+	statement := vsa.NewStatement(cfg, provenance, opts.Key, slsa.DigestSet{"sha256": hex.EncodeToString(digest[:])}, time.Now())
+	rawPayload, err := json.Marshal(statement)
+	if err != nil {
+		return errors.Wrap(err, "marshalling VSA")
+	}
+	signature, err := signer.SignMessage(bytes.NewReader(rawPayload), options.WithContext(ctx))
+	if err != nil {
+		recordWarning(ctx, tr, EventReasonSigningFailed, "Failed to sign VSA: %v", err)
+		return errors.Wrap(err, "signing VSA")
+	}
+	vsaOpts := config.StorageOpts{
+		Key:           opts.Key + VSAKeySuffix,
+		Cert:          signer.Cert(),
+		Chain:         signer.Chain(),
+		PayloadFormat: opts.PayloadFormat,
+	}
+	if err := b.StorePayload(ctx, rawPayload, string(signature), vsaOpts); err != nil {
+		recordWarning(ctx, tr, EventReasonStorageFailed, "Failed to store VSA in %s backend: %v", b.Type(), err)
+		return errors.Wrapf(err, "storing VSA in %s backend", b.Type())
+	}
+	logger.Infof("Stored VSA %s for TaskRun %s/%s", vsaOpts.Key, tr.Namespace, tr.Name)
+	return nil
+}
+
+// storeCountersignature stores the countersignature of the envelope of a payload next to it,
+// with the envelope as its payload.
+func storeCountersignature(ctx context.Context, tr *v1beta1.TaskRun, b storage.Backend, countersigner signing.Signer, envelope, countersignature []byte, opts config.StorageOpts) error {
+	csOpts := config.StorageOpts{
+		Key:           opts.Key + CountersignatureKeySuffix,
+		Cert:          countersigner.Cert(),
+		Chain:         countersigner.Chain(),
+		PayloadFormat: opts.PayloadFormat,
+	}
+	if err := b.StorePayload(ctx, envelope, string(countersignature), csOpts); err != nil {
+		recordWarning(ctx, tr, EventReasonStorageFailed, "Failed to store countersignature in %s backend: %v", b.Type(), err)
+		return errors.Wrapf(err, "storing countersignature in %s backend", b.Type())
+	}
+	logging.FromContext(ctx).Infof("Stored countersignature %s for TaskRun %s/%s", csOpts.Key, tr.Namespace, tr.Name)
+	return nil
+}
+
+// storeBundle stores the Sigstore bundle of a signed payload next to it. A bundle that can't be
+// made, which retrying won't change, is reported with an event.
+func storeBundle(ctx context.Context, tr *v1beta1.TaskRun, b storage.Backend, rawPayload, signature []byte, envelope bool, opts config.StorageOpts) error {
+	logger := logging.FromContext(ctx)
+	sb, err := bundle.New(rawPayload, signature, envelope, opts.Cert, opts.Chain, opts.Bundle)
+	if err != nil {
+		logger.Warnf("Not storing a Sigstore bundle of %s for TaskRun %s/%s: %v", opts.Key, tr.Namespace, tr.Name, err)
+		recordWarning(ctx, tr, EventReasonStorageFailed, "Failed to create Sigstore bundle of %s payload: %v", opts.PayloadFormat, err)
+		return nil
+	}
+	raw, err := json.Marshal(sb)
+	if err != nil {
+		return errors.Wrap(err, "marshalling Sigstore bundle")
+	}
+	bundleOpts := config.StorageOpts{
+		Key:           opts.Key + BundleKeySuffix,
+		PayloadFormat: bundle.PayloadFormat,
+	}
+	if err := b.StorePayload(ctx, raw, "", bundleOpts); err != nil {
+		recordWarning(ctx, tr, EventReasonStorageFailed, "Failed to store Sigstore bundle in %s backend: %v", b.Type(), err)
+		return errors.Wrapf(err, "storing Sigstore bundle in %s backend", b.Type())
+	}
+	logger.Infof("Stored Sigstore bundle %s for TaskRun %s/%s", bundleOpts.Key, tr.Namespace, tr.Name)
+	return nil
+}
+
+// recordOCITargets records where the oci backend pushed the signatures and attestations of the
+// images, so a push that failed for one registry of an image can be told from the others.
+func recordOCITargets(annotations map[string]string, targets []oci.Target) error {
+	if len(targets) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(targets)
+	if err != nil {
+		return errors.Wrap(err, "encoding OCI targets")
+	}
+	annotations[OCITargetsAnnotation] = string(b)
+	return nil
+}
+
+// storeDryRun stores an unsigned payload, with an empty signature, under a key marked
+// with DryRunKeySuffix so it can't be mistaken for a signed payload.
+func storeDryRun(ctx context.Context, b storage.Backend, tr *v1beta1.TaskRun, payload interface{}, payloadFormat, key string) error {
+	rawPayload, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "marshalling payload")
+	}
+	logging.FromContext(ctx).Infof("Dry run: storing unsigned %s payload for TaskRun %s/%s in %s", payloadFormat, tr.Namespace, tr.Name, b.Type())
+	return b.StorePayload(ctx, rawPayload, "", config.StorageOpts{
+		Key:           key + DryRunKeySuffix,
+		PayloadFormat: payloadFormat,
+	})
+}
+
+// Set this as a var for mocking.
+var writeDeadLetter = deadletter.Write
+
+// reportDeadLetter records a TaskRun that failed to be signed after exhausting its retries.
+func reportDeadLetter(ctx context.Context, cfg config.Config, tr *v1beta1.TaskRun, reason string, err error) {
+	logger := logging.FromContext(ctx)
+	logger.Errorf("giving up signing TaskRun %s/%s (%s): %v", tr.Namespace, tr.Name, reason, err)
+	metrics.RecordPermanentFailure(ctx, reason)
+	if cfg.DeadLetter.URL == "" {
+		return
+	}
+	if err := writeDeadLetter(ctx, cfg.DeadLetter.URL, deadletter.NewRecord(tr, reason, err)); err != nil {
+		logger.Errorf("error writing dead letter record: %v", err)
+	}
 }
 
 func HandleRetry(tr *v1beta1.TaskRun, ps versioned.Interface, annotations map[string]string) error {
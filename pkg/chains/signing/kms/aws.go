@@ -0,0 +1,346 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awskms "github.com/aws/aws-sdk-go/service/kms"
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature"
+	awssigner "github.com/sigstore/sigstore/pkg/signature/kms/aws"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// The AWS SDK reads the chain of roles of an identity from its shared config files. Chains writes
+// the files for the identity of awskms:// keys, and creates the session of the key's client with
+// them, see awsProfile.sessionOptions. sigstore's client for awskms:// keys only reads its
+// settings from the environment, so the keys are signed with by an awsKMS instead.
+
+// The locations EKS mounts the tokens of workload identities at, unless its environment
+// variables say otherwise. They're vars for testing.
+var (
+	irsaTokenPath        = "/var/run/secrets/eks.amazonaws.com/serviceaccount/token"
+	podIdentityTokenPath = "/var/run/secrets/pods.eks.amazonaws.com/serviceaccount/eks-pod-identity-token"
+	podIdentityEndpoint  = "http://169.254.170.23/v1/credentials"
+)
+
+// awsSessionName is the name of the sessions of the roles Chains assumes, in CloudTrail.
+const awsSessionName = "tekton-chains"
+
+// kmsRegionPattern finds the region in the ARN of a key or alias.
+var kmsRegionPattern = regexp.MustCompile(`arn:aws[a-z-]*:kms:([a-z0-9-]+):`)
+
+// podIdentity holds the credentials EKS Pod Identity handed out, until they're about to expire.
+var podIdentity = &podIdentityCredentials{}
+
+type podIdentityCredentials struct {
+	mu    sync.Mutex
+	creds *awsCredentials
+	// generation counts the credentials fetched. The clients of awskms keys are cached for
+	// each, since the SDK can't fetch them itself.
+	generation int
+}
+
+// awsCredentials are temporary credentials, in the format of the container credentials API.
+type awsCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// Get returns the current credentials of the pod, fetching new ones 5 minutes before they
+// expire, and their generation.
+func (p *podIdentityCredentials) Get(ctx context.Context) (*awsCredentials, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.creds != nil && clock().Before(p.creds.Expiration.Add(-5*time.Minute)) {
+		return p.creds, p.generation, nil
+	}
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if endpoint == "" {
+		endpoint = podIdentityEndpoint
+	}
+	tokenPath := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE")
+	if tokenPath == "" {
+		tokenPath = podIdentityTokenPath
+	}
+	token, err := ioutil.ReadFile(tokenPath)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "reading the eks pod identity token")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", strings.TrimSpace(string(token)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "fetching eks pod identity credentials")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("eks pod identity agent returned %s", resp.Status)
+	}
+	creds := &awsCredentials{}
+	if err := json.NewDecoder(resp.Body).Decode(creds); err != nil {
+		return nil, 0, errors.Wrap(err, "decoding eks pod identity credentials")
+	}
+	p.creds = creds
+	p.generation++
+	return p.creds, p.generation, nil
+}
+
+// awsProfile is the shared config the AWS KMS client of cfg is created with.
+type awsProfile struct {
+	// config and credentials are the contents of the shared config and credentials files.
+	config, credentials string
+	// profile is the profile the client uses, the last role assumed.
+	profile string
+	region  string
+	// generation changes when the credentials in the files do.
+	generation int
+}
+
+// newAWSProfile returns the shared config of the identity of cfg, with its chain of roles. Without
+// an identity, it only has the region.
+func newAWSProfile(ctx context.Context, cfg config.KMSSigner) (*awsProfile, error) {
+	a := cfg.AWS
+	p := &awsProfile{region: a.Region}
+	if p.region == "" {
+		if m := kmsRegionPattern.FindStringSubmatch(cfg.KMSRef); m != nil {
+			p.region = m[1]
+		}
+	}
+
+	var conf strings.Builder
+	switch a.Identity {
+	case config.AWSIdentityIRSA:
+		role := a.RoleARN
+		if role == "" {
+			role = os.Getenv("AWS_ROLE_ARN")
+		}
+		if role == "" {
+			return nil, fmt.Errorf("irsa needs a role, in signers.kms.aws.role-arn or AWS_ROLE_ARN")
+		}
+		tokenPath := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		if tokenPath == "" {
+			tokenPath = irsaTokenPath
+		}
+		// The SDK reads the token again each time it assumes the role, so rotated tokens are used.
+		fmt.Fprintf(&conf, "[profile chains-0]\nrole_arn = %s\nweb_identity_token_file = %s\nrole_session_name = %s\n", role, tokenPath, awsSessionName)
+	case config.AWSIdentityPodIdentity:
+		creds, generation, err := podIdentity.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.credentials = fmt.Sprintf("[chains-0]\naws_access_key_id = %s\naws_secret_access_key = %s\naws_session_token = %s\n", creds.AccessKeyID, creds.SecretAccessKey, creds.Token)
+		p.generation = generation
+	default:
+		return p, nil
+	}
+	p.profile = "chains-0"
+	for i, role := range a.AssumeRoles {
+		p.profile = fmt.Sprintf("chains-%d", i+1)
+		fmt.Fprintf(&conf, "[profile %s]\nrole_arn = %s\nsource_profile = chains-%d\nrole_session_name = %s\n", p.profile, role, i, awsSessionName)
+	}
+	p.config = conf.String()
+	return p, nil
+}
+
+// sessionOptions writes the shared config files to dir, and returns the options the session of
+// the client is created with. The profile takes precedence over the credentials in the
+// environment of the controller.
+func (p *awsProfile) sessionOptions(dir string) (session.Options, error) {
+	opts := session.Options{}
+	if p.region != "" {
+		opts.Config.Region = aws.String(p.region)
+	}
+	if p.profile == "" {
+		// Without an identity, the SDK finds the credentials as usual.
+		return opts, nil
+	}
+	for name, contents := range map[string]string{"config": p.config, "credentials": p.credentials} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			return opts, err
+		}
+		opts.SharedConfigFiles = append(opts.SharedConfigFiles, path)
+	}
+	sort.Strings(opts.SharedConfigFiles)
+	opts.SharedConfigState = session.SharedConfigEnable
+	opts.Profile = p.profile
+	return opts, nil
+}
+
+// newAWSKMS returns the client of an awskms:// key, created with opts. It's set as a var for
+// testing.
+var newAWSKMS = func(ref string, opts session.Options) (signature.SignerVerifier, error) {
+	if err := awssigner.ValidReference(ref); err != nil {
+		return nil, err
+	}
+	// The reference is awskms://[endpoint]/key, where the key is an ID, an ARN or an alias.
+	parts := strings.SplitN(strings.TrimPrefix(ref, awssigner.ReferenceScheme), "/", 2)
+	if parts[0] != "" {
+		opts.Config.Endpoint = aws.String("https://" + parts[0])
+	}
+	if os.Getenv("AWS_TLS_INSECURE_SKIP_VERIFY") == "1" {
+		opts.Config.HTTPClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}} // nolint: gosec
+	}
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "new aws session")
+	}
+	return &awsKMS{client: awskms.New(sess), keyID: parts[1]}, nil
+}
+
+// awsKMS signs with an awskms:// key like sigstore's client does, with a client whose session
+// it's given.
+type awsKMS struct {
+	client *awskms.KMS
+	keyID  string
+
+	mu sync.Mutex
+	// algorithm and verifier are the signing algorithm of the key, and what its signatures are
+	// verified with, once they're fetched.
+	algorithm *string
+	verifier  signature.Verifier
+}
+
+// key returns the signing algorithm of the key, and a verifier of its public key.
+func (a *awsKMS) key(ctx context.Context) (*string, signature.Verifier, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.verifier != nil {
+		return a.algorithm, a.verifier, nil
+	}
+	described, err := a.client.DescribeKeyWithContext(ctx, &awskms.DescribeKeyInput{KeyId: &a.keyID})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "getting key metadata")
+	}
+	if len(described.KeyMetadata.SigningAlgorithms) == 0 {
+		return nil, nil, fmt.Errorf("key %s has no signing algorithms", a.keyID)
+	}
+	out, err := a.client.GetPublicKeyWithContext(ctx, &awskms.GetPublicKeyInput{KeyId: &a.keyID})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "getting public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(out.PublicKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parsing public key")
+	}
+	algorithm := described.KeyMetadata.SigningAlgorithms[0]
+	var verifier signature.Verifier
+	switch hash := awsHashes[*algorithm]; *algorithm {
+	case awskms.SigningAlgorithmSpecRsassaPssSha256, awskms.SigningAlgorithmSpecRsassaPssSha384, awskms.SigningAlgorithmSpecRsassaPssSha512:
+		verifier, err = signature.LoadRSAPSSVerifier(pub.(*rsa.PublicKey), hash, nil)
+	case awskms.SigningAlgorithmSpecRsassaPkcs1V15Sha256, awskms.SigningAlgorithmSpecRsassaPkcs1V15Sha384, awskms.SigningAlgorithmSpecRsassaPkcs1V15Sha512:
+		verifier, err = signature.LoadRSAPKCS1v15Verifier(pub.(*rsa.PublicKey), hash)
+	case awskms.SigningAlgorithmSpecEcdsaSha256, awskms.SigningAlgorithmSpecEcdsaSha384, awskms.SigningAlgorithmSpecEcdsaSha512:
+		verifier, err = signature.LoadECDSAVerifier(pub.(*ecdsa.PublicKey), hash)
+	default:
+		err = fmt.Errorf("signing algorithm %s unsupported", *algorithm)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	a.algorithm, a.verifier = algorithm, verifier
+	return a.algorithm, a.verifier, nil
+}
+
+// awsHashes are the hash functions of the signing algorithms of AWS KMS.
+var awsHashes = map[string]crypto.Hash{
+	awskms.SigningAlgorithmSpecRsassaPssSha256:      crypto.SHA256,
+	awskms.SigningAlgorithmSpecRsassaPkcs1V15Sha256: crypto.SHA256,
+	awskms.SigningAlgorithmSpecEcdsaSha256:          crypto.SHA256,
+	awskms.SigningAlgorithmSpecRsassaPssSha384:      crypto.SHA384,
+	awskms.SigningAlgorithmSpecRsassaPkcs1V15Sha384: crypto.SHA384,
+	awskms.SigningAlgorithmSpecEcdsaSha384:          crypto.SHA384,
+	awskms.SigningAlgorithmSpecRsassaPssSha512:      crypto.SHA512,
+	awskms.SigningAlgorithmSpecRsassaPkcs1V15Sha512: crypto.SHA512,
+	awskms.SigningAlgorithmSpecEcdsaSha512:          crypto.SHA512,
+}
+
+// PublicKey returns the public key of the key.
+func (a *awsKMS) PublicKey(opts ...signature.PublicKeyOption) (crypto.PublicKey, error) {
+	ctx := context.Background()
+	for _, opt := range opts {
+		opt.ApplyContext(&ctx)
+	}
+	_, verifier, err := a.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.PublicKey()
+}
+
+// SignMessage signs the digest of message, with the hash function of the key's algorithm.
+func (a *awsKMS) SignMessage(message io.Reader, opts ...signature.SignOption) ([]byte, error) {
+	ctx := context.Background()
+	for _, opt := range opts {
+		opt.ApplyContext(&ctx)
+	}
+	algorithm, _, err := a.key(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash := awsHashes[*algorithm]
+	digest, _, err := signature.ComputeDigestForSigning(message, hash, []crypto.Hash{hash}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	out, err := a.client.SignWithContext(ctx, &awskms.SignInput{
+		KeyId:            &a.keyID,
+		Message:          digest,
+		MessageType:      aws.String(awskms.MessageTypeDigest),
+		SigningAlgorithm: algorithm,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "signing with kms")
+	}
+	return out.Signature, nil
+}
+
+// VerifySignature verifies the signature with the public key of the key.
+func (a *awsKMS) VerifySignature(sig, message io.Reader, opts ...signature.VerifyOption) error {
+	ctx := context.Background()
+	for _, opt := range opts {
+		opt.ApplyContext(&ctx)
+	}
+	_, verifier, err := a.key(ctx)
+	if err != nil {
+		return err
+	}
+	return verifier.VerifySignature(sig, message, opts...)
+}
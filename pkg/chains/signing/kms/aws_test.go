@@ -0,0 +1,243 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/tektoncd/chains/pkg/config"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+const awsKeyRef = "awskms:///arn:aws:kms:eu-west-1:111122223333:key/1234abcd"
+
+func TestNewAWSProfile_IRSA(t *testing.T) {
+	cfg := config.KMSSigner{
+		KMSRef: awsKeyRef,
+		AWS: config.AWSAuth{
+			Identity:    config.AWSIdentityIRSA,
+			RoleARN:     "arn:aws:iam::111122223333:role/chains",
+			AssumeRoles: []string{"arn:aws:iam::444455556666:role/signer"},
+		},
+	}
+	p, err := newAWSProfile(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("newAWSProfile() error = %v", err)
+	}
+	want := `[profile chains-0]
+role_arn = arn:aws:iam::111122223333:role/chains
+web_identity_token_file = /var/run/secrets/eks.amazonaws.com/serviceaccount/token
+role_session_name = tekton-chains
+[profile chains-1]
+role_arn = arn:aws:iam::444455556666:role/signer
+source_profile = chains-0
+role_session_name = tekton-chains
+`
+	if diff := cmp.Diff(want, p.config); diff != "" {
+		t.Errorf("config mismatch (-want +got): %s", diff)
+	}
+	if p.profile != "chains-1" || p.region != "eu-west-1" {
+		t.Errorf("got profile %s in region %s, want chains-1 in eu-west-1", p.profile, p.region)
+	}
+
+	opts, err := p.sessionOptions(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.SharedConfigFiles) != 2 {
+		t.Fatalf("sessionOptions() has files %v, want the config and credentials files", opts.SharedConfigFiles)
+	}
+	written, err := ioutil.ReadFile(opts.SharedConfigFiles[0])
+	if err != nil || string(written) != want {
+		t.Errorf("the config file has %q, %v", written, err)
+	}
+	if opts.Profile != "chains-1" || opts.SharedConfigState != session.SharedConfigEnable || aws.StringValue(opts.Config.Region) != "eu-west-1" {
+		t.Errorf("sessionOptions() = %+v", opts)
+	}
+
+	cfg.AWS.RoleARN = ""
+	oldRole := os.Getenv("AWS_ROLE_ARN")
+	os.Unsetenv("AWS_ROLE_ARN")
+	defer os.Setenv("AWS_ROLE_ARN", oldRole)
+	if _, err := newAWSProfile(context.Background(), cfg); err == nil {
+		t.Error("expected an error for irsa without a role")
+	}
+}
+
+func TestNewAWSProfile_PodIdentity(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "pod-token" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		fetches++
+		fmt.Fprintf(w, `{"AccessKeyId": "AKID%d", "SecretAccessKey": "secret", "Token": "session", "Expiration": "2021-11-01T01:00:00Z"}`, fetches)
+	}))
+	defer server.Close()
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenPath, []byte("pod-token"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	defer func(endpoint, path string, c func() time.Time) {
+		podIdentityEndpoint, podIdentityTokenPath, clock = endpoint, path, c
+		podIdentity = &podIdentityCredentials{}
+	}(podIdentityEndpoint, podIdentityTokenPath, clock)
+	podIdentityEndpoint, podIdentityTokenPath = server.URL, tokenPath
+	clock = func() time.Time { return now }
+	podIdentity = &podIdentityCredentials{}
+
+	cfg := config.KMSSigner{KMSRef: "awskms:///alias/chains", AWS: config.AWSAuth{Identity: config.AWSIdentityPodIdentity, Region: "us-west-2"}}
+	steps := []struct {
+		after          time.Duration
+		wantGeneration int
+	}{{0, 1}, {50 * time.Minute, 1}, {6 * time.Minute, 2}}
+	for _, s := range steps {
+		now = now.Add(s.after)
+		p, err := newAWSProfile(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("newAWSProfile() error = %v", err)
+		}
+		want := fmt.Sprintf("[chains-0]\naws_access_key_id = AKID%d\naws_secret_access_key = secret\naws_session_token = session\n", s.wantGeneration)
+		if p.credentials != want || p.generation != s.wantGeneration || p.profile != "chains-0" || p.region != "us-west-2" {
+			t.Errorf("after %s: newAWSProfile() = %+v, want credentials %q", s.after, p, want)
+		}
+	}
+}
+
+func TestNewSignerAWS(t *testing.T) {
+	clients.Purge()
+	t.Cleanup(clients.Purge)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	os.Setenv("AWS_ACCESS_KEY_ID", "static")
+	defer os.Setenv("AWS_ACCESS_KEY_ID", oldKey)
+
+	var profile, accessKey string
+	defer func(f func(string, session.Options) (signature.SignerVerifier, error)) { newAWSKMS = f }(newAWSKMS)
+	newAWSKMS = func(ref string, opts session.Options) (signature.SignerVerifier, error) {
+		profile, accessKey = opts.Profile, os.Getenv("AWS_ACCESS_KEY_ID")
+		for _, f := range opts.SharedConfigFiles {
+			if _, err := os.Stat(f); err != nil {
+				t.Errorf("the shared config file isn't there: %v", err)
+			}
+		}
+		return &fakeKMS{key: key}, nil
+	}
+	cfg := config.KMSSigner{
+		KMSRef: awsKeyRef,
+		AWS:    config.AWSAuth{Identity: config.AWSIdentityIRSA, RoleARN: "arn:aws:iam::111122223333:role/chains"},
+	}
+	if _, err := NewSigner(cfg, logtesting.TestLogger(t)); err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	if profile != "chains-0" || accessKey != "static" {
+		t.Errorf("client created with profile %q and access key %q, want profile chains-0 without changing the environment", profile, accessKey)
+	}
+}
+
+func TestAWSKMS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signed int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.DescribeKey":
+			resp = map[string]interface{}{"KeyMetadata": map[string]interface{}{"KeyId": "1234abcd", "SigningAlgorithms": []string{"ECDSA_SHA_256"}}}
+		case "TrentService.GetPublicKey":
+			resp = map[string]interface{}{"PublicKey": der}
+		case "TrentService.Sign":
+			var req struct{ Message []byte }
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			sig, err := ecdsa.SignASN1(rand.Reader, key, req.Message)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			signed++
+			resp = map[string]interface{}{"Signature": sig}
+		default:
+			http.Error(w, "unexpected call", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	// A CA bundle in the environment would replace the test server's.
+	defer os.Setenv("AWS_CA_BUNDLE", os.Getenv("AWS_CA_BUNDLE"))
+	os.Unsetenv("AWS_CA_BUNDLE")
+
+	opts := session.Options{Config: aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		HTTPClient:  server.Client(),
+	}}
+	ref := "awskms://" + strings.TrimPrefix(server.URL, "https://") + "/1234abcd-12ab-34cd-56ef-1234567890ab"
+	sv, err := newAWSKMS(ref, opts)
+	if err != nil {
+		t.Fatalf("newAWSKMS() error = %v", err)
+	}
+	sig, err := sv.SignMessage(strings.NewReader("payload"))
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	if err := sv.VerifySignature(bytes.NewReader(sig), strings.NewReader("payload")); err != nil {
+		t.Errorf("VerifySignature() error = %v", err)
+	}
+	if err := sv.VerifySignature(bytes.NewReader(sig), strings.NewReader("other")); err == nil {
+		t.Error("expected the signature of another payload not to verify")
+	}
+	pub, err := sv.PublicKey()
+	if err != nil || !key.PublicKey.Equal(pub) {
+		t.Errorf("PublicKey() = %v, %v, want the key's", pub, err)
+	}
+	if signed != 1 {
+		t.Errorf("signed %d times, want 1", signed)
+	}
+}
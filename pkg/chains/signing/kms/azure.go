@@ -0,0 +1,155 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/tektoncd/chains/pkg/metrics"
+)
+
+// Azure Key Vault throttles the requests of a vault beyond its service limits with a 429 and a
+// Retry-After. The Azure SDK only retries them a few times, so signatures it still fails with
+// throttling are retried here, with backoff, instead of failing the TaskRun.
+
+// The backoff of throttled signatures: the delay doubles from azureBaseDelay up to azureMaxDelay,
+// with jitter so signatures throttled together aren't retried together, and a signature is given
+// up on after azureMaxAttempts. A Retry-After is waited for instead, with up to azureBaseDelay of
+// jitter, unless it's longer than azureMaxDelay. They're vars for testing.
+var (
+	azureMaxAttempts = 5
+	azureBaseDelay   = time.Second
+	azureMaxDelay    = time.Minute
+)
+
+// azureMetricsKMS is the kms label of the throttling metrics of Azure Key Vault.
+const azureMetricsKMS = "azurekms"
+
+// wait and jitter are set for testing. wait waits for d, or until ctx is done, and jitter
+// returns a random duration in [0, d).
+var (
+	wait = func(ctx context.Context, d time.Duration) error {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			return nil
+		}
+	}
+	jitter = func(d time.Duration) time.Duration { return time.Duration(rand.Int63n(int64(d))) }
+)
+
+// signRetryingThrottled signs message, retrying the signatures Azure Key Vault throttles.
+func (s *Signer) signRetryingThrottled(ctx context.Context, message io.Reader, opts ...signature.SignOption) ([]byte, error) {
+	// The message is read again for each attempt.
+	raw, err := ioutil.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		sig, err := s.sign(ctx, bytes.NewReader(raw), opts...)
+		if err == nil || !azureThrottled(err) {
+			return sig, err
+		}
+		metrics.RecordKMSThrottled(ctx, azureMetricsKMS)
+		if attempt == azureMaxAttempts {
+			return nil, errors.Wrapf(err, "azure key vault throttled %d attempts to sign with %s", attempt, s.ref)
+		}
+		delay, ok := retryAfter(err)
+		if ok && delay > azureMaxDelay {
+			return nil, errors.Wrapf(err, "azure key vault asked to retry signing with %s after %s", s.ref, delay)
+		}
+		if ok {
+			delay += jitter(azureBaseDelay)
+		} else {
+			delay = azureBackoff(attempt)
+		}
+		s.logger.Warnf("Azure Key Vault throttled signing with %s, retrying in %s", s.ref, delay)
+		metrics.RecordKMSThrottleDelay(ctx, azureMetricsKMS, delay)
+		if err := wait(ctx, delay); err != nil {
+			return nil, errors.Wrapf(err, "waiting to retry signing with %s", s.ref)
+		}
+	}
+}
+
+// azureBackoff is the delay before the attempt after attempt, between half and all of the
+// exponential backoff.
+func azureBackoff(attempt int) time.Duration {
+	d := azureBaseDelay << (attempt - 1)
+	if d > azureMaxDelay || d <= 0 {
+		d = azureMaxDelay
+	}
+	return d/2 + jitter(d/2)
+}
+
+// azureThrottled is whether err is a request Azure Key Vault throttled.
+func azureThrottled(err error) bool {
+	if resp := azureResponse(err); resp != nil {
+		return resp.StatusCode == http.StatusTooManyRequests
+	}
+	return strings.Contains(err.Error(), "StatusCode=429")
+}
+
+// retryAfter returns the Retry-After of the throttled response of err, in seconds or as a date.
+func retryAfter(err error) (time.Duration, bool) {
+	resp := azureResponse(err)
+	if resp == nil {
+		return 0, false
+	}
+	ra := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(ra); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(ra); err == nil {
+		d := t.Sub(clock())
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// azureResponse finds the response in the chain of err. The Azure SDK returns it in the Response
+// field of autorest.DetailedError, which can't be referred to here.
+func azureResponse(err error) *http.Response {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.ValueOf(err)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		if f := v.FieldByName("Response"); f.IsValid() && f.CanInterface() {
+			if resp, ok := f.Interface().(*http.Response); ok && resp != nil {
+				return resp
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/kms"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+	"github.com/tektoncd/chains/pkg/config"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+// detailedError is like the autorest.DetailedError the Azure SDK fails with.
+type detailedError struct {
+	StatusCode interface{}
+	Response   *http.Response
+}
+
+func (e detailedError) Error() string {
+	return fmt.Sprintf("keyvault.BaseClient#Sign: Failure responding to request: StatusCode=%v", e.StatusCode)
+}
+
+// throttledKMS fails the first signatures it's asked for with errs, and signs the others.
+type throttledKMS struct {
+	fakeKMS
+	errs     []error
+	messages []string
+}
+
+func (f *throttledKMS) SignMessage(message io.Reader, _ ...signature.SignOption) ([]byte, error) {
+	raw, err := ioutil.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+	f.messages = append(f.messages, string(raw))
+	if len(f.errs) > 0 {
+		err, f.errs = f.errs[0], f.errs[1:]
+		return nil, err
+	}
+	return []byte("signature"), nil
+}
+
+func throttled(retryAfter string) error {
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return errors.Wrap(detailedError{StatusCode: http.StatusTooManyRequests, Response: resp}, "signing the payload")
+}
+
+func TestSignRetryingThrottled(t *testing.T) {
+	var slept []time.Duration
+	defer func(w func(context.Context, time.Duration) error, j func(time.Duration) time.Duration, now func() time.Time) {
+		wait, jitter, clock = w, j, now
+	}(wait, jitter, clock)
+	wait = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	jitter = func(d time.Duration) time.Duration { return d / 4 }
+	now := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return now }
+	defer func(f func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error)) { getKMS = f }(getKMS)
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   bool
+		wantSlept []time.Duration
+	}{{
+		name: "signs",
+	}, {
+		name:      "honors retry-after",
+		errs:      []error{throttled("3"), throttled(now.Add(10 * time.Second).Format(http.TimeFormat))},
+		wantSlept: []time.Duration{3250 * time.Millisecond, 10250 * time.Millisecond},
+	}, {
+		name:      "backs off without retry-after",
+		errs:      []error{throttled(""), throttled(""), detailedError{StatusCode: 429}},
+		wantSlept: []time.Duration{625 * time.Millisecond, 1250 * time.Millisecond, 2500 * time.Millisecond},
+	}, {
+		name:      "gives up",
+		errs:      []error{throttled(""), throttled(""), throttled(""), throttled(""), throttled("")},
+		wantErr:   true,
+		wantSlept: []time.Duration{625 * time.Millisecond, 1250 * time.Millisecond, 2500 * time.Millisecond, 5 * time.Second},
+	}, {
+		name:    "gives up when retry-after is too long",
+		errs:    []error{throttled("3600")},
+		wantErr: true,
+	}, {
+		name:    "doesn't retry other errors",
+		errs:    []error{errors.Wrap(detailedError{StatusCode: 403, Response: &http.Response{StatusCode: 403}}, "signing the payload")},
+		wantErr: true,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			slept = nil
+			clients.Purge()
+			t.Cleanup(clients.Purge)
+			f := &throttledKMS{errs: tc.errs}
+			getKMS = func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error) { return f, nil }
+			s, err := NewSigner(config.KMSSigner{KMSRef: "azurekms://chains.vault.azure.net/signer"}, logtesting.TestLogger(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := s.SignMessage(bytes.NewReader([]byte("payload")))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("SignMessage() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && string(sig) != "signature" {
+				t.Errorf("SignMessage() = %s", sig)
+			}
+			if fmt.Sprint(slept) != fmt.Sprint(tc.wantSlept) {
+				t.Errorf("slept %v, want %v", slept, tc.wantSlept)
+			}
+			for _, m := range f.messages {
+				if m != "payload" {
+					t.Errorf("attempts signed %q, want the payload each time", f.messages)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSignRetryingThrottledContext(t *testing.T) {
+	clients.Purge()
+	t.Cleanup(clients.Purge)
+	defer func(f func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error)) { getKMS = f }(getKMS)
+	f := &throttledKMS{errs: []error{throttled("30")}}
+	getKMS = func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error) { return f, nil }
+	s, err := NewSigner(config.KMSSigner{KMSRef: "azurekms://chains.vault.azure.net/signer"}, logtesting.TestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.SignMessage(bytes.NewReader([]byte("payload")), options.WithContext(ctx)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("SignMessage() error = %v, want the error of its context", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("SignMessage() returned after %s, want it to stop waiting for the Retry-After when its context is done", d)
+	}
+	if len(f.messages) != 1 {
+		t.Errorf("signed %d times, want no retry once the context is done", len(f.messages))
+	}
+}
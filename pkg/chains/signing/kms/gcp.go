@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// The Cloud KMS client finds its credentials with the application default credentials. To
+// federate the controller's identity, Chains writes an external account credential file for
+// gcpkms:// keys, and points GOOGLE_APPLICATION_CREDENTIALS at it while the client is created.
+
+// The endpoints of workload identity federation. They're vars for testing.
+var (
+	gcpSTSEndpoint         = "https://sts.googleapis.com/v1/token"
+	gcpImpersonateEndpoint = "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/%s:generateAccessToken"
+)
+
+// gcpExternalAccount is an external account credential file, as read by golang.org/x/oauth2/google.
+type gcpExternalAccount struct {
+	Type                           string              `json:"type"`
+	Audience                       string              `json:"audience"`
+	SubjectTokenType               string              `json:"subject_token_type"`
+	TokenURL                       string              `json:"token_url"`
+	ServiceAccountImpersonationURL string              `json:"service_account_impersonation_url,omitempty"`
+	CredentialSource               gcpCredentialSource `json:"credential_source"`
+}
+
+type gcpCredentialSource struct {
+	File   string `json:"file"`
+	Format struct {
+		Type string `json:"type"`
+	} `json:"format"`
+}
+
+// gcpEnvironment writes the external account credentials of auth to dir, and returns the
+// environment the client is created with. The token is read from its file each time it's
+// exchanged, so rotated tokens are used.
+func gcpEnvironment(dir string, auth config.GCPAuth) (map[string]string, error) {
+	account := gcpExternalAccount{
+		Type:             "external_account",
+		Audience:         auth.Audience,
+		SubjectTokenType: "urn:ietf:params:oauth:token-type:jwt",
+		TokenURL:         gcpSTSEndpoint,
+	}
+	if auth.ServiceAccount != "" {
+		account.ServiceAccountImpersonationURL = fmt.Sprintf(gcpImpersonateEndpoint, auth.ServiceAccount)
+	}
+	account.CredentialSource.File = auth.TokenPath
+	if account.CredentialSource.File == "" {
+		account.CredentialSource.File = serviceAccountTokenPath
+	}
+	account.CredentialSource.Format.Type = "text"
+
+	raw, err := json.Marshal(account)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "external-account.json")
+	if err := ioutil.WriteFile(path, raw, 0600); err != nil {
+		return nil, err
+	}
+	return map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": path}, nil
+}
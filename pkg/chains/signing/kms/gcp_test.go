@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sigstore/sigstore/pkg/signature/kms"
+	"github.com/tektoncd/chains/pkg/config"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+const gcpProvider = "//iam.googleapis.com/projects/123456789/locations/global/workloadIdentityPools/chains/providers/cluster"
+
+func TestGCPEnvironment(t *testing.T) {
+	auth := config.GCPAuth{Audience: gcpProvider, ServiceAccount: "chains@p.iam.gserviceaccount.com", TokenPath: "/var/run/secrets/chains/token"}
+	env, err := gcpEnvironment(t.TempDir(), auth)
+	if err != nil {
+		t.Fatalf("gcpEnvironment() error = %v", err)
+	}
+	raw, err := ioutil.ReadFile(env["GOOGLE_APPLICATION_CREDENTIALS"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"type":                              "external_account",
+		"audience":                          gcpProvider,
+		"subject_token_type":                "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":                         "https://sts.googleapis.com/v1/token",
+		"service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/chains@p.iam.gserviceaccount.com:generateAccessToken",
+		"credential_source": map[string]interface{}{
+			"file":   "/var/run/secrets/chains/token",
+			"format": map[string]interface{}{"type": "text"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("credentials mismatch (-want +got): %s", diff)
+	}
+
+	// Without a service account, the federated token is used directly.
+	env, err = gcpEnvironment(t.TempDir(), config.GCPAuth{Audience: gcpProvider})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err = ioutil.ReadFile(env["GOOGLE_APPLICATION_CREDENTIALS"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["service_account_impersonation_url"]; ok {
+		t.Errorf("expected no impersonation without a service account, got %s", raw)
+	}
+	if source := got["credential_source"].(map[string]interface{}); source["file"] != serviceAccountTokenPath {
+		t.Errorf("credential_source = %v, want the service account token", source)
+	}
+}
+
+func TestNewSignerGCP(t *testing.T) {
+	clients.Purge()
+	t.Cleanup(clients.Purge)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCreds, ok := os.LookupEnv("GOOGLE_APPLICATION_CREDENTIALS")
+	os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/secrets/key.json")
+	defer func() {
+		if ok {
+			os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", oldCreds)
+		} else {
+			os.Unsetenv("GOOGLE_APPLICATION_CREDENTIALS")
+		}
+	}()
+
+	var audience string
+	defer func(f func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error)) { getKMS = f }(getKMS)
+	getKMS = func(_ context.Context, ref string, _ crypto.Hash) (kms.SignerVerifier, error) {
+		raw, err := ioutil.ReadFile(os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+		if err != nil {
+			t.Errorf("the credentials file isn't there: %v", err)
+		}
+		account := gcpExternalAccount{}
+		if err := json.Unmarshal(raw, &account); err != nil {
+			t.Error(err)
+		}
+		audience = account.Audience
+		return &fakeKMS{key: key}, nil
+	}
+	cfg := config.KMSSigner{
+		KMSRef: "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1",
+		GCP:    config.GCPAuth{Audience: gcpProvider},
+	}
+	if _, err := NewSigner(cfg, logtesting.TestLogger(t)); err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	if audience != gcpProvider {
+		t.Errorf("client created with audience %q, want %q", audience, gcpProvider)
+	}
+	if got := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); got != "/secrets/key.json" {
+		t.Errorf("GOOGLE_APPLICATION_CREDENTIALS = %q after creating the client, want it restored", got)
+	}
+}
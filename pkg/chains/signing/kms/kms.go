@@ -16,11 +16,23 @@ package kms
 import (
 	"context"
 	"crypto"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
 
+	"github.com/tektoncd/chains/pkg/chains/cache"
+	"github.com/tektoncd/chains/pkg/chains/limit"
 	"github.com/tektoncd/chains/pkg/config"
 
 	"github.com/sigstore/sigstore/pkg/signature"
 	"github.com/sigstore/sigstore/pkg/signature/kms"
+	"github.com/sigstore/sigstore/pkg/signature/kms/aws"
+	"github.com/sigstore/sigstore/pkg/signature/kms/azure"
+	"github.com/sigstore/sigstore/pkg/signature/kms/gcp"
+	"github.com/sigstore/sigstore/pkg/signature/kms/hashivault"
 
 	"github.com/tektoncd/chains/pkg/chains/signing"
 	"go.uber.org/zap"
@@ -29,21 +41,221 @@ import (
 // Signer exposes methods to sign payloads using a KMS
 type Signer struct {
 	signature.SignerVerifier
+	ref    string
 	logger *zap.SugaredLogger
+	// vault is set when the signer logged in to Vault with the Kubernetes auth method.
+	vault bool
+	// azure is set for Azure Key Vault keys, whose signatures are retried when they're throttled.
+	azure bool
 }
 
+// The clients of each KMS key, and their public keys, are cached so that they aren't created
+// and fetched again for every TaskRun. The clients cache the keys they sign with themselves.
+var (
+	clients    = cache.New(cache.DefaultTTL)
+	publicKeys = cache.New(cache.DefaultTTL)
+)
+
+// getKMS is set as a var for mocking.
+var getKMS = kms.Get
+
+// envMu keeps the environment from changing while the clients of KMS keys that read their
+// settings from it are created.
+var envMu sync.Mutex
+
 // NewSigner returns a configured Signer
 func NewSigner(cfg config.KMSSigner, logger *zap.SugaredLogger) (*Signer, error) {
-	k, err := kms.Get(context.Background(), cfg.KMSRef, crypto.SHA256)
+	if strings.HasPrefix(cfg.KMSRef, hashivault.ReferenceScheme) && cfg.Vault.Role != "" {
+		return newVaultSigner(cfg, logger)
+	}
+	if strings.HasPrefix(cfg.KMSRef, aws.ReferenceScheme) && (cfg.AWS.Identity != "" || cfg.AWS.Region != "") {
+		return newAWSSigner(cfg, logger)
+	}
+	if strings.HasPrefix(cfg.KMSRef, gcp.ReferenceScheme) && cfg.GCP.Audience != "" {
+		return newGCPSigner(cfg, logger)
+	}
+	k, err := clients.Get(cfg.KMSRef, func() (interface{}, error) {
+		return getKMS(context.Background(), cfg.KMSRef, crypto.SHA256)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{
+		SignerVerifier: k.(signature.SignerVerifier),
+		ref:            cfg.KMSRef,
+		logger:         logger,
+		azure:          strings.HasPrefix(cfg.KMSRef, azure.ReferenceScheme),
+	}, nil
+}
+
+// newVaultSigner returns a Signer for a hashivault key, with a client for the current token of
+// the controller's Vault session.
+func newVaultSigner(cfg config.KMSSigner, logger *zap.SugaredLogger) (*Signer, error) {
+	token, generation, err := vault.Token(context.Background(), cfg.Vault)
+	if err != nil {
+		return nil, err
+	}
+	k, err := clients.Get(fmt.Sprintf("%s#%d", cfg.KMSRef, generation), func() (interface{}, error) {
+		return withEnvironment(map[string]string{"VAULT_TOKEN": token}, func() (interface{}, error) {
+			return getKMS(context.Background(), cfg.KMSRef, crypto.SHA256)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{
+		SignerVerifier: k.(signature.SignerVerifier),
+		ref:            cfg.KMSRef,
+		logger:         logger,
+		vault:          true,
+	}, nil
+}
+
+// newAWSSigner returns a Signer for an awskms key, whose client gets its credentials from the
+// workload identity of the controller.
+func newAWSSigner(cfg config.KMSSigner, logger *zap.SugaredLogger) (*Signer, error) {
+	p, err := newAWSProfile(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("%s#%s/%s/%s/%s#%d", cfg.KMSRef, cfg.AWS.Identity, p.region, cfg.AWS.RoleARN, strings.Join(cfg.AWS.AssumeRoles, ","), p.generation)
+	k, err := clients.Get(id, func() (interface{}, error) {
+		dir, err := ioutil.TempDir("", "chains-kms")
+		if err != nil {
+			return nil, err
+		}
+		// The session reads the files when it's created.
+		defer os.RemoveAll(dir)
+		opts, err := p.sessionOptions(dir)
+		if err != nil {
+			return nil, err
+		}
+		return newAWSKMS(cfg.KMSRef, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{
+		SignerVerifier: k.(signature.SignerVerifier),
+		ref:            cfg.KMSRef,
+		logger:         logger,
+	}, nil
+}
+
+// newGCPSigner returns a Signer for a gcpkms key, whose client gets its credentials from the
+// workload identity federation of cfg.
+func newGCPSigner(cfg config.KMSSigner, logger *zap.SugaredLogger) (*Signer, error) {
+	id := fmt.Sprintf("%s#%s/%s/%s", cfg.KMSRef, cfg.GCP.Audience, cfg.GCP.ServiceAccount, cfg.GCP.TokenPath)
+	return newSignerWithFiles(cfg.KMSRef, id, func(dir string) (map[string]string, error) {
+		return gcpEnvironment(dir, cfg.GCP)
+	}, logger)
+}
+
+// newSignerWithFiles returns a Signer whose client, cached as id, reads its credentials from
+// the files environment writes to a directory, and the environment it returns. The clients read
+// the files when they're created, so they're only kept until then.
+func newSignerWithFiles(ref, id string, environment func(dir string) (map[string]string, error), logger *zap.SugaredLogger) (*Signer, error) {
+	k, err := clients.Get(id, func() (interface{}, error) {
+		dir, err := ioutil.TempDir("", "chains-kms")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		env, err := environment(dir)
+		if err != nil {
+			return nil, err
+		}
+		return withEnvironment(env, func() (interface{}, error) {
+			return getKMS(context.Background(), ref, crypto.SHA256)
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &Signer{
-		SignerVerifier: k,
+		SignerVerifier: k.(signature.SignerVerifier),
+		ref:            ref,
 		logger:         logger,
 	}, nil
 }
 
+// withEnvironment sets env while create runs, and restores the environment after.
+func withEnvironment(env map[string]string, create func() (interface{}, error)) (interface{}, error) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	for name, value := range env {
+		old, ok := os.LookupEnv(name)
+		if value == "" {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, value)
+		}
+		defer func(name, old string, ok bool) {
+			if ok {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name, old, ok)
+	}
+	return create()
+}
+
+// PublicKey returns the public key of the KMS key, which is cached.
+func (s *Signer) PublicKey(opts ...signature.PublicKeyOption) (crypto.PublicKey, error) {
+	return publicKeys.Get(s.ref, func() (interface{}, error) {
+		return s.SignerVerifier.PublicKey(opts...)
+	})
+}
+
+// SignMessage signs with the KMS once the number of calls made to KMSs at the same time
+// allows it, unless the context of opts is done first.
+func (s *Signer) SignMessage(message io.Reader, opts ...signature.SignOption) ([]byte, error) {
+	ctx := context.Background()
+	for _, opt := range opts {
+		opt.ApplyContext(&ctx)
+	}
+	if s.azure {
+		return s.signRetryingThrottled(ctx, message, opts...)
+	}
+	return s.sign(ctx, message, opts...)
+}
+
+func (s *Signer) sign(ctx context.Context, message io.Reader, opts ...signature.SignOption) ([]byte, error) {
+	var sig []byte
+	err := limit.KMS.Do(ctx, func() error {
+		var err error
+		sig, err = s.SignerVerifier.SignMessage(message, opts...)
+		return err
+	})
+	// Vault denies tokens it revoked: the next signer logs in again.
+	if err != nil && s.vault && strings.Contains(err.Error(), "Code: 403") {
+		s.logger.Warnf("Vault denied signing with %s, logging in again for the next signature: %v", s.ref, err)
+		vault.Invalidate()
+	}
+	return sig, err
+}
+
+// referenceValidators check the references of each kind of KMS, by scheme.
+var referenceValidators = map[string]func(string) error{
+	aws.ReferenceScheme:        aws.ValidReference,
+	azure.ReferenceScheme:      azure.ValidReference,
+	gcp.ReferenceScheme:        gcp.ValidReference,
+	hashivault.ReferenceScheme: hashivault.ValidReference,
+}
+
+// ValidReference checks that ref is a well formed reference to a key in a supported KMS,
+// without accessing the KMS.
+func ValidReference(ref string) error {
+	for scheme, validate := range referenceValidators {
+		if strings.HasPrefix(ref, scheme) {
+			return validate(ref)
+		}
+	}
+	return fmt.Errorf("kms reference %q must start with one of %s, %s, %s or %s", ref,
+		aws.ReferenceScheme, azure.ReferenceScheme, gcp.ReferenceScheme, hashivault.ReferenceScheme)
+}
+
 func (s *Signer) Type() string {
 	return signing.TypeKMS
 }
@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/kms"
+	"github.com/sigstore/sigstore/pkg/signature/options"
+	"github.com/tektoncd/chains/pkg/chains/limit"
+	"github.com/tektoncd/chains/pkg/config"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+// fakeKMS counts the public keys asked of it.
+type fakeKMS struct {
+	kms.SignerVerifier
+	key            *ecdsa.PrivateKey
+	publicKeyCalls int
+}
+
+func (f *fakeKMS) PublicKey(...signature.PublicKeyOption) (crypto.PublicKey, error) {
+	f.publicKeyCalls++
+	return f.key.Public(), nil
+}
+
+func TestNewSignerCaches(t *testing.T) {
+	clients.Purge()
+	publicKeys.Purge()
+	t.Cleanup(func() {
+		clients.Purge()
+		publicKeys.Purge()
+	})
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakes := map[string]*fakeKMS{}
+	defer func(f func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error)) { getKMS = f }(getKMS)
+	getKMS = func(_ context.Context, ref string, _ crypto.Hash) (kms.SignerVerifier, error) {
+		if _, ok := fakes[ref]; ok {
+			t.Errorf("client of %s created again", ref)
+		}
+		fakes[ref] = &fakeKMS{key: key}
+		return fakes[ref], nil
+	}
+	logger := logtesting.TestLogger(t)
+
+	for i := 0; i < 3; i++ {
+		for _, ref := range []string{"gcpkms://a", "gcpkms://b"} {
+			s, err := NewSigner(config.KMSSigner{KMSRef: ref}, logger)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, err := s.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.PublicKey.Equal(pub) {
+				t.Errorf("PublicKey() of %s isn't the key of the KMS", ref)
+			}
+		}
+	}
+	for ref, f := range fakes {
+		if f.publicKeyCalls != 1 {
+			t.Errorf("public key of %s asked for %d times, want once", ref, f.publicKeyCalls)
+		}
+	}
+	if len(fakes) != 2 {
+		t.Errorf("%d clients created, want 2", len(fakes))
+	}
+}
+
+func TestSignMessageContext(t *testing.T) {
+	clients.Purge()
+	t.Cleanup(clients.Purge)
+	defer func(f func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error)) { getKMS = f }(getKMS)
+	f := &throttledKMS{}
+	getKMS = func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error) { return f, nil }
+	s, err := NewSigner(config.KMSSigner{KMSRef: "gcpkms://a"}, logtesting.TestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another call holds the only KMS call allowed at a time.
+	defer limit.KMS.SetLimit(0)
+	limit.KMS.SetLimit(1)
+	if err := limit.KMS.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer limit.KMS.Release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.SignMessage(bytes.NewReader([]byte("payload")), options.WithContext(ctx)); !errors.Is(err, context.Canceled) {
+		t.Errorf("SignMessage() error = %v, want the error of the context it's waiting in", err)
+	}
+	if len(f.messages) != 0 {
+		t.Errorf("signed %q while waiting for the KMS", f.messages)
+	}
+}
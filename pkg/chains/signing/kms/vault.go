@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// serviceAccountTokenPath is the token of the controller's service account, which it logs in to
+// Vault with. It's a var for testing.
+var serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// clock is set for testing.
+var clock = time.Now
+
+// vault is the session of the controller with Vault, when it logs in with the Kubernetes auth
+// method instead of a static VAULT_TOKEN.
+var vault = &vaultSession{}
+
+// vaultSession holds the Vault token the controller logged in for. It's renewed once two thirds
+// of its lease have passed, and the controller logs in again when it can't be renewed, so signing
+// carries on when tokens expire.
+type vaultSession struct {
+	mu        sync.Mutex
+	auth      config.VaultAuth
+	token     string
+	renewable bool
+	lease     time.Duration
+	expires   time.Time
+	// generation counts the tokens logged in for. The clients of hashivault keys are cached for
+	// each, since they only read VAULT_TOKEN when they're created.
+	generation int
+}
+
+// vaultAuthResponse is the part of Vault's login and renewal responses with the token.
+type vaultAuthResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// Token returns a Vault token for the role of auth, and its generation.
+func (s *vaultSession) Token(ctx context.Context, auth config.VaultAuth) (string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := clock()
+	if s.token != "" && s.auth == auth {
+		if now.Before(s.expires.Add(-s.lease / 3)) {
+			return s.token, s.generation, nil
+		}
+		if s.renewable && now.Before(s.expires) {
+			if resp, err := vaultAuth(ctx, "auth/token/renew-self", s.token, map[string]string{}); err == nil {
+				s.set(auth, resp, now)
+				return s.token, s.generation, nil
+			}
+		}
+	}
+	jwt, err := ioutil.ReadFile(serviceAccountTokenPath)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "reading the service account token to log in to vault with")
+	}
+	login := map[string]string{"role": auth.Role, "jwt": strings.TrimSpace(string(jwt))}
+	resp, err := vaultAuth(ctx, fmt.Sprintf("auth/%s/login", strings.Trim(auth.Path, "/")), "", login)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "logging in to vault as role %s", auth.Role)
+	}
+	s.set(auth, resp, now)
+	s.generation++
+	return s.token, s.generation, nil
+}
+
+// Invalidate forgets the token, so the next signer logs in again, e.g. once Vault has revoked it.
+func (s *vaultSession) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+func (s *vaultSession) set(auth config.VaultAuth, resp *vaultAuthResponse, now time.Time) {
+	s.auth = auth
+	s.token = resp.Auth.ClientToken
+	s.renewable = resp.Auth.Renewable
+	s.lease = time.Duration(resp.Auth.LeaseDuration) * time.Second
+	s.expires = now.Add(s.lease)
+}
+
+// vaultAuth calls an auth endpoint of the Vault at VAULT_ADDR, with token if it's set.
+func vaultAuth(ctx context.Context, path, token string, body interface{}) (*vaultAuthResponse, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return nil, errors.New("VAULT_ADDR is not set")
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(addr, "/")+"/v1/"+path, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s for %s", resp.Status, path)
+	}
+	auth := &vaultAuthResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(auth); err != nil {
+		return nil, errors.Wrapf(err, "decoding the response to %s", path)
+	}
+	if auth.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("vault returned no token for %s", path)
+	}
+	return auth, nil
+}
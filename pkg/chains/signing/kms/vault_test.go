@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/signature/kms"
+	"github.com/tektoncd/chains/pkg/config"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+// fakeVault logs in the role tekton-chains, and renews the tokens it handed out unless failRenew
+// is set.
+type fakeVault struct {
+	logins, renewals int
+	failRenew        bool
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var token string
+	switch r.URL.Path {
+	case "/v1/auth/kubernetes/login":
+		if body["role"] != "tekton-chains" || body["jwt"] != "sa-token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		f.logins++
+		token = fmt.Sprintf("token-%d", f.logins)
+	case "/v1/auth/token/renew-self":
+		if f.failRenew {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		f.renewals++
+		token = r.Header.Get("X-Vault-Token")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprintf(w, `{"auth": {"client_token": %q, "lease_duration": 60, "renewable": true}}`, token)
+}
+
+// withFakeVault points the Vault session at a fake Vault, with a clock the test moves.
+func withFakeVault(t *testing.T) (*fakeVault, *time.Time) {
+	t.Helper()
+	f := &fakeVault{}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenPath, []byte("sa-token\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	oldPath, oldNow, oldAddr := serviceAccountTokenPath, clock, os.Getenv("VAULT_ADDR")
+	serviceAccountTokenPath = tokenPath
+	clock = func() time.Time { return now }
+	vault = &vaultSession{}
+	os.Setenv("VAULT_ADDR", server.URL)
+	t.Cleanup(func() {
+		serviceAccountTokenPath, clock = oldPath, oldNow
+		vault = &vaultSession{}
+		os.Setenv("VAULT_ADDR", oldAddr)
+	})
+	return f, &now
+}
+
+func TestVaultSession(t *testing.T) {
+	f, now := withFakeVault(t)
+	ctx := context.Background()
+	auth := config.VaultAuth{Role: "tekton-chains", Path: "kubernetes"}
+
+	steps := []struct {
+		name           string
+		after          time.Duration
+		failRenew      bool
+		wantToken      string
+		wantGeneration int
+		wantLogins     int
+		wantRenewals   int
+	}{
+		{name: "logs in", wantToken: "token-1", wantGeneration: 1, wantLogins: 1},
+		{name: "reuses the token", after: 30 * time.Second, wantToken: "token-1", wantGeneration: 1, wantLogins: 1},
+		{name: "renews the token", after: 15 * time.Second, wantToken: "token-1", wantGeneration: 1, wantLogins: 1, wantRenewals: 1},
+		{name: "logs in again once the token expired", after: 2 * time.Minute, wantToken: "token-2", wantGeneration: 2, wantLogins: 2, wantRenewals: 1},
+		{name: "logs in again when renewing fails", after: 45 * time.Second, failRenew: true, wantToken: "token-3", wantGeneration: 3, wantLogins: 3, wantRenewals: 1},
+	}
+	for _, s := range steps {
+		*now = now.Add(s.after)
+		f.failRenew = s.failRenew
+		token, generation, err := vault.Token(ctx, auth)
+		if err != nil {
+			t.Fatalf("%s: Token() error = %v", s.name, err)
+		}
+		if token != s.wantToken || generation != s.wantGeneration {
+			t.Errorf("%s: Token() = %s, %d, want %s, %d", s.name, token, generation, s.wantToken, s.wantGeneration)
+		}
+		if f.logins != s.wantLogins || f.renewals != s.wantRenewals {
+			t.Errorf("%s: %d logins and %d renewals, want %d and %d", s.name, f.logins, f.renewals, s.wantLogins, s.wantRenewals)
+		}
+	}
+
+	vault.Invalidate()
+	if token, _, err := vault.Token(ctx, auth); err != nil || token != "token-4" {
+		t.Errorf("Token() after Invalidate() = %s, %v, want token-4", token, err)
+	}
+	if _, _, err := vault.Token(ctx, config.VaultAuth{Role: "other", Path: "kubernetes"}); err == nil {
+		t.Error("expected an error logging in as a role vault denies")
+	}
+}
+
+func TestNewSignerVault(t *testing.T) {
+	_, now := withFakeVault(t)
+	clients.Purge()
+	t.Cleanup(clients.Purge)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken := os.Getenv("VAULT_TOKEN")
+	defer os.Setenv("VAULT_TOKEN", oldToken)
+	var created []string
+	defer func(f func(context.Context, string, crypto.Hash) (kms.SignerVerifier, error)) { getKMS = f }(getKMS)
+	getKMS = func(_ context.Context, ref string, _ crypto.Hash) (kms.SignerVerifier, error) {
+		created = append(created, os.Getenv("VAULT_TOKEN"))
+		return &fakeKMS{key: key}, nil
+	}
+	cfg := config.KMSSigner{KMSRef: "hashivault://chains", Vault: config.VaultAuth{Role: "tekton-chains", Path: "kubernetes"}}
+	logger := logtesting.TestLogger(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := NewSigner(cfg, logger); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A client is created with the token of the next login.
+	*now = now.Add(2 * time.Minute)
+	if _, err := NewSigner(cfg, logger); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(created) != "[token-1 token-2]" {
+		t.Errorf("clients created with tokens %v, want [token-1 token-2]", created)
+	}
+}
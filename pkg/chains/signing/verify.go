@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signing
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// Verify checks a signature made by a Signer over payload, and returns the content that was signed.
+// Signatures made by a wrapped Signer are DSSE envelopes, which are checked against the payload they carry.
+func Verify(v signature.Verifier, sig, payload []byte) ([]byte, error) {
+	env := dsse.Envelope{}
+	if !decodeEnvelope(sig, &env) {
+		if err := v.VerifySignature(bytes.NewReader(sig), bytes.NewReader(payload)); err != nil {
+			return nil, errors.Wrap(err, "verifying signature")
+		}
+		return payload, nil
+	}
+
+	body, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding envelope payload")
+	}
+	if !bytes.Equal(body, payload) {
+		return nil, errors.New("envelope payload does not match the stored payload")
+	}
+	pae := dsse.PAE(env.PayloadType, string(body))
+	for _, s := range env.Signatures {
+		raw, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+		if err := v.VerifySignature(bytes.NewReader(raw), bytes.NewReader(pae)); err == nil {
+			return body, nil
+		}
+	}
+	return nil, errors.New("no signature in the envelope could be verified")
+}
+
+// IsEnvelope returns whether a signature is the DSSE envelope of a wrapped Signer.
+func IsEnvelope(sig []byte) bool {
+	return decodeEnvelope(sig, &dsse.Envelope{})
+}
+
+func decodeEnvelope(sig []byte, env *dsse.Envelope) bool {
+	return json.Unmarshal(sig, env) == nil && env.PayloadType != ""
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+type testSigner struct {
+	signature.SignerVerifier
+}
+
+func (s *testSigner) Type() string  { return TypeX509 }
+func (s *testSigner) Cert() string  { return "" }
+func (s *testSigner) Chain() string { return "" }
+
+func newTestSigner(t *testing.T) Signer {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sv, err := signature.LoadECDSASignerVerifier(priv, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{SignerVerifier: sv}
+}
+
+func TestVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	other := newTestSigner(t)
+	wrapped, err := Wrap(context.Background(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"hello":"world"}`)
+
+	tests := []struct {
+		name     string
+		signer   Signer
+		verifier signature.Verifier
+		payload  []byte
+		wantErr  bool
+	}{
+		{
+			name:     "raw signature",
+			signer:   signer,
+			verifier: signer,
+			payload:  payload,
+		},
+		{
+			name:     "envelope",
+			signer:   wrapped,
+			verifier: signer,
+			payload:  payload,
+		},
+		{
+			name:     "raw signature, wrong key",
+			signer:   signer,
+			verifier: other,
+			payload:  payload,
+			wantErr:  true,
+		},
+		{
+			name:     "envelope, wrong key",
+			signer:   wrapped,
+			verifier: other,
+			payload:  payload,
+			wantErr:  true,
+		},
+		{
+			name:     "envelope, different payload stored",
+			signer:   wrapped,
+			verifier: signer,
+			payload:  []byte(`{"hello":"there"}`),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, err := tt.signer.SignMessage(bytes.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Verify(tt.verifier, sig, tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, payload) {
+				t.Errorf("Verify() = %s, want %s", got, payload)
+			}
+		})
+	}
+}
+
+func TestCountersign(t *testing.T) {
+	team := newTestSigner(t)
+	org := newTestSigner(t)
+	wrapped, err := Wrap(context.Background(), team)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := wrapped.SignMessage(bytes.NewReader([]byte(`{"hello":"world"}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	countersignature, err := Countersign(org, envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Verify(org, countersignature, envelope)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !bytes.Equal(got, envelope) {
+		t.Errorf("Verify() = %s, want the countersigned envelope %s", got, envelope)
+	}
+	if _, err := Verify(team, countersignature, envelope); err == nil {
+		t.Error("expected the countersignature not to verify with the key of the envelope")
+	}
+	if _, err := Verify(org, countersignature, []byte(`{"payloadType":"other"}`)); err == nil {
+		t.Error("expected the countersignature not to verify another envelope")
+	}
+}
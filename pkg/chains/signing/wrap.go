@@ -25,49 +25,82 @@ import (
 	"github.com/in-toto/in-toto-golang/in_toto"
 	"github.com/secure-systems-lab/go-securesystemslib/dsse"
 	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/sigstore/sigstore/pkg/signature/options"
 
 	"golang.org/x/crypto/ssh"
 )
 
+// CountersignaturePayloadType is the payload type of the envelopes countersigning a DSSE
+// envelope, which is their payload.
+const CountersignaturePayloadType = "application/vnd.dsse.envelope.v1+json"
+
 func Wrap(ctx context.Context, s Signer) (Signer, error) {
-	pub, err := s.PublicKey()
+	pub, keyID, err := keyOf(s)
 	if err != nil {
 		return nil, err
 	}
+	return &sslSigner{
+		wrapped: s,
+		keyID:   keyID,
+		typ:     s.Type(),
+		pub:     pub,
+		cert:    s.Cert(),
+		chain:   s.Chain(),
+	}, nil
+}
 
-	// Generate public key fingerprint
-	sshpk, err := ssh.NewPublicKey(pub)
+// Countersign signs the DSSE envelope made by another signer with s, e.g. an organization's key
+// over the signature of a team's. It returns a DSSE envelope whose payload is envelope.
+func Countersign(s Signer, envelope []byte, opts ...signature.SignOption) ([]byte, error) {
+	_, keyID, err := keyOf(s)
+	if err != nil {
+		return nil, err
+	}
+	es, err := envelopeSigner(s, keyID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	env, err := es.SignPayload(CountersignaturePayloadType, envelope)
 	if err != nil {
 		return nil, err
 	}
-	fingerprint := ssh.FingerprintSHA256(sshpk)
+	return json.Marshal(env)
+}
 
-	adapter := sslAdapter{
-		wrapped: s,
-		KeyID:   fingerprint,
+// keyOf returns the public key of s, and its fingerprint, which is the key ID of its signatures.
+func keyOf(s Signer) (crypto.PublicKey, string, error) {
+	pub, err := s.PublicKey()
+	if err != nil {
+		return nil, "", err
 	}
 
-	envelope, err := dsse.NewEnvelopeSigner(&adapter)
+	// Generate public key fingerprint
+	sshpk, err := ssh.NewPublicKey(pub)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return &sslSigner{
-		wrapper: envelope,
-		typ:     s.Type(),
-		pub:     pub,
-		cert:    s.Cert(),
-		chain:   s.Chain(),
-	}, nil
+	return pub, ssh.FingerprintSHA256(sshpk), nil
+}
+
+// envelopeSigner returns a DSSE envelope signer that signs with s and opts, as keyID.
+func envelopeSigner(s Signer, keyID string, opts ...signature.SignOption) (*dsse.EnvelopeSigner, error) {
+	return dsse.NewEnvelopeSigner(&sslAdapter{
+		wrapped: s,
+		KeyID:   keyID,
+		opts:    opts,
+	})
 }
 
 // sslAdapter converts our signing objects into the type expected by the Envelope signer for wrapping.
 type sslAdapter struct {
 	wrapped Signer
 	KeyID   string
+	// opts are passed on to the wrapped signer, e.g. the context it signs in.
+	opts []signature.SignOption
 }
 
 func (w *sslAdapter) Sign(data []byte) ([]byte, string, error) {
-	sig, err := w.wrapped.SignMessage(bytes.NewReader(data))
+	sig, err := w.wrapped.SignMessage(bytes.NewReader(data), w.opts...)
 	return sig, w.KeyID, err
 }
 
@@ -77,7 +110,8 @@ func (w *sslAdapter) Verify(keyID string, data, sig []byte) error {
 
 // sslSigner converts the EnvelopeSigners back into our types, after wrapping.
 type sslSigner struct {
-	wrapper *dsse.EnvelopeSigner
+	wrapped Signer
+	keyID   string
 	typ     string
 	pub     crypto.PublicKey
 	cert    string
@@ -92,7 +126,11 @@ func (s *sslSigner) PublicKey(opts ...signature.PublicKeyOption) (crypto.PublicK
 }
 
 func (s *sslSigner) Sign(ctx context.Context, payload []byte) ([]byte, []byte, error) {
-	env, err := s.wrapper.SignPayload(in_toto.PayloadType, payload)
+	es, err := envelopeSigner(s.wrapped, s.keyID, options.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	env, err := es.SignPayload(in_toto.PayloadType, payload)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -108,7 +146,11 @@ func (s *sslSigner) SignMessage(payload io.Reader, opts ...signature.SignOption)
 	if err != nil {
 		return nil, err
 	}
-	env, err := s.wrapper.SignPayload(in_toto.PayloadType, m)
+	es, err := envelopeSigner(s.wrapped, s.keyID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	env, err := es.SignPayload(in_toto.PayloadType, m)
 	if err != nil {
 		return nil, err
 	}
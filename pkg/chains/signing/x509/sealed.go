@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package x509
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/chains/cache"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// The keys unsealed with Cloud KMS are kept in memory, so they aren't decrypted again for every
+// TaskRun. They're only ever held in memory.
+var unsealed = cache.New(cache.DefaultTTL)
+
+// cloudKMSEndpoint is the Cloud KMS API keys are decrypted with.
+const cloudKMSEndpoint = "https://cloudkms.googleapis.com/v1/"
+
+// decrypt is set as a var for mocking.
+var decrypt = func(ctx context.Context, key string, ciphertext []byte) ([]byte, error) {
+	client, _, err := htransport.NewClient(ctx, option.WithScopes("https://www.googleapis.com/auth/cloudkms"))
+	if err != nil {
+		return nil, errors.Wrap(err, "creating the Cloud KMS client")
+	}
+	// []byte fields are base64 in JSON, as the API wants them.
+	body, err := json.Marshal(struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}{ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cloudKMSEndpoint+key+":decrypt", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cloud kms returned %s", resp.Status)
+	}
+	var decrypted struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decrypted); err != nil {
+		return nil, errors.Wrap(err, "decoding the decrypted key")
+	}
+	return decrypted.Plaintext, nil
+}
+
+// unseal returns the private key in the file named name of the signing secret, decrypted with
+// the Cloud KMS key sealingKey, or as it is when there is no sealing key.
+func unseal(sealingKey, name string, contents []byte) ([]byte, error) {
+	if sealingKey == "" {
+		return contents, nil
+	}
+	id := fmt.Sprintf("%s/%x", sealingKey, sha256.Sum256(contents))
+	key, err := unsealed.Get(id, func() (interface{}, error) {
+		return decrypt(context.Background(), sealingKey, contents)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unsealing %s with %s", name, sealingKey)
+	}
+	return key.([]byte), nil
+}
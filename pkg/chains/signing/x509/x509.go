@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/url"
+	"os"
 	"path/filepath"
 
 	"google.golang.org/api/idtoken"
@@ -34,6 +35,7 @@ import (
 	"github.com/tektoncd/chains/pkg/chains/signing"
 	"github.com/tektoncd/chains/pkg/config"
 	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
 )
 
 // Signer exposes methods to sign payloads.
@@ -46,40 +48,77 @@ type Signer struct {
 
 // NewSigner returns a configured Signer
 func NewSigner(secretPath string, cfg config.Config, logger *zap.SugaredLogger) (*Signer, error) {
-	x509PrivateKeyPath := filepath.Join(secretPath, "x509.pem")
-	cosignPrivateKeypath := filepath.Join(secretPath, "cosign.key")
+	return NewSignerWithPrefix(secretPath, "", cfg, logger)
+}
 
-	if cfg.Signers.X509.FulcioEnabled {
-		return fulcioSigner(cfg.Signers.X509.FulcioAuth, cfg.Signers.X509.FulcioAddr, logger)
-	} else if contents, err := ioutil.ReadFile(x509PrivateKeyPath); err == nil {
-		return x509Signer(contents, logger)
-	} else if contents, err := ioutil.ReadFile(cosignPrivateKeypath); err == nil {
-		return cosignSigner(secretPath, contents, logger)
+// NewSignerWithPrefix returns a Signer whose keys are read from the files of the signing
+// secret whose names start with prefix, e.g. release.x509.pem, see config.SignerProfile.
+func NewSignerWithPrefix(secretPath, prefix string, cfg config.Config, logger *zap.SugaredLogger) (*Signer, error) {
+	return NewSignerWithIdentity(secretPath, prefix, cfg, nil, logger)
+}
+
+// IdentityToken returns an OIDC token for audience, whose identity Fulcio certifies.
+type IdentityToken func(ctx context.Context, audience string) (string, error)
+
+// NewSignerWithIdentity returns a Signer like NewSignerWithPrefix. With the serviceaccount
+// auth, its Fulcio certificate is for the identity of the token identity returns.
+func NewSignerWithIdentity(secretPath, prefix string, cfg config.Config, identity IdentityToken, logger *zap.SugaredLogger) (*Signer, error) {
+	return NewSignerWithKeys(KeysIn(secretPath), prefix, cfg, identity, logger)
+}
+
+// Keys returns the contents of the key file with a name, e.g. x509.pem, or an error
+// os.IsNotExist recognizes when there's no such file.
+type Keys func(name string) ([]byte, error)
+
+// KeysIn returns the Keys in the files of the directory a signing secret is mounted at.
+func KeysIn(secretPath string) Keys {
+	return func(name string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(secretPath, name))
 	}
-	return nil, errors.New("no valid private key found, looked for: [x509.pem, cosign.key]")
 }
 
-func fulcioSigner(auth, addr string, logger *zap.SugaredLogger) (*Signer, error) {
-	if auth != "google" {
-		return nil, errors.New(fmt.Sprintf("%s is not yet implemented as an authorization scheme for the fulcio signer", auth))
+// KeysOf returns the Keys in the data of secret, whose keys are the names of the files.
+func KeysOf(secret *corev1.Secret) Keys {
+	return func(name string) ([]byte, error) {
+		if contents, ok := secret.Data[name]; ok {
+			return contents, nil
+		}
+		return nil, &os.PathError{Op: "read", Path: fmt.Sprintf("secret %s/%s: %s", secret.Namespace, secret.Name, name), Err: os.ErrNotExist}
 	}
-	logger.Info("Signing with fulcio ...")
+}
 
-	ts, err := idtoken.NewTokenSource(context.Background(), "sigstore")
-	if err != nil {
-		return nil, errors.Wrap(err, "new token source")
+// NewSignerWithKeys returns a Signer like NewSignerWithIdentity, whose keys are read from keys
+// instead of the files of the signing secret.
+func NewSignerWithKeys(keys Keys, prefix string, cfg config.Config, identity IdentityToken, logger *zap.SugaredLogger) (*Signer, error) {
+	if cfg.Signers.X509.FulcioEnabled {
+		return fulcioSigner(cfg.Signers.X509, identity, logger)
+	} else if contents, err := keys(prefix + "x509.pem"); err == nil {
+		if contents, err = unseal(cfg.Signers.X509.SealingKey, prefix+"x509.pem", contents); err != nil {
+			return nil, err
+		}
+		return x509Signer(contents, logger)
+	} else if contents, err := keys(prefix + "cosign.key"); err == nil {
+		if contents, err = unseal(cfg.Signers.X509.SealingKey, prefix+"cosign.key", contents); err != nil {
+			return nil, err
+		}
+		return cosignSigner(keys, prefix, contents, logger)
 	}
-	tok, err := ts.Token()
+	return nil, fmt.Errorf("no valid private key found, looked for: [%sx509.pem, %scosign.key]", prefix, prefix)
+}
+
+func fulcioSigner(cfg config.X509Signer, identity IdentityToken, logger *zap.SugaredLogger) (*Signer, error) {
+	token, err := identityToken(cfg, identity)
 	if err != nil {
-		return nil, errors.Wrap(err, "getting token")
+		return nil, err
 	}
+	logger.Info("Signing with fulcio ...")
 
-	u, err := url.Parse(addr)
+	u, err := url.Parse(cfg.FulcioAddr)
 	if err != nil {
 		return nil, errors.Wrap(err, "new fulcio client")
 	}
 	client := client.New(u)
-	k, err := fulcio.NewSigner(context.Background(), tok.AccessToken, "https://oauth2.sigstore.dev/auth", "sigstore", client)
+	k, err := fulcio.NewSigner(context.Background(), token, "https://oauth2.sigstore.dev/auth", cfg.FulcioAudience, client)
 	if err != nil {
 		return nil, errors.Wrap(err, "new signer")
 	}
@@ -91,10 +130,37 @@ func fulcioSigner(auth, addr string, logger *zap.SugaredLogger) (*Signer, error)
 	}, nil
 }
 
+// identityToken returns the OIDC token Fulcio certifies the identity of: the controller's Google
+// identity, or the one identity returns for the serviceaccount auth.
+func identityToken(cfg config.X509Signer, identity IdentityToken) (string, error) {
+	switch cfg.FulcioAuth {
+	case config.FulcioAuthGoogle:
+		ts, err := idtoken.NewTokenSource(context.Background(), cfg.FulcioAudience)
+		if err != nil {
+			return "", errors.Wrap(err, "new token source")
+		}
+		tok, err := ts.Token()
+		if err != nil {
+			return "", errors.Wrap(err, "getting token")
+		}
+		return tok.AccessToken, nil
+	case config.FulcioAuthServiceAccount:
+		if identity == nil {
+			return "", errors.New("the serviceaccount authorization scheme needs the identity of a TaskRun")
+		}
+		tok, err := identity(context.Background(), cfg.FulcioAudience)
+		return tok, errors.Wrap(err, "getting token")
+	}
+	return "", fmt.Errorf("%s is not yet implemented as an authorization scheme for the fulcio signer", cfg.FulcioAuth)
+}
+
 func x509Signer(privateKey []byte, logger *zap.SugaredLogger) (*Signer, error) {
 	logger.Info("Found x509 key...")
 
 	p, _ := pem.Decode(privateKey)
+	if p == nil {
+		return nil, errors.New("expected a PEM private key")
+	}
 	if p.Type != "PRIVATE KEY" {
 		return nil, fmt.Errorf("expected private key, found object of type %s", p.Type)
 	}
@@ -109,12 +175,11 @@ func x509Signer(privateKey []byte, logger *zap.SugaredLogger) (*Signer, error) {
 	return &Signer{SignerVerifier: signer, logger: logger}, nil
 }
 
-func cosignSigner(secretPath string, privateKey []byte, logger *zap.SugaredLogger) (*Signer, error) {
+func cosignSigner(keys Keys, prefix string, privateKey []byte, logger *zap.SugaredLogger) (*Signer, error) {
 	logger.Info("Found cosign key...")
-	cosignPasswordPath := filepath.Join(secretPath, "cosign.password")
-	password, err := ioutil.ReadFile(cosignPasswordPath)
+	password, err := keys(prefix + "cosign.password")
 	if err != nil {
-		return nil, errors.Wrap(err, "reading cosign.password file")
+		return nil, errors.Wrapf(err, "reading %scosign.password file", prefix)
 	}
 	signer, err := cosign.LoadECDSAPrivateKey(privateKey, password)
 	if err != nil {
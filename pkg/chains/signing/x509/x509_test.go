@@ -15,15 +15,21 @@ package x509
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/tektoncd/chains/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	logtesting "knative.dev/pkg/logging/testing"
 )
 
@@ -124,3 +130,80 @@ func TestSigner_SignED25519(t *testing.T) {
 		t.Error("invalid signature")
 	}
 }
+
+func TestIdentityToken(t *testing.T) {
+	cfg := config.X509Signer{FulcioAuth: config.FulcioAuthServiceAccount, FulcioAudience: "fulcio.example.com"}
+	if _, err := identityToken(cfg, nil); err == nil {
+		t.Error("expected an error for the serviceaccount auth without an identity")
+	}
+
+	var audience string
+	identity := func(ctx context.Context, aud string) (string, error) {
+		audience = aud
+		return "token", nil
+	}
+	got, err := identityToken(cfg, identity)
+	if err != nil {
+		t.Fatalf("identityToken() error = %v", err)
+	}
+	if got != "token" || audience != "fulcio.example.com" {
+		t.Errorf("identityToken() = %q for audience %q", got, audience)
+	}
+
+	cfg.FulcioAuth = "github"
+	if _, err := identityToken(cfg, identity); err == nil {
+		t.Error("expected an error for an unknown auth")
+	}
+}
+
+func TestSigner_Sealed(t *testing.T) {
+	logger := logtesting.TestLogger(t)
+	d := t.TempDir()
+	// The mock KMS "encrypts" by base64 encoding.
+	sealed := base64.StdEncoding.EncodeToString([]byte(ecdsaPriv))
+	if err := ioutil.WriteFile(filepath.Join(d, "x509.pem"), []byte(sealed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const sealingKey = "projects/p/locations/global/keyRings/r/cryptoKeys/seal"
+	calls := 0
+	defer func(d func(context.Context, string, []byte) ([]byte, error)) { decrypt = d }(decrypt)
+	decrypt = func(ctx context.Context, key string, ciphertext []byte) ([]byte, error) {
+		calls++
+		if key != sealingKey {
+			return nil, fmt.Errorf("unexpected key %s", key)
+		}
+		return base64.StdEncoding.DecodeString(string(ciphertext))
+	}
+	defer unsealed.Purge()
+
+	if _, err := NewSigner(d, config.Config{}, logger); err == nil {
+		t.Error("expected an error loading a sealed key without the sealing key")
+	}
+	cfg := config.Config{Signers: config.SignerConfigs{X509: config.X509Signer{SealingKey: sealingKey}}}
+	for i := 0; i < 2; i++ {
+		if _, err := NewSigner(d, cfg, logger); err != nil {
+			t.Fatalf("NewSigner() error = %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("the key was decrypted %d times, want it decrypted once", calls)
+	}
+}
+
+func TestNewSignerWithKeys(t *testing.T) {
+	logger := logtesting.TestLogger(t)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "signing-secrets", Namespace: "team-a"},
+		Data:       map[string][]byte{"release.x509.pem": []byte(ecdsaPriv)},
+	}
+	keys := KeysOf(secret)
+	if _, err := NewSignerWithKeys(keys, "release.", config.Config{}, nil, logger); err != nil {
+		t.Fatalf("NewSignerWithKeys() error = %v", err)
+	}
+	if _, err := NewSignerWithKeys(keys, "", config.Config{}, nil, logger); err == nil {
+		t.Error("expected an error without the keys of the prefix")
+	}
+	if _, err := keys("cosign.password"); !os.IsNotExist(err) {
+		t.Errorf("keys() error = %v for a file that isn't in the secret, want one os.IsNotExist recognizes", err)
+	}
+}
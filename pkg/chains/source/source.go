@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package source creates source attestations: in-toto statements about the git commit a
+// TaskRun built, recording where the commit came from and what submitted the build, so the
+// source can be verified separately from the build provenance.
+package source
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+// PredicateSource is the predicate type of source attestations.
+const PredicateSource = "https://tekton.dev/chains/source@v1"
+
+// The parameters and results that describe the source of a TaskRun. The commit and URL are
+// the ones materials are recorded from.
+const (
+	commitHint    = "CHAINS-GIT_COMMIT"
+	urlHint       = "CHAINS-GIT_URL"
+	refHint       = "CHAINS-GIT_REF"
+	committerHint = "CHAINS-GIT_COMMITTER"
+)
+
+// The labels Tekton Triggers sets on the runs it creates.
+const (
+	eventIDLabel       = "triggers.tekton.dev/triggers-eventid"
+	eventListenerLabel = "triggers.tekton.dev/eventlistener"
+	triggerLabel       = "triggers.tekton.dev/trigger"
+)
+
+// Statement is an in-toto statement with a source predicate.
+type Statement struct {
+	intoto.StatementHeader
+	Predicate Predicate `json:"predicate"`
+}
+
+// Predicate describes where a commit came from, and what submitted it to be built.
+type Predicate struct {
+	// Attester identifies Chains as what made the attestation.
+	Attester Attester `json:"attester"`
+	// Repository is the URL of the git repository.
+	Repository string `json:"repository"`
+	// Ref is the branch or tag the commit was checked out from, e.g. refs/heads/main.
+	Ref string `json:"ref,omitempty"`
+	// Revision is the commit.
+	Revision string `json:"revision"`
+	// Committer is who committed the commit.
+	Committer string `json:"committer,omitempty"`
+	// Submission is what submitted the commit to be built.
+	Submission Submission `json:"submission"`
+}
+
+// Attester identifies what made an attestation.
+type Attester struct {
+	ID string `json:"id"`
+}
+
+// Submission is the context the commit was submitted to be built in.
+type Submission struct {
+	// TaskRun is the namespace and name of the TaskRun that built the commit.
+	TaskRun string `json:"taskRun"`
+	// PipelineRun is the name of the PipelineRun the TaskRun ran in, if any.
+	PipelineRun string `json:"pipelineRun,omitempty"`
+	// EventID is the ID Tekton Triggers gave the event that started the build, if any.
+	EventID string `json:"eventID,omitempty"`
+	// EventListener is the Tekton Triggers EventListener that received the event, if any.
+	EventListener string `json:"eventListener,omitempty"`
+	// Trigger is the Tekton Triggers Trigger that created the run, if any.
+	Trigger string `json:"trigger,omitempty"`
+}
+
+// NewStatement creates a source attestation for the commit the TaskRun built. It returns
+// false when the TaskRun doesn't name a repository and commit.
+func NewStatement(tr *v1beta1.TaskRun, attesterID string) (Statement, bool, error) {
+	commit, url := hint(tr, commitHint), hint(tr, urlHint)
+	if commit == "" || url == "" {
+		return Statement{}, false, nil
+	}
+	// Git commits are sha1 digests. Anything else, such as a branch, isn't immutable.
+	commit = strings.ToLower(commit)
+	if b, err := hex.DecodeString(commit); err != nil || len(b) != 20 {
+		return Statement{}, false, fmt.Errorf("%s %q is not a git commit", commitHint, commit)
+	}
+	return Statement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: PredicateSource,
+			Subject: []intoto.Subject{{
+				Name:   url,
+				Digest: slsa.DigestSet{"sha1": commit},
+			}},
+		},
+		Predicate: Predicate{
+			Attester:   Attester{ID: attesterID},
+			Repository: url,
+			Ref:        hint(tr, refHint),
+			Revision:   commit,
+			Committer:  hint(tr, committerHint),
+			Submission: Submission{
+				TaskRun:       fmt.Sprintf("%s/%s", tr.Namespace, tr.Name),
+				PipelineRun:   tr.Labels[pipeline.PipelineRunLabelKey],
+				EventID:       tr.Labels[eventIDLabel],
+				EventListener: tr.Labels[eventListenerLabel],
+				Trigger:       tr.Labels[triggerLabel],
+			},
+		},
+	}, true, nil
+}
+
+// hint finds the value of a parameter or result, like the formatters find the git commit
+// and URL: results take precedence over the defaults of the Task, which take precedence
+// over the parameters of the TaskRun.
+func hint(tr *v1beta1.TaskRun, name string) (value string) {
+	for _, p := range tr.Spec.Params {
+		if p.Name == name {
+			value = p.Value.StringVal
+		}
+	}
+	if tr.Status.TaskSpec != nil {
+		for _, p := range tr.Status.TaskSpec.Params {
+			if p.Name == name && p.Default != nil {
+				value = p.Default.StringVal
+			}
+		}
+	}
+	for _, r := range tr.Status.TaskRunResults {
+		if r.Name == name {
+			value = r.Value
+		}
+	}
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package source
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const commit = "c4b75d454655c1755ab116947e88a59ac03e28a9"
+
+func TestNewStatement(t *testing.T) {
+	tests := []struct {
+		name    string
+		tr      *v1beta1.TaskRun
+		want    Statement
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name: "params and results",
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "ns",
+					Name:      "build-abc",
+					Labels: map[string]string{
+						"tekton.dev/pipelineRun":               "build",
+						"triggers.tekton.dev/triggers-eventid": "1234",
+						"triggers.tekton.dev/eventlistener":    "github",
+						"triggers.tekton.dev/trigger":          "push",
+					},
+				},
+				Spec: v1beta1.TaskRunSpec{
+					Params: []v1beta1.Param{
+						{Name: "CHAINS-GIT_URL", Value: *v1beta1.NewArrayOrString("https://github.com/org/repo")},
+						{Name: "CHAINS-GIT_REF", Value: *v1beta1.NewArrayOrString("refs/heads/main")},
+					},
+				},
+				Status: v1beta1.TaskRunStatus{
+					TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+						TaskRunResults: []v1beta1.TaskRunResult{
+							{Name: "CHAINS-GIT_COMMIT", Value: commit + "\n"},
+							{Name: "CHAINS-GIT_COMMITTER", Value: "Jane Doe <jane@example.com>"},
+						},
+					},
+				},
+			},
+			want: Statement{
+				StatementHeader: intoto.StatementHeader{
+					Type:          intoto.StatementInTotoV01,
+					PredicateType: PredicateSource,
+					Subject:       []intoto.Subject{{Name: "https://github.com/org/repo", Digest: slsa.DigestSet{"sha1": commit}}},
+				},
+				Predicate: Predicate{
+					Attester:   Attester{ID: "https://tekton.dev/chains/v2"},
+					Repository: "https://github.com/org/repo",
+					Ref:        "refs/heads/main",
+					Revision:   commit,
+					Committer:  "Jane Doe <jane@example.com>",
+					Submission: Submission{
+						TaskRun:       "ns/build-abc",
+						PipelineRun:   "build",
+						EventID:       "1234",
+						EventListener: "github",
+						Trigger:       "push",
+					},
+				},
+			},
+			wantOK: true,
+		},
+		{
+			name: "no commit",
+			tr: &v1beta1.TaskRun{
+				Spec: v1beta1.TaskRunSpec{
+					Params: []v1beta1.Param{
+						{Name: "CHAINS-GIT_URL", Value: *v1beta1.NewArrayOrString("https://github.com/org/repo")},
+					},
+				},
+			},
+		},
+		{
+			name: "branch instead of commit",
+			tr: &v1beta1.TaskRun{
+				Spec: v1beta1.TaskRunSpec{
+					Params: []v1beta1.Param{
+						{Name: "CHAINS-GIT_URL", Value: *v1beta1.NewArrayOrString("https://github.com/org/repo")},
+						{Name: "CHAINS-GIT_COMMIT", Value: *v1beta1.NewArrayOrString("main")},
+					},
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := NewStatement(tt.tr, "https://tekton.dev/chains/v2")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewStatement() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("NewStatement() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("NewStatement() -want +got: %s", diff)
+			}
+		})
+	}
+}
@@ -17,6 +17,8 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"io"
+	"time"
 
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
@@ -43,8 +45,13 @@ type SignedDocument struct {
 	Signature string
 	Cert      string
 	Chain     string
+	Bundle    *config.TlogBundle
 	Object    interface{}
 	Name      string
+	// Namespace and TaskRun are the TaskRun the payload was stored for, and Stored when.
+	Namespace string
+	TaskRun   string
+	Stored    time.Time
 }
 
 // NewStorageBackend returns a new Tekton StorageBackend that stores signatures on a TaskRun
@@ -67,7 +74,7 @@ func newStorageBackendWithColl(logger *zap.SugaredLogger, tr *v1beta1.TaskRun, c
 }
 
 // StorePayload implements the Payloader interface.
-func (b *Backend) StorePayload(signed []byte, signature string, opts config.StorageOpts) error {
+func (b *Backend) StorePayload(ctx context.Context, signed []byte, signature string, opts config.StorageOpts) error {
 	var obj interface{}
 	if err := json.Unmarshal(signed, &obj); err != nil {
 		return err
@@ -80,9 +87,13 @@ func (b *Backend) StorePayload(signed []byte, signature string, opts config.Stor
 		Name:      opts.Key,
 		Cert:      opts.Cert,
 		Chain:     opts.Chain,
+		Bundle:    opts.Bundle,
+		Namespace: b.tr.Namespace,
+		TaskRun:   b.tr.Name,
+		Stored:    time.Now(),
 	}
 
-	if err := b.coll.Put(context.Background(), &entry); err != nil {
+	if err := b.coll.Put(ctx, &entry); err != nil {
 		return err
 	}
 
@@ -116,6 +127,41 @@ func (b *Backend) RetrievePayload(opts config.StorageOpts) (string, error) {
 	return string(d.Signed), nil
 }
 
+// ListPayloads lists the documents stored for TaskRuns in filter.Namespace, or in every namespace.
+func (b *Backend) ListPayloads(ctx context.Context, filter config.ListFilter) ([]config.ListedPayload, error) {
+	q := b.coll.Query()
+	if filter.Namespace != "" {
+		q = q.Where("Namespace", "=", filter.Namespace)
+	}
+	iter := q.Get(ctx)
+	defer iter.Stop()
+	listed := []config.ListedPayload{}
+	for {
+		var d SignedDocument
+		err := iter.Next(ctx, &d)
+		if err == io.EOF {
+			return listed, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sig, err := base64.StdEncoding.DecodeString(d.Signature)
+		if err != nil {
+			return nil, err
+		}
+		listed = append(listed, config.ListedPayload{
+			Namespace: d.Namespace,
+			TaskRun:   d.TaskRun,
+			Key:       d.Name,
+			Payload:   d.Signed,
+			Signature: string(sig),
+			Cert:      d.Cert,
+			Chain:     d.Chain,
+			Stored:    d.Stored,
+		})
+	}
+}
+
 func (b *Backend) retrieveDocument(opts config.StorageOpts) (SignedDocument, error) {
 	d := SignedDocument{Name: opts.Key}
 	if err := b.coll.Get(context.Background(), &d); err != nil {
@@ -80,7 +80,7 @@ func TestBackend_StorePayload(t *testing.T) {
 
 			// Store the document.
 			opts := config.StorageOpts{Key: tt.args.key}
-			if err := b.StorePayload(sb, tt.args.signature, opts); (err != nil) != tt.wantErr {
+			if err := b.StorePayload(ctx, sb, tt.args.signature, opts); (err != nil) != tt.wantErr {
 				t.Fatalf("Backend.StorePayload() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			obj := SignedDocument{
@@ -110,3 +110,36 @@ func TestBackend_StorePayload(t *testing.T) {
 		})
 	}
 }
+
+func TestBackend_ListPayloads(t *testing.T) {
+	ctx := context.Background()
+	coll, err := docstore.OpenCollection(ctx, "mem://chains/name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coll.Close()
+
+	for _, tr := range []*v1beta1.TaskRun{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "bar", UID: "bar"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "baz", Name: "bar", UID: "baz"}},
+	} {
+		b := newStorageBackendWithColl(logtesting.TestLogger(t), tr, coll)
+		opts := config.StorageOpts{Key: string(tr.UID), Cert: "cert"}
+		if err := b.StorePayload(ctx, []byte(`{}`), "signature", opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := newStorageBackendWithColl(logtesting.TestLogger(t), &v1beta1.TaskRun{}, coll)
+	got, err := b.ListPayloads(ctx, config.ListFilter{Namespace: "baz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("ListPayloads() returned %d payloads, want 1", len(got))
+	}
+	p := got[0]
+	if p.Namespace != "baz" || p.TaskRun != "bar" || p.Key != "baz" || p.Signature != "signature" || p.Cert != "cert" || p.Stored.IsZero() {
+		t.Errorf("ListPayloads() = %+v, want the payload stored for baz/bar", p)
+	}
+}
@@ -15,16 +15,23 @@ package gcs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"path"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 )
 
 const (
@@ -33,6 +40,19 @@ const (
 	SignatureNameFormat = "taskrun-%s-%s/%s.signature"
 	// taskrun-$namespace-$name/$key.payload
 	PayloadNameFormat = "taskrun-%s-%s/%s.payload"
+	// taskrun-$namespace-$name/$key.bundle
+	BundleNameFormat = "taskrun-%s-%s/%s.bundle"
+	// taskrun-$namespace-$name/$key.cert
+	CertNameFormat = "taskrun-%s-%s/%s.cert"
+	// taskrun-$namespace-$name/$key.chain
+	ChainNameFormat = "taskrun-%s-%s/%s.chain"
+)
+
+// The metadata of the objects stored for a TaskRun, since its namespace and name can't be told
+// apart in the object names.
+const (
+	NamespaceMetadataKey = "namespace"
+	TaskRunMetadataKey   = "taskrun"
 )
 
 // Backend is a storage backend that stores signed payloads in the TaskRun metadata as an annotation.
@@ -43,6 +63,8 @@ type Backend struct {
 	writer gcsWriter
 	reader gcsReader
 	cfg    config.Config
+	// retentionChecked is set once the retention policy of the bucket is found to be long enough.
+	retentionChecked bool
 }
 
 // NewStorageBackend returns a new Tekton StorageBackend that stores signatures on a TaskRun
@@ -56,14 +78,25 @@ func NewStorageBackend(logger *zap.SugaredLogger, tr *v1beta1.TaskRun, cfg confi
 	return &Backend{
 		logger: logger,
 		tr:     tr,
-		writer: &writer{client: client, bucket: bucket},
+		writer: &writer{
+			client: client,
+			bucket: bucket,
+			cfg:    cfg.Storage.GCS,
+			metadata: map[string]string{
+				NamespaceMetadataKey: tr.Namespace,
+				TaskRunMetadataKey:   tr.Name,
+			},
+		},
 		reader: &reader{client: client, bucket: bucket},
 		cfg:    cfg,
 	}, nil
 }
 
 // StorePayload implements the Payloader interface.
-func (b *Backend) StorePayload(rawPayload []byte, signature string, opts config.StorageOpts) error {
+func (b *Backend) StorePayload(ctx context.Context, rawPayload []byte, signature string, opts config.StorageOpts) error {
+	if err := b.checkRetention(); err != nil {
+		return err
+	}
 	// We need two object names: the signature and the payload. We want to make these unique to the UID, but easy to find based on the
 	// name/namespace as well.
 	// $bucket/taskrun-$namespace-$name/$key.signature
@@ -72,47 +105,57 @@ func (b *Backend) StorePayload(rawPayload []byte, signature string, opts config.
 	sigName := path.Join(root, fmt.Sprintf("%s.signature", opts.Key))
 	b.logger.Infof("Storing payload at %s", sigName)
 
-	sigObj := b.writer.GetWriter(sigName)
-	if _, err := sigObj.Write([]byte(signature)); err != nil {
-		return err
-	}
-	if err := sigObj.Close(); err != nil {
+	if err := b.writeObject(ctx, sigName, []byte(signature)); err != nil {
 		return err
 	}
-
 	payloadName := path.Join(root, fmt.Sprintf("%s.payload", opts.Key))
-	payloadObj := b.writer.GetWriter(payloadName)
-	defer payloadObj.Close()
-	if _, err := payloadObj.Write(rawPayload); err != nil {
+	if err := b.writeObject(ctx, payloadName, rawPayload); err != nil {
 		return err
 	}
-	if err := payloadObj.Close(); err != nil {
-		return err
+
+	if opts.Bundle != nil {
+		bundle, err := json.Marshal(opts.Bundle)
+		if err != nil {
+			return err
+		}
+		if err := b.writeObject(ctx, fmt.Sprintf(BundleNameFormat, b.tr.Namespace, b.tr.Name, opts.Key), bundle); err != nil {
+			return err
+		}
 	}
 
 	if opts.Cert == "" {
 		return nil
 	}
-	certName := path.Join(root, fmt.Sprintf("%s.cert", opts.Key))
-	certObj := b.writer.GetWriter(certName)
-	defer certObj.Close()
-	if _, err := certObj.Write([]byte(opts.Cert)); err != nil {
+	if err := b.writeObject(ctx, fmt.Sprintf(CertNameFormat, b.tr.Namespace, b.tr.Name, opts.Key), []byte(opts.Cert)); err != nil {
 		return err
 	}
-	if err := certObj.Close(); err != nil {
+	return b.writeObject(ctx, fmt.Sprintf(ChainNameFormat, b.tr.Namespace, b.tr.Name, opts.Key), []byte(opts.Chain))
+}
+
+func (b *Backend) writeObject(ctx context.Context, object string, data []byte) error {
+	w := b.writer.GetWriter(ctx, object)
+	defer w.Close()
+	if _, err := w.Write(data); err != nil {
 		return err
 	}
+	return w.Close()
+}
 
-	chainName := path.Join(root, fmt.Sprintf("%s.chain", opts.Key))
-	chainObj := b.writer.GetWriter(chainName)
-	defer chainObj.Close()
-	if _, err := chainObj.Write([]byte(opts.Chain)); err != nil {
-		return err
+// checkRetention checks that the bucket keeps objects for at least storage.gcs.retention,
+// before anything is written to it.
+func (b *Backend) checkRetention() error {
+	want := b.cfg.Storage.GCS.Retention
+	if want == 0 || b.retentionChecked {
+		return nil
 	}
-	if err := chainObj.Close(); err != nil {
-		return err
+	got, err := b.writer.RetentionPeriod()
+	if err != nil {
+		return fmt.Errorf("reading the retention policy of bucket %s: %w", b.cfg.Storage.GCS.Bucket, err)
 	}
-
+	if got < want {
+		return fmt.Errorf("bucket %s keeps objects for %s, but storage.gcs.retention requires at least %s", b.cfg.Storage.GCS.Bucket, got, want)
+	}
+	b.retentionChecked = true
 	return nil
 }
 
@@ -121,16 +164,22 @@ func (b *Backend) Type() string {
 }
 
 type gcsWriter interface {
-	GetWriter(object string) io.WriteCloser
+	GetWriter(ctx context.Context, object string) io.WriteCloser
+	// RetentionPeriod returns how long the retention policy of the bucket keeps objects for.
+	RetentionPeriod() (time.Duration, error)
 }
 
 type writer struct {
-	client *storage.Client
-	bucket string
+	client   *storage.Client
+	bucket   string
+	cfg      config.GCSStorageConfig
+	metadata map[string]string
 }
 
 type gcsReader interface {
 	GetReader(object string) (io.ReadCloser, error)
+	// List returns the attributes of the objects whose names start with prefix.
+	List(ctx context.Context, prefix string) ([]*storage.ObjectAttrs, error)
 }
 
 type reader struct {
@@ -138,9 +187,47 @@ type reader struct {
 	bucket string
 }
 
-func (r *writer) GetWriter(object string) io.WriteCloser {
-	ctx := context.Background()
-	return r.client.Bucket(r.bucket).Object(object).NewWriter(ctx)
+func (r *writer) GetWriter(ctx context.Context, object string) io.WriteCloser {
+	o := r.client.Bucket(r.bucket).Object(object)
+	// Objects that are held or retained can't be replaced, e.g. when a TaskRun is retried, so
+	// those that already exist are kept.
+	protected := r.cfg.Hold != "" || r.cfg.Retention > 0
+	if protected {
+		o = o.If(storage.Conditions{DoesNotExist: true})
+	}
+	w := o.NewWriter(ctx)
+	w.Metadata = r.metadata
+	w.KMSKeyName = r.cfg.KMSKey
+	w.TemporaryHold = r.cfg.Hold == config.GCSHoldTemporary
+	w.EventBasedHold = r.cfg.Hold == config.GCSHoldEventBased
+	if protected {
+		return keepExisting{w}
+	}
+	return w
+}
+
+func (r *writer) RetentionPeriod() (time.Duration, error) {
+	attrs, err := r.client.Bucket(r.bucket).Attrs(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	if attrs.RetentionPolicy == nil {
+		return 0, nil
+	}
+	return attrs.RetentionPolicy.RetentionPeriod, nil
+}
+
+// keepExisting writes an object only if it doesn't exist yet, without failing when it does.
+type keepExisting struct {
+	io.WriteCloser
+}
+
+func (w keepExisting) Close() error {
+	err := w.WriteCloser.Close()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+		return nil
+	}
+	return err
 }
 
 func (r *reader) GetReader(object string) (io.ReadCloser, error) {
@@ -148,6 +235,21 @@ func (r *reader) GetReader(object string) (io.ReadCloser, error) {
 	return r.client.Bucket(r.bucket).Object(object).NewReader(ctx)
 }
 
+func (r *reader) List(ctx context.Context, prefix string) ([]*storage.ObjectAttrs, error) {
+	var objects []*storage.ObjectAttrs
+	it := r.client.Bucket(r.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, attrs)
+	}
+}
+
 func (b *Backend) RetrieveSignature(opts config.StorageOpts) (string, error) {
 	object := fmt.Sprintf(SignatureNameFormat, b.tr.Namespace, b.tr.Name, opts.Key)
 	return b.retrieveObject(object)
@@ -158,6 +260,73 @@ func (b *Backend) RetrievePayload(opts config.StorageOpts) (string, error) {
 	return b.retrieveObject(object)
 }
 
+// RetrieveCert retrieves the certificate the payload was signed with, or "" if it was signed
+// without one.
+func (b *Backend) RetrieveCert(opts config.StorageOpts) (string, error) {
+	return b.retrieveOptionalObject(fmt.Sprintf(CertNameFormat, b.tr.Namespace, b.tr.Name, opts.Key))
+}
+
+// RetrieveChain retrieves the chain of the certificate the payload was signed with, or "" if it
+// was signed without one.
+func (b *Backend) RetrieveChain(opts config.StorageOpts) (string, error) {
+	return b.retrieveOptionalObject(fmt.Sprintf(ChainNameFormat, b.tr.Namespace, b.tr.Name, opts.Key))
+}
+
+// ListPayloads lists the payloads in the bucket stored for TaskRuns in filter.Namespace, or in
+// every namespace. Objects stored before their TaskRun was recorded in their metadata are listed
+// without a namespace or TaskRun.
+func (b *Backend) ListPayloads(ctx context.Context, filter config.ListFilter) ([]config.ListedPayload, error) {
+	prefix := "taskrun-"
+	if filter.Namespace != "" {
+		prefix += filter.Namespace + "-"
+	}
+	objects, err := b.reader.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing objects in bucket %s: %w", b.cfg.Storage.GCS.Bucket, err)
+	}
+	listed := []config.ListedPayload{}
+	for _, o := range objects {
+		root := strings.TrimSuffix(o.Name, ".payload")
+		if root == o.Name {
+			continue
+		}
+		p := config.ListedPayload{
+			Namespace: o.Metadata[NamespaceMetadataKey],
+			TaskRun:   o.Metadata[TaskRunMetadataKey],
+			Key:       path.Base(root),
+			Stored:    o.Created,
+		}
+		if filter.Namespace != "" && p.Namespace != filter.Namespace {
+			continue
+		}
+		payload, err := b.retrieveObject(o.Name)
+		if err != nil {
+			return nil, err
+		}
+		p.Payload = []byte(payload)
+		if p.Signature, err = b.retrieveObject(root + ".signature"); err != nil {
+			return nil, err
+		}
+		if p.Cert, err = b.retrieveOptionalObject(root + ".cert"); err != nil {
+			return nil, err
+		}
+		if p.Chain, err = b.retrieveOptionalObject(root + ".chain"); err != nil {
+			return nil, err
+		}
+		listed = append(listed, p)
+	}
+	return listed, nil
+}
+
+// retrieveOptionalObject is retrieveObject, returning "" for objects that don't exist.
+func (b *Backend) retrieveOptionalObject(object string) (string, error) {
+	value, err := b.retrieveObject(object)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return "", nil
+	}
+	return value, err
+}
+
 func (b *Backend) retrieveObject(object string) (string, error) {
 	reader, err := b.reader.GetReader(object)
 	if err != nil {
@@ -15,11 +15,21 @@ package gcs
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/storage"
+	"github.com/google/go-cmp/cmp"
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"google.golang.org/api/googleapi"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	logtesting "knative.dev/pkg/logging/testing"
@@ -67,7 +77,7 @@ func TestBackend_StorePayload(t *testing.T) {
 				cfg:    config.Config{Storage: config.StorageConfigs{GCS: config.GCSStorageConfig{Bucket: "foo"}}},
 			}
 			opts := config.StorageOpts{Key: tt.args.key}
-			if err := b.StorePayload(tt.args.signed, tt.args.signature, opts); (err != nil) != tt.wantErr {
+			if err := b.StorePayload(context.Background(), tt.args.signed, tt.args.signature, opts); (err != nil) != tt.wantErr {
 				t.Errorf("Backend.StorePayload() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			got, err := b.RetrieveSignature(opts)
@@ -88,16 +98,182 @@ func TestBackend_StorePayload(t *testing.T) {
 	}
 }
 
+func TestBackend_RetrieveCert(t *testing.T) {
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "bar"}}
+	mockGcsWrite := &mockGcsWriter{objects: map[string]*bytes.Buffer{}}
+	b := &Backend{
+		logger: logtesting.TestLogger(t),
+		tr:     tr,
+		writer: mockGcsWrite,
+		reader: &mockGcsReader{objects: mockGcsWrite.objects},
+		cfg:    config.Config{Storage: config.StorageConfigs{GCS: config.GCSStorageConfig{Bucket: "foo"}}},
+	}
+
+	keyless := config.StorageOpts{Key: "keyless", Cert: "cert", Chain: "chain"}
+	if err := b.StorePayload(context.Background(), []byte("signed"), "signature", keyless); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mockGcsWrite.objects["taskrun-foo-bar/keyless.cert"]; !ok {
+		t.Errorf("expected the cert to be stored next to the payload, got objects %v", mockGcsWrite.objects)
+	}
+	if cert, err := b.RetrieveCert(keyless); err != nil || cert != "cert" {
+		t.Errorf("RetrieveCert() = %q, %v, want %q", cert, err, "cert")
+	}
+	if chain, err := b.RetrieveChain(keyless); err != nil || chain != "chain" {
+		t.Errorf("RetrieveChain() = %q, %v, want %q", chain, err, "chain")
+	}
+
+	// Payloads signed with a key have no certificate.
+	key := config.StorageOpts{Key: "key"}
+	if err := b.StorePayload(context.Background(), []byte("signed"), "signature", key); err != nil {
+		t.Fatal(err)
+	}
+	if cert, err := b.RetrieveCert(key); err != nil || cert != "" {
+		t.Errorf("RetrieveCert() = %q, %v, want no cert", cert, err)
+	}
+	if chain, err := b.RetrieveChain(key); err != nil || chain != "" {
+		t.Errorf("RetrieveChain() = %q, %v, want no chain", chain, err)
+	}
+}
+
+func TestBackend_ListPayloads(t *testing.T) {
+	mockGcsWrite := &mockGcsWriter{objects: map[string]*bytes.Buffer{}}
+	mockGcsRead := &mockGcsReader{objects: mockGcsWrite.objects, metadata: map[string]map[string]string{}}
+	for _, tr := range []*v1beta1.TaskRun{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "bar"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "foo-bar", Name: "baz"}},
+	} {
+		b := &Backend{
+			logger: logtesting.TestLogger(t),
+			tr:     tr,
+			writer: mockGcsWrite,
+			reader: mockGcsRead,
+		}
+		opts := config.StorageOpts{Key: "key", Cert: "cert-" + tr.Name, Chain: "chain"}
+		if err := b.StorePayload(context.Background(), []byte("payload-"+tr.Name), "signature-"+tr.Name, opts); err != nil {
+			t.Fatal(err)
+		}
+		// The real writer records the TaskRun in the metadata of the objects.
+		mockGcsRead.metadata[fmt.Sprintf(PayloadNameFormat, tr.Namespace, tr.Name, opts.Key)] = map[string]string{
+			NamespaceMetadataKey: tr.Namespace,
+			TaskRunMetadataKey:   tr.Name,
+		}
+	}
+
+	b := &Backend{logger: logtesting.TestLogger(t), reader: mockGcsRead}
+	got, err := b.ListPayloads(context.Background(), config.ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []config.ListedPayload{
+		{Namespace: "foo-bar", TaskRun: "baz", Key: "key", Payload: []byte("payload-baz"), Signature: "signature-baz", Cert: "cert-baz", Chain: "chain"},
+		{Namespace: "foo", TaskRun: "bar", Key: "key", Payload: []byte("payload-bar"), Signature: "signature-bar", Cert: "cert-bar", Chain: "chain"},
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("ListPayloads() diff (-want +got):\n%s", d)
+	}
+
+	// The objects of namespace foo-bar also start with taskrun-foo-.
+	got, err = b.ListPayloads(context.Background(), config.ListFilter{Namespace: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(want[1:], got); d != "" {
+		t.Errorf("ListPayloads() diff (-want +got):\n%s", d)
+	}
+}
+
+func TestBackend_Retention(t *testing.T) {
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "bar"}}
+	tests := []struct {
+		name      string
+		required  time.Duration
+		period    time.Duration
+		periodErr error
+		wantErr   bool
+	}{
+		{name: "not required", period: time.Hour},
+		{name: "long enough", required: 24 * time.Hour, period: 48 * time.Hour},
+		{name: "too short", required: 24 * time.Hour, period: time.Hour, wantErr: true},
+		{name: "no policy", required: 24 * time.Hour, wantErr: true},
+		{name: "unreadable policy", required: 24 * time.Hour, period: 48 * time.Hour, periodErr: errors.New("forbidden"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &mockGcsWriter{objects: map[string]*bytes.Buffer{}, retention: tt.period, retentionErr: tt.periodErr}
+			b := &Backend{
+				logger: logtesting.TestLogger(t),
+				tr:     tr,
+				writer: w,
+				cfg:    config.Config{Storage: config.StorageConfigs{GCS: config.GCSStorageConfig{Bucket: "foo", Retention: tt.required}}},
+			}
+			for i := 0; i < 2; i++ {
+				if err := b.StorePayload(context.Background(), []byte("signed"), "signature", config.StorageOpts{Key: "key"}); (err != nil) != tt.wantErr {
+					t.Fatalf("Backend.StorePayload() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+			if tt.wantErr && len(w.objects) != 0 {
+				t.Errorf("expected nothing to be written, got %d objects", len(w.objects))
+			}
+			// The policy is only read once.
+			wantReads := 0
+			if tt.required > 0 {
+				wantReads = 1
+			}
+			if !tt.wantErr && w.retentionReads != wantReads {
+				t.Errorf("expected the retention policy to be read %d times, got %d", wantReads, w.retentionReads)
+			}
+		})
+	}
+}
+
+func TestKeepExisting(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{name: "written"},
+		{name: "exists", err: &googleapi.Error{Code: http.StatusPreconditionFailed}},
+		{name: "forbidden", err: &googleapi.Error{Code: http.StatusForbidden}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := keepExisting{&failingWriteCloser{err: tt.err}}
+			if err := w.Close(); (err != nil) != tt.wantErr {
+				t.Errorf("Close() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type failingWriteCloser struct {
+	bytes.Buffer
+	err error
+}
+
+func (wc *failingWriteCloser) Close() error {
+	return wc.err
+}
+
 type mockGcsWriter struct {
-	objects map[string]*bytes.Buffer
+	objects        map[string]*bytes.Buffer
+	retention      time.Duration
+	retentionErr   error
+	retentionReads int
 }
 
-func (m *mockGcsWriter) GetWriter(object string) io.WriteCloser {
+func (m *mockGcsWriter) GetWriter(_ context.Context, object string) io.WriteCloser {
 	buf := bytes.NewBuffer([]byte{})
 	m.objects[object] = buf
 	return &writeCloser{buf}
 }
 
+func (m *mockGcsWriter) RetentionPeriod() (time.Duration, error) {
+	m.retentionReads++
+	return m.retention, m.retentionErr
+}
+
 type writeCloser struct {
 	*bytes.Buffer
 }
@@ -108,12 +284,27 @@ func (wc *writeCloser) Close() error {
 }
 
 type mockGcsReader struct {
-	objects map[string]*bytes.Buffer
+	objects  map[string]*bytes.Buffer
+	metadata map[string]map[string]string
+}
+
+func (m *mockGcsReader) List(ctx context.Context, prefix string) ([]*storage.ObjectAttrs, error) {
+	var objects []*storage.ObjectAttrs
+	for name := range m.objects {
+		if strings.HasPrefix(name, prefix) {
+			objects = append(objects, &storage.ObjectAttrs{Name: name, Metadata: m.metadata[name]})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects, nil
 }
 
 func (m *mockGcsReader) GetReader(object string) (io.ReadCloser, error) {
-	buf := m.objects[object]
-	return &ReaderCloser{buf}, nil
+	buf, ok := m.objects[object]
+	if !ok {
+		return nil, storage.ErrObjectNotExist
+	}
+	return &ReaderCloser{bytes.NewBuffer(buf.Bytes())}, nil
 }
 
 type ReaderCloser struct {
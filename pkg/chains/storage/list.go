@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature/payload"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// ErrListNotSupported is returned by ListPayloads for backends that can't list what they store.
+var ErrListNotSupported = errors.New("the storage backend can't list payloads")
+
+// Lister is implemented by the backends that can list the payloads they store, across TaskRuns.
+// They may only apply the parts of the filter they can do cheaply: ListPayloads applies the rest.
+type Lister interface {
+	ListPayloads(ctx context.Context, filter config.ListFilter) ([]config.ListedPayload, error)
+}
+
+// ListPayloads returns the payloads stored in b that match filter, or ErrListNotSupported if b
+// can't list them.
+func ListPayloads(ctx context.Context, b Backend, filter config.ListFilter) ([]config.ListedPayload, error) {
+	l, ok := b.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	listed, err := l.ListPayloads(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	matching := []config.ListedPayload{}
+	for _, p := range listed {
+		if matches(filter, p) {
+			matching = append(matching, p)
+		}
+	}
+	return matching, nil
+}
+
+func matches(filter config.ListFilter, p config.ListedPayload) bool {
+	if filter.Namespace != "" && p.Namespace != filter.Namespace {
+		return false
+	}
+	if !filter.Since.IsZero() && p.Stored.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && p.Stored.After(filter.Until) {
+		return false
+	}
+	if filter.Digest == "" {
+		return true
+	}
+	for _, d := range subjectDigests(p.Payload) {
+		if d == filter.Digest {
+			return true
+		}
+	}
+	return false
+}
+
+// subjectDigests returns the digests of the subjects of an in-toto statement, or of the image of
+// a simple signing payload. Other payloads have none.
+func subjectDigests(raw []byte) []string {
+	var st in_toto.Statement
+	if err := json.Unmarshal(raw, &st); err == nil && len(st.Subject) > 0 {
+		digests := []string{}
+		for _, s := range st.Subject {
+			for alg, value := range s.Digest {
+				digests = append(digests, fmt.Sprintf("%s:%s", alg, value))
+			}
+		}
+		return digests
+	}
+	var simple payload.SimpleContainerImage
+	if err := json.Unmarshal(raw, &simple); err == nil && simple.Critical.Image.DockerManifestDigest != "" {
+		return []string{simple.Critical.Image.DockerManifestDigest}
+	}
+	return nil
+}
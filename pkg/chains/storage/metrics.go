@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/chains/pkg/metrics"
+)
+
+// instrumented records the latency and errors of each call to a Backend, labeled with its
+// type, so that a backend that degrades stands out from the others.
+type instrumented struct {
+	Backend
+}
+
+func (b *instrumented) StorePayload(ctx context.Context, rawPayload []byte, signature string, opts config.StorageOpts) error {
+	defer b.observe(metrics.OperationStore, time.Now())()
+	err := b.Backend.StorePayload(ctx, rawPayload, signature, opts)
+	b.recordError(metrics.OperationStore, err)
+	return err
+}
+
+func (b *instrumented) RetrievePayload(opts config.StorageOpts) (string, error) {
+	defer b.observe(metrics.OperationRetrieve, time.Now())()
+	payload, err := b.Backend.RetrievePayload(opts)
+	b.recordError(metrics.OperationRetrieve, err)
+	return payload, err
+}
+
+func (b *instrumented) RetrieveSignature(opts config.StorageOpts) (string, error) {
+	defer b.observe(metrics.OperationRetrieve, time.Now())()
+	signature, err := b.Backend.RetrieveSignature(opts)
+	b.recordError(metrics.OperationRetrieve, err)
+	return signature, err
+}
+
+func (b *instrumented) RetrieveCert(opts config.StorageOpts) (string, error) {
+	c, ok := b.Backend.(CertificateStore)
+	if !ok {
+		return "", nil
+	}
+	defer b.observe(metrics.OperationRetrieve, time.Now())()
+	cert, err := c.RetrieveCert(opts)
+	b.recordError(metrics.OperationRetrieve, err)
+	return cert, err
+}
+
+func (b *instrumented) RetrieveChain(opts config.StorageOpts) (string, error) {
+	c, ok := b.Backend.(CertificateStore)
+	if !ok {
+		return "", nil
+	}
+	defer b.observe(metrics.OperationRetrieve, time.Now())()
+	chain, err := c.RetrieveChain(opts)
+	b.recordError(metrics.OperationRetrieve, err)
+	return chain, err
+}
+
+func (b *instrumented) ListPayloads(ctx context.Context, filter config.ListFilter) ([]config.ListedPayload, error) {
+	l, ok := b.Backend.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	defer b.observe(metrics.OperationList, time.Now())()
+	listed, err := l.ListPayloads(ctx, filter)
+	b.recordError(metrics.OperationList, err)
+	return listed, err
+}
+
+// observe returns a func that records the latency of an operation started at start.
+func (b *instrumented) observe(operation string, start time.Time) func() {
+	return func() {
+		metrics.RecordStorageLatency(context.Background(), b.Type(), operation, time.Since(start))
+	}
+}
+
+func (b *instrumented) recordError(operation string, err error) {
+	if err != nil {
+		metrics.RecordStorageError(context.Background(), b.Type(), operation)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	dockerconfig "github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// newKeychain returns the keychain the registries signatures and attestations of the TaskRun
+// are pushed to are authenticated with: the sources storage.oci.auth.registries lists for a
+// registry, or else the sources of storage.oci.auth, each tried in order until one has
+// credentials. Only the sources that are configured are created, so unless k8schain or a
+// secret is used, no secrets are read.
+func newKeychain(ctx context.Context, kubeClient kubernetes.Interface, tr *v1beta1.TaskRun, cfg config.OCIStorageConfig) (authn.Keychain, error) {
+	created := map[string]authn.Keychain{}
+	source := func(s string) (authn.Keychain, error) {
+		if kc, ok := created[s]; ok {
+			return kc, nil
+		}
+		var kc authn.Keychain
+		var err error
+		switch {
+		case s == config.AuthK8sChain:
+			kc, err = k8schain.New(ctx, kubeClient, k8schain.Options{Namespace: tr.Namespace, ServiceAccountName: tr.Spec.ServiceAccountName})
+		case s == config.AuthWorkloadIdentity:
+			kc, err = k8schain.NewNoClient(ctx)
+		case s == config.AuthDocker:
+			kc = authn.DefaultKeychain
+		case strings.HasPrefix(s, config.AuthHelperPrefix):
+			kc = &helperKeychain{program: client.NewShellProgramFunc("docker-credential-" + strings.TrimPrefix(s, config.AuthHelperPrefix))}
+		case strings.HasPrefix(s, config.AuthSecretPrefix):
+			namespace, name := config.SplitSecretRef(strings.TrimPrefix(s, config.AuthSecretPrefix))
+			if namespace == "" {
+				namespace = tr.Namespace
+			}
+			kc, err = newSecretKeychain(ctx, kubeClient, namespace, name)
+		default:
+			err = fmt.Errorf("unknown source of registry credentials %q", s)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "creating %s keychain", s)
+		}
+		created[s] = kc
+		return kc, nil
+	}
+	chain := func(sources []string) (authn.Keychain, error) {
+		var kcs []authn.Keychain
+		for _, s := range sources {
+			kc, err := source(s)
+			if err != nil {
+				return nil, err
+			}
+			kcs = append(kcs, kc)
+		}
+		return authn.NewMultiKeychain(kcs...), nil
+	}
+
+	sources := cfg.Auth
+	if len(sources) == 0 {
+		sources = []string{config.AuthK8sChain}
+	}
+	kc := &registryKeychain{registries: map[string]authn.Keychain{}}
+	var err error
+	if kc.fallback, err = chain(sources); err != nil {
+		return nil, err
+	}
+	for _, r := range cfg.RegistryAuth {
+		if kc.registries[r.Host], err = chain(r.Sources); err != nil {
+			return nil, err
+		}
+	}
+	return kc, nil
+}
+
+// registryKeychain resolves the credentials of each registry with its own keychain.
+type registryKeychain struct {
+	registries map[string]authn.Keychain
+	fallback   authn.Keychain
+}
+
+// Resolve implements authn.Keychain.
+func (k *registryKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if kc, ok := k.registries[target.RegistryStr()]; ok {
+		return kc.Resolve(target)
+	}
+	return k.fallback.Resolve(target)
+}
+
+// helperKeychain resolves the credentials of registries with a docker credential helper.
+type helperKeychain struct {
+	program client.ProgramFunc
+}
+
+// Resolve implements authn.Keychain.
+func (k *helperKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	creds, err := client.Get(k.program, target.RegistryStr())
+	if credentials.IsErrCredentialsNotFound(err) {
+		return authn.Anonymous, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting the credentials of %s", target.RegistryStr())
+	}
+	// Helpers return identity tokens with this user name, like docker.
+	if creds.Username == "<token>" {
+		return authn.FromConfig(authn.AuthConfig{IdentityToken: creds.Secret}), nil
+	}
+	return authn.FromConfig(authn.AuthConfig{Username: creds.Username, Password: creds.Secret}), nil
+}
+
+// newSecretKeychain returns a keychain of the credentials in the docker config secret name in
+// namespace, which is read now, when the TaskRun's payloads are about to be pushed.
+func newSecretKeychain(ctx context.Context, kubeClient kubernetes.Interface, namespace, name string) (authn.Keychain, error) {
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	switch {
+	case len(secret.Data[corev1.DockerConfigJsonKey]) > 0:
+		data = secret.Data[corev1.DockerConfigJsonKey]
+	case len(secret.Data[corev1.DockerConfigKey]) > 0:
+		// The legacy format only has the auths.
+		data = append(append([]byte(`{"auths":`), secret.Data[corev1.DockerConfigKey]...), '}')
+	default:
+		return nil, fmt.Errorf("secret %s/%s has neither %s nor %s", namespace, name, corev1.DockerConfigJsonKey, corev1.DockerConfigKey)
+	}
+	cf, err := dockerconfig.LoadFromReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing secret %s/%s", namespace, name)
+	}
+	// Secrets can't run credential helpers, only the configuration of Chains can.
+	cf.CredentialsStore, cf.CredentialHelpers = "", nil
+	return &configKeychain{cf: cf}, nil
+}
+
+// configKeychain resolves the credentials of registries with the auths of a docker config.
+type configKeychain struct {
+	cf *configfile.ConfigFile
+}
+
+// Resolve implements authn.Keychain.
+func (k *configKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	key := target.RegistryStr()
+	if key == name.DefaultRegistry {
+		key = authn.DefaultAuthKey
+	}
+	cfg, err := k.cf.GetAuthConfig(key)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == (types.AuthConfig{ServerAddress: cfg.ServerAddress}) {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		Auth:          cfg.Auth,
+		IdentityToken: cfg.IdentityToken,
+		RegistryToken: cfg.RegistryToken,
+	}), nil
+}
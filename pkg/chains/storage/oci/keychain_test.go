@@ -0,0 +1,233 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewKeychain(t *testing.T) {
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}
+	secrets := []runtime.Object{
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: "bar"},
+			Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "registry-creds", Namespace: "tekton-chains"},
+			Data:       map[string][]byte{corev1.DockerConfigKey: []byte(`{}`)},
+		},
+	}
+	tests := []struct {
+		name    string
+		cfg     config.OCIStorageConfig
+		wantErr bool
+	}{
+		{
+			// The TaskRun's service account doesn't exist.
+			name:    "k8schain by default",
+			wantErr: true,
+		},
+		{
+			name: "no secrets read",
+			cfg: config.OCIStorageConfig{
+				Auth:         []string{config.AuthDocker},
+				RegistryAuth: []config.RegistryAuth{{Host: "gcr.io", Sources: []string{config.AuthWorkloadIdentity}}},
+			},
+		},
+		{
+			name: "k8schain for a registry",
+			cfg: config.OCIStorageConfig{
+				Auth:         []string{config.AuthDocker},
+				RegistryAuth: []config.RegistryAuth{{Host: "gcr.io", Sources: []string{config.AuthK8sChain}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "secret",
+			cfg: config.OCIStorageConfig{
+				Auth: []string{"secret:registry-creds", "secret:tekton-chains/registry-creds"},
+			},
+		},
+		{
+			name: "missing secret",
+			cfg: config.OCIStorageConfig{
+				RegistryAuth: []config.RegistryAuth{{Host: "gcr.io", Sources: []string{"secret:gcr-creds"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unknown source",
+			cfg:     config.OCIStorageConfig{Auth: []string{"vault"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newKeychain(context.Background(), fakekube.NewSimpleClientset(secrets...), tr, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newKeychain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fixedKeychain resolves every registry to the same credentials.
+type fixedKeychain string
+
+func (k fixedKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	if k == "" {
+		return authn.Anonymous, nil
+	}
+	return &authn.Basic{Username: string(k)}, nil
+}
+
+func TestRegistryKeychain_Resolve(t *testing.T) {
+	kc := &registryKeychain{
+		registries: map[string]authn.Keychain{
+			"gcr.io":                    authn.NewMultiKeychain(fixedKeychain(""), fixedKeychain("workload-identity")),
+			"registry.example.com:5000": fixedKeychain("docker"),
+		},
+		fallback: fixedKeychain("k8schain"),
+	}
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{repo: "gcr.io/foo/bar", want: "workload-identity"},
+		{repo: "registry.example.com:5000/foo", want: "docker"},
+		{repo: "registry.example.com/foo", want: "k8schain"},
+		{repo: "foo/bar", want: "k8schain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			repo, err := name.NewRepository(tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth, err := kc.Resolve(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Username != tt.want {
+				t.Errorf("Resolve(%s) used the %s credentials, want %s", tt.repo, cfg.Username, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretKeychain_Resolve(t *testing.T) {
+	client := fakekube.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "bar"},
+		Data: map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{
+			"auths": {
+				"https://index.docker.io/v1/": {"auth": "aHViOmh1YnBhc3M="},
+				"registry.example.com:5000": {"username": "example", "password": "examplepass"}
+			},
+			"credsStore": "evil"
+		}`)},
+	})
+	kc, err := newSecretKeychain(context.Background(), client, "bar", "creds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		repo string
+		want authn.AuthConfig
+	}{
+		{repo: "foo/bar", want: authn.AuthConfig{Username: "hub", Password: "hubpass"}},
+		{repo: "registry.example.com:5000/foo", want: authn.AuthConfig{Username: "example", Password: "examplepass"}},
+		// The credential store of the secret isn't run.
+		{repo: "gcr.io/foo/bar", want: authn.AuthConfig{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			repo, err := name.NewRepository(tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth, err := kc.Resolve(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *cfg != tt.want {
+				t.Errorf("Resolve(%s) = %+v, want %+v", tt.repo, *cfg, tt.want)
+			}
+		})
+	}
+}
+
+// fakeHelper is a docker credential helper that knows the credentials of gcr.io.
+type fakeHelper struct {
+	server string
+}
+
+func (h *fakeHelper) Input(in io.Reader) {
+	b, _ := ioutil.ReadAll(in)
+	h.server = string(b)
+}
+
+func (h *fakeHelper) Output() ([]byte, error) {
+	if h.server != "gcr.io" {
+		return []byte(credentials.NewErrCredentialsNotFound().Error()), errors.New("exit status 1")
+	}
+	return []byte(`{"Username":"<token>","Secret":"identity-token"}`), nil
+}
+
+func TestHelperKeychain_Resolve(t *testing.T) {
+	kc := &helperKeychain{program: func(args ...string) client.Program { return &fakeHelper{} }}
+	for repo, want := range map[string]authn.AuthConfig{
+		"gcr.io/foo/bar":       {IdentityToken: "identity-token"},
+		"registry.example.com": {},
+	} {
+		r, err := name.NewRepository(repo + "/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := kc.Resolve(r)
+		if err != nil {
+			t.Fatalf("Resolve(%s) = %v", repo, err)
+		}
+		cfg, err := auth.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *cfg != want {
+			t.Errorf("Resolve(%s) = %+v, want %+v", repo, *cfg, want)
+		}
+	}
+}
@@ -18,19 +18,23 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"regexp"
 
 	"github.com/in-toto/in-toto-golang/in_toto"
 
 	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/pkg/oci"
 	"github.com/sigstore/cosign/pkg/oci/mutate"
 	ociremote "github.com/sigstore/cosign/pkg/oci/remote"
 	"github.com/sigstore/cosign/pkg/oci/static"
 	"github.com/sigstore/cosign/pkg/types"
 	"github.com/tektoncd/chains/pkg/chains/formats/simple"
+	"github.com/tektoncd/chains/pkg/chains/limit"
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	"go.uber.org/zap"
@@ -39,6 +43,10 @@ import (
 
 const (
 	StorageBackendOCI = "oci"
+
+	// TlogBundleAnnotation holds the complete transparency log entry of a signature or
+	// attestation, with the inclusion proof and checkpoint cosign's bundle has no room for.
+	TlogBundleAnnotation = "dev.tekton.chains/tlog-bundle"
 )
 
 type Backend struct {
@@ -47,27 +55,145 @@ type Backend struct {
 	cfg    config.Config
 	kc     authn.Keychain
 	auth   remote.Option
+	// transport is shared by the backends, so the connections to the registries are reused.
+	transport http.RoundTripper
+	// targets are the pushes made for the TaskRun so far.
+	targets []Target
+}
+
+// The kinds of Target.
+const (
+	TargetSignature   = "signature"
+	TargetAttestation = "attestation"
+)
+
+// Target is a repository a signature or attestation of an image was pushed to, or failed to be.
+type Target struct {
+	// Image is the image signed or attested, e.g. gcr.io/foo/bar@sha256:...
+	Image string `json:"image"`
+	// Repository is where the signature or attestation was pushed.
+	Repository string `json:"repository"`
+	// Kind is TargetSignature or TargetAttestation.
+	Kind string `json:"kind"`
+	// Error is why the push failed. It's empty when it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// Targets returns the pushes the backend made, in order. An image that was pushed to several
+// registries, or is mapped to several repositories, has a target for each.
+func (b *Backend) Targets() []Target {
+	return b.targets
+}
+
+// record adds the target of a push, and returns its error.
+func (b *Backend) record(kind, image, repo string, err error) error {
+	t := Target{Image: image, Repository: repo, Kind: kind}
+	if err != nil {
+		t.Error = err.Error()
+	}
+	b.targets = append(b.targets, t)
+	return err
 }
 
 // NewStorageBackend returns a new OCI StorageBackend that stores signatures in an OCI registry
 func NewStorageBackend(logger *zap.SugaredLogger, client kubernetes.Interface, tr *v1beta1.TaskRun, cfg config.Config) (*Backend, error) {
-	kc, err := k8schain.New(context.TODO(), client,
-		k8schain.Options{Namespace: tr.Namespace, ServiceAccountName: tr.Spec.ServiceAccountName})
+	kc, err := newKeychain(context.TODO(), client, tr, cfg.Storage.OCI)
+	if err != nil {
+		return nil, err
+	}
+	t, err := sharedTransport(cfg.Storage.OCI)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Backend{
-		logger: logger,
-		tr:     tr,
-		cfg:    cfg,
-		kc:     kc,
-		auth:   remote.WithAuthFromKeychain(kc),
+		logger:    logger,
+		tr:        tr,
+		cfg:       cfg,
+		kc:        kc,
+		auth:      remote.WithAuthFromKeychain(kc),
+		transport: t,
 	}, nil
 }
 
+// remoteOptions are the options registries are read from and pushed to with, for ctx.
+func (b *Backend) remoteOptions(ctx context.Context) ociremote.Option {
+	opts := []remote.Option{remote.WithContext(ctx)}
+	if b.auth != nil {
+		opts = append(opts, b.auth)
+	}
+	if b.transport != nil {
+		opts = append(opts, remote.WithTransport(b.transport))
+	}
+	return ociremote.WithRemoteOptions(opts...)
+}
+
+// insecure returns whether registry can be connected to over plain HTTP.
+func (b *Backend) insecure(registry string) bool {
+	if b.cfg.Storage.OCI.Insecure {
+		return true
+	}
+	for _, r := range b.cfg.Storage.OCI.Registries {
+		if r.Host == registry {
+			return r.Insecure
+		}
+	}
+	return false
+}
+
+// newDigest parses the digest reference s, allowing its registry to be connected to over plain
+// HTTP when it's insecure.
+func (b *Backend) newDigest(s string) (name.Digest, error) {
+	d, err := name.NewDigest(s)
+	if err != nil || !b.insecure(d.RegistryStr()) {
+		return d, err
+	}
+	return name.NewDigest(s, name.Insecure)
+}
+
+// newRepository parses the repository s, allowing its registry to be connected to over plain
+// HTTP when it's insecure.
+func (b *Backend) newRepository(s string) (name.Repository, error) {
+	r, err := name.NewRepository(s)
+	if err != nil || !b.insecure(r.RegistryStr()) {
+		return r, err
+	}
+	return name.NewRepository(s, name.Insecure)
+}
+
+// repositories returns the repositories the signatures and attestations of an image in repo
+// are pushed to: storage.oci.repository, or else repo, replaced by the first mapping that
+// matches it, or pushed to as well with an also mapping.
+func (b *Backend) repositories(repo name.Repository) ([]name.Repository, error) {
+	if b.cfg.Storage.OCI.Repository != "" {
+		r, err := b.newRepository(b.cfg.Storage.OCI.Repository)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s is not a valid repository", b.cfg.Storage.OCI.Repository)
+		}
+		repo = r
+	}
+	for _, m := range b.cfg.Storage.OCI.Mappings {
+		p, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compiling repository mapping %q", m.Pattern)
+		}
+		if !p.MatchString(repo.Name()) {
+			continue
+		}
+		mapped, err := b.newRepository(p.ReplaceAllString(repo.Name(), m.Replacement))
+		if err != nil {
+			return nil, errors.Wrapf(err, "mapping %s with %q", repo.Name(), m.Pattern)
+		}
+		if m.Also {
+			return []name.Repository{repo, mapped}, nil
+		}
+		return []name.Repository{mapped}, nil
+	}
+	return []name.Repository{repo}, nil
+}
+
 // StorePayload implements the Payloader interface.
-func (b *Backend) StorePayload(rawPayload []byte, signature string, storageOpts config.StorageOpts) error {
+func (b *Backend) StorePayload(ctx context.Context, rawPayload []byte, signature string, storageOpts config.StorageOpts) error {
 	b.logger.Infof("Storing payload on TaskRun %s/%s", b.tr.Namespace, b.tr.Name)
 
 	if storageOpts.PayloadFormat == "simplesigning" {
@@ -75,7 +201,7 @@ func (b *Backend) StorePayload(rawPayload []byte, signature string, storageOpts
 		if err := json.Unmarshal(rawPayload, &format); err != nil {
 			return errors.Wrap(err, "unmarshal simplesigning")
 		}
-		return b.uploadSignature(format, rawPayload, signature, storageOpts)
+		return b.uploadSignature(ctx, format, rawPayload, signature, storageOpts)
 	}
 
 	if storageOpts.PayloadFormat == "in-toto" || storageOpts.PayloadFormat == "tekton-provenance" {
@@ -90,103 +216,172 @@ func (b *Backend) StorePayload(rawPayload []byte, signature string, storageOpts
 			return errors.New("Did not find anything to attest")
 		}
 
-		return b.uploadAttestation(attestation, rawPayload, signature, storageOpts)
+		return b.uploadAttestation(ctx, attestation, rawPayload, signature, storageOpts)
 	}
 
 	return errors.New("OCI storage backend is only supported for OCI images and in-toto attestations")
 }
 
-func (b *Backend) uploadSignature(format simple.SimpleContainerImage, rawPayload []byte, signature string, storageOpts config.StorageOpts) error {
+func (b *Backend) uploadSignature(ctx context.Context, format simple.SimpleContainerImage, rawPayload []byte, signature string, storageOpts config.StorageOpts) error {
 	imageName := format.ImageName()
 	b.logger.Infof("Uploading %s signature", imageName)
-	var opts []name.Option
-	if b.cfg.Storage.OCI.Insecure {
-		opts = append(opts, name.Insecure)
-	}
-	ref, err := name.NewDigest(imageName, opts...)
+	ref, err := b.newDigest(imageName)
 	if err != nil {
 		return errors.Wrap(err, "getting digest")
 	}
-	se, err := ociremote.SignedEntity(ref)
+	repos, err := b.repositories(ref.Repository)
+	if err != nil {
+		return b.record(TargetSignature, ref.String(), ref.Repository.Name(), err)
+	}
+	newSE, err := b.attachSignature(ctx, ref, rawPayload, signature, storageOpts)
 	if err != nil {
-		return errors.Wrap(err, "getting signed image")
+		for _, repo := range repos {
+			_ = b.record(TargetSignature, ref.String(), repo.Name(), err)
+		}
+		return err
+	}
+	// Every repository is pushed to, even when pushing to one of them fails.
+	var merr *multierror.Error
+	for _, repo := range repos {
+		repo := repo
+		// Publish the signatures associated with this entity
+		push := func() error {
+			return ociremote.WriteSignatures(repo, newSE, b.remoteOptions(ctx))
+		}
+		if err := b.record(TargetSignature, ref.String(), repo.Name(), limit.Registry.Do(ctx, push)); err != nil {
+			merr = multierror.Append(merr, errors.Wrapf(err, "pushing signature of %s to %s", imageName, repo))
+			continue
+		}
+		b.logger.Infof("Successfully uploaded signature for %s to %s", imageName, repo)
+	}
+	return merr.ErrorOrNil()
+}
+
+// attachSignature returns the image ref with the signature attached to it.
+func (b *Backend) attachSignature(ctx context.Context, ref name.Digest, rawPayload []byte, signature string, storageOpts config.StorageOpts) (oci.SignedEntity, error) {
+	se, err := ociremote.SignedEntity(ref, b.remoteOptions(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "getting signed image")
 	}
 
 	sigOpts := []static.Option{}
 	if storageOpts.Cert != "" {
 		sigOpts = append(sigOpts, static.WithCertChain([]byte(storageOpts.Cert), []byte(storageOpts.Chain)))
 	}
+	if storageOpts.Bundle != nil {
+		bundleOpts, err := bundleOptions(storageOpts.Bundle)
+		if err != nil {
+			return nil, err
+		}
+		sigOpts = append(sigOpts, bundleOpts...)
+	}
 	// Create the new signature for this entity.
 	b64sig := base64.StdEncoding.EncodeToString([]byte(signature))
 	sig, err := static.NewSignature(rawPayload, b64sig, sigOpts...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Attach the signature to the entity.
-	newSE, err := mutate.AttachSignatureToEntity(se, sig)
-	if err != nil {
-		return err
-	}
-	repo := ref.Repository
-	if b.cfg.Storage.OCI.Repository != "" {
-		repo, err = name.NewRepository(b.cfg.Storage.OCI.Repository)
-		if err != nil {
-			return errors.Wrapf(err, "%s is not a valid repository", b.cfg.Storage.OCI.Repository)
-		}
-	}
-	// Publish the signatures associated with this entity
-	if err := ociremote.WriteSignatures(repo, newSE, ociremote.WithRemoteOptions(b.auth)); err != nil {
-		return err
-	}
-	b.logger.Infof("Successfully uploaded signature for %s", imageName)
-	return nil
+	return mutate.AttachSignatureToEntity(se, sig)
 }
 
-func (b *Backend) uploadAttestation(attestation in_toto.Statement, rawPayload []byte, signature string, storageOpts config.StorageOpts) error {
-	// upload an attestation for each subject
+func (b *Backend) uploadAttestation(ctx context.Context, attestation in_toto.Statement, rawPayload []byte, signature string, storageOpts config.StorageOpts) error {
+	// upload an attestation for each subject, even when uploading one of them fails
 	b.logger.Info("Starting to upload attestations to OCI ...")
+	var merr *multierror.Error
 	for _, subj := range attestation.Subject {
 		imageName := fmt.Sprintf("%s@sha256:%s", subj.Name, subj.Digest["sha256"])
 		b.logger.Infof("Starting attestation upload to OCI for %s...", imageName)
-		var opts []name.Option
-		if b.cfg.Storage.OCI.Insecure {
-			opts = append(opts, name.Insecure)
-		}
-		ref, err := name.NewDigest(imageName, opts...)
-		if err != nil {
-			return errors.Wrapf(err, "getting digest for subj %s", imageName)
-		}
-		repo := ref.Repository
-		if b.cfg.Storage.OCI.Repository != "" {
-			repo, err = name.NewRepository(b.cfg.Storage.OCI.Repository)
-			if err != nil {
-				return errors.Wrapf(err, "%s is not a valid repository", b.cfg.Storage.OCI.Repository)
-			}
+		if err := b.attest(ctx, imageName, signature, storageOpts); err != nil {
+			merr = multierror.Append(merr, err)
 		}
-		se, err := ociremote.SignedEntity(ref)
-		if err != nil {
-			return errors.Wrap(err, "getting signed image")
+	}
+	return merr.ErrorOrNil()
+}
+
+// attest attaches the attestation to the image, and pushes it to each of its repositories.
+func (b *Backend) attest(ctx context.Context, imageName, signature string, storageOpts config.StorageOpts) error {
+	ref, err := b.newDigest(imageName)
+	if err != nil {
+		return errors.Wrapf(err, "getting digest for subj %s", imageName)
+	}
+	repos, err := b.repositories(ref.Repository)
+	if err != nil {
+		return b.record(TargetAttestation, ref.String(), ref.Repository.Name(), err)
+	}
+	newImage, err := b.attachAttestation(ctx, ref, signature, storageOpts)
+	if err != nil {
+		for _, repo := range repos {
+			_ = b.record(TargetAttestation, ref.String(), repo.Name(), err)
 		}
-		// Create the new attestation for this entity.
-		attOpts := []static.Option{static.WithLayerMediaType(types.DssePayloadType)}
-		if storageOpts.Cert != "" {
-			attOpts = append(attOpts, static.WithCertChain([]byte(storageOpts.Cert), []byte(storageOpts.Chain)))
+		return errors.Wrapf(err, "attaching attestation to %s", imageName)
+	}
+	var merr *multierror.Error
+	for _, repo := range repos {
+		repo := repo
+		// Publish the signatures associated with this entity
+		push := func() error {
+			return ociremote.WriteAttestations(repo, newImage, b.remoteOptions(ctx))
 		}
-		att, err := static.NewAttestation([]byte(signature), attOpts...)
-		if err != nil {
-			return err
+		if err := b.record(TargetAttestation, ref.String(), repo.Name(), limit.Registry.Do(ctx, push)); err != nil {
+			merr = multierror.Append(merr, errors.Wrapf(err, "pushing attestation of %s to %s", imageName, repo))
+			continue
 		}
-		newImage, err := mutate.AttachAttestationToEntity(se, att)
+		b.logger.Infof("Successfully uploaded attestation for %s to %s", imageName, repo)
+	}
+	return merr.ErrorOrNil()
+}
+
+// attachAttestation returns the image ref with the attestation attached to it.
+func (b *Backend) attachAttestation(ctx context.Context, ref name.Digest, signature string, storageOpts config.StorageOpts) (oci.SignedEntity, error) {
+	se, err := ociremote.SignedEntity(ref, b.remoteOptions(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "getting signed image")
+	}
+	// Create the new attestation for this entity.
+	attOpts := []static.Option{static.WithLayerMediaType(types.DssePayloadType)}
+	if storageOpts.Cert != "" {
+		attOpts = append(attOpts, static.WithCertChain([]byte(storageOpts.Cert), []byte(storageOpts.Chain)))
+	}
+	if storageOpts.Bundle != nil {
+		bundleOpts, err := bundleOptions(storageOpts.Bundle)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		// Publish the signatures associated with this entity
-		if err := ociremote.WriteAttestations(repo, newImage, ociremote.WithRemoteOptions(b.auth)); err != nil {
-			return err
-		}
-		b.logger.Infof("Successfully uploaded attestation for %s", imageName)
+		attOpts = append(attOpts, bundleOpts...)
+	}
+	att, err := static.NewAttestation([]byte(signature), attOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return mutate.AttachAttestationToEntity(se, att)
+}
+
+// bundleOptions attach a transparency log entry to a signature or attestation: as the bundle
+// cosign checks, and in full in TlogBundleAnnotation.
+func bundleOptions(b *config.TlogBundle) ([]static.Option, error) {
+	full, err := json.Marshal(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding transparency log bundle")
+	}
+	return []static.Option{
+		static.WithAnnotations(map[string]string{TlogBundleAnnotation: string(full)}),
+		static.WithBundle(bundle(b)),
+	}, nil
+}
+
+// bundle converts a transparency log entry to the bundle cosign attaches to signatures, so
+// `cosign verify` can check it offline. Cosign's bundle has no room for the inclusion proof.
+func bundle(b *config.TlogBundle) *oci.Bundle {
+	return &oci.Bundle{
+		SignedEntryTimestamp: b.SignedEntryTimestamp,
+		Payload: oci.BundlePayload{
+			Body:           b.Body,
+			IntegratedTime: b.IntegratedTime,
+			LogIndex:       b.LogIndex,
+			LogID:          b.LogID,
+		},
 	}
-	return nil
 }
 
 func (b *Backend) Type() string {
@@ -14,12 +14,19 @@ limitations under the License.
 package oci
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/sigstore/cosign/pkg/oci/static"
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -73,9 +80,159 @@ func TestBackend_StorePayload(t *testing.T) {
 				kc:     tt.fields.kc,
 				auth:   tt.fields.auth,
 			}
-			if err := b.StorePayload(tt.args.rawPayload, tt.args.signature, tt.args.storageOpts); (err != nil) != tt.wantErr {
+			if err := b.StorePayload(context.Background(), tt.args.rawPayload, tt.args.signature, tt.args.storageOpts); (err != nil) != tt.wantErr {
 				t.Errorf("Backend.StorePayload() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestBundleOptions(t *testing.T) {
+	tb := &config.TlogBundle{
+		Body:                 "Ym9keQ==",
+		IntegratedTime:       1637000000,
+		LogIndex:             7,
+		LogID:                "log",
+		SignedEntryTimestamp: []byte("set"),
+		InclusionProof:       &config.InclusionProof{LogIndex: 7, TreeSize: 8, RootHash: "abcd", Hashes: []string{"ef"}},
+		Checkpoint:           &config.Checkpoint{SignedNote: "Rekor\n8\nq80=\n\n— rekor abcd\n"},
+	}
+	opts, err := bundleOptions(tb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := static.NewSignature([]byte("payload"), "c2lnbmF0dXJl", append([]static.Option{static.WithCertChain([]byte("cert"), nil)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations, err := sig.Annotations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &config.TlogBundle{}
+	if err := json.Unmarshal([]byte(annotations[TlogBundleAnnotation]), got); err != nil {
+		t.Fatalf("decoding %s: %v", TlogBundleAnnotation, err)
+	}
+	if d := cmp.Diff(tb, got); d != "" {
+		t.Errorf("%s diff (-want +got):\n%s", TlogBundleAnnotation, d)
+	}
+	// The bundle cosign checks and the certificate are still attached.
+	for _, key := range []string{static.BundleAnnotationKey, static.CertificateAnnotationKey} {
+		if annotations[key] == "" {
+			t.Errorf("expected the %s annotation", key)
+		}
+	}
+}
+
+func TestBackend_NewDigest(t *testing.T) {
+	b := &Backend{cfg: config.Config{Storage: config.StorageConfigs{OCI: config.OCIStorageConfig{
+		Registries: []config.RegistryConnection{{Host: "registry.lab:5000", Insecure: true}, {Host: "gcr.io", SkipTLSVerify: true}},
+	}}}}
+	digest := "@sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+	for image, want := range map[string]string{
+		"registry.lab:5000/foo/bar": "http",
+		"registry.lab/foo/bar":      "https",
+		"gcr.io/foo/bar":            "https",
+	} {
+		ref, err := b.newDigest(image + digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ref.Registry.Scheme(); got != want {
+			t.Errorf("scheme of %s = %s, want %s", image, got, want)
+		}
+		repo, err := b.newRepository(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := repo.Registry.Scheme(); got != want {
+			t.Errorf("scheme of repository %s = %s, want %s", image, got, want)
+		}
+	}
+}
+
+func TestBackend_Repositories(t *testing.T) {
+	mappings := []config.RepositoryMapping{
+		{Pattern: `^staging\.example\.com/(.*)$`, Replacement: "prod.example.com/$1"},
+		{Pattern: `^gcr\.io/team/`, Replacement: "gcr.io/mirror/", Also: true},
+	}
+	tests := []struct {
+		repo       string
+		repository string
+		want       []string
+	}{
+		{repo: "staging.example.com/app", want: []string{"prod.example.com/app"}},
+		{repo: "gcr.io/team/app", want: []string{"gcr.io/team/app", "gcr.io/mirror/app"}},
+		{repo: "gcr.io/other/app", want: []string{"gcr.io/other/app"}},
+		{repo: "gcr.io/other/app", repository: "staging.example.com/signatures", want: []string{"prod.example.com/signatures"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.repo, func(t *testing.T) {
+			b := &Backend{cfg: config.Config{Storage: config.StorageConfigs{OCI: config.OCIStorageConfig{
+				Repository: tt.repository,
+				Mappings:   mappings,
+			}}}}
+			repo, err := name.NewRepository(tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repos, err := b.repositories(repo)
+			if err != nil {
+				t.Fatalf("repositories() = %v", err)
+			}
+			var got []string
+			for _, r := range repos {
+				got = append(got, r.Name())
+			}
+			if d := cmp.Diff(tt.want, got); d != "" {
+				t.Errorf("repositories() diff (-want +got):\n%s", d)
+			}
+		})
+	}
+}
+
+func TestBackend_Targets(t *testing.T) {
+	// A registry that is down.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	registry := strings.TrimPrefix(s.URL, "http://")
+	digest := "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+
+	b := &Backend{
+		logger: logtesting.TestLogger(t),
+		tr:     &v1beta1.TaskRun{ObjectMeta: v1.ObjectMeta{Name: "foo", Namespace: "bar"}},
+		cfg: config.Config{Storage: config.StorageConfigs{OCI: config.OCIStorageConfig{
+			Mappings: []config.RepositoryMapping{{Pattern: "/team/", Replacement: "/mirror/", Also: true}},
+		}}},
+	}
+	statement, err := json.Marshal(in_toto.Statement{StatementHeader: in_toto.StatementHeader{Subject: []in_toto.Subject{
+		{Name: registry + "/team/app", Digest: map[string]string{"sha256": digest}},
+		{Name: registry + "/other/app", Digest: map[string]string{"sha256": digest}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.StorePayload(context.Background(), statement, "", config.StorageOpts{PayloadFormat: "in-toto"}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// Every subject is attempted, with a target for each of its repositories.
+	var got []string
+	for _, target := range b.Targets() {
+		if target.Kind != TargetAttestation || target.Error == "" {
+			t.Errorf("expected a failed attestation target, got %+v", target)
+		}
+		got = append(got, strings.TrimPrefix(target.Image, registry)+" "+strings.TrimPrefix(target.Repository, registry))
+	}
+	want := []string{
+		"/team/app@sha256:" + digest + " /team/app",
+		"/team/app@sha256:" + digest + " /mirror/app",
+		"/other/app@sha256:" + digest + " /other/app",
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("Targets() diff (-want +got):\n%s", d)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// maxIdleConnsPerHost is the number of connections kept open to each registry between pushes.
+// The default of net/http, 2, would have the workers pushing to the same registry reconnect.
+const maxIdleConnsPerHost = 32
+
+var (
+	transportMu sync.Mutex
+	// transport is shared by all the pushes, so that the connections to the registries are
+	// reused, until the settings it was made with change.
+	transport    http.RoundTripper
+	transportKey string
+)
+
+// readFile is set as a var for mocking.
+var readFile = ioutil.ReadFile
+
+// sharedTransport returns the transport registries are pushed to with, made with the proxy
+// and CA certificates of cfg, and the connection settings of its registries.
+func sharedTransport(cfg config.OCIStorageConfig) (http.RoundTripper, error) {
+	certs, err := readCerts(cfg.CACerts)
+	if err != nil {
+		return nil, err
+	}
+	key := cfg.Proxy + "\n" + string(certs)
+	registryCerts := map[string][]byte{}
+	for _, r := range cfg.Registries {
+		c, err := readCerts(r.CACerts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "registry %s", r.Host)
+		}
+		registryCerts[r.Host] = c
+		key += fmt.Sprintf("\n%s %t\n%s", r.Host, r.SkipTLSVerify, c)
+	}
+
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if transport != nil && key == transportKey {
+		return transport, nil
+	}
+	t, err := newTransport(cfg.Proxy, certs, false)
+	if err != nil {
+		return nil, err
+	}
+	var rt http.RoundTripper = t
+	hosts := map[string]*http.Transport{}
+	for _, r := range cfg.Registries {
+		if len(registryCerts[r.Host]) == 0 && !r.SkipTLSVerify {
+			continue
+		}
+		c := append(append([]byte{}, certs...), registryCerts[r.Host]...)
+		if hosts[r.Host], err = newTransport(cfg.Proxy, c, r.SkipTLSVerify); err != nil {
+			return nil, errors.Wrapf(err, "registry %s", r.Host)
+		}
+	}
+	if len(hosts) > 0 {
+		rt = &registryTransport{hosts: hosts, fallback: t}
+	}
+	if old, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		old.CloseIdleConnections()
+	}
+	transport, transportKey = rt, key
+	return rt, nil
+}
+
+// readCerts reads the PEM certificates in the file at path, if it's set.
+func readCerts(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	// The file is read every time, so that the certificates mounted from a ConfigMap or
+	// a secret are picked up when they are updated.
+	certs, err := readFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the registry CA certificates")
+	}
+	return certs, nil
+}
+
+// newTransport returns a transport that connects through proxy, or the proxy of the
+// HTTPS_PROXY and NO_PROXY environment variables when it's empty, and trusts the PEM
+// certificates in certs in addition to the system's. With skipVerify, certificates
+// aren't verified at all.
+func newTransport(proxy string, certs []byte, skipVerify bool) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing the registry proxy %s", proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if len(certs) > 0 || skipVerify {
+		t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: skipVerify}
+	}
+	if len(certs) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(certs) {
+			return nil, fmt.Errorf("no PEM certificates in the registry CA certificates")
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	return t, nil
+}
+
+// registryTransport connects to the registries in hosts with their own transport, and to
+// any other host, e.g. the token services and blob storage registries redirect to, with
+// the fallback.
+type registryTransport struct {
+	hosts    map[string]*http.Transport
+	fallback *http.Transport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *registryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if h, ok := t.hosts[req.URL.Host]; ok {
+		return h.RoundTrip(req)
+	}
+	return t.fallback.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of all the transports.
+func (t *registryTransport) CloseIdleConnections() {
+	for _, h := range t.hosts {
+		h.CloseIdleConnections()
+	}
+	t.fallback.CloseIdleConnections()
+}
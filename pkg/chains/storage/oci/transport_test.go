@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oci
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+func TestSharedTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	certs := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	files := map[string][]byte{"ca.pem": certs, "empty.pem": []byte("nope")}
+	readFile = func(name string) ([]byte, error) {
+		return files[name], nil
+	}
+	defer func() {
+		readFile = ioutil.ReadFile
+		transport, transportKey = nil, ""
+	}()
+
+	cfg := config.OCIStorageConfig{CACerts: "ca.pem"}
+	tr, err := sharedTransport(cfg)
+	if err != nil {
+		t.Fatalf("sharedTransport() = %v", err)
+	}
+	// The registry is trusted with the CA certificates.
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("expected the registry to be trusted: %v", err)
+	}
+	resp.Body.Close()
+
+	again, err := sharedTransport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != tr {
+		t.Error("expected the transport to be shared while the settings don't change")
+	}
+
+	cfg.Proxy = "http://proxy.example.com:3128"
+	proxied, err := sharedTransport(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxied == tr {
+		t.Error("expected a new transport once the proxy changes")
+	}
+	req := &http.Request{URL: &url.URL{Scheme: "https", Host: "gcr.io"}}
+	if u, err := proxied.(*http.Transport).Proxy(req); err != nil || u.String() != cfg.Proxy {
+		t.Errorf("Proxy() = %v, %v, want %s", u, err, cfg.Proxy)
+	}
+
+	if _, err := sharedTransport(config.OCIStorageConfig{CACerts: "empty.pem"}); err == nil {
+		t.Error("expected an error for a file without certificates")
+	}
+}
+
+func TestSharedTransport_Registries(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	lab := httptest.NewTLSServer(handler)
+	defer lab.Close()
+	internal := httptest.NewTLSServer(handler)
+	defer internal.Close()
+	other := httptest.NewTLSServer(handler)
+	defer other.Close()
+	certs := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: internal.Certificate().Raw})
+	readFile = func(name string) ([]byte, error) {
+		return certs, nil
+	}
+	defer func() {
+		readFile = ioutil.ReadFile
+		transport, transportKey = nil, ""
+	}()
+
+	host := func(srv *httptest.Server) string {
+		return strings.TrimPrefix(srv.URL, "https://")
+	}
+	tr, err := sharedTransport(config.OCIStorageConfig{Registries: []config.RegistryConnection{
+		{Host: host(lab), SkipTLSVerify: true},
+		{Host: host(internal), CACerts: "internal.pem"},
+	}})
+	if err != nil {
+		t.Fatalf("sharedTransport() = %v", err)
+	}
+	client := &http.Client{Transport: tr}
+	for _, srv := range []*httptest.Server{lab, internal} {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Errorf("expected %s to be trusted: %v", host(srv), err)
+			continue
+		}
+		resp.Body.Close()
+	}
+	// The settings of a registry only apply to it.
+	if _, err := client.Get(other.URL); err == nil {
+		t.Errorf("expected %s not to be trusted", host(other))
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"go.uber.org/zap"
+)
+
+const (
+	StorageBackendResults = "results"
+
+	// ResultAnnotation is set on TaskRuns by the Tekton Results watcher to the name of
+	// the Result that their Records belong to.
+	ResultAnnotation = "results.tekton.dev/result"
+
+	// RecordType is the data type of the Records that signed payloads are stored in.
+	RecordType = "dev.tekton.chains.signed.v1"
+
+	apiPrefix = "/apis/results.tekton.dev/v1alpha2/parents/"
+	// serviceAccountTokenPath is the token that requests to the Results API are authorized with.
+	serviceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// Backend is a storage backend that stores signed payloads as Records in Tekton Results,
+// alongside the Records of the TaskRun itself.
+type Backend struct {
+	logger    *zap.SugaredLogger
+	tr        *v1beta1.TaskRun
+	address   string
+	client    *http.Client
+	tokenPath string
+}
+
+// SignedRecord is the data of a Record that holds a signed payload.
+type SignedRecord struct {
+	Signed    []byte `json:"signed"`
+	Signature string `json:"signature"`
+	Cert      string `json:"cert,omitempty"`
+	Chain     string `json:"chain,omitempty"`
+	// Bundle is the transparency log entry of the signature, if it was uploaded to one.
+	Bundle *config.TlogBundle `json:"bundle,omitempty"`
+}
+
+// record and recordData follow the JSON mapping of the Results Record message.
+type record struct {
+	Name string     `json:"name"`
+	Data recordData `json:"data"`
+}
+
+type recordData struct {
+	Type  string `json:"type"`
+	Value []byte `json:"value"`
+}
+
+// NewStorageBackend returns a new Results StorageBackend that stores signed payloads as Records
+func NewStorageBackend(logger *zap.SugaredLogger, tr *v1beta1.TaskRun, cfg config.Config) *Backend {
+	return &Backend{
+		logger:    logger,
+		tr:        tr,
+		address:   strings.TrimSuffix(cfg.Storage.Results.Address, "/"),
+		client:    http.DefaultClient,
+		tokenPath: serviceAccountTokenPath,
+	}
+}
+
+// StorePayload implements the Payloader interface.
+func (b *Backend) StorePayload(ctx context.Context, signed []byte, signature string, opts config.StorageOpts) error {
+	value, err := json.Marshal(SignedRecord{
+		Signed:    signed,
+		Signature: signature,
+		Cert:      opts.Cert,
+		Chain:     opts.Chain,
+		Bundle:    opts.Bundle,
+	})
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(record{
+		Name: b.recordName(opts),
+		Data: recordData{Type: RecordType, Value: value},
+	})
+	if err != nil {
+		return err
+	}
+	b.logger.Infof("Storing payload in Tekton Results record %s", b.recordName(opts))
+	_, err = b.do(ctx, http.MethodPost, apiPrefix+b.resultName()+"/records", body)
+	var serr *statusError
+	if !errors.As(err, &serr) || serr.code != http.StatusConflict {
+		return errors.Wrap(err, "creating record")
+	}
+	// The record was stored by an earlier attempt, and is updated with this one.
+	_, err = b.do(ctx, http.MethodPatch, apiPrefix+b.recordName(opts), body)
+	return errors.Wrap(err, "updating record")
+}
+
+func (b *Backend) Type() string {
+	return StorageBackendResults
+}
+
+func (b *Backend) RetrieveSignature(opts config.StorageOpts) (string, error) {
+	r, err := b.retrieveRecord(opts)
+	if err != nil {
+		return "", err
+	}
+	return r.Signature, nil
+}
+
+func (b *Backend) RetrievePayload(opts config.StorageOpts) (string, error) {
+	r, err := b.retrieveRecord(opts)
+	if err != nil {
+		return "", err
+	}
+	return string(r.Signed), nil
+}
+
+func (b *Backend) retrieveRecord(opts config.StorageOpts) (SignedRecord, error) {
+	body, err := b.do(context.Background(), http.MethodGet, apiPrefix+b.recordName(opts), nil)
+	if err != nil {
+		return SignedRecord{}, errors.Wrap(err, "getting record")
+	}
+	var rec record
+	if err := json.Unmarshal(body, &rec); err != nil {
+		return SignedRecord{}, err
+	}
+	var sr SignedRecord
+	if err := json.Unmarshal(rec.Data.Value, &sr); err != nil {
+		return SignedRecord{}, err
+	}
+	return sr, nil
+}
+
+// resultName returns the Result that the TaskRun's Records belong to. Without the
+// annotation from the Results watcher, this is the Result the watcher would create.
+func (b *Backend) resultName() string {
+	if name, ok := b.tr.Annotations[ResultAnnotation]; ok {
+		return name
+	}
+	return fmt.Sprintf("%s/results/%s", b.tr.Namespace, b.tr.UID)
+}
+
+func (b *Backend) recordName(opts config.StorageOpts) string {
+	return fmt.Sprintf("%s/records/chains-%s", b.resultName(), opts.Key)
+}
+
+func (b *Backend) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.address+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token, err := ioutil.ReadFile(b.tokenPath); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &statusError{code: resp.StatusCode, msg: fmt.Sprintf("%s %s: %s: %s", method, path, resp.Status, respBody)}
+	}
+	return respBody, nil
+}
+
+// statusError is returned for the requests the Results API didn't accept.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
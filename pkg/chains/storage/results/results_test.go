@@ -0,0 +1,158 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+// fakeResults serves the Record endpoints of the Results API from memory.
+type fakeResults struct {
+	records map[string][]byte
+	token   string
+}
+
+func (f *fakeResults) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.token = r.Header.Get("Authorization")
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	switch r.Method {
+	case http.MethodPost:
+		body, _ := ioutil.ReadAll(r.Body)
+		var rec record
+		if err := json.Unmarshal(body, &rec); err != nil || !strings.HasPrefix(rec.Name, strings.TrimSuffix(path, "records")) {
+			http.Error(w, "bad record", http.StatusBadRequest)
+			return
+		}
+		if _, ok := f.records[rec.Name]; ok {
+			http.Error(w, "record already exists", http.StatusConflict)
+			return
+		}
+		f.records[rec.Name] = body
+		w.Write(body)
+	case http.MethodPatch:
+		body, _ := ioutil.ReadAll(r.Body)
+		var rec record
+		if err := json.Unmarshal(body, &rec); err != nil || rec.Name != path {
+			http.Error(w, "bad record", http.StatusBadRequest)
+			return
+		}
+		if _, ok := f.records[path]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		f.records[path] = body
+		w.Write(body)
+	case http.MethodGet:
+		body, ok := f.records[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}
+}
+
+func TestBackend_StorePayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantRecord  string
+	}{
+		{
+			name:       "no result annotation",
+			wantRecord: "default/results/uid-1234/records/chains-taskrun-uid-1234",
+		}, {
+			name:        "result annotation",
+			annotations: map[string]string{ResultAnnotation: "default/results/pipelinerun-uid"},
+			wantRecord:  "default/results/pipelinerun-uid/records/chains-taskrun-uid-1234",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeResults{records: map[string][]byte{}}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			tokenPath := filepath.Join(t.TempDir(), "token")
+			if err := ioutil.WriteFile(tokenPath, []byte("sa-token\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "foo",
+					Namespace:   "default",
+					UID:         "uid-1234",
+					Annotations: tt.annotations,
+				},
+			}
+			cfg := config.Config{Storage: config.StorageConfigs{Results: config.ResultsStorageConfig{Address: server.URL + "/"}}}
+			b := NewStorageBackend(logtesting.TestLogger(t), tr, cfg)
+			b.tokenPath = tokenPath
+
+			opts := config.StorageOpts{Key: "taskrun-uid-1234", Cert: "cert"}
+			if err := b.StorePayload(context.Background(), []byte(`{"foo":"bar"}`), "signature", opts); err != nil {
+				t.Fatalf("StorePayload() error = %v", err)
+			}
+			if _, ok := fake.records[tt.wantRecord]; !ok {
+				t.Errorf("expected record %s, got %v", tt.wantRecord, fake.records)
+			}
+			if fake.token != "Bearer sa-token" {
+				t.Errorf("Authorization = %q, want the service account token", fake.token)
+			}
+
+			payload, err := b.RetrievePayload(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload != `{"foo":"bar"}` {
+				t.Errorf("RetrievePayload() = %s", payload)
+			}
+			sig, err := b.RetrieveSignature(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sig != "signature" {
+				t.Errorf("RetrieveSignature() = %s", sig)
+			}
+
+			if _, err := b.RetrievePayload(config.StorageOpts{Key: "missing"}); err == nil {
+				t.Error("expected an error for a missing record")
+			}
+
+			// A retry stores the payload again, over the record of the earlier attempt.
+			if err := b.StorePayload(context.Background(), []byte(`{"foo":"baz"}`), "signature-2", opts); err != nil {
+				t.Fatalf("StorePayload() again error = %v", err)
+			}
+			if payload, err := b.RetrievePayload(opts); err != nil || payload != `{"foo":"baz"}` {
+				t.Errorf("RetrievePayload() after the retry = %s, %v", payload, err)
+			}
+			if sig, err := b.RetrieveSignature(opts); err != nil || sig != "signature-2" {
+				t.Errorf("RetrieveSignature() after the retry = %s, %v", sig, err)
+			}
+		})
+	}
+}
@@ -14,9 +14,13 @@ limitations under the License.
 package storage
 
 import (
+	"context"
+
+	"github.com/pkg/errors"
 	"github.com/tektoncd/chains/pkg/chains/storage/docdb"
 	"github.com/tektoncd/chains/pkg/chains/storage/gcs"
 	"github.com/tektoncd/chains/pkg/chains/storage/oci"
+	"github.com/tektoncd/chains/pkg/chains/storage/results"
 	"github.com/tektoncd/chains/pkg/chains/storage/tekton"
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
@@ -27,44 +31,115 @@ import (
 
 // Backend is an interface to store a chains Payload
 type Backend interface {
-	StorePayload(rawPayload []byte, signature string, opts config.StorageOpts) error
+	StorePayload(ctx context.Context, rawPayload []byte, signature string, opts config.StorageOpts) error
 	RetrievePayload(opts config.StorageOpts) (string, error)
 	RetrieveSignature(opts config.StorageOpts) (string, error)
 	Type() string
 }
 
+// CertificateStore is implemented by the backends that store the certificate and chain
+// payloads were signed with, so keyless signatures can be verified. They return "" for
+// payloads signed without a certificate.
+type CertificateStore interface {
+	RetrieveCert(opts config.StorageOpts) (string, error)
+	RetrieveChain(opts config.StorageOpts) (string, error)
+}
+
+// RetrieveCertificates returns the certificate and chain a payload stored in b was signed with,
+// or "" when it had none, or b doesn't store them.
+func RetrieveCertificates(b Backend, opts config.StorageOpts) (string, string, error) {
+	c, ok := b.(CertificateStore)
+	if !ok {
+		return "", "", nil
+	}
+	cert, err := c.RetrieveCert(opts)
+	if err != nil || cert == "" {
+		return "", "", err
+	}
+	chain, err := c.RetrieveChain(opts)
+	if err != nil {
+		return "", "", err
+	}
+	return cert, chain, nil
+}
+
 // InitializeBackends creates and initializes every configured storage backend.
 func InitializeBackends(ps versioned.Interface, kc kubernetes.Interface, logger *zap.SugaredLogger, tr *v1beta1.TaskRun, cfg config.Config) (map[string]Backend, error) {
 	// Add an entry here for every configured backend
 	configuredBackends := []string{
 		cfg.Artifacts.TaskRuns.StorageBackend,
 		cfg.Artifacts.OCI.StorageBackend}
+	// SBOMs are always attached to their images.
+	if cfg.SBOM.Enabled {
+		configuredBackends = append(configuredBackends, oci.StorageBackendOCI)
+	}
+	for _, r := range cfg.Storage.Routes {
+		configuredBackends = append(configuredBackends, r.Backends...)
+	}
 
 	// Now only initialize and return the configured ones.
 	backends := map[string]Backend{}
 	for _, backendType := range configuredBackends {
-		switch backendType {
-		case gcs.StorageBackendGCS:
-			gcsBackend, err := gcs.NewStorageBackend(logger, tr, cfg)
-			if err != nil {
-				return nil, err
-			}
-			backends[backendType] = gcsBackend
-		case tekton.StorageBackendTekton:
-			backends[backendType] = tekton.NewStorageBackend(ps, logger, tr)
-		case oci.StorageBackendOCI:
-			ociBackend, err := oci.NewStorageBackend(logger, kc, tr, cfg)
+		if _, ok := backends[backendType]; ok {
+			continue
+		}
+		b, err := InitializeBackend(ps, kc, logger, tr, cfg, backendType)
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			backends[backendType] = b
+		}
+	}
+	return backends, nil
+}
+
+// InitializeBackend creates and initializes the storage backend of the given type. It returns
+// nil for unknown types. The latency and errors of the backend are recorded in metrics.
+func InitializeBackend(ps versioned.Interface, kc kubernetes.Interface, logger *zap.SugaredLogger, tr *v1beta1.TaskRun, cfg config.Config, backendType string) (Backend, error) {
+	b, err := newBackend(ps, kc, logger, tr, cfg, backendType)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, nil
+	}
+	return &instrumented{Backend: b}, nil
+}
+
+// Targets returns the pushes made by an oci backend, or nil for the other backends.
+func Targets(b Backend) []oci.Target {
+	if i, ok := b.(*instrumented); ok {
+		b = i.Backend
+	}
+	if o, ok := b.(*oci.Backend); ok {
+		return o.Targets()
+	}
+	return nil
+}
+
+func newBackend(ps versioned.Interface, kc kubernetes.Interface, logger *zap.SugaredLogger, tr *v1beta1.TaskRun, cfg config.Config, backendType string) (Backend, error) {
+	switch backendType {
+	case gcs.StorageBackendGCS:
+		return gcs.NewStorageBackend(logger, tr, cfg)
+	case tekton.StorageBackendTekton:
+		var fallback tekton.Fallback
+		if fb := cfg.Storage.Tekton.Fallback; fb != "" && fb != tekton.StorageBackendTekton {
+			b, err := InitializeBackend(ps, kc, logger, tr, cfg, fb)
 			if err != nil {
-				return nil, err
+				return nil, errors.Wrap(err, "initializing storage.tekton.fallback")
 			}
-			backends[backendType] = ociBackend
-		case docdb.StorageTypeDocDB:
-			docdbBackend, err := docdb.NewStorageBackend(logger, tr, cfg)
-			if err != nil {
-				return nil, err
+			if b != nil {
+				fallback = b
 			}
-			backends[backendType] = docdbBackend
 		}
+		return tekton.NewStorageBackend(ps, logger, tr, cfg.Storage.Tekton, fallback), nil
+	case oci.StorageBackendOCI:
+		return oci.NewStorageBackend(logger, kc, tr, cfg)
+	case docdb.StorageTypeDocDB:
+		return docdb.NewStorageBackend(logger, tr, cfg)
+	case results.StorageBackendResults:
+		return results.NewStorageBackend(logger, tr, cfg), nil
 	}
-	return backends, nil
+	return nil, nil
 }
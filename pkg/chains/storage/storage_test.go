@@ -14,14 +14,19 @@ limitations under the License.
 package storage
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client/fake"
+	"go.opencensus.io/stats/view"
 	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	logtesting "knative.dev/pkg/logging/testing"
+	knativemetrics "knative.dev/pkg/metrics"
 	rtesting "knative.dev/pkg/reconciler/testing"
 )
 
@@ -61,3 +66,120 @@ func TestInitializeBackends(t *testing.T) {
 		})
 	}
 }
+
+type failingBackend struct {
+	Backend
+}
+
+func (b *failingBackend) StorePayload(context.Context, []byte, string, config.StorageOpts) error {
+	return errors.New("unavailable")
+}
+
+func (b *failingBackend) Type() string {
+	return "failing"
+}
+
+func TestInstrumented(t *testing.T) {
+	knativemetrics.InitForTesting()
+	b := &instrumented{Backend: &failingBackend{}}
+	if err := b.StorePayload(context.Background(), nil, "", config.StorageOpts{}); err == nil {
+		t.Fatal("expected the error of the backend")
+	}
+
+	for _, v := range []string{"storage_duration_seconds", "storage_errors_count"} {
+		rows, err := view.RetrieveData(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, r := range rows {
+			tags := map[string]string{}
+			for _, tg := range r.Tags {
+				tags[tg.Key.Name()] = tg.Value
+			}
+			found = found || (tags["backend"] == "failing" && tags["operation"] == "store")
+		}
+		if !found {
+			t.Errorf("expected %s to be recorded for the failing backend, got %v", v, rows)
+		}
+	}
+}
+
+type listingBackend struct {
+	Backend
+	payloads []config.ListedPayload
+}
+
+func (b *listingBackend) ListPayloads(context.Context, config.ListFilter) ([]config.ListedPayload, error) {
+	return b.payloads, nil
+}
+
+func (b *listingBackend) Type() string {
+	return "listing"
+}
+
+func TestListPayloads(t *testing.T) {
+	now := time.Now()
+	statement := config.ListedPayload{
+		Namespace: "foo",
+		Key:       "statement",
+		Payload:   []byte(`{"_type":"https://in-toto.io/Statement/v0.1","subject":[{"name":"gcr.io/foo/bar","digest":{"sha256":"abc"}}]}`),
+		Stored:    now.Add(-48 * time.Hour),
+	}
+	simple := config.ListedPayload{
+		Namespace: "bar",
+		Key:       "simple",
+		Payload:   []byte(`{"critical":{"identity":{"docker-reference":"gcr.io/foo/bar"},"image":{"docker-manifest-digest":"sha256:def"},"type":"cosign container image signature"}}`),
+		Stored:    now,
+	}
+	taskRun := config.ListedPayload{Namespace: "foo", Key: "taskrun", Payload: []byte(`{"kind":"TaskRun"}`), Stored: now}
+	b := &instrumented{Backend: &listingBackend{payloads: []config.ListedPayload{statement, simple, taskRun}}}
+
+	tests := []struct {
+		name   string
+		filter config.ListFilter
+		want   []string
+	}{{
+		name: "everything",
+		want: []string{"statement", "simple", "taskrun"},
+	}, {
+		name:   "namespace",
+		filter: config.ListFilter{Namespace: "foo"},
+		want:   []string{"statement", "taskrun"},
+	}, {
+		name:   "statement subject",
+		filter: config.ListFilter{Digest: "sha256:abc"},
+		want:   []string{"statement"},
+	}, {
+		name:   "simple signing image",
+		filter: config.ListFilter{Digest: "sha256:def"},
+		want:   []string{"simple"},
+	}, {
+		name:   "since",
+		filter: config.ListFilter{Since: now.Add(-time.Hour)},
+		want:   []string{"simple", "taskrun"},
+	}, {
+		name:   "until",
+		filter: config.ListFilter{Until: now.Add(-time.Hour)},
+		want:   []string{"statement"},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed, err := ListPayloads(context.Background(), b, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, p := range listed {
+				got = append(got, p.Key)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListPayloads() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ListPayloads(context.Background(), &instrumented{Backend: &failingBackend{}}, config.ListFilter{}); !errors.Is(err, ErrListNotSupported) {
+		t.Errorf("ListPayloads() error = %v, want %v", err, ErrListNotSupported)
+	}
+}
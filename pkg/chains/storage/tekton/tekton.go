@@ -16,8 +16,11 @@ package tekton
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/tektoncd/chains/pkg/config"
 
 	"github.com/tektoncd/chains/pkg/patch"
@@ -34,44 +37,104 @@ const (
 	SignatureAnnotationFormat = "chains.tekton.dev/signature-%s"
 	CertAnnotationsFormat     = "chains.tekton.dev/cert-%s"
 	ChainAnnotationFormat     = "chains.tekton.dev/chain-%s"
+	BundleAnnotationFormat    = "chains.tekton.dev/bundle-%s"
+	// FallbackAnnotationFormat records the backend a payload was stored in instead, because it
+	// didn't fit in the annotations of the TaskRun. It isn't garbage collected.
+	FallbackAnnotationFormat = "chains.tekton.dev/fallback-%s"
 )
 
+// Fallback is a storage backend payloads that don't fit in the annotations of a TaskRun are
+// stored in instead.
+type Fallback interface {
+	StorePayload(ctx context.Context, rawPayload []byte, signature string, opts config.StorageOpts) error
+	RetrievePayload(opts config.StorageOpts) (string, error)
+	RetrieveSignature(opts config.StorageOpts) (string, error)
+	Type() string
+}
+
 // Backend is a storage backend that stores signed payloads in the TaskRun metadata as an annotation.
 // It is stored as base64 encoded JSON.
 type Backend struct {
-	pipelienclientset versioned.Interface
-	logger            *zap.SugaredLogger
-	tr                *v1beta1.TaskRun
+	pipelienclientset  versioned.Interface
+	logger             *zap.SugaredLogger
+	tr                 *v1beta1.TaskRun
+	maxAnnotationsSize int
+	fallback           Fallback
+	// annotations are the annotations of the TaskRun payloads are measured against. They're
+	// read once, then taken from the TaskRun each patch returns.
+	annotations map[string]string
 }
 
-// NewStorageBackend returns a new Tekton StorageBackend that stores signatures on a TaskRun
-func NewStorageBackend(ps versioned.Interface, logger *zap.SugaredLogger, tr *v1beta1.TaskRun) *Backend {
+// NewStorageBackend returns a new Tekton StorageBackend that stores signatures on a TaskRun.
+// Payloads that would make the annotations of the TaskRun larger than cfg.MaxAnnotationsSize
+// are stored in fallback instead, and fail to be stored when it's nil.
+func NewStorageBackend(ps versioned.Interface, logger *zap.SugaredLogger, tr *v1beta1.TaskRun, cfg config.TektonStorageConfig, fallback Fallback) *Backend {
 	return &Backend{
-		pipelienclientset: ps,
-		logger:            logger,
-		tr:                tr,
+		pipelienclientset:  ps,
+		logger:             logger,
+		tr:                 tr,
+		maxAnnotationsSize: cfg.MaxAnnotationsSize,
+		fallback:           fallback,
 	}
 }
 
 // StorePayload implements the Payloader interface.
-func (b *Backend) StorePayload(rawPayload []byte, signature string, opts config.StorageOpts) error {
+func (b *Backend) StorePayload(ctx context.Context, rawPayload []byte, signature string, opts config.StorageOpts) error {
 	b.logger.Infof("Storing payload on TaskRun %s/%s", b.tr.Namespace, b.tr.Name)
 
-	// Use patch instead of update to prevent race conditions.
-	patchBytes, err := patch.GetAnnotationsPatch(map[string]string{
+	annotations := map[string]string{
 		// Base64 encode both the signature and the payload
 		fmt.Sprintf(PayloadAnnotationFormat, opts.Key):   base64.StdEncoding.EncodeToString(rawPayload),
 		fmt.Sprintf(SignatureAnnotationFormat, opts.Key): base64.StdEncoding.EncodeToString([]byte(signature)),
 		fmt.Sprintf(CertAnnotationsFormat, opts.Key):     base64.StdEncoding.EncodeToString([]byte(opts.Cert)),
 		fmt.Sprintf(ChainAnnotationFormat, opts.Key):     base64.StdEncoding.EncodeToString([]byte(opts.Chain)),
-	})
+	}
+	if opts.Bundle != nil {
+		bundle, err := json.Marshal(opts.Bundle)
+		if err != nil {
+			return err
+		}
+		annotations[fmt.Sprintf(BundleAnnotationFormat, opts.Key)] = base64.StdEncoding.EncodeToString(bundle)
+	}
+
+	maxSize := b.maxAnnotationsSize
+	if maxSize == 0 {
+		maxSize = config.DefaultMaxAnnotationsSize
+	}
+	size, err := b.annotationsSize(ctx, annotations)
+	if err != nil {
+		return err
+	}
+	var removed []string
+	if size > maxSize {
+		if b.fallback == nil {
+			return fmt.Errorf("storing payload %s would make the annotations of TaskRun %s/%s %d bytes, more than the %d allowed, and storage.tekton.fallback is not set", opts.Key, b.tr.Namespace, b.tr.Name, size, maxSize)
+		}
+		b.logger.Infof("Storing payload %s in the %s backend, since the annotations of TaskRun %s/%s would be %d bytes, more than the %d allowed", opts.Key, b.fallback.Type(), b.tr.Namespace, b.tr.Name, size, maxSize)
+		if err := b.fallback.StorePayload(ctx, rawPayload, signature, opts); err != nil {
+			return errors.Wrapf(err, "storing payload %s in the %s fallback", opts.Key, b.fallback.Type())
+		}
+		// The annotations of a payload stored before, e.g. by an earlier signing, would
+		// otherwise be retrieved instead of the fallback's.
+		for k := range annotations {
+			removed = append(removed, k)
+		}
+		annotations = map[string]string{fmt.Sprintf(FallbackAnnotationFormat, opts.Key): b.fallback.Type()}
+	} else {
+		removed = []string{fmt.Sprintf(FallbackAnnotationFormat, opts.Key)}
+	}
+
+	// Use patch instead of update to prevent race conditions.
+	patchBytes, err := patch.GetAnnotationsRemovalPatch(annotations, removed)
 	if err != nil {
 		return err
 	}
-	if _, err := b.pipelienclientset.TektonV1beta1().TaskRuns(b.tr.Namespace).Patch(
-		context.TODO(), b.tr.Name, types.MergePatchType, patchBytes, v1.PatchOptions{}); err != nil {
+	tr, err := b.pipelienclientset.TektonV1beta1().TaskRuns(b.tr.Namespace).Patch(
+		ctx, b.tr.Name, types.MergePatchType, patchBytes, v1.PatchOptions{})
+	if err != nil {
 		return err
 	}
+	b.annotations = tr.Annotations
 	return nil
 }
 
@@ -79,6 +142,43 @@ func (b *Backend) Type() string {
 	return StorageBackendTekton
 }
 
+// annotationsSize returns the size the annotations of the TaskRun would be with annotations
+// added, counted as Kubernetes does. The TaskRun is only read for the first payload.
+func (b *Backend) annotationsSize(ctx context.Context, annotations map[string]string) (int, error) {
+	if b.annotations == nil {
+		tr, err := b.pipelienclientset.TektonV1beta1().TaskRuns(b.tr.Namespace).Get(ctx, b.tr.Name, v1.GetOptions{})
+		if err != nil {
+			return 0, errors.Wrap(err, "getting taskrun")
+		}
+		b.annotations = tr.Annotations
+		if b.annotations == nil {
+			b.annotations = map[string]string{}
+		}
+	}
+	size := 0
+	for k, v := range b.annotations {
+		if _, ok := annotations[k]; !ok {
+			size += len(k) + len(v)
+		}
+	}
+	for k, v := range annotations {
+		size += len(k) + len(v)
+	}
+	return size, nil
+}
+
+// storedIn returns the fallback a payload was stored in, or nil if it's in the annotations.
+func (b *Backend) storedIn(key string) (Fallback, error) {
+	stored, err := b.retrieveAnnotationValue(fmt.Sprintf(FallbackAnnotationFormat, key), false)
+	if err != nil || stored == "" {
+		return nil, err
+	}
+	if b.fallback == nil || b.fallback.Type() != stored {
+		return nil, fmt.Errorf("payload %s was stored in the %s backend, which isn't storage.tekton.fallback", key, stored)
+	}
+	return b.fallback, nil
+}
+
 // retrieveAnnotationValue retrieve the value of an annotation and base64 decode it if needed.
 func (b *Backend) retrieveAnnotationValue(annotationKey string, decode bool) (string, error) {
 	// Retrieve the TaskRun.
@@ -112,12 +212,101 @@ func (b *Backend) retrieveAnnotationValue(annotationKey string, decode bool) (st
 // RetrieveSignature retrieve the signature stored in the taskrun.
 func (b *Backend) RetrieveSignature(opts config.StorageOpts) (string, error) {
 	b.logger.Infof("Retrieving signature on TaskRun %s/%s", b.tr.Namespace, b.tr.Name)
+	fallback, err := b.storedIn(opts.Key)
+	if err != nil {
+		return "", err
+	}
+	if fallback != nil {
+		return fallback.RetrieveSignature(opts)
+	}
 	return b.retrieveAnnotationValue(fmt.Sprintf(SignatureAnnotationFormat, opts.Key), true)
 }
 
+// certStore is implemented by the fallbacks that store the certificates payloads were signed with.
+type certStore interface {
+	RetrieveCert(opts config.StorageOpts) (string, error)
+	RetrieveChain(opts config.StorageOpts) (string, error)
+}
+
+// RetrieveCert retrieves the certificate the payload stored in the taskrun was signed with, or
+// "" if it was signed without one.
+func (b *Backend) RetrieveCert(opts config.StorageOpts) (string, error) {
+	fallback, err := b.storedIn(opts.Key)
+	if err != nil {
+		return "", err
+	}
+	if fallback != nil {
+		if c, ok := fallback.(certStore); ok {
+			return c.RetrieveCert(opts)
+		}
+		return "", nil
+	}
+	return b.retrieveAnnotationValue(fmt.Sprintf(CertAnnotationsFormat, opts.Key), true)
+}
+
+// RetrieveChain retrieves the chain of the certificate the payload stored in the taskrun was
+// signed with, or "" if it was signed without one.
+func (b *Backend) RetrieveChain(opts config.StorageOpts) (string, error) {
+	fallback, err := b.storedIn(opts.Key)
+	if err != nil {
+		return "", err
+	}
+	if fallback != nil {
+		if c, ok := fallback.(certStore); ok {
+			return c.RetrieveChain(opts)
+		}
+		return "", nil
+	}
+	return b.retrieveAnnotationValue(fmt.Sprintf(ChainAnnotationFormat, opts.Key), true)
+}
+
+// ListPayloads lists the payloads stored on the TaskRuns in filter.Namespace, or in every
+// namespace. Payloads stored in the fallback aren't listed. The time a TaskRun completed stands
+// in for when its payloads were stored.
+func (b *Backend) ListPayloads(ctx context.Context, filter config.ListFilter) ([]config.ListedPayload, error) {
+	trs, err := b.pipelienclientset.TektonV1beta1().TaskRuns(filter.Namespace).List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing taskruns")
+	}
+	prefix := strings.TrimSuffix(PayloadAnnotationFormat, "%s")
+	listed := []config.ListedPayload{}
+	for _, tr := range trs.Items {
+		stored := tr.CreationTimestamp.Time
+		if tr.Status.CompletionTime != nil {
+			stored = tr.Status.CompletionTime.Time
+		}
+		for annotation := range tr.Annotations {
+			key := strings.TrimPrefix(annotation, prefix)
+			if key == annotation {
+				continue
+			}
+			p := config.ListedPayload{Namespace: tr.Namespace, TaskRun: tr.Name, Key: key, Stored: stored}
+			var payload string
+			for format, v := range map[string]*string{PayloadAnnotationFormat: &payload, SignatureAnnotationFormat: &p.Signature, CertAnnotationsFormat: &p.Cert, ChainAnnotationFormat: &p.Chain} {
+				name := fmt.Sprintf(format, key)
+				decoded, err := base64.StdEncoding.DecodeString(tr.Annotations[name])
+				if err != nil {
+					return nil, fmt.Errorf("error decoding the annotation value for the key %q: %s", name, err)
+				}
+				*v = string(decoded)
+			}
+			p.Payload = []byte(payload)
+			listed = append(listed, p)
+		}
+	}
+	return listed, nil
+}
+
 // RetrievePayload retrieve the payload stored in the taskrun.
 func (b *Backend) RetrievePayload(opts config.StorageOpts) (string, error) {
 	b.logger.Infof("Retrieving payload on TaskRun %s/%s", b.tr.Namespace, b.tr.Name)
+	fallback, err := b.storedIn(opts.Key)
+	if err != nil {
+		return "", err
+	}
+	if fallback != nil {
+		return fallback.RetrievePayload(opts)
+	}
 	payload, err := b.retrieveAnnotationValue(fmt.Sprintf(PayloadAnnotationFormat, opts.Key), true)
 	if err != nil {
 		return "", err
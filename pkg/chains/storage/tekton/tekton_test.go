@@ -14,7 +14,9 @@ limitations under the License.
 package tekton
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -32,6 +34,7 @@ func TestBackend_StorePayload(t *testing.T) {
 	tests := []struct {
 		name    string
 		payload interface{}
+		bundle  *config.TlogBundle
 		wantErr bool
 	}{
 		{
@@ -41,6 +44,14 @@ func TestBackend_StorePayload(t *testing.T) {
 				B: 3,
 			},
 		},
+		{
+			name: "with transparency log bundle",
+			payload: mockPayload{
+				A: "foo",
+				B: 3,
+			},
+			bundle: &config.TlogBundle{Body: "Ym9keQ==", LogIndex: 42, SignedEntryTimestamp: []byte("set")},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -65,9 +76,9 @@ func TestBackend_StorePayload(t *testing.T) {
 			if err != nil {
 				t.Errorf("error marshaling json: %v", err)
 			}
-			opts := config.StorageOpts{Key: "mockpayload"}
+			opts := config.StorageOpts{Key: "mockpayload", Bundle: tt.bundle}
 			mockSignature := "mocksignature"
-			if err := b.StorePayload(payload, mockSignature, opts); (err != nil) != tt.wantErr {
+			if err := b.StorePayload(ctx, payload, mockSignature, opts); (err != nil) != tt.wantErr {
 				t.Errorf("Backend.StorePayload() error = %v, wantErr %v", err, tt.wantErr)
 			}
 
@@ -100,6 +111,25 @@ func TestBackend_StorePayload(t *testing.T) {
 				t.Errorf("unexpected signature: (-want, +got): %s", diff)
 			}
 
+			// The bundle is only stored when there is one.
+			rawBundle, err := b.retrieveAnnotationValue(fmt.Sprintf(BundleAnnotationFormat, opts.Key), true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.bundle == nil {
+				if rawBundle != "" {
+					t.Errorf("unexpected bundle %s", rawBundle)
+				}
+				return
+			}
+			bundle := &config.TlogBundle{}
+			if err := json.Unmarshal([]byte(rawBundle), bundle); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.bundle, bundle); diff != "" {
+				t.Errorf("unexpected bundle: (-want, +got): %s", diff)
+			}
+
 		})
 	}
 }
@@ -109,3 +139,233 @@ type mockPayload struct {
 	A string
 	B int
 }
+
+// memoryBackend stores payloads in memory.
+type memoryBackend struct {
+	payloads, signatures map[string]string
+}
+
+func (m *memoryBackend) StorePayload(ctx context.Context, rawPayload []byte, signature string, opts config.StorageOpts) error {
+	m.payloads[opts.Key] = string(rawPayload)
+	m.signatures[opts.Key] = signature
+	return nil
+}
+
+func (m *memoryBackend) RetrievePayload(opts config.StorageOpts) (string, error) {
+	return m.payloads[opts.Key], nil
+}
+
+func (m *memoryBackend) RetrieveSignature(opts config.StorageOpts) (string, error) {
+	return m.signatures[opts.Key], nil
+}
+
+func (m *memoryBackend) Type() string {
+	return "memory"
+}
+
+func TestBackend_StorePayloadFallback(t *testing.T) {
+	small, large := []byte(`{"A":"small"}`), []byte(fmt.Sprintf(`{"A":"%0500d"}`, 0))
+	tests := []struct {
+		name         string
+		noFallback   bool
+		payload      []byte
+		wantFallback bool
+		wantErr      bool
+	}{{
+		name:    "fits",
+		payload: small,
+	}, {
+		name:         "too large",
+		payload:      large,
+		wantFallback: true,
+	}, {
+		name:       "too large without fallback",
+		noFallback: true,
+		payload:    large,
+		wantErr:    true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			c := fakepipelineclient.Get(ctx)
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "foo",
+					Namespace:   "bar",
+					Annotations: map[string]string{"existing": fmt.Sprintf("%0200d", 0)},
+				},
+			}
+			if _, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			memory := &memoryBackend{payloads: map[string]string{}, signatures: map[string]string{}}
+			var fallback Fallback = memory
+			if tt.noFallback {
+				fallback = nil
+			}
+			// The existing annotation, and a small payload, fit.
+			b := NewStorageBackend(c, logtesting.TestLogger(t), tr, config.TektonStorageConfig{MaxAnnotationsSize: 600}, fallback)
+
+			opts := config.StorageOpts{Key: "mockpayload"}
+			err := b.StorePayload(ctx, tt.payload, "mocksignature", opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StorePayload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, inAnnotations := got.Annotations[fmt.Sprintf(PayloadAnnotationFormat, opts.Key)]
+			if inAnnotations == tt.wantFallback {
+				t.Errorf("payload in the annotations = %v, want %v", inAnnotations, !tt.wantFallback)
+			}
+			wantRef := ""
+			if tt.wantFallback {
+				wantRef = memory.Type()
+			}
+			if ref := got.Annotations[fmt.Sprintf(FallbackAnnotationFormat, opts.Key)]; ref != wantRef {
+				t.Errorf("fallback annotation = %q, want %q", ref, wantRef)
+			}
+			if _, ok := memory.payloads[opts.Key]; ok != tt.wantFallback {
+				t.Errorf("payload in the fallback = %v, want %v", ok, tt.wantFallback)
+			}
+
+			// The payload is retrieved from wherever it was stored.
+			payload, err := b.RetrievePayload(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload != string(tt.payload) {
+				t.Errorf("RetrievePayload() = %s, want %s", payload, tt.payload)
+			}
+			sig, err := b.RetrieveSignature(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sig != "mocksignature" {
+				t.Errorf("RetrieveSignature() = %s, want mocksignature", sig)
+			}
+		})
+	}
+}
+
+func TestBackend_StorePayloadAgain(t *testing.T) {
+	small, large := []byte(`{"A":"small"}`), []byte(fmt.Sprintf(`{"A":"%0500d"}`, 0))
+	tests := []struct {
+		name          string
+		first, second []byte
+		wantFallback  bool
+	}{{
+		name:         "moved to the fallback",
+		first:        small,
+		second:       large,
+		wantFallback: true,
+	}, {
+		name:   "moved back to the annotations",
+		first:  large,
+		second: small,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			c := fakepipelineclient.Get(ctx)
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "foo",
+					Namespace:   "bar",
+					Annotations: map[string]string{"existing": fmt.Sprintf("%0200d", 0)},
+				},
+			}
+			if _, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			memory := &memoryBackend{payloads: map[string]string{}, signatures: map[string]string{}}
+			b := NewStorageBackend(c, logtesting.TestLogger(t), tr, config.TektonStorageConfig{MaxAnnotationsSize: 600}, memory)
+
+			opts := config.StorageOpts{Key: "mockpayload"}
+			for _, p := range [][]byte{tt.first, tt.second} {
+				if err := b.StorePayload(ctx, p, "mocksignature", opts); err != nil {
+					t.Fatalf("StorePayload() = %v", err)
+				}
+			}
+			// The TaskRun is only read to measure the first payload.
+			gets := 0
+			for _, a := range c.Actions() {
+				if a.GetVerb() == "get" {
+					gets++
+				}
+			}
+			if gets != 1 {
+				t.Errorf("StorePayload() got the TaskRun %d times, want 1", gets)
+			}
+
+			got, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, format := range []string{PayloadAnnotationFormat, SignatureAnnotationFormat, CertAnnotationsFormat, ChainAnnotationFormat} {
+				if _, ok := got.Annotations[fmt.Sprintf(format, opts.Key)]; ok == tt.wantFallback {
+					t.Errorf("annotation %s = %v, want %v", fmt.Sprintf(format, opts.Key), ok, !tt.wantFallback)
+				}
+			}
+			if _, ok := got.Annotations[fmt.Sprintf(FallbackAnnotationFormat, opts.Key)]; ok != tt.wantFallback {
+				t.Errorf("fallback annotation = %v, want %v", ok, tt.wantFallback)
+			}
+			payload, err := b.RetrievePayload(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload != string(tt.second) {
+				t.Errorf("RetrievePayload() = %s, want %s", payload, tt.second)
+			}
+		})
+	}
+}
+
+func TestBackend_ListPayloads(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	c := fakepipelineclient.Get(ctx)
+	for _, tr := range []*v1beta1.TaskRun{
+		{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "baz"}},
+	} {
+		if _, err := c.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		b := NewStorageBackend(c, logtesting.TestLogger(t), tr, config.TektonStorageConfig{}, nil)
+		opts := config.StorageOpts{Key: "key", Cert: "cert", Chain: "chain"}
+		if err := b.StorePayload(ctx, []byte(`{"namespace":"`+tr.Namespace+`"}`), "signature", opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := NewStorageBackend(c, logtesting.TestLogger(t), &v1beta1.TaskRun{}, config.TektonStorageConfig{}, nil)
+	got, err := b.ListPayloads(ctx, config.ListFilter{Namespace: "baz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []config.ListedPayload{{
+		Namespace: "baz",
+		TaskRun:   "foo",
+		Key:       "key",
+		Payload:   []byte(`{"namespace":"baz"}`),
+		Signature: "signature",
+		Cert:      "cert",
+		Chain:     "chain",
+	}}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("ListPayloads() diff (-want +got):\n%s", d)
+	}
+
+	got, err = b.ListPayloads(ctx, config.ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("ListPayloads() returned %d payloads, want one for each TaskRun", len(got))
+	}
+}
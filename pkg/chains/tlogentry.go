@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/swag"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+// entryAPIVersion is the version of every kind of entry Chains uploads.
+const entryAPIVersion = "0.0.1"
+
+// NewTlogEntry creates the transparency log entry of the given kind for a signature. Wrapped
+// payloads are signed as a DSSE envelope, which is passed as the signature, and other payloads
+// are passed with their raw signature. pubOrCert is the PEM encoded public key or certificate
+// the signature can be verified with.
+func NewTlogEntry(entryType string, signature, rawPayload, pubOrCert []byte) (models.ProposedEntry, error) {
+	switch entryType {
+	case config.EntryTypeIntoto:
+		pub := strfmt.Base64(pubOrCert)
+		return &models.Intoto{
+			APIVersion: swag.String(entryAPIVersion),
+			Spec: models.IntotoV001Schema{
+				Content: &models.IntotoV001SchemaContent{
+					Envelope: string(signature),
+				},
+				PublicKey: &pub,
+			},
+		}, nil
+	case config.EntryTypeRekord:
+		return &models.Rekord{
+			APIVersion: swag.String(entryAPIVersion),
+			Spec: models.RekordV001Schema{
+				Data: &models.RekordV001SchemaData{
+					Content: strfmt.Base64(rawPayload),
+				},
+				Signature: &models.RekordV001SchemaSignature{
+					Content: strfmt.Base64(signature),
+					Format:  models.RekordV001SchemaSignatureFormatX509,
+					PublicKey: &models.RekordV001SchemaSignaturePublicKey{
+						Content: strfmt.Base64(pubOrCert),
+					},
+				},
+			},
+		}, nil
+	case config.EntryTypeDSSE:
+		return &rawEntry{
+			kind: entryType,
+			spec: dsseSpec{
+				ProposedContent: dsseContent{
+					Envelope:  string(signature),
+					Verifiers: []strfmt.Base64{pubOrCert},
+				},
+			},
+		}, nil
+	case config.EntryTypeHashedRekord:
+		signed, sig, err := signedContent(signature, rawPayload)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(signed)
+		return &rawEntry{
+			kind: entryType,
+			spec: hashedRekordSpec{
+				Signature: hashedRekordSignature{
+					Content:   sig,
+					PublicKey: hashedRekordPublicKey{Content: pubOrCert},
+				},
+				Data: hashedRekordData{
+					Hash: hashedRekordHash{Algorithm: "sha256", Value: hex.EncodeToString(digest[:])},
+				},
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported transparency log entry type %q", entryType)
+}
+
+// signedContent returns what was actually signed, and the raw signature over it. An envelope's
+// signature is over the pre-authentication encoding of its payload, not the payload itself.
+func signedContent(signature, rawPayload []byte) ([]byte, []byte, error) {
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(signature, &env); err != nil || env.PayloadType == "" {
+		return rawPayload, signature, nil
+	}
+	if len(env.Signatures) != 1 {
+		return nil, nil, fmt.Errorf("envelope has %d signatures, wanted 1", len(env.Signatures))
+	}
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "decoding envelope payload")
+	}
+	sig, err := base64.StdEncoding.DecodeString(env.Signatures[0].Sig)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "decoding envelope signature")
+	}
+	return dsse.PAE(env.PayloadType, string(payload)), sig, nil
+}
+
+// rawEntry is an entry of a kind the Rekor client has no model for. It's only ever sent to
+// the log, which validates it.
+type rawEntry struct {
+	kind string
+	spec interface{}
+}
+
+func (e *rawEntry) Kind() string {
+	return e.kind
+}
+
+func (e *rawEntry) SetKind(kind string) {
+	e.kind = kind
+}
+
+func (e *rawEntry) Validate(strfmt.Registry) error {
+	return nil
+}
+
+func (e *rawEntry) ContextValidate(context.Context, strfmt.Registry) error {
+	return nil
+}
+
+func (e *rawEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind       string      `json:"kind"`
+		APIVersion string      `json:"apiVersion"`
+		Spec       interface{} `json:"spec"`
+	}{
+		Kind:       e.kind,
+		APIVersion: entryAPIVersion,
+		Spec:       e.spec,
+	})
+}
+
+// The specs of dsse and hashedrekord entries, as defined by Rekor's schemas.
+type dsseSpec struct {
+	ProposedContent dsseContent `json:"proposedContent"`
+}
+
+type dsseContent struct {
+	Envelope  string          `json:"envelope"`
+	Verifiers []strfmt.Base64 `json:"verifiers"`
+}
+
+type hashedRekordSpec struct {
+	Signature hashedRekordSignature `json:"signature"`
+	Data      hashedRekordData      `json:"data"`
+}
+
+type hashedRekordSignature struct {
+	Content   strfmt.Base64         `json:"content"`
+	PublicKey hashedRekordPublicKey `json:"publicKey"`
+}
+
+type hashedRekordPublicKey struct {
+	Content strfmt.Base64 `json:"content"`
+}
+
+type hashedRekordData struct {
+	Hash hashedRekordHash `json:"hash"`
+}
+
+type hashedRekordHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+func TestNewTlogEntry(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cryptoutils.MarshalPublicKeyToPEM(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(b []byte) []byte {
+		digest := sha256.Sum256(b)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	// A simplesigning payload and its raw signature.
+	payload := []byte(`{"critical":{}}`)
+	rawSig := sign(payload)
+
+	// An in-toto statement, signed as an envelope.
+	statement := []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`)
+	payloadType := "application/vnd.in-toto+json"
+	envelope, err := json.Marshal(dsse.Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(statement),
+		Signatures: []dsse.Signature{{
+			Sig: base64.StdEncoding.EncodeToString(sign(dsse.PAE(payloadType, string(statement)))),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		Kind       string                 `json:"kind"`
+		APIVersion string                 `json:"apiVersion"`
+		Spec       map[string]interface{} `json:"spec"`
+	}
+	tests := []struct {
+		name      string
+		entryType string
+		signature []byte
+		payload   []byte
+		check     func(t *testing.T, e entry)
+	}{{
+		name:      "intoto",
+		entryType: config.EntryTypeIntoto,
+		signature: envelope,
+		payload:   statement,
+		check: func(t *testing.T, e entry) {
+			content := e.Spec["content"].(map[string]interface{})
+			if content["envelope"] != string(envelope) {
+				t.Errorf("envelope = %v, want %s", content["envelope"], envelope)
+			}
+		},
+	}, {
+		name:      "dsse",
+		entryType: config.EntryTypeDSSE,
+		signature: envelope,
+		payload:   statement,
+		check: func(t *testing.T, e entry) {
+			content := e.Spec["proposedContent"].(map[string]interface{})
+			if content["envelope"] != string(envelope) {
+				t.Errorf("envelope = %v, want %s", content["envelope"], envelope)
+			}
+			verifiers := content["verifiers"].([]interface{})
+			if len(verifiers) != 1 || verifiers[0] != base64.StdEncoding.EncodeToString(pub) {
+				t.Errorf("verifiers = %v, want the public key", verifiers)
+			}
+		},
+	}, {
+		name:      "rekord",
+		entryType: config.EntryTypeRekord,
+		signature: rawSig,
+		payload:   payload,
+		check: func(t *testing.T, e entry) {
+			data := e.Spec["data"].(map[string]interface{})
+			if data["content"] != base64.StdEncoding.EncodeToString(payload) {
+				t.Errorf("content = %v, want the payload", data["content"])
+			}
+		},
+	}, {
+		name:      "hashedrekord of a payload",
+		entryType: config.EntryTypeHashedRekord,
+		signature: rawSig,
+		payload:   payload,
+		check: func(t *testing.T, e entry) {
+			verifyHashedRekord(t, e.Spec, &key.PublicKey)
+		},
+	}, {
+		name:      "hashedrekord of an envelope",
+		entryType: config.EntryTypeHashedRekord,
+		signature: envelope,
+		payload:   statement,
+		check: func(t *testing.T, e entry) {
+			verifyHashedRekord(t, e.Spec, &key.PublicKey)
+		},
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposed, err := NewTlogEntry(tt.entryType, tt.signature, tt.payload, pub)
+			if err != nil {
+				t.Fatalf("NewTlogEntry() = %v", err)
+			}
+			b, err := json.Marshal(proposed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got entry
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Kind != tt.entryType || got.APIVersion != "0.0.1" {
+				t.Errorf("got a %s entry of version %s, want a %s entry of version 0.0.1", got.Kind, got.APIVersion, tt.entryType)
+			}
+			tt.check(t, got)
+		})
+	}
+
+	if _, err := NewTlogEntry("helm", rawSig, payload, pub); err == nil {
+		t.Error("expected error creating an unsupported entry")
+	}
+}
+
+// verifyHashedRekord checks the signature in a hashedrekord entry is over the digest in it.
+func verifyHashedRekord(t *testing.T, spec map[string]interface{}, pub *ecdsa.PublicKey) {
+	t.Helper()
+	sig, err := base64.StdEncoding.DecodeString(spec["signature"].(map[string]interface{})["content"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := spec["data"].(map[string]interface{})["hash"].(map[string]interface{})
+	if hash["algorithm"] != "sha256" {
+		t.Errorf("algorithm = %v, want sha256", hash["algorithm"])
+	}
+	digest, err := hex.DecodeString(hash["value"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.VerifyASN1(pub, digest, sig) {
+		t.Error("the signature doesn't verify against the digest")
+	}
+}
@@ -0,0 +1,392 @@
+/*
+Copyright 2020 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/chains/logs"
+	"github.com/tektoncd/chains/pkg/chains/signing"
+	"github.com/tektoncd/chains/pkg/chains/storage"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/chains/pkg/metrics"
+	"github.com/tektoncd/chains/pkg/patch"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+)
+
+// TransparencyPendingAnnotation lists the uploads of a TaskRun's payloads to transparency logs
+// that are queued, as a JSON list of PendingUpload. It's removed once they have all landed.
+const TransparencyPendingAnnotation = "chains.tekton.dev/transparency-pending"
+
+// PendingUpload is a payload waiting to be uploaded to a transparency log. It records what the
+// upload is queued again with after the controller restarted, see ResumeUploads.
+type PendingUpload struct {
+	// Key is the storage key of the payload.
+	Key string `json:"key"`
+	// URL is the transparency log it's uploaded to.
+	URL string `json:"url"`
+	// Primary is set for the log at transparency.url.
+	Primary bool `json:"primary,omitempty"`
+	// EntryType is the kind of entry the payload is uploaded as.
+	EntryType string `json:"entryType,omitempty"`
+	// Signer is the signer, x509, kms or a signer profile, the payload was signed with.
+	Signer string `json:"signer,omitempty"`
+	// Storage is the backend the payload and its signature are read back from. It's empty
+	// when they were only pushed to registries, which they can't be read back from.
+	Storage string `json:"storage,omitempty"`
+}
+
+// ErrUploadsLost is returned by ResumeUploads when the payloads of the pending uploads can't
+// be read back, so the TaskRun has to be signed again.
+var ErrUploadsLost = errors.New("the payloads of the pending transparency log uploads can't be read back")
+
+// The backoff of queued uploads: the delay between attempts doubles from tlogRetryBaseDelay up
+// to tlogRetryMaxDelay, and an upload is given up on after tlogMaxAttempts. That's a little over
+// three hours of retries. Set as vars for testing.
+var (
+	tlogRetryBaseDelay = 5 * time.Second
+	tlogRetryMaxDelay  = 10 * time.Minute
+	tlogMaxAttempts    = 25
+)
+
+// TlogQueue uploads signatures to transparency logs in the background when transparency.async
+// is set, so an outage of a log doesn't hold up or fail signing. Failed uploads are retried
+// with exponential backoff, and each entry is recorded on its TaskRun once it lands.
+//
+// The queue is held in memory. The TaskRuns whose uploads were lost when the controller
+// stopped are found by their TransparencyPendingAnnotation, see Lost, and their uploads are
+// queued again from it, see ResumeUploads.
+type TlogQueue struct {
+	ps    versioned.Interface
+	queue workqueue.RateLimitingInterface
+
+	mu sync.Mutex
+	// queued counts the uploads of each TaskRun that haven't landed or been given up on.
+	queued map[types.NamespacedName]int
+}
+
+// tlogJob is the upload of a signature to one transparency log.
+type tlogJob struct {
+	taskRun types.NamespacedName
+	upload  PendingUpload
+	// primary is set for the log at transparency.url.
+	primary               bool
+	client                rekorClient
+	signer                signing.Signer
+	signature, rawPayload []byte
+	// cert is the certificate the payload was signed with, if any.
+	cert      string
+	entryType string
+	// recorder records events on the TaskRun. It may be nil.
+	recorder record.EventRecorder
+	// logFields identify the TaskRun and payload on the lines logged about the upload.
+	logFields []interface{}
+}
+
+// NewTlogQueue returns a queue that records entries on TaskRuns with ps. Uploads start once Run is called.
+func NewTlogQueue(ps versioned.Interface) *TlogQueue {
+	return &TlogQueue{
+		ps:     ps,
+		queue:  workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(tlogRetryBaseDelay, tlogRetryMaxDelay), "transparency-uploads"),
+		queued: map[types.NamespacedName]int{},
+	}
+}
+
+// Run uploads queued entries one at a time until ctx is done. Entries are recorded one at a
+// time too, so recording the entries of a TaskRun never races with itself.
+func (q *TlogQueue) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		q.queue.ShutDown()
+	}()
+	for q.processNext(ctx) {
+	}
+}
+
+// Lost reports whether the TaskRun is waiting for uploads that aren't queued, because the
+// controller was restarted since they were.
+func (q *TlogQueue) Lost(tr *v1beta1.TaskRun) bool {
+	if q == nil {
+		return false
+	}
+	if pending, err := PendingUploads(tr); err != nil || len(pending) == 0 {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queued[types.NamespacedName{Namespace: tr.Namespace, Name: tr.Name}] == 0
+}
+
+// ResumeUploads queues the uploads a TaskRun is waiting for again, once they're Lost, with the
+// payloads and signatures read back from the backends they were stored in. It returns
+// ErrUploadsLost when one of them can't be read back.
+func (ts *TaskRunSigner) ResumeUploads(ctx context.Context, tr *v1beta1.TaskRun) error {
+	pending, err := PendingUploads(tr)
+	if err != nil {
+		return err
+	}
+	for _, p := range pending {
+		if p.Storage == "" || p.Signer == "" {
+			return errors.Wrapf(ErrUploadsLost, "%s isn't stored in a backend it can be read back from", p.Key)
+		}
+	}
+	cfg := *config.FromContext(ctx)
+	logger := logging.FromContext(ctx)
+	backends, err := getBackends(ts.Pipelineclientset, ts.KubeClient, logs.For(logger, logs.Storage), tr, cfg)
+	if err != nil {
+		return err
+	}
+	keys, err := signingKeys(ctx, ts.KubeClient, ts.SecretPath, cfg, tr)
+	if err != nil {
+		return err
+	}
+	signers := allSigners(keys, cfg, ts.serviceAccountIdentity(tr), logger)
+
+	var jobs []*tlogJob
+	for _, p := range pending {
+		b, ok := backends[p.Storage]
+		if !ok {
+			return errors.Wrapf(ErrUploadsLost, "%s is stored in %s, which is no longer configured", p.Key, p.Storage)
+		}
+		signer, ok := signers[p.Signer]
+		if !ok {
+			return errors.Wrapf(ErrUploadsLost, "%s was signed with %s, which is no longer configured", p.Key, p.Signer)
+		}
+		opts := config.StorageOpts{Key: p.Key}
+		signature, err := b.RetrieveSignature(opts)
+		if err != nil {
+			return errors.Wrapf(err, "retrieving signature %s", p.Key)
+		}
+		payload, err := b.RetrievePayload(opts)
+		if err != nil {
+			return errors.Wrapf(err, "retrieving payload %s", p.Key)
+		}
+		cert, _, err := storage.RetrieveCertificates(b, opts)
+		if err != nil {
+			return errors.Wrapf(err, "retrieving certificate %s", p.Key)
+		}
+		client, err := getRekor(cfg.Transparency, p.URL, ts.SecretPath, logs.For(logger, logs.Transparency))
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, &tlogJob{
+			taskRun:    types.NamespacedName{Namespace: tr.Namespace, Name: tr.Name},
+			upload:     p,
+			primary:    p.Primary,
+			client:     client,
+			signer:     signer,
+			signature:  []byte(signature),
+			rawPayload: []byte(payload),
+			cert:       cert,
+			entryType:  p.EntryType,
+			recorder:   controller.GetEventRecorder(ctx),
+			logFields:  logs.TaskRunFields(tr),
+		})
+	}
+	ts.TlogQueue.track(jobs)
+	ts.TlogQueue.start(jobs)
+	return nil
+}
+
+// track counts jobs as queued before the TaskRun is marked as waiting for them, so that the
+// TaskRun is never seen waiting for uploads the queue doesn't know of.
+func (q *TlogQueue) track(jobs []*tlogJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range jobs {
+		q.queued[job.taskRun]++
+	}
+}
+
+// untrack stops counting jobs, once they're done or won't be queued after all.
+func (q *TlogQueue) untrack(jobs ...*tlogJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range jobs {
+		if q.queued[job.taskRun]--; q.queued[job.taskRun] <= 0 {
+			delete(q.queued, job.taskRun)
+		}
+	}
+}
+
+// start queues tracked jobs.
+func (q *TlogQueue) start(jobs []*tlogJob) {
+	for _, job := range jobs {
+		q.queue.Add(job)
+	}
+}
+
+func (q *TlogQueue) processNext(ctx context.Context) bool {
+	item, shutdown := q.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.queue.Done(item)
+	job := item.(*tlogJob)
+	logger := logs.For(logging.FromContext(ctx), logs.Transparency).With(job.logFields...)
+	if job.recorder != nil {
+		ctx = controller.WithEventRecorder(ctx, job.recorder)
+	}
+
+	err := q.upload(ctx, job)
+	if err != nil {
+		if attempts := q.queue.NumRequeues(item) + 1; attempts < tlogMaxAttempts {
+			logger.Warnf("Upload of %s to %s failed %d times, retrying: %v", job.upload.Key, job.upload.URL, attempts, err)
+			q.queue.AddRateLimited(item)
+			return true
+		}
+		logger.Errorf("Giving up on the upload of %s to %s: %v", job.upload.Key, job.upload.URL, err)
+		if rerr := q.giveUp(ctx, job, err); rerr != nil {
+			logger.Error(rerr)
+		}
+	}
+	q.queue.Forget(item)
+	q.untrack(job)
+	return true
+}
+
+// upload uploads the signature of a job, and records the entry on the TaskRun.
+func (q *TlogQueue) upload(ctx context.Context, job *tlogJob) error {
+	start := time.Now()
+	bundle, err := job.client.UploadTlog(ctx, job.signer, job.signature, job.rawPayload, job.cert, job.entryType)
+	metrics.RecordTransparencyLatency(ctx, time.Since(start))
+	if err != nil {
+		return errors.Wrapf(err, "uploading %s to %s", job.upload.Key, job.upload.URL)
+	}
+	entry, err := newTransparencyEntry(job.upload.URL, job.upload.Key, bundle)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{}
+	if job.primary {
+		annotations[ChainsTransparencyAnnotation] = fmt.Sprintf("%s/api/v1/log/entries?logIndex=%d", job.upload.URL, bundle.LogIndex)
+	}
+	_, err = q.record(ctx, job, annotations, &entry)
+	return err
+}
+
+// giveUp records that the upload of a job failed for good.
+func (q *TlogQueue) giveUp(ctx context.Context, job *tlogJob, uploadErr error) error {
+	tr, err := q.record(ctx, job, WithLastError(nil, uploadErr), nil)
+	if tr != nil {
+		recordWarning(ctx, tr, EventReasonTransparencyFailed, "Gave up uploading %s to transparency log %s after %d attempts: %v", job.upload.Key, job.upload.URL, tlogMaxAttempts, uploadErr)
+	}
+	return err
+}
+
+// record removes the upload of a job from the TaskRun's pending uploads, and adds its entry to
+// the recorded ones, if it landed. The TaskRun is patched at the resourceVersion the annotations
+// were read at, and read again when another upload or the controller changed it in the meantime.
+// It returns the TaskRun, or nil if it no longer exists.
+func (q *TlogQueue) record(ctx context.Context, job *tlogJob, annotations map[string]string, entry *TransparencyEntry) (*v1beta1.TaskRun, error) {
+	var tr *v1beta1.TaskRun
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		tr, err = q.ps.TektonV1beta1().TaskRuns(job.taskRun.Namespace).Get(ctx, job.taskRun.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			tr = nil
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "getting taskrun %s", job.taskRun)
+		}
+		return q.patchRecord(ctx, tr, job, annotations, entry)
+	})
+	if apierrors.IsConflict(err) {
+		return tr, errors.Wrapf(err, "recording transparency log entry of %s", job.upload.Key)
+	}
+	return tr, err
+}
+
+// patchRecord patches the annotations of record onto tr, at its resourceVersion.
+func (q *TlogQueue) patchRecord(ctx context.Context, tr *v1beta1.TaskRun, job *tlogJob, base map[string]string, entry *TransparencyEntry) error {
+	annotations := make(map[string]string, len(base))
+	for k, v := range base {
+		annotations[k] = v
+	}
+	if entry != nil {
+		entries, err := TransparencyEntries(tr)
+		if err != nil {
+			return err
+		}
+		if err := recordTransparencyEntries(annotations, append(entries, *entry)); err != nil {
+			return err
+		}
+	}
+	pending, err := PendingUploads(tr)
+	if err != nil {
+		return err
+	}
+	var remaining []PendingUpload
+	for _, p := range pending {
+		if p != job.upload {
+			remaining = append(remaining, p)
+		}
+	}
+	var removed []string
+	if len(remaining) == 0 {
+		removed = append(removed, TransparencyPendingAnnotation)
+	} else if err := recordPendingUploads(annotations, remaining); err != nil {
+		return err
+	}
+
+	patchBytes, err := patch.GetConditionalAnnotationsRemovalPatch(annotations, removed, tr.ResourceVersion)
+	if err != nil {
+		return err
+	}
+	if _, err := q.ps.TektonV1beta1().TaskRuns(tr.Namespace).Patch(ctx, tr.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return err
+		}
+		return errors.Wrapf(err, "recording transparency log entry of %s", job.upload.Key)
+	}
+	return nil
+}
+
+// recordPendingUploads sets TransparencyPendingAnnotation in annotations.
+func recordPendingUploads(annotations map[string]string, pending []PendingUpload) error {
+	b, err := json.Marshal(pending)
+	if err != nil {
+		return errors.Wrap(err, "encoding pending transparency log uploads")
+	}
+	annotations[TransparencyPendingAnnotation] = string(b)
+	return nil
+}
+
+// PendingUploads returns the queued uploads recorded on a TaskRun.
+func PendingUploads(tr *v1beta1.TaskRun) ([]PendingUpload, error) {
+	value, ok := tr.Annotations[TransparencyPendingAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var pending []PendingUpload
+	if err := json.Unmarshal([]byte(value), &pending); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", TransparencyPendingAnnotation)
+	}
+	return pending, nil
+}
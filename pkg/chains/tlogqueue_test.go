@@ -0,0 +1,371 @@
+/*
+Copyright 2020 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client/fake"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ktesting "k8s.io/client-go/testing"
+	logtesting "knative.dev/pkg/logging/testing"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+func TestTaskRunSigner_AsyncTransparency(t *testing.T) {
+	const public, internal = "https://rekor.sigstore.dev", "https://rekor.example.com"
+	backends := []*mockBackend{{backendType: "mock"}}
+	cleanup := setupMocks(backends, nil)
+	defer cleanup()
+	logs := map[string]*mockRekor{public: {}, internal: {}}
+	getRekor = func(_ config.TransparencyConfig, url, _ string, _ *zap.SugaredLogger) (rekorClient, error) {
+		return logs[url], nil
+	}
+
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ps := fakepipelineclient.Get(ctx)
+	ctx = config.ToContext(ctx, &config.Config{
+		Artifacts: config.ArtifactConfigs{
+			TaskRuns: config.Artifact{
+				Format:         "tekton",
+				StorageBackend: "mock",
+				Signer:         "x509",
+			},
+		},
+		Transparency: config.TransparencyConfig{
+			Enabled:        true,
+			Async:          true,
+			URL:            public,
+			AdditionalURLs: []string{internal},
+			EntryTypes:     config.TransparencyEntryTypes{Tekton: config.EntryTypeRekord},
+		},
+	})
+	q := NewTlogQueue(ps)
+	ts := &TaskRunSigner{
+		Pipelineclientset: ps,
+		SecretPath:        "./signing/x509/testdata/",
+		TlogQueue:         q,
+	}
+
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.SignTaskRun(ctx, tr); err != nil {
+		t.Fatalf("TaskRunSigner.SignTaskRun() error = %v", err)
+	}
+	if len(logs[public].entries) != 0 || len(logs[internal].entries) != 0 {
+		t.Error("expected no entries to be uploaded while signing")
+	}
+	if backends[0].storedPayload == nil {
+		t.Error("expected the payload to be stored while signing")
+	}
+
+	got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Reconciled(got) {
+		t.Error("expected the taskrun to be signed before its entries are uploaded")
+	}
+	pending, err := PendingUploads(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending uploads, got %v", pending)
+	}
+	if q.Lost(got) {
+		t.Error("expected the pending uploads to be queued")
+	}
+
+	for range pending {
+		q.processNext(ctx)
+	}
+
+	got, err = ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Annotations[TransparencyPendingAnnotation]; ok {
+		t.Errorf("expected %s to be removed, got %s", TransparencyPendingAnnotation, got.Annotations[TransparencyPendingAnnotation])
+	}
+	if got.Annotations[ChainsTransparencyAnnotation] == "" {
+		t.Errorf("expected %s to be set", ChainsTransparencyAnnotation)
+	}
+	entries, err := TransparencyEntries(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, e := range entries {
+		urls = append(urls, e.URL)
+	}
+	if d := cmp.Diff([]string{public, internal}, urls); d != "" {
+		t.Errorf("recorded transparency log entries diff (-want +got):\n%s", d)
+	}
+}
+
+func TestTaskRunSigner_ResumeUploads(t *testing.T) {
+	const url = "https://rekor.sigstore.dev"
+	backends := []*mockBackend{{backendType: "mock"}}
+	cleanup := setupMocks(backends, nil)
+	defer cleanup()
+	rekor := &mockRekor{}
+	getRekor = func(config.TransparencyConfig, string, string, *zap.SugaredLogger) (rekorClient, error) {
+		return rekor, nil
+	}
+
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ps := fakepipelineclient.Get(ctx)
+	ctx = config.ToContext(ctx, &config.Config{
+		Artifacts: config.ArtifactConfigs{
+			TaskRuns: config.Artifact{Format: "tekton", StorageBackend: "mock", Signer: "x509"},
+		},
+		Transparency: config.TransparencyConfig{
+			Enabled:    true,
+			Async:      true,
+			URL:        url,
+			EntryTypes: config.TransparencyEntryTypes{Tekton: config.EntryTypeRekord},
+		},
+	})
+	ts := &TaskRunSigner{
+		Pipelineclientset: ps,
+		SecretPath:        "./signing/x509/testdata/",
+		TlogQueue:         NewTlogQueue(ps),
+	}
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.SignTaskRun(ctx, tr); err != nil {
+		t.Fatalf("TaskRunSigner.SignTaskRun() error = %v", err)
+	}
+
+	// The controller restarts with an empty queue.
+	ts.TlogQueue = NewTlogQueue(ps)
+	got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ts.TlogQueue.Lost(got) {
+		t.Fatal("expected the uploads to be lost")
+	}
+	if err := ts.ResumeUploads(ctx, got); err != nil {
+		t.Fatalf("TaskRunSigner.ResumeUploads() error = %v", err)
+	}
+	if ts.TlogQueue.Lost(got) {
+		t.Error("expected the uploads to be queued again")
+	}
+	ts.TlogQueue.processNext(ctx)
+
+	if len(rekor.entries) != 1 || string(rekor.entries[0]) != backends[0].storedSignature {
+		t.Errorf("uploaded %q, want the stored signature %q", rekor.entries, backends[0].storedSignature)
+	}
+	got, err = ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Annotations[TransparencyPendingAnnotation]; ok {
+		t.Errorf("expected %s to be removed", TransparencyPendingAnnotation)
+	}
+	if got.Annotations[ChainsTransparencyAnnotation] == "" {
+		t.Errorf("expected %s to be set", ChainsTransparencyAnnotation)
+	}
+
+	// Payloads only pushed to registries can't be read back.
+	pushed := tr.DeepCopy()
+	pushed.Annotations = map[string]string{}
+	if err := recordPendingUploads(pushed.Annotations, []PendingUpload{{Key: "taskrun-uid", URL: url, Signer: "x509"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.ResumeUploads(ctx, pushed); !errors.Is(err, ErrUploadsLost) {
+		t.Errorf("TaskRunSigner.ResumeUploads() error = %v, want %v", err, ErrUploadsLost)
+	}
+}
+
+func TestTlogQueue_Retry(t *testing.T) {
+	oldMaxAttempts := tlogMaxAttempts
+	defer func() { tlogMaxAttempts = oldMaxAttempts }()
+
+	tests := []struct {
+		name        string
+		maxAttempts int
+		wantRetry   bool
+	}{
+		{name: "retried", maxAttempts: 2, wantRetry: true},
+		{name: "given up on", maxAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlogMaxAttempts = tt.maxAttempts
+			ctx, _ := rtesting.SetupFakeContext(t)
+			ps := fakepipelineclient.Get(ctx)
+
+			upload := PendingUpload{Key: "taskrun-uid", URL: "https://rekor.sigstore.dev"}
+			tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", Annotations: map[string]string{}}}
+			if err := recordPendingUploads(tr.Annotations, []PendingUpload{upload}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			signer, err := x509.NewSigner("./signing/x509/testdata/", config.Config{}, logtesting.TestLogger(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := NewTlogQueue(ps)
+			job := &tlogJob{
+				taskRun: types.NamespacedName{Namespace: tr.Namespace, Name: tr.Name},
+				upload:  upload,
+				primary: true,
+				client:  &mockRekor{err: errors.New("unavailable")},
+				signer:  signer,
+			}
+			jobs := []*tlogJob{job}
+			q.track(jobs)
+			q.start(jobs)
+			q.processNext(ctx)
+
+			got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, pending := got.Annotations[TransparencyPendingAnnotation]
+			if pending != tt.wantRetry {
+				t.Errorf("%s set = %v, want %v", TransparencyPendingAnnotation, pending, tt.wantRetry)
+			}
+			if requeues := q.queue.NumRequeues(job); (requeues == 1) != tt.wantRetry {
+				t.Errorf("upload requeued %d times, want retried = %v", requeues, tt.wantRetry)
+			}
+			if _, failed := got.Annotations[LastErrorAnnotation]; failed == tt.wantRetry {
+				t.Errorf("%s set = %v, want %v", LastErrorAnnotation, failed, !tt.wantRetry)
+			}
+			if _, ok := got.Annotations[ChainsTransparencyAnnotation]; ok {
+				t.Errorf("expected no %s", ChainsTransparencyAnnotation)
+			}
+		})
+	}
+}
+
+func TestTlogQueue_Conflict(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ps := fakepipelineclient.Get(ctx)
+
+	mine := PendingUpload{Key: "taskrun-uid", URL: "https://rekor.sigstore.dev"}
+	other := PendingUpload{Key: "taskrun-uid", URL: "https://rekor.example.com"}
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", ResourceVersion: "1", Annotations: map[string]string{}}}
+	if err := recordPendingUploads(tr.Annotations, []PendingUpload{mine, other}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other upload is recorded between the first read of the TaskRun and its patch.
+	var patches []string
+	ps.PrependReactor("patch", "taskruns", func(action ktesting.Action) (bool, runtime.Object, error) {
+		patches = append(patches, string(action.(ktesting.PatchAction).GetPatch()))
+		if len(patches) > 1 {
+			return false, nil, nil
+		}
+		changed := tr.DeepCopy()
+		changed.ResourceVersion = "2"
+		if err := recordPendingUploads(changed.Annotations, []PendingUpload{mine}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ps.Tracker().Update(v1beta1.SchemeGroupVersion.WithResource("taskruns"), changed, changed.Namespace); err != nil {
+			t.Fatal(err)
+		}
+		return true, nil, apierrors.NewConflict(v1beta1.Resource("taskruns"), tr.Name, errors.New("the object has been modified"))
+	})
+
+	signer, err := x509.NewSigner("./signing/x509/testdata/", config.Config{}, logtesting.TestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewTlogQueue(ps)
+	job := &tlogJob{
+		taskRun: types.NamespacedName{Namespace: tr.Namespace, Name: tr.Name},
+		upload:  mine,
+		primary: true,
+		client:  &mockRekor{},
+		signer:  signer,
+	}
+	if err := q.upload(ctx, job); err != nil {
+		t.Fatalf("TlogQueue.upload() error = %v", err)
+	}
+
+	if len(patches) != 2 {
+		t.Fatalf("TaskRun patched %d times, want 2", len(patches))
+	}
+	for i, rv := range []string{"1", "2"} {
+		if want := fmt.Sprintf(`"resourceVersion":%q`, rv); !strings.Contains(patches[i], want) {
+			t.Errorf("patch %d = %s, want it at resourceVersion %s", i, patches[i], rv)
+		}
+	}
+	got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Annotations[TransparencyPendingAnnotation]; ok {
+		t.Errorf("expected %s to be removed, got %s", TransparencyPendingAnnotation, got.Annotations[TransparencyPendingAnnotation])
+	}
+	if got.Annotations[ChainsTransparencyAnnotation] == "" {
+		t.Errorf("expected %s to be set", ChainsTransparencyAnnotation)
+	}
+}
+
+func TestTlogQueue_Lost(t *testing.T) {
+	pending := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar", Annotations: map[string]string{}}}
+	if err := recordPendingUploads(pending.Annotations, []PendingUpload{{Key: "taskrun-uid", URL: "https://rekor.sigstore.dev"}}); err != nil {
+		t.Fatal(err)
+	}
+	done := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}
+
+	ctx, _ := rtesting.SetupFakeContext(t)
+	q := NewTlogQueue(fakepipelineclient.Get(ctx))
+	if !q.Lost(pending) {
+		t.Error("expected uploads the queue doesn't know of to be lost")
+	}
+	if q.Lost(done) {
+		t.Error("expected a taskrun without pending uploads not to be lost")
+	}
+	job := &tlogJob{taskRun: types.NamespacedName{Namespace: "bar", Name: "foo"}}
+	q.track([]*tlogJob{job})
+	if q.Lost(pending) {
+		t.Error("expected queued uploads not to be lost")
+	}
+	q.untrack(job)
+	if !q.Lost(pending) {
+		t.Error("expected uploads to be lost once the queue is done with them")
+	}
+
+	var nilQueue *TlogQueue
+	if nilQueue.Lost(pending) {
+		t.Error("expected a TaskRunSigner without a queue never to lose uploads")
+	}
+}
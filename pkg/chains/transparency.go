@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+)
+
+// TransparencyEntriesAnnotation records where the transparency log entries of the payloads
+// signed for a TaskRun are, as a JSON list of TransparencyEntry.
+const TransparencyEntriesAnnotation = "chains.tekton.dev/transparency-entries"
+
+// transparencyLog is a log the signatures of payloads are uploaded to.
+type transparencyLog struct {
+	url    string
+	client rekorClient
+}
+
+// tlogUpload is an entry uploaded to a transparency log.
+type tlogUpload struct {
+	url    string
+	bundle *config.TlogBundle
+}
+
+// TransparencyEntry locates the transparency log entry of a signed payload.
+type TransparencyEntry struct {
+	// Key is the storage key of the payload.
+	Key string `json:"key"`
+	// URL is the transparency log the entry is in.
+	URL string `json:"url"`
+	// UUID identifies the entry in the log. It's the leaf hash of the entry.
+	UUID string `json:"uuid"`
+	// LogIndex is the index of the entry in the log.
+	LogIndex int64 `json:"logIndex"`
+	// IntegratedTime is when the entry was added to the log, in seconds since the epoch.
+	IntegratedTime int64 `json:"integratedTime"`
+}
+
+// EntryURL is where the entry can be retrieved from the log.
+func (e TransparencyEntry) EntryURL() string {
+	return fmt.Sprintf("%s/api/v1/log/entries/%s", strings.TrimSuffix(e.URL, "/"), e.UUID)
+}
+
+// newTransparencyEntry records where an entry uploaded for the payload stored under key is.
+func newTransparencyEntry(url, key string, bundle *config.TlogBundle) (TransparencyEntry, error) {
+	leaf, err := leafHash(bundle.Body)
+	if err != nil {
+		return TransparencyEntry{}, err
+	}
+	return TransparencyEntry{
+		Key:            key,
+		URL:            url,
+		UUID:           hex.EncodeToString(leaf),
+		LogIndex:       bundle.LogIndex,
+		IntegratedTime: bundle.IntegratedTime,
+	}, nil
+}
+
+// recordTransparencyEntries sets TransparencyEntriesAnnotation in annotations, if there are entries.
+func recordTransparencyEntries(annotations map[string]string, entries []TransparencyEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return errors.Wrap(err, "encoding transparency log entries")
+	}
+	annotations[TransparencyEntriesAnnotation] = string(b)
+	return nil
+}
+
+// TransparencyEntries returns the transparency log entries recorded on a TaskRun.
+func TransparencyEntries(tr *v1beta1.TaskRun) ([]TransparencyEntry, error) {
+	value, ok := tr.Annotations[TransparencyEntriesAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var entries []TransparencyEntry
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", TransparencyEntriesAnnotation)
+	}
+	return entries, nil
+}
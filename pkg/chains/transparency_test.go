@@ -0,0 +1,95 @@
+/*
+Copyright 2020 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/trillian/merkle/rfc6962"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewTransparencyEntry(t *testing.T) {
+	body := []byte(`{"kind":"rekord"}`)
+	bundle := &config.TlogBundle{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		LogIndex:       7,
+		IntegratedTime: 1637000000,
+	}
+	got, err := newTransparencyEntry("https://rekor.example.com", "taskrun-1234", bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TransparencyEntry{
+		Key:            "taskrun-1234",
+		URL:            "https://rekor.example.com",
+		UUID:           hex.EncodeToString(rfc6962.DefaultHasher.HashLeaf(body)),
+		LogIndex:       7,
+		IntegratedTime: 1637000000,
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("newTransparencyEntry() diff (-want +got):\n%s", d)
+	}
+
+	if _, err := newTransparencyEntry("https://rekor.example.com", "taskrun-1234", &config.TlogBundle{Body: "not base64!"}); err == nil {
+		t.Error("expected an error for an entry that can't be decoded")
+	}
+}
+
+func TestTransparencyEntryURL(t *testing.T) {
+	for _, url := range []string{"https://rekor.example.com", "https://rekor.example.com/"} {
+		e := TransparencyEntry{URL: url, UUID: "abcd"}
+		if got, want := e.EntryURL(), "https://rekor.example.com/api/v1/log/entries/abcd"; got != want {
+			t.Errorf("EntryURL() = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestTransparencyEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        []TransparencyEntry
+		wantErr     bool
+	}{{
+		name: "none",
+	}, {
+		name: "entries",
+		annotations: map[string]string{
+			TransparencyEntriesAnnotation: `[{"key":"taskrun-1234","url":"https://rekor.example.com","uuid":"abcd","logIndex":1,"integratedTime":2}]`,
+		},
+		want: []TransparencyEntry{{Key: "taskrun-1234", URL: "https://rekor.example.com", UUID: "abcd", LogIndex: 1, IntegratedTime: 2}},
+	}, {
+		name:        "invalid",
+		annotations: map[string]string{TransparencyEntriesAnnotation: "{"},
+		wantErr:     true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+			got, err := TransparencyEntries(tr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TransparencyEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if d := cmp.Diff(tt.want, got); d != "" {
+				t.Errorf("TransparencyEntries() diff (-want +got):\n%s", d)
+			}
+		})
+	}
+}
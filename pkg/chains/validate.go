@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/chains/signing"
+	"github.com/tektoncd/chains/pkg/chains/signing/kms"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/config"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ValidateConfig returns a config.Validator that, beyond the static checks in
+// config.Validate, loads every signer the configuration uses: x509 keys are read
+// from secretPath and KMS keys are fetched from the KMS.
+func ValidateConfig(secretPath string, logger *zap.SugaredLogger) config.Validator {
+	return func(cfg *config.Config) error {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if cfg.DryRun.Enabled {
+			return nil
+		}
+		for _, s := range sets.NewString(cfg.Artifacts.TaskRuns.Signer, cfg.Artifacts.OCI.Signer).List() {
+			if err := CheckSigner(secretPath, *cfg, s, logger); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// CheckSigner loads the signer with the given name, x509, kms or a signer profile: x509 keys
+// are read from secretPath and KMS keys are fetched from the KMS.
+func CheckSigner(secretPath string, cfg config.Config, name string, logger *zap.SugaredLogger) error {
+	profile, cfg := cfg.ResolveSigner(name)
+	switch profile.Type {
+	case signing.TypeX509:
+		// Fulcio certificates are only requested when signing.
+		if cfg.Signers.X509.FulcioEnabled {
+			return nil
+		}
+		if _, err := x509.NewSignerWithPrefix(secretPath, profile.KeyPrefix(), cfg, logger); err != nil {
+			return errors.Wrapf(err, "loading %s signer", name)
+		}
+	case signing.TypeKMS:
+		signer, err := kms.NewSigner(cfg.Signers.KMS, logger)
+		if err != nil {
+			return errors.Wrapf(err, "loading %s signer %s", name, cfg.Signers.KMS.KMSRef)
+		}
+		if _, err := signer.PublicKey(); err != nil {
+			return errors.Wrapf(err, "reaching kms key %s", cfg.Signers.KMS.KMSRef)
+		}
+	}
+	return nil
+}
@@ -15,9 +15,14 @@ package chains
 
 import (
 	"context"
-	"strings"
 
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature"
 	"github.com/tektoncd/chains/pkg/artifacts"
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	"github.com/tektoncd/chains/pkg/chains/signing"
+	"github.com/tektoncd/chains/pkg/chains/storage"
+	"github.com/tektoncd/chains/pkg/chains/storage/oci"
 	"github.com/tektoncd/chains/pkg/config"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
 	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
@@ -33,50 +38,263 @@ type TaskRunVerifier struct {
 	KubeClient        kubernetes.Interface
 	Pipelineclientset versioned.Interface
 	SecretPath        string
+	// PublicKeys, when set, verify the signatures made by each type of signer
+	// instead of the configured signers. The payloads of a type of signer whose verifier is
+	// nil are verified with the certificate they were stored with.
+	PublicKeys map[string]signature.Verifier
+	// CertificateVerifier returns what a payload stored with a certificate is verified with,
+	// once it has checked the certificate, e.g. that Fulcio issued it.
+	CertificateVerifier func(cert, chain []byte) (signature.Verifier, error)
+}
+
+// StoredPayload is a payload and its signature, as stored for a TaskRun.
+type StoredPayload struct {
+	// Type is the type of artifact the payload describes.
+	Type string
+	// Key is the key the payload is stored under.
+	Key string
+	// Format is the payload format.
+	Format formats.PayloadType
+	// Signer is the type of signer that signed the payload.
+	Signer string
+	// Payload is the payload as it was signed, or the payload of the envelope for wrapped formats.
+	Payload []byte
+	// Signature is the stored signature. For wrapped formats this is the envelope.
+	Signature []byte
+	// Cert and Chain are the PEM certificate the payload was signed with and its chain, for
+	// keyless signatures whose backend stores them.
+	Cert  string
+	Chain string
+	// Countersignature is the countersignature of the envelope, when a countersigner is
+	// configured.
+	Countersignature *StoredCountersignature
+}
+
+// StoredCountersignature is the countersignature of the envelope of a payload, as stored for a
+// TaskRun.
+type StoredCountersignature struct {
+	// Signer is the signer that countersigned the envelope.
+	Signer string
+	// Signature is the DSSE envelope whose payload is the countersigned envelope.
+	Signature []byte
+	Cert      string
+	Chain     string
+}
+
+// VerifiedPayload is a payload stored for a TaskRun whose signature has been verified.
+type VerifiedPayload struct {
+	// Type is the type of artifact the payload describes.
+	Type string
+	// Key is the key the payload is stored under.
+	Key string
+	// Format is the payload format.
+	Format formats.PayloadType
+	// Signer is the type of signer that signed the payload.
+	Signer string
+	// Payload is the signed content. For wrapped formats this is the payload of the envelope.
+	Payload []byte
+	// Signature is the verified signature. For wrapped formats this is the envelope.
+	Signature []byte
+	// Cert is the PEM certificate the payload was signed with, if it was stored with one.
+	Cert string
+	// Countersigner is the signer whose countersignature of the envelope was verified, if any.
+	Countersigner string
 }
 
 func (tv *TaskRunVerifier) VerifyTaskRun(ctx context.Context, tr *v1beta1.TaskRun) error {
-	// Get all the things we might need (storage backends, signers and formatters)
+	_, err := tv.VerifiedPayloads(ctx, tr)
+	return err
+}
+
+// VerifiedPayloads retrieves every payload stored for the TaskRun from the configured backends
+// and verifies its signature. Signatures stored in OCI registries are skipped, since they are
+// verified against the image instead.
+func (tv *TaskRunVerifier) VerifiedPayloads(ctx context.Context, tr *v1beta1.TaskRun) ([]VerifiedPayload, error) {
 	cfg := *config.FromContext(ctx)
 	logger := logging.FromContext(ctx)
 	logger.Infof("Verifying signature for TaskRun %s/%s", tr.Namespace, tr.Name)
 
-	// TODO: Hook this up to config.
-	enabledSignableTypes := []artifacts.Signable{
-		&artifacts.TaskRunArtifact{Logger: logger},
-		&artifacts.OCIArtifact{Logger: logger},
+	verifiers, countersigners := tv.PublicKeys, tv.PublicKeys
+	if verifiers == nil {
+		keys, err := signingKeys(ctx, tv.KubeClient, tv.SecretPath, cfg, tr)
+		if err != nil {
+			return nil, err
+		}
+		verifiers = map[string]signature.Verifier{}
+		for signerType, signer := range allSigners(keys, cfg, nil, logger) {
+			verifiers[signerType] = signer
+		}
+		// Countersignatures are never verified with the keys of the TaskRun's namespace.
+		countersigners = map[string]signature.Verifier{}
+		if name := cfg.Signers.Countersigner; name != "" {
+			cs, err := newCountersigner(tv.SecretPath, cfg, nil, logger)
+			if err != nil {
+				logger.Warnf("error configuring countersigner %s: %s", name, err)
+			} else {
+				countersigners[name] = cs
+			}
+		}
 	}
-
-	// Storage
-	allBackends, err := getBackends(tv.Pipelineclientset, tv.KubeClient, logger, tr, cfg)
+	stored, err := RetrievePayloads(ctx, tv.Pipelineclientset, tv.KubeClient, tr)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	signers := allSigners(tv.SecretPath, cfg, logger)
-
-	for _, signableType := range enabledSignableTypes {
 
-		// Verify the signature.
-		signerType := signableType.Signer(cfg)
-		signer, ok := signers[signerType]
-		if !ok {
-			logger.Warnf("No signer %s configured for %s", signerType, signableType.Type())
+	verified := []VerifiedPayload{}
+	for _, p := range stored {
+		if _, ok := verifiers[p.Signer]; !ok {
+			logger.Warnf("No signer %s configured for %s", p.Signer, p.Type)
 			continue
 		}
-
-		backend := allBackends[signableType.StorageBackend(cfg)]
-		signature, err := backend.RetrieveSignature(config.StorageOpts{})
+		verifier, err := tv.verifier(verifiers, p.Signer, p.Key, p.Cert, p.Chain)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		payload, err := backend.RetrievePayload(config.StorageOpts{})
+		signed, err := signing.Verify(verifier, p.Signature, p.Payload)
 		if err != nil {
-			return err
+			return nil, errors.Wrapf(err, "verifying %s", p.Key)
+		}
+		vp := VerifiedPayload{
+			Type:      p.Type,
+			Key:       p.Key,
+			Format:    p.Format,
+			Signer:    p.Signer,
+			Payload:   signed,
+			Signature: p.Signature,
+			Cert:      p.Cert,
 		}
-		if err := signer.VerifySignature(strings.NewReader(signature), strings.NewReader(payload)); err != nil {
-			return err
+		if c := p.Countersignature; c != nil {
+			key := p.Key + CountersignatureKeySuffix
+			verifier, err := tv.verifier(countersigners, c.Signer, key, c.Cert, c.Chain)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := signing.Verify(verifier, c.Signature, p.Signature); err != nil {
+				return nil, errors.Wrapf(err, "verifying %s", key)
+			}
+			vp.Countersigner = c.Signer
 		}
+		verified = append(verified, vp)
+	}
+	return verified, nil
+}
+
+// verifier returns what the signatures of signer are verified with: its verifier, or the
+// certificate stored with the signature under key when it's nil.
+func (tv *TaskRunVerifier) verifier(verifiers map[string]signature.Verifier, signer, key, cert, chain string) (signature.Verifier, error) {
+	verifier, ok := verifiers[signer]
+	if !ok {
+		return nil, errors.Errorf("no signer %s configured to verify %s with", signer, key)
+	}
+	if verifier != nil {
+		return verifier, nil
+	}
+	if cert == "" || tv.CertificateVerifier == nil {
+		return nil, errors.Errorf("no certificate to verify %s with is stored", key)
+	}
+	verifier, err := tv.CertificateVerifier([]byte(cert), []byte(chain))
+	if err != nil {
+		return nil, errors.Wrapf(err, "verifying the certificate of %s", key)
 	}
+	return verifier, nil
+}
 
-	return nil
+// RetrievePayloads retrieves every payload stored for the TaskRun from the configured backends.
+// Signatures stored in OCI registries are skipped, since they are stored next to the images.
+func RetrievePayloads(ctx context.Context, ps versioned.Interface, kc kubernetes.Interface, tr *v1beta1.TaskRun) ([]StoredPayload, error) {
+	logger := logging.FromContext(ctx)
+	// TODO: Hook this up to config.
+	return retrievePayloads(ctx, ps, kc, tr, &artifacts.TaskRunArtifact{Logger: logger}, &artifacts.OCIArtifact{Logger: logger})
+}
+
+// RetrieveTaskRunPayload retrieves the payload stored for the TaskRun itself, leaving out the
+// payloads of the images it built.
+func RetrieveTaskRunPayload(ctx context.Context, ps versioned.Interface, kc kubernetes.Interface, tr *v1beta1.TaskRun) (*StoredPayload, error) {
+	stored, err := retrievePayloads(ctx, ps, kc, tr, &artifacts.TaskRunArtifact{Logger: logging.FromContext(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 {
+		return nil, errors.Errorf("no payload is stored for TaskRun %s/%s", tr.Namespace, tr.Name)
+	}
+	return &stored[0], nil
+}
+
+func retrievePayloads(ctx context.Context, ps versioned.Interface, kc kubernetes.Interface, tr *v1beta1.TaskRun, enabledSignableTypes ...artifacts.Signable) ([]StoredPayload, error) {
+	cfg := *config.FromContext(ctx)
+	logger := logging.FromContext(ctx)
+
+	// Storage
+	allBackends, err := getBackends(ps, kc, logger, tr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := []StoredPayload{}
+	for _, signableType := range enabledSignableTypes {
+		// The payloads are read from the first of their backends that isn't a registry.
+		var backend storage.Backend
+		inOCI := false
+		for _, backendType := range cfg.Storage.StorageBackends(string(signableType.PayloadFormat(cfg)), signableType.StorageBackend(cfg)) {
+			b, ok := allBackends[backendType]
+			if ok && b.Type() == oci.StorageBackendOCI {
+				inOCI = true
+			} else if ok {
+				backend = b
+				break
+			}
+		}
+		if backend == nil {
+			if inOCI {
+				logger.Infof("%s signatures for TaskRun %s/%s are stored in OCI registries", signableType.Type(), tr.Namespace, tr.Name)
+			}
+			continue
+		}
+
+		for _, obj := range signableType.ExtractObjects(tr) {
+			opts := config.StorageOpts{Key: VersionedKey(tr, signableType.Key(obj))}
+			sig, err := backend.RetrieveSignature(opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "retrieving signature %s", opts.Key)
+			}
+			if sig == "" {
+				return nil, errors.Errorf("no signature stored for %s in %s", opts.Key, backend.Type())
+			}
+			payload, err := backend.RetrievePayload(opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "retrieving payload %s", opts.Key)
+			}
+			cert, chain, err := storage.RetrieveCertificates(backend, opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "retrieving certificate %s", opts.Key)
+			}
+			p := StoredPayload{
+				Type:      signableType.Type(),
+				Key:       opts.Key,
+				Format:    signableType.PayloadFormat(cfg),
+				Signer:    signableType.Signer(cfg),
+				Payload:   []byte(payload),
+				Signature: []byte(sig),
+				Cert:      cert,
+				Chain:     chain,
+			}
+			// With a countersigner, no envelope is stored without its countersignature.
+			if name := cfg.Signers.Countersigner; name != "" && signing.IsEnvelope(p.Signature) {
+				csOpts := config.StorageOpts{Key: opts.Key + CountersignatureKeySuffix}
+				cs, err := backend.RetrieveSignature(csOpts)
+				if err != nil {
+					return nil, errors.Wrapf(err, "retrieving countersignature %s", csOpts.Key)
+				}
+				if cs == "" {
+					return nil, errors.Errorf("no countersignature stored for %s in %s", opts.Key, backend.Type())
+				}
+				p.Countersignature = &StoredCountersignature{Signer: name, Signature: []byte(cs)}
+				if p.Countersignature.Cert, p.Countersignature.Chain, err = storage.RetrieveCertificates(backend, csOpts); err != nil {
+					return nil, errors.Wrapf(err, "retrieving certificate %s", csOpts.Key)
+				}
+			}
+			stored = append(stored, p)
+		}
+	}
+	return stored, nil
 }
@@ -0,0 +1,228 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chains
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/chains/storage/tekton"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"knative.dev/pkg/controller"
+	logtesting "knative.dev/pkg/logging/testing"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+func TestTaskRunVerifier_VerifiedPayloads(t *testing.T) {
+	for _, format := range []string{"tekton", "in-toto"} {
+		t.Run(format, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			ps := fakepipelineclient.Get(ctx)
+			ctx = controller.WithEventRecorder(ctx, record.NewFakeRecorder(10))
+			ctx = config.ToContext(ctx, &config.Config{
+				Artifacts: config.ArtifactConfigs{
+					TaskRuns: config.Artifact{
+						Format:         format,
+						StorageBackend: "tekton",
+						Signer:         "x509",
+					},
+				},
+			})
+
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+					UID:  types.UID("1234"),
+				},
+			}
+			if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			ts := &TaskRunSigner{
+				Pipelineclientset: ps,
+				SecretPath:        "./signing/x509/testdata/",
+			}
+			if err := ts.SignTaskRun(ctx, tr); err != nil {
+				t.Fatal(err)
+			}
+
+			tv := &TaskRunVerifier{
+				Pipelineclientset: ps,
+				SecretPath:        "./signing/x509/testdata/",
+			}
+			got, err := tv.VerifiedPayloads(ctx, tr)
+			if err != nil {
+				t.Fatalf("VerifiedPayloads() error = %v", err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("VerifiedPayloads() returned %d payloads, want 1", len(got))
+			}
+			if got[0].Key != "taskrun-1234" {
+				t.Errorf("key = %s, want taskrun-1234", got[0].Key)
+			}
+			if !json.Valid(got[0].Payload) {
+				t.Errorf("payload is not JSON: %s", got[0].Payload)
+			}
+
+			// Tamper with the stored payload.
+			signed, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			signed.Annotations[fmt.Sprintf(tekton.PayloadAnnotationFormat, "taskrun-1234")] = base64.StdEncoding.EncodeToString([]byte(`{"forged":true}`))
+			if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Update(ctx, signed, metav1.UpdateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := tv.VerifyTaskRun(ctx, tr); err == nil {
+				t.Error("expected a tampered payload to fail verification")
+			}
+		})
+	}
+}
+
+func TestTaskRunVerifier_StoredCertificate(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ps := fakepipelineclient.Get(ctx)
+	ctx = controller.WithEventRecorder(ctx, record.NewFakeRecorder(10))
+	cfg := &config.Config{
+		Artifacts: config.ArtifactConfigs{
+			TaskRuns: config.Artifact{
+				Format:         "tekton",
+				StorageBackend: "tekton",
+				Signer:         "x509",
+			},
+		},
+	}
+	ctx = config.ToContext(ctx, cfg)
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "foo",
+			UID:  types.UID("1234"),
+		},
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ts := &TaskRunSigner{
+		Pipelineclientset: ps,
+		SecretPath:        "./signing/x509/testdata/",
+	}
+	if err := ts.SignTaskRun(ctx, tr); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a stored certificate there's nothing to verify the payload with.
+	tv := &TaskRunVerifier{
+		Pipelineclientset: ps,
+		PublicKeys:        map[string]signature.Verifier{"x509": nil},
+		CertificateVerifier: func(cert, chain []byte) (signature.Verifier, error) {
+			if string(cert) != "cert" || string(chain) != "chain" {
+				return nil, fmt.Errorf("unexpected certificate %q and chain %q", cert, chain)
+			}
+			return allSigners(x509.KeysIn("./signing/x509/testdata/"), *cfg, nil, logtesting.TestLogger(t))["x509"], nil
+		},
+	}
+	if _, err := tv.VerifiedPayloads(ctx, tr); err == nil {
+		t.Error("expected an error verifying a payload stored without a certificate")
+	}
+
+	signed, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed.Annotations[fmt.Sprintf(tekton.CertAnnotationsFormat, "taskrun-1234")] = base64.StdEncoding.EncodeToString([]byte("cert"))
+	signed.Annotations[fmt.Sprintf(tekton.ChainAnnotationFormat, "taskrun-1234")] = base64.StdEncoding.EncodeToString([]byte("chain"))
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Update(ctx, signed, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tv.VerifiedPayloads(ctx, tr)
+	if err != nil {
+		t.Fatalf("VerifiedPayloads() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Cert != "cert" {
+		t.Errorf("VerifiedPayloads() = %+v, want one payload verified with the stored certificate", got)
+	}
+}
+
+func TestTaskRunVerifier_Countersignature(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ps := fakepipelineclient.Get(ctx)
+	ctx = controller.WithEventRecorder(ctx, record.NewFakeRecorder(10))
+	cfg := &config.Config{
+		Artifacts: config.ArtifactConfigs{
+			TaskRuns: config.Artifact{
+				Format:         "in-toto",
+				StorageBackend: "tekton",
+				Signer:         "x509",
+			},
+		},
+		Signers: config.SignerConfigs{Countersigner: "x509"},
+	}
+	ctx = config.ToContext(ctx, cfg)
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "foo",
+			UID:  types.UID("1234"),
+		},
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ts := &TaskRunSigner{
+		Pipelineclientset: ps,
+		SecretPath:        "./signing/x509/testdata/",
+	}
+	if err := ts.SignTaskRun(ctx, tr); err != nil {
+		t.Fatal(err)
+	}
+
+	tv := &TaskRunVerifier{
+		Pipelineclientset: ps,
+		PublicKeys: map[string]signature.Verifier{
+			"x509": allSigners(x509.KeysIn("./signing/x509/testdata/"), *cfg, nil, logtesting.TestLogger(t))["x509"],
+		},
+	}
+	got, err := tv.VerifiedPayloads(ctx, tr)
+	if err != nil {
+		t.Fatalf("VerifiedPayloads() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Countersigner != "x509" {
+		t.Errorf("VerifiedPayloads() = %+v, want one payload countersigned by x509", got)
+	}
+
+	// A countersignature over another envelope doesn't verify.
+	signed, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := fmt.Sprintf(tekton.SignatureAnnotationFormat, "taskrun-1234"+CountersignatureKeySuffix)
+	signed.Annotations[key] = signed.Annotations[fmt.Sprintf(tekton.SignatureAnnotationFormat, "taskrun-1234")]
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Update(ctx, signed, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tv.VerifiedPayloads(ctx, tr); err == nil {
+		t.Error("expected an error verifying a tampered countersignature")
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/secure-systems-lab/go-securesystemslib/dsse"
+	"github.com/sigstore/cosign/cmd/cosign/cli/fulcio/fulcioroots"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/sigstore/cosign/pkg/oci"
+	ociremote "github.com/sigstore/cosign/pkg/oci/remote"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// ImageOptions configures image verification.
+type ImageOptions struct {
+	// Verifier checks the signatures. When nil, signatures are checked with the Fulcio
+	// certificates attached to them, which must chain to the Fulcio roots.
+	Verifier signature.Verifier
+	// Keychain authenticates to the registry. It defaults to the Docker config.
+	Keychain authn.Keychain
+	// RekorURL, when set, requires signatures to be in that transparency log, unless the
+	// transparency log bundle attached to them verifies.
+	RekorURL string
+}
+
+// ImagePayload is a signature or attestation stored next to an image that has been verified.
+type ImagePayload struct {
+	// Payload is the signed content. For attestations this is the in-toto statement in the envelope.
+	Payload []byte
+	// Bundle is the transparency log entry attached to the signature, if there is one.
+	Bundle *oci.Bundle
+}
+
+// VerifyImageSignatures verifies the simple signing signatures stored next to an image,
+// and returns the ones that are valid. It fails if none are.
+func VerifyImageSignatures(ctx context.Context, ref name.Reference, opts ImageOptions) ([]ImagePayload, error) {
+	co := checkOpts(ctx, opts)
+	co.ClaimVerifier = cosign.SimpleClaimVerifier
+	sigs, _, err := cosign.VerifyImageSignatures(ctx, ref, co)
+	if err != nil {
+		return nil, err
+	}
+	return imagePayloads(sigs)
+}
+
+// VerifyImageAttestation verifies the in-toto attestations stored next to an image, and
+// returns the ones that are valid and have the image as a subject. It fails if none are.
+func VerifyImageAttestation(ctx context.Context, ref name.Reference, opts ImageOptions) ([]ImagePayload, error) {
+	co := checkOpts(ctx, opts)
+	co.ClaimVerifier = cosign.IntotoSubjectClaimVerifier
+	atts, _, err := cosign.VerifyImageAttestations(ctx, ref, co)
+	if err != nil {
+		return nil, err
+	}
+	return imagePayloads(atts)
+}
+
+// BuilderID returns the builder.id of the provenance in a verified attestation.
+func BuilderID(statement []byte) (string, error) {
+	provenance := intoto.ProvenanceStatement{}
+	if err := json.Unmarshal(statement, &provenance); err != nil {
+		return "", errors.Wrap(err, "decoding provenance")
+	}
+	return provenance.Predicate.Builder.ID, nil
+}
+
+func checkOpts(ctx context.Context, opts ImageOptions) *cosign.CheckOpts {
+	keychain := opts.Keychain
+	if keychain == nil {
+		keychain = authn.DefaultKeychain
+	}
+	co := &cosign.CheckOpts{
+		RegistryClientOpts: []ociremote.Option{
+			ociremote.WithRemoteOptions(remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx)),
+		},
+		SigVerifier: opts.Verifier,
+		RekorURL:    opts.RekorURL,
+	}
+	if opts.Verifier == nil {
+		co.RootCerts = fulcioroots.Get()
+	}
+	return co
+}
+
+func imagePayloads(sigs []oci.Signature) ([]ImagePayload, error) {
+	payloads := make([]ImagePayload, 0, len(sigs))
+	for _, sig := range sigs {
+		payload, err := sig.Payload()
+		if err != nil {
+			return nil, err
+		}
+		// Attestations are DSSE envelopes.
+		env := dsse.Envelope{}
+		if err := json.Unmarshal(payload, &env); err == nil && env.PayloadType != "" {
+			if payload, err = base64.StdEncoding.DecodeString(env.Payload); err != nil {
+				return nil, errors.Wrap(err, "decoding attestation")
+			}
+		}
+		bundle, err := sig.Bundle()
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, ImagePayload{Payload: payload, Bundle: bundle})
+	}
+	return payloads, nil
+}
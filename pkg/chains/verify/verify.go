@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package verify verifies the signatures and attestations Chains creates, for tools and
+// admission controllers that check what Chains signed without running the controller.
+package verify
+
+import (
+	"context"
+	"crypto"
+	cx509 "crypto/x509"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/cmd/cosign/cli/fulcio/fulcioroots"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/tektoncd/chains/pkg/artifacts"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/definitions"
+	"github.com/tektoncd/chains/pkg/chains/storage/tekton"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TaskRunPayload is a payload stored for a TaskRun whose signature has been verified.
+type TaskRunPayload = chains.VerifiedPayload
+
+// TaskRunOptions configures VerifyTaskRun.
+type TaskRunOptions struct {
+	// KubeClient and PipelineClient read the TaskRun and the storage backends it was stored in.
+	KubeClient     kubernetes.Interface
+	PipelineClient versioned.Interface
+	// Verifiers verify the signatures made by each type of signer, such as "x509" or "kms".
+	// Payloads signed by a type of signer that has no verifier are skipped, and those of a type
+	// of signer whose verifier is nil are verified with the Fulcio certificate stored with them.
+	Verifiers map[string]signature.Verifier
+}
+
+// VerifyTaskRun retrieves the payloads stored for a TaskRun from the storage backends in the
+// Chains configuration in ctx, and verifies their signatures.
+// Signatures stored in OCI registries are verified against the images instead, with
+// VerifyImageSignatures or VerifyImageAttestation.
+func VerifyTaskRun(ctx context.Context, tr *v1beta1.TaskRun, opts TaskRunOptions) ([]TaskRunPayload, error) {
+	if len(opts.Verifiers) == 0 {
+		return nil, errors.New("no verifiers to verify signatures with")
+	}
+	tv := &chains.TaskRunVerifier{
+		KubeClient:          opts.KubeClient,
+		Pipelineclientset:   opts.PipelineClient,
+		PublicKeys:          opts.Verifiers,
+		CertificateVerifier: Certificate,
+	}
+	return tv.VerifiedPayloads(ctx, tr)
+}
+
+// LoadPublicKey loads a PEM encoded public key file, such as cosign.pub, or a key in a KMS
+// when ref is a KMS reference such as gcpkms://projects/...
+func LoadPublicKey(ctx context.Context, ref string) (signature.Verifier, error) {
+	return definitions.LoadVerifier(ctx, ref)
+}
+
+// TaskRunCertificate verifies with the Fulcio certificate stored on the TaskRun, once it has
+// checked that the certificate was issued by Fulcio.
+func TaskRunCertificate(tr *v1beta1.TaskRun) (signature.Verifier, error) {
+	key := chains.VersionedKey(tr, (&artifacts.TaskRunArtifact{}).Key(tr))
+	certPEM, err := base64.StdEncoding.DecodeString(tr.Annotations[fmt.Sprintf(tekton.CertAnnotationsFormat, key)])
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding certificate")
+	}
+	if len(certPEM) == 0 {
+		return nil, fmt.Errorf("no Fulcio certificate is stored on TaskRun %s/%s", tr.Namespace, tr.Name)
+	}
+	chainPEM, err := base64.StdEncoding.DecodeString(tr.Annotations[fmt.Sprintf(tekton.ChainAnnotationFormat, key)])
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding certificate chain")
+	}
+	return Certificate(certPEM, chainPEM)
+}
+
+// Certificate verifies with a PEM Fulcio certificate, once it has checked that the certificate
+// was issued by Fulcio, through the intermediates in chainPEM if there are any.
+func Certificate(certPEM, chainPEM []byte) (signature.Verifier, error) {
+	certs, err := cryptoutils.UnmarshalCertificatesFromPEM(certPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing certificate")
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	intermediates := cx509.NewCertPool()
+	if len(chainPEM) > 0 {
+		chain, err := cryptoutils.UnmarshalCertificatesFromPEM(chainPEM)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing certificate chain")
+		}
+		for _, c := range chain {
+			intermediates.AddCert(c)
+		}
+	}
+	// Fulcio certificates are short lived, so check them as of when they were issued.
+	if _, err := certs[0].Verify(cx509.VerifyOptions{
+		Roots:         fulcioroots.Get(),
+		Intermediates: intermediates,
+		CurrentTime:   certs[0].NotBefore,
+		KeyUsages:     []cx509.ExtKeyUsage{cx509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return nil, errors.Wrap(err, "verifying certificate")
+	}
+	return signature.LoadVerifier(certs[0].PublicKey, crypto.SHA256)
+}
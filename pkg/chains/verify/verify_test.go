@@ -0,0 +1,211 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"knative.dev/pkg/controller"
+	logtesting "knative.dev/pkg/logging/testing"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+const secretPath = "../signing/x509/testdata/"
+
+func TestVerifyTaskRun(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ps := fakepipelineclient.Get(ctx)
+	ctx = controller.WithEventRecorder(ctx, record.NewFakeRecorder(10))
+	cfg := &config.Config{
+		Artifacts: config.ArtifactConfigs{
+			TaskRuns: config.Artifact{
+				Format:         "in-toto",
+				StorageBackend: "tekton",
+				Signer:         "x509",
+			},
+		},
+	}
+	ctx = config.ToContext(ctx, cfg)
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "foo",
+			UID:  types.UID("1234"),
+		},
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	ts := &chains.TaskRunSigner{
+		Pipelineclientset: ps,
+		SecretPath:        secretPath,
+	}
+	if err := ts.SignTaskRun(ctx, tr); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := x509.NewSigner(secretPath, *cfg, logtesting.TestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := signature.LoadVerifier(otherKey.Public(), crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		verifiers map[string]signature.Verifier
+		want      int
+		wantErr   bool
+	}{
+		{
+			name:      "signing key",
+			verifiers: map[string]signature.Verifier{"x509": signer},
+			want:      1,
+		},
+		{
+			name:      "another key",
+			verifiers: map[string]signature.Verifier{"x509": other},
+			wantErr:   true,
+		},
+		{
+			name:      "no verifier for the signer",
+			verifiers: map[string]signature.Verifier{"kms": signer},
+		},
+		{
+			name:    "no verifiers",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyTaskRun(ctx, tr, TaskRunOptions{PipelineClient: ps, Verifiers: tt.verifiers})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyTaskRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Fatalf("VerifyTaskRun() returned %d payloads, want %d", len(got), tt.want)
+			}
+			if tt.want > 0 && got[0].Key != "taskrun-1234" {
+				t.Errorf("key = %s, want taskrun-1234", got[0].Key)
+			}
+		})
+	}
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	if err := ioutil.WriteFile(path, pub, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := LoadPublicKey(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := v.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !priv.PublicKey.Equal(got) {
+		t.Error("LoadPublicKey() loaded a different key")
+	}
+
+	if _, err := LoadPublicKey(context.Background(), filepath.Join(t.TempDir(), "missing.pub")); err == nil {
+		t.Error("expected an error loading a missing key")
+	}
+}
+
+func TestTaskRunCertificate(t *testing.T) {
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   "default",
+			UID:         types.UID("1234"),
+			Annotations: map[string]string{"chains.tekton.dev/cert-taskrun-1234": "bm90IGEgY2VydA=="},
+		},
+	}
+	if _, err := TaskRunCertificate(tr); err == nil {
+		t.Error("expected an error for an invalid certificate")
+	}
+	delete(tr.Annotations, "chains.tekton.dev/cert-taskrun-1234")
+	if _, err := TaskRunCertificate(tr); err == nil {
+		t.Error("expected an error without a certificate")
+	}
+}
+
+func TestBuilderID(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "provenance",
+			statement: `{"_type": "https://in-toto.io/Statement/v0.1", "predicate": {"builder": {"id": "https://tekton.dev/chains/v2"}}}`,
+			want:      "https://tekton.dev/chains/v2",
+		},
+		{
+			name:      "no builder",
+			statement: `{"_type": "https://in-toto.io/Statement/v0.1", "predicate": {}}`,
+		},
+		{
+			name:      "not json",
+			statement: `not json`,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuilderID([]byte(tt.statement))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuilderID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BuilderID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vsa evaluates provenance against the policy in the Chains configuration, and creates
+// the Verification Summary Attestations (VSAs) Chains signs when the policy passes.
+// See https://slsa.dev/verification_summary/v0.1.
+package vsa
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+const (
+	// PredicateVSA is the predicate type of Verification Summary Attestations.
+	PredicateVSA = "https://slsa.dev/verification_summary/v0.1"
+	// ResultPassed is the verification result of a VSA whose policy passed.
+	ResultPassed = "PASSED"
+)
+
+// Statement is an in-toto statement with a VSA predicate.
+type Statement struct {
+	intoto.StatementHeader
+	Predicate Predicate `json:"predicate"`
+}
+
+// Predicate is the predicate of a Verification Summary Attestation.
+type Predicate struct {
+	Verifier           Verifier             `json:"verifier"`
+	TimeVerified       time.Time            `json:"time_verified"`
+	ResourceURI        string               `json:"resource_uri"`
+	Policy             ResourceDescriptor   `json:"policy"`
+	InputAttestations  []ResourceDescriptor `json:"input_attestations"`
+	VerificationResult string               `json:"verification_result"`
+	PolicyLevel        string               `json:"policy_level,omitempty"`
+}
+
+// Verifier identifies what verified the artifacts.
+type Verifier struct {
+	ID string `json:"id"`
+}
+
+// ResourceDescriptor refers to a policy or an attestation.
+type ResourceDescriptor struct {
+	URI    string         `json:"uri"`
+	Digest slsa.DigestSet `json:"digest,omitempty"`
+}
+
+// Evaluate checks the provenance against the policy in cfg. It returns every way in which
+// the provenance doesn't satisfy the policy.
+func Evaluate(cfg config.Config, provenance intoto.ProvenanceStatement) error {
+	var merr *multierror.Error
+	if len(provenance.Subject) == 0 {
+		merr = multierror.Append(merr, fmt.Errorf("the provenance has no subjects"))
+	}
+	if ids := cfg.VSA.BuilderIDs; len(ids) > 0 && !contains(ids, provenance.Predicate.Builder.ID) {
+		merr = multierror.Append(merr, fmt.Errorf("builder %q is not one of %s", provenance.Predicate.Builder.ID, strings.Join(ids, ", ")))
+	}
+	if cfg.VSA.RequireMaterials && len(provenance.Predicate.Materials) == 0 {
+		merr = multierror.Append(merr, fmt.Errorf("the provenance has no materials"))
+	}
+	return merr.ErrorOrNil()
+}
+
+// NewStatement creates a VSA for the subjects of the provenance, which is stored under
+// provenanceURI and has the given digest. The policy must have passed.
+func NewStatement(cfg config.Config, provenance intoto.ProvenanceStatement, provenanceURI string, provenanceDigest slsa.DigestSet, verified time.Time) Statement {
+	verifierID := cfg.VSA.VerifierID
+	if verifierID == "" {
+		verifierID = cfg.Builder.ID
+	}
+	resourceURI := ""
+	if len(provenance.Subject) > 0 {
+		resourceURI = provenance.Subject[0].Name
+	}
+	return Statement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: PredicateVSA,
+			Subject:       provenance.Subject,
+		},
+		Predicate: Predicate{
+			Verifier:           Verifier{ID: verifierID},
+			TimeVerified:       verified.UTC(),
+			ResourceURI:        resourceURI,
+			Policy:             ResourceDescriptor{URI: cfg.VSA.PolicyURI},
+			InputAttestations:  []ResourceDescriptor{{URI: provenanceURI, Digest: provenanceDigest}},
+			VerificationResult: ResultPassed,
+			PolicyLevel:        cfg.VSA.PolicyLevel,
+		},
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
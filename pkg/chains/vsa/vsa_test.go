@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vsa
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	slsa "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+func provenance(builderID string, materials ...string) intoto.ProvenanceStatement {
+	p := intoto.ProvenanceStatement{
+		StatementHeader: intoto.StatementHeader{
+			Subject: []intoto.Subject{{Name: "gcr.io/foo/bar", Digest: slsa.DigestSet{"sha256": "05f95b26"}}},
+		},
+	}
+	p.Predicate.Builder.ID = builderID
+	for _, m := range materials {
+		p.Predicate.Materials = append(p.Predicate.Materials, slsa.ProvenanceMaterial{URI: m})
+	}
+	return p
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		vsa        config.VSAConfig
+		provenance intoto.ProvenanceStatement
+		wantErr    bool
+	}{
+		{
+			name:       "no policy",
+			provenance: provenance("https://tekton.dev/chains/v2"),
+		},
+		{
+			name:       "allowed builder",
+			vsa:        config.VSAConfig{BuilderIDs: []string{"https://example.com", "https://tekton.dev/chains/v2"}},
+			provenance: provenance("https://tekton.dev/chains/v2"),
+		},
+		{
+			name:       "other builder",
+			vsa:        config.VSAConfig{BuilderIDs: []string{"https://example.com"}},
+			provenance: provenance("https://tekton.dev/chains/v2"),
+			wantErr:    true,
+		},
+		{
+			name:       "materials",
+			vsa:        config.VSAConfig{RequireMaterials: true},
+			provenance: provenance("https://tekton.dev/chains/v2", "git+https://github.com/tektoncd/chains"),
+		},
+		{
+			name:       "missing materials",
+			vsa:        config.VSAConfig{RequireMaterials: true},
+			provenance: provenance("https://tekton.dev/chains/v2"),
+			wantErr:    true,
+		},
+		{
+			name:       "no subjects",
+			provenance: intoto.ProvenanceStatement{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Evaluate(config.Config{VSA: tt.vsa}, tt.provenance)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewStatement(t *testing.T) {
+	cfg := config.Config{
+		Builder: config.BuilderConfig{ID: "https://tekton.dev/chains/v2"},
+		VSA: config.VSAConfig{
+			PolicyURI:   "https://example.com/policy",
+			PolicyLevel: "SLSA_LEVEL_2",
+		},
+	}
+	p := provenance("https://tekton.dev/chains/v2")
+	verified := time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC)
+	digest := slsa.DigestSet{"sha256": "abcd"}
+
+	want := Statement{
+		StatementHeader: intoto.StatementHeader{
+			Type:          intoto.StatementInTotoV01,
+			PredicateType: PredicateVSA,
+			Subject:       p.Subject,
+		},
+		Predicate: Predicate{
+			Verifier:           Verifier{ID: "https://tekton.dev/chains/v2"},
+			TimeVerified:       verified,
+			ResourceURI:        "gcr.io/foo/bar",
+			Policy:             ResourceDescriptor{URI: "https://example.com/policy"},
+			InputAttestations:  []ResourceDescriptor{{URI: "taskrun-1234", Digest: digest}},
+			VerificationResult: ResultPassed,
+			PolicyLevel:        "SLSA_LEVEL_2",
+		},
+	}
+	if diff := cmp.Diff(want, NewStatement(cfg, p, "taskrun-1234", digest, verified)); diff != "" {
+		t.Errorf("NewStatement() mismatch (-want +got): %s", diff)
+	}
+
+	cfg.VSA.VerifierID = "https://example.com/verifier"
+	if got := NewStatement(cfg, p, "taskrun-1234", digest, verified); got.Predicate.Verifier.ID != cfg.VSA.VerifierID {
+		t.Errorf("verifier = %s, want %s", got.Predicate.Verifier.ID, cfg.VSA.VerifierID)
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	"github.com/tektoncd/chains/pkg/chains/storage"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"knative.dev/pkg/logging"
+)
+
+type configOptions struct {
+	*options
+	file  string
+	probe bool
+}
+
+func newConfigCommand(o *options) *cobra.Command {
+	co := &configOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the Chains configuration",
+	}
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check chains-config for mistakes before rolling it out",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return co.validate(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+	validateCmd.Flags().StringVarP(&co.file, "file", "f", "", "Validate the ConfigMap in this file instead of the one in the cluster.")
+	validateCmd.Flags().BoolVar(&co.probe, "probe", false, "Also load the configured signers and storage backends, to check the keys and credentials they need. "+
+		"Uses the signing secret in the cluster and the credentials available to chainsctl.")
+	cmd.AddCommand(validateCmd)
+	return cmd
+}
+
+func (co *configOptions) validate(ctx context.Context, w io.Writer) error {
+	cm, err := co.configMap(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range config.UnknownKeys(cm.Data) {
+		fmt.Fprintf(w, "warning: %s is not a Chains setting and is ignored\n", k)
+	}
+	cfg, err := config.NewConfigFromConfigMap(cm)
+	if err != nil {
+		fmt.Fprintf(w, "error: %v\n", err)
+		return fmt.Errorf("%s is not valid", config.ChainsConfig)
+	}
+	if formats.PayloadType(cfg.Artifacts.TaskRuns.Format) == formats.PayloadTypeProvenance {
+		fmt.Fprintf(w, "warning: the %s format is deprecated, use %s instead\n", formats.PayloadTypeProvenance, formats.PayloadTypeInTotoIte6)
+	}
+
+	errs := errorList(cfg.Validate())
+	// Probing a configuration that is missing settings would only repeat the same errors.
+	if co.probe && len(errs) == 0 {
+		errs = co.probeConfig(ctx, cfg)
+	}
+	for _, err := range errs {
+		fmt.Fprintf(w, "error: %v\n", err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s is not valid", config.ChainsConfig)
+	}
+	fmt.Fprintf(w, "%s is valid\n", config.ChainsConfig)
+	return nil
+}
+
+// configMap reads the ConfigMap from the file, or gets it from the cluster.
+func (co *configOptions) configMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	if co.file != "" {
+		return readConfigMap(co.file)
+	}
+	kc, _, err := co.clients()
+	if err != nil {
+		return nil, err
+	}
+	cm, err := kc.CoreV1().ConfigMaps(co.chainsNamespace).Get(ctx, config.ChainsConfig, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting %s/%s", co.chainsNamespace, config.ChainsConfig)
+	}
+	return cm, nil
+}
+
+// readConfigMap reads a ConfigMap manifest.
+func readConfigMap(file string) (*corev1.ConfigMap, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cm := &corev1.ConfigMap{}
+	if err := yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(cm); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", file)
+	}
+	if cm.Kind != "" && cm.Kind != "ConfigMap" {
+		return nil, fmt.Errorf("%s holds a %s, not a ConfigMap", file, cm.Kind)
+	}
+	return cm, nil
+}
+
+// probeConfig loads the signers and storage backends the way the controller does.
+func (co *configOptions) probeConfig(ctx context.Context, cfg *config.Config) []error {
+	kc, pc, err := co.clients()
+	if err != nil {
+		return []error{err}
+	}
+	logger := logging.FromContext(co.context(ctx, cfg))
+
+	var errs []error
+	secret, err := co.signingSecret(ctx)
+	if apierrors.IsNotFound(err) {
+		secret = &corev1.Secret{}
+	} else if err != nil {
+		return []error{err}
+	}
+	if err := withSecretDir(secret, func(dir string) error {
+		return chains.ValidateConfig(dir, logger)(cfg)
+	}); err != nil {
+		errs = append(errs, err)
+	}
+	// The OCI backend authenticates as the service account of the TaskRun, so use the controller's.
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: co.chainsNamespace},
+		Spec:       v1beta1.TaskRunSpec{ServiceAccountName: ControllerServiceAccount},
+	}
+	if _, err := storage.InitializeBackends(pc, kc, logger, tr, *cfg); err != nil {
+		errs = append(errs, errors.Wrap(err, "initializing storage backends"))
+	}
+	return errs
+}
+
+// errorList splits up the errors collected in a multierror.
+func errorList(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if merr, ok := err.(*multierror.Error); ok {
+		return merr.Errors
+	}
+	return []error{err}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tektoncd/chains/pkg/config"
+	fakepipeline "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigValidate(t *testing.T) {
+	priv, _ := testKeys(t)
+	chainsConfig := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: config.ChainsConfig, Namespace: DefaultChainsNamespace},
+			Data:       data,
+		}
+	}
+	signingSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: SigningSecret, Namespace: DefaultChainsNamespace},
+		Data:       map[string][]byte{x509Key: priv},
+	}
+	controllerServiceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: ControllerServiceAccount, Namespace: DefaultChainsNamespace},
+	}
+
+	tests := []struct {
+		name     string
+		existing []runtime.Object
+		file     string
+		args     []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			existing: []runtime.Object{chainsConfig(map[string]string{"artifacts.taskrun.format": "in-toto"})},
+			want:     []string{"chains-config is valid"},
+		},
+		{
+			name: "valid file",
+			file: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: chains-config
+data:
+  artifacts.taskrun.format: in-toto
+`,
+			want: []string{"chains-config is valid"},
+		},
+		{
+			name: "unknown keys",
+			file: `data:
+  artifacts.taskrun.fromat: in-toto
+`,
+			want: []string{"warning: artifacts.taskrun.fromat is not a Chains setting", "chains-config is valid"},
+		},
+		{
+			name: "deprecated format",
+			file: `data:
+  artifacts.taskrun.format: tekton-provenance
+`,
+			want: []string{"warning: the tekton-provenance format is deprecated"},
+		},
+		{
+			name: "invalid value",
+			file: `data:
+  artifacts.taskrun.format: slsa
+`,
+			want:    []string{"error: failed to parse data"},
+			wantErr: true,
+		},
+		{
+			name: "missing settings",
+			file: `data:
+  artifacts.taskrun.storage: gcs
+  artifacts.oci.signer: kms
+`,
+			want:    []string{"error: artifacts.taskrun.storage is gcs but storage.gcs.bucket is not set", "error: artifacts.oci.signer is kms but signers.kms.kmsref is not set"},
+			wantErr: true,
+		},
+		{
+			name: "not a configmap",
+			file: `kind: Secret
+`,
+			wantErr: true,
+		},
+		{
+			name:     "probe",
+			existing: []runtime.Object{chainsConfig(nil), signingSecret, controllerServiceAccount},
+			args:     []string{"--probe"},
+			want:     []string{"chains-config is valid"},
+		},
+		{
+			name:     "probe without keys",
+			existing: []runtime.Object{chainsConfig(nil), controllerServiceAccount},
+			args:     []string{"--probe"},
+			want:     []string{"error: loading x509 signer"},
+			wantErr:  true,
+		},
+		{
+			name:    "no configmap",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &options{
+				chainsNamespace: DefaultChainsNamespace,
+				kubeClient:      fakekube.NewSimpleClientset(tt.existing...),
+				pipelineClient:  fakepipeline.NewSimpleClientset(),
+			}
+			args := append([]string{"config", "validate"}, tt.args...)
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "chains-config.yaml")
+				if err := ioutil.WriteFile(path, []byte(tt.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "-f", path)
+			}
+			out, err := runCommand(o, args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("config validate error = %v, wantErr %v\n%s", err, tt.wantErr, out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output %q doesn't contain %q", out, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ghodss/yaml"
+	intoto "github.com/in-toto/in-toto-golang/in_toto"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The output formats of describe.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+type describeOptions struct {
+	*options
+	output string
+}
+
+// attestationSummary is the part of an in-toto provenance attestation people look at.
+type attestationSummary struct {
+	Key           string      `json:"key"`
+	PredicateType string      `json:"predicateType"`
+	Builder       string      `json:"builder"`
+	BuildType     string      `json:"buildType"`
+	StartedOn     *time.Time  `json:"startedOn,omitempty"`
+	FinishedOn    *time.Time  `json:"finishedOn,omitempty"`
+	Reproducible  bool        `json:"reproducible"`
+	Subjects      []artifact  `json:"subjects"`
+	Materials     []artifact  `json:"materials"`
+	Parameters    interface{} `json:"parameters,omitempty"`
+	// Transparency is the transparency log entry of the attestation, if it was uploaded.
+	Transparency *chains.TransparencyEntry `json:"transparency,omitempty"`
+}
+
+// artifact is a subject or material of an attestation.
+type artifact struct {
+	Name   string   `json:"name"`
+	Digest []string `json:"digest"`
+}
+
+func newDescribeCommand(o *options) *cobra.Command {
+	do := &describeOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "describe",
+		Short: "Show what Chains stored in a readable form",
+	}
+	attestationCmd := &cobra.Command{
+		Use:   "attestation TASKRUN",
+		Short: "Summarize the in-toto provenance stored for a TaskRun",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch do.output {
+			case outputTable, outputJSON, outputYAML:
+			default:
+				return fmt.Errorf("--output must be one of %s, %s or %s", outputTable, outputJSON, outputYAML)
+			}
+			return do.describeAttestation(cmd.Context(), cmd.OutOrStdout(), args[0])
+		},
+	}
+	attestationCmd.Flags().StringVarP(&do.output, "output", "o", outputTable, "Output format: table, json or yaml.")
+	cmd.AddCommand(attestationCmd)
+	return cmd
+}
+
+func (do *describeOptions) describeAttestation(ctx context.Context, w io.Writer, taskRun string) error {
+	ns, err := do.namespace()
+	if err != nil {
+		return err
+	}
+	kc, pc, err := do.clients()
+	if err != nil {
+		return err
+	}
+	tr, err := pc.TektonV1beta1().TaskRuns(ns).Get(ctx, taskRun, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if tr.Annotations[chains.ChainsAnnotation] != "true" {
+		return fmt.Errorf("taskrun %s/%s has not been signed", tr.Namespace, tr.Name)
+	}
+	cfg, err := do.chainsConfig(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = do.context(ctx, cfg)
+
+	payloads, err := chains.RetrievePayloads(ctx, pc, kc, tr)
+	if err != nil {
+		return err
+	}
+	tlogEntries, err := chains.TransparencyEntries(tr)
+	if err != nil {
+		return err
+	}
+	summaries := []attestationSummary{}
+	for _, p := range payloads {
+		if p.Format != formats.PayloadTypeInTotoIte6 {
+			continue
+		}
+		s, err := summarize(p.Key, p.Payload)
+		if err != nil {
+			return err
+		}
+		for i := range tlogEntries {
+			if tlogEntries[i].Key == p.Key {
+				s.Transparency = &tlogEntries[i]
+			}
+		}
+		summaries = append(summaries, s)
+	}
+	if len(summaries) == 0 {
+		return fmt.Errorf("no %s attestations are stored for taskrun %s/%s", formats.PayloadTypeInTotoIte6, tr.Namespace, tr.Name)
+	}
+
+	switch do.output {
+	case outputJSON:
+		b, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	case outputYAML:
+		b, err := yaml.Marshal(summaries)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(w, string(b))
+	default:
+		for i, s := range summaries {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			printSummary(w, s)
+		}
+	}
+	return nil
+}
+
+// summarize decodes an in-toto provenance statement.
+func summarize(key string, payload []byte) (attestationSummary, error) {
+	st := intoto.ProvenanceStatement{}
+	if err := json.Unmarshal(payload, &st); err != nil {
+		return attestationSummary{}, errors.Wrapf(err, "decoding %s", key)
+	}
+	s := attestationSummary{
+		Key:           key,
+		PredicateType: st.PredicateType,
+		Builder:       st.Predicate.Builder.ID,
+		BuildType:     st.Predicate.BuildType,
+		Subjects:      []artifact{},
+		Materials:     []artifact{},
+		Parameters:    st.Predicate.Invocation.Parameters,
+	}
+	if m := st.Predicate.Metadata; m != nil {
+		s.StartedOn = m.BuildStartedOn
+		s.FinishedOn = m.BuildFinishedOn
+		s.Reproducible = m.Reproducible
+	}
+	for _, subject := range st.Subject {
+		s.Subjects = append(s.Subjects, artifact{Name: subject.Name, Digest: digests(subject.Digest)})
+	}
+	for _, material := range st.Predicate.Materials {
+		s.Materials = append(s.Materials, artifact{Name: material.URI, Digest: digests(material.Digest)})
+	}
+	return s, nil
+}
+
+// digests formats a digest set as sorted algorithm:value pairs.
+func digests(set map[string]string) []string {
+	d := []string{}
+	for alg, value := range set {
+		d = append(d, alg+":"+value)
+	}
+	sort.Strings(d)
+	return d
+}
+
+func printSummary(w io.Writer, s attestationSummary) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintf(tw, "Attestation:\t%s\n", s.Key)
+	fmt.Fprintf(tw, "Predicate Type:\t%s\n", s.PredicateType)
+	fmt.Fprintf(tw, "Builder:\t%s\n", s.Builder)
+	fmt.Fprintf(tw, "Build Type:\t%s\n", s.BuildType)
+	fmt.Fprintf(tw, "Started:\t%s\n", formatTime(s.StartedOn))
+	fmt.Fprintf(tw, "Finished:\t%s\n", formatTime(s.FinishedOn))
+	fmt.Fprintf(tw, "Reproducible:\t%t\n", s.Reproducible)
+	if e := s.Transparency; e != nil {
+		fmt.Fprintf(tw, "Transparency Log:\t%s (index %d, integrated at %s)\n", e.EntryURL(), e.LogIndex, time.Unix(e.IntegratedTime, 0).UTC().Format(time.RFC3339))
+	}
+	printArtifacts(tw, "Subjects", "NAME", s.Subjects)
+	printArtifacts(tw, "Materials", "URI", s.Materials)
+
+	fmt.Fprintln(tw, "Parameters:")
+	params, ok := s.Parameters.([]interface{})
+	switch {
+	case s.Parameters == nil:
+		fmt.Fprintln(tw, "  <none>")
+	case ok:
+		for _, p := range params {
+			fmt.Fprintf(tw, "  %v\n", p)
+		}
+	default:
+		b, _ := json.Marshal(s.Parameters)
+		fmt.Fprintf(tw, "  %s\n", b)
+	}
+}
+
+func printArtifacts(w io.Writer, title, nameHeader string, artifacts []artifact) {
+	fmt.Fprintf(w, "%s:\n", title)
+	if len(artifacts) == 0 {
+		fmt.Fprintln(w, "  <none>")
+		return
+	}
+	fmt.Fprintf(w, "  %s\tDIGEST\n", nameHeader)
+	for _, a := range artifacts {
+		fmt.Fprintf(w, "  %s\t%s\n", a.Name, strings.Join(a.Digest, ", "))
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "<unknown>"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
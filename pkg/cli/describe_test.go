@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tektoncd/chains/pkg/chains"
+)
+
+const testStatement = `{
+  "_type": "https://in-toto.io/Statement/v0.1",
+  "predicateType": "https://slsa.dev/provenance/v0.2",
+  "subject": [{"name": "gcr.io/foo/bar", "digest": {"sha256": "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"}}],
+  "predicate": {
+    "builder": {"id": "https://tekton.dev/chains/v2"},
+    "buildType": "https://tekton.dev/attestations/chains@v2",
+    "invocation": {"parameters": ["IMAGE=gcr.io/foo/bar"]},
+    "metadata": {"buildStartedOn": "2021-11-15T18:13:20Z", "buildFinishedOn": "2021-11-15T18:15:00Z", "completeness": {}, "reproducible": false},
+    "materials": [{"uri": "git+https://github.com/foo/bar.git", "digest": {"sha1": "50c56a48cfb3a5a80fa36ed91c739bdac8381cbe"}}]
+  }
+}`
+
+func TestPrintSummary(t *testing.T) {
+	s, err := summarize("taskrun-1234", []byte(testStatement))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	printSummary(&out, s)
+	want := `Attestation:     taskrun-1234
+Predicate Type:  https://slsa.dev/provenance/v0.2
+Builder:         https://tekton.dev/chains/v2
+Build Type:      https://tekton.dev/attestations/chains@v2
+Started:         2021-11-15T18:13:20Z
+Finished:        2021-11-15T18:15:00Z
+Reproducible:    false
+Subjects:
+  NAME            DIGEST
+  gcr.io/foo/bar  sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5
+Materials:
+  URI                                 DIGEST
+  git+https://github.com/foo/bar.git  sha1:50c56a48cfb3a5a80fa36ed91c739bdac8381cbe
+Parameters:
+  IMAGE=gcr.io/foo/bar
+`
+	if got := out.String(); got != want {
+		t.Errorf("printSummary() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPrintSummaryTransparency(t *testing.T) {
+	s, err := summarize("taskrun-1234", []byte(testStatement))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Transparency = &chains.TransparencyEntry{
+		Key:            "taskrun-1234",
+		URL:            "https://rekor.example.com",
+		UUID:           "abcd",
+		LogIndex:       42,
+		IntegratedTime: 1637000000,
+	}
+	var out bytes.Buffer
+	printSummary(&out, s)
+	want := "Transparency Log:  https://rekor.example.com/api/v1/log/entries/abcd (index 42, integrated at 2021-11-15T18:13:20Z)\n"
+	if got := out.String(); !strings.Contains(got, want) {
+		t.Errorf("printSummary() =\n%s\nwant it to contain\n%s", got, want)
+	}
+}
+
+func TestDescribeAttestation(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{name: "table", format: "in-toto", output: "table", want: "Attestation:     taskrun-1234"},
+		{name: "json", format: "in-toto", output: "json", want: `"key": "taskrun-1234"`},
+		{name: "yaml", format: "in-toto", output: "yaml", want: "  key: taskrun-1234"},
+		{name: "bad output", format: "in-toto", output: "xml", wantErr: true},
+		{name: "not in-toto", format: "tekton", output: "table", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := signedTaskRun(t, tt.format)
+			out, err := runCommand(o, "describe", "attestation", "foo", "-n", "default", "-o", tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("describe attestation error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("output %q doesn't contain %q", out, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/chains/pkg/chains"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+// mismatch is a field that differs between an attestation and the TaskRun it describes.
+type mismatch struct {
+	Path   string
+	Stored string
+	Live   string
+}
+
+// missing stands in for a field that is only on one side of a mismatch.
+const missing = "<missing>"
+
+func newDriftCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Compare what Chains attested with the objects as they are now",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "taskrun NAME",
+		Short: "Report where a TaskRun no longer matches the payload stored for it, such as changed results or parameters",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.driftTaskRun(cmd.Context(), cmd.OutOrStdout(), args[0])
+		},
+	})
+	return cmd
+}
+
+func (o *options) driftTaskRun(ctx context.Context, w io.Writer, taskRun string) error {
+	ns, err := o.namespace()
+	if err != nil {
+		return err
+	}
+	kc, pc, err := o.clients()
+	if err != nil {
+		return err
+	}
+	tr, err := pc.TektonV1beta1().TaskRuns(ns).Get(ctx, taskRun, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if tr.Annotations[chains.ChainsAnnotation] != "true" {
+		return fmt.Errorf("taskrun %s/%s has not been signed", tr.Namespace, tr.Name)
+	}
+	cfg, err := o.chainsConfig(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = o.context(ctx, cfg)
+
+	stored, err := chains.RetrieveTaskRunPayload(ctx, pc, kc, tr)
+	if err != nil {
+		return err
+	}
+	// Format the TaskRun as it is now, with the formatter that created the stored payload.
+	payload, err := chains.CreatePayload(*cfg, stored.Format, tr, logging.FromContext(ctx))
+	if err != nil {
+		return err
+	}
+	live, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var storedObj, liveObj interface{}
+	if err := json.Unmarshal(stored.Payload, &storedObj); err != nil {
+		return errors.Wrapf(err, "decoding %s", stored.Key)
+	}
+	if err := json.Unmarshal(live, &liveObj); err != nil {
+		return err
+	}
+	mismatches := jsonDiff("", storedObj, liveObj)
+	if len(mismatches) == 0 {
+		fmt.Fprintf(w, "taskrun %s/%s matches %s (%s)\n", tr.Namespace, tr.Name, stored.Key, stored.Format)
+		return nil
+	}
+	fmt.Fprintf(w, "taskrun %s/%s differs from %s (%s):\n", tr.Namespace, tr.Name, stored.Key, stored.Format)
+	for _, m := range mismatches {
+		fmt.Fprintf(w, "  %s: attested %s, now %s\n", m.Path, m.Stored, m.Live)
+	}
+	return fmt.Errorf("taskrun %s/%s has drifted from its attestation", tr.Namespace, tr.Name)
+}
+
+// jsonDiff lists the fields where two decoded JSON documents differ.
+func jsonDiff(path string, stored, live interface{}) []mismatch {
+	switch s := stored.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for k := range s {
+			keys[k] = true
+		}
+		for k := range l {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var mismatches []mismatch
+		for _, k := range sorted {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			sv, sok := s[k]
+			lv, lok := l[k]
+			switch {
+			case !sok:
+				mismatches = append(mismatches, mismatch{Path: p, Stored: missing, Live: jsonString(lv)})
+			case !lok:
+				mismatches = append(mismatches, mismatch{Path: p, Stored: jsonString(sv), Live: missing})
+			default:
+				mismatches = append(mismatches, jsonDiff(p, sv, lv)...)
+			}
+		}
+		return mismatches
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok {
+			break
+		}
+		var mismatches []mismatch
+		for i := 0; i < len(s) || i < len(l); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(s):
+				mismatches = append(mismatches, mismatch{Path: p, Stored: missing, Live: jsonString(l[i])})
+			case i >= len(l):
+				mismatches = append(mismatches, mismatch{Path: p, Stored: jsonString(s[i]), Live: missing})
+			default:
+				mismatches = append(mismatches, jsonDiff(p, s[i], l[i])...)
+			}
+		}
+		return mismatches
+	}
+	if sv, lv := jsonString(stored), jsonString(live); sv != lv {
+		return []mismatch{{Path: path, Stored: sv, Live: lv}}
+	}
+	return nil
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJSONDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored string
+		live   string
+		want   []mismatch
+	}{
+		{
+			name:   "same",
+			stored: `{"a": [1, {"b": "c"}]}`,
+			live:   `{"a": [1, {"b": "c"}]}`,
+		},
+		{
+			name:   "changed value",
+			stored: `{"subject": [{"digest": {"sha256": "abc"}}]}`,
+			live:   `{"subject": [{"digest": {"sha256": "def"}}]}`,
+			want:   []mismatch{{Path: "subject[0].digest.sha256", Stored: `"abc"`, Live: `"def"`}},
+		},
+		{
+			name:   "added and removed",
+			stored: `{"a": 1, "params": ["x=1"]}`,
+			live:   `{"b": 2, "params": ["x=1", "y=2"]}`,
+			want: []mismatch{
+				{Path: "a", Stored: "1", Live: missing},
+				{Path: "b", Stored: missing, Live: "2"},
+				{Path: "params[1]", Stored: missing, Live: `"y=2"`},
+			},
+		},
+		{
+			name:   "changed type",
+			stored: `{"a": {"b": 1}}`,
+			live:   `{"a": [1]}`,
+			want:   []mismatch{{Path: "a", Stored: `{"b":1}`, Live: "[1]"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored, live interface{}
+			if err := json.Unmarshal([]byte(tt.stored), &stored); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.live), &live); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, jsonDiff("", stored, live)); diff != "" {
+				t.Errorf("jsonDiff() (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestDriftTaskRun(t *testing.T) {
+	for _, format := range []string{"tekton", "in-toto"} {
+		t.Run(format, func(t *testing.T) {
+			o, _ := signedTaskRun(t, format)
+			out, err := runCommand(o, "drift", "taskrun", "foo", "-n", "default")
+			if err != nil {
+				t.Fatalf("drift error = %v\n%s", err, out)
+			}
+			if want := "matches taskrun-1234"; !strings.Contains(out, want) {
+				t.Errorf("output %q doesn't contain %q", out, want)
+			}
+
+			// Change the results after the TaskRun was signed.
+			ctx := context.Background()
+			taskRuns := o.pipelineClient.TektonV1beta1().TaskRuns("default")
+			tr, err := taskRuns.Get(ctx, "foo", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr.Status.TaskRunResults = []v1beta1.TaskRunResult{
+				{Name: "IMAGE_URL", Value: "gcr.io/foo/bar"},
+				{Name: "IMAGE_DIGEST", Value: "sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"},
+			}
+			if _, err := taskRuns.UpdateStatus(ctx, tr, metav1.UpdateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			out, err = runCommand(o, "drift", "taskrun", "foo", "-n", "default")
+			if err == nil {
+				t.Fatalf("expected drift to be reported\n%s", out)
+			}
+			if want := "differs from taskrun-1234"; !strings.Contains(out, want) {
+				t.Errorf("output %q doesn't contain %q", out, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/storage"
+	"github.com/tektoncd/chains/pkg/chains/storage/tekton"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/logging"
+)
+
+// The files written for each TaskRun, and for each payload stored for it.
+const (
+	taskRunFile   = "taskrun.json"
+	payloadFile   = "payload.json"
+	signatureFile = "signature"
+	certFile      = "cert.pem"
+	chainFile     = "chain.pem"
+	bundleFile    = "bundle.json"
+)
+
+type exportOptions struct {
+	*options
+	output        string
+	since         string
+	selector      string
+	allNamespaces bool
+	backend       string
+	digest        string
+}
+
+func newExportCommand(o *options) *cobra.Command {
+	eo := &exportOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write the payloads, signatures and certificates stored for TaskRuns to a directory or tarball",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if eo.output == "" {
+				return errors.New("--output is required")
+			}
+			if eo.backend == "" && eo.digest != "" {
+				return errors.New("--digest requires --backend")
+			}
+			if eo.backend != "" && eo.selector != "" {
+				return errors.New("--selector can't be used with --backend")
+			}
+			since, err := parseSince(eo.since)
+			if err != nil {
+				return err
+			}
+			return eo.export(cmd.Context(), cmd.OutOrStdout(), since)
+		},
+	}
+	cmd.Flags().StringVarP(&eo.output, "output", "o", "", "Directory to write to. Paths ending in .tar or .tar.gz are written as a tarball.")
+	cmd.Flags().StringVar(&eo.since, "since", "", "Only export TaskRuns that completed within this long, such as 30d or 12h. By default every TaskRun is exported.")
+	cmd.Flags().StringVarP(&eo.selector, "selector", "l", "", "Label selector of the TaskRuns to export.")
+	cmd.Flags().BoolVarP(&eo.allNamespaces, "all-namespaces", "A", false, "Export TaskRuns in every namespace.")
+	cmd.Flags().StringVar(&eo.backend, "backend", "", "List the payloads stored in this storage backend, such as gcs or docdb, instead of walking TaskRuns.")
+	cmd.Flags().StringVar(&eo.digest, "digest", "", "With --backend, only export the payloads about the subject with this digest, such as sha256:abc.")
+	return cmd
+}
+
+// parseSince parses a duration, which may also be given in days.
+func parseSince(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid --since %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid --since %q", s)
+	}
+	return d, nil
+}
+
+func (eo *exportOptions) export(ctx context.Context, w io.Writer, since time.Duration) error {
+	ns, err := eo.namespace()
+	if err != nil {
+		return err
+	}
+	if eo.allNamespaces {
+		ns = ""
+	}
+	kc, pc, err := eo.clients()
+	if err != nil {
+		return err
+	}
+	cfg, err := eo.chainsConfig(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = eo.context(ctx, cfg)
+
+	if eo.backend != "" {
+		return eo.exportBackend(ctx, w, kc, pc, *cfg, ns, since)
+	}
+
+	taskRuns, err := eo.taskRuns(ctx, ns, nil, eo.selector)
+	if err != nil {
+		return err
+	}
+
+	out, err := newExportWriter(eo.output)
+	if err != nil {
+		return err
+	}
+	var merr *multierror.Error
+	exported := 0
+	for i := range taskRuns {
+		tr := &taskRuns[i]
+		if tr.Annotations[chains.ChainsAnnotation] != "true" {
+			continue
+		}
+		if since > 0 && (tr.Status.CompletionTime == nil || time.Since(tr.Status.CompletionTime.Time) > since) {
+			continue
+		}
+		payloads, err := chains.RetrievePayloads(ctx, pc, kc, tr)
+		if err == nil {
+			err = exportTaskRun(out, tr, payloads)
+		}
+		if err != nil {
+			merr = multierror.Append(merr, errors.Wrapf(err, "taskrun %s/%s", tr.Namespace, tr.Name))
+			continue
+		}
+		exported++
+	}
+	if err := out.Close(); err != nil {
+		merr = multierror.Append(merr, errors.Wrapf(err, "writing %s", eo.output))
+	}
+	fmt.Fprintf(w, "Exported %d taskruns to %s\n", exported, eo.output)
+	return merr.ErrorOrNil()
+}
+
+// exportTaskRun writes the TaskRun and what is stored for it under <namespace>/<name>.
+func exportTaskRun(out exportWriter, tr *v1beta1.TaskRun, payloads []chains.StoredPayload) error {
+	dir := path.Join(tr.Namespace, tr.Name)
+	b, err := json.MarshalIndent(tr, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := out.WriteFile(path.Join(dir, taskRunFile), b); err != nil {
+		return err
+	}
+	for _, p := range payloads {
+		files := payloadFiles(p.Payload, p.Signature, p.Cert, p.Chain)
+		// Transparency log bundles are only kept on the TaskRun, by the tekton backend.
+		if v, ok := tr.Annotations[fmt.Sprintf(tekton.BundleAnnotationFormat, p.Key)]; ok {
+			bundle, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return errors.Wrapf(err, "decoding %s for %s", bundleFile, p.Key)
+			}
+			files[bundleFile] = bundle
+		}
+		for name, content := range files {
+			if err := out.WriteFile(path.Join(dir, p.Key, name), content); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// payloadFiles returns the files written for a stored payload.
+func payloadFiles(payload, signature []byte, cert, chain string) map[string][]byte {
+	files := map[string][]byte{
+		payloadFile:   payload,
+		signatureFile: signature,
+	}
+	if cert != "" {
+		files[certFile] = []byte(cert)
+	}
+	if chain != "" {
+		files[chainFile] = []byte(chain)
+	}
+	return files
+}
+
+// exportBackend writes the payloads listed by a storage backend, under <namespace>/<name> of the
+// TaskRuns they were stored for.
+func (eo *exportOptions) exportBackend(ctx context.Context, w io.Writer, kc kubernetes.Interface, pc versioned.Interface, cfg config.Config, ns string, since time.Duration) error {
+	b, err := storage.InitializeBackend(pc, kc, logging.FromContext(ctx), &v1beta1.TaskRun{}, cfg, eo.backend)
+	if err != nil {
+		return errors.Wrapf(err, "initializing the %s backend", eo.backend)
+	}
+	if b == nil {
+		return fmt.Errorf("unknown storage backend %q", eo.backend)
+	}
+	filter := config.ListFilter{Namespace: ns, Digest: eo.digest}
+	if since > 0 {
+		filter.Since = time.Now().Add(-since)
+	}
+	payloads, err := storage.ListPayloads(ctx, b, filter)
+	if err != nil {
+		return errors.Wrapf(err, "listing the payloads in the %s backend", eo.backend)
+	}
+
+	out, err := newExportWriter(eo.output)
+	if err != nil {
+		return err
+	}
+	var merr *multierror.Error
+	for _, p := range payloads {
+		for name, content := range payloadFiles(p.Payload, []byte(p.Signature), p.Cert, p.Chain) {
+			if err := out.WriteFile(path.Join(p.Namespace, p.TaskRun, p.Key, name), content); err != nil {
+				merr = multierror.Append(merr, errors.Wrapf(err, "payload %s", p.Key))
+			}
+		}
+	}
+	if err := out.Close(); err != nil {
+		merr = multierror.Append(merr, errors.Wrapf(err, "writing %s", eo.output))
+	}
+	fmt.Fprintf(w, "Exported %d payloads to %s\n", len(payloads), eo.output)
+	return merr.ErrorOrNil()
+}
+
+// exportWriter writes the exported files to a directory or a tarball.
+type exportWriter interface {
+	WriteFile(name string, content []byte) error
+	Close() error
+}
+
+func newExportWriter(output string) (exportWriter, error) {
+	if !strings.HasSuffix(output, ".tar") && !strings.HasSuffix(output, ".tar.gz") && !strings.HasSuffix(output, ".tgz") {
+		if err := os.MkdirAll(output, 0755); err != nil {
+			return nil, err
+		}
+		return dirWriter(output), nil
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	tw := &tarWriter{closers: []io.Closer{f}}
+	if strings.HasSuffix(output, ".tar") {
+		tw.w = tar.NewWriter(f)
+	} else {
+		gw := gzip.NewWriter(f)
+		tw.w = tar.NewWriter(gw)
+		tw.closers = append([]io.Closer{gw}, tw.closers...)
+	}
+	tw.closers = append([]io.Closer{tw.w}, tw.closers...)
+	return tw, nil
+}
+
+type dirWriter string
+
+func (d dirWriter) WriteFile(name string, content []byte) error {
+	p := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, content, 0644)
+}
+
+func (d dirWriter) Close() error {
+	return nil
+}
+
+type tarWriter struct {
+	w       *tar.Writer
+	closers []io.Closer
+}
+
+func (t *tarWriter) WriteFile(name string, content []byte) error {
+	if err := t.w.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := t.w.Write(content)
+	return err
+}
+
+// Close flushes the tar and gzip streams before closing the file.
+func (t *tarWriter) Close() error {
+	var merr *multierror.Error
+	for _, c := range t.closers {
+		if err := c.Close(); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	return merr.ErrorOrNil()
+}
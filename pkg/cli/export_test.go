@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		since   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{since: "", want: 0},
+		{since: "30d", want: 30 * 24 * time.Hour},
+		{since: "12h", want: 12 * time.Hour},
+		{since: "1.5d", wantErr: true},
+		{since: "-1h", wantErr: true},
+		{since: "month", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.since, func(t *testing.T) {
+			got, err := parseSince(tt.since)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSince() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSince() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	wantFiles := []string{
+		"default/foo/taskrun-1234/payload.json",
+		"default/foo/taskrun-1234/signature",
+		"default/foo/taskrun.json",
+	}
+	tests := []struct {
+		name      string
+		output    string
+		since     string
+		completed time.Duration
+		want      []string
+	}{
+		{
+			name:   "directory",
+			output: "evidence",
+			want:   wantFiles,
+		},
+		{
+			name:   "tarball",
+			output: "evidence.tar.gz",
+			want:   wantFiles,
+		},
+		{
+			name:      "recent",
+			output:    "evidence",
+			since:     "30d",
+			completed: 24 * time.Hour,
+			want:      wantFiles,
+		},
+		{
+			name:      "too old",
+			output:    "evidence",
+			since:     "30d",
+			completed: 31 * 24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := signedTaskRun(t, "in-toto")
+			ctx := context.Background()
+			taskRuns := o.pipelineClient.TektonV1beta1().TaskRuns("default")
+			tr, err := taskRuns.Get(ctx, "foo", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(-tt.completed)}
+			if _, err := taskRuns.UpdateStatus(ctx, tr, metav1.UpdateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			output := filepath.Join(t.TempDir(), tt.output)
+			args := []string{"export", "-n", "default", "-o", output}
+			if tt.since != "" {
+				args = append(args, "--since", tt.since)
+			}
+			if _, err := runCommand(o, args...); err != nil {
+				t.Fatalf("export error = %v", err)
+			}
+
+			got := exportedFiles(t, output)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("exported files (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestExport_Backend(t *testing.T) {
+	o, _ := signedTaskRun(t, "in-toto")
+	output := filepath.Join(t.TempDir(), "evidence")
+	if _, err := runCommand(o, "export", "-n", "default", "--backend", "tekton", "-o", output); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+	want := []string{
+		"default/foo/taskrun-1234/payload.json",
+		"default/foo/taskrun-1234/signature",
+	}
+	if diff := cmp.Diff(want, exportedFiles(t, output)); diff != "" {
+		t.Errorf("exported files (-want +got): %s", diff)
+	}
+
+	// The payload is about the TaskRun, not an image.
+	output = filepath.Join(t.TempDir(), "evidence")
+	if _, err := runCommand(o, "export", "-n", "default", "--backend", "tekton", "--digest", "sha256:abc", "-o", output); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+	if got := exportedFiles(t, output); len(got) != 0 {
+		t.Errorf("expected no files for another digest, got %v", got)
+	}
+
+	if _, err := runCommand(o, "export", "--digest", "sha256:abc", "-o", output); err == nil {
+		t.Error("expected --digest without --backend to fail")
+	}
+}
+
+// exportedFiles lists the files in an export directory or tarball.
+func exportedFiles(t *testing.T, output string) []string {
+	t.Helper()
+	var files []string
+	if !strings.HasSuffix(output, ".tar.gz") {
+		err := filepath.Walk(output, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(output, p)
+			files = append(files, filepath.ToSlash(rel))
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return files
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, hdr.Name)
+	}
+	sort.Strings(files)
+	return files
+}
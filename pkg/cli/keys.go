@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/pkg/cosign"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The keys of the signing secret, which are the files the x509 signer reads.
+const (
+	x509Key        = "x509.pem"
+	cosignKey      = "cosign.key"
+	cosignPassword = "cosign.password"
+	cosignPub      = "cosign.pub"
+)
+
+type keysOptions struct {
+	*options
+	passwordStdin bool
+	force         bool
+}
+
+func newKeysCommand(o *options) *cobra.Command {
+	ko := &keysOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage the keys Chains signs with",
+	}
+	generateCmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a cosign key pair, store it in the signing secret and print the public key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ko.generate(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+	generateCmd.Flags().BoolVar(&ko.passwordStdin, "password-stdin", false, "Read the password the private key is encrypted with from stdin. By default a random password is generated and only stored in the secret.")
+	generateCmd.Flags().BoolVar(&ko.force, "force", false, "Replace the keys in a signing secret that already has some.")
+	cmd.AddCommand(generateCmd)
+	return cmd
+}
+
+func (ko *keysOptions) generate(ctx context.Context, in io.Reader, out io.Writer) error {
+	password, err := ko.password(in)
+	if err != nil {
+		return err
+	}
+	keys, err := cosign.GenerateKeyPair(func(bool) ([]byte, error) { return password, nil })
+	if err != nil {
+		return errors.Wrap(err, "generating keys")
+	}
+	data := map[string][]byte{
+		cosignKey:      keys.PrivateBytes,
+		cosignPassword: password,
+		cosignPub:      keys.PublicBytes,
+	}
+
+	kc, _, err := ko.clients()
+	if err != nil {
+		return err
+	}
+	secrets := kc.CoreV1().Secrets(ko.chainsNamespace)
+	secret, err := secrets.Get(ctx, SigningSecret, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		_, err = secrets.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SigningSecret, Namespace: ko.chainsNamespace},
+			Data:       data,
+		}, metav1.CreateOptions{})
+	case err != nil:
+		return errors.Wrapf(err, "getting %s/%s", ko.chainsNamespace, SigningSecret)
+	default:
+		if (len(secret.Data[x509Key]) > 0 || len(secret.Data[cosignKey]) > 0) && !ko.force {
+			return fmt.Errorf("%s/%s already has signing keys, pass --force to replace them", ko.chainsNamespace, SigningSecret)
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		// The controller prefers x509.pem over cosign.key, so it has to go.
+		delete(secret.Data, x509Key)
+		for k, v := range data {
+			secret.Data[k] = v
+		}
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return errors.Wrapf(err, "storing keys in %s/%s", ko.chainsNamespace, SigningSecret)
+	}
+
+	fmt.Fprint(out, string(keys.PublicBytes))
+	return nil
+}
+
+// password reads the password from stdin, or generates one.
+func (ko *keysOptions) password(in io.Reader) ([]byte, error) {
+	if ko.passwordStdin {
+		b, err := ioutil.ReadAll(in)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading password")
+		}
+		return bytes.TrimRight(b, "\r\n"), nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "generating password")
+	}
+	return []byte(base64.RawURLEncoding.EncodeToString(b)), nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/config"
+	fakepipeline "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestKeysGenerate(t *testing.T) {
+	secret := func(data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SigningSecret, Namespace: DefaultChainsNamespace},
+			Data:       data,
+		}
+	}
+	tests := []struct {
+		name     string
+		existing []runtime.Object
+		args     []string
+		wantErr  bool
+	}{
+		{
+			name: "no secret",
+		},
+		{
+			name:     "empty secret",
+			existing: []runtime.Object{secret(nil)},
+		},
+		{
+			name:     "secret with keys",
+			existing: []runtime.Object{secret(map[string][]byte{x509Key: []byte("key")})},
+			wantErr:  true,
+		},
+		{
+			name:     "replace keys",
+			existing: []runtime.Object{secret(map[string][]byte{x509Key: []byte("key"), "other": []byte("kept")})},
+			args:     []string{"--force"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kc := fakekube.NewSimpleClientset(tt.existing...)
+			o := &options{chainsNamespace: DefaultChainsNamespace, kubeClient: kc, pipelineClient: fakepipeline.NewSimpleClientset()}
+			out, err := runCommand(o, append([]string{"keys", "generate"}, tt.args...)...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("keys generate error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			got, err := kc.CoreV1().Secrets(DefaultChainsNamespace).Get(context.Background(), SigningSecret, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := got.Data[x509Key]; ok {
+				t.Errorf("expected %s to be removed", x509Key)
+			}
+			if !bytes.Equal(got.Data[cosignPub], []byte(out)) {
+				t.Errorf("printed public key %q, stored %q", out, got.Data[cosignPub])
+			}
+
+			// The controller can sign with the stored keys.
+			dir := t.TempDir()
+			for k, v := range got.Data {
+				if err := ioutil.WriteFile(filepath.Join(dir, k), v, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			signer, err := x509.NewSigner(dir, config.Config{}, logtesting.TestLogger(t))
+			if err != nil {
+				t.Fatalf("loading signer: %v", err)
+			}
+			pub, err := signer.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubPEM, err := cryptoutils.MarshalPublicKeyToPEM(pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(pubPEM, []byte(out)) {
+				t.Errorf("printed public key %q doesn't match the private key's %q", out, pubPEM)
+			}
+		})
+	}
+}
+
+func TestKeysGenerate_passwordStdin(t *testing.T) {
+	kc := fakekube.NewSimpleClientset()
+	cmd := newRootCommand(&options{chainsNamespace: DefaultChainsNamespace, kubeClient: kc, pipelineClient: fakepipeline.NewSimpleClientset()})
+	cmd.SetIn(strings.NewReader("hunter2\n"))
+	cmd.SetOut(ioutil.Discard)
+	cmd.SetArgs([]string{"keys", "generate", "--password-stdin"})
+	if err := cmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := kc.CoreV1().Secrets(DefaultChainsNamespace).Get(context.Background(), SigningSecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.Data[cosignPassword]) != "hunter2" {
+		t.Errorf("password = %q, want hunter2", got.Data[cosignPassword])
+	}
+}
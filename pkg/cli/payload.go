@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"knative.dev/pkg/logging"
+)
+
+type payloadOptions struct {
+	*options
+	file       string
+	format     string
+	configFile string
+}
+
+func newPayloadCommand(o *options) *cobra.Command {
+	po := &payloadOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "payload",
+		Short: "Print the payload Chains would sign for a TaskRun manifest, without a cluster or keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if po.file == "" {
+				return errors.New("--file is required")
+			}
+			return po.payload(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVarP(&po.file, "file", "f", "", "TaskRun manifest, in YAML or JSON, or - to read it from stdin.")
+	cmd.Flags().StringVar(&po.format, "format", "", fmt.Sprintf("Payload format, one of %v. Defaults to artifacts.taskrun.format.", formats.AllFormatters))
+	cmd.Flags().StringVar(&po.configFile, "config", "", "chains-config manifest to take the format and other settings from. Defaults to the default configuration.")
+	return cmd
+}
+
+func (po *payloadOptions) payload(ctx context.Context, in io.Reader, w io.Writer) error {
+	data := map[string]string{}
+	if po.configFile != "" {
+		cm, err := readConfigMap(po.configFile)
+		if err != nil {
+			return err
+		}
+		data = cm.Data
+	}
+	cfg, err := config.NewConfigFromMap(data)
+	if err != nil {
+		return err
+	}
+	format := formats.PayloadType(cfg.Artifacts.TaskRuns.Format)
+	if po.format != "" {
+		format = formats.PayloadType(po.format)
+	}
+
+	tr, err := po.readTaskRun(in)
+	if err != nil {
+		return err
+	}
+	payload, err := chains.CreatePayload(*cfg, format, tr, logging.FromContext(po.context(ctx, cfg)))
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	printJSON(w, b)
+	return nil
+}
+
+// readTaskRun reads the TaskRun manifest from the file, or from in.
+func (po *payloadOptions) readTaskRun(in io.Reader) (*v1beta1.TaskRun, error) {
+	if po.file != "-" {
+		f, err := os.Open(po.file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		in = f
+	}
+	tr := &v1beta1.TaskRun{}
+	if err := yaml.NewYAMLOrJSONDecoder(in, 4096).Decode(tr); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", po.file)
+	}
+	if tr.Kind != "" && tr.Kind != "TaskRun" {
+		return nil, fmt.Errorf("%s holds a %s, not a TaskRun", po.file, tr.Kind)
+	}
+	return tr, nil
+}
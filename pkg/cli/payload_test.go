@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTaskRun = `apiVersion: tekton.dev/v1beta1
+kind: TaskRun
+metadata:
+  name: build-push-run
+  namespace: default
+spec:
+  params:
+  - name: IMAGE
+    value: gcr.io/foo/bar
+status:
+  taskResults:
+  - name: IMAGE_URL
+    value: gcr.io/foo/bar
+  - name: IMAGE_DIGEST
+    value: sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5
+`
+
+func TestPayload(t *testing.T) {
+	dir := t.TempDir()
+	taskRunFile := filepath.Join(dir, "taskrun.yaml")
+	if err := ioutil.WriteFile(taskRunFile, []byte(testTaskRun), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "chains-config.yaml")
+	if err := ioutil.WriteFile(configFile, []byte(`kind: ConfigMap
+data:
+  artifacts.taskrun.format: in-toto
+  builder.id: https://example.com/builder
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "default format",
+			args: []string{"-f", taskRunFile},
+			want: []string{`"name": "IMAGE_DIGEST"`},
+		},
+		{
+			name: "in-toto",
+			args: []string{"-f", taskRunFile, "--format", "in-toto"},
+			want: []string{`"name": "gcr.io/foo/bar"`, `"sha256": "05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"`},
+		},
+		{
+			name: "config",
+			args: []string{"-f", taskRunFile, "--config", configFile},
+			want: []string{`"id": "https://example.com/builder"`},
+		},
+		{
+			name:  "stdin",
+			args:  []string{"-f", "-", "--format", "in-toto"},
+			stdin: testTaskRun,
+			want:  []string{`"name": "gcr.io/foo/bar"`},
+		},
+		{
+			name:    "unknown format",
+			args:    []string{"-f", taskRunFile, "--format", "slsa/v1"},
+			wantErr: true,
+		},
+		{
+			name:    "not a taskrun",
+			args:    []string{"-f", configFile},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newRootCommand(&options{})
+			var out bytes.Buffer
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetOut(&out)
+			cmd.SetArgs(append([]string{"payload"}, tt.args...))
+			err := cmd.ExecuteContext(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("payload error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output %q doesn't contain %q", out.String(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/rekor/pkg/generated/client/entries"
+	"github.com/sigstore/rekor/pkg/generated/models"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/config"
+	"knative.dev/pkg/logging"
+)
+
+// tlogEntry finds the transparency log entry of a verified payload. The inclusion proof of the
+// entry and its signed entry timestamp are verified against the log's public key.
+func tlogEntry(ctx context.Context, rc *client.Rekor, logKey *ecdsa.PublicKey, cfg *config.Config, p chains.VerifiedPayload, v signature.Verifier) (*models.LogEntryAnon, error) {
+	pubOrCert, err := publicKeyOrCert(p, v)
+	if err != nil {
+		return nil, err
+	}
+	// The payload was uploaded as the kind of entry configured for its format. See the
+	// controller's UploadTlog.
+	proposed, err := chains.NewTlogEntry(cfg.Transparency.EntryType(string(p.Format)), p.Signature, p.Payload, pubOrCert)
+	if err != nil {
+		return nil, err
+	}
+	uuid, err := findTlogEntry(ctx, rc, proposed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "finding transparency log entry of %s", p.Key)
+	}
+	entry, err := chains.GetVerifiedTlogEntry(ctx, rc, uuid, logKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "verifying transparency log entry %s", uuid)
+	}
+	if entry.LogIndex == nil || entry.IntegratedTime == nil {
+		return nil, fmt.Errorf("transparency log entry %s is incomplete", uuid)
+	}
+	return entry, nil
+}
+
+// logPublicKey returns the public key of the transparency log at url, trusted the way the
+// controller trusts it: with the public key or TUF root in the signing secret, if it has one.
+// Without access to the secret, e.g. when verifying with --key, only the key of the public log is
+// trusted, through the Sigstore TUF root. Any other log is asked for its key, with a warning.
+func (o *options) logPublicKey(ctx context.Context, rc *client.Rekor, cfg *config.Config, url string) (*ecdsa.PublicKey, error) {
+	secret, err := o.signingSecret(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Can't read the signing secret, so the key of transparency log %s isn't read from it: %v", url, err)
+		return chains.LogPublicKey(ctx, rc, cfg.Transparency, url, "")
+	}
+	var key *ecdsa.PublicKey
+	err = withSecretDir(secret, func(dir string) error {
+		key, err = chains.LogPublicKey(ctx, rc, cfg.Transparency, url, dir)
+		return err
+	})
+	return key, err
+}
+
+// findTlogEntry searches the log for an entry, and returns its UUID.
+func findTlogEntry(ctx context.Context, rc *client.Rekor, proposed models.ProposedEntry) (string, error) {
+	query := &models.SearchLogQuery{}
+	query.SetEntries([]models.ProposedEntry{proposed})
+	params := entries.NewSearchLogQueryParamsWithContext(ctx)
+	params.SetEntry(query)
+	resp, err := rc.Entries.SearchLogQuery(params)
+	if err != nil {
+		return "", errors.Wrap(err, "searching transparency log")
+	}
+	if len(resp.Payload) != 1 || len(resp.Payload[0]) != 1 {
+		return "", fmt.Errorf("no %s entry found in transparency log", proposed.Kind())
+	}
+	for uuid := range resp.Payload[0] {
+		return uuid, nil
+	}
+	return "", nil
+}
+
+// publicKeyOrCert returns what the controller uploaded alongside the signature: the Fulcio
+// certificate if one was stored, otherwise the public key.
+func publicKeyOrCert(p chains.VerifiedPayload, v signature.Verifier) ([]byte, error) {
+	if p.Cert != "" {
+		return []byte(p.Cert), nil
+	}
+	pub, err := v.PublicKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting public key")
+	}
+	return cryptoutils.MarshalPublicKeyToPEM(pub)
+}
+
+func printTlogEntry(w io.Writer, logIndex, integratedTime int64) {
+	fmt.Fprintf(w, "Transparency log index %d, integrated at %s\n", logIndex, time.Unix(integratedTime, 0).UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/merkle/rfc6962"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+)
+
+// fakeRekor serves a transparency log holding a single entry of the given kind.
+func fakeRekor(t *testing.T, kind string, tamper bool) *httptest.Server {
+	t.Helper()
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPub, err := cryptoutils.MarshalPublicKeyToPEM(logKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// In a log of one entry, the root hash is the leaf hash of the entry, which is also its UUID.
+	raw := []byte(`{"kind":"` + kind + `"}`)
+	uuid := hex.EncodeToString(rfc6962.DefaultHasher.HashLeaf(raw))
+	body := base64.StdEncoding.EncodeToString(raw)
+	// The signed entry timestamp is over the canonical JSON of these fields, which is sorted by key.
+	set, err := json.Marshal(map[string]interface{}{
+		"body":           body,
+		"integratedTime": 1637000000,
+		"logID":          "log",
+		"logIndex":       42,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tamper {
+		set = []byte(strings.Replace(string(set), "42", "43", 1))
+	}
+	digest := sha256.Sum256(set)
+	setSig, err := ecdsa.SignASN1(rand.Reader, logKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := map[string]interface{}{
+		uuid: map[string]interface{}{
+			"body":           body,
+			"integratedTime": 1637000000,
+			"logID":          "log",
+			"logIndex":       42,
+			"verification": map[string]interface{}{
+				"inclusionProof": map[string]interface{}{
+					"hashes":   []string{},
+					"logIndex": 0,
+					"rootHash": uuid,
+					"treeSize": 1,
+				},
+				"signedEntryTimestamp": setSig,
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/log/publicKey", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write(logPub)
+	})
+	mux.HandleFunc("/api/v1/log/entries/retrieve", func(w http.ResponseWriter, r *http.Request) {
+		query := struct {
+			Entries []struct {
+				Kind string `json:"kind"`
+			} `json:"entries"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil || len(query.Entries) != 1 || query.Entries[0].Kind != kind {
+			t.Errorf("searched for %+v (%v), want a %s entry", query, err, kind)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode([]interface{}{entry})
+	})
+	mux.HandleFunc("/api/v1/log/entries/"+uuid, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestVerifyTaskRun_rekor(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		config  map[string]string
+		kind    string
+		tamper  bool
+		wantErr string
+	}{
+		{name: "rekord", format: "tekton", kind: "rekord"},
+		{name: "intoto", format: "in-toto", kind: "intoto"},
+		{name: "dsse", format: "in-toto", config: map[string]string{"transparency.entry.in-toto": "dsse"}, kind: "dsse"},
+		{name: "hashedrekord", format: "tekton", config: map[string]string{"transparency.entry.tekton": "hashedrekord"}, kind: "hashedrekord"},
+		{name: "bad signed entry timestamp", format: "in-toto", kind: "intoto", tamper: true, wantErr: "verifying signed entry timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := signedTaskRunWithConfig(t, tt.format, tt.config)
+			rekor := fakeRekor(t, tt.kind, tt.tamper)
+			out, err := runCommand(o, "verify", "taskrun", "foo", "-n", "default", "--rekor", "--rekor-url", rekor.URL)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("verify --rekor error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verify --rekor error = %v", err)
+			}
+			if want := "Transparency log index 42, integrated at 2021-11-15T18:13:20Z"; !strings.Contains(out, want) {
+				t.Errorf("output %q doesn't contain %q", out, want)
+			}
+		})
+	}
+}
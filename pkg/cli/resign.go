@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// listPageSize is how many TaskRuns are listed at a time.
+const listPageSize = 500
+
+type resignOptions struct {
+	*options
+	selector      string
+	allNamespaces bool
+}
+
+func newResignCommand(o *options) *cobra.Command {
+	ro := &resignOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "resign",
+		Short: "Have Chains sign objects again, for example after rotating keys or upgrading formats",
+	}
+	taskRunCmd := &cobra.Command{
+		Use:   "taskrun [NAME...]",
+		Short: "Have TaskRuns signed again, by name or by label selector",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 0) == (ro.selector == "") {
+				return errors.New("pass either the names of TaskRuns or a --selector")
+			}
+			return ro.resign(cmd.Context(), cmd.OutOrStdout(), args)
+		},
+	}
+	taskRunCmd.Flags().StringVarP(&ro.selector, "selector", "l", "", "Label selector of the TaskRuns to sign again.")
+	taskRunCmd.Flags().BoolVarP(&ro.allNamespaces, "all-namespaces", "A", false, "Sign the TaskRuns that match --selector in every namespace.")
+	cmd.AddCommand(taskRunCmd)
+	return cmd
+}
+
+func (ro *resignOptions) resign(ctx context.Context, w io.Writer, names []string) error {
+	ns, err := ro.namespace()
+	if err != nil {
+		return err
+	}
+	if ro.allNamespaces {
+		ns = ""
+	}
+	taskRuns, err := ro.taskRuns(ctx, ns, names, ro.selector)
+	if err != nil {
+		return err
+	}
+	_, pc, err := ro.clients()
+	if err != nil {
+		return err
+	}
+
+	var merr *multierror.Error
+	for i := range taskRuns {
+		tr := &taskRuns[i]
+		switch {
+		case chains.ResignRequested(tr):
+			fmt.Fprintf(w, "taskrun %s/%s is already waiting to be signed again\n", tr.Namespace, tr.Name)
+		case !chains.Reconciled(tr):
+			// The controller signs it anyway once it completes.
+			fmt.Fprintf(w, "taskrun %s/%s skipped, it hasn't been signed yet\n", tr.Namespace, tr.Name)
+		default:
+			if err := chains.RequestResign(tr, pc); err != nil {
+				merr = multierror.Append(merr, errors.Wrapf(err, "taskrun %s/%s", tr.Namespace, tr.Name))
+				continue
+			}
+			fmt.Fprintf(w, "taskrun %s/%s will be signed again\n", tr.Namespace, tr.Name)
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+// taskRuns gets the named TaskRuns, or lists the ones matching selector.
+// Without names or a selector, it lists every TaskRun in the namespace.
+func (o *options) taskRuns(ctx context.Context, namespace string, names []string, selector string) ([]v1beta1.TaskRun, error) {
+	_, pc, err := o.clients()
+	if err != nil {
+		return nil, err
+	}
+	client := pc.TektonV1beta1().TaskRuns(namespace)
+	taskRuns := []v1beta1.TaskRun{}
+	if len(names) > 0 {
+		for _, name := range names {
+			tr, err := client.Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			taskRuns = append(taskRuns, *tr)
+		}
+		return taskRuns, nil
+	}
+
+	opts := metav1.ListOptions{LabelSelector: selector, Limit: listPageSize}
+	for {
+		list, err := client.List(ctx, opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "listing taskruns")
+		}
+		taskRuns = append(taskRuns, list.Items...)
+		if list.Continue == "" {
+			return taskRuns, nil
+		}
+		opts.Continue = list.Continue
+	}
+}
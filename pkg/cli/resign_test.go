@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakepipeline "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResignTaskRun(t *testing.T) {
+	taskRun := func(namespace, name, state string, labels map[string]string) *v1beta1.TaskRun {
+		tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}}
+		if state != "" {
+			tr.Annotations = map[string]string{chains.ChainsAnnotation: state}
+		}
+		return tr
+	}
+	app := map[string]string{"app": "foo"}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "by name",
+			args: []string{"signed", "failed"},
+			want: []string{"default/signed", "default/failed"},
+		},
+		{
+			name: "unsigned taskruns are left alone",
+			args: []string{"signed", "unsigned"},
+			want: []string{"default/signed"},
+		},
+		{
+			name: "by selector",
+			args: []string{"-l", "app=foo"},
+			want: []string{"default/signed"},
+		},
+		{
+			name: "by selector in all namespaces",
+			args: []string{"-l", "app=foo", "-A"},
+			want: []string{"default/signed", "other/signed"},
+		},
+		{
+			name:    "missing taskrun",
+			args:    []string{"missing"},
+			wantErr: true,
+		},
+		{
+			name:    "no taskruns",
+			wantErr: true,
+		},
+		{
+			name:    "names and selector",
+			args:    []string{"signed", "-l", "app=foo"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := fakepipeline.NewSimpleClientset(
+				taskRun("default", "signed", "true", app),
+				taskRun("default", "failed", "failed", nil),
+				taskRun("default", "unsigned", "", app),
+				taskRun("other", "signed", "true", app),
+			)
+			o := &options{kubeClient: fakekube.NewSimpleClientset(), pipelineClient: pc}
+			_, err := runCommand(o, append([]string{"resign", "taskrun", "-n", "default"}, tt.args...)...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resign error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := map[string]bool{}
+			for _, key := range tt.want {
+				want[key] = true
+			}
+			list, err := pc.TektonV1beta1().TaskRuns("").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range list.Items {
+				tr := &list.Items[i]
+				key := tr.Namespace + "/" + tr.Name
+				if got := chains.ResignRequested(tr); got != want[key] {
+					t.Errorf("taskrun %s resign requested = %t, want %t", key, got, want[key])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cli implements the chainsctl commands.
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/chains/pkg/reconciler/chainsconfig"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"knative.dev/pkg/logging"
+)
+
+const (
+	// DefaultChainsNamespace is the namespace Chains is installed in by the release manifests.
+	DefaultChainsNamespace = "tekton-chains"
+	// SigningSecret is the name of the secret the controller reads its keys from.
+	SigningSecret = "signing-secrets"
+	// ControllerServiceAccount is the service account the controller runs as.
+	ControllerServiceAccount = "tekton-chains-controller"
+)
+
+// options holds the flags and clients shared by every command.
+type options struct {
+	kubeconfig      string
+	overrides       clientcmd.ConfigOverrides
+	chainsNamespace string
+	verbose         bool
+
+	// The clients are created from the flags on first use, unless they are already set.
+	// Without a dynamic client, the configuration is only read from the chains-config ConfigMap.
+	kubeClient     kubernetes.Interface
+	pipelineClient versioned.Interface
+	dynamicClient  dynamic.Interface
+}
+
+// pluginHosts are the CLIs chainsctl can be installed as a plugin of, by naming it <host>-chains.
+var pluginHosts = []string{"kubectl", "tkn"}
+
+// NewRootCommand returns the chainsctl command. When the binary is installed as a kubectl or tkn
+// plugin, the command is nested under one for the host, so usage and help read "kubectl chains ...".
+func NewRootCommand() *cobra.Command {
+	return newPluginCommand(filepath.Base(os.Args[0]), os.Args[1:], &options{})
+}
+
+func newPluginCommand(binary string, args []string, o *options) *cobra.Command {
+	cmd := newRootCommand(o)
+	host := pluginHost(binary)
+	if host == "" {
+		cmd.SetArgs(args)
+		return cmd
+	}
+	cmd.Use = "chains"
+	root := &cobra.Command{
+		Use:           host,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		// The host completes the commands of its plugins itself.
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+	}
+	root.AddCommand(cmd)
+	root.SetArgs(append([]string{cmd.Use}, args...))
+	return root
+}
+
+// pluginHost returns the CLI the binary is a plugin of, if any.
+func pluginHost(binary string) string {
+	binary = strings.TrimSuffix(binary, ".exe")
+	for _, host := range pluginHosts {
+		if binary == host+"-chains" {
+			return host
+		}
+	}
+	return ""
+}
+
+func newRootCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "chainsctl",
+		Short:         "Inspect and manage the signatures and attestations Tekton Chains creates",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	clientcmd.BindOverrideFlags(&o.overrides, flags, clientcmd.ConfigOverrideFlags{
+		CurrentContext: clientcmd.FlagInfo{LongName: "context", Description: "The name of the kubeconfig context to use."},
+		ContextOverrideFlags: clientcmd.ContextOverrideFlags{
+			Namespace: clientcmd.FlagInfo{LongName: "namespace", ShortName: "n", Description: "The namespace of the TaskRuns."},
+		},
+	})
+	flags.StringVar(&o.chainsNamespace, "chains-namespace", DefaultChainsNamespace, "The namespace Chains is installed in.")
+	flags.BoolVarP(&o.verbose, "verbose", "v", false, "Log what is being done.")
+
+	cmd.AddCommand(
+		newVerifyCommand(o),
+		newResignCommand(o),
+		newExportCommand(o),
+		newDescribeCommand(o),
+		newPayloadCommand(o),
+		newDriftCommand(o),
+		newKeysCommand(o),
+		newConfigCommand(o),
+	)
+	return cmd
+}
+
+func (o *options) clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &o.overrides)
+}
+
+// namespace returns the namespace of the TaskRuns: the --namespace flag, or the namespace of the current context.
+func (o *options) namespace() (string, error) {
+	ns, _, err := o.clientConfig().Namespace()
+	return ns, err
+}
+
+func (o *options) clients() (kubernetes.Interface, versioned.Interface, error) {
+	if o.kubeClient != nil && o.pipelineClient != nil {
+		return o.kubeClient, o.pipelineClient, nil
+	}
+	cfg, err := o.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "loading kubeconfig")
+	}
+	if o.kubeClient, err = kubernetes.NewForConfig(cfg); err != nil {
+		return nil, nil, err
+	}
+	if o.pipelineClient, err = versioned.NewForConfig(cfg); err != nil {
+		return nil, nil, err
+	}
+	if o.dynamicClient, err = dynamic.NewForConfig(cfg); err != nil {
+		return nil, nil, err
+	}
+	return o.kubeClient, o.pipelineClient, nil
+}
+
+// chainsConfig loads the configuration of the Chains installation, as the controller reads
+// it: from the ChainsConfig if there is one, and the chains-config ConfigMap otherwise.
+func (o *options) chainsConfig(ctx context.Context) (*config.Config, error) {
+	kc, _, err := o.clients()
+	if err != nil {
+		return nil, err
+	}
+	return chainsconfig.Load(ctx, o.dynamicClient, kc, o.chainsNamespace, nil)
+}
+
+func (o *options) signingSecret(ctx context.Context) (*corev1.Secret, error) {
+	kc, _, err := o.clients()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := kc.CoreV1().Secrets(o.chainsNamespace).Get(ctx, SigningSecret, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting %s/%s", o.chainsNamespace, SigningSecret)
+	}
+	return secret, nil
+}
+
+// withSecretDir writes the secret to a temporary directory laid out like the
+// mounted signing secrets of the controller, and calls f with it.
+func withSecretDir(secret *corev1.Secret, f func(dir string) error) error {
+	dir, err := ioutil.TempDir("", "chainsctl")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	for k, v := range secret.Data {
+		if err := ioutil.WriteFile(filepath.Join(dir, k), v, 0600); err != nil {
+			return err
+		}
+	}
+	return f(dir)
+}
+
+// context returns a context carrying the chains config and a logger. Without --verbose, only
+// warnings are logged, e.g. that a transparency log's key isn't trusted.
+func (o *options) context(ctx context.Context, cfg *config.Config) context.Context {
+	zc := zap.NewDevelopmentConfig()
+	if !o.verbose {
+		zc.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+		zc.EncoderConfig.TimeKey = ""
+		zc.EncoderConfig.CallerKey = ""
+		zc.DisableStacktrace = true
+	}
+	logger := zap.NewNop().Sugar()
+	if l, err := zc.Build(); err == nil {
+		logger = l.Sugar()
+	}
+	return config.ToContext(logging.WithLogger(ctx, logger), cfg)
+}
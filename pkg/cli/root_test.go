@@ -0,0 +1,46 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPluginCommand(t *testing.T) {
+	tests := []struct {
+		binary string
+		want   string
+	}{
+		{binary: "chainsctl", want: "chainsctl verify [command]"},
+		{binary: "kubectl-chains", want: "kubectl chains verify [command]"},
+		{binary: "kubectl-chains.exe", want: "kubectl chains verify [command]"},
+		{binary: "tkn-chains", want: "tkn chains verify [command]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.binary, func(t *testing.T) {
+			cmd := newPluginCommand(tt.binary, []string{"verify", "--help"}, &options{})
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			if err := cmd.ExecuteContext(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("help %q doesn't contain %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+	rekorclient "github.com/sigstore/rekor/pkg/client"
+	"github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/spf13/cobra"
+	"github.com/tektoncd/chains/pkg/artifacts"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/formats"
+	"github.com/tektoncd/chains/pkg/chains/signing"
+	"github.com/tektoncd/chains/pkg/chains/signing/kms"
+	"github.com/tektoncd/chains/pkg/chains/signing/x509"
+	"github.com/tektoncd/chains/pkg/chains/storage/oci"
+	"github.com/tektoncd/chains/pkg/chains/verify"
+	"github.com/tektoncd/chains/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+type verifyOptions struct {
+	*options
+	key              string
+	countersignerKey string
+	rekor            bool
+	rekorURL         string
+}
+
+func newVerifyCommand(o *options) *cobra.Command {
+	vo := &verifyOptions{options: o}
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the signatures Chains created and print what was signed",
+	}
+	cmd.PersistentFlags().StringVar(&vo.key, "key", "", "Public key to verify with: a PEM file such as cosign.pub, or a KMS reference. Defaults to the keys Chains is configured with.")
+	cmd.PersistentFlags().StringVar(&vo.countersignerKey, "countersigner-key", "", "Public key to verify the countersignatures of signers.countersigner with. Defaults to --key, or the key Chains is configured with.")
+	cmd.PersistentFlags().BoolVar(&vo.rekor, "rekor", false, "Also check that the signatures are in the transparency log, and print their log entries.")
+	cmd.PersistentFlags().StringVar(&vo.rekorURL, "rekor-url", "", "Transparency log to check. Defaults to transparency.url in chains-config.")
+	cmd.AddCommand(&cobra.Command{
+		Use:   "taskrun NAME",
+		Short: "Verify the payloads stored for a TaskRun, and the images it built",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return vo.verifyTaskRun(cmd.Context(), cmd.OutOrStdout(), args[0])
+		},
+	}, &cobra.Command{
+		Use:   "image REFERENCE",
+		Short: "Verify the signatures and attestations Chains stored for an image",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref, err := name.ParseReference(args[0])
+			if err != nil {
+				return err
+			}
+			cfg, err := vo.chainsConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+			ctx := vo.context(cmd.Context(), cfg)
+			v, err := vo.verifier(ctx, cfg, cfg.Artifacts.OCI.Signer)
+			if err != nil {
+				return err
+			}
+			return verifyImage(ctx, cmd.OutOrStdout(), ref, formats.PayloadType(cfg.Artifacts.OCI.Format), v, vo.transparencyURL(cfg))
+		},
+	})
+	return cmd
+}
+
+func (vo *verifyOptions) verifyTaskRun(ctx context.Context, w io.Writer, taskRun string) error {
+	ns, err := vo.namespace()
+	if err != nil {
+		return err
+	}
+	kc, pc, err := vo.clients()
+	if err != nil {
+		return err
+	}
+	tr, err := pc.TektonV1beta1().TaskRuns(ns).Get(ctx, taskRun, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if tr.Annotations[chains.ChainsAnnotation] != "true" {
+		return fmt.Errorf("taskrun %s/%s has not been signed", tr.Namespace, tr.Name)
+	}
+	cfg, err := vo.chainsConfig(ctx)
+	if err != nil {
+		return err
+	}
+	ctx = vo.context(ctx, cfg)
+
+	verifiers := map[string]signature.Verifier{}
+	for _, signerType := range []string{cfg.Artifacts.TaskRuns.Signer, cfg.Artifacts.OCI.Signer} {
+		if _, ok := verifiers[signerType]; ok {
+			continue
+		}
+		v, err := vo.verifier(ctx, cfg, signerType)
+		if err != nil {
+			return err
+		}
+		verifiers[signerType] = v
+	}
+	if s := cfg.Signers.Countersigner; s != "" {
+		if _, ok := verifiers[s]; !ok || vo.countersignerKey != "" {
+			v, err := vo.countersignerVerifier(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			verifiers[s] = v
+		}
+	}
+
+	payloads, err := verify.VerifyTaskRun(ctx, tr, verify.TaskRunOptions{
+		KubeClient:     kc,
+		PipelineClient: pc,
+		Verifiers:      verifiers,
+	})
+	if err != nil {
+		return err
+	}
+	var rc *client.Rekor
+	var logKey *ecdsa.PublicKey
+	if vo.rekor {
+		url := vo.transparencyURL(cfg)
+		if rc, err = rekorclient.GetRekorClient(url); err != nil {
+			return errors.Wrap(err, "creating transparency log client")
+		}
+		if logKey, err = vo.logPublicKey(ctx, rc, cfg, url); err != nil {
+			return err
+		}
+	}
+	for _, p := range payloads {
+		fmt.Fprintf(w, "Verified %s (%s)\n", p.Key, p.Format)
+		if p.Countersigner != "" {
+			fmt.Fprintf(w, "Verified the countersignature of %s by %s\n", p.Key, p.Countersigner)
+		}
+		if rc != nil {
+			entry, err := tlogEntry(ctx, rc, logKey, cfg, p, verifiers[p.Signer])
+			if err != nil {
+				return err
+			}
+			printTlogEntry(w, *entry.LogIndex, *entry.IntegratedTime)
+		}
+		printJSON(w, p.Payload)
+	}
+
+	if cfg.Artifacts.OCI.StorageBackend != oci.StorageBackendOCI {
+		return nil
+	}
+	// Images are verified with the certificates attached to their signatures when Fulcio is used.
+	v, err := vo.verifier(ctx, cfg, cfg.Artifacts.OCI.Signer)
+	if err != nil {
+		return err
+	}
+	for _, obj := range (&artifacts.OCIArtifact{Logger: logging.FromContext(ctx)}).ExtractObjects(tr) {
+		if err := verifyImage(ctx, w, obj.(name.Digest), formats.PayloadType(cfg.Artifacts.OCI.Format), v, vo.transparencyURL(cfg)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifier returns what signatures made by the signerType are verified with.
+// It returns nil for Fulcio signatures, which are verified with the certificates stored with
+// them once they are checked against the Fulcio roots.
+// signerType is x509, kms or the name of a signer profile.
+func (vo *verifyOptions) verifier(ctx context.Context, cfg *config.Config, signerType string) (signature.Verifier, error) {
+	profile, signerCfg := cfg.ResolveSigner(signerType)
+	switch {
+	case vo.key != "":
+		return verify.LoadPublicKey(ctx, vo.key)
+	case profile.Type == signing.TypeKMS:
+		return kms.NewSigner(signerCfg.Signers.KMS, logging.FromContext(ctx))
+	case signerCfg.Signers.X509.FulcioEnabled:
+		return nil, nil
+	default:
+		return vo.secretVerifier(ctx, signerCfg, profile.KeyPrefix())
+	}
+}
+
+// countersignerVerifier returns what the countersignatures of signers.countersigner are verified
+// with.
+func (vo *verifyOptions) countersignerVerifier(ctx context.Context, cfg *config.Config) (signature.Verifier, error) {
+	if vo.countersignerKey != "" {
+		return verify.LoadPublicKey(ctx, vo.countersignerKey)
+	}
+	return vo.verifier(ctx, cfg, cfg.Signers.Countersigner)
+}
+
+// secretVerifier loads the x509 signer from the signing secrets the same way the controller does,
+// or just the public key if cosign.pub was stored alongside the private key. The names of the
+// files of signer profiles start with prefix.
+func (o *options) secretVerifier(ctx context.Context, cfg config.Config, prefix string) (signature.Verifier, error) {
+	secret, err := o.signingSecret(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "pass --key to verify with a public key instead")
+	}
+	if pub := secret.Data[prefix+cosignPub]; len(pub) > 0 {
+		pk, err := cryptoutils.UnmarshalPEMToPublicKey(pub)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s%s", prefix, cosignPub)
+		}
+		return signature.LoadVerifier(pk, crypto.SHA256)
+	}
+
+	var signer signature.Verifier
+	err = withSecretDir(secret, func(dir string) error {
+		signer, err = x509.NewSignerWithPrefix(dir, prefix, cfg, logging.FromContext(ctx))
+		return err
+	})
+	return signer, err
+}
+
+// verifyImage verifies the signatures, or the attestations for in-toto formats, stored next to an image.
+// When rekorURL is set, they must also be in that transparency log.
+func verifyImage(ctx context.Context, w io.Writer, ref name.Reference, format formats.PayloadType, v signature.Verifier, rekorURL string) error {
+	opts := verify.ImageOptions{Verifier: v, RekorURL: rekorURL}
+	verifyFunc := verify.VerifyImageSignatures
+	if format == formats.PayloadTypeInTotoIte6 {
+		verifyFunc = verify.VerifyImageAttestation
+	}
+	payloads, err := verifyFunc(ctx, ref, opts)
+	if err != nil {
+		return errors.Wrapf(err, "verifying %s", ref)
+	}
+	for _, p := range payloads {
+		fmt.Fprintf(w, "Verified image %s (%s)\n", ref, format)
+		// Signatures without a bundle were looked up in the log by cosign, which doesn't return the entry.
+		if rekorURL != "" && p.Bundle != nil {
+			printTlogEntry(w, p.Bundle.Payload.LogIndex, p.Bundle.Payload.IntegratedTime)
+		}
+		printJSON(w, p.Payload)
+	}
+	return nil
+}
+
+// transparencyURL returns the transparency log to check signatures against, if --rekor was passed.
+func (vo *verifyOptions) transparencyURL(cfg *config.Config) string {
+	if !vo.rekor {
+		return ""
+	}
+	if vo.rekorURL != "" {
+		return vo.rekorURL
+	}
+	return cfg.Transparency.URL
+}
+
+// printJSON indents JSON payloads, and prints anything else as it is.
+func printJSON(w io.Writer, payload []byte) {
+	var b bytes.Buffer
+	if err := json.Indent(&b, payload, "", "  "); err != nil {
+		fmt.Fprintln(w, string(payload))
+		return
+	}
+	fmt.Fprintln(w, b.String())
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	cx509 "crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakepipeline "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"knative.dev/pkg/controller"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+// testKeys returns a PEM encoded private key in the format the controller reads, and its public key.
+func testKeys(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := cx509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := cryptoutils.MarshalPublicKeyToPEM(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), pub
+}
+
+// signedTaskRun sets up fake clients with a Chains installation that stores payloads
+// of the given format on TaskRuns, and a TaskRun it has signed.
+func signedTaskRun(t *testing.T, format string) (*options, []byte) {
+	t.Helper()
+	return signedTaskRunWithConfig(t, format, nil)
+}
+
+// signedTaskRunWithConfig is signedTaskRun, with more keys in chains-config.
+func signedTaskRunWithConfig(t *testing.T, format string, extra map[string]string) (*options, []byte) {
+	t.Helper()
+	ctx := logtesting.TestContextWithLogger(t)
+	priv, pub := testKeys(t)
+	data := map[string]string{
+		"artifacts.taskrun.format":  format,
+		"artifacts.taskrun.storage": "tekton",
+		"artifacts.oci.storage":     "tekton",
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	kc := fakekube.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: config.ChainsConfig, Namespace: DefaultChainsNamespace},
+			Data:       data,
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: SigningSecret, Namespace: DefaultChainsNamespace},
+			Data:       map[string][]byte{"x509.pem": priv},
+		},
+	)
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default", UID: types.UID("1234")},
+	}
+	pc := fakepipeline.NewSimpleClientset(tr)
+
+	secretPath := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(secretPath, "x509.pem"), priv, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.NewConfigFromMap(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = config.ToContext(controller.WithEventRecorder(ctx, record.NewFakeRecorder(10)), cfg)
+	ts := &chains.TaskRunSigner{KubeClient: kc, Pipelineclientset: pc, SecretPath: secretPath}
+	if err := ts.SignTaskRun(ctx, tr); err != nil {
+		t.Fatal(err)
+	}
+	return &options{chainsNamespace: DefaultChainsNamespace, kubeClient: kc, pipelineClient: pc}, pub
+}
+
+func runCommand(o *options, args ...string) (string, error) {
+	cmd := newRootCommand(o)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs(args)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+func TestVerifyTaskRun(t *testing.T) {
+	for _, format := range []string{"tekton", "in-toto"} {
+		t.Run(format, func(t *testing.T) {
+			o, pub := signedTaskRun(t, format)
+
+			// With the keys Chains is configured with.
+			out, err := runCommand(o, "verify", "taskrun", "foo", "-n", "default")
+			if err != nil {
+				t.Fatalf("verify error = %v", err)
+			}
+			if want := "Verified taskrun-1234 (" + format + ")"; !strings.Contains(out, want) {
+				t.Errorf("output %q doesn't contain %q", out, want)
+			}
+
+			// With a public key.
+			keyPath := filepath.Join(t.TempDir(), "cosign.pub")
+			if err := ioutil.WriteFile(keyPath, pub, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := runCommand(o, "verify", "taskrun", "foo", "-n", "default", "--key", keyPath); err != nil {
+				t.Errorf("verify --key error = %v", err)
+			}
+
+			// With the wrong public key.
+			_, other := testKeys(t)
+			if err := ioutil.WriteFile(keyPath, other, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := runCommand(o, "verify", "taskrun", "foo", "-n", "default", "--key", keyPath); err == nil {
+				t.Error("expected verifying with the wrong key to fail")
+			}
+		})
+	}
+}
+
+func TestVerifyTaskRun_unsigned(t *testing.T) {
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}}
+	o := &options{
+		chainsNamespace: DefaultChainsNamespace,
+		kubeClient:      fakekube.NewSimpleClientset(),
+		pipelineClient:  fakepipeline.NewSimpleClientset(tr),
+	}
+	_, err := runCommand(o, "verify", "taskrun", "foo", "-n", "default")
+	if err == nil || !strings.Contains(err.Error(), "has not been signed") {
+		t.Errorf("verify error = %v, want not signed", err)
+	}
+}
@@ -18,9 +18,13 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
 	cm "knative.dev/pkg/configmap"
 )
@@ -31,12 +35,30 @@ type Config struct {
 	Signers      SignerConfigs
 	Builder      BuilderConfig
 	Transparency TransparencyConfig
+	Namespaces   NamespaceConfig
+	Filters      FilterConfig
+	Events       EventsConfig
+	GC           GCConfig
+	DeadLetter   DeadLetterConfig
+	DryRun       DryRunConfig
+	Signing      SigningTimeoutConfig
+	VSA          VSAConfig
+	Definitions  DefinitionsConfig
+	SBOM         SBOMConfig
+	Source       SourceConfig
+	Images       ImagesConfig
+	Subjects     SubjectsConfig
+	Redact       RedactConfig
+	Provenance   ProvenanceConfig
+	Bundle       BundleConfig
 }
 
 // ArtifactConfig contains the configuration for how to sign/store/format the signatures for each artifact type
 type ArtifactConfigs struct {
 	TaskRuns Artifact
 	OCI      Artifact
+	// Blobs are only signed when they have a storage backend.
+	Blobs Artifact
 }
 
 // Artifact contains the configuration for how to sign/store/format the signatures for a single artifact
@@ -48,16 +70,126 @@ type Artifact struct {
 
 // StorageConfig contains the configuration to instantiate different storage providers
 type StorageConfigs struct {
-	GCS    GCSStorageConfig
-	OCI    OCIStorageConfig
-	Tekton TektonStorageConfig
-	DocDB  DocDBStorageConfig
+	GCS     GCSStorageConfig
+	OCI     OCIStorageConfig
+	Tekton  TektonStorageConfig
+	DocDB   DocDBStorageConfig
+	Results ResultsStorageConfig
+	// Routes store the payloads of some formats in other backends than those of the artifacts
+	// they are for.
+	Routes []StorageRoute
+}
+
+// StorageRoute stores the payloads of a format in a set of backends, in place of the storage
+// backend of the artifacts they are for.
+type StorageRoute struct {
+	// Format is the payload format, e.g. in-toto.
+	Format string
+	// Backends are the storage backends the payloads are stored in, in order.
+	Backends []string
+	// Transparency uploads the payloads to the transparency log when true, and doesn't when
+	// false, whatever transparency.enabled is. It's left to transparency.enabled when nil.
+	Transparency *bool
+}
+
+// Route returns the route of the payloads of a format, if it has one.
+func (s StorageConfigs) Route(format string) (StorageRoute, bool) {
+	for _, r := range s.Routes {
+		if r.Format == format {
+			return r, true
+		}
+	}
+	return StorageRoute{}, false
+}
+
+// StorageBackends returns the backends the payloads of a format are stored in: those of its
+// route, or else backend, the storage backend of the artifacts they are for.
+func (s StorageConfigs) StorageBackends(format, backend string) []string {
+	if r, ok := s.Route(format); ok {
+		return r.Backends
+	}
+	return []string{backend}
 }
 
 // SigningConfig contains the configuration to instantiate different signers
 type SignerConfigs struct {
 	X509 X509Signer
 	KMS  KMSSigner
+	// Profiles are named signers, which artifacts can be signed with instead of the x509
+	// and kms signers.
+	Profiles []SignerProfile
+	// Namespaces are the signers the chains-config of each namespace can select in place of
+	// the cluster's. The namespaces that aren't listed can't select another signer.
+	Namespaces []NamespaceSigners
+	// Countersigner is the signer, x509, kms or a profile, that countersigns the DSSE
+	// envelopes of the payloads, so they carry the signatures of two keys.
+	Countersigner string
+	// Probe is how the signers in use are checked when a configuration is loaded, before the
+	// controller reports ready: SignerProbePublicKey, SignerProbeSign or SignerProbeNone.
+	Probe string
+}
+
+// The checks of the signer probe: fetching the public key of each signer, also signing a
+// canary payload with it, or not probing the signers at all.
+const (
+	SignerProbePublicKey = "public-key"
+	SignerProbeSign      = "sign"
+	SignerProbeNone      = "none"
+)
+
+// SignerProfile is a named signer of one of the supported types.
+type SignerProfile struct {
+	Name string
+	// Type is x509 or kms.
+	Type string
+	// KMSRef is the key kms profiles sign with.
+	KMSRef string
+	// Fulcio is set for x509 profiles that get their certificates from Fulcio, with the
+	// signers.x509.fulcio settings.
+	Fulcio bool
+}
+
+// NamespaceSigners are the signers the chains-config of a namespace can select.
+type NamespaceSigners struct {
+	Namespace string
+	// Signers are x509, kms or the names of signer profiles.
+	Signers []string
+}
+
+// KeyPrefix is what the names of the files in the signing secret that an x509 profile's
+// keys are read from start with, e.g. release.x509.pem for the profile named release. The
+// x509 signer's files have no prefix.
+func (p SignerProfile) KeyPrefix() string {
+	if p.Name == "" {
+		return ""
+	}
+	return p.Name + "."
+}
+
+// The signers that aren't profiles.
+const (
+	SignerX509 = "x509"
+	SignerKMS  = "kms"
+)
+
+// ResolveSigner returns the signer profile named name, and the Config its signer is created
+// with, which has the profile's settings in place of the x509 or kms signer's. The x509 and
+// kms signers are returned as profiles without a name.
+func (c Config) ResolveSigner(name string) (SignerProfile, Config) {
+	for _, p := range c.Signers.Profiles {
+		if p.Name != name {
+			continue
+		}
+		cfg := *c.DeepCopy()
+		switch p.Type {
+		case SignerX509:
+			cfg.Signers.X509.FulcioEnabled = p.Fulcio
+		case SignerKMS:
+			cfg.Signers.KMS.KMSRef = p.KMSRef
+		}
+		return p, cfg
+	}
+	return SignerProfile{Type: name}, c
 }
 
 type BuilderConfig struct {
@@ -68,32 +200,415 @@ type X509Signer struct {
 	FulcioEnabled bool
 	FulcioAddr    string
 	FulcioAuth    string
+	// FulcioAudience is the audience of the OIDC tokens Fulcio certificates are requested with.
+	FulcioAudience string
+	// SealingKey is the Cloud KMS key the private keys of the signing secret are encrypted with,
+	// if they are. They are only decrypted in memory.
+	SealingKey string
+	// NamespaceSecret is the name of the secret in the namespace of each TaskRun its keys are read
+	// from, so tenants manage their own keys. TaskRuns in namespaces without one are signed with
+	// the keys of the signing secret. Empty reads every key from the signing secret.
+	NamespaceSecret string
 }
 
+// The schemes Fulcio certificates are requested with: the Google identity of the controller,
+// or the service account of each TaskRun.
+const (
+	FulcioAuthGoogle         = "google"
+	FulcioAuthServiceAccount = "serviceaccount"
+)
+
 type KMSSigner struct {
 	KMSRef string
+	// MaxConcurrent is the number of signing calls made to KMSs at the same time, across all
+	// the workers. There is no limit when it's 0.
+	MaxConcurrent int
+	// Vault is how hashivault:// keys log in to Vault.
+	Vault VaultAuth
+	// AWS is how awskms:// keys get their credentials.
+	AWS AWSAuth
+	// GCP is how gcpkms:// keys get their credentials.
+	GCP GCPAuth
+}
+
+// GCPAuth federates the identity of the controller's service account with Google Cloud, so
+// clusters outside of it use Cloud KMS keys without service account keys. Without an Audience,
+// the application default credentials are used.
+type GCPAuth struct {
+	// Audience is the workload identity pool provider the controller's token is exchanged with.
+	Audience string
+	// ServiceAccount is the Google service account impersonated with the federated token, if any.
+	ServiceAccount string
+	// TokenPath is the file of the token that's exchanged.
+	TokenPath string
+}
+
+// The workload identities AWS KMS keys are used with: IAM roles for service accounts, or EKS
+// Pod Identity. Without one, the AWS SDK looks for credentials as usual.
+const (
+	AWSIdentityIRSA        = "irsa"
+	AWSIdentityPodIdentity = "pod-identity"
+)
+
+// AWSAuth is how the controller gets the credentials for AWS KMS keys.
+type AWSAuth struct {
+	// Identity is AWSIdentityIRSA, AWSIdentityPodIdentity, or empty.
+	Identity string
+	// Region is the region of the keys. It's discovered from the ARNs of keys when empty.
+	Region string
+	// RoleARN is the role IRSA assumes, instead of the AWS_ROLE_ARN of the controller.
+	RoleARN string
+	// AssumeRoles are assumed in turn once the identity's credentials are found, each with
+	// the credentials of the one before.
+	AssumeRoles []string
+}
+
+// VaultAuth logs in to Vault with the Kubernetes auth method, as the controller's service
+// account. Without a Role, the VAULT_TOKEN of the controller is used as it is.
+type VaultAuth struct {
+	Role string
+	// Path is the path the Kubernetes auth method is mounted at.
+	Path string
 }
 
 type GCSStorageConfig struct {
 	Bucket string
+	// KMSKey is the Cloud KMS key objects are encrypted with, instead of the default key of
+	// the bucket, e.g. projects/p/locations/l/keyRings/r/cryptoKeys/k.
+	KMSKey string
+	// Hold is GCSHoldTemporary or GCSHoldEventBased to place a hold on the objects written, so
+	// they can't be deleted or replaced until it's released.
+	Hold string
+	// Retention is the shortest retention policy the bucket must have. Nothing is written to a
+	// bucket that keeps objects for less.
+	Retention time.Duration
 }
 
+// The holds objects written to GCS can be placed under.
+const (
+	GCSHoldTemporary  = "temporary"
+	GCSHoldEventBased = "event-based"
+)
+
 type OCIStorageConfig struct {
 	Repository string
 	Insecure   bool
+	// Auth are the sources of the credentials registries are pushed to with, tried in order.
+	// When empty, AuthK8sChain is used.
+	Auth []string
+	// RegistryAuth replaces Auth for the registries it lists.
+	RegistryAuth []RegistryAuth
+	// Proxy is the URL of the proxy registries are connected to through. The proxy of the
+	// HTTPS_PROXY and NO_PROXY environment variables is used when it's empty.
+	Proxy string
+	// CACerts is the path of a file of PEM certificates registries are trusted with, in
+	// addition to the system's.
+	CACerts string
+	// MaxConcurrent is the number of pushes to registries made at the same time, across all
+	// the workers. There is no limit when it's 0.
+	MaxConcurrent int
+	// Registries are the connection settings of the registries they list, in addition to
+	// Proxy and CACerts.
+	Registries []RegistryConnection
+	// Mappings push the signatures and attestations of images to other repositories, in
+	// order. The first that matches a repository is used.
+	Mappings []RepositoryMapping
+}
+
+// RepositoryMapping pushes the signatures and attestations of the images in the repositories
+// a regular expression matches to another repository, e.g. a staging registry that is
+// replicated to a write-restricted production one.
+type RepositoryMapping struct {
+	// Pattern is a regular expression, in the syntax of Go's regexp package, matched against
+	// the repository, e.g. gcr.io/foo/bar.
+	Pattern string
+	// Replacement replaces the matches of Pattern. $1 and ${name} refer to its groups.
+	Replacement string
+	// Also pushes to the repository of the image as well as to the replacement, instead of
+	// only to the replacement.
+	Also bool
 }
 
+// RegistryConnection is how a registry is connected to.
+type RegistryConnection struct {
+	// Host is the registry's host, with its port if it has one, e.g. registry.example.com:5000.
+	Host string
+	// Insecure allows the registry to be connected to over plain HTTP.
+	Insecure bool
+	// SkipTLSVerify doesn't verify the registry's certificate. It's meant for lab environments.
+	SkipTLSVerify bool
+	// CACerts is the path of a file of PEM certificates the registry is trusted with, in
+	// addition to the system's and the ones of OCIStorageConfig.CACerts.
+	CACerts string
+}
+
+// RegistryAuth are the sources of the credentials a registry is pushed to with.
+type RegistryAuth struct {
+	// Host is the registry's host, with its port if it has one, e.g. gcr.io or registry.example.com:5000.
+	Host string
+	// Sources are tried in order.
+	Sources []string
+}
+
+// The sources of registry credentials.
+const (
+	// AuthK8sChain uses the image pull secrets of the TaskRun's service account, then the
+	// controller's cloud workload identity, as the kubelet would.
+	AuthK8sChain = "k8schain"
+	// AuthWorkloadIdentity only uses the controller's cloud workload identity, exchanged for
+	// GCR and Artifact Registry, ECR or ACR tokens. No secrets are read.
+	AuthWorkloadIdentity = "workload-identity"
+	// AuthDocker uses the docker config file of the controller.
+	AuthDocker = "docker"
+	// AuthHelperPrefix is the prefix of the sources that run a docker credential helper of the
+	// controller, e.g. helper:ecr-login runs docker-credential-ecr-login.
+	AuthHelperPrefix = "helper:"
+	// AuthSecretPrefix is the prefix of the sources that read a docker config secret, in the
+	// TaskRun's namespace, e.g. secret:registry-creds, or in another namespace, e.g.
+	// secret:tekton-chains/registry-creds.
+	AuthSecretPrefix = "secret:"
+)
+
 type TektonStorageConfig struct {
+	// Fallback is the backend payloads are stored in instead when storing them in the
+	// annotations of the TaskRun would make them larger than MaxAnnotationsSize. Payloads
+	// that don't fit fail to be stored without one.
+	Fallback string
+	// MaxAnnotationsSize is the total size in bytes the annotations of a TaskRun may have once
+	// a payload is stored in them. DefaultMaxAnnotationsSize is used when it's 0.
+	MaxAnnotationsSize int
 }
 
+// DefaultMaxAnnotationsSize leaves room below the 256KiB Kubernetes allows the annotations of
+// an object for the annotations recorded once the payloads are stored.
+const DefaultMaxAnnotationsSize = 200 * 1024
+
 type DocDBStorageConfig struct {
 	URL string
 }
 
+// ResultsStorageConfig configures storage in Tekton Results
+type ResultsStorageConfig struct {
+	// Address is the URL of the REST API of Tekton Results
+	Address string
+}
+
 type TransparencyConfig struct {
 	Enabled          bool
 	VerifyAnnotation bool
 	URL              string
+	// AdditionalURLs are more logs entries are also uploaded to, e.g. an internal log kept
+	// alongside the public one. Only the entries in the log at URL are stored with payloads.
+	AdditionalURLs []string
+	// Async uploads entries in the background, after the payloads are stored, retrying with backoff
+	Async bool
+	// EntryTypes are the kinds of entries payloads are uploaded as, by payload format
+	EntryTypes TransparencyEntryTypes
+	// TUF configures where the public key of the log is distributed, for private logs
+	TUF TUFConfig
+}
+
+// TUFConfig configures a TUF repository the public key of the transparency log is downloaded from.
+// The repository's root is read from the signing secret.
+type TUFConfig struct {
+	// Mirror is the URL of the repository.
+	Mirror string
+	// Target is the name of the log's public key in the repository. It defaults to rekor.pub.
+	Target string
+}
+
+// The kinds of entries payloads can be uploaded to the transparency log as.
+const (
+	// EntryTypeIntoto entries hold the DSSE envelope of a wrapped payload.
+	EntryTypeIntoto = "intoto"
+	// EntryTypeDSSE entries also hold the envelope of a wrapped payload, and verify its signatures.
+	EntryTypeDSSE = "dsse"
+	// EntryTypeRekord entries hold a payload and its signature.
+	EntryTypeRekord = "rekord"
+	// EntryTypeHashedRekord entries hold the signature and the digest of what was signed.
+	EntryTypeHashedRekord = "hashedrekord"
+)
+
+// TransparencyEntryTypes configures the kind of entry each payload format is uploaded as
+type TransparencyEntryTypes struct {
+	Tekton        string
+	SimpleSigning string
+	InToto        string
+	Provenance    string
+}
+
+// URLs returns every log entries are uploaded to, starting with URL.
+func (t TransparencyConfig) URLs() []string {
+	return append([]string{t.URL}, t.AdditionalURLs...)
+}
+
+// EntryType returns the kind of entry payloads of the format are uploaded as.
+func (t TransparencyConfig) EntryType(payloadFormat string) string {
+	switch payloadFormat {
+	case "tekton":
+		return t.EntryTypes.Tekton
+	case "simplesigning":
+		return t.EntryTypes.SimpleSigning
+	case "in-toto":
+		return t.EntryTypes.InToto
+	case "tekton-provenance":
+		return t.EntryTypes.Provenance
+	}
+	return ""
+}
+
+// NamespaceConfig restricts which namespaces have their TaskRuns signed
+type NamespaceConfig struct {
+	// Include lists the only namespaces to sign TaskRuns in, if set
+	Include []string
+	// Exclude lists namespaces to never sign TaskRuns in
+	Exclude []string
+	// Selector is a label selector that namespaces must match, if set
+	Selector string
+	// QuotaRate is the number of TaskRuns signed per minute in each namespace. The others wait
+	// for their turn. There is no quota when it's 0.
+	QuotaRate int
+	// QuotaBurst is the number of TaskRuns of a namespace signed at once before the quota
+	// applies. QuotaRate is used when it's 0.
+	QuotaBurst int
+}
+
+// FilterConfig restricts which TaskRuns are signed
+type FilterConfig struct {
+	// LabelSelector is a label selector that TaskRuns must match, if set
+	LabelSelector string
+	// AnnotationSelector is a selector evaluated against TaskRun annotations, if set
+	AnnotationSelector string
+	// Tasks lists the names of the Tasks or ClusterTasks whose TaskRuns are signed, if set
+	Tasks []string
+	// Pipelines lists the names of the Pipelines whose TaskRuns are signed, if set
+	Pipelines []string
+	// Bundles lists prefixes of the Tekton Bundles whose TaskRuns are signed, if set
+	Bundles []string
+}
+
+// EventsConfig configures where CloudEvents about signed TaskRuns are sent
+type EventsConfig struct {
+	// Sink is the URL to send CloudEvents to, if set
+	Sink string
+}
+
+// GCConfig configures the cleanup of signed TaskRuns
+type GCConfig struct {
+	// Retention is how long payloads and signatures stored in TaskRun annotations are kept
+	// after signing. Zero keeps them forever.
+	Retention time.Duration
+}
+
+// DeadLetterConfig configures where TaskRuns that permanently failed to be signed are recorded
+type DeadLetterConfig struct {
+	// URL is the go-cloud URI of a docstore collection to write records to, if set
+	URL string
+}
+
+// SigningTimeoutConfig bounds how long signing a single TaskRun may take
+type SigningTimeoutConfig struct {
+	// Timeout for signing and storing all the payloads of a TaskRun. Zero means no limit.
+	Timeout time.Duration
+}
+
+// VSAConfig configures the Verification Summary Attestations Chains signs for TaskRuns whose
+// provenance passes a policy
+type VSAConfig struct {
+	// Enabled turns on VSAs. They are created for in-toto provenance only.
+	Enabled bool
+	// VerifierID identifies Chains as the verifier in VSAs. Defaults to the builder ID.
+	VerifierID string
+	// PolicyURI identifies the policy in VSAs.
+	PolicyURI string
+	// PolicyLevel is the SLSA level VSAs claim the artifacts meet, if set.
+	PolicyLevel string
+	// BuilderIDs are the builder IDs the provenance may have, if set.
+	BuilderIDs []string
+	// RequireMaterials requires the provenance to record at least one material.
+	RequireMaterials bool
+}
+
+// DefinitionsConfig configures the verification of the signatures on the Tekton bundles
+// TaskRuns got their Task and Pipeline definitions from
+type DefinitionsConfig struct {
+	// Verify turns on verification. Its result is recorded in in-toto provenance.
+	Verify bool
+	// Key is the public key bundles must be signed by: a PEM file or a KMS reference.
+	Key string
+	// Required stops Chains from signing TaskRuns whose definitions aren't signed by Key.
+	Required bool
+}
+
+// SBOMConfig configures signing the SBOMs tasks produce and attaching them to their images
+type SBOMConfig struct {
+	Enabled bool
+}
+
+// SourceConfig configures signing source attestations about the git commits TaskRuns build
+type SourceConfig struct {
+	Enabled bool
+}
+
+// BundleConfig configures storing a Sigstore bundle of each signed payload
+type BundleConfig struct {
+	Enabled bool
+}
+
+// ImagesConfig configures how the images listed in the IMAGES result are read
+type ImagesConfig struct {
+	// Resolve looks up the digests of images listed without one in the registry.
+	Resolve bool
+}
+
+// SubjectsConfig configures how the subjects of attestations are named
+type SubjectsConfig struct {
+	// Rewrites are applied to the names of subjects, in order. The first that matches a name is used.
+	Rewrites []SubjectRewrite
+}
+
+// SubjectRewrite replaces the matches of a regular expression in the names of subjects
+type SubjectRewrite struct {
+	// Pattern is a regular expression, in the syntax of Go's regexp package.
+	Pattern string
+	// Replacement replaces the matches of Pattern. $1 and ${name} refer to its groups.
+	Replacement string
+}
+
+// RedactConfig configures scanning the payloads of TaskRuns for secrets before they are signed
+type RedactConfig struct {
+	// Enabled turns on scanning.
+	Enabled bool
+	// Action is what is done with a payload a secret is found in: RedactActionRedact, the
+	// default, or RedactActionFail.
+	Action string
+	// Patterns are regular expressions, in the syntax of Go's regexp package, that secrets
+	// match. They are used in addition to the built-in ones.
+	Patterns []string
+	// EntropyThreshold is the Shannon entropy, in bits per character, from which long tokens
+	// are taken for secrets. Tokens aren't measured when it's 0.
+	EntropyThreshold float64
+}
+
+// The actions taken on a payload a secret is found in.
+const (
+	// RedactActionRedact replaces the secrets with a placeholder before the payload is signed.
+	RedactActionRedact = "redact"
+	// RedactActionFail fails the signing of the TaskRun.
+	RedactActionFail = "fail"
+)
+
+// ProvenanceConfig configures the organizational context recorded in provenance
+type ProvenanceConfig struct {
+	// Labels are the keys of the labels of TaskRuns that are recorded.
+	Labels []string
+	// Annotations are the keys of the annotations of TaskRuns that are recorded.
+	Annotations []string
+}
+
+// DryRunConfig configures dry-run mode, where payloads are stored without being signed
+type DryRunConfig struct {
+	Enabled bool
 }
 
 const (
@@ -105,30 +620,140 @@ const (
 	ociStorageKey = "artifacts.oci.storage"
 	ociSignerKey  = "artifacts.oci.signer"
 
+	blobFormatKey  = "artifacts.blob.format"
+	blobStorageKey = "artifacts.blob.storage"
+	blobSignerKey  = "artifacts.blob.signer"
+
+	imagesResolveKey = "artifacts.oci.images.resolve"
+
+	subjectsRewriteKey = "subjects.rewrite"
+
+	// Secret redaction
+	redactEnabledKey          = "redact.enabled"
+	redactActionKey           = "redact.action"
+	redactPatternsKey         = "redact.patterns"
+	redactEntropyThresholdKey = "redact.entropy.threshold"
+
 	gcsBucketKey             = "storage.gcs.bucket"
+	gcsKMSKeyKey             = "storage.gcs.kms-key"
+	gcsHoldKey               = "storage.gcs.hold"
+	gcsRetentionKey          = "storage.gcs.retention"
 	ociRepositoryKey         = "storage.oci.repository"
 	ociRepositoryInsecureKey = "storage.oci.repository.insecure"
+	ociAuthKey               = "storage.oci.auth"
+	ociRegistryAuthKey       = "storage.oci.auth.registries"
+	ociMaxConcurrentKey      = "storage.oci.max-concurrent"
+	ociProxyKey              = "storage.oci.proxy"
+	ociCACertsKey            = "storage.oci.ca-certs"
+	ociRegistriesKey         = "storage.oci.registries"
+	ociMappingsKey           = "storage.oci.repository.mappings"
+	storageRoutesKey         = "storage.routes"
 	docDBUrlKey              = "storage.docdb.url"
-	// No config needed for Tekton object storage
+	resultsAddressKey        = "storage.results.address"
+	tektonFallbackKey        = "storage.tekton.fallback"
+	tektonMaxAnnotationsKey  = "storage.tekton.max-annotations-size"
 
 	// No config needed for x509 signer
 
 	// KMS
-	kmsSignerKMSRef = "signers.kms.kmsref"
+	kmsSignerKMSRef      = "signers.kms.kmsref"
+	kmsMaxConcurrentKey  = "signers.kms.max-concurrent"
+	kmsVaultRoleKey      = "signers.kms.vault.role"
+	kmsVaultAuthPathKey  = "signers.kms.vault.auth-path"
+	kmsAWSIdentityKey    = "signers.kms.aws.identity"
+	kmsAWSRegionKey      = "signers.kms.aws.region"
+	kmsAWSRoleARNKey     = "signers.kms.aws.role-arn"
+	kmsAWSAssumeRoles    = "signers.kms.aws.assume-roles"
+	kmsGCPAudienceKey    = "signers.kms.gcp.audience"
+	kmsGCPServiceAcctKey = "signers.kms.gcp.service-account"
+	kmsGCPTokenPathKey   = "signers.kms.gcp.token-path"
 	// Fulcio
-	x509SignerFulcioEnabled = "signers.x509.fulcio.enabled"
-	x509SignerFulcioAuth    = "signers.x509.fulcio.auth"
-	x509SignerFulcioAddr    = "signers.x509.fulcio.address"
+	x509SignerFulcioEnabled  = "signers.x509.fulcio.enabled"
+	x509SignerFulcioAuth     = "signers.x509.fulcio.auth"
+	x509SignerFulcioAddr     = "signers.x509.fulcio.address"
+	x509SignerFulcioAudience = "signers.x509.fulcio.audience"
+	// Sealed keys
+	x509SealingKeyKey = "signers.x509.sealing-key"
+	// Keys in the TaskRun's namespace
+	x509NamespaceSecretKey = "signers.x509.namespace-secret"
+	// Profiles
+	signerProfilesKey   = "signers.profiles"
+	signerNamespacesKey = "signers.namespaces"
+	countersignerKey    = "signers.countersigner"
+	signerProbeKey      = "signers.probe"
 
 	// Builder config
 	builderIDKey = "builder.id"
 
+	// Organizational context
+	provenanceLabelsKey      = "provenance.labels"
+	provenanceAnnotationsKey = "provenance.annotations"
+
 	transparencyEnabledKey = "transparency.enabled"
 	transparencyURLKey     = "transparency.url"
 
+	transparencyAdditionalURLsKey = "transparency.additional-urls"
+	transparencyAsyncKey          = "transparency.async"
+
+	transparencyEntryTektonKey        = "transparency.entry.tekton"
+	transparencyEntrySimpleSigningKey = "transparency.entry.simplesigning"
+	transparencyEntryInTotoKey        = "transparency.entry.in-toto"
+	transparencyEntryProvenanceKey    = "transparency.entry.tekton-provenance"
+
+	transparencyTUFMirrorKey = "transparency.tuf.mirror"
+	transparencyTUFTargetKey = "transparency.tuf.target"
+
+	// Namespace filtering
+	namespacesIncludeKey    = "namespaces.include"
+	namespacesExcludeKey    = "namespaces.exclude"
+	namespacesSelectorKey   = "namespaces.selector"
+	namespacesQuotaRateKey  = "namespaces.quota.rate"
+	namespacesQuotaBurstKey = "namespaces.quota.burst"
+
+	// TaskRun filtering
+	filtersLabelsKey      = "filters.labels"
+	filtersAnnotationsKey = "filters.annotations"
+	filtersTasksKey       = "filters.tasks"
+	filtersPipelinesKey   = "filters.pipelines"
+	filtersBundlesKey     = "filters.bundles"
+
+	eventsSinkKey = "events.sink"
+
+	gcRetentionKey = "gc.retention"
+
+	deadLetterURLKey = "deadletter.url"
+
+	dryRunEnabledKey = "dryrun.enabled"
+
+	signingTimeoutKey = "signing.timeout"
+
+	// Verification Summary Attestations
+	vsaEnabledKey          = "vsa.enabled"
+	vsaVerifierIDKey       = "vsa.verifier.id"
+	vsaPolicyURIKey        = "vsa.policy.uri"
+	vsaPolicyLevelKey      = "vsa.policy.level"
+	vsaPolicyBuilderIDsKey = "vsa.policy.builder.ids"
+	vsaPolicyMaterialsKey  = "vsa.policy.materials.required"
+
+	// Task and Pipeline definition verification
+	definitionsVerifyEnabledKey  = "definitions.verify.enabled"
+	definitionsVerifyKeyKey      = "definitions.verify.key"
+	definitionsVerifyRequiredKey = "definitions.verify.required"
+
+	sbomEnabledKey = "sbom.enabled"
+
+	sourceEnabledKey = "source.enabled"
+
+	bundleEnabledKey = "bundle.enabled"
+
 	ChainsConfig = "chains-config"
 )
 
+// OverridesLabel marks the chains-config ConfigMaps of other namespaces as namespace overrides.
+// The overrides of a ConfigMap without it set to "true" don't apply, and only the ConfigMaps
+// with it are sent to the webhook that validates them.
+const OverridesLabel = "chains.tekton.dev/overrides"
+
 func defaultConfig() *Config {
 	return &Config{
 		Artifacts: ArtifactConfigs{
@@ -142,15 +767,31 @@ func defaultConfig() *Config {
 				StorageBackend: "oci",
 				Signer:         "x509",
 			},
+			Blobs: Artifact{
+				Format: "in-toto",
+				Signer: "x509",
+			},
 		},
 		Transparency: TransparencyConfig{
 			URL: "https://rekor.sigstore.dev",
+			EntryTypes: TransparencyEntryTypes{
+				Tekton:        EntryTypeRekord,
+				SimpleSigning: EntryTypeRekord,
+				InToto:        EntryTypeIntoto,
+				Provenance:    EntryTypeIntoto,
+			},
 		},
 		Signers: SignerConfigs{
 			X509: X509Signer{
-				FulcioAuth: "google",
-				FulcioAddr: "https://fulcio.sigstore.dev",
+				FulcioAuth:     FulcioAuthGoogle,
+				FulcioAddr:     "https://fulcio.sigstore.dev",
+				FulcioAudience: "sigstore",
+			},
+			KMS: KMSSigner{
+				Vault: VaultAuth{Path: "kubernetes"},
+				GCP:   GCPAuth{TokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token"},
 			},
+			Probe: SignerProbePublicKey,
 		},
 		Builder: BuilderConfig{
 			ID: "tekton-chains",
@@ -162,42 +803,190 @@ func defaultConfig() *Config {
 func NewConfigFromMap(data map[string]string) (*Config, error) {
 	cfg := defaultConfig()
 
-	if err := cm.Parse(data,
-		// Artifact-specific configs
-		// TaskRuns
-		asString(taskrunFormatKey, &cfg.Artifacts.TaskRuns.Format, "tekton", "in-toto", "tekton-provenance"),
-		asString(taskrunStorageKey, &cfg.Artifacts.TaskRuns.StorageBackend, "tekton", "oci", "gcs", "docdb"),
-		asString(taskrunSignerKey, &cfg.Artifacts.TaskRuns.Signer, "x509", "kms"),
-		// OCI
-		asString(ociFormatKey, &cfg.Artifacts.OCI.Format, "tekton", "simplesigning"),
-		asString(ociStorageKey, &cfg.Artifacts.OCI.StorageBackend, "tekton", "oci", "gcs", "docdb"),
-		asString(ociSignerKey, &cfg.Artifacts.OCI.Signer, "x509", "kms"),
-
+	if err := cm.Parse(data, append(artifactParsers(&cfg.Artifacts),
 		// Storage level configs
 		asString(gcsBucketKey, &cfg.Storage.GCS.Bucket),
+		asString(gcsKMSKeyKey, &cfg.Storage.GCS.KMSKey),
+		asString(gcsHoldKey, &cfg.Storage.GCS.Hold, GCSHoldTemporary, GCSHoldEventBased),
+		cm.AsDuration(gcsRetentionKey, &cfg.Storage.GCS.Retention),
 		asString(ociRepositoryKey, &cfg.Storage.OCI.Repository),
 		asBool(ociRepositoryInsecureKey, &cfg.Storage.OCI.Insecure),
+		asStringSlice(ociAuthKey, &cfg.Storage.OCI.Auth),
+		asRegistryAuth(ociRegistryAuthKey, &cfg.Storage.OCI.RegistryAuth),
+		cm.AsInt(ociMaxConcurrentKey, &cfg.Storage.OCI.MaxConcurrent),
+		asString(ociProxyKey, &cfg.Storage.OCI.Proxy),
+		asString(ociCACertsKey, &cfg.Storage.OCI.CACerts),
+		asRegistryConnections(ociRegistriesKey, &cfg.Storage.OCI.Registries),
+		asRepositoryMappings(ociMappingsKey, &cfg.Storage.OCI.Mappings),
 		asString(docDBUrlKey, &cfg.Storage.DocDB.URL),
+		asString(resultsAddressKey, &cfg.Storage.Results.Address),
+		asString(tektonFallbackKey, &cfg.Storage.Tekton.Fallback, fallbackBackends.List()...),
+		cm.AsInt(tektonMaxAnnotationsKey, &cfg.Storage.Tekton.MaxAnnotationsSize),
+		asStorageRoutes(storageRoutesKey, &cfg.Storage.Routes),
 
 		oneOf(transparencyEnabledKey, &cfg.Transparency.Enabled, "true", "manual"),
 		oneOf(transparencyEnabledKey, &cfg.Transparency.VerifyAnnotation, "manual"),
 		asString(transparencyURLKey, &cfg.Transparency.URL),
+		asStringSlice(transparencyAdditionalURLsKey, &cfg.Transparency.AdditionalURLs),
+		asBool(transparencyAsyncKey, &cfg.Transparency.Async),
+		// Payloads that aren't wrapped in an envelope can't be uploaded as envelopes.
+		asString(transparencyEntryTektonKey, &cfg.Transparency.EntryTypes.Tekton, EntryTypeRekord, EntryTypeHashedRekord),
+		asString(transparencyEntrySimpleSigningKey, &cfg.Transparency.EntryTypes.SimpleSigning, EntryTypeRekord, EntryTypeHashedRekord),
+		asString(transparencyEntryInTotoKey, &cfg.Transparency.EntryTypes.InToto, EntryTypeIntoto, EntryTypeDSSE, EntryTypeHashedRekord),
+		asString(transparencyEntryProvenanceKey, &cfg.Transparency.EntryTypes.Provenance, EntryTypeIntoto, EntryTypeDSSE, EntryTypeHashedRekord),
+		asString(transparencyTUFMirrorKey, &cfg.Transparency.TUF.Mirror),
+		asString(transparencyTUFTargetKey, &cfg.Transparency.TUF.Target),
 
 		asString(kmsSignerKMSRef, &cfg.Signers.KMS.KMSRef),
+		cm.AsInt(kmsMaxConcurrentKey, &cfg.Signers.KMS.MaxConcurrent),
+		asString(kmsVaultRoleKey, &cfg.Signers.KMS.Vault.Role),
+		asString(kmsVaultAuthPathKey, &cfg.Signers.KMS.Vault.Path),
+		asString(kmsAWSIdentityKey, &cfg.Signers.KMS.AWS.Identity, AWSIdentityIRSA, AWSIdentityPodIdentity),
+		asString(kmsAWSRegionKey, &cfg.Signers.KMS.AWS.Region),
+		asString(kmsAWSRoleARNKey, &cfg.Signers.KMS.AWS.RoleARN),
+		asStringSlice(kmsAWSAssumeRoles, &cfg.Signers.KMS.AWS.AssumeRoles),
+		asString(kmsGCPAudienceKey, &cfg.Signers.KMS.GCP.Audience),
+		asString(kmsGCPServiceAcctKey, &cfg.Signers.KMS.GCP.ServiceAccount),
+		asString(kmsGCPTokenPathKey, &cfg.Signers.KMS.GCP.TokenPath),
 
 		asBool(x509SignerFulcioEnabled, &cfg.Signers.X509.FulcioEnabled),
 		asString(x509SignerFulcioAuth, &cfg.Signers.X509.FulcioAuth),
 		asString(x509SignerFulcioAddr, &cfg.Signers.X509.FulcioAddr),
+		asString(x509SignerFulcioAudience, &cfg.Signers.X509.FulcioAudience),
+		asString(x509SealingKeyKey, &cfg.Signers.X509.SealingKey),
+		asString(x509NamespaceSecretKey, &cfg.Signers.X509.NamespaceSecret),
+		asSignerProfiles(signerProfilesKey, &cfg.Signers.Profiles),
+		asNamespaceSigners(signerNamespacesKey, &cfg.Signers.Namespaces),
+		asSignerName(countersignerKey, &cfg.Signers.Countersigner),
+		asString(signerProbeKey, &cfg.Signers.Probe, SignerProbePublicKey, SignerProbeSign, SignerProbeNone),
 
 		// Build config
 		asString(builderIDKey, &cfg.Builder.ID),
-	); err != nil {
+		asStringSlice(provenanceLabelsKey, &cfg.Provenance.Labels),
+		asStringSlice(provenanceAnnotationsKey, &cfg.Provenance.Annotations),
+
+		asStringSlice(namespacesIncludeKey, &cfg.Namespaces.Include),
+		asStringSlice(namespacesExcludeKey, &cfg.Namespaces.Exclude),
+		asLabelSelector(namespacesSelectorKey, &cfg.Namespaces.Selector),
+		cm.AsInt(namespacesQuotaRateKey, &cfg.Namespaces.QuotaRate),
+		cm.AsInt(namespacesQuotaBurstKey, &cfg.Namespaces.QuotaBurst),
+
+		asLabelSelector(filtersLabelsKey, &cfg.Filters.LabelSelector),
+		asLabelSelector(filtersAnnotationsKey, &cfg.Filters.AnnotationSelector),
+		asStringSlice(filtersTasksKey, &cfg.Filters.Tasks),
+		asStringSlice(filtersPipelinesKey, &cfg.Filters.Pipelines),
+		asStringSlice(filtersBundlesKey, &cfg.Filters.Bundles),
+
+		asString(eventsSinkKey, &cfg.Events.Sink),
+
+		cm.AsDuration(gcRetentionKey, &cfg.GC.Retention),
+
+		asString(deadLetterURLKey, &cfg.DeadLetter.URL),
+
+		asBool(dryRunEnabledKey, &cfg.DryRun.Enabled),
+
+		cm.AsDuration(signingTimeoutKey, &cfg.Signing.Timeout),
+
+		asBool(vsaEnabledKey, &cfg.VSA.Enabled),
+		asString(vsaVerifierIDKey, &cfg.VSA.VerifierID),
+		asString(vsaPolicyURIKey, &cfg.VSA.PolicyURI),
+		asString(vsaPolicyLevelKey, &cfg.VSA.PolicyLevel),
+		asStringSlice(vsaPolicyBuilderIDsKey, &cfg.VSA.BuilderIDs),
+		asBool(vsaPolicyMaterialsKey, &cfg.VSA.RequireMaterials),
+
+		asBool(definitionsVerifyEnabledKey, &cfg.Definitions.Verify),
+		asString(definitionsVerifyKeyKey, &cfg.Definitions.Key),
+		asBool(definitionsVerifyRequiredKey, &cfg.Definitions.Required),
+
+		asBool(sbomEnabledKey, &cfg.SBOM.Enabled),
+
+		asBool(sourceEnabledKey, &cfg.Source.Enabled),
+
+		asBool(bundleEnabledKey, &cfg.Bundle.Enabled),
+
+		asBool(imagesResolveKey, &cfg.Images.Resolve),
+
+		asSubjectRewrites(subjectsRewriteKey, &cfg.Subjects.Rewrites),
+
+		asBool(redactEnabledKey, &cfg.Redact.Enabled),
+		asString(redactActionKey, &cfg.Redact.Action, RedactActionRedact, RedactActionFail),
+		asPatterns(redactPatternsKey, &cfg.Redact.Patterns),
+		cm.AsFloat64(redactEntropyThresholdKey, &cfg.Redact.EntropyThreshold),
+	)...); err != nil {
 		return nil, fmt.Errorf("failed to parse data: %w", err)
 	}
 
 	return cfg, nil
 }
 
+// WithNamespaceOverrides returns a copy of the Config with the artifact format, storage
+// and signer settings in data, the chains-config of namespace, applied. Other keys in data
+// are ignored. The signers that signers.namespaces doesn't allow namespace to select are
+// rejected.
+func (c *Config) WithNamespaceOverrides(namespace string, data map[string]string) (*Config, error) {
+	cfg := c.DeepCopy()
+	if err := cm.Parse(data, artifactParsers(&cfg.Artifacts)...); err != nil {
+		return nil, fmt.Errorf("failed to parse overrides: %w", err)
+	}
+	allowed := sets.NewString()
+	for _, n := range c.Signers.Namespaces {
+		if n.Namespace == namespace {
+			allowed.Insert(n.Signers...)
+		}
+	}
+	for _, s := range []struct{ key, cluster, override string }{
+		{taskrunSignerKey, c.Artifacts.TaskRuns.Signer, cfg.Artifacts.TaskRuns.Signer},
+		{ociSignerKey, c.Artifacts.OCI.Signer, cfg.Artifacts.OCI.Signer},
+		{blobSignerKey, c.Artifacts.Blobs.Signer, cfg.Artifacts.Blobs.Signer},
+	} {
+		if s.override != s.cluster && !allowed.Has(s.override) {
+			return nil, fmt.Errorf("%s is %s, which %s doesn't allow namespace %s to sign with", s.key, s.override, signerNamespacesKey, namespace)
+		}
+	}
+	return cfg, nil
+}
+
+// payloadFormats are the formats payloads are created in.
+var payloadFormats = sets.NewString("tekton", "simplesigning", "in-toto", "tekton-provenance")
+
+// storageBackends are the backends payloads are stored in.
+var storageBackends = sets.NewString("tekton", "oci", "gcs", "docdb", "results")
+
+// fallbackBackends are the backends payloads too large for the tekton backend are stored in.
+var fallbackBackends = storageBackends.Difference(sets.NewString("tekton"))
+
+// artifactFormats and artifactBackends are the formats and the storage backends of each kind
+// of artifact, by the name of its keys.
+var (
+	artifactFormats = map[string]sets.String{
+		"taskrun": sets.NewString("tekton", "in-toto", "tekton-provenance"),
+		"oci":     sets.NewString("tekton", "simplesigning"),
+		"blob":    sets.NewString("in-toto"),
+	}
+	artifactBackends = map[string]sets.String{
+		"taskrun": storageBackends,
+		"oci":     storageBackends,
+		"blob":    storageBackends.Difference(sets.NewString("oci")),
+	}
+)
+
+func artifactParsers(cfg *ArtifactConfigs) []cm.ParseFunc {
+	return []cm.ParseFunc{
+		// TaskRuns
+		asString(taskrunFormatKey, &cfg.TaskRuns.Format, artifactFormats["taskrun"].List()...),
+		asString(taskrunStorageKey, &cfg.TaskRuns.StorageBackend, artifactBackends["taskrun"].List()...),
+		asSignerName(taskrunSignerKey, &cfg.TaskRuns.Signer),
+		// OCI
+		asString(ociFormatKey, &cfg.OCI.Format, artifactFormats["oci"].List()...),
+		asString(ociStorageKey, &cfg.OCI.StorageBackend, artifactBackends["oci"].List()...),
+		asSignerName(ociSignerKey, &cfg.OCI.Signer),
+		// Blobs
+		asString(blobFormatKey, &cfg.Blobs.Format, artifactFormats["blob"].List()...),
+		asString(blobStorageKey, &cfg.Blobs.StorageBackend, artifactBackends["blob"].List()...),
+		asSignerName(blobSignerKey, &cfg.Blobs.Signer),
+	}
+}
+
 // NewConfigFromConfigMap creates a Config from the supplied ConfigMap
 func NewConfigFromConfigMap(configMap *corev1.ConfigMap) (*Config, error) {
 	return NewConfigFromMap(configMap.Data)
@@ -250,10 +1039,373 @@ func asString(key string, target *string, values ...string) cm.ParseFunc {
 		if len(values) > 0 {
 			vals := sets.NewString(values...)
 			if !vals.Has(raw) {
-				return fmt.Errorf("invalid value %q wanted one of %v", raw, vals.List())
+				return fmt.Errorf("invalid value %q for %s, wanted one of %v", raw, key, vals.List())
 			}
 		}
 		*target = raw
 		return nil
 	}
 }
+
+// asStringSlice parses a comma separated list at key into the target, if it exists.
+func asStringSlice(key string, target *[]string) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		vals := []string{}
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				vals = append(vals, v)
+			}
+		}
+		*target = vals
+		return nil
+	}
+}
+
+// asRegistryAuth parses the credential sources of registries at key into the target, if it
+// exists. Entries are separated by commas, and written as host=source. A registry with more
+// than one source has an entry for each, in the order they're tried.
+func asRegistryAuth(key string, target *[]RegistryAuth) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		registries := []RegistryAuth{}
+		index := map[string]int{}
+		for _, entry := range strings.Split(raw, ",") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+				return fmt.Errorf("invalid entry %q for %s, wanted host=source", entry, key)
+			}
+			host, source := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+			i, ok := index[host]
+			if !ok {
+				i = len(registries)
+				index[host] = i
+				registries = append(registries, RegistryAuth{Host: host})
+			}
+			registries[i].Sources = append(registries[i].Sources, source)
+		}
+		*target = registries
+		return nil
+	}
+}
+
+// asRegistryConnections parses the connection settings of registries at key into the target,
+// if it exists. There is one registry per line, written as: host: [insecure] [skip-tls-verify] [ca-certs=path]
+func asRegistryConnections(key string, target *[]RegistryConnection) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		registries := []RegistryConnection{}
+		seen := sets.NewString()
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, ": ", 2)
+			if len(parts) != 2 {
+				// A registry without options.
+				parts = []string{strings.TrimSuffix(line, ":"), ""}
+			}
+			r := RegistryConnection{Host: strings.TrimSpace(parts[0])}
+			switch {
+			case r.Host == "" || strings.ContainsAny(r.Host, " /"):
+				return fmt.Errorf("invalid registry %q for %s, wanted: host: [insecure] [skip-tls-verify] [ca-certs=path]", line, key)
+			case seen.Has(r.Host):
+				return fmt.Errorf("registry %q for %s is listed more than once", r.Host, key)
+			}
+			seen.Insert(r.Host)
+			for _, opt := range strings.Fields(parts[1]) {
+				switch {
+				case opt == "insecure":
+					r.Insecure = true
+				case opt == "skip-tls-verify":
+					r.SkipTLSVerify = true
+				case strings.HasPrefix(opt, "ca-certs=") && opt != "ca-certs=":
+					r.CACerts = strings.TrimPrefix(opt, "ca-certs=")
+				default:
+					return fmt.Errorf("invalid option %q of registry %s for %s, wanted insecure, skip-tls-verify or ca-certs=path", opt, r.Host, key)
+				}
+			}
+			registries = append(registries, r)
+		}
+		*target = registries
+		return nil
+	}
+}
+
+// signerNamePattern is what the names of signer profiles look like.
+var signerNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// asSignerName passes the name of a signer at key through into the target, if it exists. The
+// signer is x509, kms or a signer profile, which Validate checks is defined: the chains-config
+// of a namespace names the profiles defined by the cluster's.
+func asSignerName(key string, target *string) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		if !signerNamePattern.MatchString(raw) {
+			return fmt.Errorf("invalid value %q for %s, wanted %s, %s or the name of a signer profile", raw, key, SignerX509, SignerKMS)
+		}
+		*target = raw
+		return nil
+	}
+}
+
+// asSignerProfiles parses signer profiles at key into the target, if it exists. There is one
+// profile per line, written as: name: type [kmsref | fulcio]
+func asSignerProfiles(key string, target *[]SignerProfile) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		profiles := []SignerProfile{}
+		seen := sets.NewString(SignerX509, SignerKMS)
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid profile %q for %s, wanted: name: type [kmsref | fulcio]", line, key)
+			}
+			p := SignerProfile{Name: strings.TrimSpace(parts[0])}
+			switch {
+			case !signerNamePattern.MatchString(p.Name):
+				return fmt.Errorf("invalid profile name %q for %s, wanted lower case letters, digits and dashes", p.Name, key)
+			case seen.Has(p.Name):
+				return fmt.Errorf("profile %q for %s is defined more than once, or is named after a signer", p.Name, key)
+			}
+			seen.Insert(p.Name)
+			fields := strings.Fields(parts[1])
+			if len(fields) > 0 {
+				p.Type = fields[0]
+			}
+			switch {
+			case p.Type == SignerKMS && len(fields) == 2:
+				p.KMSRef = fields[1]
+			case p.Type == SignerX509 && len(fields) == 1:
+			case p.Type == SignerX509 && len(fields) == 2 && fields[1] == "fulcio":
+				p.Fulcio = true
+			default:
+				return fmt.Errorf("invalid profile %q for %s, wanted %s with a KMS reference, or %s with an optional fulcio", line, key, SignerKMS, SignerX509)
+			}
+			profiles = append(profiles, p)
+		}
+		*target = profiles
+		return nil
+	}
+}
+
+// asNamespaceSigners parses the signers namespaces can select at key into the target, if it
+// exists. There is one namespace per line: its name, a colon, and the signers separated by
+// commas. Validate checks the names of the namespaces, and that the signers are defined.
+func asNamespaceSigners(key string, target *[]NamespaceSigners) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		namespaces := []NamespaceSigners{}
+		seen := sets.NewString()
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+				return fmt.Errorf("invalid namespace %q for %s, wanted: namespace: signer[,signer...]", line, key)
+			}
+			n := NamespaceSigners{Namespace: strings.TrimSpace(parts[0])}
+			if seen.Has(n.Namespace) {
+				return fmt.Errorf("invalid namespace %q for %s: it's listed more than once", n.Namespace, key)
+			}
+			seen.Insert(n.Namespace)
+			for _, s := range strings.Split(parts[1], ",") {
+				if s = strings.TrimSpace(s); !signerNamePattern.MatchString(s) {
+					return fmt.Errorf("invalid signer %q of namespace %s for %s, wanted %s, %s or the name of a signer profile", s, n.Namespace, key, SignerX509, SignerKMS)
+				}
+				n.Signers = append(n.Signers, s)
+			}
+			namespaces = append(namespaces, n)
+		}
+		*target = namespaces
+		return nil
+	}
+}
+
+// asLabelSelector passes the value at key through into the target, if it exists
+// and is a valid label selector.
+func asLabelSelector(key string, target *string) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		if _, err := labels.Parse(raw); err != nil {
+			return fmt.Errorf("invalid label selector %q for %s: %w", raw, key, err)
+		}
+		*target = raw
+		return nil
+	}
+}
+
+// asSubjectRewrites parses rewrite rules at key into the target, if it exists. There is one
+// rule per line, written as: pattern => replacement
+func asSubjectRewrites(key string, target *[]SubjectRewrite) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		rewrites := []SubjectRewrite{}
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, "=>", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid rewrite %q for %s, wanted: pattern => replacement", line, key)
+			}
+			rw := SubjectRewrite{Pattern: strings.TrimSpace(parts[0]), Replacement: strings.TrimSpace(parts[1])}
+			if rw.Pattern == "" {
+				return fmt.Errorf("invalid rewrite %q for %s: the pattern is empty", line, key)
+			}
+			if _, err := regexp.Compile(rw.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern %q for %s: %w", rw.Pattern, key, err)
+			}
+			rewrites = append(rewrites, rw)
+		}
+		*target = rewrites
+		return nil
+	}
+}
+
+// asRepositoryMappings parses repository mappings at key into the target, if it exists. There
+// is one mapping per line, written as: pattern => replacement [also]
+func asRepositoryMappings(key string, target *[]RepositoryMapping) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		mappings := []RepositoryMapping{}
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, "=>", 2)
+			if len(parts) != 2 {
+				return fmt.Errorf("invalid mapping %q for %s, wanted: pattern => replacement [also]", line, key)
+			}
+			m := RepositoryMapping{Pattern: strings.TrimSpace(parts[0])}
+			fields := strings.Fields(parts[1])
+			switch {
+			case m.Pattern == "" || len(fields) == 0 || len(fields) > 2:
+				return fmt.Errorf("invalid mapping %q for %s, wanted: pattern => replacement [also]", line, key)
+			case len(fields) == 2 && fields[1] != "also":
+				return fmt.Errorf("invalid mapping %q for %s: unknown option %q, wanted also", line, key, fields[1])
+			}
+			m.Replacement, m.Also = fields[0], len(fields) == 2
+			if _, err := regexp.Compile(m.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern %q for %s: %w", m.Pattern, key, err)
+			}
+			mappings = append(mappings, m)
+		}
+		*target = mappings
+		return nil
+	}
+}
+
+// storageRouteOptions are the options a storage route can end with.
+var storageRouteOptions = map[string]bool{"transparency": true, "no-transparency": false}
+
+// asStorageRoutes parses storage routes at key into the target, if it exists. There is one
+// route per line: the payload format, a colon, the storage backends separated by commas, and
+// then optionally transparency or no-transparency.
+func asStorageRoutes(key string, target *[]StorageRoute) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		routes := []StorageRoute{}
+		seen := map[string]bool{}
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			parts := strings.SplitN(line, ":", 2)
+			fields := []string{}
+			if len(parts) == 2 {
+				fields = strings.Fields(parts[1])
+			}
+			if len(fields) == 0 || len(fields) > 2 {
+				return fmt.Errorf("invalid route %q for %s, wanted: format: backend[,backend...] [transparency|no-transparency]", line, key)
+			}
+			r := StorageRoute{Format: strings.TrimSpace(parts[0])}
+			switch {
+			case !payloadFormats.Has(r.Format):
+				return fmt.Errorf("invalid route %q for %s: unknown format %q, wanted one of %v", line, key, r.Format, payloadFormats.List())
+			case seen[r.Format]:
+				return fmt.Errorf("invalid route %q for %s: %s is routed more than once", line, key, r.Format)
+			}
+			seen[r.Format] = true
+			for _, b := range strings.Split(fields[0], ",") {
+				if !storageBackends.Has(b) {
+					return fmt.Errorf("invalid route %q for %s: unknown storage backend %q, wanted one of %v", line, key, b, storageBackends.List())
+				}
+				r.Backends = append(r.Backends, b)
+			}
+			if len(fields) == 2 {
+				upload, ok := storageRouteOptions[fields[1]]
+				if !ok {
+					return fmt.Errorf("invalid route %q for %s: unknown option %q, wanted transparency or no-transparency", line, key, fields[1])
+				}
+				r.Transparency = &upload
+			}
+			if sets.NewString(r.Backends...).Len() != len(r.Backends) {
+				return fmt.Errorf("invalid route %q for %s: a storage backend is listed more than once", line, key)
+			}
+			routes = append(routes, r)
+		}
+		*target = routes
+		return nil
+	}
+}
+
+// asPatterns parses regular expressions at key into the target, if it exists. There is one
+// expression per line.
+func asPatterns(key string, target *[]string) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		patterns := []string{}
+		for _, line := range strings.Split(raw, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if _, err := regexp.Compile(line); err != nil {
+				return fmt.Errorf("invalid pattern %q for %s: %w", line, key, err)
+			}
+			patterns = append(patterns, line)
+		}
+		*target = patterns
+		return nil
+	}
+}
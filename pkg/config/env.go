@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// EnvPrefix starts the names of the environment variables that set chains-config keys.
+const EnvPrefix = "CHAINS_"
+
+// EnvVar returns the environment variable that sets a chains-config key: the key in upper
+// case, with dots and dashes replaced by underscores, after EnvPrefix. For example,
+// artifacts.taskrun.format is set by CHAINS_ARTIFACTS_TASKRUN_FORMAT.
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// FromEnv returns the chains-config keys set by the environment, given as KEY=value pairs
+// like os.Environ returns. Variables starting with EnvPrefix that don't set a key are errors,
+// since they're usually typos.
+func FromEnv(environ []string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, k := range knownKeys.List() {
+		keys[EnvVar(k)] = k
+	}
+	overrides := map[string]string{}
+	var merr *multierror.Error
+	for _, kv := range environ {
+		name, value := kv, ""
+		if i := strings.Index(kv, "="); i >= 0 {
+			name, value = kv[:i], kv[i+1:]
+		}
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		key, ok := keys[name]
+		if !ok {
+			merr = multierror.Append(merr, fmt.Errorf("environment variable %s doesn't set a %s key", name, ChainsConfig))
+			continue
+		}
+		overrides[key] = value
+	}
+	return overrides, merr.ErrorOrNil()
+}
+
+// ParseOverride parses a key=value pair, as passed to the controller's -config flag.
+func ParseOverride(s string) (string, string, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("%q is not key=value", s)
+	}
+	key := strings.TrimSpace(parts[0])
+	if !knownKeys.Has(key) {
+		return "", "", fmt.Errorf("%s is not a %s key", key, ChainsConfig)
+	}
+	return key, parts[1], nil
+}
+
+// WithOverrides returns a copy of data, the data of chains-config, with the keys set by
+// overrides replaced.
+func WithOverrides(data, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(data)+len(overrides))
+	for k, v := range data {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// OverriddenKeys lists the keys overrides sets, in order.
+func OverriddenKeys(overrides map[string]string) []string {
+	keys := make([]string, 0, len(overrides))
+	for k := range overrides {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type overridesKey struct{}
+
+// ToContextWithOverrides adds the keys that override chains-config, e.g. because they're
+// set by the controller's environment, to ctx.
+func ToContextWithOverrides(ctx context.Context, overrides map[string]string) context.Context {
+	return context.WithValue(ctx, overridesKey{}, overrides)
+}
+
+// OverridesFromContext returns the keys that override chains-config, if there are any.
+func OverridesFromContext(ctx context.Context) map[string]string {
+	overrides, _ := ctx.Value(overridesKey{}).(map[string]string)
+	return overrides
+}
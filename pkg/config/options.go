@@ -16,10 +16,74 @@ limitations under the License.
 
 package config
 
+import "time"
+
 // StorageOpts contains additional information required when storing signatures
 type StorageOpts struct {
 	Key           string
 	Cert          string
 	Chain         string
 	PayloadFormat string
+	// Bundle is the transparency log entry of the signature, if it was uploaded to one.
+	Bundle *TlogBundle
+}
+
+// TlogBundle is what a transparency log returned for an entry: enough to check that the entry
+// is in the log without talking to it again.
+type TlogBundle struct {
+	// Body is the base64 encoded entry, as the log stores it.
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogIndex       int64  `json:"logIndex"`
+	LogID          string `json:"logID"`
+	// SignedEntryTimestamp is the log's signature over the fields above.
+	SignedEntryTimestamp []byte `json:"signedEntryTimestamp"`
+	// InclusionProof proves the entry is in the log's Merkle tree.
+	InclusionProof *InclusionProof `json:"inclusionProof,omitempty"`
+	// Checkpoint is the log's signed commitment to a tree that includes the inclusion proof's.
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// InclusionProof is a Merkle inclusion proof of a transparency log entry.
+type InclusionProof struct {
+	LogIndex int64    `json:"logIndex"`
+	TreeSize int64    `json:"treeSize"`
+	RootHash string   `json:"rootHash"`
+	Hashes   []string `json:"hashes"`
+}
+
+// Checkpoint is a signed tree head of a transparency log.
+type Checkpoint struct {
+	// SignedNote is the checkpoint in the signed note format the log returned it in.
+	SignedNote string `json:"signedNote"`
+	// ConsistencyProof proves the tree of the inclusion proof is a prefix of the checkpoint's.
+	// It's empty when both are the same tree.
+	ConsistencyProof []string `json:"consistencyProof,omitempty"`
+}
+
+// ListFilter selects the payloads a storage backend lists. Its zero fields match every payload.
+// +k8s:deepcopy-gen=false
+type ListFilter struct {
+	// Namespace is the namespace of the TaskRuns the payloads were stored for.
+	Namespace string
+	// Digest is the digest of a subject of the payloads, such as sha256:abc.
+	Digest string
+	// Since and Until bound when the payloads were stored.
+	Since time.Time
+	Until time.Time
+}
+
+// ListedPayload is a payload a storage backend listed, with the TaskRun it was stored for.
+// +k8s:deepcopy-gen=false
+type ListedPayload struct {
+	Namespace string
+	TaskRun   string
+	Key       string
+	Payload   []byte
+	Signature string
+	// Cert and Chain are the certificate the payload was signed with and its chain, if any.
+	Cert  string
+	Chain string
+	// Stored is when the payload was stored.
+	Stored time.Time
 }
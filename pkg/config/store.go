@@ -19,6 +19,8 @@ package config
 import (
 	"context"
 
+	"github.com/tektoncd/chains/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/reconciler"
 )
@@ -54,12 +56,43 @@ func (s *ConfigStore) Load() *Config {
 
 // NewConfigStore returns a reconciler.ConfigStore for the chains configuration data.
 func NewConfigStore(logger configmap.Logger, onAfterStore ...func(name string, value interface{})) *ConfigStore {
+	return NewValidatingConfigStore(logger, nil, onAfterStore...)
+}
+
+// NewValidatingConfigStore returns a reconciler.ConfigStore that checks each new
+// configuration with validate. An invalid update is rejected and the last valid
+// configuration stays in use. An invalid initial configuration is used anyway, since
+// there is nothing to fall back to. Either way the error is logged and reported
+// by the config_valid metric.
+func NewValidatingConfigStore(logger configmap.Logger, validate Validator, onAfterStore ...func(name string, value interface{})) *ConfigStore {
+	return NewValidatingConfigStoreWithOverrides(logger, validate, nil, onAfterStore...)
+}
+
+// NewValidatingConfigStoreWithOverrides is NewValidatingConfigStore, with the keys in
+// overrides replacing the ones in the ConfigMap, see WithOverrides.
+func NewValidatingConfigStoreWithOverrides(logger configmap.Logger, validate Validator, overrides map[string]string, onAfterStore ...func(name string, value interface{})) *ConfigStore {
+	loaded := false
+	constructor := func(configMap *corev1.ConfigMap) (*Config, error) {
+		cfg, err := NewConfigFromMap(WithOverrides(configMap.Data, overrides))
+		if err == nil && validate != nil {
+			err = validate(cfg)
+		}
+		metrics.RecordConfigValid(context.Background(), err == nil)
+		if err != nil && !loaded && cfg != nil {
+			logger.Errorf("Using invalid chains config %q: %v", configMap.Name, err)
+			err = nil
+		}
+		if err == nil {
+			loaded = true
+		}
+		return cfg, err
+	}
 	return &ConfigStore{
 		UntypedStore: configmap.NewUntypedStore(
 			"chains",
 			logger,
 			configmap.Constructors{
-				ChainsConfig: NewConfigFromConfigMap,
+				ChainsConfig: constructor,
 			},
 			onAfterStore...,
 		),
@@ -85,9 +85,19 @@ func TestNewConfigStore(t *testing.T) {
 
 var defaultSigners = SignerConfigs{
 	X509: X509Signer{
-		FulcioAuth: "google",
-		FulcioAddr: "https://fulcio.sigstore.dev",
+		FulcioAuth:     "google",
+		FulcioAddr:     "https://fulcio.sigstore.dev",
+		FulcioAudience: "sigstore",
 	},
+	KMS:   KMSSigner{Vault: VaultAuth{Path: "kubernetes"}, GCP: GCPAuth{TokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token"}},
+	Probe: SignerProbePublicKey,
+}
+
+var defaultEntryTypes = TransparencyEntryTypes{
+	Tekton:        EntryTypeRekord,
+	SimpleSigning: EntryTypeRekord,
+	InToto:        EntryTypeIntoto,
+	Provenance:    EntryTypeIntoto,
 }
 
 func TestParse(t *testing.T) {
@@ -114,10 +124,15 @@ func TestParse(t *testing.T) {
 						StorageBackend: "oci",
 						Signer:         "x509",
 					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
 				},
 				Signers: defaultSigners,
 				Transparency: TransparencyConfig{
-					URL: "https://rekor.sigstore.dev",
+					URL:        "https://rekor.sigstore.dev",
+					EntryTypes: defaultEntryTypes,
 				},
 			},
 		},
@@ -139,10 +154,15 @@ func TestParse(t *testing.T) {
 						StorageBackend: "oci",
 						Signer:         "x509",
 					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
 				},
 				Signers: defaultSigners,
 				Transparency: TransparencyConfig{
-					URL: "https://rekor.sigstore.dev",
+					URL:        "https://rekor.sigstore.dev",
+					EntryTypes: defaultEntryTypes,
 				},
 			},
 		},
@@ -164,12 +184,17 @@ func TestParse(t *testing.T) {
 						StorageBackend: "oci",
 						Signer:         "x509",
 					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
 				},
 				Signers: defaultSigners,
 				Transparency: TransparencyConfig{
 					Enabled:          true,
 					VerifyAnnotation: true,
 					URL:              "https://rekor.sigstore.dev",
+					EntryTypes:       defaultEntryTypes,
 				},
 			},
 		},
@@ -191,10 +216,15 @@ func TestParse(t *testing.T) {
 						StorageBackend: "oci",
 						Signer:         "x509",
 					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
 				},
 				Signers: defaultSigners,
 				Transparency: TransparencyConfig{
-					URL: "https://rekor.sigstore.dev",
+					URL:        "https://rekor.sigstore.dev",
+					EntryTypes: defaultEntryTypes,
 				},
 			},
 		}, {
@@ -219,16 +249,24 @@ func TestParse(t *testing.T) {
 						StorageBackend: "oci",
 						Signer:         "x509",
 					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
 				},
 				Signers: SignerConfigs{
 					X509: X509Signer{
-						FulcioEnabled: true,
-						FulcioAuth:    "google",
-						FulcioAddr:    "fulcio-address",
+						FulcioEnabled:  true,
+						FulcioAuth:     "google",
+						FulcioAddr:     "fulcio-address",
+						FulcioAudience: "sigstore",
 					},
+					KMS:   KMSSigner{Vault: VaultAuth{Path: "kubernetes"}, GCP: GCPAuth{TokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token"}},
+					Probe: SignerProbePublicKey,
 				},
 				Transparency: TransparencyConfig{
-					URL: "https://rekor.sigstore.dev",
+					URL:        "https://rekor.sigstore.dev",
+					EntryTypes: defaultEntryTypes,
 				},
 			},
 		}, {
@@ -251,16 +289,24 @@ func TestParse(t *testing.T) {
 						StorageBackend: "oci",
 						Signer:         "x509",
 					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
 				},
 				Signers: SignerConfigs{
 					X509: X509Signer{
-						FulcioAuth: "google",
-						FulcioAddr: "https://fulcio.sigstore.dev",
+						FulcioAuth:     "google",
+						FulcioAddr:     "https://fulcio.sigstore.dev",
+						FulcioAudience: "sigstore",
 					},
+					KMS:   KMSSigner{Vault: VaultAuth{Path: "kubernetes"}, GCP: GCPAuth{TokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token"}},
+					Probe: SignerProbePublicKey,
 				},
 				Transparency: TransparencyConfig{
-					Enabled: true,
-					URL:     "https://rekor.sigstore.dev",
+					Enabled:    true,
+					URL:        "https://rekor.sigstore.dev",
+					EntryTypes: defaultEntryTypes,
 				},
 			},
 		}, {
@@ -283,20 +329,147 @@ func TestParse(t *testing.T) {
 						StorageBackend: "oci",
 						Signer:         "x509",
 					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
 				},
 				Signers: SignerConfigs{
 					X509: X509Signer{
-						FulcioAuth: "google",
-						FulcioAddr: "https://fulcio.sigstore.dev",
+						FulcioAuth:     "google",
+						FulcioAddr:     "https://fulcio.sigstore.dev",
+						FulcioAudience: "sigstore",
 					},
+					KMS:   KMSSigner{Vault: VaultAuth{Path: "kubernetes"}, GCP: GCPAuth{TokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token"}},
+					Probe: SignerProbePublicKey,
 				},
 				Transparency: TransparencyConfig{
 					Enabled:          true,
 					VerifyAnnotation: true,
 					URL:              "https://rekor.sigstore.dev",
+					EntryTypes:       defaultEntryTypes,
 				},
 			},
 		},
+		{
+			name: "namespaces",
+			data: map[string]string{
+				namespacesIncludeKey:    "team-a, team-b,",
+				namespacesExcludeKey:    "kube-system",
+				namespacesSelectorKey:   "chains.tekton.dev/sign=true",
+				namespacesQuotaRateKey:  "60",
+				namespacesQuotaBurstKey: "5",
+			},
+			want: Config{
+				Builder: BuilderConfig{
+					"tekton-chains",
+				},
+				Artifacts: ArtifactConfigs{
+					TaskRuns: Artifact{
+						Format:         "tekton",
+						Signer:         "x509",
+						StorageBackend: "tekton",
+					},
+					OCI: Artifact{
+						Format:         "simplesigning",
+						StorageBackend: "oci",
+						Signer:         "x509",
+					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
+				},
+				Signers: defaultSigners,
+				Transparency: TransparencyConfig{
+					URL:        "https://rekor.sigstore.dev",
+					EntryTypes: defaultEntryTypes,
+				},
+				Namespaces: NamespaceConfig{
+					Include:    []string{"team-a", "team-b"},
+					Exclude:    []string{"kube-system"},
+					Selector:   "chains.tekton.dev/sign=true",
+					QuotaRate:  60,
+					QuotaBurst: 5,
+				},
+			},
+		},
+		{
+			name: "vsa",
+			data: map[string]string{
+				vsaEnabledKey:          "true",
+				vsaVerifierIDKey:       "https://example.com/verifier",
+				vsaPolicyURIKey:        "https://example.com/policy",
+				vsaPolicyLevelKey:      "SLSA_LEVEL_2",
+				vsaPolicyBuilderIDsKey: "tekton-chains, https://tekton.dev/chains/v2",
+				vsaPolicyMaterialsKey:  "true",
+			},
+			want: Config{
+				Builder: BuilderConfig{
+					"tekton-chains",
+				},
+				Artifacts: ArtifactConfigs{
+					TaskRuns: Artifact{
+						Format:         "tekton",
+						Signer:         "x509",
+						StorageBackend: "tekton",
+					},
+					OCI: Artifact{
+						Format:         "simplesigning",
+						StorageBackend: "oci",
+						Signer:         "x509",
+					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
+				},
+				Signers: defaultSigners,
+				Transparency: TransparencyConfig{
+					URL:        "https://rekor.sigstore.dev",
+					EntryTypes: defaultEntryTypes,
+				},
+				VSA: VSAConfig{
+					Enabled:          true,
+					VerifierID:       "https://example.com/verifier",
+					PolicyURI:        "https://example.com/policy",
+					PolicyLevel:      "SLSA_LEVEL_2",
+					BuilderIDs:       []string{"tekton-chains", "https://tekton.dev/chains/v2"},
+					RequireMaterials: true,
+				},
+			},
+		},
+		{
+			name: "sbom",
+			data: map[string]string{sbomEnabledKey: "true"},
+			want: Config{
+				Builder: BuilderConfig{
+					"tekton-chains",
+				},
+				Artifacts: ArtifactConfigs{
+					TaskRuns: Artifact{
+						Format:         "tekton",
+						Signer:         "x509",
+						StorageBackend: "tekton",
+					},
+					OCI: Artifact{
+						Format:         "simplesigning",
+						StorageBackend: "oci",
+						Signer:         "x509",
+					},
+					Blobs: Artifact{
+						Format: "in-toto",
+						Signer: "x509",
+					},
+				},
+				Signers: defaultSigners,
+				Transparency: TransparencyConfig{
+					URL:        "https://rekor.sigstore.dev",
+					EntryTypes: defaultEntryTypes,
+				},
+				SBOM: SBOMConfig{Enabled: true},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -310,3 +483,948 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseGCRetention(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{gcRetentionKey: "72h"})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	if cfg.GC.Retention != 72*time.Hour {
+		t.Errorf("GC.Retention = %v, want 72h", cfg.GC.Retention)
+	}
+	if _, err := NewConfigFromMap(map[string]string{gcRetentionKey: "3 days"}); err == nil {
+		t.Error("expected error parsing invalid retention")
+	}
+}
+
+func TestParseInvalidNamespaceSelector(t *testing.T) {
+	if _, err := NewConfigFromMap(map[string]string{namespacesSelectorKey: "foo=bar=baz"}); err == nil {
+		t.Error("expected error parsing invalid namespace selector")
+	}
+}
+
+func TestParseImagesResolve(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{imagesResolveKey: "true"})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	if !cfg.Images.Resolve {
+		t.Error("Images.Resolve = false, want true")
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{sourceEnabledKey: "true"})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	if !cfg.Source.Enabled {
+		t.Error("Source.Enabled = false, want true")
+	}
+}
+
+func TestParseBlobStorage(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{blobStorageKey: "gcs"})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	if cfg.Artifacts.Blobs.StorageBackend != "gcs" {
+		t.Errorf("Blobs.StorageBackend = %s, want gcs", cfg.Artifacts.Blobs.StorageBackend)
+	}
+	// Blobs aren't images, so they can't be stored in a registry next to one.
+	if _, err := NewConfigFromMap(map[string]string{blobStorageKey: "oci"}); err == nil {
+		t.Error("expected error parsing oci blob storage")
+	}
+}
+
+func TestParseMaxConcurrent(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		kmsMaxConcurrentKey: "10",
+		ociMaxConcurrentKey: "20",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	if cfg.Signers.KMS.MaxConcurrent != 10 {
+		t.Errorf("Signers.KMS.MaxConcurrent = %d, want 10", cfg.Signers.KMS.MaxConcurrent)
+	}
+	if cfg.Storage.OCI.MaxConcurrent != 20 {
+		t.Errorf("Storage.OCI.MaxConcurrent = %d, want 20", cfg.Storage.OCI.MaxConcurrent)
+	}
+	if _, err := NewConfigFromMap(map[string]string{kmsMaxConcurrentKey: "many"}); err == nil {
+		t.Error("expected error parsing a limit that isn't a number")
+	}
+}
+
+func TestParseTektonStorage(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		tektonFallbackKey:       "oci",
+		tektonMaxAnnotationsKey: "102400",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := TektonStorageConfig{Fallback: "oci", MaxAnnotationsSize: 102400}
+	if cfg.Storage.Tekton != want {
+		t.Errorf("Storage.Tekton = %+v, want %+v", cfg.Storage.Tekton, want)
+	}
+	// Payloads can't fall back to the annotations they don't fit in.
+	if _, err := NewConfigFromMap(map[string]string{tektonFallbackKey: "tekton"}); err == nil {
+		t.Error("expected error parsing tekton fallback storage")
+	}
+}
+
+func TestParseSubjectRewrites(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{subjectsRewriteKey: `
+^mirror\.internal/(.*)$ => gcr.io/$1
+
+^gcr\.io/foo/ => gcr.io/bar/
+`})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := []SubjectRewrite{
+		{Pattern: `^mirror\.internal/(.*)$`, Replacement: "gcr.io/$1"},
+		{Pattern: `^gcr\.io/foo/`, Replacement: "gcr.io/bar/"},
+	}
+	if diff := cmp.Diff(want, cfg.Subjects.Rewrites); diff != "" {
+		t.Errorf("Rewrites: -want +got: %s", diff)
+	}
+
+	for _, invalid := range []string{"gcr.io/foo", "( => gcr.io/foo", " => gcr.io/foo"} {
+		if _, err := NewConfigFromMap(map[string]string{subjectsRewriteKey: invalid}); err == nil {
+			t.Errorf("expected error parsing rewrite %q", invalid)
+		}
+	}
+}
+
+func TestParseRedact(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		redactEnabledKey: "true",
+		redactActionKey:  "fail",
+		redactPatternsKey: `
+\bcorp-[0-9a-f]{32}\b
+
+password=(\S+)
+`,
+		redactEntropyThresholdKey: "4.5",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := RedactConfig{
+		Enabled:          true,
+		Action:           RedactActionFail,
+		Patterns:         []string{`\bcorp-[0-9a-f]{32}\b`, `password=(\S+)`},
+		EntropyThreshold: 4.5,
+	}
+	if diff := cmp.Diff(want, cfg.Redact); diff != "" {
+		t.Errorf("Redact: -want +got: %s", diff)
+	}
+
+	for _, invalid := range []map[string]string{
+		{redactActionKey: "drop"},
+		{redactPatternsKey: "("},
+		{redactEntropyThresholdKey: "high"},
+	} {
+		if _, err := NewConfigFromMap(invalid); err == nil {
+			t.Errorf("expected error parsing %v", invalid)
+		}
+	}
+}
+
+func TestParseProvenanceContext(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		provenanceLabelsKey:      "team, cost-center",
+		provenanceAnnotationsKey: "example.com/change-ticket",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := ProvenanceConfig{
+		Labels:      []string{"team", "cost-center"},
+		Annotations: []string{"example.com/change-ticket"},
+	}
+	if diff := cmp.Diff(want, cfg.Provenance); diff != "" {
+		t.Errorf("Provenance: -want +got: %s", diff)
+	}
+}
+
+func TestParseTransparencyEntryTypes(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		transparencyEntryInTotoKey:        "dsse",
+		transparencyEntrySimpleSigningKey: "hashedrekord",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := map[string]string{
+		"tekton":            EntryTypeRekord,
+		"simplesigning":     EntryTypeHashedRekord,
+		"in-toto":           EntryTypeDSSE,
+		"tekton-provenance": EntryTypeIntoto,
+		"unknown":           "",
+	}
+	for format, entryType := range want {
+		if got := cfg.Transparency.EntryType(format); got != entryType {
+			t.Errorf("EntryType(%s) = %s, want %s", format, got, entryType)
+		}
+	}
+	// Only payloads wrapped in an envelope can be uploaded as one.
+	if _, err := NewConfigFromMap(map[string]string{transparencyEntrySimpleSigningKey: "dsse"}); err == nil {
+		t.Error("expected error parsing dsse entries for simplesigning payloads")
+	}
+}
+
+func TestParseTUF(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		transparencyURLKey:       "https://rekor.example.com",
+		transparencyTUFMirrorKey: "https://tuf.example.com",
+		transparencyTUFTargetKey: "private-rekor.pub",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := TUFConfig{Mirror: "https://tuf.example.com", Target: "private-rekor.pub"}
+	if cfg.Transparency.TUF != want {
+		t.Errorf("TUF = %+v, want %+v", cfg.Transparency.TUF, want)
+	}
+}
+
+func TestParseAdditionalTransparencyURLs(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		transparencyAdditionalURLsKey: "https://rekor.example.com, https://rekor.internal.example.com",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := []string{"https://rekor.sigstore.dev", "https://rekor.example.com", "https://rekor.internal.example.com"}
+	if diff := cmp.Diff(want, cfg.Transparency.URLs()); diff != "" {
+		t.Errorf("URLs() diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseRegistryAuth(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		ociAuthKey:         "docker, k8schain",
+		ociRegistryAuthKey: "gcr.io=workload-identity, registry.example.com:5000=docker, gcr.io=docker",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	if diff := cmp.Diff([]string{AuthDocker, AuthK8sChain}, cfg.Storage.OCI.Auth); diff != "" {
+		t.Errorf("Auth diff (-want +got):\n%s", diff)
+	}
+	want := []RegistryAuth{
+		{Host: "gcr.io", Sources: []string{AuthWorkloadIdentity, AuthDocker}},
+		{Host: "registry.example.com:5000", Sources: []string{AuthDocker}},
+	}
+	if diff := cmp.Diff(want, cfg.Storage.OCI.RegistryAuth); diff != "" {
+		t.Errorf("RegistryAuth diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := NewConfigFromMap(map[string]string{ociRegistryAuthKey: "gcr.io"}); err == nil {
+		t.Error("expected an entry without a source to be rejected")
+	}
+}
+
+func TestParseRegistryConnections(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		ociRegistriesKey: `
+registry.lab:5000: insecure skip-tls-verify
+harbor.example.com: ca-certs=/etc/chains/harbor-ca.pem
+localhost:5000
+`,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := []RegistryConnection{
+		{Host: "registry.lab:5000", Insecure: true, SkipTLSVerify: true},
+		{Host: "harbor.example.com", CACerts: "/etc/chains/harbor-ca.pem"},
+		{Host: "localhost:5000"},
+	}
+	if diff := cmp.Diff(want, cfg.Storage.OCI.Registries); diff != "" {
+		t.Errorf("Registries diff (-want +got):\n%s", diff)
+	}
+
+	for _, raw := range []string{"gcr.io: plaintext", "gcr.io: ca-certs=", "gcr.io/foo: insecure", "gcr.io: insecure\ngcr.io: skip-tls-verify"} {
+		if _, err := NewConfigFromMap(map[string]string{ociRegistriesKey: raw}); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestParseRepositoryMappings(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		ociMappingsKey: `
+^staging\.example\.com/(.*)$ => prod.example.com/$1
+^gcr\.io/team/ => gcr.io/mirror/ also
+`,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := []RepositoryMapping{
+		{Pattern: `^staging\.example\.com/(.*)$`, Replacement: "prod.example.com/$1"},
+		{Pattern: `^gcr\.io/team/`, Replacement: "gcr.io/mirror/", Also: true},
+	}
+	if diff := cmp.Diff(want, cfg.Storage.OCI.Mappings); diff != "" {
+		t.Errorf("Mappings diff (-want +got):\n%s", diff)
+	}
+
+	for _, raw := range []string{"gcr.io/foo", "gcr.io/foo =>", "gcr.io/foo => gcr.io/bar instead", "( => gcr.io/bar"} {
+		if _, err := NewConfigFromMap(map[string]string{ociMappingsKey: raw}); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestParseStorageRoutes(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		storageRoutesKey: `
+simplesigning: oci
+in-toto: oci,gcs transparency
+tekton: tekton no-transparency
+`,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	upload, noUpload := true, false
+	want := []StorageRoute{
+		{Format: "simplesigning", Backends: []string{"oci"}},
+		{Format: "in-toto", Backends: []string{"oci", "gcs"}, Transparency: &upload},
+		{Format: "tekton", Backends: []string{"tekton"}, Transparency: &noUpload},
+	}
+	if diff := cmp.Diff(want, cfg.Storage.Routes); diff != "" {
+		t.Errorf("Routes diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"oci", "gcs"}, cfg.Storage.StorageBackends("in-toto", "tekton")); diff != "" {
+		t.Errorf("StorageBackends() of a routed format diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"tekton"}, cfg.Storage.StorageBackends("tekton-provenance", "tekton")); diff != "" {
+		t.Errorf("StorageBackends() of a format without a route diff (-want +got):\n%s", diff)
+	}
+
+	for _, raw := range []string{
+		"in-toto",
+		"in-toto:",
+		"cyclonedx: oci",
+		"in-toto: s3",
+		"in-toto: oci,oci",
+		"in-toto: oci always",
+		"in-toto: oci\nin-toto: gcs",
+	} {
+		if _, err := NewConfigFromMap(map[string]string{storageRoutesKey: raw}); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestParseSignerProfiles(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		signerProfilesKey: `
+release-hsm: kms gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k
+dev-cosign: x509
+ci: x509 fulcio
+`,
+		taskrunSignerKey: "release-hsm",
+		ociSignerKey:     "dev-cosign",
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := []SignerProfile{
+		{Name: "release-hsm", Type: SignerKMS, KMSRef: "gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k"},
+		{Name: "dev-cosign", Type: SignerX509},
+		{Name: "ci", Type: SignerX509, Fulcio: true},
+	}
+	if diff := cmp.Diff(want, cfg.Signers.Profiles); diff != "" {
+		t.Errorf("Profiles diff (-want +got):\n%s", diff)
+	}
+	if cfg.Artifacts.TaskRuns.Signer != "release-hsm" || cfg.Artifacts.OCI.Signer != "dev-cosign" {
+		t.Errorf("expected the artifacts to be signed by their profiles, got %s and %s", cfg.Artifacts.TaskRuns.Signer, cfg.Artifacts.OCI.Signer)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	for _, invalid := range []string{
+		"release-hsm: kms",
+		"dev: x509 cosign",
+		"dev x509",
+		"Dev: x509",
+		"kms: kms gcpkms://foo",
+		"dev: x509\ndev: x509 fulcio",
+		"dev: pgp",
+	} {
+		if _, err := NewConfigFromMap(map[string]string{signerProfilesKey: invalid}); err == nil {
+			t.Errorf("expected profiles %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestParseNamespaceSigners(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		signerProfilesKey: "release-hsm: kms gcpkms://release\ndev-cosign: x509",
+		signerNamespacesKey: `
+team-a: dev-cosign
+release: release-hsm, dev-cosign
+`,
+	})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want := []NamespaceSigners{
+		{Namespace: "team-a", Signers: []string{"dev-cosign"}},
+		{Namespace: "release", Signers: []string{"release-hsm", "dev-cosign"}},
+	}
+	if diff := cmp.Diff(want, cfg.Signers.Namespaces); diff != "" {
+		t.Errorf("Namespaces diff (-want +got):\n%s", diff)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	for _, invalid := range []string{
+		"team-a",
+		"team-a:",
+		"team-a: Dev",
+		"team-a: x509\nteam-a: kms",
+	} {
+		if _, err := NewConfigFromMap(map[string]string{signerNamespacesKey: invalid}); err == nil {
+			t.Errorf("expected namespaces %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestWithNamespaceOverrides(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		signerProfilesKey:   "release-hsm: kms gcpkms://release\ndev-cosign: x509",
+		signerNamespacesKey: "team-a: dev-cosign",
+		taskrunSignerKey:    "x509",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		data      map[string]string
+		wantErr   bool
+	}{
+		{name: "cluster signer", namespace: "team-b", data: map[string]string{taskrunSignerKey: "x509"}},
+		{name: "allowed signer", namespace: "team-a", data: map[string]string{taskrunSignerKey: "dev-cosign", ociSignerKey: "dev-cosign"}},
+		{name: "signer not allowed", namespace: "team-a", data: map[string]string{taskrunSignerKey: "release-hsm"}, wantErr: true},
+		{name: "namespace not listed", namespace: "team-b", data: map[string]string{blobSignerKey: "dev-cosign"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cfg.WithNamespaceOverrides(tt.namespace, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WithNamespaceOverrides() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Artifacts.TaskRuns.Signer != tt.data[taskrunSignerKey] && tt.data[taskrunSignerKey] != "" {
+				t.Errorf("TaskRun signer = %s, want %s", got.Artifacts.TaskRuns.Signer, tt.data[taskrunSignerKey])
+			}
+		})
+	}
+}
+
+func TestResolveSigner(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{
+		kmsSignerKMSRef:   "gcpkms://default",
+		signerProfilesKey: "release: kms gcpkms://release\nci: x509 fulcio",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, got := cfg.ResolveSigner("release")
+	if p.Name != "release" || p.KeyPrefix() != "release." || got.Signers.KMS.KMSRef != "gcpkms://release" {
+		t.Errorf("ResolveSigner(release) = %+v with kmsref %s", p, got.Signers.KMS.KMSRef)
+	}
+	p, got = cfg.ResolveSigner("ci")
+	if p.Type != SignerX509 || !got.Signers.X509.FulcioEnabled {
+		t.Errorf("ResolveSigner(ci) = %+v with fulcio enabled %v", p, got.Signers.X509.FulcioEnabled)
+	}
+	p, got = cfg.ResolveSigner(SignerKMS)
+	if p.Name != "" || p.KeyPrefix() != "" || p.Type != SignerKMS || got.Signers.KMS.KMSRef != "gcpkms://default" {
+		t.Errorf("ResolveSigner(kms) = %+v with kmsref %s", p, got.Signers.KMS.KMSRef)
+	}
+	if cfg.Signers.KMS.KMSRef != "gcpkms://default" || cfg.Signers.X509.FulcioEnabled {
+		t.Error("expected resolving a profile not to change the config")
+	}
+}
+
+func TestParseTransparencyAsync(t *testing.T) {
+	cfg, err := NewConfigFromMap(map[string]string{transparencyAsyncKey: "true"})
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	if !cfg.Transparency.Async {
+		t.Error("expected transparency.async to be parsed")
+	}
+}
+
+func TestNewValidatingConfigStoreWithOverrides(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+
+	ns := system.Namespace()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "chains-config",
+			Namespace: ns,
+		},
+		Data: map[string]string{taskrunFormatKey: "tekton", taskrunSignerKey: "kms"},
+	}
+	fakekubeclient := fakek8s.NewSimpleClientset(cm)
+	cmw := informer.NewInformedWatcher(fakekubeclient, system.Namespace())
+
+	cs := NewValidatingConfigStoreWithOverrides(logtesting.TestLogger(t), nil, map[string]string{taskrunFormatKey: "in-toto"})
+	cs.WatchConfigs(cmw)
+	if err := cmw.Start(ctx.Done()); err != nil {
+		t.Fatalf("Error starting configmap.Watcher %v", err)
+	}
+
+	got := cs.Load().Artifacts.TaskRuns
+	if got.Format != "in-toto" {
+		t.Errorf("format = %s, want the override", got.Format)
+	}
+	if got.Signer != "kms" {
+		t.Errorf("signer = %s, want the one in the ConfigMap", got.Signer)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	got, err := FromEnv([]string{
+		"CHAINS_ARTIFACTS_TASKRUN_FORMAT=in-toto",
+		"CHAINS_TRANSPARENCY_ADDITIONAL_URLS=https://rekor.example.com",
+		"CHAINS_SIGNERS_KMS_KMSREF=gcpkms://projects/foo=bar",
+		"SYSTEM_NAMESPACE=tekton-chains",
+	})
+	if err != nil {
+		t.Fatalf("FromEnv() = %v", err)
+	}
+	want := map[string]string{
+		taskrunFormatKey:              "in-toto",
+		transparencyAdditionalURLsKey: "https://rekor.example.com",
+		kmsSignerKMSRef:               "gcpkms://projects/foo=bar",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("FromEnv() diff (-want +got):\n%s", diff)
+	}
+
+	if _, err := FromEnv([]string{"CHAINS_ARTIFACTS_TASKRUN_FROMAT=in-toto"}); err == nil {
+		t.Error("expected a variable that doesn't set a key to be rejected")
+	}
+}
+
+func TestEnvVarsAreUnique(t *testing.T) {
+	seen := map[string]string{}
+	for _, k := range knownKeys.List() {
+		if other, ok := seen[EnvVar(k)]; ok {
+			t.Errorf("%s and %s are both set by %s", k, other, EnvVar(k))
+		}
+		seen[EnvVar(k)] = k
+	}
+}
+
+func TestParseOverride(t *testing.T) {
+	key, value, err := ParseOverride("subjects.rewrite=^a$ => b")
+	if err != nil {
+		t.Fatalf("ParseOverride() = %v", err)
+	}
+	if key != subjectsRewriteKey || value != "^a$ => b" {
+		t.Errorf("ParseOverride() = %s, %s", key, value)
+	}
+	for _, s := range []string{"artifacts.taskrun.format", "artifacts.taskrun.fromat=in-toto"} {
+		if _, _, err := ParseOverride(s); err == nil {
+			t.Errorf("ParseOverride(%q) succeeded, wanted an error", s)
+		}
+	}
+}
+
+func TestNewValidatingConfigStore(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+
+	ns := system.Namespace()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "chains-config",
+			Namespace: ns,
+		},
+		Data: map[string]string{taskrunSignerKey: "kms"},
+	}
+	fakekubeclient := fakek8s.NewSimpleClientset(cm)
+	cmw := informer.NewInformedWatcher(fakekubeclient, system.Namespace())
+
+	validate := func(cfg *Config) error {
+		return cfg.Validate()
+	}
+	cs := NewValidatingConfigStore(logtesting.TestLogger(t), validate)
+	cs.WatchConfigs(cmw)
+	if err := cmw.Start(ctx.Done()); err != nil {
+		t.Fatalf("Error starting configmap.Watcher %v", err)
+	}
+
+	// An invalid initial config is still loaded, there is nothing to fall back to.
+	if diff := cmp.Diff("kms", cs.Load().Artifacts.TaskRuns.Signer); diff != "" {
+		t.Error(diff)
+	}
+
+	// A valid update is applied.
+	cm.Data[kmsSignerKMSRef] = "gcpkms://foo"
+	var err error
+	if cm, err = fakekubeclient.CoreV1().ConfigMaps(ns).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Errorf("error updating configmap: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if diff := cmp.Diff("gcpkms://foo", cs.Load().Signers.KMS.KMSRef); diff != "" {
+		t.Error(diff)
+	}
+
+	// An invalid update is rejected and the last valid config is kept.
+	cm.Data[taskrunStorageKey] = "gcs"
+	if _, err := fakekubeclient.CoreV1().ConfigMaps(ns).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Errorf("error updating configmap: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if diff := cmp.Diff("tekton", cs.Load().Artifacts.TaskRuns.StorageBackend); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			data: map[string]string{},
+		}, {
+			name:    "kms without ref",
+			data:    map[string]string{ociSignerKey: "kms"},
+			wantErr: true,
+		}, {
+			name: "kms with ref",
+			data: map[string]string{ociSignerKey: "kms", kmsSignerKMSRef: "gcpkms://foo"},
+		}, {
+			name: "fulcio with service account identities",
+			data: map[string]string{x509SignerFulcioEnabled: "true", x509SignerFulcioAuth: "serviceaccount", x509SignerFulcioAudience: "fulcio.example.com"},
+		}, {
+			name:    "fulcio with an unknown auth",
+			data:    map[string]string{signerProfilesKey: "ci: x509 fulcio", x509SignerFulcioAuth: "github"},
+			wantErr: true,
+		}, {
+			name:    "fulcio without an audience",
+			data:    map[string]string{x509SignerFulcioEnabled: "true", x509SignerFulcioAudience: ""},
+			wantErr: true,
+		}, {
+			name: "keys in the taskrun's namespace",
+			data: map[string]string{x509NamespaceSecretKey: "chains-signing-secrets"},
+		}, {
+			name:    "keys in a secret with an invalid name",
+			data:    map[string]string{x509NamespaceSecretKey: "Signing_Secrets"},
+			wantErr: true,
+		}, {
+			name: "tuf mirror",
+			data: map[string]string{transparencyTUFMirrorKey: "https://tuf.example.com", transparencyTUFTargetKey: "private-rekor.pub"},
+		}, {
+			name: "additional transparency logs",
+			data: map[string]string{transparencyAdditionalURLsKey: "https://rekor.example.com"},
+		}, {
+			name: "gcs kms key",
+			data: map[string]string{gcsKMSKeyKey: "projects/p/locations/us/keyRings/r/cryptoKeys/k", gcsHoldKey: "event-based", gcsRetentionKey: "2160h"},
+		}, {
+			name: "vault kubernetes auth",
+			data: map[string]string{kmsSignerKMSRef: "hashivault://chains", kmsVaultRoleKey: "tekton-chains"},
+		}, {
+			name:    "vault role without an auth path",
+			data:    map[string]string{kmsVaultRoleKey: "tekton-chains", kmsVaultAuthPathKey: ""},
+			wantErr: true,
+		}, {
+			name: "aws irsa with a role chain",
+			data: map[string]string{
+				kmsSignerKMSRef:   "awskms:///arn:aws:kms:us-east-1:111122223333:key/1234abcd",
+				kmsAWSIdentityKey: "irsa",
+				kmsAWSRoleARNKey:  "arn:aws:iam::111122223333:role/chains",
+				kmsAWSAssumeRoles: "arn:aws:iam::444455556666:role/signer",
+			},
+		}, {
+			name:    "aws assume role that isn't a role",
+			data:    map[string]string{kmsAWSIdentityKey: "pod-identity", kmsAWSAssumeRoles: "arn:aws:iam::444455556666:user/signer"},
+			wantErr: true,
+		}, {
+			name:    "aws assume roles without an identity",
+			data:    map[string]string{kmsAWSAssumeRoles: "arn:aws:iam::444455556666:role/signer"},
+			wantErr: true,
+		}, {
+			name: "gcp workload identity federation",
+			data: map[string]string{
+				kmsSignerKMSRef:      "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k",
+				kmsGCPAudienceKey:    "//iam.googleapis.com/projects/123456789/locations/global/workloadIdentityPools/chains/providers/cluster",
+				kmsGCPServiceAcctKey: "chains@p.iam.gserviceaccount.com",
+				kmsGCPTokenPathKey:   "/var/run/secrets/chains/token",
+			},
+		}, {
+			name:    "gcp audience that isn't a provider",
+			data:    map[string]string{kmsGCPAudienceKey: "projects/123456789/locations/global/workloadIdentityPools/chains"},
+			wantErr: true,
+		}, {
+			name:    "gcp service account without an audience",
+			data:    map[string]string{kmsGCPServiceAcctKey: "chains@p.iam.gserviceaccount.com"},
+			wantErr: true,
+		}, {
+			name:    "gcp service account that isn't an email",
+			data:    map[string]string{kmsGCPAudienceKey: "//iam.googleapis.com/projects/123456789/locations/global/workloadIdentityPools/chains/providers/cluster", kmsGCPServiceAcctKey: "chains"},
+			wantErr: true,
+		}, {
+			name: "signer probe signs",
+			data: map[string]string{signerProbeKey: "sign"},
+		}, {
+			name: "sealing key",
+			data: map[string]string{x509SealingKeyKey: "projects/p/locations/global/keyRings/r/cryptoKeys/seal"},
+		}, {
+			name:    "sealing key that isn't a cloud kms key",
+			data:    map[string]string{x509SealingKeyKey: "gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/seal"},
+			wantErr: true,
+		}, {
+			name:    "gcs kms key that isn't one",
+			data:    map[string]string{gcsKMSKeyKey: "gcpkms://projects/p/locations/us/keyRings/r/cryptoKeys/k"},
+			wantErr: true,
+		}, {
+			name:    "negative gcs retention",
+			data:    map[string]string{gcsRetentionKey: "-1h"},
+			wantErr: true,
+		}, {
+			name:    "route to gcs without a bucket",
+			data:    map[string]string{storageRoutesKey: "in-toto: oci,gcs"},
+			wantErr: true,
+		}, {
+			name: "route to gcs with a bucket",
+			data: map[string]string{storageRoutesKey: "in-toto: oci,gcs", gcsBucketKey: "attestations"},
+		}, {
+			name:    "additional transparency log without scheme",
+			data:    map[string]string{transparencyAdditionalURLsKey: "rekor.example.com"},
+			wantErr: true,
+		}, {
+			name:    "additional transparency log that is the main one",
+			data:    map[string]string{transparencyAdditionalURLsKey: "https://rekor.sigstore.dev"},
+			wantErr: true,
+		}, {
+			name: "registry auth",
+			data: map[string]string{ociAuthKey: "docker, workload-identity", ociRegistryAuthKey: "gcr.io=workload-identity"},
+		}, {
+			name:    "unknown auth source",
+			data:    map[string]string{ociAuthKey: "vault"},
+			wantErr: true,
+		}, {
+			name:    "unknown registry auth source",
+			data:    map[string]string{ociRegistryAuthKey: "gcr.io=google"},
+			wantErr: true,
+		}, {
+			name: "credential helper and secrets",
+			data: map[string]string{ociAuthKey: "helper:ecr-login, secret:registry-creds", ociRegistryAuthKey: "gcr.io=secret:tekton-chains/gcr-creds"},
+		}, {
+			name:    "credential helper path",
+			data:    map[string]string{ociAuthKey: "helper:../../bin/sh"},
+			wantErr: true,
+		}, {
+			name:    "invalid secret",
+			data:    map[string]string{ociRegistryAuthKey: "gcr.io=secret:Creds"},
+			wantErr: true,
+		}, {
+			name:    "tuf mirror without scheme",
+			data:    map[string]string{transparencyTUFMirrorKey: "tuf.example.com"},
+			wantErr: true,
+		}, {
+			name:    "undefined signer profile",
+			data:    map[string]string{taskrunSignerKey: "release"},
+			wantErr: true,
+		}, {
+			name: "signer profile",
+			data: map[string]string{taskrunSignerKey: "release", signerProfilesKey: "release: kms gcpkms://foo"},
+		}, {
+			name:    "undefined countersigner",
+			data:    map[string]string{countersignerKey: "notary"},
+			wantErr: true,
+		}, {
+			name:    "kms countersigner without kmsref",
+			data:    map[string]string{countersignerKey: "kms"},
+			wantErr: true,
+		}, {
+			name: "countersigner profile",
+			data: map[string]string{countersignerKey: "notary", signerProfilesKey: "notary: kms gcpkms://notary"},
+		}, {
+			name:    "namespace signer undefined",
+			data:    map[string]string{signerNamespacesKey: "team-a: release"},
+			wantErr: true,
+		}, {
+			name:    "invalid namespace",
+			data:    map[string]string{signerNamespacesKey: "Team_A: x509"},
+			wantErr: true,
+		}, {
+			name: "namespace signers",
+			data: map[string]string{signerNamespacesKey: "team-a: release, x509", signerProfilesKey: "release: kms gcpkms://release"},
+		}, {
+			name:    "gcs without bucket",
+			data:    map[string]string{taskrunStorageKey: "gcs"},
+			wantErr: true,
+		}, {
+			name: "gcs with bucket",
+			data: map[string]string{taskrunStorageKey: "gcs", gcsBucketKey: "bucket"},
+		}, {
+			name:    "docdb without url",
+			data:    map[string]string{ociStorageKey: "docdb"},
+			wantErr: true,
+		}, {
+			name:    "tekton fallback gcs without bucket",
+			data:    map[string]string{tektonFallbackKey: "gcs"},
+			wantErr: true,
+		}, {
+			name: "tekton fallback oci",
+			data: map[string]string{tektonFallbackKey: "oci", tektonMaxAnnotationsKey: "1024"},
+		}, {
+			name: "registry proxy",
+			data: map[string]string{ociProxyKey: "http://proxy.example.com:3128", ociCACertsKey: "/etc/chains/registry-ca.pem"},
+		}, {
+			name:    "registry proxy without scheme",
+			data:    map[string]string{ociProxyKey: "proxy.example.com:3128"},
+			wantErr: true,
+		}, {
+			name: "namespace quota",
+			data: map[string]string{namespacesQuotaRateKey: "30", namespacesQuotaBurstKey: "10"},
+		}, {
+			name:    "negative namespace quota",
+			data:    map[string]string{namespacesQuotaRateKey: "-1"},
+			wantErr: true,
+		}, {
+			name:    "invalid provenance label key",
+			data:    map[string]string{provenanceLabelsKey: "team, not a key"},
+			wantErr: true,
+		}, {
+			name:    "negative entropy threshold",
+			data:    map[string]string{redactEntropyThresholdKey: "-1"},
+			wantErr: true,
+		}, {
+			name:    "negative kms limit",
+			data:    map[string]string{kmsMaxConcurrentKey: "-1"},
+			wantErr: true,
+		}, {
+			name:    "negative registry limit",
+			data:    map[string]string{ociMaxConcurrentKey: "-1"},
+			wantErr: true,
+		}, {
+			name:    "negative max annotations size",
+			data:    map[string]string{tektonMaxAnnotationsKey: "-1"},
+			wantErr: true,
+		}, {
+			name:    "blob gcs without bucket",
+			data:    map[string]string{blobStorageKey: "gcs"},
+			wantErr: true,
+		}, {
+			name:    "transparency without url",
+			data:    map[string]string{transparencyEnabledKey: "true", transparencyURLKey: ""},
+			wantErr: true,
+		}, {
+			name:    "vsa without in-toto",
+			data:    map[string]string{vsaEnabledKey: "true", vsaPolicyURIKey: "https://example.com/policy"},
+			wantErr: true,
+		}, {
+			name:    "vsa without policy",
+			data:    map[string]string{vsaEnabledKey: "true", taskrunFormatKey: "in-toto"},
+			wantErr: true,
+		}, {
+			name: "vsa",
+			data: map[string]string{vsaEnabledKey: "true", taskrunFormatKey: "in-toto", vsaPolicyURIKey: "https://example.com/policy"},
+		}, {
+			name:    "definitions without key",
+			data:    map[string]string{definitionsVerifyEnabledKey: "true"},
+			wantErr: true,
+		}, {
+			name:    "definitions required without verification",
+			data:    map[string]string{definitionsVerifyRequiredKey: "true", definitionsVerifyKeyKey: "cosign.pub"},
+			wantErr: true,
+		}, {
+			name: "definitions",
+			data: map[string]string{definitionsVerifyEnabledKey: "true", definitionsVerifyKeyKey: "cosign.pub", definitionsVerifyRequiredKey: "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewConfigFromMap(tt.data)
+			if err != nil {
+				t.Fatalf("NewConfigFromMap() = %v", err)
+			}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Configs that aren't parsed from chains-config, like those of ChainsConfig resources, can have
+// any backend or format.
+func TestValidate_storageNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr bool
+	}{
+		{name: "defaults", mutate: func(*Config) {}},
+		{name: "unknown taskrun backend", mutate: func(c *Config) { c.Artifacts.TaskRuns.StorageBackend = "tekotn" }, wantErr: true},
+		{name: "blobs in oci", mutate: func(c *Config) { c.Artifacts.Blobs.StorageBackend = "oci" }, wantErr: true},
+		{name: "unknown oci format", mutate: func(c *Config) { c.Artifacts.OCI.Format = "in-toto" }, wantErr: true},
+		{name: "tekton fallback", mutate: func(c *Config) { c.Storage.Tekton.Fallback = "tekton" }, wantErr: true},
+		{name: "unknown routed backend", mutate: func(c *Config) {
+			c.Storage.Routes = []StorageRoute{{Format: "in-toto", Backends: []string{"s3"}}}
+		}, wantErr: true},
+		{name: "unknown routed format", mutate: func(c *Config) {
+			c.Storage.Routes = []StorageRoute{{Format: "spdx", Backends: []string{"oci"}}}
+		}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewConfigFromMap(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.mutate(cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	got := UnknownKeys(map[string]string{
+		taskrunFormatKey:           "in-toto",
+		signingTimeoutKey:          "1m",
+		"artifacts.taskrun.fromat": "in-toto",
+		"_example":                 "...",
+		"storage.gcs.bukcet":       "bucket",
+	})
+	want := []string{"artifacts.taskrun.fromat", "storage.gcs.bukcet"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("UnknownKeys() (-want, +got) = %s", diff)
+	}
+}
+
+func TestUnknownOverrideKeys(t *testing.T) {
+	got := UnknownOverrideKeys(map[string]string{
+		taskrunFormatKey: "in-toto",
+		ociStorageKey:    "oci",
+		gcsBucketKey:     "bucket",
+		"_example":       "...",
+	})
+	want := []string{gcsBucketKey}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("UnknownOverrideKeys() (-want, +got) = %s", diff)
+	}
+}
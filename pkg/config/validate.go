@@ -0,0 +1,364 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Validator checks a parsed Config, for example against the secrets and services it refers to.
+type Validator func(*Config) error
+
+// Validate checks that the settings each configured signer and storage backend
+// needs are present.
+func (c *Config) Validate() error {
+	var merr *multierror.Error
+	signers := sets.NewString(SignerX509, SignerKMS)
+	for _, p := range c.Signers.Profiles {
+		signers.Insert(p.Name)
+	}
+	for name, a := range map[string]Artifact{"taskrun": c.Artifacts.TaskRuns, "oci": c.Artifacts.OCI, "blob": c.Artifacts.Blobs} {
+		if a.Signer != "" && !signers.Has(a.Signer) {
+			merr = multierror.Append(merr, fmt.Errorf("artifacts.%s.signer is %s, which is not a signer or signer profile, wanted one of %v", name, a.Signer, signers.List()))
+		}
+		if a.Signer == SignerKMS && c.Signers.KMS.KMSRef == "" {
+			merr = multierror.Append(merr, fmt.Errorf("artifacts.%s.signer is kms but %s is not set", name, kmsSignerKMSRef))
+		}
+		if !artifactFormats[name].Has(a.Format) {
+			merr = multierror.Append(merr, fmt.Errorf("artifacts.%s.format is %q, wanted one of %v", name, a.Format, artifactFormats[name].List()))
+		}
+		key := fmt.Sprintf("artifacts.%s.storage", name)
+		switch {
+		case name == "blob" && a.StorageBackend == "":
+			// Blobs aren't signed without a storage backend.
+		case storageBackends.Has(a.StorageBackend) && !artifactBackends[name].Has(a.StorageBackend):
+			merr = multierror.Append(merr, fmt.Errorf("%s is %q, wanted one of %v", key, a.StorageBackend, artifactBackends[name].List()))
+		default:
+			if err := c.validateStorage(key, a.StorageBackend); err != nil {
+				merr = multierror.Append(merr, err)
+			}
+		}
+	}
+	for _, n := range c.Signers.Namespaces {
+		if errs := validation.IsDNS1123Label(n.Namespace); len(errs) > 0 {
+			merr = multierror.Append(merr, fmt.Errorf("%s has invalid namespace %q: %s", signerNamespacesKey, n.Namespace, strings.Join(errs, ", ")))
+		}
+		for _, s := range n.Signers {
+			if !signers.Has(s) {
+				merr = multierror.Append(merr, fmt.Errorf("%s allows namespace %s to sign with %s, which is not a signer or signer profile, wanted one of %v", signerNamespacesKey, n.Namespace, s, signers.List()))
+			}
+		}
+	}
+	if s := c.Signers.Countersigner; s != "" && !signers.Has(s) {
+		merr = multierror.Append(merr, fmt.Errorf("%s is %s, which is not a signer or signer profile, wanted one of %v", countersignerKey, s, signers.List()))
+	}
+	if c.Signers.Countersigner == SignerKMS && c.Signers.KMS.KMSRef == "" {
+		merr = multierror.Append(merr, fmt.Errorf("%s is kms but %s is not set", countersignerKey, kmsSignerKMSRef))
+	}
+	if c.usesFulcio() {
+		if a := c.Signers.X509.FulcioAuth; a != FulcioAuthGoogle && a != FulcioAuthServiceAccount {
+			merr = multierror.Append(merr, fmt.Errorf("%s is %s, wanted %s or %s", x509SignerFulcioAuth, a, FulcioAuthGoogle, FulcioAuthServiceAccount))
+		}
+		if c.Signers.X509.FulcioAudience == "" {
+			merr = multierror.Append(merr, fmt.Errorf("%s can't be empty", x509SignerFulcioAudience))
+		}
+	}
+	if fb := c.Storage.Tekton.Fallback; fb != "" {
+		if !fallbackBackends.Has(fb) {
+			merr = multierror.Append(merr, fmt.Errorf("%s is %q, wanted one of %v", tektonFallbackKey, fb, fallbackBackends.List()))
+		} else if err := c.validateStorage(tektonFallbackKey, fb); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	for _, r := range c.Storage.Routes {
+		if !payloadFormats.Has(r.Format) {
+			merr = multierror.Append(merr, fmt.Errorf("%s routes unknown format %q, wanted one of %v", storageRoutesKey, r.Format, payloadFormats.List()))
+		}
+		for _, b := range r.Backends {
+			if err := c.validateStorage(fmt.Sprintf("%s for %s", storageRoutesKey, r.Format), b); err != nil {
+				merr = multierror.Append(merr, err)
+			}
+		}
+		if r.Transparency != nil && *r.Transparency && c.Transparency.URL == "" {
+			merr = multierror.Append(merr, fmt.Errorf("%s uploads %s to the transparency log but %s is empty", storageRoutesKey, r.Format, transparencyURLKey))
+		}
+	}
+	if c.Namespaces.QuotaRate < 0 {
+		merr = multierror.Append(merr, fmt.Errorf("%s is %d, it can't be negative", namespacesQuotaRateKey, c.Namespaces.QuotaRate))
+	}
+	if c.Namespaces.QuotaBurst < 0 {
+		merr = multierror.Append(merr, fmt.Errorf("%s is %d, it can't be negative", namespacesQuotaBurstKey, c.Namespaces.QuotaBurst))
+	}
+	if c.Signers.KMS.Vault.Role != "" && c.Signers.KMS.Vault.Path == "" {
+		merr = multierror.Append(merr, fmt.Errorf("%s is set but %s is empty", kmsVaultRoleKey, kmsVaultAuthPathKey))
+	}
+	if len(c.Signers.KMS.AWS.AssumeRoles) > 0 && c.Signers.KMS.AWS.Identity == "" {
+		merr = multierror.Append(merr, fmt.Errorf("%s needs %s, the identity the first role is assumed with", kmsAWSAssumeRoles, kmsAWSIdentityKey))
+	}
+	for key, roles := range map[string][]string{kmsAWSRoleARNKey: {c.Signers.KMS.AWS.RoleARN}, kmsAWSAssumeRoles: c.Signers.KMS.AWS.AssumeRoles} {
+		for _, r := range roles {
+			if r != "" && !iamRolePattern.MatchString(r) {
+				merr = multierror.Append(merr, fmt.Errorf("%s %q is not the ARN of an IAM role", key, r))
+			}
+		}
+	}
+	if a := c.Signers.KMS.GCP.Audience; a != "" && !gcpProviderPattern.MatchString(a) {
+		merr = multierror.Append(merr, fmt.Errorf("%s %q is not a workload identity pool provider, wanted //iam.googleapis.com/projects/<number>/locations/global/workloadIdentityPools/<pool>/providers/<provider>", kmsGCPAudienceKey, a))
+	}
+	if sa := c.Signers.KMS.GCP.ServiceAccount; sa != "" {
+		if c.Signers.KMS.GCP.Audience == "" {
+			merr = multierror.Append(merr, fmt.Errorf("%s is impersonated with a federated token, it needs %s", kmsGCPServiceAcctKey, kmsGCPAudienceKey))
+		}
+		if !strings.Contains(sa, "@") {
+			merr = multierror.Append(merr, fmt.Errorf("%s %q is not the email of a service account", kmsGCPServiceAcctKey, sa))
+		}
+	}
+	if k := c.Signers.X509.SealingKey; k != "" && !kmsKeyPattern.MatchString(k) {
+		merr = multierror.Append(merr, fmt.Errorf("%s %q is not a Cloud KMS key, wanted projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>", x509SealingKeyKey, k))
+	}
+	if s := c.Signers.X509.NamespaceSecret; s != "" {
+		if errs := validation.IsDNS1123Subdomain(s); len(errs) > 0 {
+			merr = multierror.Append(merr, fmt.Errorf("%s %q is not the name of a secret: %s", x509NamespaceSecretKey, s, strings.Join(errs, ", ")))
+		}
+	}
+	if c.Signers.KMS.MaxConcurrent < 0 {
+		merr = multierror.Append(merr, fmt.Errorf("%s is %d, it can't be negative", kmsMaxConcurrentKey, c.Signers.KMS.MaxConcurrent))
+	}
+	if c.Storage.OCI.MaxConcurrent < 0 {
+		merr = multierror.Append(merr, fmt.Errorf("%s is %d, it can't be negative", ociMaxConcurrentKey, c.Storage.OCI.MaxConcurrent))
+	}
+	if k := c.Storage.GCS.KMSKey; k != "" && !kmsKeyPattern.MatchString(k) {
+		merr = multierror.Append(merr, fmt.Errorf("%s %q is not a Cloud KMS key, wanted projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>", gcsKMSKeyKey, k))
+	}
+	if c.Storage.GCS.Retention < 0 {
+		merr = multierror.Append(merr, fmt.Errorf("%s is %s, it can't be negative", gcsRetentionKey, c.Storage.GCS.Retention))
+	}
+	if c.Storage.Tekton.MaxAnnotationsSize < 0 {
+		merr = multierror.Append(merr, fmt.Errorf("%s is %d, it can't be negative", tektonMaxAnnotationsKey, c.Storage.Tekton.MaxAnnotationsSize))
+	}
+	for _, s := range c.Storage.OCI.Auth {
+		if err := validateAuthSource(s); err != nil {
+			merr = multierror.Append(merr, fmt.Errorf("%s %w", ociAuthKey, err))
+		}
+	}
+	for _, r := range c.Storage.OCI.RegistryAuth {
+		for _, s := range r.Sources {
+			if err := validateAuthSource(s); err != nil {
+				merr = multierror.Append(merr, fmt.Errorf("%s for %s %w", ociRegistryAuthKey, r.Host, err))
+			}
+		}
+	}
+	if c.Transparency.Enabled && c.Transparency.URL == "" {
+		merr = multierror.Append(merr, fmt.Errorf("%s is enabled but %s is empty", transparencyEnabledKey, transparencyURLKey))
+	}
+	seen := map[string]bool{c.Transparency.URL: true}
+	for _, u := range c.Transparency.AdditionalURLs {
+		switch {
+		case !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://"):
+			merr = multierror.Append(merr, fmt.Errorf("%s %q is not an http(s) URL", transparencyAdditionalURLsKey, u))
+		case seen[u]:
+			merr = multierror.Append(merr, fmt.Errorf("%s lists %q more than once, or with %s", transparencyAdditionalURLsKey, u, transparencyURLKey))
+		}
+		seen[u] = true
+	}
+	if p := c.Storage.OCI.Proxy; p != "" && !strings.HasPrefix(p, "https://") && !strings.HasPrefix(p, "http://") {
+		merr = multierror.Append(merr, fmt.Errorf("%s %q is not an http(s) URL", ociProxyKey, p))
+	}
+	if m := c.Transparency.TUF.Mirror; m != "" && !strings.HasPrefix(m, "https://") && !strings.HasPrefix(m, "http://") {
+		merr = multierror.Append(merr, fmt.Errorf("%s %q is not an http(s) URL", transparencyTUFMirrorKey, m))
+	}
+	if c.VSA.Enabled && c.Artifacts.TaskRuns.Format != "in-toto" {
+		merr = multierror.Append(merr, fmt.Errorf("%s is enabled but %s is %s, VSAs are only created for in-toto provenance", vsaEnabledKey, taskrunFormatKey, c.Artifacts.TaskRuns.Format))
+	}
+	if c.VSA.Enabled && c.VSA.PolicyURI == "" {
+		merr = multierror.Append(merr, fmt.Errorf("%s is enabled but %s is not set", vsaEnabledKey, vsaPolicyURIKey))
+	}
+	if c.Definitions.Verify && c.Definitions.Key == "" {
+		merr = multierror.Append(merr, fmt.Errorf("%s is enabled but %s is not set", definitionsVerifyEnabledKey, definitionsVerifyKeyKey))
+	}
+	if c.Definitions.Required && !c.Definitions.Verify {
+		merr = multierror.Append(merr, fmt.Errorf("%s is set but %s is not enabled", definitionsVerifyRequiredKey, definitionsVerifyEnabledKey))
+	}
+	for _, k := range c.Provenance.Labels {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			merr = multierror.Append(merr, fmt.Errorf("%s has invalid key %q: %s", provenanceLabelsKey, k, strings.Join(errs, ", ")))
+		}
+	}
+	for _, k := range c.Provenance.Annotations {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			merr = multierror.Append(merr, fmt.Errorf("%s has invalid key %q: %s", provenanceAnnotationsKey, k, strings.Join(errs, ", ")))
+		}
+	}
+	if c.Redact.EntropyThreshold < 0 {
+		merr = multierror.Append(merr, fmt.Errorf("%s is %g, it can't be negative", redactEntropyThresholdKey, c.Redact.EntropyThreshold))
+	}
+	return merr.ErrorOrNil()
+}
+
+// iamRolePattern is what the ARNs of IAM roles look like.
+var iamRolePattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::\d{12}:role/.+$`)
+
+// gcpProviderPattern is what the audiences of workload identity pool providers look like.
+var gcpProviderPattern = regexp.MustCompile(`^//iam\.googleapis\.com/projects/\d+/locations/global/workloadIdentityPools/[^/]+/providers/[^/]+$`)
+
+// kmsKeyPattern is what the resource names of Cloud KMS keys look like.
+var kmsKeyPattern = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// authSources are the sources of registry credentials that aren't a helper or a secret.
+var authSources = sets.NewString(AuthK8sChain, AuthWorkloadIdentity, AuthDocker)
+
+// helperNamePattern is what the names of docker credential helpers look like. They can't be
+// paths, so only the helpers on the controller's PATH are run.
+var helperNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// validateAuthSource checks that s is a known source of registry credentials, a credential
+// helper, or a reference to a secret.
+func validateAuthSource(s string) error {
+	switch {
+	case authSources.Has(s):
+	case strings.HasPrefix(s, AuthHelperPrefix):
+		if h := strings.TrimPrefix(s, AuthHelperPrefix); !helperNamePattern.MatchString(h) {
+			return fmt.Errorf("has invalid credential helper %q", h)
+		}
+	case strings.HasPrefix(s, AuthSecretPrefix):
+		ref := strings.TrimPrefix(s, AuthSecretPrefix)
+		namespace, name := SplitSecretRef(ref)
+		if namespace != "" {
+			if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+				return fmt.Errorf("has invalid secret %q: %s", ref, strings.Join(errs, ", "))
+			}
+		}
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("has invalid secret %q: %s", ref, strings.Join(errs, ", "))
+		}
+	default:
+		return fmt.Errorf("has unknown source %q, wanted one of %v, %s<name> or %s[<namespace>/]<name>", s, authSources.List(), AuthHelperPrefix, AuthSecretPrefix)
+	}
+	return nil
+}
+
+// SplitSecretRef splits the reference to a secret of a secret: source into its namespace,
+// which is empty for the TaskRun's, and its name.
+func SplitSecretRef(ref string) (namespace, name string) {
+	if i := strings.Index(ref, "/"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return "", ref
+}
+
+// validateStorage checks that the storage backend set by key is known, and that the settings
+// it needs are present.
+func (c *Config) validateStorage(key, backend string) error {
+	switch backend {
+	case "gcs":
+		if c.Storage.GCS.Bucket == "" {
+			return fmt.Errorf("%s is gcs but %s is not set", key, gcsBucketKey)
+		}
+	case "docdb":
+		if c.Storage.DocDB.URL == "" {
+			return fmt.Errorf("%s is docdb but %s is not set", key, docDBUrlKey)
+		}
+	case "results":
+		if c.Storage.Results.Address == "" {
+			return fmt.Errorf("%s is results but %s is not set", key, resultsAddressKey)
+		}
+	case "tekton", "oci":
+	default:
+		return fmt.Errorf("%s is %q, which is not a storage backend, wanted one of %v", key, backend, storageBackends.List())
+	}
+	return nil
+}
+
+// usesFulcio returns whether any signer gets its certificates from Fulcio.
+func (c *Config) usesFulcio() bool {
+	if c.Signers.X509.FulcioEnabled {
+		return true
+	}
+	for _, p := range c.Signers.Profiles {
+		if p.Fulcio {
+			return true
+		}
+	}
+	return false
+}
+
+// knownKeys are the keys chains-config is read from.
+var knownKeys = sets.NewString(
+	taskrunFormatKey, taskrunStorageKey, taskrunSignerKey,
+	ociFormatKey, ociStorageKey, ociSignerKey,
+	blobFormatKey, blobStorageKey, blobSignerKey,
+	imagesResolveKey,
+	subjectsRewriteKey,
+	redactEnabledKey, redactActionKey, redactPatternsKey, redactEntropyThresholdKey,
+	gcsBucketKey, gcsKMSKeyKey, gcsHoldKey, gcsRetentionKey, ociRepositoryKey, ociRepositoryInsecureKey, ociAuthKey, ociRegistryAuthKey, ociMaxConcurrentKey, ociProxyKey, ociCACertsKey, ociRegistriesKey, ociMappingsKey, storageRoutesKey, docDBUrlKey, resultsAddressKey,
+	tektonFallbackKey, tektonMaxAnnotationsKey,
+	kmsSignerKMSRef, kmsMaxConcurrentKey, kmsVaultRoleKey, kmsVaultAuthPathKey, kmsAWSIdentityKey, kmsAWSRegionKey, kmsAWSRoleARNKey, kmsAWSAssumeRoles, kmsGCPAudienceKey, kmsGCPServiceAcctKey, kmsGCPTokenPathKey, x509SignerFulcioEnabled, x509SignerFulcioAuth, x509SignerFulcioAddr, x509SignerFulcioAudience, x509SealingKeyKey, x509NamespaceSecretKey, signerProfilesKey, signerNamespacesKey, countersignerKey, signerProbeKey,
+	builderIDKey,
+	provenanceLabelsKey, provenanceAnnotationsKey,
+	transparencyEnabledKey, transparencyURLKey, transparencyAdditionalURLsKey, transparencyAsyncKey,
+	transparencyEntryTektonKey, transparencyEntrySimpleSigningKey, transparencyEntryInTotoKey, transparencyEntryProvenanceKey,
+	transparencyTUFMirrorKey, transparencyTUFTargetKey,
+	namespacesIncludeKey, namespacesExcludeKey, namespacesSelectorKey, namespacesQuotaRateKey, namespacesQuotaBurstKey,
+	filtersLabelsKey, filtersAnnotationsKey, filtersTasksKey, filtersPipelinesKey, filtersBundlesKey,
+	eventsSinkKey,
+	gcRetentionKey,
+	deadLetterURLKey,
+	dryRunEnabledKey,
+	signingTimeoutKey,
+	vsaEnabledKey, vsaVerifierIDKey, vsaPolicyURIKey, vsaPolicyLevelKey, vsaPolicyBuilderIDsKey, vsaPolicyMaterialsKey,
+	definitionsVerifyEnabledKey, definitionsVerifyKeyKey, definitionsVerifyRequiredKey,
+	sbomEnabledKey,
+	sourceEnabledKey,
+	bundleEnabledKey,
+)
+
+// overridableKeys are the keys the chains-config of a namespace can override.
+var overridableKeys = sets.NewString(
+	taskrunFormatKey, taskrunStorageKey, taskrunSignerKey,
+	ociFormatKey, ociStorageKey, ociSignerKey,
+	blobFormatKey, blobStorageKey, blobSignerKey,
+)
+
+// KnownKeys returns the keys chains-config is read from, sorted.
+func KnownKeys() []string {
+	return knownKeys.List()
+}
+
+// UnknownKeys returns the keys in data that Chains doesn't read, which are usually typos.
+// Keys starting with an underscore, such as _example, are ignored.
+func UnknownKeys(data map[string]string) []string {
+	return unknownKeys(data, knownKeys)
+}
+
+// UnknownOverrideKeys returns the keys in the chains-config of a namespace that Chains
+// doesn't read, see WithNamespaceOverrides.
+func UnknownOverrideKeys(data map[string]string) []string {
+	return unknownKeys(data, overridableKeys)
+}
+
+func unknownKeys(data map[string]string, known sets.String) []string {
+	unknown := []string{}
+	for k := range data {
+		if !known.Has(k) && !strings.HasPrefix(k, "_") {
+			unknown = append(unknown, k)
+		}
+	}
+	return sets.NewString(unknown...).List()
+}
@@ -1,3 +1,4 @@
+//go:build !ignore_autogenerated
 // +build !ignore_autogenerated
 
 /*
@@ -20,6 +21,27 @@ limitations under the License.
 
 package config
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSAuth) DeepCopyInto(out *AWSAuth) {
+	*out = *in
+	if in.AssumeRoles != nil {
+		in, out := &in.AssumeRoles, &out.AssumeRoles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSAuth.
+func (in *AWSAuth) DeepCopy() *AWSAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Artifact) DeepCopyInto(out *Artifact) {
 	*out = *in
@@ -41,6 +63,7 @@ func (in *ArtifactConfigs) DeepCopyInto(out *ArtifactConfigs) {
 	*out = *in
 	out.TaskRuns = in.TaskRuns
 	out.OCI = in.OCI
+	out.Blobs = in.Blobs
 	return
 }
 
@@ -70,14 +93,67 @@ func (in *BuilderConfig) DeepCopy() *BuilderConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundleConfig) DeepCopyInto(out *BundleConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundleConfig.
+func (in *BundleConfig) DeepCopy() *BundleConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(BundleConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Checkpoint) DeepCopyInto(out *Checkpoint) {
+	*out = *in
+	if in.ConsistencyProof != nil {
+		in, out := &in.ConsistencyProof, &out.ConsistencyProof
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Checkpoint.
+func (in *Checkpoint) DeepCopy() *Checkpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(Checkpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Config) DeepCopyInto(out *Config) {
 	*out = *in
 	out.Artifacts = in.Artifacts
-	out.Storage = in.Storage
-	out.Signers = in.Signers
+	in.Storage.DeepCopyInto(&out.Storage)
+	in.Signers.DeepCopyInto(&out.Signers)
 	out.Builder = in.Builder
-	out.Transparency = in.Transparency
+	in.Transparency.DeepCopyInto(&out.Transparency)
+	in.Namespaces.DeepCopyInto(&out.Namespaces)
+	in.Filters.DeepCopyInto(&out.Filters)
+	out.Events = in.Events
+	out.GC = in.GC
+	out.DeadLetter = in.DeadLetter
+	out.DryRun = in.DryRun
+	out.Signing = in.Signing
+	in.VSA.DeepCopyInto(&out.VSA)
+	out.Definitions = in.Definitions
+	out.SBOM = in.SBOM
+	out.Source = in.Source
+	out.Images = in.Images
+	in.Subjects.DeepCopyInto(&out.Subjects)
+	in.Redact.DeepCopyInto(&out.Redact)
+	in.Provenance.DeepCopyInto(&out.Provenance)
+	out.Bundle = in.Bundle
 	return
 }
 
@@ -91,6 +167,38 @@ func (in *Config) DeepCopy() *Config {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeadLetterConfig) DeepCopyInto(out *DeadLetterConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeadLetterConfig.
+func (in *DeadLetterConfig) DeepCopy() *DeadLetterConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DeadLetterConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DefinitionsConfig) DeepCopyInto(out *DefinitionsConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DefinitionsConfig.
+func (in *DefinitionsConfig) DeepCopy() *DefinitionsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DefinitionsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DocDBStorageConfig) DeepCopyInto(out *DocDBStorageConfig) {
 	*out = *in
@@ -107,6 +215,101 @@ func (in *DocDBStorageConfig) DeepCopy() *DocDBStorageConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunConfig) DeepCopyInto(out *DryRunConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunConfig.
+func (in *DryRunConfig) DeepCopy() *DryRunConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventsConfig) DeepCopyInto(out *EventsConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventsConfig.
+func (in *EventsConfig) DeepCopy() *EventsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EventsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FilterConfig) DeepCopyInto(out *FilterConfig) {
+	*out = *in
+	if in.Tasks != nil {
+		in, out := &in.Tasks, &out.Tasks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pipelines != nil {
+		in, out := &in.Pipelines, &out.Pipelines
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Bundles != nil {
+		in, out := &in.Bundles, &out.Bundles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FilterConfig.
+func (in *FilterConfig) DeepCopy() *FilterConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(FilterConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCConfig) DeepCopyInto(out *GCConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCConfig.
+func (in *GCConfig) DeepCopy() *GCConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GCConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPAuth) DeepCopyInto(out *GCPAuth) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPAuth.
+func (in *GCPAuth) DeepCopy() *GCPAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GCSStorageConfig) DeepCopyInto(out *GCSStorageConfig) {
 	*out = *in
@@ -123,9 +326,49 @@ func (in *GCSStorageConfig) DeepCopy() *GCSStorageConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImagesConfig) DeepCopyInto(out *ImagesConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImagesConfig.
+func (in *ImagesConfig) DeepCopy() *ImagesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ImagesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InclusionProof) DeepCopyInto(out *InclusionProof) {
+	*out = *in
+	if in.Hashes != nil {
+		in, out := &in.Hashes, &out.Hashes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InclusionProof.
+func (in *InclusionProof) DeepCopy() *InclusionProof {
+	if in == nil {
+		return nil
+	}
+	out := new(InclusionProof)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KMSSigner) DeepCopyInto(out *KMSSigner) {
 	*out = *in
+	out.Vault = in.Vault
+	in.AWS.DeepCopyInto(&out.AWS)
+	out.GCP = in.GCP
 	return
 }
 
@@ -139,9 +382,78 @@ func (in *KMSSigner) DeepCopy() *KMSSigner {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceConfig) DeepCopyInto(out *NamespaceConfig) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceConfig.
+func (in *NamespaceConfig) DeepCopy() *NamespaceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceSigners) DeepCopyInto(out *NamespaceSigners) {
+	*out = *in
+	if in.Signers != nil {
+		in, out := &in.Signers, &out.Signers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceSigners.
+func (in *NamespaceSigners) DeepCopy() *NamespaceSigners {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceSigners)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OCIStorageConfig) DeepCopyInto(out *OCIStorageConfig) {
 	*out = *in
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RegistryAuth != nil {
+		in, out := &in.RegistryAuth, &out.RegistryAuth
+		*out = make([]RegistryAuth, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Registries != nil {
+		in, out := &in.Registries, &out.Registries
+		*out = make([]RegistryConnection, len(*in))
+		copy(*out, *in)
+	}
+	if in.Mappings != nil {
+		in, out := &in.Mappings, &out.Mappings
+		*out = make([]RepositoryMapping, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -155,11 +467,155 @@ func (in *OCIStorageConfig) DeepCopy() *OCIStorageConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvenanceConfig) DeepCopyInto(out *ProvenanceConfig) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvenanceConfig.
+func (in *ProvenanceConfig) DeepCopy() *ProvenanceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvenanceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedactConfig) DeepCopyInto(out *RedactConfig) {
+	*out = *in
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedactConfig.
+func (in *RedactConfig) DeepCopy() *RedactConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RedactConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryAuth) DeepCopyInto(out *RegistryAuth) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryAuth.
+func (in *RegistryAuth) DeepCopy() *RegistryAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryConnection) DeepCopyInto(out *RegistryConnection) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryConnection.
+func (in *RegistryConnection) DeepCopy() *RegistryConnection {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryConnection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RepositoryMapping) DeepCopyInto(out *RepositoryMapping) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RepositoryMapping.
+func (in *RepositoryMapping) DeepCopy() *RepositoryMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(RepositoryMapping)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResultsStorageConfig) DeepCopyInto(out *ResultsStorageConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResultsStorageConfig.
+func (in *ResultsStorageConfig) DeepCopy() *ResultsStorageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ResultsStorageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SBOMConfig) DeepCopyInto(out *SBOMConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SBOMConfig.
+func (in *SBOMConfig) DeepCopy() *SBOMConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SBOMConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SignerConfigs) DeepCopyInto(out *SignerConfigs) {
 	*out = *in
 	out.X509 = in.X509
-	out.KMS = in.KMS
+	in.KMS.DeepCopyInto(&out.KMS)
+	if in.Profiles != nil {
+		in, out := &in.Profiles, &out.Profiles
+		*out = make([]SignerProfile, len(*in))
+		copy(*out, *in)
+	}
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]NamespaceSigners, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -173,13 +629,69 @@ func (in *SignerConfigs) DeepCopy() *SignerConfigs {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SignerProfile) DeepCopyInto(out *SignerProfile) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SignerProfile.
+func (in *SignerProfile) DeepCopy() *SignerProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(SignerProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SigningTimeoutConfig) DeepCopyInto(out *SigningTimeoutConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SigningTimeoutConfig.
+func (in *SigningTimeoutConfig) DeepCopy() *SigningTimeoutConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SigningTimeoutConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourceConfig) DeepCopyInto(out *SourceConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceConfig.
+func (in *SourceConfig) DeepCopy() *SourceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageConfigs) DeepCopyInto(out *StorageConfigs) {
 	*out = *in
 	out.GCS = in.GCS
-	out.OCI = in.OCI
+	in.OCI.DeepCopyInto(&out.OCI)
 	out.Tekton = in.Tekton
 	out.DocDB = in.DocDB
+	out.Results = in.Results
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]StorageRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -196,6 +708,11 @@ func (in *StorageConfigs) DeepCopy() *StorageConfigs {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *StorageOpts) DeepCopyInto(out *StorageOpts) {
 	*out = *in
+	if in.Bundle != nil {
+		in, out := &in.Bundle, &out.Bundle
+		*out = new(TlogBundle)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -209,6 +726,85 @@ func (in *StorageOpts) DeepCopy() *StorageOpts {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageRoute) DeepCopyInto(out *StorageRoute) {
+	*out = *in
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Transparency != nil {
+		in, out := &in.Transparency, &out.Transparency
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageRoute.
+func (in *StorageRoute) DeepCopy() *StorageRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubjectRewrite) DeepCopyInto(out *SubjectRewrite) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubjectRewrite.
+func (in *SubjectRewrite) DeepCopy() *SubjectRewrite {
+	if in == nil {
+		return nil
+	}
+	out := new(SubjectRewrite)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubjectsConfig) DeepCopyInto(out *SubjectsConfig) {
+	*out = *in
+	if in.Rewrites != nil {
+		in, out := &in.Rewrites, &out.Rewrites
+		*out = make([]SubjectRewrite, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubjectsConfig.
+func (in *SubjectsConfig) DeepCopy() *SubjectsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SubjectsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TUFConfig) DeepCopyInto(out *TUFConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TUFConfig.
+func (in *TUFConfig) DeepCopy() *TUFConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TUFConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TektonStorageConfig) DeepCopyInto(out *TektonStorageConfig) {
 	*out = *in
@@ -225,9 +821,47 @@ func (in *TektonStorageConfig) DeepCopy() *TektonStorageConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TlogBundle) DeepCopyInto(out *TlogBundle) {
+	*out = *in
+	if in.SignedEntryTimestamp != nil {
+		in, out := &in.SignedEntryTimestamp, &out.SignedEntryTimestamp
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.InclusionProof != nil {
+		in, out := &in.InclusionProof, &out.InclusionProof
+		*out = new(InclusionProof)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Checkpoint != nil {
+		in, out := &in.Checkpoint, &out.Checkpoint
+		*out = new(Checkpoint)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TlogBundle.
+func (in *TlogBundle) DeepCopy() *TlogBundle {
+	if in == nil {
+		return nil
+	}
+	out := new(TlogBundle)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TransparencyConfig) DeepCopyInto(out *TransparencyConfig) {
 	*out = *in
+	if in.AdditionalURLs != nil {
+		in, out := &in.AdditionalURLs, &out.AdditionalURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.EntryTypes = in.EntryTypes
+	out.TUF = in.TUF
 	return
 }
 
@@ -241,6 +875,59 @@ func (in *TransparencyConfig) DeepCopy() *TransparencyConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransparencyEntryTypes) DeepCopyInto(out *TransparencyEntryTypes) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransparencyEntryTypes.
+func (in *TransparencyEntryTypes) DeepCopy() *TransparencyEntryTypes {
+	if in == nil {
+		return nil
+	}
+	out := new(TransparencyEntryTypes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VSAConfig) DeepCopyInto(out *VSAConfig) {
+	*out = *in
+	if in.BuilderIDs != nil {
+		in, out := &in.BuilderIDs, &out.BuilderIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VSAConfig.
+func (in *VSAConfig) DeepCopy() *VSAConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(VSAConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VaultAuth) DeepCopyInto(out *VaultAuth) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultAuth.
+func (in *VaultAuth) DeepCopy() *VaultAuth {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultAuth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *X509Signer) DeepCopyInto(out *X509Signer) {
 	*out = *in
@@ -0,0 +1,278 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	knativemetrics "knative.dev/pkg/metrics"
+)
+
+// The metrics are exported by the knative metrics exporter, which is configured
+// through the config-observability ConfigMap. With the default prometheus backend
+// they are served on :9090/metrics.
+var (
+	formatKey  = tag.MustNewKey("format")
+	backendKey = tag.MustNewKey("backend")
+	signerKey  = tag.MustNewKey("signer")
+	reasonKey  = tag.MustNewKey("reason")
+	// namespaceKey is the namespace of the TaskRun, set on ctx with WithNamespace.
+	namespaceKey = tag.MustNewKey("namespace")
+	// clusterKey is the remote cluster of the TaskRuns, or empty for the local one, set on ctx
+	// with WithCluster.
+	clusterKey = tag.MustNewKey("cluster")
+	// operationKey is the storage operation: OperationStore, OperationRetrieve or OperationList.
+	operationKey = tag.MustNewKey("operation")
+	// kmsKey is the KMS that throttled a request, e.g. azurekms.
+	kmsKey = tag.MustNewKey("kms")
+
+	signedCount = stats.Float64("signed_payloads_count",
+		"number of payloads signed and stored",
+		stats.UnitDimensionless)
+
+	failedCount = stats.Float64("signing_failures_count",
+		"number of payloads that failed to be signed or stored",
+		stats.UnitDimensionless)
+
+	permanentFailures = stats.Float64("permanent_failures_count",
+		"number of TaskRuns that failed to be signed after exhausting their retries",
+		stats.UnitDimensionless)
+
+	signingLatency = stats.Float64("signing_duration_seconds",
+		"time taken by the signer to sign a single payload",
+		stats.UnitSeconds)
+
+	transparencyLatency = stats.Float64("transparency_upload_duration_seconds",
+		"time taken to upload a single entry to the transparency log",
+		stats.UnitSeconds)
+
+	storageLatency = stats.Float64("storage_duration_seconds",
+		"time taken by a storage backend to store or retrieve a single payload",
+		stats.UnitSeconds)
+
+	storageErrors = stats.Float64("storage_errors_count",
+		"number of payloads a storage backend failed to store or retrieve",
+		stats.UnitDimensionless)
+
+	throttledCount = stats.Float64("quota_throttled_count",
+		"number of times a TaskRun had to wait for the signing quota of its namespace",
+		stats.UnitDimensionless)
+
+	kmsThrottledCount = stats.Float64("kms_throttled_count",
+		"number of signing requests a KMS throttled",
+		stats.UnitDimensionless)
+
+	kmsThrottleDelay = stats.Float64("kms_throttle_delay_seconds",
+		"time waited before retrying a signing request a KMS throttled",
+		stats.UnitSeconds)
+
+	unsignedTaskRuns = stats.Float64("unsigned_taskruns",
+		"number of completed TaskRuns that have not been signed yet",
+		stats.UnitDimensionless)
+
+	backlogTaskRuns = stats.Float64("backlog_taskruns",
+		"number of completed TaskRuns waiting for the recent ones to be signed first",
+		stats.UnitDimensionless)
+
+	configValid = stats.Int64("config_valid",
+		"whether the last chains config that was loaded is valid (1) or not (0)",
+		stats.UnitDimensionless)
+
+	latencyBuckets = view.Distribution(0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60)
+
+	// Views are the views registered for the chains controller.
+	Views = []*view.View{{
+		Description: signedCount.Description(),
+		Measure:     signedCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{formatKey, backendKey, namespaceKey, clusterKey},
+	}, {
+		Description: failedCount.Description(),
+		Measure:     failedCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{formatKey, backendKey, namespaceKey, clusterKey},
+	}, {
+		Description: permanentFailures.Description(),
+		Measure:     permanentFailures,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{reasonKey, namespaceKey, clusterKey},
+	}, {
+		Description: signingLatency.Description(),
+		Measure:     signingLatency,
+		Aggregation: latencyBuckets,
+		TagKeys:     []tag.Key{signerKey, namespaceKey, clusterKey},
+	}, {
+		Description: throttledCount.Description(),
+		Measure:     throttledCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{namespaceKey, clusterKey},
+	}, {
+		Description: transparencyLatency.Description(),
+		Measure:     transparencyLatency,
+		Aggregation: latencyBuckets,
+	}, {
+		Description: storageLatency.Description(),
+		Measure:     storageLatency,
+		Aggregation: latencyBuckets,
+		TagKeys:     []tag.Key{backendKey, operationKey},
+	}, {
+		Description: storageErrors.Description(),
+		Measure:     storageErrors,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{backendKey, operationKey},
+	}, {
+		Description: kmsThrottledCount.Description(),
+		Measure:     kmsThrottledCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{kmsKey},
+	}, {
+		Description: kmsThrottleDelay.Description(),
+		Measure:     kmsThrottleDelay,
+		Aggregation: latencyBuckets,
+		TagKeys:     []tag.Key{kmsKey},
+	}, {
+		Description: configValid.Description(),
+		Measure:     configValid,
+		Aggregation: view.LastValue(),
+	}, {
+		Description: unsignedTaskRuns.Description(),
+		Measure:     unsignedTaskRuns,
+		Aggregation: view.LastValue(),
+		// Each cluster has its own count, which would otherwise overwrite the others'.
+		TagKeys: []tag.Key{clusterKey},
+	}, {
+		Description: backlogTaskRuns.Description(),
+		Measure:     backlogTaskRuns,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{clusterKey},
+	}}
+)
+
+func init() {
+	if err := view.Register(Views...); err != nil {
+		panic(err)
+	}
+}
+
+// WithNamespace labels the signing metrics recorded with ctx with the namespace of the TaskRun
+// being signed.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	tagged, err := tag.New(ctx, tag.Upsert(namespaceKey, namespace))
+	if err != nil {
+		return ctx
+	}
+	return tagged
+}
+
+// WithCluster labels the metrics recorded with ctx with the remote cluster of the TaskRuns, or
+// with an empty cluster for the local one.
+func WithCluster(ctx context.Context, cluster string) context.Context {
+	tagged, err := tag.New(ctx, tag.Upsert(clusterKey, cluster))
+	if err != nil {
+		return ctx
+	}
+	return tagged
+}
+
+// RecordSigned records that a payload of the given format was signed and stored in backend.
+func RecordSigned(ctx context.Context, format, backend string) {
+	record(ctx, signedCount.M(1), tag.Upsert(formatKey, format), tag.Upsert(backendKey, backend))
+}
+
+// RecordFailed records that a payload of the given format could not be signed or stored in backend.
+func RecordFailed(ctx context.Context, format, backend string) {
+	record(ctx, failedCount.M(1), tag.Upsert(formatKey, format), tag.Upsert(backendKey, backend))
+}
+
+// RecordPermanentFailure records that a TaskRun failed to be signed for reason, and won't be retried.
+func RecordPermanentFailure(ctx context.Context, reason string) {
+	record(ctx, permanentFailures.M(1), tag.Upsert(reasonKey, reason))
+}
+
+// RecordSigningLatency records how long the given signer took to sign a payload.
+// For the kms signer this is the latency of the KMS call.
+func RecordSigningLatency(ctx context.Context, signer string, d time.Duration) {
+	record(ctx, signingLatency.M(d.Seconds()), tag.Upsert(signerKey, signer))
+}
+
+// RecordTransparencyLatency records how long an upload to the transparency log took.
+func RecordTransparencyLatency(ctx context.Context, d time.Duration) {
+	record(ctx, transparencyLatency.M(d.Seconds()))
+}
+
+// The storage operations.
+const (
+	OperationStore    = "store"
+	OperationRetrieve = "retrieve"
+	OperationList     = "list"
+)
+
+// RecordStorageLatency records how long backend took to store or retrieve a payload, whether
+// it succeeded or not.
+func RecordStorageLatency(ctx context.Context, backend, operation string, d time.Duration) {
+	record(ctx, storageLatency.M(d.Seconds()), tag.Upsert(backendKey, backend), tag.Upsert(operationKey, operation))
+}
+
+// RecordStorageError records that backend failed to store or retrieve a payload.
+func RecordStorageError(ctx context.Context, backend, operation string) {
+	record(ctx, storageErrors.M(1), tag.Upsert(backendKey, backend), tag.Upsert(operationKey, operation))
+}
+
+// RecordThrottled records that a TaskRun in namespace had to wait for the signing quota of the
+// namespace.
+func RecordThrottled(ctx context.Context, namespace string) {
+	record(ctx, throttledCount.M(1), tag.Upsert(namespaceKey, namespace))
+}
+
+// RecordKMSThrottled records that kms throttled a signing request.
+func RecordKMSThrottled(ctx context.Context, kms string) {
+	record(ctx, kmsThrottledCount.M(1), tag.Upsert(kmsKey, kms))
+}
+
+// RecordKMSThrottleDelay records how long a signing request kms throttled waited to be retried.
+func RecordKMSThrottleDelay(ctx context.Context, kms string, d time.Duration) {
+	record(ctx, kmsThrottleDelay.M(d.Seconds()), tag.Upsert(kmsKey, kms))
+}
+
+// RecordUnsignedTaskRuns records the current number of completed but unsigned TaskRuns.
+func RecordUnsignedTaskRuns(ctx context.Context, count int) {
+	record(ctx, unsignedTaskRuns.M(float64(count)))
+}
+
+// RecordBacklogTaskRuns records the current number of TaskRuns waiting to be signed after the
+// recent ones.
+func RecordBacklogTaskRuns(ctx context.Context, count int) {
+	record(ctx, backlogTaskRuns.M(float64(count)))
+}
+
+// RecordConfigValid records whether the last chains config that was loaded is valid.
+func RecordConfigValid(ctx context.Context, valid bool) {
+	var v int64
+	if valid {
+		v = 1
+	}
+	record(ctx, configValid.M(v))
+}
+
+func record(ctx context.Context, m stats.Measurement, mutators ...tag.Mutator) {
+	ctx, err := tag.New(ctx, mutators...)
+	if err != nil {
+		return
+	}
+	knativemetrics.Record(ctx, m)
+}
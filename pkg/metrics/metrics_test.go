@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	knativemetrics "knative.dev/pkg/metrics"
+)
+
+func init() {
+	knativemetrics.InitForTesting()
+}
+
+func TestRecordSigned(t *testing.T) {
+	ctx := context.Background()
+	RecordSigned(ctx, "in-toto", "oci")
+	RecordSigned(ctx, "in-toto", "oci")
+	RecordSigned(ctx, "tekton", "tekton")
+	RecordFailed(ctx, "tekton", "gcs")
+
+	rows, err := view.RetrieveData("signed_payloads_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	for _, r := range rows {
+		key := ""
+		for _, tg := range r.Tags {
+			key += tg.Key.Name() + "=" + tg.Value + ","
+		}
+		got[key] = r.Data.(*view.CountData).Value
+	}
+	if got["backend=oci,format=in-toto,"] != 2 {
+		t.Errorf("expected 2 in-toto payloads stored in oci, got %v", got)
+	}
+	if got["backend=tekton,format=tekton,"] != 1 {
+		t.Errorf("expected 1 tekton payload stored in tekton, got %v", got)
+	}
+
+	rows, err = view.RetrieveData("signing_failures_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Errorf("expected a single failure row, got %d", len(rows))
+	}
+}
+
+func TestRecordPermanentFailure(t *testing.T) {
+	ctx := context.Background()
+	RecordPermanentFailure(ctx, "StorageFailed")
+
+	rows, err := view.RetrieveData("permanent_failures_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Tags[0].Value != "StorageFailed" {
+		t.Errorf("expected a single StorageFailed row, got %v", rows)
+	}
+}
+
+func TestRecordLatency(t *testing.T) {
+	ctx := context.Background()
+	RecordSigningLatency(ctx, "kms", 2*time.Second)
+	RecordTransparencyLatency(ctx, time.Second)
+
+	for _, v := range []string{"signing_duration_seconds", "transparency_upload_duration_seconds"} {
+		rows, err := view.RetrieveData(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Data.(*view.DistributionData).Count != 1 {
+			t.Errorf("expected a single measurement for %s, got %v", v, rows)
+		}
+	}
+}
+
+func TestRecordKMSThrottled(t *testing.T) {
+	ctx := context.Background()
+	RecordKMSThrottled(ctx, "azurekms")
+	RecordKMSThrottled(ctx, "azurekms")
+	RecordKMSThrottleDelay(ctx, "azurekms", 3*time.Second)
+
+	rows, err := view.RetrieveData("kms_throttled_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Tags[0].Value != "azurekms" || rows[0].Data.(*view.CountData).Value != 2 {
+		t.Errorf("expected 2 throttled azurekms requests, got %v", rows)
+	}
+	rows, err = view.RetrieveData("kms_throttle_delay_seconds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.DistributionData).Sum() != 3 {
+		t.Errorf("expected a single delay of 3 seconds, got %v", rows)
+	}
+}
+
+func TestRecordUnsignedTaskRuns(t *testing.T) {
+	ctx := context.Background()
+	RecordUnsignedTaskRuns(ctx, 3)
+	RecordUnsignedTaskRuns(ctx, 5)
+	// A remote cluster doesn't overwrite the count of the local one.
+	RecordUnsignedTaskRuns(WithCluster(ctx, "east"), 7)
+
+	rows, err := view.RetrieveData("unsigned_taskruns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, r := range rows {
+		cluster := ""
+		for _, tg := range r.Tags {
+			cluster = tg.Value
+		}
+		got[cluster] = r.Data.(*view.LastValueData).Value
+	}
+	if len(got) != 2 || got[""] != 5 || got["east"] != 7 {
+		t.Errorf("expected last values of 5 for the local cluster and 7 for east, got %v", got)
+	}
+}
+
+func TestRecordBacklogTaskRuns(t *testing.T) {
+	ctx := context.Background()
+	RecordBacklogTaskRuns(ctx, 40)
+	RecordBacklogTaskRuns(ctx, 12)
+
+	rows, err := view.RetrieveData("backlog_taskruns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.LastValueData).Value != 12 {
+		t.Errorf("expected last value of 12, got %v", rows)
+	}
+}
+
+func TestRecordConfigValid(t *testing.T) {
+	ctx := context.Background()
+	RecordConfigValid(ctx, true)
+	RecordConfigValid(ctx, false)
+
+	rows, err := view.RetrieveData("config_valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.LastValueData).Value != 0 {
+		t.Errorf("expected last value of 0, got %v", rows)
+	}
+}
+
+func TestRecordStorage(t *testing.T) {
+	ctx := context.Background()
+	RecordStorageLatency(ctx, "oci", OperationStore, time.Second)
+	RecordStorageLatency(ctx, "gcs", OperationStore, time.Second)
+	RecordStorageLatency(ctx, "gcs", OperationRetrieve, time.Second)
+	RecordStorageError(ctx, "gcs", OperationStore)
+
+	rows, err := view.RetrieveData("storage_duration_seconds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Errorf("expected a row for each backend and operation, got %v", rows)
+	}
+	rows, err = view.RetrieveData("storage_errors_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.CountData).Value != 1 {
+		t.Errorf("expected a single gcs store error, got %v", rows)
+	}
+}
+
+func TestWithNamespace(t *testing.T) {
+	ctx := WithNamespace(context.Background(), "team-a")
+	RecordPermanentFailure(ctx, "SigningTimeout")
+	RecordThrottled(context.Background(), "team-b")
+
+	rows, err := view.RetrieveData("permanent_failures_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			found = found || (tg.Key.Name() == "namespace" && tg.Value == "team-a")
+		}
+	}
+	if !found {
+		t.Errorf("expected a failure in namespace team-a, got %v", rows)
+	}
+
+	rows, err = view.RetrieveData("quota_throttled_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Tags[0].Value != "team-b" {
+		t.Errorf("expected a single row for team-b, got %v", rows)
+	}
+}
@@ -26,6 +26,33 @@ func GetAnnotationsPatch(newAnnotations map[string]string) ([]byte, error) {
 	return json.Marshal(p)
 }
 
+// GetAnnotationsRemovalPatch returns merge patch bytes that set newAnnotations and
+// remove every annotation listed in removed.
+func GetAnnotationsRemovalPatch(newAnnotations map[string]string, removed []string) ([]byte, error) {
+	return GetConditionalAnnotationsRemovalPatch(newAnnotations, removed, "")
+}
+
+// GetConditionalAnnotationsRemovalPatch is GetAnnotationsRemovalPatch for annotations computed
+// from the object at resourceVersion: the patch fails with a conflict if the object has changed
+// since. An empty resourceVersion patches the object whatever its version.
+func GetConditionalAnnotationsRemovalPatch(newAnnotations map[string]string, removed []string, resourceVersion string) ([]byte, error) {
+	annotations := map[string]*string{}
+	for k, v := range newAnnotations {
+		v := v
+		annotations[k] = &v
+	}
+	for _, k := range removed {
+		annotations[k] = nil
+	}
+	p := removalPatch{
+		Metadata: removalMetadata{
+			Annotations:     annotations,
+			ResourceVersion: resourceVersion,
+		},
+	}
+	return json.Marshal(p)
+}
+
 // These are used to get proper json formatting
 type patch struct {
 	Metadata metadata `json:"metadata,omitempty"`
@@ -33,3 +60,12 @@ type patch struct {
 type metadata struct {
 	Annotations map[string]string `json:"annotations,omitempty"`
 }
+
+// A nil value is marshalled to null, which removes the key in a merge patch.
+type removalPatch struct {
+	Metadata removalMetadata `json:"metadata"`
+}
+type removalMetadata struct {
+	Annotations     map[string]*string `json:"annotations"`
+	ResourceVersion string             `json:"resourceVersion,omitempty"`
+}
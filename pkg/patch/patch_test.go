@@ -61,3 +61,50 @@ func TestGetAnnotationsPatch(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAnnotationsRemovalPatch(t *testing.T) {
+	tests := []struct {
+		name            string
+		newAnnotations  map[string]string
+		removed         []string
+		resourceVersion string
+		want            string
+	}{
+		{
+			name: "remove only",
+			removed: []string{
+				"foo",
+			},
+			want: `{"metadata":{"annotations":{"foo":null}}}`,
+		},
+		{
+			name: "set and remove",
+			newAnnotations: map[string]string{
+				"baz": "bat",
+			},
+			removed: []string{
+				"foo",
+			},
+			want: `{"metadata":{"annotations":{"baz":"bat","foo":null}}}`,
+		},
+		{
+			name: "at a resourceVersion",
+			newAnnotations: map[string]string{
+				"baz": "bat",
+			},
+			resourceVersion: "42",
+			want:            `{"metadata":{"annotations":{"baz":"bat"},"resourceVersion":"42"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetConditionalAnnotationsRemovalPatch(tt.newAnnotations, tt.removed, tt.resourceVersion)
+			if err != nil {
+				t.Fatalf("GetConditionalAnnotationsRemovalPatch() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("GetConditionalAnnotationsRemovalPatch() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
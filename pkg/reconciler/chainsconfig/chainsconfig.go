@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chainsconfig reads the Chains configuration from the ChainsConfig resource, when
+// there is one, in place of the chains-config ConfigMap, and reports the health of the
+// signers and storage backends it configures in the resource's status.
+package chainsconfig
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
+)
+
+// Name is the name of the ChainsConfig Chains reads. Others are ignored.
+const Name = config.ChainsConfig
+
+// Resource is the ChainsConfig resource, see config/300-chainsconfig.yaml.
+var Resource = schema.GroupVersionResource{Group: "chains.tekton.dev", Version: "v1alpha1", Resource: "chainsconfigs"}
+
+// The Ready condition is True when every signer and storage backend in use is healthy.
+const (
+	ConditionReady = "Ready"
+
+	ReasonHealthy       = "Healthy"
+	ReasonUnhealthy     = "Unhealthy"
+	ReasonInvalidConfig = "InvalidConfig"
+)
+
+// Status is the status of a ChainsConfig.
+type Status struct {
+	// ObservedGeneration is the generation of the spec the status is of.
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+	// Signers are the signers in use.
+	Signers []Component `json:"signers,omitempty"`
+	// StorageBackends are the storage backends in use.
+	StorageBackends []Component `json:"storageBackends,omitempty"`
+}
+
+// Component is a signer or storage backend.
+type Component struct {
+	Name string `json:"name"`
+	// Artifacts are the kinds of artifacts that use it, e.g. taskrun or oci.
+	Artifacts []string `json:"artifacts,omitempty"`
+	Healthy   bool     `json:"healthy"`
+	// Message is why it isn't healthy.
+	Message string `json:"message,omitempty"`
+}
+
+// Flatten returns the spec of a ChainsConfig as the data of a chains-config ConfigMap. The
+// spec has the same keys as the ConfigMap, and every value is a string.
+func Flatten(u *unstructured.Unstructured) (map[string]string, error) {
+	spec, _, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]string, len(spec))
+	for k, v := range spec {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("spec.%s is a %T, not a string", k, v)
+		}
+		data[k] = s
+	}
+	return data, nil
+}
+
+// Load reads the configuration once, the way the Watcher passes it to the controllers: from
+// the ChainsConfig if there is one, and from the chains-config ConfigMap in namespace
+// otherwise, with the overrides applied. Without a dynamic client, only the ConfigMap is read,
+// and without either, the defaults are used. The configuration is validated.
+func Load(ctx context.Context, dc dynamic.Interface, kc kubernetes.Interface, namespace string, overrides map[string]string) (*config.Config, error) {
+	data, err := loadData(ctx, dc, kc, namespace)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.NewConfigFromMap(config.WithOverrides(data, overrides))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", config.ChainsConfig)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", config.ChainsConfig)
+	}
+	return cfg, nil
+}
+
+func loadData(ctx context.Context, dc dynamic.Interface, kc kubernetes.Interface, namespace string) (map[string]string, error) {
+	if dc != nil {
+		u, err := dc.Resource(Resource).Get(ctx, Name, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			return Flatten(u)
+		// Either the CRD isn't installed, or there's no ChainsConfig. Like the Watcher, the
+		// ConfigMap is read when the ChainsConfig can't be, e.g. without the permission to.
+		case !apierrors.IsNotFound(err):
+			logging.FromContext(ctx).Warnf("Error getting the ChainsConfig, only reading the %s ConfigMap: %v", config.ChainsConfig, err)
+		}
+	}
+	cm, err := kc.CoreV1().ConfigMaps(namespace).Get(ctx, config.ChainsConfig, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting %s/%s", namespace, config.ChainsConfig)
+	}
+	return cm.Data, nil
+}
+
+// asConfigMap returns the ConfigMap the configuration read from a ChainsConfig is passed
+// to observers of the chains-config ConfigMap as.
+func asConfigMap(u *unstructured.Unstructured, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            config.ChainsConfig,
+			Namespace:       system.Namespace(),
+			ResourceVersion: u.GetResourceVersion(),
+		},
+		Data: data,
+	}
+}
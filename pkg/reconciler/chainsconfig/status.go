@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chainsconfig
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/storage"
+	"github.com/tektoncd/chains/pkg/chains/storage/oci"
+	"github.com/tektoncd/chains/pkg/chains/storage/tekton"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"knative.dev/pkg/system"
+)
+
+// controllerServiceAccount is the service account the controller runs as. The OCI backend
+// authenticates as the service account of a TaskRun, so it's checked with the controller's.
+const controllerServiceAccount = "tekton-chains-controller"
+
+// updateStatus checks the signers and storage backends the ChainsConfig configures, and
+// records their health in its status.
+func (w *Watcher) updateStatus(ctx context.Context, u *unstructured.Unstructured) error {
+	status := Status{}
+	if raw, ok, _ := unstructured.NestedMap(u.Object, "status"); ok {
+		// A status that can't be read is replaced.
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &status)
+	}
+	data, err := Flatten(u)
+	if err != nil {
+		return err
+	}
+	w.computeStatus(&status, data)
+	status.ObservedGeneration = u.GetGeneration()
+	for i := range status.Conditions {
+		status.Conditions[i].ObservedGeneration = u.GetGeneration()
+	}
+
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return err
+	}
+	u = u.DeepCopy()
+	if err := unstructured.SetNestedMap(u.Object, raw, "status"); err != nil {
+		return err
+	}
+	_, err = w.dynamicClient.Resource(Resource).UpdateStatus(ctx, u, metav1.UpdateOptions{})
+	return err
+}
+
+// computeStatus sets the signers, storage backends and Ready condition of status for the
+// configuration in data.
+func (w *Watcher) computeStatus(status *Status, data map[string]string) {
+	status.Signers, status.StorageBackends = nil, nil
+	cfg, err := config.NewConfigFromMap(config.WithOverrides(data, w.overrides))
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    ConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonInvalidConfig,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	signers, backends := inUse(cfg)
+	var unhealthy []string
+	for _, c := range signers {
+		c.Healthy, c.Message = healthy(chains.CheckSigner(w.secretPath, *cfg, c.Name, w.logger))
+		if !c.Healthy {
+			unhealthy = append(unhealthy, "signer "+c.Name)
+		}
+		status.Signers = append(status.Signers, c)
+	}
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Namespace: system.Namespace()},
+		Spec:       v1beta1.TaskRunSpec{ServiceAccountName: controllerServiceAccount},
+	}
+	for _, c := range backends {
+		_, err := storage.InitializeBackend(w.pipelineClient, w.kubeClient, w.logger, tr, *cfg, c.Name)
+		c.Healthy, c.Message = healthy(err)
+		if !c.Healthy {
+			unhealthy = append(unhealthy, "storage backend "+c.Name)
+		}
+		status.StorageBackends = append(status.StorageBackends, c)
+	}
+
+	if len(unhealthy) > 0 {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    ConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonUnhealthy,
+			Message: fmt.Sprintf("Not healthy: %s", strings.Join(unhealthy, ", ")),
+		})
+		return
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:    ConditionReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  ReasonHealthy,
+		Message: "The signers and storage backends in use are healthy",
+	})
+}
+
+// inUse returns the signers and storage backends the artifacts are configured with, sorted
+// by name.
+func inUse(cfg *config.Config) ([]Component, []Component) {
+	signers, backends := map[string][]string{}, map[string][]string{}
+	// The payloads of an artifact are stored in the backends of the route of their format, if
+	// it has one.
+	add := func(artifact string, a config.Artifact) {
+		signers[a.Signer] = append(signers[a.Signer], artifact)
+		for _, b := range cfg.Storage.StorageBackends(a.Format, a.StorageBackend) {
+			backends[b] = append(backends[b], artifact)
+		}
+	}
+	add("taskrun", cfg.Artifacts.TaskRuns)
+	add("oci", cfg.Artifacts.OCI)
+	// Blobs are only signed when they have a storage backend.
+	if cfg.Artifacts.Blobs.StorageBackend != "" {
+		add("blob", cfg.Artifacts.Blobs)
+	}
+	// The payloads that don't fit in the annotations of TaskRuns are stored in the fallback.
+	if f := cfg.Storage.Tekton.Fallback; f != "" {
+		for _, a := range backends[tekton.StorageBackendTekton] {
+			backends[f] = append(backends[f], a)
+		}
+	}
+	// SBOMs are always attached to their images.
+	if cfg.SBOM.Enabled {
+		backends[oci.StorageBackendOCI] = append(backends[oci.StorageBackendOCI], "sbom")
+	}
+	return components(signers), components(backends)
+}
+
+func components(artifacts map[string][]string) []Component {
+	var cs []Component
+	for name, a := range artifacts {
+		cs = append(cs, Component{Name: name, Artifacts: a})
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Name < cs[j].Name
+	})
+	return cs
+}
+
+func healthy(err error) (bool, string) {
+	if err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chainsconfig
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/tektoncd/chains/pkg/config"
+	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client/fake"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	logtesting "knative.dev/pkg/logging/testing"
+	rtesting "knative.dev/pkg/reconciler/testing"
+	"knative.dev/pkg/system"
+)
+
+func TestComputeStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		secretPath   string
+		data         map[string]string
+		overrides    map[string]string
+		wantReason   string
+		wantSigners  []Component
+		wantBackends []Component
+	}{{
+		name:       "defaults",
+		secretPath: "../../chains/signing/x509/testdata/",
+		wantReason: ReasonHealthy,
+		wantSigners: []Component{
+			{Name: "x509", Artifacts: []string{"taskrun", "oci"}, Healthy: true},
+		},
+		wantBackends: []Component{
+			{Name: "oci", Artifacts: []string{"oci"}, Healthy: true},
+			{Name: "tekton", Artifacts: []string{"taskrun"}, Healthy: true},
+		},
+	}, {
+		name:       "missing key",
+		data:       map[string]string{"artifacts.oci.storage": "tekton"},
+		secretPath: t.TempDir(),
+		wantReason: ReasonUnhealthy,
+		wantSigners: []Component{
+			{Name: "x509", Artifacts: []string{"taskrun", "oci"}},
+		},
+		wantBackends: []Component{
+			{Name: "tekton", Artifacts: []string{"taskrun", "oci"}, Healthy: true},
+		},
+	}, {
+		name:       "overridden",
+		secretPath: "../../chains/signing/x509/testdata/",
+		data:       map[string]string{"artifacts.oci.storage": "tekton"},
+		overrides:  map[string]string{"artifacts.oci.storage": "oci"},
+		wantReason: ReasonHealthy,
+		wantSigners: []Component{
+			{Name: "x509", Artifacts: []string{"taskrun", "oci"}, Healthy: true},
+		},
+		wantBackends: []Component{
+			{Name: "oci", Artifacts: []string{"oci"}, Healthy: true},
+			{Name: "tekton", Artifacts: []string{"taskrun"}, Healthy: true},
+		},
+	}, {
+		name:       "tekton fallback",
+		secretPath: "../../chains/signing/x509/testdata/",
+		data:       map[string]string{"storage.tekton.fallback": "oci"},
+		wantReason: ReasonHealthy,
+		wantSigners: []Component{
+			{Name: "x509", Artifacts: []string{"taskrun", "oci"}, Healthy: true},
+		},
+		wantBackends: []Component{
+			{Name: "oci", Artifacts: []string{"oci", "taskrun"}, Healthy: true},
+			{Name: "tekton", Artifacts: []string{"taskrun"}, Healthy: true},
+		},
+	}, {
+		name:       "invalid",
+		data:       map[string]string{"artifacts.taskrun.format": "nope"},
+		wantReason: ReasonInvalidConfig,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			// The OCI backend authenticates as the controller.
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: controllerServiceAccount, Namespace: system.Namespace()}}
+			if _, err := fakekubeclient.Get(ctx).CoreV1().ServiceAccounts(sa.Namespace).Create(ctx, sa, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			w := &Watcher{
+				logger:         logtesting.TestLogger(t),
+				kubeClient:     fakekubeclient.Get(ctx),
+				pipelineClient: fakepipelineclient.Get(ctx),
+				secretPath:     tt.secretPath,
+				overrides:      tt.overrides,
+			}
+			status := &Status{}
+			w.computeStatus(status, tt.data)
+
+			ready := meta.FindStatusCondition(status.Conditions, ConditionReady)
+			if ready == nil {
+				t.Fatal("expected a Ready condition")
+			}
+			if ready.Reason != tt.wantReason {
+				t.Errorf("Ready reason = %s (%s), want %s", ready.Reason, ready.Message, tt.wantReason)
+			}
+			if (ready.Status == metav1.ConditionTrue) != (tt.wantReason == ReasonHealthy) {
+				t.Errorf("Ready status = %s, want healthy = %v", ready.Status, tt.wantReason == ReasonHealthy)
+			}
+			ignoreMessage := cmpopts.IgnoreFields(Component{}, "Message")
+			if d := cmp.Diff(tt.wantSigners, status.Signers, ignoreMessage); d != "" {
+				t.Errorf("signers diff (-want +got):\n%s", d)
+			}
+			if d := cmp.Diff(tt.wantBackends, status.StorageBackends, ignoreMessage); d != "" {
+				t.Errorf("storage backends diff (-want +got):\n%s", d)
+			}
+			for _, c := range append(status.Signers, status.StorageBackends...) {
+				if !c.Healthy && c.Message == "" {
+					t.Errorf("expected unhealthy %s to say why", c.Name)
+				}
+			}
+		})
+	}
+}
+
+// chainsConfigCRD reads the ChainsConfig CRD from the release manifests.
+func chainsConfigCRD(t *testing.T) apiextensionsv1.JSONSchemaProps {
+	t.Helper()
+	b, err := ioutil.ReadFile("../../../config/300-chainsconfig.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(b, crd); err != nil {
+		t.Fatal(err)
+	}
+	return crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties["spec"]
+}
+
+func TestCRDHasEveryKey(t *testing.T) {
+	spec := chainsConfigCRD(t)
+	var got []string
+	for k := range spec.Properties {
+		got = append(got, k)
+	}
+	if d := cmp.Diff(config.KnownKeys(), got, cmpopts.SortSlices(func(a, b string) bool { return a < b })); d != "" {
+		t.Errorf("ChainsConfig spec properties diff (-known keys +got):\n%s", d)
+	}
+}
+
+func TestCRDDefaults(t *testing.T) {
+	spec := chainsConfigCRD(t)
+	data := map[string]string{}
+	for k, p := range spec.Properties {
+		if p.Default == nil {
+			continue
+		}
+		var v string
+		if err := yaml.Unmarshal(p.Default.Raw, &v); err != nil {
+			t.Fatalf("default of %s: %v", k, err)
+		}
+		data[k] = v
+	}
+	got, err := config.NewConfigFromMap(data)
+	if err != nil {
+		t.Fatalf("NewConfigFromMap() = %v", err)
+	}
+	want, err := config.NewConfigFromMap(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("the ChainsConfig defaults aren't those of the ConfigMap (-want +got):\n%s", d)
+	}
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chainsconfig
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/config"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	pipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/injection/clients/dynamicclient"
+	"knative.dev/pkg/logging"
+)
+
+// resyncPeriod is how often the health of the signers and storage backends is checked again.
+var resyncPeriod = 10 * time.Minute
+
+var (
+	watchersMu sync.Mutex
+	watchers   = map[configmap.Watcher]*Watcher{}
+)
+
+// Watcher is a configmap.Watcher that passes the configuration in the ChainsConfig, while
+// there is one, to the observers of the chains-config ConfigMap. The ConfigMap is passed to
+// them otherwise. Other ConfigMaps are watched by the underlying configmap.Watcher.
+type Watcher struct {
+	configmap.Watcher
+
+	logger         *zap.SugaredLogger
+	dynamicClient  dynamic.Interface
+	kubeClient     kubernetes.Interface
+	pipelineClient versioned.Interface
+	secretPath     string
+	overrides      map[string]string
+
+	mu         sync.Mutex
+	observers  []configmap.Observer
+	registered bool
+	// configMap is the last chains-config ConfigMap seen, and chainsConfig the ChainsConfig,
+	// if there is one.
+	configMap    *corev1.ConfigMap
+	chainsConfig *corev1.ConfigMap
+
+	run sync.Once
+}
+
+// WatcherFor returns the Watcher that wraps cmw, creating it with the clients in ctx on first
+// use. Controllers that share cmw share the Watcher, so the clients are those of the first
+// one. The keys of the signing secret are read from secretPath to check the signers. Without
+// a dynamic client in ctx, only the ConfigMap is read.
+func WatcherFor(ctx context.Context, cmw configmap.Watcher, secretPath string) *Watcher {
+	if w, ok := cmw.(*Watcher); ok {
+		return w
+	}
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	if w, ok := watchers[cmw]; ok {
+		return w
+	}
+	w := &Watcher{
+		Watcher:        cmw,
+		logger:         logging.FromContext(ctx),
+		kubeClient:     kubeclient.Get(ctx),
+		pipelineClient: pipelineclient.Get(ctx),
+		secretPath:     secretPath,
+		overrides:      config.OverridesFromContext(ctx),
+	}
+	if dc, ok := ctx.Value(dynamicclient.Key{}).(dynamic.Interface); ok {
+		w.dynamicClient = dc
+	}
+	watchers[cmw] = w
+	return w
+}
+
+// Watch implements configmap.Watcher.
+func (w *Watcher) Watch(name string, o ...configmap.Observer) {
+	if name != config.ChainsConfig {
+		w.Watcher.Watch(name, o...)
+		return
+	}
+	w.mu.Lock()
+	w.observers = append(w.observers, o...)
+	register := !w.registered
+	w.registered = true
+	current := w.current()
+	w.mu.Unlock()
+	// Some watchers, such as configmap.StaticWatcher, call the observer right away, so the
+	// observers added later are passed the configuration that was already read.
+	if register {
+		w.Watcher.Watch(name, w.onConfigMap)
+	} else if current != nil {
+		for _, obs := range o {
+			obs(current)
+		}
+	}
+}
+
+// Run reads the ChainsConfig, and keeps watching it in the background until ctx is done. It
+// must be called before the watcher is started, so that the observers are first passed the
+// ChainsConfig if there is one. Without the ChainsConfig CRD, only the ConfigMap is read.
+// Only the first call does anything.
+func (w *Watcher) Run(ctx context.Context) {
+	w.run.Do(func() {
+		if w.dynamicClient == nil {
+			return
+		}
+		client := w.dynamicClient.Resource(Resource)
+		byName := fields.OneTermEqualSelector("metadata.name", Name).String()
+		list, err := client.List(ctx, metav1.ListOptions{FieldSelector: byName})
+		if apierrors.IsNotFound(err) {
+			w.logger.Infof("The ChainsConfig CRD isn't installed, only reading the %s ConfigMap", config.ChainsConfig)
+			return
+		}
+		if err != nil {
+			w.logger.Errorw("Error listing ChainsConfigs, only reading the ConfigMap", zap.Error(err))
+			return
+		}
+		for i := range list.Items {
+			w.onChainsConfig(&list.Items[i])
+		}
+
+		lw := &cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				opts.FieldSelector = byName
+				return client.List(ctx, opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				opts.FieldSelector = byName
+				return client.Watch(ctx, opts)
+			},
+		}
+		_, informer := cache.NewInformer(lw, &unstructured.Unstructured{}, resyncPeriod, cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				w.reconcile(ctx, obj.(*unstructured.Unstructured))
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				old, u := oldObj.(*unstructured.Unstructured), newObj.(*unstructured.Unstructured)
+				// Skip the updates of the status, but not the periodic resyncs, which
+				// check the health of the signers and storage backends again.
+				if old.GetGeneration() == u.GetGeneration() && old.GetResourceVersion() != u.GetResourceVersion() {
+					return
+				}
+				w.reconcile(ctx, u)
+			},
+			DeleteFunc: func(interface{}) {
+				w.onDelete()
+			},
+		})
+		go informer.Run(ctx.Done())
+	})
+}
+
+// reconcile passes the configuration in the ChainsConfig to the observers, and updates its
+// status.
+func (w *Watcher) reconcile(ctx context.Context, u *unstructured.Unstructured) {
+	if !w.onChainsConfig(u) {
+		return
+	}
+	if err := w.updateStatus(ctx, u); err != nil {
+		w.logger.Errorw("Error updating the status of the ChainsConfig", zap.Error(err))
+	}
+}
+
+// onConfigMap is called with the chains-config ConfigMap, which is only passed to the
+// observers while there's no ChainsConfig.
+func (w *Watcher) onConfigMap(cm *corev1.ConfigMap) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.configMap = cm
+	w.notify()
+}
+
+// onChainsConfig is called with the ChainsConfig, and returns whether its configuration was
+// passed to the observers.
+func (w *Watcher) onChainsConfig(u *unstructured.Unstructured) bool {
+	data, err := Flatten(u)
+	if err != nil {
+		w.logger.Errorw("Error reading the ChainsConfig", zap.Error(err))
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chainsConfig = asConfigMap(u, data)
+	w.notify()
+	return true
+}
+
+// onDelete is called when the ChainsConfig is deleted, and goes back to the ConfigMap.
+func (w *Watcher) onDelete() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.logger.Infof("The ChainsConfig was deleted, reading the %s ConfigMap", config.ChainsConfig)
+	w.chainsConfig = nil
+	w.notify()
+}
+
+// notify passes the current configuration to the observers. w.mu must be held.
+func (w *Watcher) notify() {
+	cm := w.current()
+	if cm == nil {
+		return
+	}
+	for _, o := range w.observers {
+		o(cm)
+	}
+}
+
+// current returns the ChainsConfig as a ConfigMap if there is one, and the ConfigMap
+// otherwise. w.mu must be held.
+func (w *Watcher) current() *corev1.ConfigMap {
+	if w.chainsConfig != nil {
+		return w.chainsConfig
+	}
+	return w.configMap
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chainsconfig
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/chains/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"knative.dev/pkg/configmap"
+	logtesting "knative.dev/pkg/logging/testing"
+	rtesting "knative.dev/pkg/reconciler/testing"
+	"knative.dev/pkg/system"
+	_ "knative.dev/pkg/system/testing" // Setup system.Namespace()
+)
+
+func chainsConfig(spec map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	u.SetAPIVersion(Resource.GroupVersion().String())
+	u.SetKind("ChainsConfig")
+	u.SetName(Name)
+	u.SetGeneration(1)
+	return u
+}
+
+func TestFlatten(t *testing.T) {
+	got, err := Flatten(chainsConfig(map[string]interface{}{
+		"artifacts.taskrun.format": "in-toto",
+		"transparency.enabled":     "true",
+	}))
+	if err != nil {
+		t.Fatalf("Flatten() = %v", err)
+	}
+	want := map[string]string{
+		"artifacts.taskrun.format": "in-toto",
+		"transparency.enabled":     "true",
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("Flatten() diff (-want +got):\n%s", d)
+	}
+
+	if _, err := Flatten(chainsConfig(map[string]interface{}{"transparency.enabled": true})); err == nil {
+		t.Error("expected a value that isn't a string to be rejected")
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.ChainsConfig, Namespace: system.Namespace()},
+		Data:       map[string]string{"artifacts.taskrun.format": "tekton"},
+	}
+	other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config-logging", Namespace: system.Namespace()}}
+	w := &Watcher{
+		Watcher: configmap.NewStaticWatcher(cm, other),
+		logger:  logtesting.TestLogger(t),
+	}
+
+	var got []map[string]string
+	w.Watch(config.ChainsConfig, func(cm *corev1.ConfigMap) {
+		got = append(got, cm.Data)
+	})
+	var others []string
+	w.Watch(other.Name, func(cm *corev1.ConfigMap) {
+		others = append(others, cm.Name)
+	})
+
+	w.onChainsConfig(chainsConfig(map[string]interface{}{"artifacts.taskrun.format": "in-toto"}))
+	// The ConfigMap is ignored while there's a ChainsConfig.
+	w.onConfigMap(&corev1.ConfigMap{Data: map[string]string{"artifacts.taskrun.format": "tekton-provenance"}})
+	w.onDelete()
+
+	var late []map[string]string
+	w.Watch(config.ChainsConfig, func(cm *corev1.ConfigMap) {
+		late = append(late, cm.Data)
+	})
+
+	want := []map[string]string{
+		{"artifacts.taskrun.format": "tekton"},
+		{"artifacts.taskrun.format": "in-toto"},
+		{"artifacts.taskrun.format": "in-toto"},
+		{"artifacts.taskrun.format": "tekton-provenance"},
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("configurations observed diff (-want +got):\n%s", d)
+	}
+	if d := cmp.Diff(want[len(want)-1:], late); d != "" {
+		t.Errorf("configurations observed by a later observer diff (-want +got):\n%s", d)
+	}
+	if d := cmp.Diff([]string{other.Name}, others); d != "" {
+		t.Errorf("other ConfigMaps observed diff (-want +got):\n%s", d)
+	}
+}
+
+func TestWatcherFor(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	cmw := configmap.NewStaticWatcher()
+	w := WatcherFor(ctx, cmw, "")
+	if WatcherFor(ctx, cmw, "") != w {
+		t.Error("expected the controllers sharing a configmap.Watcher to share its Watcher")
+	}
+	if WatcherFor(ctx, w, "") != w {
+		t.Error("expected a Watcher not to be wrapped again")
+	}
+	// Without a dynamic client, only the ConfigMap is read.
+	w.Run(ctx)
+}
+
+func TestLoad(t *testing.T) {
+	cm := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: config.ChainsConfig, Namespace: system.Namespace()},
+			Data:       data,
+		}
+	}
+	tests := []struct {
+		name       string
+		configMaps []*corev1.ConfigMap
+		overrides  map[string]string
+		wantFormat string
+		wantErr    bool
+	}{
+		{
+			name:       "configmap",
+			configMaps: []*corev1.ConfigMap{cm(map[string]string{"artifacts.taskrun.format": "tekton"})},
+			wantFormat: "tekton",
+		},
+		{
+			name:       "no configmap",
+			wantFormat: "tekton",
+		},
+		{
+			name:       "overrides",
+			configMaps: []*corev1.ConfigMap{cm(map[string]string{"artifacts.taskrun.format": "tekton"})},
+			overrides:  map[string]string{"artifacts.taskrun.format": "in-toto"},
+			wantFormat: "in-toto",
+		},
+		{
+			name:       "invalid",
+			configMaps: []*corev1.ConfigMap{cm(map[string]string{"artifacts.taskrun.storage": "tekton,mongo"})},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kc := fakekube.NewSimpleClientset()
+			for _, cm := range tt.configMaps {
+				if _, err := kc.CoreV1().ConfigMaps(cm.Namespace).Create(context.Background(), cm, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Without a dynamic client, only the ConfigMap is read.
+			cfg, err := Load(context.Background(), nil, kc, system.Namespace(), tt.overrides)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && cfg.Artifacts.TaskRuns.Format != tt.wantFormat {
+				t.Errorf("Load() format = %s, want %s", cfg.Artifacts.TaskRuns.Format, tt.wantFormat)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	signing "github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+)
+
+// backfillPageSize is the number of TaskRuns listed at a time during a backfill.
+const backfillPageSize = 500
+
+// Backfill reconciles the TaskRuns in namespace, or in all namespaces if it is empty,
+// that completed after since and haven't been signed, e.g. because they completed
+// before chains was installed or while the controller was down. Like the controller, it only
+// lists the TaskRuns that match the LabelSelector of the CacheOptions in ctx, if there are any.
+// The chains config must be in ctx. It returns the number of TaskRuns reconciled.
+func (r *Reconciler) Backfill(ctx context.Context, namespace string, since time.Time) (int, error) {
+	logger := logging.FromContext(ctx)
+	var merr *multierror.Error
+	count := 0
+	opts := metav1.ListOptions{Limit: backfillPageSize}
+	if cacheOpts, ok := getCacheOptions(ctx); ok {
+		opts.LabelSelector = cacheOpts.LabelSelector
+	}
+	for {
+		trs, err := r.Pipelineclientset.TektonV1beta1().TaskRuns(namespace).List(ctx, opts)
+		if err != nil {
+			return count, errors.Wrap(err, "listing taskruns")
+		}
+		for i := range trs.Items {
+			tr := &trs.Items[i]
+			if !needsBackfill(tr, since) {
+				continue
+			}
+			logger.Infof("backfilling taskrun %s/%s", tr.Namespace, tr.Name)
+			if err := r.ReconcileKind(ctx, tr); err != nil {
+				merr = multierror.Append(merr, errors.Wrapf(err, "taskrun %s/%s", tr.Namespace, tr.Name))
+				continue
+			}
+			count++
+		}
+		if trs.Continue == "" {
+			return count, merr.ErrorOrNil()
+		}
+		opts.Continue = trs.Continue
+	}
+}
+
+// needsBackfill returns whether the TaskRun completed after since without being signed.
+func needsBackfill(tr *v1beta1.TaskRun, since time.Time) bool {
+	if !tr.IsDone() || signing.Reconciled(tr) {
+		return false
+	}
+	return completedAt(tr).After(since)
+}
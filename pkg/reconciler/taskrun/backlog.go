@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/metrics"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// BacklogAfter is how long after they completed unsigned TaskRuns are signed as backlog, e.g.
+// when the controller catches up after an outage: only while no other TaskRuns wait in the
+// work queue, and on all workers but one, so the TaskRuns that complete in the meantime are
+// signed first. Disabled when 0.
+var BacklogAfter time.Duration
+
+// BacklogMaxWait is how long a TaskRun is held back at most, so that the backlog is drained on
+// clusters whose work queue is never empty. The TaskRuns that waited longer are signed on the
+// workers left to the backlog. Unlimited when 0.
+var BacklogMaxWait = 30 * time.Minute
+
+// backlogAdmissionTimeout is how long a slot is kept for a TaskRun let out of the backlog, in
+// case it's never reconciled, e.g. because it was deleted.
+const backlogAdmissionTimeout = time.Minute
+
+// backlogRetryTimeout is how long a TaskRun let out of the backlog stays out after it failed to
+// be signed, so that it isn't held back again when it's retried. It's longer than the longest
+// backoff of the work queue.
+const backlogRetryTimeout = time.Hour
+
+// backlogCheckPeriod is how often the backlog checks for free workers when nothing woke it.
+var backlogCheckPeriod = time.Second
+
+// backlog holds back the TaskRuns that completed more than after ago until the work queue is
+// empty, or they waited maxWait, and a slot is free, and lets the most recently completed ones
+// out first.
+type backlog struct {
+	after   time.Duration
+	maxWait time.Duration
+	// slots is how many backlog TaskRuns are signed at once.
+	slots int
+	// idle reports whether no TaskRuns wait in the work queue.
+	idle func() bool
+	// enqueue puts a TaskRun let out of the backlog back in the work queue.
+	enqueue func(types.NamespacedName)
+
+	mu sync.Mutex
+	// waiting are the held back TaskRuns.
+	waiting map[types.NamespacedName]held
+	// admitted are the TaskRuns let out that haven't been signed yet.
+	admitted map[types.NamespacedName]admission
+	signing  int
+	wake     chan struct{}
+}
+
+// held is a TaskRun in the backlog.
+type held struct {
+	// completed is when the TaskRun completed.
+	completed time.Time
+	// since is when the TaskRun was first held back.
+	since time.Time
+}
+
+// admission is a TaskRun let out of the backlog.
+type admission struct {
+	// at is when the TaskRun was let out, or last failed to be signed.
+	at time.Time
+	// failed is set once the TaskRun failed to be signed. It doesn't keep a slot while it
+	// waits to be retried, so that TaskRuns that keep failing don't hold up the backlog.
+	failed bool
+}
+
+// newBacklog returns a backlog whose TaskRuns are signed on all of the workers but one.
+func newBacklog(after, maxWait time.Duration, workers int, idle func() bool, enqueue func(types.NamespacedName)) *backlog {
+	slots := workers - 1
+	if slots < 1 {
+		slots = 1
+	}
+	return &backlog{
+		after:    after,
+		maxWait:  maxWait,
+		slots:    slots,
+		idle:     idle,
+		enqueue:  enqueue,
+		waiting:  map[types.NamespacedName]held{},
+		admitted: map[types.NamespacedName]admission{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// admit reports whether tr is signed now. A TaskRun that completed more than after ago is held
+// back until it's let out of the backlog, and release has to be called once it's been signed,
+// or wasn't after all. A TaskRun that wasn't signed stays out of the backlog when it's retried.
+// TaskRuns being deleted aren't held back, since their deletion waits for them to be signed.
+// A nil backlog admits every TaskRun.
+func (b *backlog) admit(tr *v1beta1.TaskRun) (release func(signed bool), ok bool) {
+	completed := completedAt(tr)
+	if b == nil || completed.IsZero() || time.Since(completed) < b.after || !tr.GetDeletionTimestamp().IsZero() {
+		return func(bool) {}, true
+	}
+	key := types.NamespacedName{Namespace: tr.Namespace, Name: tr.Name}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.admitted[key]; ok {
+		// The slot of the admission is taken over by signing.
+		b.admitted[key] = admission{at: time.Now(), failed: true}
+		b.signing++
+		return func(signed bool) {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.signing--
+			if signed {
+				delete(b.admitted, key)
+			} else {
+				b.admitted[key] = admission{at: time.Now(), failed: true}
+			}
+			b.notify()
+		}, true
+	}
+	if _, ok := b.waiting[key]; !ok {
+		b.waiting[key] = held{completed: completed, since: time.Now()}
+	}
+	b.notify()
+	return nil, false
+}
+
+// notify wakes run up without waiting for it.
+func (b *backlog) notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run lets TaskRuns out of the backlog, and reports how many are held back, until ctx is done.
+func (b *backlog) run(ctx context.Context) {
+	ticker := time.NewTicker(backlogCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.wake:
+		case <-ticker.C:
+		}
+		keys, waiting := b.next(time.Now())
+		for _, key := range keys {
+			b.enqueue(key)
+		}
+		metrics.RecordBacklogTaskRuns(ctx, waiting)
+	}
+}
+
+// next returns the TaskRuns let out of the backlog, as many as there are free slots, and how
+// many are still held back. Only the TaskRuns that waited longer than maxWait are let out while
+// the work queue isn't idle.
+func (b *backlog) next(now time.Time) ([]types.NamespacedName, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	taken := b.signing
+	for key, a := range b.admitted {
+		switch {
+		case a.failed && now.Sub(a.at) > backlogRetryTimeout, !a.failed && now.Sub(a.at) > backlogAdmissionTimeout:
+			delete(b.admitted, key)
+		case !a.failed:
+			taken++
+		}
+	}
+	if len(b.waiting) == 0 {
+		return nil, 0
+	}
+	idle := b.idle()
+	var keys []types.NamespacedName
+	for free := b.slots - taken; free > 0; free-- {
+		var newest types.NamespacedName
+		var newestAt time.Time
+		for key, h := range b.waiting {
+			if !idle && (b.maxWait <= 0 || now.Sub(h.since) < b.maxWait) {
+				continue
+			}
+			if newest.Name == "" || h.completed.After(newestAt) || (h.completed.Equal(newestAt) && key.String() < newest.String()) {
+				newest, newestAt = key, h.completed
+			}
+		}
+		if newest.Name == "" {
+			break
+		}
+		delete(b.waiting, newest)
+		b.admitted[newest] = admission{at: now}
+		keys = append(keys, newest)
+	}
+	return keys, len(b.waiting)
+}
+
+// completedAt returns when the TaskRun completed.
+func completedAt(tr *v1beta1.TaskRun) time.Time {
+	if tr.Status.CompletionTime != nil {
+		return tr.Status.CompletionTime.Time
+	}
+	return tr.CreationTimestamp.Time
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+func completedTaskRun(name string, ago time.Duration) *v1beta1.TaskRun {
+	return &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Status: v1beta1.TaskRunStatus{
+			Status: duckv1beta1.Status{Conditions: []apis.Condition{{Type: apis.ConditionSucceeded, Status: "True"}}},
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				CompletionTime: &metav1.Time{Time: time.Now().Add(-ago)},
+			},
+		},
+	}
+}
+
+func TestBacklog(t *testing.T) {
+	idle := false
+	b := newBacklog(10*time.Minute, 30*time.Minute, 3, func() bool { return idle }, nil)
+	names := func(keys []types.NamespacedName) []string {
+		var l []string
+		for _, k := range keys {
+			l = append(l, k.Name)
+		}
+		return l
+	}
+
+	if _, ok := b.admit(completedTaskRun("recent", time.Minute)); !ok {
+		t.Error("expected a recent TaskRun to be signed now")
+	}
+	deleting := completedTaskRun("deleting", time.Hour)
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	if _, ok := b.admit(deleting); !ok {
+		t.Error("expected a TaskRun being deleted to be signed now")
+	}
+	trs := map[string]*v1beta1.TaskRun{}
+	for i := 1; i <= 3; i++ {
+		tr := completedTaskRun(fmt.Sprintf("%dh", i), time.Duration(i)*time.Hour)
+		trs[tr.Name] = tr
+		if _, ok := b.admit(tr); ok {
+			t.Errorf("expected %s to be held back", tr.Name)
+		}
+	}
+
+	now := time.Now()
+	if keys, waiting := b.next(now); len(keys) != 0 || waiting != 3 {
+		t.Errorf("next() = %v, %d while the work queue isn't idle, want none of 3 let out", keys, waiting)
+	}
+	idle = true
+	// Two of the three workers sign backlog TaskRuns, the most recently completed first.
+	keys, waiting := b.next(now)
+	if diff := cmp.Diff([]string{"1h", "2h"}, names(keys)); diff != "" || waiting != 1 {
+		t.Errorf("next() mismatch with %d waiting (-want +got): %s", waiting, diff)
+	}
+	release, ok := b.admit(trs["1h"])
+	if !ok {
+		t.Fatal("expected a TaskRun let out of the backlog to be signed")
+	}
+	if keys, _ := b.next(now); len(keys) != 0 {
+		t.Errorf("next() = %v with no free slots, want none", keys)
+	}
+	// A TaskRun that failed to be signed frees its slot, but isn't held back when it's retried.
+	release(false)
+	if keys, _ := b.next(now); !cmp.Equal([]string{"3h"}, names(keys)) {
+		t.Errorf("next() = %v once a slot is free, want 3h", names(keys))
+	}
+	release, ok = b.admit(trs["1h"])
+	if !ok {
+		t.Fatal("expected a TaskRun let out of the backlog to stay out when it's retried")
+	}
+	release(true)
+	if _, ok := b.admitted[types.NamespacedName{Namespace: "ns", Name: "1h"}]; ok {
+		t.Error("expected a signed TaskRun to be let go of")
+	}
+
+	// The slots of the TaskRuns let out that are never reconciled are freed.
+	if keys, waiting := b.next(now.Add(2 * backlogAdmissionTimeout)); len(keys) != 0 || waiting != 0 || len(b.admitted) != 0 {
+		t.Errorf("next() = %v, %d with %v admitted, want the admissions to time out", keys, waiting, b.admitted)
+	}
+
+	var nilBacklog *backlog
+	if _, ok := nilBacklog.admit(trs["3h"]); !ok {
+		t.Error("expected a nil backlog to admit every TaskRun")
+	}
+}
+
+func TestBacklog_maxWait(t *testing.T) {
+	b := newBacklog(10*time.Minute, 30*time.Minute, 2, func() bool { return false }, nil)
+	for _, name := range []string{"a", "b"} {
+		if _, ok := b.admit(completedTaskRun(name, time.Hour)); ok {
+			t.Errorf("expected %s to be held back", name)
+		}
+	}
+
+	now := time.Now()
+	if keys, _ := b.next(now.Add(20 * time.Minute)); len(keys) != 0 {
+		t.Errorf("next() = %v before the TaskRuns waited 30m, want none", keys)
+	}
+	// The TaskRuns are let out on the slots of the backlog without waiting for an idle queue.
+	if keys, waiting := b.next(now.Add(31 * time.Minute)); len(keys) != 1 || waiting != 1 {
+		t.Errorf("next() = %v, %d once the TaskRuns waited 30m, want one let out and one waiting", keys, waiting)
+	}
+}
+
+func TestReconciler_backlog(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ctx = config.ToContext(ctx, &config.Config{})
+	ps := fakepipelineclient.Get(ctx)
+	tr := completedTaskRun("old", time.Hour)
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var enqueued []types.NamespacedName
+	signer := &mockSigner{}
+	r := &Reconciler{
+		TaskRunSigner:     signer,
+		KubeClient:        fakekubeclient.Get(ctx),
+		Pipelineclientset: ps,
+		backlog: newBacklog(10*time.Minute, 0, 2, func() bool { return true }, func(key types.NamespacedName) {
+			enqueued = append(enqueued, key)
+		}),
+	}
+
+	if err := r.ReconcileKind(ctx, tr); err != nil {
+		t.Fatalf("Reconciler.ReconcileKind() error = %v", err)
+	}
+	if signer.signed {
+		t.Fatal("expected a TaskRun in the backlog not to be signed")
+	}
+	keys, _ := r.backlog.next(time.Now())
+	for _, key := range keys {
+		r.backlog.enqueue(key)
+	}
+	if want := []types.NamespacedName{{Namespace: "ns", Name: "old"}}; !cmp.Equal(want, enqueued) {
+		t.Fatalf("enqueued %v, want %v", enqueued, want)
+	}
+	if err := r.ReconcileKind(ctx, tr); err != nil {
+		t.Fatalf("Reconciler.ReconcileKind() error = %v", err)
+	}
+	if !signer.signed {
+		t.Error("expected the TaskRun let out of the backlog to be signed")
+	}
+	if r.backlog.signing != 0 {
+		t.Errorf("%d TaskRuns are still signing, want the slot released", r.backlog.signing)
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"time"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	"github.com/tektoncd/pipeline/pkg/client/informers/externalversions"
+	pipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client"
+	"github.com/tektoncd/pipeline/pkg/client/injection/informers/factory"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+)
+
+// CacheOptions reduce the memory used by the TaskRun informer cache.
+type CacheOptions struct {
+	// Strip removes the fields that aren't needed to decide whether to sign a TaskRun
+	// from cached TaskRuns. TaskRuns are fetched in full before they are signed.
+	Strip bool
+	// LabelSelector limits the cache to the TaskRuns that match it.
+	LabelSelector string
+}
+
+type cacheOptionsKey struct{}
+
+// WithCacheOptions returns a context whose informers are set up with opts.
+func WithCacheOptions(ctx context.Context, opts CacheOptions) context.Context {
+	return context.WithValue(ctx, cacheOptionsKey{}, opts)
+}
+
+func getCacheOptions(ctx context.Context) (CacheOptions, bool) {
+	opts, ok := ctx.Value(cacheOptionsKey{}).(CacheOptions)
+	return opts, ok
+}
+
+func init() {
+	// This runs after the factory's own injector, so it replaces that factory.
+	injection.Default.RegisterInformerFactory(withCacheOptionsFactory)
+}
+
+// withCacheOptionsFactory replaces the Tekton informer factory with one that applies
+// the CacheOptions in ctx, if there are any.
+func withCacheOptionsFactory(ctx context.Context) context.Context {
+	opts, ok := getCacheOptions(ctx)
+	if !ok {
+		return ctx
+	}
+	namespace := injection.GetNamespaceScope(ctx)
+	tweak := func(o *metav1.ListOptions) {
+		if opts.LabelSelector != "" {
+			o.LabelSelector = opts.LabelSelector
+		}
+	}
+	f := externalversions.NewSharedInformerFactoryWithOptions(pipelineclient.Get(ctx), controller.GetResyncPeriod(ctx),
+		externalversions.WithNamespace(namespace), externalversions.WithTweakListOptions(tweak))
+	if opts.Strip {
+		// The factory hands out the first informer created for a type, so the
+		// generated TaskRun informer uses this one.
+		f.InformerFor(&v1beta1.TaskRun{}, func(c versioned.Interface, resync time.Duration) cache.SharedIndexInformer {
+			return newStrippedTaskRunInformer(c, namespace, resync, tweak)
+		})
+	}
+	return context.WithValue(ctx, factory.Key{}, f)
+}
+
+// newStrippedTaskRunInformer returns a TaskRun informer that strips TaskRuns before caching them.
+func newStrippedTaskRunInformer(c versioned.Interface, namespace string, resync time.Duration, tweak func(*metav1.ListOptions)) cache.SharedIndexInformer {
+	lw := &cache.ListWatch{
+		ListFunc: func(o metav1.ListOptions) (runtime.Object, error) {
+			tweak(&o)
+			trs, err := c.TektonV1beta1().TaskRuns(namespace).List(context.TODO(), o)
+			if err != nil {
+				return nil, err
+			}
+			for i := range trs.Items {
+				stripTaskRun(&trs.Items[i])
+			}
+			return trs, nil
+		},
+		WatchFunc: func(o metav1.ListOptions) (watch.Interface, error) {
+			tweak(&o)
+			w, err := c.TektonV1beta1().TaskRuns(namespace).Watch(context.TODO(), o)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(e watch.Event) (watch.Event, bool) {
+				if tr, ok := e.Object.(*v1beta1.TaskRun); ok {
+					stripTaskRun(tr)
+				}
+				return e, true
+			}), nil
+		},
+	}
+	return cache.NewSharedIndexInformer(lw, &v1beta1.TaskRun{}, resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// stripTaskRun removes the fields that can be large and aren't needed to decide
+// whether to sign the TaskRun. Labels, annotations, the TaskRef, the conditions and
+// the start and completion times are kept.
+func stripTaskRun(tr *v1beta1.TaskRun) {
+	tr.ManagedFields = nil
+	delete(tr.Annotations, corev1.LastAppliedConfigAnnotation)
+
+	tr.Spec.Params = nil
+	tr.Spec.TaskSpec = nil
+	tr.Spec.PodTemplate = nil
+	tr.Spec.Workspaces = nil
+	tr.Spec.Resources = nil
+
+	tr.Status.Steps = nil
+	tr.Status.Sidecars = nil
+	tr.Status.CloudEvents = nil
+	tr.Status.RetriesStatus = nil
+	tr.Status.ResourcesResult = nil
+	tr.Status.TaskRunResults = nil
+	tr.Status.TaskSpec = nil
+}
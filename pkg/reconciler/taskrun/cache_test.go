@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"testing"
+
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	rtesting "knative.dev/pkg/reconciler/testing"
+)
+
+func fullTaskRun(name string, labels map[string]string) *v1beta1.TaskRun {
+	return &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          name,
+			Namespace:     "default",
+			Labels:        labels,
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "tekton"}},
+		},
+		Spec: v1beta1.TaskRunSpec{
+			TaskRef: &v1beta1.TaskRef{Name: "build"},
+			Params:  []v1beta1.Param{{Name: "foo", Value: *v1beta1.NewArrayOrString("bar")}},
+		},
+		Status: v1beta1.TaskRunStatus{
+			Status: duckv1beta1.Status{
+				Conditions: []apis.Condition{{Type: apis.ConditionSucceeded}},
+			},
+			TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+				TaskRunResults: []v1beta1.TaskRunResult{{Name: "IMAGE_DIGEST", Value: "sha256:abc"}},
+				Steps:          []v1beta1.StepState{{Name: "build"}},
+			},
+		},
+	}
+}
+
+func TestStrippedTaskRunInformer(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ps := fakepipelineclient.Get(ctx)
+	for _, tr := range []*v1beta1.TaskRun{
+		fullTaskRun("selected", map[string]string{"sign": "true"}),
+		fullTaskRun("other", nil),
+	} {
+		if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	informer := newStrippedTaskRunInformer(ps, "", 0, func(o *metav1.ListOptions) {
+		o.LabelSelector = "sign=true"
+	})
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatal("informer didn't sync")
+	}
+
+	items := informer.GetStore().List()
+	if len(items) != 1 {
+		t.Fatalf("cached %d TaskRuns, wanted only the selected one", len(items))
+	}
+	tr := items[0].(*v1beta1.TaskRun)
+	if tr.ManagedFields != nil || tr.Spec.Params != nil || tr.Status.TaskRunResults != nil || tr.Status.Steps != nil {
+		t.Errorf("expected cached TaskRun to be stripped, got %+v", tr)
+	}
+	if tr.Spec.TaskRef == nil || tr.Spec.TaskRef.Name != "build" || !tr.IsDone() || tr.Labels["sign"] != "true" {
+		t.Errorf("expected fields used for filtering to be kept, got %+v", tr)
+	}
+}
+
+func TestReconciler_fetchBeforeSigning(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ctx = config.ToContext(ctx, &config.Config{})
+	ps := fakepipelineclient.Get(ctx)
+
+	tr := fullTaskRun("foo", nil)
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	cached := tr.DeepCopy()
+	stripTaskRun(cached)
+
+	signer := &mockSigner{}
+	r := &Reconciler{
+		TaskRunSigner:      signer,
+		KubeClient:         fakekubeclient.Get(ctx),
+		Pipelineclientset:  ps,
+		FetchBeforeSigning: true,
+	}
+	if err := r.ReconcileKind(ctx, cached); err != nil {
+		t.Fatalf("Reconciler.ReconcileKind() error = %v", err)
+	}
+	if !signer.signed || len(signer.tr.Status.TaskRunResults) != 1 {
+		t.Errorf("expected the full TaskRun to be signed, got %+v", signer.tr)
+	}
+}
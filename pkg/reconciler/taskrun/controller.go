@@ -17,40 +17,156 @@ import (
 	"context"
 
 	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/limit"
+	"github.com/tektoncd/chains/pkg/chains/logs"
 	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/chains/pkg/metrics"
+	"github.com/tektoncd/chains/pkg/reconciler/chainsconfig"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
 	pipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client"
 	taskruninformer "github.com/tektoncd/pipeline/pkg/client/injection/informers/pipeline/v1beta1/taskrun"
 	taskrunreconciler "github.com/tektoncd/pipeline/pkg/client/injection/reconciler/pipeline/v1beta1/taskrun"
+	"go.uber.org/zap"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
 )
 
+// NewController returns a controller that adds a finalizer to the completed TaskRuns, so they
+// can't be deleted before chains has had a chance to sign them.
 func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	watchLogLevels(ctx, cmw)
+	if DebugPort != 0 {
+		serveDebug(ctx, DebugPort)
+	}
+	if ReadinessPort != 0 {
+		serveReadiness(ctx, ReadinessPort)
+	}
+	return newController(ctx, cmw, true, "")
+}
+
+// NewControllerWithoutFinalizer returns a controller that signs TaskRuns without
+// adding a finalizer to them, and removes it from the TaskRuns that have it.
+func NewControllerWithoutFinalizer(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	watchLogLevels(ctx, cmw)
+	if DebugPort != 0 {
+		serveDebug(ctx, DebugPort)
+	}
+	if ReadinessPort != 0 {
+		serveReadiness(ctx, ReadinessPort)
+	}
+	return newController(ctx, cmw, false, "")
+}
+
+// watchLogLevels sets the levels of the components in package logs from the config-logging
+// ConfigMap, when there is one, as the level of the controller is.
+func watchLogLevels(ctx context.Context, cmw configmap.Watcher) {
 	logger := logging.FromContext(ctx)
+	_, err := kubeclient.Get(ctx).CoreV1().ConfigMaps(system.Namespace()).Get(ctx, logging.ConfigMapName(), metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return
+	case err != nil:
+		logger.Errorw("Error reading the logging configmap, the components log at the level of the controller", zap.Error(err))
+		return
+	}
+	cmw.Watch(logging.ConfigMapName(), logs.UpdateLevels(logger))
+}
+
+// newController returns a controller that signs the TaskRuns in a cluster, named cluster if
+// it's a remote one.
+func newController(ctx context.Context, cmw configmap.Watcher, useFinalizer bool, cluster string) *controller.Impl {
+	logger := logging.FromContext(ctx)
+	if err := checkStatefulSetShard(ctx); err != nil {
+		logger.Fatalw("Error sharding TaskRuns across replicas", zap.Error(err))
+	}
 	taskRunInformer := taskruninformer.Get(ctx)
+	// The ChainsConfig, if there is one, takes the place of the chains-config ConfigMap.
+	chainsConfigWatcher := chainsconfig.WatcherFor(ctx, cmw, SecretPath)
+	chainsConfigWatcher.Run(ctx)
 
-	c := &Reconciler{
-		TaskRunSigner: &chains.TaskRunSigner{
-			KubeClient:        kubeclient.Get(ctx),
-			Pipelineclientset: pipelineclient.Get(ctx),
-			SecretPath:        SecretPath,
-		},
+	c := NewReconciler(kubeclient.Get(ctx), pipelineclient.Get(ctx), chains.NewTlogQueue(pipelineclient.Get(ctx)))
+	c.Cluster = cluster
+	c.UseFinalizer = useFinalizer
+	namespaceConfigs, err := NewNamespaceConfigLister(ctx, kubeclient.Get(ctx))
+	if err != nil {
+		logger.Fatalw("Error watching the chains config of the namespaces", zap.Error(err))
+	}
+	c.NamespaceConfigs = namespaceConfigs
+	if c.Namespaces, err = NewNamespaceLister(ctx, kubeclient.Get(ctx)); err != nil {
+		logger.Fatalw("Error watching the namespaces", zap.Error(err))
+	}
+	c.quota = newNamespaceQuota()
+	if opts, ok := getCacheOptions(ctx); ok {
+		c.FetchBeforeSigning = opts.Strip
+	}
+	// The limits on the calls to KMSs and registries follow the configuration, and the signers
+	// of the local cluster's configuration are probed for readiness.
+	onAfterStore := []func(string, interface{}){limit.Update}
+	if cluster == "" {
+		onAfterStore = append(onAfterStore, readiness.watch(ctx, SecretPath))
 	}
+	var cfgStore *config.ConfigStore
 	impl := taskrunreconciler.NewImpl(ctx, c, func(impl *controller.Impl) controller.Options {
-		cfgStore := config.NewConfigStore(logger)
-		cfgStore.WatchConfigs(cmw)
+		cfgStore = config.NewValidatingConfigStoreWithOverrides(logger, chains.ValidateConfig(SecretPath, logger), config.OverridesFromContext(ctx), onAfterStore...)
+		cfgStore.WatchConfigs(chainsConfigWatcher)
 
 		return controller.Options{
 			// The chains reconciler shouldn't mutate the taskrun's status.
 			SkipStatusUpdates: true,
 			ConfigStore:       cfgStore,
-			FinalizerName:     "chains.tekton.dev",
 		}
 	})
+	impl.Reconciler = &finalizingReconciler{
+		generatedReconciler: impl.Reconciler.(generatedReconciler),
+		r:                   c,
+		lister:              taskRunInformer.Lister(),
+		configStore:         cfgStore,
+	}
+
+	// The TaskRun informer watches every namespace, and the TaskRuns of the namespaces that
+	// aren't signed are filtered out before they're queued.
+	taskRunInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: c.watched(cfgStore),
+		Handler:    controller.HandleAll(impl.Enqueue),
+	})
+	// The gauges of each cluster are reported separately.
+	metricsCtx := metrics.WithCluster(ctx, cluster)
+	if BacklogAfter > 0 {
+		c.backlog = newBacklog(BacklogAfter, BacklogMaxWait, controller.DefaultThreadsPerController,
+			func() bool { return impl.WorkQueue().Len() == 0 }, impl.EnqueueKey)
+		go c.backlog.run(metricsCtx)
+	}
 
-	taskRunInformer.Informer().AddEventHandler(controller.HandleAll(impl.Enqueue))
+	// With buckets, each replica only lists and counts the TaskRuns it reconciles.
+	shard := shardOf(impl)
+	signings.addCluster(cluster, taskRunInformer.Lister(), shard)
+	go reportUnsignedTaskRuns(metricsCtx, taskRunInformer.Lister(), shard)
+	go c.TlogQueue.Run(ctx)
 
 	return impl
 }
+
+// NewReconciler returns a Reconciler that signs TaskRuns with the keys in SecretPath. When
+// transparency.async is set, uploads to transparency logs are queued on queue, which must
+// be running. Without a queue, they're always uploaded as the TaskRuns are signed.
+func NewReconciler(kubeClient kubernetes.Interface, pipelineClient versioned.Interface, queue *chains.TlogQueue) *Reconciler {
+	return &Reconciler{
+		TaskRunSigner: &chains.TaskRunSigner{
+			KubeClient:        kubeClient,
+			Pipelineclientset: pipelineClient,
+			SecretPath:        SecretPath,
+			TlogQueue:         queue,
+			PayloadCache:      chains.NewPayloadCache(),
+		},
+		KubeClient:        kubeClient,
+		Pipelineclientset: pipelineClient,
+		TlogQueue:         queue,
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	signing "github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/logging"
+)
+
+// DebugPath is the path of the endpoint that lists the TaskRuns waiting to be signed, being
+// signed, and that failed to be signed.
+const DebugPath = "/debug/signings"
+
+// DebugPort is the port the debug endpoint is served on, on localhost only, so that it's
+// reached with kubectl port-forward. It isn't served when 0.
+var DebugPort = 0
+
+// Profiling serves the net/http/pprof profiles of the controller under /debug/pprof/ on the
+// debug port too.
+var Profiling = false
+
+// Signings is the response of the debug endpoint.
+type Signings struct {
+	// Queued are the completed TaskRuns that haven't been signed yet, including those that
+	// are retried after an error.
+	Queued []SigningStatus `json:"queued"`
+	// InFlight are the TaskRuns being signed.
+	InFlight []SigningStatus `json:"inFlight"`
+	// Failed are the TaskRuns that failed to be signed and won't be retried.
+	Failed []SigningStatus `json:"failed"`
+}
+
+// SigningStatus is where a TaskRun is in the signing process.
+type SigningStatus struct {
+	// Cluster is the remote cluster the TaskRun is in, or empty for the local one.
+	Cluster   string    `json:"cluster,omitempty"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	UID       types.UID `json:"uid"`
+	// State is the signing state of the TaskRun, empty if it hasn't been seen yet.
+	State string `json:"state,omitempty"`
+	// Since is when the TaskRun got to the state, or started being signed for those in flight.
+	Since         *time.Time `json:"since,omitempty"`
+	Retries       int        `json:"retries,omitempty"`
+	FailureReason string     `json:"failureReason,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+}
+
+// signings tracks the TaskRuns being signed, and lists the others in the caches of the
+// controllers of each cluster.
+var signings = &signingTracker{
+	listers:  map[string]listers.TaskRunLister{},
+	shards:   map[string]shard{},
+	inFlight: map[types.UID]inFlightSigning{},
+}
+
+type signingTracker struct {
+	mu       sync.Mutex
+	listers  map[string]listers.TaskRunLister
+	shards   map[string]shard
+	inFlight map[types.UID]inFlightSigning
+}
+
+type inFlightSigning struct {
+	cluster string
+	tr      *v1beta1.TaskRun
+	start   time.Time
+}
+
+// addCluster lists the TaskRuns of a cluster in shard with lister.
+func (t *signingTracker) addCluster(cluster string, lister listers.TaskRunLister, shard shard) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listers[cluster] = lister
+	t.shards[cluster] = shard
+}
+
+// start records that a TaskRun is being signed, until the returned func is called.
+func (t *signingTracker) start(cluster string, tr *v1beta1.TaskRun) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight[tr.UID] = inFlightSigning{cluster: cluster, tr: tr, start: time.Now()}
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.inFlight, tr.UID)
+	}
+}
+
+// list returns the signings of the TaskRuns in namespace, or in every namespace if it's empty.
+func (t *signingTracker) list(namespace string) (*Signings, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &Signings{Queued: []SigningStatus{}, InFlight: []SigningStatus{}, Failed: []SigningStatus{}}
+	for _, f := range t.inFlight {
+		if namespace != "" && f.tr.Namespace != namespace {
+			continue
+		}
+		status := signingStatus(f.cluster, f.tr)
+		start := f.start
+		status.Since = &start
+		s.InFlight = append(s.InFlight, status)
+	}
+	for cluster, lister := range t.listers {
+		var trs []*v1beta1.TaskRun
+		var err error
+		if namespace == "" {
+			trs, err = lister.List(labels.Everything())
+		} else {
+			trs, err = lister.TaskRuns(namespace).List(labels.Everything())
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, tr := range trs {
+			if _, ok := t.inFlight[tr.UID]; ok || !tr.IsDone() || !t.shards[cluster](tr) {
+				continue
+			}
+			switch {
+			case signing.State(tr) == signing.StateFailed:
+				s.Failed = append(s.Failed, signingStatus(cluster, tr))
+			case !signing.Reconciled(tr):
+				s.Queued = append(s.Queued, signingStatus(cluster, tr))
+			}
+		}
+	}
+	for _, l := range [][]SigningStatus{s.Queued, s.InFlight, s.Failed} {
+		sortSignings(l)
+	}
+	return s, nil
+}
+
+func signingStatus(cluster string, tr *v1beta1.TaskRun) SigningStatus {
+	status := SigningStatus{
+		Cluster:       cluster,
+		Namespace:     tr.Namespace,
+		Name:          tr.Name,
+		UID:           tr.UID,
+		State:         signing.State(tr),
+		FailureReason: tr.Annotations[signing.FailureReasonAnnotation],
+		LastError:     tr.Annotations[signing.LastErrorAnnotation],
+	}
+	if since, err := time.Parse(time.RFC3339, tr.Annotations[signing.StateTimeAnnotation]); err == nil {
+		status.Since = &since
+	} else if tr.Status.CompletionTime != nil {
+		status.Since = &tr.Status.CompletionTime.Time
+	}
+	// The annotation counts the retries from 0.
+	if retries, err := strconv.Atoi(tr.Annotations[signing.RetryAnnotation]); err == nil {
+		status.Retries = retries + 1
+	}
+	return status
+}
+
+func sortSignings(l []SigningStatus) {
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].Cluster != l[j].Cluster {
+			return l[i].Cluster < l[j].Cluster
+		}
+		if l[i].Namespace != l[j].Namespace {
+			return l[i].Namespace < l[j].Namespace
+		}
+		return l[i].Name < l[j].Name
+	})
+}
+
+// ServeHTTP lists the signings as JSON. The namespace query parameter limits them to a
+// namespace.
+func (t *signingTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s, err := t.list(r.URL.Query().Get("namespace"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(s)
+}
+
+// debugHandler serves the debug endpoint, and the profiles when profiling is set.
+func debugHandler(profiling bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(DebugPath, signings)
+	if profiling {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
+
+// serveDebug serves the debug endpoint on localhost until ctx is done.
+func serveDebug(ctx context.Context, port int) {
+	logger := logging.FromContext(ctx)
+	srv := &http.Server{Addr: fmt.Sprintf("127.0.0.1:%d", port), Handler: debugHandler(Profiling)}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Errorf("Error shutting down the debug endpoint: %v", err)
+		}
+	}()
+	go func() {
+		logger.Infof("Serving the debug endpoint on %s%s", srv.Addr, DebugPath)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Errorw("Error serving the debug endpoint", zap.Error(err))
+		}
+	}()
+}
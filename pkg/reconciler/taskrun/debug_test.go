@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	signing "github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+)
+
+func debugTaskRun(namespace, name string, done bool, annotations map[string]string) *v1beta1.TaskRun {
+	tr := &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   namespace,
+		Name:        name,
+		UID:         types.UID(namespace + "/" + name),
+		Annotations: annotations,
+	}}
+	if done {
+		tr.Status.Status = duckv1beta1.Status{Conditions: []apis.Condition{{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue}}}
+	}
+	return tr
+}
+
+func taskRunLister(t *testing.T, trs ...*v1beta1.TaskRun) listers.TaskRunLister {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, tr := range trs {
+		if err := indexer.Add(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return listers.NewTaskRunLister(indexer)
+}
+
+func TestSigningTracker(t *testing.T) {
+	stateTime := "2021-10-12T09:30:00Z"
+	tracker := &signingTracker{
+		listers:  map[string]listers.TaskRunLister{},
+		shards:   map[string]shard{},
+		inFlight: map[types.UID]inFlightSigning{},
+	}
+	signingTR := debugTaskRun("ns", "signing", true, map[string]string{signing.StateAnnotation: signing.StateSigning})
+	tracker.addCluster("", taskRunLister(t,
+		debugTaskRun("ns", "running", false, map[string]string{signing.StateAnnotation: signing.StatePending}),
+		debugTaskRun("ns", "unseen", true, nil),
+		debugTaskRun("ns", "retried", true, map[string]string{
+			signing.StateAnnotation:     signing.StatePending,
+			signing.StateTimeAnnotation: stateTime,
+			signing.RetryAnnotation:     "1",
+			signing.LastErrorAnnotation: "uploading: 503",
+		}),
+		signingTR,
+		debugTaskRun("ns", "signed", true, map[string]string{signing.StateAnnotation: signing.StateSigned, signing.ChainsAnnotation: "true"}),
+		debugTaskRun("other", "failed", true, map[string]string{
+			signing.StateAnnotation:         signing.StateFailed,
+			signing.ChainsAnnotation:        "failed",
+			signing.FailureReasonAnnotation: signing.EventReasonStorageFailed,
+			signing.LastErrorAnnotation:     "storing: 403",
+		}),
+	), wholeShard)
+	// The TaskRuns of the buckets other replicas lead aren't listed.
+	tracker.addCluster("remote", taskRunLister(t, debugTaskRun("ns", "remote", true, nil), debugTaskRun("ns", "other-replica", true, nil)),
+		func(tr *v1beta1.TaskRun) bool { return tr.Name != "other-replica" })
+	done := tracker.start("", signingTR)
+
+	since, _ := time.Parse(time.RFC3339, stateTime)
+	want := &Signings{
+		Queued: []SigningStatus{
+			{Namespace: "ns", Name: "retried", UID: "ns/retried", State: signing.StatePending, Since: &since, Retries: 2, LastError: "uploading: 503"},
+			{Namespace: "ns", Name: "unseen", UID: "ns/unseen"},
+			{Cluster: "remote", Namespace: "ns", Name: "remote", UID: "ns/remote"},
+		},
+		InFlight: []SigningStatus{
+			{Namespace: "ns", Name: "signing", UID: "ns/signing", State: signing.StateSigning},
+		},
+		Failed: []SigningStatus{
+			{Namespace: "other", Name: "failed", UID: "other/failed", State: signing.StateFailed, FailureReason: signing.EventReasonStorageFailed, LastError: "storing: 403"},
+		},
+	}
+	// The start of in-flight signings is the time they started.
+	ignoreInFlightSince := cmpopts.IgnoreFields(SigningStatus{}, "Since")
+
+	rec := httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DebugPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	got := &Signings{}
+	if err := json.Unmarshal(rec.Body.Bytes(), got); err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff(want.Queued, got.Queued); d != "" {
+		t.Errorf("queued diff (-want +got):\n%s", d)
+	}
+	if d := cmp.Diff(want.InFlight, got.InFlight, ignoreInFlightSince); d != "" {
+		t.Errorf("in flight diff (-want +got):\n%s", d)
+	}
+	if len(got.InFlight) == 1 && got.InFlight[0].Since == nil {
+		t.Error("expected the start of in-flight signings")
+	}
+	if d := cmp.Diff(want.Failed, got.Failed); d != "" {
+		t.Errorf("failed diff (-want +got):\n%s", d)
+	}
+
+	done()
+	got, err := tracker.list("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = &Signings{Queued: []SigningStatus{}, InFlight: []SigningStatus{}, Failed: want.Failed}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("in namespace other, signings diff (-want +got):\n%s", d)
+	}
+
+	rec = httptest.NewRecorder()
+	tracker.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, DebugPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	for _, profiling := range []bool{false, true} {
+		rec := httptest.NewRecorder()
+		debugHandler(profiling).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+		want := http.StatusNotFound
+		if profiling {
+			want = http.StatusOK
+		}
+		if rec.Code != want {
+			t.Errorf("with profiling %v, /debug/pprof/ status = %d, want %d", profiling, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewNamespaceLister returns a lister of the namespaces, whose labels namespaces.selector is
+// matched against, which are watched until ctx is done. It returns once they're listed.
+func NewNamespaceLister(ctx context.Context, kubeClient kubernetes.Interface) (corev1listers.NamespaceLister, error) {
+	informer := corev1informers.NewNamespaceInformer(kubeClient, 0, cache.Indexers{})
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return nil, errors.New("listing the namespaces")
+	}
+	return corev1listers.NewNamespaceLister(informer.GetIndexer()), nil
+}
+
+// watched returns the filter of the TaskRun events that are queued. The TaskRuns in the
+// namespaces that aren't signed are only queued while they have the finalizer to remove. The
+// filters are those of the configuration when the event is handled: the TaskRuns of a namespace
+// that is signed later are queued when they're updated next, or on the next resync.
+func (r *Reconciler) watched(store *config.ConfigStore) func(interface{}) bool {
+	return func(obj interface{}) bool {
+		tr, ok := obj.(*v1beta1.TaskRun)
+		if !ok || hasFinalizer(tr) {
+			return true
+		}
+		cfg, ok := store.UntypedLoad(config.ChainsConfig).(*config.Config)
+		if !ok {
+			return true
+		}
+		allowed, err := r.namespaceAllowed(context.Background(), cfg.Namespaces, tr.Namespace)
+		// The TaskRuns whose namespace can't be checked are reconciled, which reports the error.
+		return err != nil || allowed
+	}
+}
+
+// namespaceAllowed returns whether TaskRuns in the namespace should be signed,
+// according to the namespace filters in the chains config.
+func (r *Reconciler) namespaceAllowed(ctx context.Context, cfg config.NamespaceConfig, namespace string) (bool, error) {
+	if sets.NewString(cfg.Exclude...).Has(namespace) {
+		return false, nil
+	}
+	if len(cfg.Include) > 0 && !sets.NewString(cfg.Include...).Has(namespace) {
+		return false, nil
+	}
+	if cfg.Selector == "" {
+		return true, nil
+	}
+	var ns *corev1.Namespace
+	var err error
+	if r.Namespaces != nil {
+		ns, err = r.Namespaces.Get(namespace)
+	} else {
+		ns, err = r.KubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "getting namespace %s", namespace)
+	}
+	ok, err := matches(cfg.Selector, ns.Labels)
+	return ok, errors.Wrap(err, "evaluating namespace selector")
+}
+
+// taskRunSelected returns whether the TaskRun matches the label and annotation
+// selectors and the Task and Pipeline references in the chains config.
+func taskRunSelected(cfg config.FilterConfig, tr *v1beta1.TaskRun) (bool, error) {
+	if !referenceSelected(cfg, tr) {
+		return false, nil
+	}
+	if ok, err := matches(cfg.LabelSelector, tr.Labels); err != nil || !ok {
+		return false, errors.Wrap(err, "evaluating label selector")
+	}
+	if ok, err := matches(cfg.AnnotationSelector, tr.Annotations); err != nil || !ok {
+		return false, errors.Wrap(err, "evaluating annotation selector")
+	}
+	return true, nil
+}
+
+// referenceSelected returns whether the TaskRun runs one of the configured Tasks, Pipelines
+// or Bundles. If none are configured every TaskRun is selected.
+func referenceSelected(cfg config.FilterConfig, tr *v1beta1.TaskRun) bool {
+	if len(cfg.Tasks) == 0 && len(cfg.Pipelines) == 0 && len(cfg.Bundles) == 0 {
+		return true
+	}
+	tasks := sets.NewString(cfg.Tasks...)
+	if tasks.Has(tr.Labels[pipeline.TaskLabelKey]) || tasks.Has(tr.Labels[pipeline.ClusterTaskLabelKey]) {
+		return true
+	}
+	if ref := tr.Spec.TaskRef; ref != nil {
+		if tasks.Has(ref.Name) {
+			return true
+		}
+		for _, b := range cfg.Bundles {
+			if bundleSelected(ref.Bundle, b) {
+				return true
+			}
+		}
+	}
+	return sets.NewString(cfg.Pipelines...).Has(tr.Labels[pipeline.PipelineLabelKey])
+}
+
+// bundleSelected returns whether bundle is in the repository prefix names, with any tag or
+// digest, or in a repository under it when prefix ends with a "/". A prefix only matches whole
+// repositories: gcr.io/org/catalog doesn't select gcr.io/org/catalog-forks:latest.
+func bundleSelected(bundle, prefix string) bool {
+	if bundle == "" || prefix == "" || !strings.HasPrefix(bundle, prefix) {
+		return false
+	}
+	if strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	rest := bundle[len(prefix):]
+	return rest == "" || rest[0] == ':' || rest[0] == '@'
+}
+
+// matches returns whether the set matches the selector. An empty selector matches everything.
+func matches(selector string, set map[string]string) (bool, error) {
+	if selector == "" {
+		return true, nil
+	}
+	s, err := labels.Parse(selector)
+	if err != nil {
+		return false, err
+	}
+	return s.Matches(labels.Set(set)), nil
+}
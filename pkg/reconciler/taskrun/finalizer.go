@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/controller"
+	pkgreconciler "knative.dev/pkg/reconciler"
+)
+
+// finalizerName is the finalizer that keeps the TaskRuns waiting to be signed from being
+// deleted. Versions of Chains before it was only added to those TaskRuns added it to every
+// TaskRun they saw.
+const finalizerName = "chains.tekton.dev"
+
+// hasFinalizer reports whether tr has the chains finalizer.
+func hasFinalizer(tr *v1beta1.TaskRun) bool {
+	return sets.NewString(tr.Finalizers...).Has(finalizerName)
+}
+
+// setFinalizer adds the chains finalizer to tr, or removes it. The finalizers are patched with
+// the resourceVersion of tr, as the generated reconcilers do, so that the finalizers other
+// controllers set in the meantime aren't overwritten.
+func (r *Reconciler) setFinalizer(ctx context.Context, tr *v1beta1.TaskRun, set bool) error {
+	if hasFinalizer(tr) == set {
+		return nil
+	}
+	finalizers := sets.NewString(tr.Finalizers...)
+	if set {
+		finalizers.Insert(finalizerName)
+	} else {
+		finalizers.Delete(finalizerName)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers.List(),
+			"resourceVersion": tr.ResourceVersion,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := r.Pipelineclientset.TektonV1beta1().TaskRuns(tr.Namespace).Patch(ctx, tr.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return errors.Wrapf(err, "updating the finalizers of taskrun %s/%s", tr.Namespace, tr.Name)
+	}
+	return nil
+}
+
+// generatedReconciler is the reconciler generated for TaskRuns.
+type generatedReconciler interface {
+	controller.Reconciler
+	pkgreconciler.LeaderAware
+	leader
+}
+
+// finalizingReconciler hands the TaskRuns being deleted that have the chains finalizer to the
+// Reconciler. The generated reconciler only finalizes TaskRuns for reconcilers that have it add
+// the finalizer to every TaskRun, when Chains only adds it to the TaskRuns waiting to be signed.
+type finalizingReconciler struct {
+	generatedReconciler
+	r           *Reconciler
+	lister      listers.TaskRunLister
+	configStore pkgreconciler.ConfigStore
+}
+
+// Reconcile implements controller.Reconciler.
+func (f *finalizingReconciler) Reconcile(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return f.generatedReconciler.Reconcile(ctx, key)
+	}
+	tr, err := f.lister.TaskRuns(namespace).Get(name)
+	if err != nil || tr.GetDeletionTimestamp().IsZero() || !hasFinalizer(tr) || !f.IsLeaderFor(types.NamespacedName{Namespace: namespace, Name: name}) {
+		return f.generatedReconciler.Reconcile(ctx, key)
+	}
+	return f.r.ReconcileKind(f.configStore.ToContext(ctx), tr.DeepCopy())
+}
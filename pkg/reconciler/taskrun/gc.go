@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	signing "github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/chains/storage/tekton"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/chains/pkg/patch"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+)
+
+// storedAnnotationPrefixes are the prefixes of the annotations written by the tekton storage backend.
+var storedAnnotationPrefixes = []string{
+	fmt.Sprintf(tekton.PayloadAnnotationFormat, ""),
+	fmt.Sprintf(tekton.SignatureAnnotationFormat, ""),
+	fmt.Sprintf(tekton.CertAnnotationsFormat, ""),
+	fmt.Sprintf(tekton.ChainAnnotationFormat, ""),
+	fmt.Sprintf(tekton.BundleAnnotationFormat, ""),
+}
+
+// pendingUploadsRecheck is how long garbage collection waits for the queued transparency log
+// uploads of a TaskRun, which read its payloads and signatures back from the annotations.
+var pendingUploadsRecheck = time.Minute
+
+// collectGarbage removes the payloads and signatures stored in the annotations of a
+// reconciled TaskRun once the configured retention period has passed. Until then the
+// TaskRun is requeued for when it expires, and while transparency log uploads are pending.
+// The signing state annotations are kept, so the TaskRun isn't signed again.
+func (r *Reconciler) collectGarbage(ctx context.Context, cfg config.GCConfig, tr *v1beta1.TaskRun) error {
+	if cfg.Retention <= 0 || !tr.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+	keys := storedAnnotations(tr)
+	if len(keys) == 0 {
+		return nil
+	}
+	if remaining := cfg.Retention - time.Since(reconciledAt(tr)); remaining > 0 {
+		return controller.NewRequeueAfter(remaining)
+	}
+	// Pending uploads that can't be decoded are left for the queue to report.
+	if pending, err := signing.PendingUploads(tr); err != nil || len(pending) > 0 {
+		logging.FromContext(ctx).Infof("taskrun %s/%s has pending transparency log uploads, keeping its stored annotations", tr.Namespace, tr.Name)
+		return controller.NewRequeueAfter(pendingUploadsRecheck)
+	}
+
+	logging.FromContext(ctx).Infof("removing %d stored annotations from taskrun %s/%s", len(keys), tr.Namespace, tr.Name)
+	patchBytes, err := patch.GetAnnotationsRemovalPatch(nil, keys)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Pipelineclientset.TektonV1beta1().TaskRuns(tr.Namespace).Patch(
+		ctx, tr.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{}); err != nil {
+		return errors.Wrap(err, "removing stored annotations")
+	}
+	return nil
+}
+
+func storedAnnotations(tr *v1beta1.TaskRun) []string {
+	keys := []string{}
+	for k := range tr.Annotations {
+		for _, prefix := range storedAnnotationPrefixes {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reconciledAt returns when the TaskRun last changed signing state, falling back
+// to when it completed.
+func reconciledAt(tr *v1beta1.TaskRun) time.Time {
+	if t, err := time.Parse(time.RFC3339, tr.Annotations[signing.StateTimeAnnotation]); err == nil {
+		return t
+	}
+	if tr.Status.CompletionTime != nil {
+		return tr.Status.CompletionTime.Time
+	}
+	return tr.CreationTimestamp.Time
+}
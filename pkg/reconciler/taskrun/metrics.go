@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"time"
+
+	signing "github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/metrics"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/logging"
+)
+
+// unsignedReportPeriod is how often the number of unsigned TaskRuns is reported.
+var unsignedReportPeriod = 30 * time.Second
+
+// reportUnsignedTaskRuns periodically records the number of completed TaskRuns
+// that haven't been signed yet, until the context is cancelled.
+func reportUnsignedTaskRuns(ctx context.Context, lister listers.TaskRunLister, shard shard) {
+	logger := logging.FromContext(ctx)
+	ticker := time.NewTicker(unsignedReportPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := countUnsignedTaskRuns(lister, shard)
+			if err != nil {
+				logger.Warnf("error counting unsigned taskruns: %v", err)
+				continue
+			}
+			metrics.RecordUnsignedTaskRuns(ctx, count)
+		}
+	}
+}
+
+func countUnsignedTaskRuns(lister listers.TaskRunLister, shard shard) (int, error) {
+	trs, err := lister.List(labels.Everything())
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, tr := range trs {
+		if tr.IsDone() && !signing.Reconciled(tr) && shard(tr) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/config"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewNamespaceConfigLister returns a lister of the chains-config ConfigMaps of the namespaces,
+// which are watched until ctx is done. Only the ConfigMaps named chains-config with the
+// config.OverridesLabel are listed, so the controller doesn't keep every ConfigMap in the
+// cluster, and doesn't apply overrides the webhook hasn't validated. It returns once they're
+// listed.
+func NewNamespaceConfigLister(ctx context.Context, kubeClient kubernetes.Interface) (corev1listers.ConfigMapLister, error) {
+	informer := corev1informers.NewFilteredConfigMapInformer(kubeClient, metav1.NamespaceAll, 0,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", config.ChainsConfig).String()
+			opts.LabelSelector = labels.Set{config.OverridesLabel: "true"}.String()
+		})
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return nil, errors.Errorf("listing the %s ConfigMaps of the namespaces", config.ChainsConfig)
+	}
+	return corev1listers.NewConfigMapLister(informer.GetIndexer()), nil
+}
+
+// withNamespaceConfig returns a context carrying the chains config for TaskRuns in
+// namespace: the cluster config with the overrides from a chains-config ConfigMap
+// in that namespace applied, if there is one.
+func (r *Reconciler) withNamespaceConfig(ctx context.Context, namespace string) (context.Context, error) {
+	if r.NamespaceConfigs == nil {
+		return ctx, nil
+	}
+	cm, err := r.NamespaceConfigs.ConfigMaps(namespace).Get(config.ChainsConfig)
+	if k8serrors.IsNotFound(err) {
+		return ctx, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting %s in namespace %s", config.ChainsConfig, namespace)
+	}
+	cfg, err := config.FromContext(ctx).WithNamespaceOverrides(namespace, cm.Data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s in namespace %s", config.ChainsConfig, namespace)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "validating %s in namespace %s", config.ChainsConfig, namespace)
+	}
+	return config.ToContext(ctx, cfg), nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/config"
+	"golang.org/x/time/rate"
+)
+
+// namespaceQuota limits the rate the TaskRuns of each namespace are signed at, so that a
+// namespace completing many TaskRuns doesn't hold up the others.
+type namespaceQuota struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newNamespaceQuota() *namespaceQuota {
+	return &namespaceQuota{limiters: map[string]*rate.Limiter{}}
+}
+
+// take takes the turn of a TaskRun in namespace. It returns 0 when the TaskRun can be signed
+// now, or how long it has to wait otherwise, in which case the turn isn't taken. A nil quota
+// never waits.
+func (q *namespaceQuota) take(namespace string, cfg config.NamespaceConfig) time.Duration {
+	if q == nil || cfg.QuotaRate <= 0 {
+		return 0
+	}
+	limit := rate.Limit(float64(cfg.QuotaRate) / time.Minute.Seconds())
+	burst := cfg.QuotaBurst
+	if burst <= 0 {
+		burst = cfg.QuotaRate
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	l, ok := q.limiters[namespace]
+	if !ok {
+		l = rate.NewLimiter(limit, burst)
+		q.limiters[namespace] = l
+	}
+	// The quota follows the configuration.
+	if l.Limit() != limit {
+		l.SetLimit(limit)
+	}
+	if l.Burst() != burst {
+		l.SetBurst(burst)
+	}
+	r := l.Reserve()
+	if d := r.Delay(); d > 0 {
+		r.Cancel()
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/config"
+)
+
+func TestNamespaceQuota(t *testing.T) {
+	q := newNamespaceQuota()
+	cfg := config.NamespaceConfig{QuotaRate: 1, QuotaBurst: 2}
+
+	for i := 0; i < 2; i++ {
+		if wait := q.take("noisy", cfg); wait != 0 {
+			t.Fatalf("take() = %s, want the burst to be signed right away", wait)
+		}
+	}
+	// The TaskRuns that wait don't take turns, so they all wait for the next one.
+	for i := 0; i < 3; i++ {
+		if wait := q.take("noisy", cfg); wait <= 0 || wait > time.Minute {
+			t.Errorf("take() = %s, want a TaskRun over the quota to wait for the next turn", wait)
+		}
+	}
+	if wait := q.take("quiet", cfg); wait != 0 {
+		t.Errorf("take() = %s, want other namespaces not to wait", wait)
+	}
+
+	// Removing the quota lets every TaskRun through.
+	if wait := q.take("noisy", config.NamespaceConfig{}); wait != 0 {
+		t.Errorf("take() = %s, want no wait without a quota", wait)
+	}
+	var none *namespaceQuota
+	if wait := none.take("noisy", cfg); wait != 0 {
+		t.Errorf("take() = %s, want no wait without a quota", wait)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/config"
+	"go.uber.org/zap"
+	"knative.dev/pkg/logging"
+)
+
+// ReadinessPath is the path of the readiness endpoint, which reports the controller ready once
+// the signers of its configuration passed their probe.
+const ReadinessPath = "/readyz"
+
+// ReadinessPort is the port the readiness endpoint is served on. It isn't served when 0.
+var ReadinessPort = 0
+
+// probeTimeout bounds the probe of the signers of a configuration.
+const probeTimeout = time.Minute
+
+// probeSigners is set for testing.
+var probeSigners = chains.ProbeSigners
+
+// Readiness is the response of the readiness endpoint.
+type Readiness struct {
+	Ready bool `json:"ready"`
+	// Signers are the probes of the signers of the configuration in use. They're empty until
+	// the first one is done, and when signers.probe is none.
+	Signers []chains.SignerProbe `json:"signers"`
+}
+
+// readiness holds the probes of the signers of the last configuration.
+var readiness = &signerReadiness{}
+
+type signerReadiness struct {
+	mu sync.Mutex
+	// generation counts the configurations probed, so the probes of one that was replaced
+	// while they ran are dropped.
+	generation int
+	probed     bool
+	probes     []chains.SignerProbe
+}
+
+// watch returns the onAfterStore of the config store, which probes the signers of each new
+// configuration in the background, since KMSs can take a while to answer. The probes of the
+// last configuration are kept until those of the new one are done.
+func (r *signerReadiness) watch(ctx context.Context, secretPath string) func(string, interface{}) {
+	return func(_ string, value interface{}) {
+		cfg, ok := value.(*config.Config)
+		if !ok {
+			return
+		}
+		go r.probe(ctx, r.next(), secretPath, *cfg)
+	}
+}
+
+// next returns the generation of a new configuration.
+func (r *signerReadiness) next() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	return r.generation
+}
+
+// probe probes the signers of cfg, the configuration of generation, and keeps the probes unless
+// a newer configuration was stored in the meantime.
+func (r *signerReadiness) probe(ctx context.Context, generation int, secretPath string, cfg config.Config) {
+	logger := logging.FromContext(ctx)
+	probeCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	probes := probeSigners(probeCtx, secretPath, cfg, logger)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if generation != r.generation {
+		return
+	}
+	r.probed, r.probes = true, probes
+	for _, p := range probes {
+		if !p.Ready {
+			logger.Errorw("Signer probe failed, the controller isn't ready", zap.String("signer", p.Signer),
+				zap.String("reason", p.Reason), zap.String("hint", p.Hint), zap.String("error", p.Error))
+		}
+	}
+}
+
+func (r *signerReadiness) get() Readiness {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ready := Readiness{Ready: r.probed, Signers: r.probes}
+	for _, p := range r.probes {
+		ready.Ready = ready.Ready && p.Ready
+	}
+	if ready.Signers == nil {
+		ready.Signers = []chains.SignerProbe{}
+	}
+	return ready
+}
+
+// ServeHTTP reports the probes of the signers as JSON, with a 503 while they haven't all passed.
+func (r *signerReadiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ready := r.get()
+	w.Header().Set("Content-Type", "application/json")
+	if !ready.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(ready)
+}
+
+// serveReadiness serves the readiness endpoint until ctx is done.
+func serveReadiness(ctx context.Context, port int) {
+	logger := logging.FromContext(ctx)
+	mux := http.NewServeMux()
+	mux.Handle(ReadinessPath, readiness)
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Errorf("Error shutting down the readiness endpoint: %v", err)
+		}
+	}()
+	go func() {
+		logger.Infof("Serving the readiness endpoint on %s%s", srv.Addr, ReadinessPath)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Errorw("Error serving the readiness endpoint", zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/config"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/util/wait"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestSignerReadiness(t *testing.T) {
+	ctx := logtesting.TestContextWithLogger(t)
+	failed := []chains.SignerProbe{{Signer: "kms", Reason: chains.ProbeReasonPermission, Error: "denied"}}
+	passed := []chains.SignerProbe{{Signer: "kms", Ready: true}}
+	defer func(f func(context.Context, string, config.Config, *zap.SugaredLogger) []chains.SignerProbe) {
+		probeSigners = f
+	}(probeSigners)
+	probeSigners = func(_ context.Context, _ string, cfg config.Config, _ *zap.SugaredLogger) []chains.SignerProbe {
+		if cfg.Signers.KMS.KMSRef == "denied" {
+			return failed
+		}
+		return passed
+	}
+	configWith := func(ref string) config.Config {
+		return config.Config{Signers: config.SignerConfigs{KMS: config.KMSSigner{KMSRef: ref}}}
+	}
+	r := &signerReadiness{}
+	get := func() (int, Readiness) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+		got := Readiness{}
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, got
+	}
+	if code, got := get(); code != http.StatusServiceUnavailable || got.Ready {
+		t.Errorf("before the first probe: %d %+v, want not ready", code, got)
+	}
+
+	r.probe(ctx, r.next(), SecretPath, configWith("denied"))
+	if code, got := get(); code != http.StatusServiceUnavailable || got.Ready || len(got.Signers) != 1 || got.Signers[0].Reason != chains.ProbeReasonPermission {
+		t.Errorf("after a failed probe: %d %+v, want not ready with the failure", code, got)
+	}
+
+	// The probes of a configuration replaced while they ran are dropped.
+	replaced := r.next()
+	r.probe(ctx, r.next(), SecretPath, configWith("allowed"))
+	if code, got := get(); code != http.StatusOK || !got.Ready {
+		t.Errorf("after a passing probe: %d %+v, want ready", code, got)
+	}
+	r.probe(ctx, replaced, SecretPath, configWith("denied"))
+	if code, got := get(); code != http.StatusOK || !got.Ready {
+		t.Errorf("after the probe of a replaced configuration failed: %d %+v, want ready", code, got)
+	}
+
+	// The configurations the store keeps are probed in the background.
+	update := r.watch(ctx, SecretPath)
+	update("other", "not a config")
+	cfg := configWith("denied")
+	update(config.ChainsConfig, &cfg)
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		code, _ := get()
+		return code == http.StatusServiceUnavailable, nil
+	}); err != nil {
+		t.Errorf("the probe of a new configuration didn't fail readiness: %v", err)
+	}
+}
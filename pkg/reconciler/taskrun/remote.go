@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/reconciler/chainsconfig"
+	"go.uber.org/zap"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection"
+	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/leaderelection"
+	"knative.dev/pkg/logging"
+)
+
+// LoadRemoteClusters reads a kubeconfig for each remote cluster from dir, which is
+// typically a mounted Secret. Each file is one cluster, named after the file.
+func LoadRemoteClusters(dir string) (map[string]*rest.Config, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading remote clusters from %s", dir)
+	}
+	clusters := map[string]*rest.Config{}
+	for _, f := range files {
+		// Skip directories and the hidden files that Secret volumes are made of.
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		cfg, err := clientcmd.BuildConfigFromFlags("", filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "loading kubeconfig for cluster %s", f.Name())
+		}
+		clusters[f.Name()] = cfg
+	}
+	return clusters, nil
+}
+
+// NewRemoteController returns a constructor for a controller that signs the TaskRuns
+// in a remote cluster. The chains config, signing keys and leader election leases
+// all come from the local cluster; the TaskRuns, and the Kubernetes objects looked
+// up while signing them, come from the remote one.
+func NewRemoteController(name string, cfg *rest.Config, useFinalizer bool) injection.ControllerConstructor {
+	return func(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+		logger := logging.FromContext(ctx).With("cluster", name)
+		ctx = logging.WithLogger(ctx, logger)
+
+		if leaderelection.HasLeaderElection(ctx) {
+			// Leases are named after the component, so each cluster needs its own.
+			leConfig, err := sharedmain.GetLeaderElectionConfig(ctx)
+			if err != nil {
+				logger.Fatalw("Error loading leader election configuration", zap.Error(err))
+			}
+			ctx = leaderelection.WithDynamicLeaderElectorBuilder(ctx, kubeclient.Get(ctx),
+				leConfig.GetComponentConfig("watcher-"+name))
+		}
+
+		// Watch the ChainsConfig with the clients of the local cluster.
+		cmw = chainsconfig.WatcherFor(ctx, cmw, SecretPath)
+		ctx, informers := injection.Default.SetupInformers(ctx, cfg)
+		go func() {
+			if err := controller.StartInformers(ctx.Done(), informers...); err != nil {
+				logger.Errorw("Failed to start informers", zap.Error(err))
+			}
+		}()
+		return newController(ctx, cmw, useFinalizer, name)
+	}
+}
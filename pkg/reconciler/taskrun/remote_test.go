@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: build
+  cluster:
+    server: https://build.example.com:6443
+contexts:
+- name: build
+  context:
+    cluster: build
+    user: chains
+current-context: build
+users:
+- name: chains
+  user:
+    token: abc123
+`
+
+func TestLoadRemoteClusters(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "build-1"), []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Secret volumes contain hidden data directories and symlinks.
+	if err := os.Mkdir(filepath.Join(dir, "..data"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	clusters, err := LoadRemoteClusters(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("LoadRemoteClusters() got %d clusters, wanted 1", len(clusters))
+	}
+	cfg, ok := clusters["build-1"]
+	if !ok {
+		t.Fatalf("LoadRemoteClusters() = %v, wanted cluster build-1", clusters)
+	}
+	if cfg.Host != "https://build.example.com:6443" || cfg.BearerToken != "abc123" {
+		t.Errorf("unexpected config for build-1: host %s", cfg.Host)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken"), []byte("not: [a kubeconfig"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRemoteClusters(dir); err == nil {
+		t.Error("expected an error for an invalid kubeconfig")
+	}
+}
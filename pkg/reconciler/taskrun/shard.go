@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/leaderelection"
+)
+
+// statefulSetOrdinalEnv is set to the name of the pod when the controller is run as a
+// StatefulSet, whose ordinal is the bucket of TaskRuns the replica reconciles.
+const statefulSetOrdinalEnv = "STATEFUL_CONTROLLER_ORDINAL"
+
+// shard reports whether a TaskRun is in the buckets this replica of the controller leads. With
+// more than one bucket in config-leader-election, each replica only reconciles the TaskRuns of
+// its buckets, so the work done besides reconciling, like reporting the unsigned TaskRuns, is
+// limited to them too, so that the replicas don't each count every TaskRun.
+type shard func(tr *v1beta1.TaskRun) bool
+
+// wholeShard has every TaskRun.
+func wholeShard(*v1beta1.TaskRun) bool {
+	return true
+}
+
+// leader is the reconciler generated for TaskRuns, which knows the buckets it leads.
+type leader interface {
+	IsLeaderFor(key types.NamespacedName) bool
+}
+
+// shardOf returns the shard of the replica that runs impl. A replica that doesn't lead any
+// bucket yet has no TaskRuns.
+func shardOf(impl *controller.Impl) shard {
+	l, ok := impl.Reconciler.(leader)
+	if !ok {
+		return wholeShard
+	}
+	return func(tr *v1beta1.TaskRun) bool {
+		return l.IsLeaderFor(types.NamespacedName{Namespace: tr.Namespace, Name: tr.Name})
+	}
+}
+
+// checkStatefulSetShard fails when the controller is run as a StatefulSet but its ordinal has no
+// bucket, because there are fewer buckets than replicas. Leader election would otherwise fall
+// back to competing for the leases of the buckets, which the other replicas don't take, and
+// the replica would reconcile every TaskRun alongside them.
+func checkStatefulSetShard(ctx context.Context) error {
+	if os.Getenv(statefulSetOrdinalEnv) == "" || !leaderelection.HasLeaderElection(ctx) {
+		return nil
+	}
+	cfg, err := sharedmain.GetLeaderElectionConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "loading the leader election configuration")
+	}
+	if _, _, err := leaderelection.NewStatefulSetBucketAndSet(int(cfg.Buckets)); err != nil {
+		return errors.Wrapf(err, "%s is set but the replica has no bucket, set buckets in %s to the number of replicas", statefulSetOrdinalEnv, leaderelection.ConfigMapName())
+	}
+	return nil
+}
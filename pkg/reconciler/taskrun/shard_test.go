@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/hash"
+	"knative.dev/pkg/leaderelection"
+	pkgreconciler "knative.dev/pkg/reconciler"
+	rtesting "knative.dev/pkg/reconciler/testing"
+	"knative.dev/pkg/system"
+)
+
+// bucketReconciler leads the buckets it's promoted to, like the generated reconcilers.
+type bucketReconciler struct {
+	pkgreconciler.LeaderAwareFuncs
+}
+
+func (*bucketReconciler) Reconcile(context.Context, string) error {
+	return nil
+}
+
+func TestShardOf(t *testing.T) {
+	r := &bucketReconciler{}
+	s := shardOf(&controller.Impl{Reconciler: r})
+	trs := []*v1beta1.TaskRun{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		trs = append(trs, &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}})
+	}
+	for _, tr := range trs {
+		if s(tr) {
+			t.Errorf("%s is in the shard of a replica that doesn't lead any bucket", tr.Name)
+		}
+	}
+
+	buckets := hash.NewBucketSet(sets.NewString("bucket-0", "bucket-1")).Buckets()
+	if err := r.Promote(buckets[0], func(pkgreconciler.Bucket, types.NamespacedName) {}); err != nil {
+		t.Fatal(err)
+	}
+	owned := 0
+	for _, tr := range trs {
+		if s(tr) != buckets[0].Has(types.NamespacedName{Namespace: tr.Namespace, Name: tr.Name}) {
+			t.Errorf("%s is in the shard: %v, want it there if its bucket is led", tr.Name, s(tr))
+		}
+		if s(tr) {
+			owned++
+		}
+	}
+	if owned == 0 || owned == len(trs) {
+		t.Errorf("the shard has %d of %d TaskRuns, want some of them", owned, len(trs))
+	}
+
+	if !shardOf(&controller.Impl{})(trs[0]) {
+		t.Error("expected a controller without buckets to have every TaskRun")
+	}
+}
+
+func TestCheckStatefulSetShard(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	kc := fakekubeclient.Get(ctx)
+	if _, err := kc.CoreV1().ConfigMaps(system.Namespace()).Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: leaderelection.ConfigMapName(), Namespace: system.Namespace()},
+		Data:       map[string]string{"buckets": "2"},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{statefulSetOrdinalEnv, "STATEFUL_SERVICE_NAME"} {
+		defer os.Setenv(env, os.Getenv(env))
+	}
+	os.Setenv("STATEFUL_SERVICE_NAME", "tekton-chains-controller")
+
+	tests := []struct {
+		name    string
+		ordinal string
+		ha      bool
+		wantErr bool
+	}{
+		{name: "deployment", ha: true},
+		{name: "ordinal with a bucket", ordinal: "tekton-chains-controller-1", ha: true},
+		{name: "ordinal without a bucket", ordinal: "tekton-chains-controller-2", ha: true, wantErr: true},
+		{name: "without leader election", ordinal: "tekton-chains-controller-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv(statefulSetOrdinalEnv, tt.ordinal)
+			ctx := ctx
+			if tt.ha {
+				ctx = leaderelection.WithStandardLeaderElectorBuilder(ctx, kc, leaderelection.ComponentConfig{Component: "watcher", Buckets: 2})
+			}
+			if err := checkStatefulSetShard(ctx); (err != nil) != tt.wantErr {
+				t.Errorf("checkStatefulSetShard() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
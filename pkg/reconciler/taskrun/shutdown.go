@@ -0,0 +1,80 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ShutdownGracePeriod is how long TaskRuns that are being signed when the controller
+// is stopped are given to finish. After that their signing is cancelled, and the
+// failure is recorded on the TaskRun so it is retried.
+var ShutdownGracePeriod = 20 * time.Second
+
+// recordTimeout is how long Drain waits past the grace period for cancelled
+// signings to be recorded.
+const recordTimeout = 5 * time.Second
+
+// inflight tracks the TaskRuns that are being signed.
+var inflight sync.WaitGroup
+
+// Drain waits for the TaskRuns that are being signed to finish, for at most the
+// grace period plus the time needed to record the ones that were cancelled.
+// It returns false if they didn't finish in time.
+func Drain() bool {
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(ShutdownGracePeriod + recordTimeout):
+		return false
+	}
+}
+
+// withGracePeriod returns a context with the values of ctx that is only cancelled
+// grace after ctx is, so that work in progress isn't dropped when the controller stops.
+func withGracePeriod(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	gctx, cancel := context.WithCancel(detached{ctx})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-gctx.Done():
+			return
+		}
+		t := time.NewTimer(grace)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			cancel()
+		case <-gctx.Done():
+		}
+	}()
+	return gctx, cancel
+}
+
+// detached carries the values of its parent but not its deadline or cancellation.
+type detached struct {
+	parent context.Context
+}
+
+func (d detached) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (d detached) Done() <-chan struct{}             { return nil }
+func (d detached) Err() error                        { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
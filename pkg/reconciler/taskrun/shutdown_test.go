@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package taskrun
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type testKey struct{}
+
+func TestWithGracePeriod(t *testing.T) {
+	parent, stop := context.WithCancel(context.WithValue(context.Background(), testKey{}, "value"))
+	ctx, cancel := withGracePeriod(parent, 100*time.Millisecond)
+	defer cancel()
+
+	if ctx.Value(testKey{}) != "value" {
+		t.Error("expected values of the parent context to be kept")
+	}
+
+	stop()
+	select {
+	case <-ctx.Done():
+		t.Fatal("context was cancelled before the grace period")
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context wasn't cancelled after the grace period")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	defer func(d time.Duration) { ShutdownGracePeriod = d }(ShutdownGracePeriod)
+	ShutdownGracePeriod = 0
+
+	if !Drain() {
+		t.Error("Drain() = false with nothing in flight")
+	}
+
+	inflight.Add(1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		inflight.Done()
+	}()
+	if !Drain() {
+		t.Error("Drain() = false, wanted in-flight signing to finish")
+	}
+}
@@ -16,9 +16,18 @@ package taskrun
 import (
 	"context"
 
+	"github.com/pkg/errors"
+
 	signing "github.com/tektoncd/chains/pkg/chains"
+	"github.com/tektoncd/chains/pkg/config"
+	"github.com/tektoncd/chains/pkg/metrics"
 	"github.com/tektoncd/pipeline/pkg/apis/pipeline/v1beta1"
+	versioned "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
 	taskrunreconciler "github.com/tektoncd/pipeline/pkg/client/injection/reconciler/pipeline/v1beta1/taskrun"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	pkgreconciler "knative.dev/pkg/reconciler"
 )
@@ -29,37 +38,181 @@ const (
 )
 
 type Reconciler struct {
-	TaskRunSigner signing.Signer
+	TaskRunSigner     signing.Signer
+	KubeClient        kubernetes.Interface
+	Pipelineclientset versioned.Interface
+	// FetchBeforeSigning gets the full TaskRun before signing it, for when cached TaskRuns are stripped.
+	FetchBeforeSigning bool
+	// UseFinalizer adds the chains finalizer to the completed TaskRuns until they're signed, so
+	// they can't be deleted before. Without it, the finalizer is removed from the TaskRuns that
+	// have it.
+	UseFinalizer bool
+	// TlogQueue is the queue of the TaskRunSigner's asynchronous transparency log uploads, if it has one.
+	TlogQueue *signing.TlogQueue
+	// NamespaceConfigs lists the chains-config ConfigMaps whose overrides apply to the TaskRuns
+	// of their namespaces. Without it, every TaskRun is signed with the cluster config.
+	NamespaceConfigs corev1listers.ConfigMapLister
+	// Namespaces lists the namespaces namespaces.selector is matched against. Without it, the
+	// namespaces are read from the API server.
+	Namespaces corev1listers.NamespaceLister
+	// Cluster is the name of the remote cluster the TaskRuns are in, or empty for the local one.
+	Cluster string
+	// quota limits the rate the TaskRuns of each namespace are signed at, when
+	// namespaces.quota.rate is set.
+	quota *namespaceQuota
+	// backlog holds back the TaskRuns that completed long ago, so the recent ones are signed
+	// first. Without one, TaskRuns are signed in the order they're reconciled.
+	backlog *backlog
+}
+
+// uploadResumer is a Signer that can queue the transparency log uploads a TaskRun lost again.
+type uploadResumer interface {
+	ResumeUploads(ctx context.Context, tr *v1beta1.TaskRun) error
 }
 
-// Check that our Reconciler implements taskrunreconciler.Interface and taskrunreconciler.Finalizer
+// Check that our Reconciler implements taskrunreconciler.Interface. It doesn't implement
+// taskrunreconciler.Finalizer, which would have the generated reconciler add the finalizer to
+// every TaskRun: it's added to the completed TaskRuns until they're signed, and the TaskRuns
+// being deleted with it are handed over by finalizingReconciler.
 var _ taskrunreconciler.Interface = (*Reconciler)(nil)
-var _ taskrunreconciler.Finalizer = (*Reconciler)(nil)
 
 // ReconcileKind  handles a changed or created TaskRun.
 // This is the main entrypoint for chains business logic.
 func (r *Reconciler) ReconcileKind(ctx context.Context, tr *v1beta1.TaskRun) pkgreconciler.Event {
-	return r.FinalizeKind(ctx, tr)
-}
-
-// FinalizeKind implements taskrunreconciler.Finalizer
-// We utilize finalizers to ensure that we get a crack at signing every taskrun
-// that we see flowing through the system.  If we don't add a finalizer, it could
-// get cleaned up before we see the final state and sign it.
-func (r *Reconciler) FinalizeKind(ctx context.Context, tr *v1beta1.TaskRun) pkgreconciler.Event {
+	ctx = metrics.WithCluster(ctx, r.Cluster)
+	// TaskRuns that are deleted before they complete aren't signed. Nor are those being deleted
+	// when the finalizer is disabled, which only have it from an earlier configuration.
+	if !tr.GetDeletionTimestamp().IsZero() && (!tr.IsDone() || !r.UseFinalizer) {
+		return r.setFinalizer(ctx, tr, false)
+	}
 	// Check to make sure the TaskRun is finished.
 	if !tr.IsDone() {
 		logging.FromContext(ctx).Infof("taskrun %s/%s is still running", tr.Namespace, tr.Name)
-		return nil
+		return r.markPending(ctx, tr)
+	}
+	// A re-sign request resets the signing state; the resulting update is reconciled again.
+	if signing.ResignRequested(tr) {
+		logging.FromContext(ctx).Infof("taskrun %s/%s asked to be re-signed", tr.Namespace, tr.Name)
+		return signing.PrepareResign(tr, r.Pipelineclientset)
+	}
+	// Uploads queued before the controller was restarted are gone. They're queued again from the
+	// payloads and signatures that were stored, and the TaskRun is only signed again when those
+	// can't be read back.
+	if signing.Reconciled(tr) && r.TlogQueue.Lost(tr) {
+		resumer, ok := r.TaskRunSigner.(uploadResumer)
+		if !ok {
+			logging.FromContext(ctx).Warnf("taskrun %s/%s lost its queued transparency log uploads, signing it again", tr.Namespace, tr.Name)
+			return signing.RequestResign(tr, r.Pipelineclientset)
+		}
+		err := resumer.ResumeUploads(ctx, tr)
+		switch {
+		case errors.Is(err, signing.ErrUploadsLost):
+			logging.FromContext(ctx).Warnf("taskrun %s/%s lost its queued transparency log uploads, signing it again: %v", tr.Namespace, tr.Name, err)
+			return signing.RequestResign(tr, r.Pipelineclientset)
+		case err != nil:
+			return errors.Wrapf(err, "queueing the transparency log uploads of taskrun %s/%s again", tr.Namespace, tr.Name)
+		}
+		logging.FromContext(ctx).Infof("taskrun %s/%s queued its lost transparency log uploads again", tr.Namespace, tr.Name)
 	}
 	// Check to see if it has already been signed.
 	if signing.Reconciled(tr) {
 		logging.FromContext(ctx).Infof("taskrun %s/%s has been reconciled", tr.Namespace, tr.Name)
+		if err := r.setFinalizer(ctx, tr, false); err != nil {
+			return err
+		}
+		return r.collectGarbage(ctx, config.FromContext(ctx).GC, tr)
+	}
+
+	if signing.SkipRequested(tr) {
+		logging.FromContext(ctx).Infof("taskrun %s/%s asked to be skipped", tr.Namespace, tr.Name)
+		return signing.MarkSkipped(tr, r.Pipelineclientset, nil)
+	}
+
+	if sign, err := r.shouldSign(ctx, tr); err != nil || !sign {
+		if err != nil {
+			return err
+		}
+		return r.setFinalizer(ctx, tr, false)
+	}
+	// The finalizer is removed once the TaskRun is marked as signed, failed or skipped.
+	if r.UseFinalizer && tr.GetDeletionTimestamp().IsZero() {
+		if err := r.setFinalizer(ctx, tr, true); err != nil {
+			return err
+		}
+	}
+
+	// The TaskRuns that completed long ago are signed once the backlog lets them out.
+	release, ok := r.backlog.admit(tr)
+	if !ok {
+		logging.FromContext(ctx).Debugf("taskrun %s/%s is in the backlog, signing the recent ones first", tr.Namespace, tr.Name)
 		return nil
 	}
+	signed := false
+	defer func() { release(signed) }()
+
+	// The TaskRuns over the quota of their namespace are put back in the queue until their
+	// turn, without counting as a retry.
+	if wait := r.quota.take(tr.Namespace, config.FromContext(ctx).Namespaces); wait > 0 {
+		logging.FromContext(ctx).Infof("taskrun %s/%s is over the signing quota of its namespace, waiting %s", tr.Namespace, tr.Name, wait)
+		metrics.RecordThrottled(ctx, tr.Namespace)
+		return controller.NewRequeueAfter(wait)
+	}
 
+	ctx, err := r.withNamespaceConfig(ctx, tr.Namespace)
+	if err != nil {
+		return err
+	}
+
+	if r.FetchBeforeSigning {
+		full, err := r.Pipelineclientset.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "getting taskrun %s/%s", tr.Namespace, tr.Name)
+		}
+		tr = full
+	}
+
+	// Let signing that has started finish when the controller is stopped.
+	inflight.Add(1)
+	defer inflight.Done()
+	defer signings.start(r.Cluster, tr)()
+	ctx, cancel := withGracePeriod(ctx, ShutdownGracePeriod)
+	defer cancel()
 	if err := r.TaskRunSigner.SignTaskRun(ctx, tr); err != nil {
 		return err
 	}
+	signed = true
 	return nil
 }
+
+// markPending records that a running TaskRun will be signed once it completes.
+func (r *Reconciler) markPending(ctx context.Context, tr *v1beta1.TaskRun) error {
+	if signing.State(tr) != "" || signing.SkipRequested(tr) {
+		return nil
+	}
+	if sign, err := r.shouldSign(ctx, tr); err != nil || !sign {
+		return err
+	}
+	return signing.MarkPending(tr, r.Pipelineclientset)
+}
+
+// shouldSign applies the configured namespace and TaskRun filters.
+func (r *Reconciler) shouldSign(ctx context.Context, tr *v1beta1.TaskRun) (bool, error) {
+	cfg := config.FromContext(ctx)
+	allowed, err := r.namespaceAllowed(ctx, cfg.Namespaces, tr.Namespace)
+	if err != nil {
+		return false, err
+	}
+	if !allowed {
+		logging.FromContext(ctx).Infof("taskrun %s/%s is in a namespace that is not signed", tr.Namespace, tr.Name)
+		return false, nil
+	}
+	selected, err := taskRunSelected(cfg.Filters, tr)
+	if err != nil {
+		return false, err
+	}
+	if !selected {
+		logging.FromContext(ctx).Infof("taskrun %s/%s does not match the configured filters", tr.Namespace, tr.Name)
+		return false, nil
+	}
+	return true, nil
+}
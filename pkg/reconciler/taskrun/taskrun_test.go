@@ -15,7 +15,9 @@ package taskrun
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	signing "github.com/tektoncd/chains/pkg/chains"
 	"github.com/tektoncd/chains/pkg/config"
@@ -23,13 +25,18 @@ import (
 	informers "github.com/tektoncd/pipeline/pkg/client/informers/externalversions/pipeline/v1beta1"
 	fakepipelineclient "github.com/tektoncd/pipeline/pkg/client/injection/client/fake"
 	faketaskruninformer "github.com/tektoncd/pipeline/pkg/client/injection/informers/pipeline/v1beta1/taskrun/fake"
+	listers "github.com/tektoncd/pipeline/pkg/client/listers/pipeline/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	fakek8s "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 	"knative.dev/pkg/apis"
 	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
-	_ "knative.dev/pkg/client/injection/kube/client/fake"
+	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	logtesting "knative.dev/pkg/logging/testing"
 	pkgreconciler "knative.dev/pkg/reconciler"
 	rtesting "knative.dev/pkg/reconciler/testing"
 	"knative.dev/pkg/system"
@@ -86,6 +93,98 @@ func TestReconciler_Reconcile(t *testing.T) {
 	}
 }
 
+func TestNewController_finalizer(t *testing.T) {
+	completed := duckv1beta1.Status{Conditions: []apis.Condition{{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue}}}
+	now := metav1.Now()
+	tests := []struct {
+		name          string
+		ctor          func(context.Context, configmap.Watcher) *controller.Impl
+		tr            *v1beta1.TaskRun
+		wantFinalizer bool
+	}{
+		{
+			name: "running",
+			ctor: NewController,
+			tr:   &v1beta1.TaskRun{},
+		},
+		{
+			name:          "completed",
+			ctor:          NewController,
+			tr:            &v1beta1.TaskRun{Status: v1beta1.TaskRunStatus{Status: completed}},
+			wantFinalizer: true,
+		},
+		{
+			name: "signed with the finalizer of an earlier version",
+			ctor: NewController,
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Finalizers: []string{finalizerName}, Annotations: map[string]string{signing.ChainsAnnotation: "true"}},
+				Status:     v1beta1.TaskRunStatus{Status: completed},
+			},
+		},
+		{
+			name: "deleted before it completed",
+			ctor: NewController,
+			tr:   &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Finalizers: []string{finalizerName}, DeletionTimestamp: &now}},
+		},
+		{
+			name: "deleted before it's signed",
+			ctor: NewController,
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Finalizers: []string{finalizerName}, DeletionTimestamp: &now},
+				Status:     v1beta1.TaskRunStatus{Status: completed},
+			},
+			wantFinalizer: true,
+		},
+		{
+			name: "completed without finalizer",
+			ctor: NewControllerWithoutFinalizer,
+			tr:   &v1beta1.TaskRun{Status: v1beta1.TaskRunStatus{Status: completed}},
+		},
+		{
+			name: "deleted with the finalizer without finalizer",
+			ctor: NewControllerWithoutFinalizer,
+			tr: &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{Finalizers: []string{finalizerName}, DeletionTimestamp: &now},
+				Status:     v1beta1.TaskRunStatus{Status: completed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			tr := tt.tr.DeepCopy()
+			tr.Name, tr.Namespace = "bar", "foo"
+			tri := setupData(ctx, t, []*v1beta1.TaskRun{tr})
+			if err := tri.Informer().GetIndexer().Add(tr); err != nil {
+				t.Fatal(err)
+			}
+
+			configMapWatcher := configmap.NewStaticWatcher(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: system.Namespace(),
+					Name:      config.ChainsConfig,
+				},
+			})
+			ctl := tt.ctor(ctx, configMapWatcher)
+			if la, ok := ctl.Reconciler.(pkgreconciler.LeaderAware); ok {
+				if err := la.Promote(pkgreconciler.UniversalBucket(), func(pkgreconciler.Bucket, types.NamespacedName) {}); err != nil {
+					t.Fatalf("Promote() = %v", err)
+				}
+			}
+			// There are no signing keys, so the completed TaskRuns fail to be signed.
+			ctl.Reconciler.Reconcile(ctx, "foo/bar")
+
+			got, err := fakepipelineclient.Get(ctx).TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hasFinalizer(got) != tt.wantFinalizer {
+				t.Errorf("finalizers = %v, want chains finalizer %v", got.Finalizers, tt.wantFinalizer)
+			}
+		})
+	}
+}
+
 func setupData(ctx context.Context, t *testing.T, trs []*v1beta1.TaskRun) informers.TaskRunInformer {
 	tri := faketaskruninformer.Get(ctx)
 	c := fakepipelineclient.Get(ctx)
@@ -151,9 +250,16 @@ func TestReconciler_handleTaskRun(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			signer := &mockSigner{}
 			ctx, _ := rtesting.SetupFakeContext(t)
+			ctx = config.ToContext(ctx, &config.Config{})
+			ps := fakepipelineclient.Get(ctx)
+			if _, err := ps.TektonV1beta1().TaskRuns(tt.tr.Namespace).Create(ctx, tt.tr, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
 
 			r := &Reconciler{
-				TaskRunSigner: signer,
+				TaskRunSigner:     signer,
+				KubeClient:        fakekubeclient.Get(ctx),
+				Pipelineclientset: ps,
 			}
 			if err := r.ReconcileKind(ctx, tt.tr); err != nil {
 				t.Errorf("Reconciler.handleTaskRun() error = %v", err)
@@ -165,11 +271,749 @@ func TestReconciler_handleTaskRun(t *testing.T) {
 	}
 }
 
+func TestReconciler_skipAnnotation(t *testing.T) {
+	signer := &mockSigner{}
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ctx = config.ToContext(ctx, &config.Config{})
+	ps := fakepipelineclient.Get(ctx)
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   "bar",
+			Annotations: map[string]string{signing.SkipAnnotation: "true"},
+		},
+		Status: v1beta1.TaskRunStatus{
+			Status: duckv1beta1.Status{
+				Conditions: []apis.Condition{{Type: apis.ConditionSucceeded}},
+			}},
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Reconciler{
+		TaskRunSigner:     signer,
+		KubeClient:        fakekubeclient.Get(ctx),
+		Pipelineclientset: ps,
+	}
+	if err := r.ReconcileKind(ctx, tr); err != nil {
+		t.Errorf("Reconciler.ReconcileKind() error = %v", err)
+	}
+	if signer.signed {
+		t.Error("expected taskrun with skip annotation not to be signed")
+	}
+
+	got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations[signing.ChainsAnnotation] != "skipped" {
+		t.Errorf("expected taskrun to be marked skipped, got %q", got.Annotations[signing.ChainsAnnotation])
+	}
+}
+
+func TestReconciler_pendingState(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ctx = config.ToContext(ctx, &config.Config{
+		Namespaces: config.NamespaceConfig{Exclude: []string{"excluded"}},
+	})
+	ps := fakepipelineclient.Get(ctx)
+	r := &Reconciler{
+		TaskRunSigner:     &mockSigner{},
+		KubeClient:        fakekubeclient.Get(ctx),
+		Pipelineclientset: ps,
+	}
+
+	for ns, want := range map[string]string{"bar": signing.StatePending, "excluded": ""} {
+		tr := &v1beta1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: ns,
+			},
+		}
+		if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.ReconcileKind(ctx, tr); err != nil {
+			t.Errorf("Reconciler.ReconcileKind() error = %v", err)
+		}
+		got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state := signing.State(got); state != want {
+			t.Errorf("State() in namespace %s = %q, want %q", ns, state, want)
+		}
+	}
+}
+
+func TestReconciler_resignAnnotation(t *testing.T) {
+	signer := &mockSigner{}
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ctx = config.ToContext(ctx, &config.Config{})
+	ps := fakepipelineclient.Get(ctx)
+
+	tr := &v1beta1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "bar",
+			Annotations: map[string]string{
+				signing.ChainsAnnotation: "true",
+				signing.ResignAnnotation: "true",
+			},
+		},
+		Status: v1beta1.TaskRunStatus{
+			Status: duckv1beta1.Status{
+				Conditions: []apis.Condition{{Type: apis.ConditionSucceeded}},
+			}},
+	}
+	if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Reconciler{
+		TaskRunSigner:     signer,
+		KubeClient:        fakekubeclient.Get(ctx),
+		Pipelineclientset: ps,
+	}
+	if err := r.ReconcileKind(ctx, tr); err != nil {
+		t.Errorf("Reconciler.ReconcileKind() error = %v", err)
+	}
+
+	got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signing.Reconciled(got) || signing.ResignRequested(got) {
+		t.Fatalf("expected signing state to be cleared, got annotations %v", got.Annotations)
+	}
+	if v := signing.SigningVersion(got); v != 1 {
+		t.Errorf("SigningVersion() = %d, want 1", v)
+	}
+
+	// The next reconcile signs it again.
+	if err := r.ReconcileKind(ctx, got); err != nil {
+		t.Errorf("Reconciler.ReconcileKind() error = %v", err)
+	}
+	if !signer.signed {
+		t.Error("expected taskrun to be re-signed")
+	}
+}
+
+func TestReconciler_lostTransparencyUploads(t *testing.T) {
+	tests := []struct {
+		name        string
+		signer      signing.Signer
+		wantErr     bool
+		wantResign  bool
+		wantResumed bool
+	}{
+		{name: "signer can't resume uploads", signer: &mockSigner{}, wantResign: true},
+		{name: "uploads resumed", signer: &resumingSigner{}, wantResumed: true},
+		{name: "payloads can't be read back", signer: &resumingSigner{err: signing.ErrUploadsLost}, wantResign: true},
+		{name: "backend unavailable", signer: &resumingSigner{err: errors.New("unavailable")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			ctx = config.ToContext(ctx, &config.Config{})
+			ps := fakepipelineclient.Get(ctx)
+
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "bar",
+					Annotations: map[string]string{
+						signing.ChainsAnnotation:              "true",
+						signing.TransparencyPendingAnnotation: `[{"key":"taskrun-uid","url":"https://rekor.sigstore.dev"}]`,
+					},
+				},
+				Status: v1beta1.TaskRunStatus{
+					Status: duckv1beta1.Status{
+						Conditions: []apis.Condition{{Type: apis.ConditionSucceeded}},
+					}},
+			}
+			if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			r := &Reconciler{
+				TaskRunSigner:     tt.signer,
+				KubeClient:        fakekubeclient.Get(ctx),
+				Pipelineclientset: ps,
+				TlogQueue:         signing.NewTlogQueue(ps),
+			}
+			if err := r.ReconcileKind(ctx, tr); (err != nil) != tt.wantErr {
+				t.Errorf("Reconciler.ReconcileKind() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signing.ResignRequested(got) != tt.wantResign {
+				t.Errorf("re-sign requested = %v, want %v, got annotations %v", signing.ResignRequested(got), tt.wantResign, got.Annotations)
+			}
+			if s, ok := tt.signer.(*resumingSigner); ok && s.resumed != tt.wantResumed {
+				t.Errorf("uploads resumed = %v, want %v", s.resumed, tt.wantResumed)
+			}
+		})
+	}
+}
+
+func TestReconciler_collectGarbage(t *testing.T) {
+	signedAt := func(d time.Duration) string {
+		return time.Now().Add(-d).UTC().Format(time.RFC3339)
+	}
+	tests := []struct {
+		name        string
+		retention   time.Duration
+		signedAt    string
+		pending     string
+		wantRemoved bool
+		wantRequeue bool
+	}{
+		{
+			name:     "no retention",
+			signedAt: signedAt(48 * time.Hour),
+		},
+		{
+			name:        "expired",
+			retention:   24 * time.Hour,
+			signedAt:    signedAt(48 * time.Hour),
+			wantRemoved: true,
+		},
+		{
+			name:        "not expired",
+			retention:   24 * time.Hour,
+			signedAt:    signedAt(time.Hour),
+			wantRequeue: true,
+		},
+		{
+			// The queued uploads read the payloads back from the annotations.
+			name:        "uploads pending",
+			retention:   24 * time.Hour,
+			signedAt:    signedAt(48 * time.Hour),
+			pending:     `[{"key":"taskrun-1234","url":"https://rekor.sigstore.dev","storage":"tekton"}]`,
+			wantRequeue: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := rtesting.SetupFakeContext(t)
+			ctx = config.ToContext(ctx, &config.Config{GC: config.GCConfig{Retention: tt.retention}})
+			ps := fakepipelineclient.Get(ctx)
+
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "bar",
+					Annotations: map[string]string{
+						signing.ChainsAnnotation:                   "true",
+						signing.StateTimeAnnotation:                tt.signedAt,
+						"chains.tekton.dev/payload-taskrun-1234":   "payload",
+						"chains.tekton.dev/signature-taskrun-1234": "signature",
+						"chains.tekton.dev/bundle-taskrun-1234":    "bundle",
+					},
+				},
+				Status: v1beta1.TaskRunStatus{
+					Status: duckv1beta1.Status{
+						Conditions: []apis.Condition{{Type: apis.ConditionSucceeded}},
+					}},
+			}
+			if tt.pending != "" {
+				tr.Annotations[signing.TransparencyPendingAnnotation] = tt.pending
+			}
+			if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			r := &Reconciler{
+				TaskRunSigner:     &mockSigner{},
+				KubeClient:        fakekubeclient.Get(ctx),
+				Pipelineclientset: ps,
+			}
+			err := r.ReconcileKind(ctx, tr)
+			if requeue, _ := controller.IsRequeueKey(err); requeue != tt.wantRequeue {
+				t.Errorf("Reconciler.ReconcileKind() error = %v, want requeue %v", err, tt.wantRequeue)
+			} else if !requeue && err != nil {
+				t.Errorf("Reconciler.ReconcileKind() error = %v", err)
+			}
+
+			got, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Get(ctx, tr.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, a := range []string{"chains.tekton.dev/payload-taskrun-1234", "chains.tekton.dev/bundle-taskrun-1234"} {
+				if _, ok := got.Annotations[a]; ok == tt.wantRemoved {
+					t.Errorf("%s annotation present = %v, want removed %v", a, ok, tt.wantRemoved)
+				}
+			}
+			if !signing.Reconciled(got) {
+				t.Error("expected taskrun to still be marked as signed")
+			}
+		})
+	}
+}
+
+func TestReconciler_namespaceFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        config.NamespaceConfig
+		shouldSign bool
+	}{
+		{
+			name:       "no filters",
+			shouldSign: true,
+		},
+		{
+			name:       "included",
+			cfg:        config.NamespaceConfig{Include: []string{"other", "team-a"}},
+			shouldSign: true,
+		},
+		{
+			name:       "not included",
+			cfg:        config.NamespaceConfig{Include: []string{"other"}},
+			shouldSign: false,
+		},
+		{
+			name:       "excluded",
+			cfg:        config.NamespaceConfig{Include: []string{"team-a"}, Exclude: []string{"team-a"}},
+			shouldSign: false,
+		},
+		{
+			name:       "selector matches",
+			cfg:        config.NamespaceConfig{Selector: "team=a"},
+			shouldSign: true,
+		},
+		{
+			name:       "selector doesn't match",
+			cfg:        config.NamespaceConfig{Selector: "platform"},
+			shouldSign: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &mockSigner{}
+			ctx, _ := rtesting.SetupFakeContext(t)
+			ctx = config.ToContext(ctx, &config.Config{Namespaces: tt.cfg})
+
+			kc := fakek8s.NewSimpleClientset(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "team-a",
+					Labels: map[string]string{"team": "a"},
+				},
+			})
+			namespaces, err := NewNamespaceLister(ctx, kc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &Reconciler{
+				TaskRunSigner: signer,
+				KubeClient:    kc,
+				Namespaces:    namespaces,
+			}
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "team-a",
+				},
+				Status: v1beta1.TaskRunStatus{
+					Status: duckv1beta1.Status{
+						Conditions: []apis.Condition{{Type: apis.ConditionSucceeded}},
+					}},
+			}
+			if err := r.ReconcileKind(ctx, tr); err != nil {
+				t.Errorf("Reconciler.ReconcileKind() error = %v", err)
+			}
+			if signer.signed != tt.shouldSign {
+				t.Errorf("Reconciler.ReconcileKind() signed = %v, wanted %v", signer.signed, tt.shouldSign)
+			}
+		})
+	}
+}
+
+func TestReconciler_watched(t *testing.T) {
+	store := config.NewConfigStore(logtesting.TestLogger(t))
+	store.OnConfigChanged(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: config.ChainsConfig},
+		Data:       map[string]string{"namespaces.exclude": "team-b"},
+	})
+	watched := (&Reconciler{}).watched(store)
+
+	taskRun := func(namespace string, finalizers ...string) *v1beta1.TaskRun {
+		return &v1beta1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: namespace, Finalizers: finalizers}}
+	}
+	if !watched(taskRun("team-a")) {
+		t.Error("expected the TaskRuns of a signed namespace to be queued")
+	}
+	if watched(taskRun("team-b")) {
+		t.Error("expected the TaskRuns of an excluded namespace not to be queued")
+	}
+	if !watched(taskRun("team-b", finalizerName)) {
+		t.Error("expected the TaskRuns of an excluded namespace with the finalizer to be queued, to remove it")
+	}
+	if !watched(cache.DeletedFinalStateUnknown{Key: "team-b/foo"}) {
+		t.Error("expected the TaskRuns that were deleted while not watched to be queued")
+	}
+}
+
+func TestReconciler_namespaceOverrides(t *testing.T) {
+	clusterCfg := &config.Config{
+		Artifacts: config.ArtifactConfigs{
+			TaskRuns: config.Artifact{Format: "tekton", StorageBackend: "tekton", Signer: "x509"},
+			OCI:      config.Artifact{Format: "simplesigning", StorageBackend: "oci", Signer: "x509"},
+			Blobs:    config.Artifact{Format: "in-toto", Signer: "x509"},
+		},
+		Signers: config.SignerConfigs{
+			Profiles: []config.SignerProfile{
+				{Name: "dev-cosign", Type: config.SignerX509},
+				{Name: "release-hsm", Type: config.SignerKMS, KMSRef: "gcpkms://release"},
+			},
+			Namespaces: []config.NamespaceSigners{{Namespace: "team-a", Signers: []string{"dev-cosign", "kms"}}},
+		},
+	}
+	tests := []struct {
+		name       string
+		data       map[string]string
+		unlabeled  bool
+		want       config.Artifact
+		shouldSign bool
+	}{
+		{
+			name:       "no overrides",
+			want:       clusterCfg.Artifacts.TaskRuns,
+			shouldSign: true,
+		}, {
+			name:       "overrides without the label",
+			data:       map[string]string{"artifacts.taskrun.format": "foo"},
+			unlabeled:  true,
+			want:       clusterCfg.Artifacts.TaskRuns,
+			shouldSign: true,
+		}, {
+			name: "format and storage overridden",
+			data: map[string]string{
+				"artifacts.taskrun.format":  "in-toto",
+				"artifacts.taskrun.storage": "oci",
+				"builder.id":                "ignored",
+			},
+			want:       config.Artifact{Format: "in-toto", StorageBackend: "oci", Signer: "x509"},
+			shouldSign: true,
+		}, {
+			name: "unknown format",
+			data: map[string]string{"artifacts.taskrun.format": "foo"},
+		}, {
+			name: "kms signer without a key",
+			data: map[string]string{"artifacts.taskrun.signer": "kms"},
+		}, {
+			name:       "allowed signer profile",
+			data:       map[string]string{"artifacts.taskrun.signer": "dev-cosign"},
+			want:       config.Artifact{Format: "tekton", StorageBackend: "tekton", Signer: "dev-cosign"},
+			shouldSign: true,
+		}, {
+			name: "signer profile not allowed",
+			data: map[string]string{"artifacts.taskrun.signer": "release-hsm"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer := &mockSigner{}
+			ctx, _ := rtesting.SetupFakeContext(t)
+			ctx = config.ToContext(ctx, clusterCfg)
+			kc := fakekubeclient.Get(ctx)
+			if tt.data != nil {
+				cm := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: config.ChainsConfig, Namespace: "team-a", Labels: map[string]string{config.OverridesLabel: "true"}},
+					Data:       tt.data,
+				}
+				if tt.unlabeled {
+					cm.Labels = nil
+				}
+				if _, err := kc.CoreV1().ConfigMaps(cm.Namespace).Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			namespaceConfigs, err := NewNamespaceConfigLister(ctx, kc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &Reconciler{
+				TaskRunSigner:    signer,
+				KubeClient:       kc,
+				NamespaceConfigs: namespaceConfigs,
+			}
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "team-a",
+				},
+				Status: v1beta1.TaskRunStatus{
+					Status: duckv1beta1.Status{
+						Conditions: []apis.Condition{{Type: apis.ConditionSucceeded}},
+					}},
+			}
+			err = r.ReconcileKind(ctx, tr)
+			if (err == nil) != tt.shouldSign {
+				t.Errorf("Reconciler.ReconcileKind() error = %v", err)
+			}
+			if signer.signed != tt.shouldSign {
+				t.Fatalf("Reconciler.ReconcileKind() signed = %v, wanted %v", signer.signed, tt.shouldSign)
+			}
+			if tt.shouldSign && signer.cfg.Artifacts.TaskRuns != tt.want {
+				t.Errorf("TaskRun artifact config = %v, want %v", signer.cfg.Artifacts.TaskRuns, tt.want)
+			}
+			if clusterCfg.Artifacts.TaskRuns.Format != "tekton" {
+				t.Error("namespace overrides modified the cluster config")
+			}
+		})
+	}
+}
+
+func TestReconciler_Backfill(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+	ctx = config.ToContext(ctx, &config.Config{})
+	ps := fakepipelineclient.Get(ctx)
+	now := time.Now()
+
+	// Only the TaskRuns that match -taskrun-selector are backfilled.
+	ctx = WithCacheOptions(ctx, CacheOptions{LabelSelector: "chains.tekton.dev/sign=true"})
+	selected := map[string]string{"chains.tekton.dev/sign": "true"}
+
+	done := func(name string, completed time.Time, annotations map[string]string) *v1beta1.TaskRun {
+		return &v1beta1.TaskRun{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: selected, Annotations: annotations},
+			Status: v1beta1.TaskRunStatus{
+				Status: duckv1beta1.Status{
+					Conditions: []apis.Condition{{Type: apis.ConditionSucceeded}},
+				},
+				TaskRunStatusFields: v1beta1.TaskRunStatusFields{
+					CompletionTime: &metav1.Time{Time: completed},
+				},
+			},
+		}
+	}
+	unselected := done("unselected", now.Add(-time.Hour), nil)
+	unselected.Labels = nil
+	for _, tr := range []*v1beta1.TaskRun{
+		done("recent", now.Add(-time.Hour), nil),
+		unselected,
+		done("old", now.Add(-48*time.Hour), nil),
+		done("signed", now.Add(-time.Hour), map[string]string{signing.ChainsAnnotation: "true"}),
+		{ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"}},
+	} {
+		if _, err := ps.TektonV1beta1().TaskRuns(tr.Namespace).Create(ctx, tr, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	signer := &mockSigner{}
+	r := &Reconciler{
+		TaskRunSigner:     signer,
+		KubeClient:        fakekubeclient.Get(ctx),
+		Pipelineclientset: ps,
+	}
+	count, err := r.Backfill(ctx, "", now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	if count != 1 || !signer.signed || signer.tr.Name != "recent" {
+		t.Errorf("Backfill() = %d, signed = %v, wanted 1 signed TaskRun", count, signer.signed)
+	}
+}
+
+func TestTaskRunSelected(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.FilterConfig
+		labels      map[string]string
+		annotations map[string]string
+		taskRef     *v1beta1.TaskRef
+		want        bool
+	}{
+		{
+			name: "no filters",
+			want: true,
+		},
+		{
+			name:    "task ref matches",
+			cfg:     config.FilterConfig{Tasks: []string{"build", "release"}},
+			taskRef: &v1beta1.TaskRef{Name: "release"},
+			want:    true,
+		},
+		{
+			name:   "cluster task label matches",
+			cfg:    config.FilterConfig{Tasks: []string{"buildah"}},
+			labels: map[string]string{"tekton.dev/clusterTask": "buildah"},
+			want:   true,
+		},
+		{
+			name:    "task ref doesn't match",
+			cfg:     config.FilterConfig{Tasks: []string{"release"}},
+			taskRef: &v1beta1.TaskRef{Name: "lint"},
+			want:    false,
+		},
+		{
+			name:   "pipeline matches",
+			cfg:    config.FilterConfig{Tasks: []string{"release"}, Pipelines: []string{"release-pipeline"}},
+			labels: map[string]string{"tekton.dev/pipeline": "release-pipeline"},
+			want:   true,
+		},
+		{
+			name:    "bundle prefix matches",
+			cfg:     config.FilterConfig{Bundles: []string{"gcr.io/tekton-releases/catalog/"}},
+			taskRef: &v1beta1.TaskRef{Name: "kaniko", Bundle: "gcr.io/tekton-releases/catalog/upstream/kaniko:0.5"},
+			want:    true,
+		},
+		{
+			name:    "bundle doesn't match",
+			cfg:     config.FilterConfig{Bundles: []string{"gcr.io/tekton-releases/catalog/"}},
+			taskRef: &v1beta1.TaskRef{Name: "kaniko", Bundle: "docker.io/someone/kaniko:latest"},
+			want:    false,
+		},
+		{
+			name:    "bundle repository matches",
+			cfg:     config.FilterConfig{Bundles: []string{"gcr.io/tekton-releases/catalog/upstream/kaniko"}},
+			taskRef: &v1beta1.TaskRef{Name: "kaniko", Bundle: "gcr.io/tekton-releases/catalog/upstream/kaniko:0.5"},
+			want:    true,
+		},
+		{
+			name:    "bundle digest matches",
+			cfg:     config.FilterConfig{Bundles: []string{"gcr.io/tekton-releases/catalog/upstream/kaniko"}},
+			taskRef: &v1beta1.TaskRef{Name: "kaniko", Bundle: "gcr.io/tekton-releases/catalog/upstream/kaniko@sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"},
+			want:    true,
+		},
+		{
+			name:    "bundle in another repository with the same prefix",
+			cfg:     config.FilterConfig{Bundles: []string{"gcr.io/tekton-releases/catalog/upstream/kaniko"}},
+			taskRef: &v1beta1.TaskRef{Name: "kaniko", Bundle: "gcr.io/tekton-releases/catalog/upstream/kaniko-fork:0.5"},
+			want:    false,
+		},
+		{
+			name:    "bundle with a longer tag",
+			cfg:     config.FilterConfig{Bundles: []string{"gcr.io/tekton-releases/catalog/upstream/kaniko:0.5"}},
+			taskRef: &v1beta1.TaskRef{Name: "kaniko", Bundle: "gcr.io/tekton-releases/catalog/upstream/kaniko:0.50"},
+			want:    false,
+		},
+		{
+			name:   "label matches",
+			cfg:    config.FilterConfig{LabelSelector: "chains.tekton.dev/sign=true"},
+			labels: map[string]string{"chains.tekton.dev/sign": "true"},
+			want:   true,
+		},
+		{
+			name: "label missing",
+			cfg:  config.FilterConfig{LabelSelector: "chains.tekton.dev/sign=true"},
+			want: false,
+		},
+		{
+			name:   "pipeline label",
+			cfg:    config.FilterConfig{LabelSelector: "tekton.dev/pipeline in (release, nightly)"},
+			labels: map[string]string{"tekton.dev/pipeline": "lint"},
+			want:   false,
+		},
+		{
+			name:        "annotation matches",
+			cfg:         config.FilterConfig{AnnotationSelector: "chains.tekton.dev/sign=true"},
+			annotations: map[string]string{"chains.tekton.dev/sign": "true"},
+			want:        true,
+		},
+		{
+			name:        "label matches, annotation doesn't",
+			cfg:         config.FilterConfig{LabelSelector: "team", AnnotationSelector: "chains.tekton.dev/sign=true"},
+			labels:      map[string]string{"team": "a"},
+			annotations: map[string]string{"chains.tekton.dev/sign": "false"},
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &v1beta1.TaskRun{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      tt.labels,
+					Annotations: tt.annotations,
+				},
+				Spec: v1beta1.TaskRunSpec{
+					TaskRef: tt.taskRef,
+				},
+			}
+			got, err := taskRunSelected(tt.cfg, tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("taskRunSelected() = %v, wanted %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountUnsignedTaskRuns(t *testing.T) {
+	done := duckv1beta1.Status{
+		Conditions: []apis.Condition{{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue}},
+	}
+	trs := []*v1beta1.TaskRun{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "unsigned", Namespace: "foo"},
+			Status:     v1beta1.TaskRunStatus{Status: done},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "signed",
+				Namespace:   "foo",
+				Annotations: map[string]string{signing.ChainsAnnotation: "true"},
+			},
+			Status: v1beta1.TaskRunStatus{Status: done},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "foo"},
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, tr := range trs {
+		if err := indexer.Add(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := countUnsignedTaskRuns(listers.NewTaskRunLister(indexer), wholeShard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1 {
+		t.Errorf("countUnsignedTaskRuns() = %d, wanted 1", got)
+	}
+	// The TaskRuns of the buckets other replicas lead are counted by them.
+	otherReplica := func(*v1beta1.TaskRun) bool { return false }
+	if got, err := countUnsignedTaskRuns(listers.NewTaskRunLister(indexer), otherReplica); err != nil || got != 0 {
+		t.Errorf("countUnsignedTaskRuns() = %d, %v outside the shard, wanted 0", got, err)
+	}
+}
+
 type mockSigner struct {
 	signed bool
+	cfg    *config.Config
+	tr     *v1beta1.TaskRun
 }
 
 func (m *mockSigner) SignTaskRun(ctx context.Context, tr *v1beta1.TaskRun) error {
 	m.signed = true
+	m.cfg = config.FromContext(ctx)
+	m.tr = tr
+	return nil
+}
+
+// resumingSigner queues the transparency log uploads TaskRuns lost again, unless err is set.
+type resumingSigner struct {
+	mockSigner
+	err     error
+	resumed bool
+}
+
+func (m *resumingSigner) ResumeUploads(ctx context.Context, tr *v1beta1.TaskRun) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.resumed = true
 	return nil
 }
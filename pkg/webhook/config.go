@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/tektoncd/chains/pkg/chains/signing/kms"
+	"github.com/tektoncd/chains/pkg/config"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigValidator rejects chains-config updates that Chains can't use, so a typo can't
+// silently turn signing off: unknown keys, unsupported values, such as formats and storage
+// backends, settings a signer or backend needs that are missing, and malformed KMS references.
+type ConfigValidator struct {
+	// Namespace is the namespace Chains is installed in. The chains-config of other
+	// namespaces holds overrides, which only set artifacts.* keys.
+	Namespace string
+	Logger    *zap.SugaredLogger
+}
+
+// ServeHTTP handles AdmissionReviews.
+func (v *ConfigValidator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, v.Logger, v.Admit)
+}
+
+// Admit allows the request unless it creates or updates a chains-config that isn't valid.
+func (v *ConfigValidator) Admit(_ context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if req.Kind.Kind != "ConfigMap" || req.Name != config.ChainsConfig || len(req.Object.Raw) == 0 {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	cm := &corev1.ConfigMap{}
+	if err := json.Unmarshal(req.Object.Raw, cm); err != nil {
+		return deny(fmt.Sprintf("decoding ConfigMap: %v", err))
+	}
+	validate := validateConfig
+	if req.Namespace != v.Namespace {
+		validate = validateOverrides
+	}
+	if err := validate(cm.Data); err != nil {
+		v.Logger.Infof("Denied %s/%s: %v", req.Namespace, req.Name, err)
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonInvalid, Message: fmt.Sprintf("%s is not valid: %v", config.ChainsConfig, err)},
+		}
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+// validateConfig checks the chains-config in the namespace Chains is installed in.
+func validateConfig(data map[string]string) error {
+	var merr *multierror.Error
+	if unknown := config.UnknownKeys(data); len(unknown) > 0 {
+		merr = multierror.Append(merr, fmt.Errorf("unknown keys %s", strings.Join(unknown, ", ")))
+	}
+	cfg, err := config.NewConfigFromMap(data)
+	if err != nil {
+		return multierror.Append(merr, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	refs := []string{cfg.Signers.KMS.KMSRef}
+	for _, p := range cfg.Signers.Profiles {
+		refs = append(refs, p.KMSRef)
+	}
+	for _, ref := range refs {
+		if ref == "" {
+			continue
+		}
+		if err := kms.ValidReference(ref); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+	return merr.ErrorOrNil()
+}
+
+// validateOverrides checks the chains-config of another namespace. Only its values are
+// checked: whether they're complete depends on the chains-config they override.
+func validateOverrides(data map[string]string) error {
+	var merr *multierror.Error
+	if unknown := config.UnknownOverrideKeys(data); len(unknown) > 0 {
+		merr = multierror.Append(merr, fmt.Errorf("keys %s can't be overridden per namespace", strings.Join(unknown, ", ")))
+	}
+	if _, err := config.NewConfigFromMap(data); err != nil {
+		merr = multierror.Append(merr, err)
+	}
+	return merr.ErrorOrNil()
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func TestConfigValidator_Admit(t *testing.T) {
+	tests := []struct {
+		name        string
+		cmName      string
+		namespace   string
+		data        map[string]string
+		wantAllowed bool
+		wantMessage string
+	}{
+		{
+			name:        "valid",
+			data:        map[string]string{"artifacts.taskrun.format": "in-toto", "artifacts.oci.storage": "oci"},
+			wantAllowed: true,
+		},
+		{
+			name:        "unknown key",
+			data:        map[string]string{"artifacts.taskrun.fromat": "in-toto"},
+			wantMessage: "artifacts.taskrun.fromat",
+		},
+		{
+			name:        "unsupported format",
+			data:        map[string]string{"artifacts.taskrun.format": "slsa"},
+			wantMessage: "artifacts.taskrun.format",
+		},
+		{
+			name:        "unsupported storage backend",
+			data:        map[string]string{"artifacts.oci.storage": "s3"},
+			wantMessage: "artifacts.oci.storage",
+		},
+		{
+			name:        "missing setting",
+			data:        map[string]string{"artifacts.taskrun.storage": "gcs"},
+			wantMessage: "storage.gcs.bucket",
+		},
+		{
+			name:        "malformed kms reference",
+			data:        map[string]string{"artifacts.taskrun.signer": "kms", "signers.kms.kmsref": "gcpkms://projects/foo"},
+			wantMessage: "gcpkms://",
+		},
+		{
+			name:        "unsupported kms",
+			data:        map[string]string{"artifacts.taskrun.signer": "kms", "signers.kms.kmsref": "vault://foo"},
+			wantMessage: "must start with one of",
+		},
+		{
+			name:        "valid kms reference",
+			data:        map[string]string{"artifacts.taskrun.signer": "kms", "signers.kms.kmsref": "gcpkms://projects/foo/locations/global/keyRings/bar/cryptoKeys/baz"},
+			wantAllowed: true,
+		},
+		{
+			name:        "valid overrides",
+			namespace:   "team-a",
+			data:        map[string]string{"artifacts.taskrun.storage": "gcs"},
+			wantAllowed: true,
+		},
+		{
+			name:        "key that can't be overridden",
+			namespace:   "team-a",
+			data:        map[string]string{"storage.gcs.bucket": "bucket"},
+			wantMessage: "can't be overridden",
+		},
+		{
+			name:        "unsupported override",
+			namespace:   "team-a",
+			data:        map[string]string{"artifacts.oci.format": "in-toto"},
+			wantMessage: "artifacts.oci.format",
+		},
+		{
+			name:        "other configmap",
+			cmName:      "other",
+			data:        map[string]string{"artifacts.taskrun.fromat": "in-toto"},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request(t, "ConfigMap", &corev1.ConfigMap{Data: tt.data})
+			req.Name, req.Namespace = "chains-config", "tekton-chains"
+			if tt.cmName != "" {
+				req.Name = tt.cmName
+			}
+			if tt.namespace != "" {
+				req.Namespace = tt.namespace
+			}
+			v := &ConfigValidator{Namespace: "tekton-chains", Logger: logtesting.TestLogger(t)}
+			resp := v.Admit(context.Background(), req)
+			if resp.Allowed != tt.wantAllowed {
+				t.Fatalf("Admit() allowed = %t, want %t: %+v", resp.Allowed, tt.wantAllowed, resp.Result)
+			}
+			if tt.wantMessage != "" && !strings.Contains(resp.Result.Message, tt.wantMessage) {
+				t.Errorf("Admit() message = %q, want it to mention %q", resp.Result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
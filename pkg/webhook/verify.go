@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+	"github.com/tektoncd/chains/pkg/chains/verify"
+)
+
+// verifyImage resolves the image to a digest and checks that it has a signature, or an
+// attestation, that satisfies the policy. It returns the digest, which the workload is pinned
+// to, so that a tag moved after it's admitted doesn't run an image that wasn't verified.
+func verifyImage(ctx context.Context, ref name.Reference, keychain authn.Keychain, policy Policy) (name.Digest, error) {
+	digest, ok := ref.(name.Digest)
+	if !ok {
+		desc, err := remote.Head(ref, remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx))
+		if err != nil {
+			return name.Digest{}, errors.Wrap(err, "resolving the digest")
+		}
+		digest = ref.Context().Digest(desc.Digest.String())
+	}
+	return digest, verifyDigest(ctx, digest, keychain, policy)
+}
+
+// verifyDigest checks that the image has a signature, or an attestation, that satisfies the
+// policy.
+func verifyDigest(ctx context.Context, ref name.Digest, keychain authn.Keychain, policy Policy) error {
+	opts := verify.ImageOptions{Verifier: policy.Verifier, Keychain: keychain}
+	if !policy.Attestations {
+		_, err := verify.VerifyImageSignatures(ctx, ref, opts)
+		return err
+	}
+
+	atts, err := verify.VerifyImageAttestation(ctx, ref, opts)
+	if err != nil {
+		return err
+	}
+	if policy.BuilderID == "" {
+		return nil
+	}
+	for _, att := range atts {
+		builder, err := verify.BuilderID(att.Payload)
+		if err != nil {
+			return err
+		}
+		if builder == policy.BuilderID {
+			return nil
+		}
+	}
+	return fmt.Errorf("no attestation was built by %s", policy.BuilderID)
+}
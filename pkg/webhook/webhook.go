@@ -0,0 +1,245 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook implements admission webhooks that reject workloads running images that
+// Chains hasn't signed, pinning the images of those admitted to the digests that were verified,
+// and chains-config updates Chains can't use.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Policy is what the images of admitted workloads must satisfy.
+type Policy struct {
+	// Verifier checks the signatures. When nil, signatures are checked with the Fulcio
+	// certificates attached to them.
+	Verifier signature.Verifier
+	// Attestations requires in-toto attestations, instead of simple signatures.
+	Attestations bool
+	// BuilderID, when set, is the builder.id the provenance in an attestation must have.
+	BuilderID string
+}
+
+// Admitter admits workloads whose images satisfy the policy, with their images pinned to the
+// digests that were verified.
+type Admitter struct {
+	KubeClient kubernetes.Interface
+	Policy     Policy
+	Logger     *zap.SugaredLogger
+
+	// verifyImage checks a single image against the policy, and returns the digest it checked.
+	// It is replaced in tests.
+	verifyImage func(ctx context.Context, ref name.Reference, keychain authn.Keychain, policy Policy) (name.Digest, error)
+}
+
+// ServeHTTP handles AdmissionReviews.
+func (a *Admitter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serve(w, r, a.Logger, a.Admit)
+}
+
+// serve answers an AdmissionReview with the response of admit.
+func serve(w http.ResponseWriter, r *http.Request, logger *zap.SugaredLogger, admit func(context.Context, *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse) {
+	review := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, fmt.Sprintf("decoding admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review has no request", http.StatusBadRequest)
+		return
+	}
+	review.Response = admit(r.Context(), review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		logger.Errorf("Error writing admission response: %v", err)
+	}
+}
+
+// Admit allows the request if every image it runs satisfies the policy. Images referenced by
+// tag are replaced with the digest that was verified.
+func (a *Admitter) Admit(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	spec, path, err := podSpec(req)
+	if err != nil {
+		return deny(err.Error())
+	}
+	if spec == nil {
+		// Not something that runs images.
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	keychain, err := k8schain.New(ctx, a.KubeClient, k8schain.Options{
+		Namespace:          req.Namespace,
+		ServiceAccountName: spec.ServiceAccountName,
+		ImagePullSecrets:   pullSecrets(spec),
+	})
+	if err != nil {
+		return deny(fmt.Sprintf("loading registry credentials: %v", err))
+	}
+	verify := a.verifyImage
+	if verify == nil {
+		verify = verifyImage
+	}
+
+	var failures []string
+	pinned := map[string]string{}
+	for _, image := range images(spec) {
+		ref, err := name.ParseReference(image)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", image, err))
+			continue
+		}
+		digest, err := verify(ctx, ref, keychain, a.Policy)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", image, err))
+			continue
+		}
+		pinned[image] = digest.String()
+	}
+	if len(failures) > 0 {
+		a.Logger.Infof("Denied %s %s/%s: %s", req.Kind.Kind, req.Namespace, req.Name, strings.Join(failures, "; "))
+		return deny("images not signed by Tekton Chains: " + strings.Join(failures, "; "))
+	}
+	resp := &admissionv1.AdmissionResponse{Allowed: true}
+	if ops := pinImages(path, spec, pinned); len(ops) > 0 {
+		patch, err := json.Marshal(ops)
+		if err != nil {
+			return deny(fmt.Sprintf("pinning images: %v", err))
+		}
+		patchType := admissionv1.PatchTypeJSONPatch
+		resp.Patch, resp.PatchType = patch, &patchType
+	}
+	return resp
+}
+
+// patchOperation is an operation of a JSON patch.
+type patchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// pinImages returns the operations that replace the images of the containers in the pod spec at
+// path with the references they're pinned to.
+func pinImages(path string, spec *corev1.PodSpec, pinned map[string]string) []patchOperation {
+	var ops []patchOperation
+	pin := func(field string, i int, image string) {
+		if p, ok := pinned[image]; ok && p != image {
+			ops = append(ops, patchOperation{Op: "replace", Path: fmt.Sprintf("%s/%s/%d/image", path, field, i), Value: p})
+		}
+	}
+	for i, c := range spec.InitContainers {
+		pin("initContainers", i, c.Image)
+	}
+	for i, c := range spec.Containers {
+		pin("containers", i, c.Image)
+	}
+	for i, c := range spec.EphemeralContainers {
+		pin("ephemeralContainers", i, c.Image)
+	}
+	return ops
+}
+
+func deny(message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonForbidden, Message: message},
+	}
+}
+
+// podSpec decodes the pod template of the object being admitted, and returns the JSON pointer
+// to it. It returns nil for objects that don't run pods.
+func podSpec(req *admissionv1.AdmissionRequest) (*corev1.PodSpec, string, error) {
+	var obj interface{}
+	var spec func() *corev1.PodSpec
+	path := "/spec/template/spec"
+	switch req.Kind.Kind {
+	case "Pod":
+		o := &corev1.Pod{}
+		obj, spec, path = o, func() *corev1.PodSpec { return &o.Spec }, "/spec"
+	case "Deployment":
+		o := &appsv1.Deployment{}
+		obj, spec = o, func() *corev1.PodSpec { return &o.Spec.Template.Spec }
+	case "ReplicaSet":
+		o := &appsv1.ReplicaSet{}
+		obj, spec = o, func() *corev1.PodSpec { return &o.Spec.Template.Spec }
+	case "StatefulSet":
+		o := &appsv1.StatefulSet{}
+		obj, spec = o, func() *corev1.PodSpec { return &o.Spec.Template.Spec }
+	case "DaemonSet":
+		o := &appsv1.DaemonSet{}
+		obj, spec = o, func() *corev1.PodSpec { return &o.Spec.Template.Spec }
+	case "Job":
+		o := &batchv1.Job{}
+		obj, spec = o, func() *corev1.PodSpec { return &o.Spec.Template.Spec }
+	case "CronJob":
+		o := &batchv1.CronJob{}
+		obj, spec, path = o, func() *corev1.PodSpec { return &o.Spec.JobTemplate.Spec.Template.Spec }, "/spec/jobTemplate/spec/template/spec"
+	default:
+		return nil, "", nil
+	}
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return nil, "", errors.Wrapf(err, "decoding %s", req.Kind.Kind)
+	}
+	return spec(), path, nil
+}
+
+// images lists the images of every container in the pod.
+func images(spec *corev1.PodSpec) []string {
+	seen := map[string]bool{}
+	var images []string
+	add := func(image string) {
+		if !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	for _, c := range spec.InitContainers {
+		add(c.Image)
+	}
+	for _, c := range spec.Containers {
+		add(c.Image)
+	}
+	for _, c := range spec.EphemeralContainers {
+		add(c.Image)
+	}
+	return images
+}
+
+func pullSecrets(spec *corev1.PodSpec) []string {
+	var names []string
+	for _, s := range spec.ImagePullSecrets {
+		names = append(names, s.Name)
+	}
+	return names
+}
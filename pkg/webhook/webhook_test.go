@@ -0,0 +1,210 @@
+/*
+Copyright 2021 The Tekton Authors
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+const testDigest = "sha256:05f95b26ed10668b7183c1e2da98610e91372fa9f510046d4ce5812addad86b5"
+
+func testAdmitter(t *testing.T) *Admitter {
+	return &Admitter{
+		KubeClient: fakekube.NewSimpleClientset(&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "default"},
+		}),
+		Logger: logtesting.TestLogger(t),
+		// Images are signed unless their name says otherwise, and tags resolve to testDigest.
+		verifyImage: func(_ context.Context, ref name.Reference, _ authn.Keychain, _ Policy) (name.Digest, error) {
+			if strings.Contains(ref.String(), "unsigned") {
+				return name.Digest{}, errors.New("no matching signatures")
+			}
+			if d, ok := ref.(name.Digest); ok {
+				return d, nil
+			}
+			return ref.Context().Digest(testDigest), nil
+		},
+	}
+}
+
+func request(t *testing.T, kind string, obj interface{}) *admissionv1.AdmissionRequest {
+	t.Helper()
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &admissionv1.AdmissionRequest{
+		UID:       types.UID("1234"),
+		Kind:      metav1.GroupVersionKind{Kind: kind},
+		Namespace: "default",
+		Name:      "foo",
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	pod := func(images ...string) corev1.PodSpec {
+		spec := corev1.PodSpec{}
+		for _, image := range images {
+			spec.Containers = append(spec.Containers, corev1.Container{Image: image})
+		}
+		return spec
+	}
+	tests := []struct {
+		name        string
+		kind        string
+		obj         interface{}
+		wantAllowed bool
+		wantPatch   []patchOperation
+	}{
+		{
+			name:        "signed pod",
+			kind:        "Pod",
+			obj:         &corev1.Pod{Spec: pod("gcr.io/foo/signed@" + testDigest)},
+			wantAllowed: true,
+		},
+		{
+			name:        "signed pod by tag",
+			kind:        "Pod",
+			obj:         &corev1.Pod{Spec: pod("gcr.io/foo/signed@"+testDigest, "gcr.io/foo/signed:v1")},
+			wantAllowed: true,
+			wantPatch:   []patchOperation{{Op: "replace", Path: "/spec/containers/1/image", Value: "gcr.io/foo/signed@" + testDigest}},
+		},
+		{
+			name: "signed ephemeral container",
+			kind: "Pod",
+			obj: &corev1.Pod{Spec: corev1.PodSpec{
+				EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Image: "gcr.io/foo/debug"}}},
+			}},
+			wantAllowed: true,
+			wantPatch:   []patchOperation{{Op: "replace", Path: "/spec/ephemeralContainers/0/image", Value: "gcr.io/foo/debug@" + testDigest}},
+		},
+		{
+			name: "unsigned ephemeral container",
+			kind: "Pod",
+			obj: &corev1.Pod{Spec: corev1.PodSpec{
+				EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Image: "gcr.io/foo/unsigned"}}},
+			}},
+		},
+		{
+			name: "unsigned pod",
+			kind: "Pod",
+			obj:  &corev1.Pod{Spec: pod("gcr.io/foo/signed", "gcr.io/foo/unsigned")},
+		},
+		{
+			name: "unsigned init container",
+			kind: "Pod",
+			obj: &corev1.Pod{Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Image: "gcr.io/foo/unsigned"}},
+				Containers:     []corev1.Container{{Image: "gcr.io/foo/signed"}},
+			}},
+		},
+		{
+			name:        "signed deployment",
+			kind:        "Deployment",
+			obj:         &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: pod("gcr.io/foo/signed")}}},
+			wantAllowed: true,
+			wantPatch:   []patchOperation{{Op: "replace", Path: "/spec/template/spec/containers/0/image", Value: "gcr.io/foo/signed@" + testDigest}},
+		},
+		{
+			name: "signed cronjob",
+			kind: "CronJob",
+			obj: &batchv1.CronJob{Spec: batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{InitContainers: []corev1.Container{{Image: "gcr.io/foo/signed"}}}},
+			}}}},
+			wantAllowed: true,
+			wantPatch:   []patchOperation{{Op: "replace", Path: "/spec/jobTemplate/spec/template/spec/initContainers/0/image", Value: "gcr.io/foo/signed@" + testDigest}},
+		},
+		{
+			name: "unsigned deployment",
+			kind: "Deployment",
+			obj:  &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: pod("gcr.io/foo/unsigned")}}},
+		},
+		{
+			name: "invalid reference",
+			kind: "Pod",
+			obj:  &corev1.Pod{Spec: pod("gcr.io/foo/UPPER")},
+		},
+		{
+			name:        "no pods",
+			kind:        "ConfigMap",
+			obj:         &corev1.ConfigMap{},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := testAdmitter(t).Admit(context.Background(), request(t, tt.kind, tt.obj))
+			if resp.Allowed != tt.wantAllowed {
+				t.Errorf("Admit() allowed = %t, want %t: %+v", resp.Allowed, tt.wantAllowed, resp.Result)
+			}
+			var got []patchOperation
+			if resp.Patch != nil {
+				if err := json.Unmarshal(resp.Patch, &got); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if diff := cmp.Diff(tt.wantPatch, got); diff != "" {
+				t.Errorf("Admit() patch -want +got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  request(t, "Pod", &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Image: "gcr.io/foo/unsigned"}}}}),
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	testAdmitter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/verify-images", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	got := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Response == nil || got.Response.UID != "1234" || got.Response.Allowed {
+		t.Errorf("response = %+v, want a denial of request 1234", got.Response)
+	}
+	if got.APIVersion != "admission.k8s.io/v1" || got.Kind != "AdmissionReview" {
+		t.Errorf("response type = %s %s", got.APIVersion, got.Kind)
+	}
+}